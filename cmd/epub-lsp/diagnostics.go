@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"maps"
+	"path"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/toba/epub-lsp/cmd/epub-lsp/lsp"
+	"github.com/toba/epub-lsp/epublint"
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/packager"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// diagnosticsDebounce is how long the diagnostics goroutine collects further
+// changes before validating, so a burst of edits produces one pass.
+const diagnosticsDebounce = 100 * time.Millisecond
+
+// validationBatch holds the results of one validation pass. Versions records
+// the document version each file's diagnostics were computed from; files
+// the client has not opened have no entry.
+type validationBatch struct {
+	Diagnostics map[string][]epub.Diagnostic
+	Versions    map[string]int
+	// Full is set when the pass re-validated every file in the workspace.
+	Full bool
+}
+
+// runDiagnostics validates queued URIs and publishes their diagnostics until
+// the pending channel is closed.
+func (h *epubHandler) runDiagnostics() {
+	for uri := range h.pending {
+		changed := map[string]bool{uri: true}
+
+		timer := time.NewTimer(diagnosticsDebounce)
+	collect:
+		for {
+			select {
+			case u, ok := <-h.pending:
+				if !ok {
+					break collect
+				}
+				changed[u] = true
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		h.publish(h.validate(changed))
+	}
+}
+
+// validate runs the validators over the changed files against a snapshot of
+// the workspace. When an OPF changed, the files its change can affect are
+// re-validated too, since the manifest feeds the cross-file checks.
+func (h *epubHandler) validate(changed map[string]bool) validationBatch {
+	start := time.Now()
+	h.store.mu.RLock()
+	files := maps.Clone(h.store.RawFiles)
+	versions := maps.Clone(h.store.Versions)
+	opts := h.store.lintOptions()
+	h.store.mu.RUnlock()
+
+	ws := epublint.NewWorkspace(files, opts)
+
+	h.store.mu.Lock()
+	previous := h.store.Manifest
+	if m := ws.Manifest(); m != nil {
+		h.store.Manifest = m
+	}
+	h.store.mu.Unlock()
+
+	targets := slices.Collect(maps.Keys(changed))
+	full := false
+	for u := range changed {
+		// The mimetype file's content is checked from the package documents
+		if path.Base(uriutil.Path(u)) == packager.MimetypeName {
+			for f := range files {
+				if ws.FileType(f) == epub.FileTypeOPF {
+					targets = append(targets, f)
+				}
+			}
+		}
+	}
+	for u := range changed {
+		if ws.FileType(u) != epub.FileTypeOPF {
+			continue
+		}
+		scope, all := h.manifestScope(u, previous, ws, files)
+		if all {
+			targets = slices.Collect(maps.Keys(files))
+			full = true
+			break
+		}
+		targets = append(targets, scope...)
+	}
+
+	batch := validationBatch{
+		Diagnostics: make(map[string][]epub.Diagnostic, len(targets)),
+		Versions:    make(map[string]int, len(targets)),
+		Full:        full,
+	}
+
+	for _, result := range ws.ValidateFiles(targets...) {
+		batch.Diagnostics[result.URI] = result.Diagnostics
+		if v, ok := versions[result.URI]; ok {
+			batch.Versions[result.URI] = v
+		}
+	}
+
+	// Files removed from the workspace have their diagnostics cleared
+	for u := range changed {
+		if _, ok := files[u]; !ok {
+			batch.Diagnostics[u] = nil
+		}
+	}
+
+	finished := time.Now()
+	h.store.mu.Lock()
+	h.store.LastValidation = &lsp.ValidationMetrics{
+		Finished:   finished,
+		DurationMs: float64(finished.Sub(start).Microseconds()) / 1000,
+		Files:      len(targets),
+	}
+	h.store.mu.Unlock()
+
+	return batch
+}
+
+// manifestScope returns the files to re-validate after the package document
+// at opfURI changed from previous to the workspace manifest, or reports that
+// every file must be. Metadata changes only affect the package document's
+// own checks; spine changes also affect navigation documents; and item
+// changes affect navigation and container documents, the documents the
+// items name, and the documents that refer to them.
+func (h *epubHandler) manifestScope(
+	opfURI string,
+	previous *epublint.Manifest,
+	ws *epublint.Workspace,
+	files map[string][]byte,
+) (scope []string, all bool) {
+	next := ws.Manifest()
+	opfs := 0
+	for u := range files {
+		if ws.FileType(u) == epub.FileTypeOPF {
+			opfs++
+		}
+	}
+	if previous == nil || next == nil || opfs > 1 {
+		h.logger.Debug("package document changed", "uri", opfURI, "scope", "all")
+		return nil, true
+	}
+
+	diff := previous.Diff(next)
+	if diff.PackageChanged {
+		h.logger.Debug("package document changed",
+			"uri", opfURI, "change", diff.String(), "scope", "all")
+		return nil, true
+	}
+
+	scope = []string{opfURI}
+	if diff.SpineChanged || diff.ItemsChanged() {
+		for u := range files {
+			switch ws.FileType(u) {
+			case epub.FileTypeNav, epub.FileTypeNCX:
+				scope = append(scope, u)
+			case epub.FileTypeContainer:
+				if diff.ItemsChanged() {
+					scope = append(scope, u)
+				}
+			}
+		}
+	}
+	if diff.ItemsChanged() {
+		items := slices.Concat(diff.Added, diff.Removed, diff.Changed, diff.ChangedFrom)
+		for u, content := range files {
+			if referencesItem(opfURI, u, content, ws.FileType(u), items) {
+				scope = append(scope, u)
+			}
+		}
+	}
+
+	h.logger.Debug("package document changed",
+		"uri", opfURI, "change", diff.String(), "scope", len(scope))
+	return scope, false
+}
+
+// referencesItem reports whether the file at uri is one of items or, for
+// content documents and stylesheets, mentions the file name of one.
+func referencesItem(
+	opfURI, uri string,
+	content []byte,
+	fileType epub.FileType,
+	items []validator.ManifestItem,
+) bool {
+	p := uriutil.Path(uri)
+	refers := fileType == epub.FileTypeXHTML || fileType == epub.FileTypeNav ||
+		fileType == epub.FileTypeCSS
+	for _, item := range items {
+		href := epub.StripFragment(item.Href)
+		if href == "" {
+			continue
+		}
+		if uriutil.Path(uriutil.ResolveRelative(opfURI, href)) == p {
+			return true
+		}
+		if refers && bytes.Contains(content, []byte(path.Base(href))) {
+			return true
+		}
+	}
+	return false
+}
+
+// publish stores the diagnostics in batch and sends those that changed, in
+// URI order, as one uninterrupted burst. Results computed from a document
+// version older than the one the client has since sent are discarded; the
+// pass queued by that newer change will publish instead. A full pass also
+// clears stored diagnostics for files that are no longer in the workspace.
+func (h *epubHandler) publish(batch validationBatch) {
+	var notifications [][]byte
+
+	h.store.mu.Lock()
+	if batch.Full {
+		for uri := range h.store.Diagnostics {
+			if _, ok := batch.Diagnostics[uri]; !ok {
+				batch.Diagnostics[uri] = nil
+			}
+		}
+	}
+
+	for _, uri := range slices.Sorted(maps.Keys(batch.Diagnostics)) {
+		diags := batch.Diagnostics[uri]
+		version, tracked := batch.Versions[uri]
+
+		current, open := h.store.Versions[uri]
+		if tracked != open || current != version {
+			slog.Debug("discarding stale diagnostics",
+				"uri", uri, "version", version, "current", current)
+			continue
+		}
+
+		diags = clampDiagnostics(uri, h.store.RawFiles[uri], diags)
+		previous, published := h.store.Diagnostics[uri]
+		if published && slices.EqualFunc(previous, diags, sameDiagnostic) {
+			continue
+		}
+		if _, exists := h.store.RawFiles[uri]; exists {
+			h.store.Diagnostics[uri] = diags
+		} else {
+			delete(h.store.Diagnostics, uri)
+		}
+
+		var versionPtr *int
+		if tracked {
+			versionPtr = &version
+		}
+		notifications = append(notifications,
+			lsp.PublishDiagnosticsNotification(uri, versionPtr, diags))
+	}
+	h.store.mu.Unlock()
+
+	h.sendAll(notifications)
+}
+
+// clampDiagnostics moves the ranges of diags within content, since some
+// clients reject ranges past the end of the document. Each range that had
+// to move is logged at debug level with the diagnostic's source and code,
+// to find the validator that produced it. diags is copied before any
+// change.
+func clampDiagnostics(uri string, content []byte, diags []epub.Diagnostic) []epub.Diagnostic {
+	if len(diags) == 0 {
+		return diags
+	}
+	lines := epub.NewLineIndex(content)
+	copied := false
+	for i, d := range diags {
+		rng, changed := lines.ClampRange(d.Range)
+		if !changed {
+			continue
+		}
+		slog.Debug("clamped diagnostic range", "uri", uri,
+			"source", d.Source, "code", d.Code, "range", d.Range, "clamped", rng)
+		if !copied {
+			diags = slices.Clone(diags)
+			copied = true
+		}
+		diags[i].Range = rng
+	}
+	return diags
+}
+
+// sameDiagnostic reports whether two diagnostics would look the same to the
+// client.
+func sameDiagnostic(a, b epub.Diagnostic) bool {
+	return a.Code == b.Code && a.Severity == b.Severity &&
+		a.Message == b.Message && a.Range == b.Range &&
+		a.Source == b.Source && reflect.DeepEqual(a.Fix, b.Fix)
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/cmd/epub-lsp/lsp"
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/lsp/transport"
+)
+
+const chapterURI = "file:///book/chapter.xhtml"
+
+var missingAlt = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Chapter</title></head>
+<body><img src="a.png"/></body>
+</html>
+`)
+
+var withAlt = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Chapter</title></head>
+<body><img src="a.png" alt="A"/></body>
+</html>
+`)
+
+// readPublished decodes every publishDiagnostics notification written to out.
+func readPublished(t *testing.T, out *bytes.Buffer) []lsp.PublishDiagnosticsParams {
+	t.Helper()
+	var result []lsp.PublishDiagnosticsParams
+	scanner := transport.NewScanner(out)
+	for scanner.Scan() {
+		var msg lsp.NotificationMessage[lsp.PublishDiagnosticsParams]
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatalf("failed to unmarshal notification: %v", err)
+		}
+		if msg.Method != lsp.MethodPublishDiagnostics {
+			t.Fatalf("unexpected method %q", msg.Method)
+		}
+		result = append(result, msg.Params)
+	}
+	return result
+}
+
+func TestPublishIncludesVersion(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	h.updateDocument(chapterURI, missingAlt, 3)
+	h.publish(h.validate(map[string]bool{chapterURI: true}))
+
+	published := readPublished(t, &out)
+	if len(published) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(published))
+	}
+	if published[0].Version == nil || *published[0].Version != 3 {
+		t.Errorf("expected version 3, got %v", published[0].Version)
+	}
+}
+
+func TestPublishClampsRanges(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+	content := []byte("<p>one</p>\n<p>two</p>")
+	h.updateDocument(chapterURI, content, 1)
+
+	batch := validationBatch{
+		Diagnostics: map[string][]epub.Diagnostic{chapterURI: {{
+			Code:     "test",
+			Severity: epub.SeverityError,
+			Message:  "computed against a longer buffer",
+			Range: epub.Range{
+				Start: epub.Position{Line: 40, Character: 3},
+				End:   epub.Position{Line: 0, Character: 999},
+			},
+		}}},
+		Versions: map[string]int{chapterURI: 1},
+	}
+	h.publish(batch)
+
+	published := readPublished(t, &out)
+	if len(published) != 1 || len(published[0].Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %+v", published)
+	}
+	want := lsp.Range{
+		Start: lsp.Position{Line: 0, Character: 10},
+		End:   lsp.Position{Line: 1, Character: 10},
+	}
+	if got := published[0].Diagnostics[0].Range; got != want {
+		t.Errorf("published range = %+v, want %+v", got, want)
+	}
+	if got := batch.Diagnostics[chapterURI][0].Range.Start.Line; got != 40 {
+		t.Errorf("clamping changed the validator's diagnostic, start line = %d", got)
+	}
+}
+
+func TestStaleDiagnosticsDiscarded(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	h.updateDocument(chapterURI, missingAlt, 1)
+	stale := h.validate(map[string]bool{chapterURI: true})
+
+	h.updateDocument(chapterURI, withAlt, 2)
+	fresh := h.validate(map[string]bool{chapterURI: true})
+
+	// The pass for version 2 finishes before the one for version 1.
+	h.publish(fresh)
+	h.publish(stale)
+
+	published := readPublished(t, &out)
+	if len(published) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(published))
+	}
+	if published[0].Version == nil || *published[0].Version != 2 {
+		t.Errorf("expected version 2, got %v", published[0].Version)
+	}
+	if testutil.HasCode(h.store.GetDiagnostics(chapterURI), "HTM_008") {
+		t.Error("stale HTM_008 diagnostic replaced the current diagnostics")
+	}
+}
+
+func TestStaleDiagnosticsDiscardedBeforeNewerPass(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	h.updateDocument(chapterURI, withAlt, 1)
+	stale := h.validate(map[string]bool{chapterURI: true})
+
+	// A newer edit arrives while the version 1 pass is still running.
+	h.updateDocument(chapterURI, missingAlt, 2)
+	h.publish(stale)
+
+	if published := readPublished(t, &out); len(published) != 0 {
+		t.Fatalf("expected stale diagnostics to be dropped, got %d", len(published))
+	}
+
+	h.publish(h.validate(map[string]bool{chapterURI: true}))
+
+	published := readPublished(t, &out)
+	if len(published) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(published))
+	}
+	if !testutil.HasCode(h.store.GetDiagnostics(chapterURI), "HTM_008") {
+		t.Error("expected HTM_008 from the version 2 pass")
+	}
+}
+
+func TestOPFChangeRevalidatesWorkspace(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	opfURI := "file:///book/content.opf"
+	h.updateDocument(chapterURI, withAlt, 1)
+	h.updateDocument(opfURI, []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata/>
+  <manifest/>
+  <spine/>
+</package>`), 1)
+
+	h.publish(h.validate(map[string]bool{opfURI: true}))
+
+	seen := make(map[string]bool)
+	for _, p := range readPublished(t, &out) {
+		seen[p.Uri] = true
+	}
+	if !seen[opfURI] || !seen[chapterURI] {
+		t.Errorf("expected diagnostics for both files, got %v", seen)
+	}
+}
+
+func TestUnchangedDiagnosticsNotRepublished(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	h.updateDocument(chapterURI, missingAlt, 1)
+	h.publish(h.validate(map[string]bool{chapterURI: true}))
+	if published := readPublished(t, &out); len(published) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(published))
+	}
+
+	h.publish(h.validate(map[string]bool{chapterURI: true}))
+	if published := readPublished(t, &out); len(published) != 0 {
+		t.Errorf("expected identical results to publish nothing, got %d", len(published))
+	}
+}
+
+func TestClearedDiagnosticsPublishedOnce(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	h.updateDocument(chapterURI, missingAlt, 1)
+	h.publish(h.validate(map[string]bool{chapterURI: true}))
+	readPublished(t, &out)
+
+	// Removing the image clears its diagnostics; the second identical pass
+	// must not publish the empty set again.
+	fixed := bytes.Replace(withAlt, []byte(`<img src="a.png" alt="A"/>`), nil, 1)
+	for version := 2; version <= 3; version++ {
+		h.updateDocument(chapterURI, fixed, version)
+		h.publish(h.validate(map[string]bool{chapterURI: true}))
+	}
+
+	published := readPublished(t, &out)
+	if len(published) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(published))
+	}
+	if len(published[0].Diagnostics) != 0 {
+		t.Errorf("expected an empty diagnostic set, got %v", published[0].Diagnostics)
+	}
+}
+
+func TestPublishOrderedByURI(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	uris := []string{
+		"file:///book/c.xhtml", "file:///book/a.xhtml", "file:///book/b.xhtml",
+	}
+	changed := make(map[string]bool)
+	for _, uri := range uris {
+		h.updateDocument(uri, missingAlt, 1)
+		changed[uri] = true
+	}
+	h.publish(h.validate(changed))
+
+	var got []string
+	for _, p := range readPublished(t, &out) {
+		got = append(got, p.Uri)
+	}
+	if !slices.IsSorted(got) || len(got) != len(uris) {
+		t.Errorf("expected all URIs in order, got %v", got)
+	}
+}
+
+// incompleteOPF is a package document without metadata.
+var incompleteOPF = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest/>
+  <spine/>
+</package>`)
+
+func TestLanguageIDDecidesFileType(t *testing.T) {
+	tests := []struct {
+		name, uri, languageID string
+	}{
+		{"untitled buffer", "untitled:Untitled-1", "epub-opf"},
+		{"xml extension", "file:///book/package.xml", "xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			h := newEpubHandler(&out)
+
+			h.openDocument(tt.uri, incompleteOPF, 1, tt.languageID)
+			if got := h.store.GetFileType(tt.uri); got != epub.FileTypeOPF {
+				t.Fatalf("expected OPF, got %v", got)
+			}
+			h.publish(h.validate(map[string]bool{tt.uri: true}))
+
+			published := readPublished(t, &out)
+			if len(published) != 1 || published[0].Uri != tt.uri {
+				t.Fatalf("expected diagnostics for %s, got %v", tt.uri, published)
+			}
+			testutil.ExpectCode(t,
+				testutil.DiagCodes(h.store.GetDiagnostics(tt.uri)), "OPF_030")
+		})
+	}
+}
+
+func TestUnrecognizedDocumentIgnored(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	h.openDocument("file:///book/notes.txt", []byte("notes"), 1, "plaintext")
+	if h.store.GetContent("file:///book/notes.txt") != nil {
+		t.Error("expected a plain text document to be ignored")
+	}
+}
+
+// didOpen builds a textDocument/didOpen notification for an XHTML document.
+func didOpen(t *testing.T, uri string, content []byte) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  lsp.MethodDidOpen,
+		"params": map[string]any{"textDocument": map[string]any{
+			"uri": uri, "languageId": "xhtml", "version": 1, "text": string(content),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSingleFileWithoutRoot(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"rootUri":null}}`))
+	out.Reset()
+	uri := "file:///tmp/loose/chapter.xhtml"
+	h.handleMessage(didOpen(t, uri, missingAlt))
+	h.publish(h.validate(map[string]bool{<-h.pending: true}))
+
+	published := readPublished(t, &out)
+	if len(published) != 1 || published[0].Uri != uri {
+		t.Fatalf("expected diagnostics for %s, got %v", uri, published)
+	}
+	// Only the document's own checks run without a package document
+	codes := testutil.DiagCodes(h.store.GetDiagnostics(uri))
+	testutil.ExpectCode(t, codes, "HTM_008")
+	for code := range codes {
+		if strings.HasPrefix(code, "RSC_") || strings.HasPrefix(code, "OPF_") {
+			t.Errorf("unexpected cross-file diagnostic %s", code)
+		}
+	}
+	if got := h.store.GetRootPath(); got != "" {
+		t.Errorf("expected no root, got %q", got)
+	}
+}
+
+func TestRootInferredFromPackageDocument(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	// The client opens documents before initializing
+	h.handleMessage(didOpen(t, chapterURI, withAlt))
+	h.openDocument("file:///book/OEBPS/content.opf", incompleteOPF, 1, "opf")
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"rootUri":null}}`))
+	out.Reset()
+
+	if got, want := h.store.GetRootPath(), filepath.FromSlash("/book/OEBPS"); got != want {
+		t.Errorf("expected root %q from the package document, got %q", want, got)
+	}
+
+	changed := make(map[string]bool)
+	for len(h.pending) > 0 {
+		changed[<-h.pending] = true
+	}
+	h.publish(h.validate(changed))
+
+	seen := make(map[string]bool)
+	for _, p := range readPublished(t, &out) {
+		seen[p.Uri] = true
+	}
+	if !seen[chapterURI] || !seen["file:///book/OEBPS/content.opf"] {
+		t.Errorf("expected documents opened before initialize to be validated, got %v", seen)
+	}
+}
+
+// scopedOPF returns a package document listing two chapters and an image,
+// with title as its dc:title and image as the image href.
+func scopedOPF(title, image string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="id">urn:uuid:1</dc:identifier>
+    <dc:title>` + title + `</dc:title>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest>
+    <item id="ch1" href="chapter.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="other.xhtml" media-type="application/xhtml+xml"/>
+    <item id="img" href="` + image + `" media-type="image/png"/>
+  </manifest>
+  <spine>
+    <itemref idref="ch1"/>
+    <itemref idref="ch2"/>
+  </spine>
+</package>`)
+}
+
+func TestMimetypeChangeRevalidatesPackage(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	const (
+		opfURI      = "file:///book/content.opf"
+		mimetypeURI = "file:///book/mimetype"
+	)
+	h.updateDocument(chapterURI, withAlt, 1)
+	h.updateDocument(opfURI, scopedOPF("Book", "a.png"), 1)
+	h.loadFromDisk(mimetypeURI, []byte("application/epub+zip\n"))
+
+	batch := h.validate(map[string]bool{mimetypeURI: true})
+	if _, ok := batch.Diagnostics[opfURI]; !ok {
+		t.Error("expected the package document to be re-validated")
+	}
+	if _, ok := batch.Diagnostics[chapterURI]; ok {
+		t.Error("expected the chapter not to be re-validated")
+	}
+}
+
+func TestOPFChangeScopesRevalidation(t *testing.T) {
+	const (
+		opfURI   = "file:///book/content.opf"
+		otherURI = "file:///book/other.xhtml"
+	)
+	tests := []struct {
+		name          string
+		title, image  string
+		wantValidated []string
+	}{
+		{"metadata only", "Renamed", "a.png", []string{opfURI}},
+		{"image href", "Book", "b.png", []string{chapterURI, opfURI}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			h := newEpubHandler(&out)
+			h.updateDocument(chapterURI, missingAlt, 1)
+			h.updateDocument(otherURI, bytes.ReplaceAll(missingAlt, []byte("a.png"), []byte("c.png")), 1)
+			h.updateDocument(opfURI, scopedOPF("Book", "a.png"), 1)
+			h.publish(h.validate(map[string]bool{opfURI: true}))
+			out.Reset()
+
+			h.updateDocument(opfURI, scopedOPF(tt.title, tt.image), 2)
+			batch := h.validate(map[string]bool{opfURI: true})
+			if batch.Full {
+				t.Error("expected a scoped pass")
+			}
+			validated := slices.Sorted(maps.Keys(batch.Diagnostics))
+			if !slices.Equal(validated, tt.wantValidated) {
+				t.Errorf("validated %v, want %v", validated, tt.wantValidated)
+			}
+
+			h.publish(batch)
+			for _, p := range readPublished(t, &out) {
+				if p.Uri == otherURI {
+					t.Errorf("expected no diagnostics republished for %s", otherURI)
+				}
+			}
+		})
+	}
+}
+
+func TestByteOrderMarkDoesNotShiftFirstLine(t *testing.T) {
+	tests := []struct {
+		name, uri, content, code string
+	}{
+		{"xhtml", "file:///book/chapter.xhtml",
+			`<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en"><head><title>C</title></head><body><img src="a.png"/></body></html>`,
+			"HTM_008"},
+		{"opf", "file:///book/content.opf",
+			`<package xmlns="http://www.idpf.org/2007/opf" version="3.0"><manifest/><spine/></package>`,
+			"OPF_030"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges := make(map[bool]epub.Range)
+			for _, bom := range []bool{false, true} {
+				content := tt.content
+				if bom {
+					content = epub.BOM + content
+				}
+				h := newEpubHandler(io.Discard)
+				h.updateDocument(tt.uri, []byte(content), 1)
+				if got := h.store.HasBOM(tt.uri); got != bom {
+					t.Errorf("HasBOM = %v, want %v", got, bom)
+				}
+				if got := string(h.store.GetContent(tt.uri)); got != tt.content {
+					t.Errorf("expected the BOM to be stripped, got %q", got)
+				}
+
+				h.publish(h.validate(map[string]bool{tt.uri: true}))
+				for _, d := range h.store.GetDiagnostics(tt.uri) {
+					if d.Code == tt.code {
+						ranges[bom] = d.Range
+					}
+				}
+			}
+			if len(ranges) != 2 {
+				t.Fatalf("expected %s with and without a BOM, got %v", tt.code, ranges)
+			}
+			if ranges[true] != ranges[false] {
+				t.Errorf("range with BOM %+v, without %+v", ranges[true], ranges[false])
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import "github.com/toba/epub-lsp/cmd/epub-lsp/lsp"
+
+// health summarizes the workspace and the latest validation pass for the
+// $/epubLsp/health request.
+func (h *epubHandler) health() lsp.HealthResult {
+	h.store.mu.RLock()
+	result := lsp.HealthResult{
+		Version:   version,
+		Files:     len(h.store.RawFiles),
+		FileTypes: make(map[string]int),
+	}
+	for uri := range h.store.RawFiles {
+		result.FileTypes[h.store.FileTypes[uri].String()]++
+	}
+	if h.store.LastValidation != nil {
+		last := *h.store.LastValidation
+		result.LastValidation = &last
+	}
+	for _, diags := range h.store.Diagnostics {
+		result.Diagnostics.Add(diags)
+	}
+	h.store.mu.RUnlock()
+
+	result.Memory = lsp.ReadMemoryStats()
+	return result
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/toba/epub-lsp/cmd/epub-lsp/lsp"
+	"github.com/toba/lsp/transport"
+)
+
+// readResponse decodes the single response written to out.
+func readResponse[T any](t *testing.T, out *bytes.Buffer) lsp.ResponseMessage[T] {
+	t.Helper()
+	scanner := transport.NewScanner(out)
+	if !scanner.Scan() {
+		t.Fatal("expected a response")
+	}
+	var msg lsp.ResponseMessage[T]
+	if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return msg
+}
+
+func TestHealth(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"$/epubLsp/health"}`))
+	before := readResponse[lsp.HealthResult](t, &out).Result
+	if before.Files != 0 || before.LastValidation != nil {
+		t.Errorf("expected an empty workspace before validation, got %+v", before)
+	}
+
+	styleURI := "file:///book/style.css"
+	h.updateDocument(chapterURI, missingAlt, 1)
+	h.updateDocument("file:///book/chapter2.xhtml", withAlt, 1)
+	h.updateDocument(styleURI, []byte("p { margin: 0 }"), 1)
+	h.publish(h.validate(map[string]bool{
+		chapterURI: true, "file:///book/chapter2.xhtml": true, styleURI: true,
+	}))
+
+	var want lsp.SeverityCounts
+	for _, p := range readPublished(t, &out) {
+		for _, d := range p.Diagnostics {
+			switch d.Severity {
+			case 1:
+				want.Errors++
+			case 2:
+				want.Warnings++
+			case 3:
+				want.Infos++
+			default:
+				want.Hints++
+			}
+		}
+	}
+	if want == (lsp.SeverityCounts{}) {
+		t.Fatal("expected the missing alt text to be published")
+	}
+
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"$/epubLsp/health"}`))
+	resp := readResponse[lsp.HealthResult](t, &out)
+	if resp.Error != nil || resp.Id != lsp.NumberID(2) {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	got := resp.Result
+	if got.Version != version {
+		t.Errorf("version = %q, want %q", got.Version, version)
+	}
+	if got.Files != 3 || got.FileTypes["XHTML"] != 2 || got.FileTypes["CSS"] != 1 {
+		t.Errorf("files = %d %v, want 2 XHTML and 1 CSS", got.Files, got.FileTypes)
+	}
+	if got.LastValidation == nil || got.LastValidation.Files != 3 ||
+		got.LastValidation.Finished.IsZero() || got.LastValidation.DurationMs < 0 {
+		t.Errorf("lastValidation = %+v", got.LastValidation)
+	}
+	if got.Diagnostics != want {
+		t.Errorf("diagnostics = %+v, want %+v", got.Diagnostics, want)
+	}
+	if got.Memory.HeapAlloc == 0 || got.Memory.Sys == 0 {
+		t.Errorf("memory = %+v", got.Memory)
+	}
+}
+
+func TestUnknownCustomMethodNotFound(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","id":3,"method":"$/epubLsp/unknown"}`))
+	resp := readResponse[any](t, &out)
+	if resp.Error == nil || resp.Error.Code != lsp.ErrorMethodNotFound {
+		t.Errorf("expected MethodNotFound, got %+v", resp)
+	}
+}
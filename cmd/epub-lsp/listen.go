@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/toba/epub-lsp/internal/replay"
+)
+
+// serveListener serves the first connection accepted on ln as if it were
+// stdin/stdout, recording to recorder unless it is nil, then closes ln. Connections made while it is being served
+// are closed straight away, since the server holds a single workspace.
+// Closing the connection ends the session like exit.
+func serveListener(ln net.Listener, recorder *replay.Recorder) error {
+	conn, err := ln.Accept()
+	if err != nil {
+		_ = ln.Close()
+		return err
+	}
+	slog.Info("accepted connection from " + conn.RemoteAddr().String())
+
+	var rejecting sync.WaitGroup
+	rejecting.Go(func() {
+		for {
+			other, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					slog.Error("error accepting connection: " + err.Error())
+				}
+				return
+			}
+			slog.Warn("rejecting connection from " + other.RemoteAddr().String() +
+				": a client is already connected")
+			_ = other.Close()
+		}
+	})
+
+	err = serve(conn, conn, recorder)
+	_ = conn.Close()
+	_ = ln.Close()
+	rejecting.Wait()
+	return err
+}
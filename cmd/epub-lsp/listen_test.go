@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toba/lsp/transport"
+)
+
+func TestServeListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- serveListener(ln, nil) }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(sessionTimeout))
+	scanner := transport.NewScanner(conn)
+
+	// answer sends body and returns the server's reply.
+	answer := func(body string) string {
+		t.Helper()
+		if _, err := conn.Write(frame([]byte(body))); err != nil {
+			t.Fatal(err)
+		}
+		if !scanner.Scan() {
+			t.Fatalf("no answer to %s: %v", body, scanner.Err())
+		}
+		return string(scanner.Bytes())
+	}
+
+	reply := answer(`{"jsonrpc":"2.0","id":1,"method":"initialize",` +
+		`"params":{"processId":1,"rootUri":"file:///book","capabilities":{}}}`)
+	if !strings.Contains(reply, `"capabilities"`) {
+		t.Errorf("expected initialize result, got %s", reply)
+	}
+
+	// A second client is disconnected while the first is served
+	second, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	_ = second.SetDeadline(time.Now().Add(sessionTimeout))
+	if _, err := second.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Errorf("expected second connection to be closed, got %v", err)
+	}
+
+	answer(`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`)
+	if _, err := conn.Write(frame([]byte(`{"jsonrpc":"2.0","method":"exit"}`))); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("expected clean exit, got %v", err)
+		}
+	case <-time.After(sessionTimeout):
+		t.Fatal("server did not exit")
+	}
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Error("expected the listener to be closed after exit")
+	}
+}
+
+func TestServeListenerConnectionClosed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- serveListener(ln, nil) }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	select {
+	case err := <-served:
+		if !errors.Is(err, errExitBeforeShutdown) {
+			t.Errorf("expected errExitBeforeShutdown, got %v", err)
+		}
+	case <-time.After(sessionTimeout):
+		t.Fatal("server did not stop when the connection closed")
+	}
+}
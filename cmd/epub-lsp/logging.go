@@ -0,0 +1,139 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+
+	"github.com/toba/epub-lsp/cmd/epub-lsp/lsp"
+	"github.com/toba/epub-lsp/internal/logging"
+)
+
+// logLevel is the minimum level of the default logger. It is set from the
+// logLevel setting and can change while the server runs.
+var logLevel = new(slog.LevelVar)
+
+// maxTraceBytes is the longest message body logged when tracing. Longer
+// bodies, such as whole documents in didOpen, are truncated.
+const maxTraceBytes = 4096
+
+// logOutput is where the default logger writes once configureLogging has
+// installed it. It discards the log until startLogging picks a sink.
+var logOutput *logging.Output
+
+// logFlag is the sink named by the log flag, which takes precedence over
+// the log setting, or empty when the flag was not given.
+var logFlag string
+
+// configureLogging makes the default logger write JSON to logOutput,
+// filtered by logLevel.
+func configureLogging() {
+	logOutput = new(logging.Output)
+	slog.SetDefault(slog.New(&levelHandler{
+		Handler: slog.NewJSONHandler(logOutput, nil),
+		level:   logLevel,
+	}))
+}
+
+// startLogging sends the log to the sink named by the log flag, or to the
+// log file in the user's cache directory when sink is empty.
+func startLogging(sink string) {
+	logFlag = sink
+	useLogSink(cmp.Or(sink, logging.SinkFile), 0)
+}
+
+// useLogSink switches logOutput to sink, keeping maxFiles rotated log
+// files, or logging.DefaultMaxFiles when maxFiles is not positive. When
+// the log file cannot be used, the log goes to stderr instead. Without
+// configureLogging, as in tests, it does nothing.
+func useLogSink(sink string, maxFiles int) {
+	if logOutput == nil {
+		return
+	}
+	if maxFiles <= 0 {
+		maxFiles = logging.DefaultMaxFiles
+	}
+	path, err := logging.DefaultPath(serverName)
+	if err == nil {
+		err = logOutput.Use(sink, path, maxFiles)
+	}
+	if err != nil {
+		_ = logOutput.Use(logging.SinkStderr, "", 0)
+		slog.Warn("logging to stderr: " + err.Error())
+	}
+}
+
+// levelHandler drops records below level, which, unlike the level of the
+// handler it wraps, can be changed after the logger is created.
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h *levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// applyLogSettings sets the log level and, unless the log flag was given,
+// the log sink from settings. Unknown values are logged and otherwise
+// ignored.
+func (h *epubHandler) applyLogSettings(settings *lsp.ServerSettings) {
+	if settings == nil {
+		return
+	}
+	if settings.LogLevel != "" {
+		if level, ok := lsp.ParseLogLevel(settings.LogLevel); ok {
+			h.level.Set(level)
+		} else {
+			h.logger.Warn("ignoring unknown logLevel", "logLevel", settings.LogLevel)
+		}
+	}
+
+	if logFlag != "" || settings.Log == "" && settings.LogMaxFiles == 0 {
+		return
+	}
+	sink := cmp.Or(settings.Log, logging.SinkFile)
+	if !logging.ValidSink(sink) {
+		h.logger.Warn("ignoring unknown log", "log", settings.Log)
+		return
+	}
+	useLogSink(sink, settings.LogMaxFiles)
+}
+
+// logSessionStart marks the start of a session in the log with the server
+// version and the client named in settings.
+func (h *epubHandler) logSessionStart(settings *lsp.ServerSettings) {
+	h.logger.Info("session started",
+		"version", version,
+		"client", settings.Client.Name,
+		"clientVersion", settings.Client.Version)
+}
+
+// setTrace turns message tracing on for any value other than "off".
+func (h *epubHandler) setTrace(value string) {
+	h.tracing.Store(value != lsp.TraceOff)
+}
+
+// traceMessage logs a message to or from the client at debug level when
+// tracing is on, truncating large bodies.
+func (h *epubHandler) traceMessage(direction string, data []byte) {
+	if !h.tracing.Load() {
+		return
+	}
+	body := data
+	if len(body) > maxTraceBytes {
+		body = body[:maxTraceBytes]
+	}
+	h.logger.Debug(direction,
+		"message", string(body),
+		"bytes", len(data),
+		"truncated", len(body) < len(data))
+}
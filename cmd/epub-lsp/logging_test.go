@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/cmd/epub-lsp/lsp"
+)
+
+// testLogger returns a handler whose logger writes to a buffer through a
+// levelHandler on its own level, leaving the default logger untouched.
+func testLogger(out io.Writer) (*epubHandler, *bytes.Buffer) {
+	var buf bytes.Buffer
+	h := newEpubHandler(out)
+	h.level = new(slog.LevelVar)
+	h.logger = slog.New(&levelHandler{
+		Handler: slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		level:   h.level,
+	})
+	return h, &buf
+}
+
+func TestLogLevelSetting(t *testing.T) {
+	h, buf := testLogger(io.Discard)
+
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize",` +
+		`"params":{"initializationOptions":{"logLevel":"debug"}}}`))
+	if got := h.level.Level(); got != slog.LevelDebug {
+		t.Fatalf("expected debug level after initialize, got %v", got)
+	}
+	h.logger.Debug("before")
+
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","method":"workspace/didChangeConfiguration",` +
+		`"params":{"settings":{"logLevel":"error"}}}`))
+	if got := h.level.Level(); got != slog.LevelError {
+		t.Fatalf("expected error level after didChangeConfiguration, got %v", got)
+	}
+	h.logger.Warn("after")
+
+	if !strings.Contains(buf.String(), "before") {
+		t.Error("expected the debug line while at debug level")
+	}
+	if strings.Contains(buf.String(), "after") {
+		t.Error("expected the warning to be dropped at error level")
+	}
+
+	// An unknown level keeps the current one
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","method":"workspace/didChangeConfiguration",` +
+		`"params":{"settings":{"logLevel":"loud"}}}`))
+	if got := h.level.Level(); got != slog.LevelError {
+		t.Errorf("expected error level to be kept, got %v", got)
+	}
+}
+
+func TestSessionStartLogged(t *testing.T) {
+	h, buf := testLogger(io.Discard)
+
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize",` +
+		`"params":{"clientInfo":{"name":"Zed","version":"0.150.0"}}}`))
+	for _, want := range []string{"session started", "version=" + version,
+		"client=Zed", "clientVersion=0.150.0"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected log to contain %q, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestSetTrace(t *testing.T) {
+	h, buf := testLogger(io.Discard)
+	h.level.Set(slog.LevelDebug)
+
+	hover := []byte(`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover",` +
+		`"params":{"textDocument":{"uri":"file:///book/missing.xhtml"},` +
+		`"position":{"line":0,"character":0}}}`)
+	h.handleMessage(hover)
+	h.requests.Wait()
+	if strings.Contains(buf.String(), "msg=received") {
+		t.Fatalf("expected no traced messages before $/setTrace, got %q", buf.String())
+	}
+
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","method":"$/setTrace","params":{"value":"verbose"}}`))
+	h.handleMessage(hover)
+	h.requests.Wait()
+	for _, want := range []string{"msg=received", "textDocument/hover", "msg=sent"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected trace to contain %q, got %q", want, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "id=2") {
+		t.Errorf("expected request log lines to carry the id, got %q", buf.String())
+	}
+
+	buf.Reset()
+	long := `{"jsonrpc":"2.0","method":"initialized","params":{"pad":"` +
+		strings.Repeat("x", 2*maxTraceBytes) + `"}}`
+	h.handleMessage([]byte(long))
+	if !strings.Contains(buf.String(), "truncated=true") ||
+		strings.Contains(buf.String(), strings.Repeat("x", maxTraceBytes)) {
+		t.Errorf("expected a truncated trace, got %d bytes", buf.Len())
+	}
+
+	buf.Reset()
+	h.handleMessage([]byte(`{"jsonrpc":"2.0","method":"$/setTrace","params":{"value":"off"}}`))
+	h.handleMessage(hover)
+	h.requests.Wait()
+	if strings.Contains(buf.String(), "msg=sent") {
+		t.Errorf("expected tracing to stop, got %q", buf.String())
+	}
+}
+
+func TestRequestPanicAnsweredWithInternalError(t *testing.T) {
+	var out bytes.Buffer
+	h, buf := testLogger(&out)
+
+	id := lsp.NumberID(7)
+	h.startRequest(&id, lsp.MethodHover, func(context.Context) [][]byte {
+		var positions []int
+		_ = positions[len(positions)+1]
+		return nil
+	})
+	h.requests.Wait()
+
+	if !strings.Contains(out.String(), `"id":7`) ||
+		!strings.Contains(out.String(), `"code":-32603`) ||
+		!strings.Contains(out.String(), "index out of range") {
+		t.Errorf("expected an internal error response, got %q", out.String())
+	}
+	for _, want := range []string{"request handler panicked", "id=7", "stack="} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected log to contain %q, got %q", want, buf.String())
+		}
+	}
+
+	// The server keeps answering requests
+	out.Reset()
+	h.startRequest(&id, lsp.MethodHover, func(context.Context) [][]byte {
+		return [][]byte{[]byte(`{"jsonrpc":"2.0","id":7,"result":null}`)}
+	})
+	h.requests.Wait()
+	if !strings.Contains(out.String(), `"result":null`) {
+		t.Errorf("expected a response after the panic, got %q", out.String())
+	}
+}
@@ -0,0 +1,91 @@
+package lsp
+
+import (
+	"context"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// rangeClamper moves the ranges of a response within the documents they
+// point into before the response is sent, since some clients reject
+// ranges past the end of a document. Each range that had to move is logged
+// at debug level with the method that produced it. Ranges into documents
+// the workspace does not hold are left alone.
+type rangeClamper struct {
+	ctx    context.Context
+	ws     WorkspaceReader
+	method string
+	lines  map[string]*epub.LineIndex
+}
+
+// newRangeClamper returns a clamper for the response to method.
+func newRangeClamper(ctx context.Context, ws WorkspaceReader, method string) *rangeClamper {
+	return &rangeClamper{ctx: ctx, ws: ws, method: method, lines: make(map[string]*epub.LineIndex)}
+}
+
+// clamp moves r within the document at uri.
+func (c *rangeClamper) clamp(uri string, r *Range) {
+	lines, ok := c.lines[uri]
+	if !ok {
+		if content := c.ws.GetContent(uri); content != nil {
+			lines = epub.NewLineIndex(content)
+		}
+		c.lines[uri] = lines
+	}
+	if lines == nil {
+		return
+	}
+
+	rng, changed := lines.ClampRange(epub.Range{
+		Start: posToEpub(r.Start),
+		End:   posToEpub(r.End),
+	})
+	if !changed {
+		return
+	}
+	clamped := Range{Start: lspPos(rng.Start), End: lspPos(rng.End)}
+	Logger(c.ctx).Debug("clamped response range", "method", c.method,
+		"uri", uri, "range", *r, "clamped", clamped)
+	*r = clamped
+}
+
+// edits clamps the ranges of edits to the document at uri.
+func (c *rangeClamper) edits(uri string, edits []TextEdit) {
+	for i := range edits {
+		c.clamp(uri, &edits[i].Range)
+	}
+}
+
+// workspaceEdit clamps the ranges of every edit in edit.
+func (c *rangeClamper) workspaceEdit(edit *WorkspaceEdit) {
+	if edit == nil {
+		return
+	}
+	for uri, edits := range edit.Changes {
+		c.edits(uri, edits)
+	}
+}
+
+// codeActions clamps the edits of actions. The diagnostics they fix are
+// echoed from the request and left as the client sent them.
+func (c *rangeClamper) codeActions(actions []CodeAction) {
+	for i := range actions {
+		c.workspaceEdit(actions[i].Edit)
+	}
+}
+
+// locations clamps each location to its own document.
+func (c *rangeClamper) locations(locations []Location) {
+	for i := range locations {
+		c.clamp(locations[i].URI, &locations[i].Range)
+	}
+}
+
+// symbols clamps symbols and their children to the document at uri.
+func (c *rangeClamper) symbols(uri string, symbols []DocumentSymbol) {
+	for i := range symbols {
+		c.clamp(uri, &symbols[i].Range)
+		c.clamp(uri, &symbols[i].SelectionRange)
+		c.symbols(uri, symbols[i].Children)
+	}
+}
@@ -1,14 +1,25 @@
 package lsp
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"log/slog"
+	"path"
+	"path/filepath"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
-	"github.com/toba/lsp/position"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+	"github.com/toba/epub-lsp/internal/epub/validator/opf"
+	"github.com/toba/epub-lsp/internal/epub/validator/whitespace"
+	"github.com/toba/epub-lsp/internal/epub/validator/xhtml"
 )
 
 // autoFixableCodes lists diagnostic codes that can be batch-fixed via source.fixAll.
@@ -20,13 +31,27 @@ var autoFixableCodes = map[string]bool{
 	"metadata-accessibilitysummary": true,
 	"HTM_008":                       true,
 	"epub-type-has-matching-role":   true,
+	"HTM_045":                       true,
+	"RSC_016":                       true,
+	"RSC_025":                       true,
+	"WS_001":                        true,
+	"WS_002":                        true,
+	"WS_003":                        true,
+}
+
+// whitespaceCodes lists the whitespace hygiene codes, which source.fixAll
+// fixes together by formatting the document.
+var whitespaceCodes = map[string]bool{
+	"WS_001": true,
+	"WS_002": true,
+	"WS_003": true,
 }
 
 // HandleCodeAction processes textDocument/codeAction requests.
-func HandleCodeAction(data []byte, ws WorkspaceReader) []byte {
+func HandleCodeAction(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
 	var req RequestMessage[CodeActionParams]
 	if err := json.Unmarshal(data, &req); err != nil {
-		slog.Error("error unmarshalling codeAction: " + err.Error())
+		Logger(ctx).Error("error unmarshalling codeAction: " + err.Error())
 		return marshalResponse(req.Id, []CodeAction{})
 	}
 
@@ -37,63 +62,110 @@ func HandleCodeAction(data []byte, ws WorkspaceReader) []byte {
 	}
 
 	if slices.Contains(req.Params.Context.Only, "source.fixAll") {
-		actions := handleFixAll(uri, content, ws)
+		actions := handleFixAll(ctx, uri, content, ws)
+		if ctx.Err() != nil {
+			return cancelledResponse(req.Id)
+		}
+		newRangeClamper(ctx, ws, MethodCodeAction).codeActions(actions)
 		return marshalResponse(req.Id, actions)
 	}
 
+	disabledSupport := false
+	if settings := ws.GetSettings(); settings != nil {
+		disabledSupport = settings.CodeActionDisabledSupport
+	}
+
 	var actions []CodeAction
 
 	for i := range req.Params.Context.Diagnostics {
-		action := codeActionForDiagnostic(
-			uri,
-			content,
-			&req.Params.Context.Diagnostics[i],
-		)
-		if action != nil {
-			actions = append(actions, *action)
+		diag := &req.Params.Context.Diagnostics[i]
+		diagActions := codeActionsForDiagnostic(uri, content, diag, proseWrap(ws))
+		// Adding to the manifest edits the package document, which only
+		// the workspace can reach. When it cannot, clients that show
+		// disabled actions learn why.
+		if len(diagActions) == 0 && diag.Code == "RSC_008" {
+			action := addManifestItemAction(uri, content, diag, ws)
+			if action.Disabled == nil || disabledSupport {
+				diagActions = append(diagActions, *action)
+				markPreferred(diagActions)
+			}
 		}
+		actions = append(actions, diagActions...)
 	}
 
+	newRangeClamper(ctx, ws, MethodCodeAction).codeActions(actions)
 	return marshalResponse(req.Id, actions)
 }
 
-func handleFixAll(uri string, content []byte, ws WorkspaceReader) []CodeAction {
-	storedDiags := ws.GetDiagnostics(uri)
-	if len(storedDiags) == 0 {
-		return nil
-	}
+// handleFixAll batches the fixes for every auto-fixable diagnostic. The
+// document is re-validated first, since stored diagnostics may come from an
+// older version whose positions no longer match the content. Diagnostics
+// are fixed in position order, then by code, so insertions at the same
+// point always land in the same order; an edit overlapping one already
+// taken is left for the next pass. Whitespace issues are fixed by
+// formatting the whole document when nothing else needs fixing, and one by
+// one otherwise. It stops early when ctx is cancelled.
+func handleFixAll(
+	ctx context.Context,
+	uri string,
+	content []byte,
+	ws WorkspaceReader,
+) []CodeAction {
+	diags := slices.Clone(ws.Validate(uri))
+	slices.SortStableFunc(diags, func(a, b epub.Diagnostic) int {
+		if c := comparePositions(a.Range.Start, b.Range.Start); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Code, b.Code)
+	})
 
 	var edits []TextEdit
-	var fixedDiags []Diagnostic
+	var fixedDiags, whitespaceDiags []Diagnostic
 
-	for _, d := range storedDiags {
+	fix := func(lspDiag Diagnostic) {
+		action := preferredAction(codeActionsForDiagnostic(uri, content, &lspDiag,
+			proseWrap(ws)))
+		if action == nil || action.Edit == nil {
+			return
+		}
+		// A namespace declaration another fix already bundled is dropped
+		actionEdits := slices.DeleteFunc(slices.Clone(action.Edit.Changes[uri]),
+			func(e TextEdit) bool { return slices.Contains(edits, e) })
+		if len(actionEdits) == 0 || slices.ContainsFunc(actionEdits,
+			func(e TextEdit) bool { return overlapsAny(e, edits) }) {
+			return
+		}
+		edits = append(edits, actionEdits...)
+		fixedDiags = append(fixedDiags, lspDiag)
+	}
+
+	for _, d := range diags {
+		if ctx.Err() != nil {
+			return nil
+		}
 		if !autoFixableCodes[d.Code] {
 			continue
 		}
-		lspDiag := Diagnostic{
-			Range: Range{
-				Start: Position{
-					Line:      position.IntToUint(d.Range.Start.Line),
-					Character: position.IntToUint(d.Range.Start.Character),
-				},
-				End: Position{
-					Line:      position.IntToUint(d.Range.End.Line),
-					Character: position.IntToUint(d.Range.End.Character),
-				},
-			},
-			Message:  d.Message,
-			Severity: d.Severity,
-			Code:     d.Code,
-			Source:   d.Source,
-		}
-		action := codeActionForDiagnostic(uri, content, &lspDiag)
-		if action == nil || action.Edit == nil {
+		if whitespaceCodes[d.Code] {
+			whitespaceDiags = append(whitespaceDiags, toLSPDiagnostic(d))
 			continue
 		}
-		for _, fileEdits := range action.Edit.Changes {
-			edits = append(edits, fileEdits...)
+		fix(toLSPDiagnostic(d))
+	}
+
+	if len(whitespaceDiags) > 0 {
+		edit, err := formatDocumentEdit(
+			uri, content, whitespace.Indent(content), proseWrap(ws), true)
+		if err == nil && edit != nil && len(edits) == 0 {
+			edits = []TextEdit{*edit}
+			fixedDiags = whitespaceDiags
+		} else {
+			for _, d := range whitespaceDiags {
+				if d.Code != "WS_001" {
+					fix(d)
+				}
+			}
 		}
-		fixedDiags = append(fixedDiags, lspDiag)
 	}
 
 	if len(edits) == 0 {
@@ -114,7 +186,135 @@ func handleFixAll(uri string, content []byte, ws WorkspaceReader) []CodeAction {
 	}
 }
 
-func codeActionForDiagnostic(uri string, content []byte, diag *Diagnostic) *CodeAction {
+// overlapsAny reports whether edit overlaps any of edits. Insertions at the
+// same position, or at either end of a replaced range, do not overlap;
+// clients apply them in array order.
+func overlapsAny(edit TextEdit, edits []TextEdit) bool {
+	start, end := posToEpub(edit.Range.Start), posToEpub(edit.Range.End)
+	return slices.ContainsFunc(edits, func(e TextEdit) bool {
+		return comparePositions(start, posToEpub(e.Range.End)) < 0 &&
+			comparePositions(posToEpub(e.Range.Start), end) < 0
+	})
+}
+
+// codeActionsForDiagnostic returns the quick fixes for diag, safest first,
+// with the first marked preferred, or nil if it has none. An image missing
+// alt text gets alt derived from the image before the empty alt that marks
+// it decorative, since hiding a meaningful image loses its content.
+func codeActionsForDiagnostic(
+	uri string,
+	content []byte,
+	diag *Diagnostic,
+	proseWrap string,
+) []CodeAction {
+	var actions []CodeAction
+	if diag.Code == "HTM_008" {
+		if action := derivedAltAction(uri, content, diag); action != nil {
+			actions = append(actions, *action)
+		}
+	}
+	if action := codeActionForDiagnostic(uri, content, diag, proseWrap); action != nil {
+		actions = append(actions, *action)
+	}
+	for i := range actions {
+		bundleEpubNamespace(uri, content, diag, &actions[i])
+	}
+	markPreferred(actions)
+	return actions
+}
+
+// epubAttrPattern matches an epub-prefixed attribute being written, as in
+// ` epub:type="`.
+var epubAttrPattern = regexp.MustCompile(`(^|\s)epub:[\w.-]+\s*=`)
+
+// bundleEpubNamespace adds an edit declaring the epub namespace on the root
+// element to an action that writes an epub-prefixed attribute, or edits a
+// start tag at the diagnostic that carries one, in a document whose root
+// does not declare it, so the fix leaves the document namespace-well-formed.
+func bundleEpubNamespace(
+	uri string,
+	content []byte,
+	diag *Diagnostic,
+	action *CodeAction,
+) {
+	if action.Edit == nil || len(action.Edit.Changes[uri]) == 0 ||
+		parser.RootDeclares(content, "epub") {
+		return
+	}
+	edits := action.Edit.Changes[uri]
+	writesEpub := slices.ContainsFunc(edits, func(e TextEdit) bool {
+		return epubAttrPattern.MatchString(e.NewText)
+	})
+	if !writesEpub && !startTagUsesEpub(content, diag) {
+		return
+	}
+
+	edit, ok := namespaceDeclarationEdit(content, xhtml.EpubNamespaceDeclaration)
+	// A fix replacing the root start tag, or the whole document, is left
+	// alone
+	if !ok || slices.Contains(edits, edit) || overlapsAny(edit, edits) {
+		return
+	}
+	action.Edit.Changes[uri] = append(edits, edit)
+}
+
+// startTagUsesEpub reports whether the start tag beginning at the
+// diagnostic's start position has an epub-prefixed attribute.
+func startTagUsesEpub(content []byte, diag *Diagnostic) bool {
+	offset := epub.PositionToByteOffset(content, posToEpub(diag.Range.Start))
+	if offset < 0 || offset >= len(content) || content[offset] != '<' {
+		return false
+	}
+	end := startTagCloseOffset(content, offset)
+	return end >= 0 && epubAttrPattern.Match(content[offset:end])
+}
+
+// namespaceDeclarationEdit returns the edit inserting declaration into the
+// root element's start tag, after its last namespace declaration.
+func namespaceDeclarationEdit(content []byte, declaration string) (TextEdit, bool) {
+	offset := parser.NamespaceInsertOffset(content)
+	if offset < 0 {
+		return TextEdit{}, false
+	}
+	pos := lspPos(epub.ByteOffsetToPosition(content, offset))
+	return TextEdit{Range: Range{Start: pos, End: pos}, NewText: declaration}, true
+}
+
+// markPreferred marks the first enabled action as preferred, and no other.
+func markPreferred(actions []CodeAction) {
+	preferred := false
+	for i := range actions {
+		actions[i].IsPreferred = !preferred && actions[i].Disabled == nil
+		preferred = preferred || actions[i].IsPreferred
+	}
+}
+
+// preferredAction returns the action marked preferred, or nil if there is
+// none.
+func preferredAction(actions []CodeAction) *CodeAction {
+	for i := range actions {
+		if actions[i].IsPreferred && actions[i].Disabled == nil {
+			return &actions[i]
+		}
+	}
+	return nil
+}
+
+// codeActionForDiagnostic returns the quick fix for diag, or nil if it has
+// none. Fixes that format the document lay out prose as proseWrap says.
+func codeActionForDiagnostic(
+	uri string,
+	content []byte,
+	diag *Diagnostic,
+	proseWrap string,
+) *CodeAction {
+	if diag.Data != nil {
+		if action := fixDataAction(uri, content, diag); action != nil {
+			return action
+		}
+	}
+
+	// Without fix data, recompute the fix from the diagnostic code.
 	switch diag.Code {
 	case "metadata-accessmode":
 		return insertMetaAction(uri, content, diag,
@@ -143,35 +343,236 @@ func codeActionForDiagnostic(uri string, content []byte, diag *Diagnostic) *Code
 	case "HTM_008":
 		// Missing alt attribute on img
 		return addAttributeAction(uri, content, diag,
-			"Add alt attribute",
+			decorativeAltTitle,
 			"alt", `""`)
 	case "epub-type-has-matching-role":
 		// Missing role attribute
 		return addRoleAction(uri, content, diag)
+	case "HTM_045":
+		// Undeclared epub prefix
+		return declareNamespaceAction(uri, content, diag,
+			"Declare the epub namespace", xhtml.EpubNamespaceDeclaration)
+	case "RSC_016":
+		// Bare ampersand
+		return replaceRangeAction(uri, diag, "Escape as &amp;", "&amp;")
+	case "RSC_025":
+		// HTML entity not defined in XML
+		return replaceEntityAction(uri, content, diag)
+	case "RSC_031":
+		// Insecure http reference
+		return replaceRangeAction(uri, diag, "Use https", "https")
+	case "OPF_028":
+		// Undeclared metadata property prefix
+		return addPrefixAction(uri, content, diag)
+	case "OPF_087":
+		// EPUB 2 cover meta without the cover-image property
+		return coverImageAction(uri, content, diag)
+	case "WS_001":
+		// Mixed tab and space indentation
+		return reindentAction(uri, content, diag, proseWrap)
+	case "WS_002":
+		// Trailing whitespace
+		return replaceRangeAction(uri, diag, "Remove trailing whitespace", "")
+	case "WS_003":
+		// Missing final newline
+		return replaceRangeAction(uri, diag, "Add final newline",
+			epub.DetectLineEnding(content))
 	}
 	return nil
 }
 
+func replaceEntityAction(uri string, content []byte, diag *Diagnostic) *CodeAction {
+	lines := epub.NewLineIndex(content)
+	//nolint:gosec // LSP line/character numbers fit in int
+	start := lines.Offset(epub.Position{
+		Line:      int(diag.Range.Start.Line),
+		Character: int(diag.Range.Start.Character),
+	})
+	//nolint:gosec // LSP line/character numbers fit in int
+	end := lines.Offset(epub.Position{
+		Line:      int(diag.Range.End.Line),
+		Character: int(diag.Range.End.Character),
+	})
+	if start < 0 || end-start < 3 || content[start] != '&' || content[end-1] != ';' {
+		return nil
+	}
+
+	replacement, ok := xhtml.EntityReplacement(string(content[start+1 : end-1]))
+	if !ok {
+		return nil
+	}
+
+	return replaceRangeAction(uri, diag, "Replace with "+replacement, replacement)
+}
+
+// reindentAction formats the document with the indentation most of its
+// lines already use.
+func reindentAction(uri string, content []byte, diag *Diagnostic, proseWrap string) *CodeAction {
+	indent := whitespace.Indent(content)
+	edit, err := formatDocumentEdit(uri, content, indent, proseWrap, true)
+	if err != nil || edit == nil {
+		return nil
+	}
+
+	title := "Reindent with tabs"
+	if indent != "\t" {
+		title = "Reindent with " + strconv.Itoa(len(indent)) + " spaces"
+	}
+	return &CodeAction{
+		Title:       title,
+		Kind:        "quickfix",
+		Diagnostics: []Diagnostic{*diag},
+		Edit: &WorkspaceEdit{
+			Changes: map[string][]TextEdit{uri: {*edit}},
+		},
+	}
+}
+
+var packageStartTag = regexp.MustCompile(`<(?:\w+:)?package\b[^>]*>`)
+
+// addPrefixAction declares a known vendor prefix, appending to the package
+// prefix attribute or adding one when the package has none.
+func addPrefixAction(uri string, content []byte, diag *Diagnostic) *CodeAction {
+	_, quoted, _ := strings.Cut(diag.Message, `"`)
+	prefix, _, _ := strings.Cut(quoted, `"`)
+	vocab, ok := opf.KnownPrefixes[prefix]
+	if !ok {
+		return nil
+	}
+	declaration := prefix + ": " + vocab
+
+	tag := packageStartTag.FindIndex(content)
+	if tag == nil {
+		return nil
+	}
+
+	var insertOffset int
+	var text string
+	if start, end, ok := parser.AttrValueSpan(content, tag[0], "prefix"); ok {
+		insertOffset = end
+		text = declaration
+		if strings.TrimSpace(string(content[start:end])) != "" {
+			text = " " + declaration
+		}
+	} else {
+		insertOffset = tag[1] - 1
+		if content[insertOffset-1] == '/' {
+			insertOffset--
+		}
+		text = ` prefix="` + declaration + `"`
+	}
+
+	lp := lspPos(epub.ByteOffsetToPosition(content, insertOffset))
+
+	return &CodeAction{
+		Title:       "Declare prefix \"" + prefix + "\"",
+		Kind:        "quickfix",
+		Diagnostics: []Diagnostic{*diag},
+		Edit: &WorkspaceEdit{
+			Changes: map[string][]TextEdit{
+				uri: {
+					{
+						Range:   Range{Start: lp, End: lp},
+						NewText: text,
+					},
+				},
+			},
+		},
+	}
+}
+
+func replaceRangeAction(uri string, diag *Diagnostic, title, newText string) *CodeAction {
+	return &CodeAction{
+		Title:       title,
+		Kind:        "quickfix",
+		Diagnostics: []Diagnostic{*diag},
+		Edit: &WorkspaceEdit{
+			Changes: map[string][]TextEdit{
+				uri: {
+					{
+						Range:   diag.Range,
+						NewText: newText,
+					},
+				},
+			},
+		},
+	}
+}
+
+// fixDataAction builds the edit described by the diagnostic's fix data,
+// resolving its anchor against the current content.
+func fixDataAction(uri string, content []byte, diag *Diagnostic) *CodeAction {
+	fix := diag.Data
+	if tagName, ok := fix.ClosingTag(); ok {
+		return insertBeforeClosingTagAction(uri, content, diag,
+			fix.Title, tagName, fix.InsertText)
+	}
+
+	switch fix.Anchor {
+	case epub.AnchorEndOfStartTag:
+		return insertInStartTagAction(uri, content, diag, fix.Title, fix.InsertText)
+	case epub.AnchorReplaceRange:
+		return replaceRangeAction(uri, diag, fix.Title, fix.InsertText)
+	case epub.AnchorElementContent:
+		return replaceContentAction(uri, content, diag, fix.Title, fix.InsertText)
+	case epub.AnchorRenameElement:
+		return renameElementAction(uri, content, diag, fix.Title, fix.InsertText)
+	case epub.AnchorNamespaceDeclaration:
+		return declareNamespaceAction(uri, content, diag, fix.Title, fix.InsertText)
+	}
+	return nil
+}
+
+// declareNamespaceAction inserts a namespace declaration into the root
+// element's start tag.
+func declareNamespaceAction(
+	uri string,
+	content []byte,
+	diag *Diagnostic,
+	title, declaration string,
+) *CodeAction {
+	edit, ok := namespaceDeclarationEdit(content, declaration)
+	if !ok {
+		return nil
+	}
+	return &CodeAction{
+		Title:       title,
+		Kind:        "quickfix",
+		Diagnostics: []Diagnostic{*diag},
+		Edit:        &WorkspaceEdit{Changes: map[string][]TextEdit{uri: {edit}}},
+	}
+}
+
 func insertMetaAction(
 	uri string,
 	content []byte,
 	diag *Diagnostic,
 	title, metaElement string,
 ) *CodeAction {
-	// Find the closing </metadata> tag to insert before it
+	return insertBeforeClosingTagAction(uri, content, diag,
+		title, "metadata", metaElement)
+}
+
+// insertBeforeClosingTagAction inserts text on its own line before the
+// closing tag of the first tagName element.
+func insertBeforeClosingTagAction(
+	uri string,
+	content []byte,
+	diag *Diagnostic,
+	title, tagName, text string,
+) *CodeAction {
 	root, xmlDiags := parser.Parse(content)
 	if len(xmlDiags) > 0 {
 		return nil
 	}
 
-	metadata := root.FindFirst("metadata")
-	if metadata == nil {
+	element := root.FindFirst(tagName)
+	if element == nil {
 		return nil
 	}
 
-	// Insert before closing </metadata>
-	// Find the </metadata> position in raw content
-	insertOffset := findClosingTagOffset(content, int(metadata.Offset), "metadata")
+	// Find the closing tag position in raw content
+	insertOffset := findClosingTagOffset(content, int(element.Offset), tagName)
 	if insertOffset < 0 {
 		return nil
 	}
@@ -191,7 +592,56 @@ func insertMetaAction(
 				uri: {
 					{
 						Range:   Range{Start: lp, End: lp},
-						NewText: indent + metaElement + "\n",
+						NewText: indent + text + epub.DetectLineEnding(content),
+					},
+				},
+			},
+		},
+	}
+}
+
+// replaceContentAction replaces the content of the element at the
+// diagnostic position with text, giving a self-closing element a closing
+// tag.
+func replaceContentAction(
+	uri string,
+	content []byte,
+	diag *Diagnostic,
+	title, text string,
+) *CodeAction {
+	//nolint:gosec // LSP line/character numbers fit in int
+	offset := epub.PositionToByteOffset(content, epub.Position{
+		Line:      int(diag.Range.Start.Line),
+		Character: int(diag.Range.Start.Character),
+	})
+	if offset < 0 || offset >= len(content) || content[offset] != '<' {
+		return nil
+	}
+
+	start, end, _ := parser.ElementSpan(content, offset)
+	if start < len(content) && content[start] == '/' {
+		// Replace "/>" with ">text</name>"
+		name := content[offset+1 : start]
+		if i := bytes.IndexFunc(name, unicode.IsSpace); i >= 0 {
+			name = name[:i]
+		}
+		text = ">" + text + "</" + string(name) + ">"
+		end = start + 2
+	}
+
+	return &CodeAction{
+		Title:       title,
+		Kind:        "quickfix",
+		Diagnostics: []Diagnostic{*diag},
+		Edit: &WorkspaceEdit{
+			Changes: map[string][]TextEdit{
+				uri: {
+					{
+						Range: Range{
+							Start: lspPos(epub.ByteOffsetToPosition(content, start)),
+							End:   lspPos(epub.ByteOffsetToPosition(content, end)),
+						},
+						NewText: text,
 					},
 				},
 			},
@@ -199,11 +649,127 @@ func insertMetaAction(
 	}
 }
 
+// renameElementAction replaces the name of the element starting at the
+// diagnostic in its start tag and, unless it is self-closing, its end tag.
+func renameElementAction(
+	uri string,
+	content []byte,
+	diag *Diagnostic,
+	title, name string,
+) *CodeAction {
+	//nolint:gosec // LSP line/character numbers fit in int
+	offset := epub.PositionToByteOffset(content, epub.Position{
+		Line:      int(diag.Range.Start.Line),
+		Character: int(diag.Range.Start.Character),
+	})
+	if offset < 0 || offset >= len(content) || content[offset] != '<' {
+		return nil
+	}
+
+	start, end := parser.TagNameSpans(content, offset)
+	spanEdit := func(span [2]int) TextEdit {
+		return TextEdit{
+			Range: Range{
+				Start: lspPos(epub.ByteOffsetToPosition(content, span[0])),
+				End:   lspPos(epub.ByteOffsetToPosition(content, span[1])),
+			},
+			NewText: name,
+		}
+	}
+	edits := []TextEdit{spanEdit(start)}
+	if end[0] >= 0 {
+		edits = append(edits, spanEdit(end))
+	}
+
+	return &CodeAction{
+		Title:       title,
+		Kind:        "quickfix",
+		Diagnostics: []Diagnostic{*diag},
+		Edit: &WorkspaceEdit{
+			Changes: map[string][]TextEdit{uri: edits},
+		},
+	}
+}
+
+// decorativeAltTitle is the title of the fix giving an image empty alt.
+const decorativeAltTitle = `Mark as decorative with alt=""`
+
+// genericImageWords are file name words that say nothing about an image.
+var genericImageWords = map[string]bool{
+	"img": true, "image": true, "pic": true, "picture": true, "photo": true,
+	"fig": true, "figure": true, "illus": true, "illustration": true,
+}
+
+// derivedAltAction adds alt text to the img start tag at the diagnostic
+// position, taken from its title attribute or else from the words of its
+// file name. It returns nil when neither gives any.
+func derivedAltAction(uri string, content []byte, diag *Diagnostic) *CodeAction {
+	offset := epub.PositionToByteOffset(content, posToEpub(diag.Range.Start))
+	tagStart := bytes.LastIndexByte(content[:max(offset, 0)], '<')
+	if offset < 0 || tagStart < 0 {
+		return nil
+	}
+
+	alt := ""
+	if start, end, ok := parser.AttrValueSpan(content, tagStart, "title"); ok {
+		alt = strings.Join(strings.Fields(string(content[start:end])), " ")
+	}
+	if start, end, ok := parser.AttrValueSpan(content, tagStart, "src"); ok && alt == "" {
+		alt = altFromFileName(string(content[start:end]))
+	}
+	if alt == "" {
+		return nil
+	}
+	alt = strings.ReplaceAll(alt, `"`, "&quot;")
+	return addAttributeAction(uri, content, diag, `Add alt="`+alt+`"`, "alt", `"`+alt+`"`)
+}
+
+// altFromFileName returns the words of the file name in src, such as
+// "Harbor at dawn" for "images/harbor_at-dawn.jpg", or "" when it has no
+// word of three or more letters other than generic ones such as "image".
+func altFromFileName(src string) string {
+	name := path.Base(epub.DecodeHref(epub.StripFragment(src)))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.' || unicode.IsSpace(r)
+	})
+
+	meaningful := false
+	for _, w := range words {
+		letters := 0
+		for _, r := range w {
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		}
+		if letters >= 3 && !genericImageWords[strings.ToLower(w)] {
+			meaningful = true
+		}
+	}
+	if !meaningful {
+		return ""
+	}
+	alt := strings.Join(words, " ")
+	first, size := utf8.DecodeRuneInString(alt)
+	return string(unicode.ToUpper(first)) + alt[size:]
+}
+
 func addAttributeAction(
 	uri string,
 	content []byte,
 	diag *Diagnostic,
 	title, attrName, attrValue string,
+) *CodeAction {
+	return insertInStartTagAction(uri, content, diag, title, " "+attrName+"="+attrValue)
+}
+
+// insertInStartTagAction inserts text before the end of the start tag at
+// the diagnostic position.
+func insertInStartTagAction(
+	uri string,
+	content []byte,
+	diag *Diagnostic,
+	title, text string,
 ) *CodeAction {
 	// Find the element at the diagnostic position
 	//nolint:gosec // LSP line/character numbers fit in int
@@ -216,35 +782,110 @@ func addAttributeAction(
 		return nil
 	}
 
-	// Find the > of the start tag
-	insertOffset := -1
+	insertOffset := startTagCloseOffset(content, offset)
+	if insertOffset < 0 {
+		return nil
+	}
+
+	insertPos := epub.ByteOffsetToPosition(content, insertOffset)
+	lp := lspPos(insertPos)
+
+	return &CodeAction{
+		Title:       title,
+		Kind:        "quickfix",
+		Diagnostics: []Diagnostic{*diag},
+		Edit: &WorkspaceEdit{
+			Changes: map[string][]TextEdit{
+				uri: {
+					{
+						Range:   Range{Start: lp, End: lp},
+						NewText: text,
+					},
+				},
+			},
+		},
+	}
+}
+
+// startTagCloseOffset returns the offset of the ">" or "/>" ending the start
+// tag at or after offset, or -1 if there is none.
+func startTagCloseOffset(content []byte, offset int) int {
 	for i := offset; i < len(content); i++ {
 		if content[i] == '>' {
-			insertOffset = i
-			break
+			return i
 		}
 		if content[i] == '/' && i+1 < len(content) && content[i+1] == '>' {
-			insertOffset = i
-			break
+			return i
 		}
 	}
-	if insertOffset < 0 {
+	return -1
+}
+
+// coverImageAction replaces an EPUB 2 cover meta with the cover-image
+// property on the manifest item it names: the meta is removed, with its
+// line when it is alone on one, and the property added to the item.
+func coverImageAction(uri string, content []byte, diag *Diagnostic) *CodeAction {
+	root, parseDiags := parser.Parse(content)
+	if len(parseDiags) > 0 {
+		return nil
+	}
+	//nolint:gosec // LSP line/character numbers fit in int
+	offset := epub.PositionToByteOffset(content, epub.Position{
+		Line:      int(diag.Range.Start.Line),
+		Character: int(diag.Range.Start.Character),
+	})
+	result := parser.LocateAtPosition(root, content, offset)
+	if result == nil || result.Node.Local != "meta" || result.Node.Attr("name") != "cover" {
+		return nil
+	}
+	meta := result.Node
+	id := meta.Attr("content")
+	item := findNodeByID(root, id)
+	if item == nil || item.Local != "item" {
 		return nil
 	}
 
-	insertPos := epub.ByteOffsetToPosition(content, insertOffset)
-	lp := lspPos(insertPos)
+	// Remove the meta, taking its line when nothing else is on it
+	start := int(meta.Offset)
+	_, _, end := parser.ElementSpan(content, start)
+	lineStart := bytes.LastIndexByte(content[:start], '\n') + 1
+	lineEnd := bytes.IndexByte(content[end:], '\n')
+	if lineEnd >= 0 && len(bytes.TrimSpace(content[lineStart:start])) == 0 &&
+		len(bytes.TrimSpace(content[end:end+lineEnd])) == 0 {
+		start, end = lineStart, end+lineEnd+1
+	}
+
+	// Add cover-image to the item's properties, or give it some
+	var insertOffset int
+	text := ` properties="cover-image"`
+	if valueStart, valueEnd, ok := parser.AttrValueRange(content, item, "properties"); ok {
+		insertOffset = valueEnd
+		text = "cover-image"
+		if len(bytes.TrimSpace(content[valueStart:valueEnd])) > 0 {
+			text = " cover-image"
+		}
+	} else if insertOffset = startTagCloseOffset(content, int(item.Offset)); insertOffset < 0 {
+		return nil
+	}
+	insert := lspPos(epub.ByteOffsetToPosition(content, insertOffset))
 
 	return &CodeAction{
-		Title:       title,
+		Title:       "Use properties=\"cover-image\" on item \"" + id + "\"",
 		Kind:        "quickfix",
 		Diagnostics: []Diagnostic{*diag},
 		Edit: &WorkspaceEdit{
 			Changes: map[string][]TextEdit{
 				uri: {
 					{
-						Range:   Range{Start: lp, End: lp},
-						NewText: " " + attrName + "=" + attrValue,
+						Range: Range{
+							Start: lspPos(epub.ByteOffsetToPosition(content, start)),
+							End:   lspPos(epub.ByteOffsetToPosition(content, end)),
+						},
+						NewText: "",
+					},
+					{
+						Range:   Range{Start: insert, End: insert},
+						NewText: text,
 					},
 				},
 			},
@@ -253,10 +894,13 @@ func addAttributeAction(
 }
 
 func addRoleAction(uri string, content []byte, diag *Diagnostic) *CodeAction {
-	// Try to determine the appropriate role from the diagnostic message
+	// Try to determine the appropriate role from the diagnostic message,
+	// which names it as role="x"
 	role := "doc-chapter" // default
 	msg := strings.ToLower(diag.Message)
-	if strings.Contains(msg, "noteref") {
+	if _, named, ok := strings.Cut(diag.Message, `role="`); ok {
+		role, _, _ = strings.Cut(named, `"`)
+	} else if strings.Contains(msg, "noteref") {
 		role = "doc-noteref"
 	} else if strings.Contains(msg, "footnote") {
 		role = "doc-footnote"
@@ -271,6 +915,132 @@ func addRoleAction(uri string, content []byte, diag *Diagnostic) *CodeAction {
 		"role", `"`+role+`"`)
 }
 
+// addManifestItemAction adds the workspace file an RSC_008 diagnostic
+// references to the package document's manifest, with an id from its file
+// name and a media type from its extension. When the file or the package
+// document is not in the workspace, the action is disabled with the
+// reason.
+func addManifestItemAction(
+	uri string,
+	content []byte,
+	diag *Diagnostic,
+	ws WorkspaceReader,
+) *CodeAction {
+	title := "Add to manifest"
+	disabled := func(reason string) *CodeAction {
+		return &CodeAction{
+			Title:       title,
+			Kind:        "quickfix",
+			Diagnostics: []Diagnostic{*diag},
+			Disabled:    &CodeActionDisabled{Reason: reason},
+		}
+	}
+
+	start := epub.PositionToByteOffset(content, posToEpub(diag.Range.Start))
+	end := epub.PositionToByteOffset(content, posToEpub(diag.Range.End))
+	if start < 0 || start >= end {
+		return disabled("the reference is no longer at the diagnostic's position")
+	}
+	ref := string(content[start:end])
+	title = "Add " + path.Base(epub.DecodeHref(epub.StripFragment(ref))) + " to manifest"
+
+	manifest := ws.GetManifest()
+	if manifest == nil {
+		return disabled("no package document is open")
+	}
+	opfURI := manifest.URI
+	if opfURI == "" {
+		opfURI = packageDocumentURI(ws)
+	}
+	opfContent := ws.GetContent(opfURI)
+	if opfContent == nil {
+		return disabled("no package document is open")
+	}
+
+	target, ok := uriutil.Lookup(ws.GetAllFiles(), uriutil.ResolveRelative(uri, ref))
+	if !ok {
+		return disabled(ref + " is not in the workspace")
+	}
+	mediaType := validator.MediaTypeForExtension(path.Ext(uriutil.Path(target)))
+	if mediaType == "" {
+		return disabled("the media type of " + ref + " is not known")
+	}
+	href, err := filepath.Rel(filepath.FromSlash(uriutil.Dir(opfURI)),
+		filepath.FromSlash(uriutil.Path(target)))
+	if err != nil {
+		return disabled(ref + " is not below the package document")
+	}
+	href = epub.EncodeURLPath(filepath.ToSlash(href))
+
+	root, xmlDiags := parser.Parse(opfContent)
+	if len(xmlDiags) > 0 {
+		return disabled("the package document is not well-formed")
+	}
+	element := root.FindFirst("manifest")
+	if element == nil {
+		return disabled("the package document has no manifest")
+	}
+	closeOffset := findClosingTagOffset(opfContent, int(element.Offset), "manifest")
+	if closeOffset < 0 {
+		return disabled("the package document's manifest has no end tag")
+	}
+
+	// Indent the item like the last one, or a level inside the manifest
+	indent := detectIndent(opfContent, closeOffset)
+	itemIndent := indent + "  "
+	if n := len(element.Children); n > 0 {
+		itemIndent = detectIndent(opfContent, int(element.Children[n-1].Offset))
+	}
+	eol := epub.DetectLineEnding(opfContent)
+	item := `<item id="` + manifestItemID(target, manifest) + `" href="` + href +
+		`" media-type="` + mediaType + `"/>`
+
+	insertOffset, text := closeOffset, eol+itemIndent+item+eol
+	if lineStart := closeOffset - len(indent); lineStart == 0 ||
+		opfContent[lineStart-1] == '\n' || opfContent[lineStart-1] == '\r' {
+		insertOffset, text = lineStart, itemIndent+item+eol
+	}
+	pos := lspPos(epub.ByteOffsetToPosition(opfContent, insertOffset))
+
+	return &CodeAction{
+		Title:       "Add " + path.Base(uriutil.Path(target)) + " to manifest",
+		Kind:        "quickfix",
+		Diagnostics: []Diagnostic{*diag},
+		Edit: &WorkspaceEdit{
+			Changes: map[string][]TextEdit{
+				opfURI: {{Range: Range{Start: pos, End: pos}, NewText: text}},
+			},
+		},
+	}
+}
+
+// manifestItemID derives a manifest item id from the file name of uri,
+// without its extension, made a valid XML name and numbered when another
+// item has it already.
+func manifestItemID(uri string, manifest *validator.ManifestInfo) string {
+	name := path.Base(uriutil.Path(uri))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	id := []rune(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '-'
+	}, name))
+	if len(id) == 0 || !unicode.IsLetter(id[0]) && id[0] != '_' {
+		id = append([]rune("item-"), id...)
+	}
+
+	taken := make(map[string]bool, len(manifest.Items))
+	for _, item := range manifest.Items {
+		taken[item.ID] = true
+	}
+	candidate := string(id)
+	for n := 2; taken[candidate]; n++ {
+		candidate = string(id) + "-" + strconv.Itoa(n)
+	}
+	return candidate
+}
+
 // findClosingTagOffset finds the byte offset of </tagName> in content
 // starting from the element's start offset.
 func findClosingTagOffset(content []byte, startOffset int, tagName string) int {
@@ -287,7 +1057,7 @@ func findClosingTagOffset(content []byte, startOffset int, tagName string) int {
 func detectIndent(content []byte, offset int) string {
 	// Walk backward to find the start of the line
 	lineStart := offset
-	for lineStart > 0 && content[lineStart-1] != '\n' {
+	for lineStart > 0 && content[lineStart-1] != '\n' && content[lineStart-1] != '\r' {
 		lineStart--
 	}
 
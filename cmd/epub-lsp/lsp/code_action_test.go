@@ -1,9 +1,18 @@
 package lsp
 
 import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/toba/epub-lsp/epublint"
 	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+	"github.com/toba/epub-lsp/internal/epub/validator/accessibility"
+	"github.com/toba/epub-lsp/internal/epub/validator/opf"
+	"github.com/toba/epub-lsp/internal/epub/validator/xhtml"
 )
 
 func TestHandleCodeAction_MissingAccessMode(t *testing.T) {
@@ -31,7 +40,7 @@ func TestHandleCodeAction_MissingAccessMode(t *testing.T) {
 		},
 	})
 
-	resp := HandleCodeAction(data, ws)
+	resp := HandleCodeAction(t.Context(), data, ws)
 	actions := unmarshalResult[[]CodeAction](t, resp)
 
 	if len(actions) != 1 {
@@ -47,6 +56,39 @@ func TestHandleCodeAction_MissingAccessMode(t *testing.T) {
 	}
 }
 
+func TestHandleCodeAction_ClampsStaleRange(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/style.css"
+	ws.files[uri] = []byte("p { margin: 0; }  \n")
+	ws.fileTypes[uri] = epub.FileTypeCSS
+
+	// A trailing whitespace diagnostic from a longer, older buffer
+	stale := Range{
+		Start: Position{Line: 3, Character: 16},
+		End:   Position{Line: 3, Character: 40},
+	}
+	data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Range:        stale,
+		Context: CodeActionContext{
+			Diagnostics: []Diagnostic{{Code: "WS_002", Range: stale}},
+		},
+	})
+
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	if len(actions) != 1 || actions[0].Edit == nil {
+		t.Fatalf("expected 1 code action with an edit, got %+v", actions)
+	}
+	edit := actions[0].Edit.Changes[uri][0]
+	want := Range{Start: Position{Line: 1}, End: Position{Line: 1}}
+	if edit.Range != want {
+		t.Errorf("edit range = %+v, want %+v", edit.Range, want)
+	}
+	if actions[0].Diagnostics[0].Range != stale {
+		t.Error("the diagnostic echoed back should keep the client's range")
+	}
+}
+
 func TestHandleCodeAction_NoDiagnostics(t *testing.T) {
 	ws := newMockWorkspace()
 	opfContent := []byte(`<?xml version="1.0"?><package><metadata></metadata></package>`)
@@ -61,7 +103,7 @@ func TestHandleCodeAction_NoDiagnostics(t *testing.T) {
 		},
 	})
 
-	resp := HandleCodeAction(data, ws)
+	resp := HandleCodeAction(t.Context(), data, ws)
 	actions := unmarshalResult[[]CodeAction](t, resp)
 
 	if len(actions) != 0 {
@@ -85,7 +127,7 @@ func TestHandleCodeAction_UnknownDiagCode(t *testing.T) {
 		},
 	})
 
-	resp := HandleCodeAction(data, ws)
+	resp := HandleCodeAction(t.Context(), data, ws)
 	actions := unmarshalResult[[]CodeAction](t, resp)
 
 	if len(actions) != 0 {
@@ -125,7 +167,7 @@ func TestHandleCodeAction_FixAll(t *testing.T) {
 		},
 	})
 
-	resp := HandleCodeAction(data, ws)
+	resp := HandleCodeAction(t.Context(), data, ws)
 	actions := unmarshalResult[[]CodeAction](t, resp)
 
 	if len(actions) != 1 {
@@ -165,7 +207,7 @@ func TestHandleCodeAction_FixAllNoDiagnostics(t *testing.T) {
 		},
 	})
 
-	resp := HandleCodeAction(data, ws)
+	resp := HandleCodeAction(t.Context(), data, ws)
 	actions := unmarshalResult[[]CodeAction](t, resp)
 
 	if actions != nil {
@@ -175,3 +217,995 @@ func TestHandleCodeAction_FixAllNoDiagnostics(t *testing.T) {
 		)
 	}
 }
+
+func TestHandleCodeAction_FixAllStaleDiagnostics(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/ch1.xhtml"
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml"
+  xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>One</title></head>
+<body><p class="intro">Intro</p>
+<section epub:type="chapter"><img src="a.png"/></section>
+</body></html>
+`)
+	ws.files[uri] = content
+	ws.fileTypes[uri] = epub.FileTypeXHTML
+
+	// Stored by a pass over an older version where the section and img
+	// came before the intro; fixing at these positions would corrupt it
+	ws.diagnostics[uri] = []epub.Diagnostic{
+		{
+			Code:    "epub-type-has-matching-role",
+			Message: `epub:type="chapter" should have matching role="doc-chapter"`,
+			Range:   epub.Range{Start: epub.Position{Line: 3, Character: 6}},
+		},
+		{
+			Code:  "HTM_008",
+			Range: epub.Range{Start: epub.Position{Line: 3, Character: 9}},
+		},
+	}
+	ws.fresh = map[string][]epub.Diagnostic{
+		uri: epublint.NewWorkspace(ws.files, epublint.Options{}).
+			ValidateFiles(uri)[0].Diagnostics,
+	}
+
+	data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Context:      CodeActionContext{Only: []string{"source.fixAll"}},
+	})
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	if len(actions) != 1 || actions[0].Edit == nil {
+		t.Fatalf("expected 1 source.fixAll action, got %d", len(actions))
+	}
+
+	got := applyEdits(content, actions[0].Edit.Changes[uri])
+	want := strings.NewReplacer(
+		`epub:type="chapter">`, `epub:type="chapter" role="doc-chapter">`,
+		`<img src="a.png"/>`, `<img src="a.png" alt=""/>`,
+	).Replace(string(content))
+	if got != want {
+		t.Errorf("fixed content:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHandleCodeAction_FixAllInsertionOrder(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/content.opf"
+	ws.files[uri] = []byte(`<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata>
+  </metadata>
+</package>`)
+	ws.fileTypes[uri] = epub.FileTypeOPF
+
+	// Validators may report in any order; the edits must not depend on it
+	codes := []string{
+		"metadata-accessmode",
+		"metadata-accessibilitysummary",
+		"metadata-accessibilityhazard",
+		"metadata-accessibilityfeature",
+	}
+	var want []string
+	for _, order := range [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {2, 0, 3, 1}} {
+		var diags []epub.Diagnostic
+		for _, i := range order {
+			diags = append(diags, epub.Diagnostic{Code: codes[i]})
+		}
+		ws.diagnostics[uri] = diags
+
+		data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+			TextDocument: TextDocumentIdentifier{Uri: uri},
+			Context:      CodeActionContext{Only: []string{"source.fixAll"}},
+		})
+		actions := unmarshalResult[[]CodeAction](t,
+			HandleCodeAction(t.Context(), data, ws))
+		if len(actions) != 1 || actions[0].Edit == nil {
+			t.Fatalf("expected 1 source.fixAll action, got %d", len(actions))
+		}
+
+		var got []string
+		for _, d := range actions[0].Diagnostics {
+			got = append(got, d.Code)
+		}
+		if want == nil {
+			want = got
+			if !slices.IsSorted(want) || len(want) != len(codes) {
+				t.Fatalf("expected fixes ordered by code, got %v", want)
+			}
+		} else if !slices.Equal(got, want) {
+			t.Errorf("order %v: fixes %v, want %v", order, got, want)
+		}
+	}
+}
+
+func TestHandleCodeAction_InsertMetaUsesCRLF(t *testing.T) {
+	ws := newMockWorkspace()
+	opfContent := []byte("<?xml version=\"1.0\"?>\r\n" +
+		"<package xmlns=\"http://www.idpf.org/2007/opf\">\r\n" +
+		"  <metadata xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\r\n" +
+		"    <dc:title>Test</dc:title>\r\n" +
+		"  </metadata>\r\n" +
+		"</package>\r\n")
+	ws.files["file:///book/content.opf"] = opfContent
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+		Context: CodeActionContext{
+			Diagnostics: []Diagnostic{
+				{
+					Code:    "metadata-accessmode",
+					Message: "missing schema:accessMode metadata",
+				},
+			},
+		},
+	})
+
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	if len(actions) != 1 || actions[0].Edit == nil {
+		t.Fatalf("expected 1 code action with an edit, got %d", len(actions))
+	}
+
+	edit := actions[0].Edit.Changes["file:///book/content.opf"][0]
+	if !strings.HasSuffix(edit.NewText, "\r\n") {
+		t.Errorf("expected CRLF line ending, got %q", edit.NewText)
+	}
+	if edit.Range.Start.Line != 4 || edit.Range.Start.Character != 2 {
+		t.Errorf("expected insert before </metadata> at 4:2, got %v", edit.Range.Start)
+	}
+}
+
+func TestHandleCodeAction_ReplaceEntity(t *testing.T) {
+	ws := newMockWorkspace()
+	content := []byte("<p>one&nbsp;two&mdash;three & four</p>")
+	ws.files["file:///book/ch1.xhtml"] = content
+	ws.fileTypes["file:///book/ch1.xhtml"] = epub.FileTypeXHTML
+
+	span := func(start, end int) Range {
+		return Range{
+			Start: lspPos(epub.Position{Character: start}),
+			End:   lspPos(epub.Position{Character: end}),
+		}
+	}
+
+	tests := []struct {
+		code  string
+		rng   Range
+		want  string
+		title string
+	}{
+		{"RSC_025", span(6, 12), "&#160;", "Replace with &#160;"},
+		{"RSC_025", span(15, 22), "—", "Replace with —"},
+		{"RSC_016", span(28, 29), "&amp;", "Escape as &amp;"},
+	}
+
+	for _, tt := range tests {
+		data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+			TextDocument: TextDocumentIdentifier{Uri: "file:///book/ch1.xhtml"},
+			Context: CodeActionContext{
+				Diagnostics: []Diagnostic{{Code: tt.code, Range: tt.rng}},
+			},
+		})
+
+		actions := unmarshalResult[[]CodeAction](t,
+			HandleCodeAction(t.Context(), data, ws))
+		if len(actions) != 1 || actions[0].Edit == nil {
+			t.Fatalf("%s: expected 1 code action with an edit, got %d",
+				tt.code, len(actions))
+		}
+		if actions[0].Title != tt.title {
+			t.Errorf("expected title %q, got %q", tt.title, actions[0].Title)
+		}
+
+		edit := actions[0].Edit.Changes["file:///book/ch1.xhtml"][0]
+		if edit.NewText != tt.want || edit.Range != tt.rng {
+			t.Errorf("expected %q over %v, got %q over %v",
+				tt.want, tt.rng, edit.NewText, edit.Range)
+		}
+	}
+}
+
+func TestHandleCodeAction_DeclarePrefix(t *testing.T) {
+	vocab := opf.KnownPrefixes["ibooks"]
+
+	tests := []struct {
+		name, pkg, want string
+		// before is how many bytes before the end of pkg the edit lands
+		before int
+	}{
+		{
+			"appends to existing attribute",
+			`<package version="3.0" prefix="se: https://standardebooks.org/vocab/1.0">`,
+			" ibooks: " + vocab,
+			2,
+		},
+		{
+			"creates attribute",
+			`<package version="3.0">`,
+			` prefix="ibooks: ` + vocab + `"`,
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newMockWorkspace()
+			content := []byte(tt.pkg + `
+  <metadata><meta property="ibooks:version">1.0</meta></metadata>
+</package>`)
+			ws.files["file:///book/content.opf"] = content
+			ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+			metaPos := lspPos(epub.ByteOffsetToPosition(content,
+				findSubstring(content, "<meta")))
+			msg := `undeclared prefix "ibooks" in property "ibooks:version"`
+			data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+				TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+				Context: CodeActionContext{
+					Diagnostics: []Diagnostic{{
+						Code:    "OPF_028",
+						Range:   Range{Start: metaPos, End: metaPos},
+						Message: msg,
+					}},
+				},
+			})
+
+			actions := unmarshalResult[[]CodeAction](t,
+				HandleCodeAction(t.Context(), data, ws))
+			if len(actions) != 1 || actions[0].Edit == nil {
+				t.Fatalf("expected 1 code action with an edit, got %d", len(actions))
+			}
+			if actions[0].Title != `Declare prefix "ibooks"` {
+				t.Errorf("unexpected title %q", actions[0].Title)
+			}
+
+			edit := actions[0].Edit.Changes["file:///book/content.opf"][0]
+			wantPos := lspPos(epub.ByteOffsetToPosition(content, len(tt.pkg)-tt.before))
+			if edit.NewText != tt.want || edit.Range.Start != wantPos {
+				t.Errorf("expected %q at %v, got %q at %v",
+					tt.want, wantPos, edit.NewText, edit.Range.Start)
+			}
+		})
+	}
+}
+
+func TestHandleCodeAction_FillInValue(t *testing.T) {
+	tests := []struct {
+		name, metadata, want string
+	}{
+		{
+			"whitespace title",
+			`<dc:title>  </dc:title>`,
+			`<dc:title>Untitled</dc:title>`,
+		},
+		{
+			"self-closing summary",
+			`<meta property="schema:accessibilitySummary"/>`,
+			`<meta property="schema:accessibilitySummary">` +
+				`This publication meets WCAG 2.0 Level AA.</meta>`,
+		},
+	}
+
+	const uri = "file:///book/content.opf"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte(`<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    ` + tt.metadata + `
+  </metadata>
+</package>`)
+			ws := newMockWorkspace()
+			ws.files[uri] = content
+
+			diags := append((&opf.Validator{}).Validate("", content, nil),
+				(&accessibility.MetadataValidator{}).Validate("", content, nil)...)
+			i := slices.IndexFunc(diags, func(d epub.Diagnostic) bool {
+				return d.Fix != nil && d.Fix.Title == "Fill in value"
+			})
+			if i < 0 {
+				t.Fatalf("expected a diagnostic to fill in, got %v", diags)
+			}
+
+			data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+				TextDocument: TextDocumentIdentifier{Uri: uri},
+				Context: CodeActionContext{
+					Diagnostics: []Diagnostic{toLSPDiagnostic(diags[i])},
+				},
+			})
+			actions := unmarshalResult[[]CodeAction](t,
+				HandleCodeAction(t.Context(), data, ws))
+			if len(actions) != 1 || actions[0].Edit == nil {
+				t.Fatalf("expected 1 action with an edit, got %+v", actions)
+			}
+
+			got := applyEdits(content, actions[0].Edit.Changes[uri])
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("expected %s in:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandleCodeAction_RenameElement(t *testing.T) {
+	const uri = "file:///book/ch1.xhtml"
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml">
+<body>
+  <p><b class="x">Stop <b>now</b></b>!</p>
+</body>
+</html>`)
+	ws := newMockWorkspace()
+	ws.files[uri] = content
+
+	diags := (&accessibility.StructureValidator{}).Validate("", content, nil)
+	i := slices.IndexFunc(diags, func(d epub.Diagnostic) bool {
+		return d.Code == "presentational-bold"
+	})
+	if i < 0 {
+		t.Fatalf("expected presentational-bold, got %v", diags)
+	}
+
+	data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Context: CodeActionContext{
+			Diagnostics: []Diagnostic{toLSPDiagnostic(diags[i])},
+		},
+	})
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	if len(actions) != 1 || actions[0].Edit == nil {
+		t.Fatalf("expected 1 action with an edit, got %+v", actions)
+	}
+
+	// Only the outer element's names change, in both of its tags
+	edits := actions[0].Edit.Changes[uri]
+	want := []Range{
+		{Start: Position{Line: 2, Character: 6}, End: Position{Line: 2, Character: 7}},
+		{Start: Position{Line: 2, Character: 35}, End: Position{Line: 2, Character: 36}},
+	}
+	if len(edits) != len(want) {
+		t.Fatalf("expected %d edits, got %+v", len(want), edits)
+	}
+	for j, e := range edits {
+		if e.Range != want[j] || e.NewText != "strong" {
+			t.Errorf("edit %d: expected %+v -> strong, got %+v", j, want[j], e)
+		}
+	}
+
+	got := applyEdits(content, edits)
+	if !strings.Contains(got, `<p><strong class="x">Stop <b>now</b></strong>!</p>`) {
+		t.Errorf("unexpected result:\n%s", got)
+	}
+}
+
+func TestFixDataRoundTrip(t *testing.T) {
+	fix := &epub.FixData{
+		Title:      "Add alt attribute",
+		Anchor:     epub.AnchorEndOfStartTag,
+		InsertText: ` alt=""`,
+	}
+	data := PublishDiagnosticsNotification("file:///book/ch1.xhtml", nil,
+		[]epub.Diagnostic{
+			{Code: "HTM_008", Message: "missing alt", Fix: fix},
+			{Code: "OPF_001", Message: "no fix"},
+		})
+
+	var msg NotificationMessage[PublishDiagnosticsParams]
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	diags := msg.Params.Diagnostics
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d", len(diags))
+	}
+	if diags[0].Data == nil || *diags[0].Data != *fix {
+		t.Errorf("expected data %+v, got %+v", fix, diags[0].Data)
+	}
+	if diags[1].Data != nil {
+		t.Errorf("expected no data, got %+v", diags[1].Data)
+	}
+	if strings.Contains(string(data), `"data":null`) {
+		t.Error("expected data to be omitted when there is no fix")
+	}
+}
+
+func TestCodeActionSameWithAndWithoutFixData(t *testing.T) {
+	opfContent := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Test</dc:title>
+  </metadata>
+</package>`)
+	xhtmlContent := []byte(`<?xml version="1.0"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>Test</title></head>
+<body>
+  <section epub:type="toc"><img src="a.png"/></section>
+</body>
+</html>`)
+	// Undefined entities make the document malformed, so they get their own.
+	entityContent := []byte(
+		`<html><body><p>one&nbsp;two &mdash; Smith & Sons</p></body></html>`)
+
+	files := []struct {
+		uri      string
+		content  []byte
+		fileType epub.FileType
+		diags    []epub.Diagnostic
+	}{
+		{"file:///book/content.opf", opfContent, epub.FileTypeOPF,
+			(&accessibility.MetadataValidator{}).Validate("", opfContent, nil)},
+		{"file:///book/ch1.xhtml", xhtmlContent, epub.FileTypeXHTML, append(
+			(&xhtml.Validator{}).Validate("", xhtmlContent, nil),
+			(&accessibility.StructureValidator{}).Validate("", xhtmlContent, nil)...)},
+		{"file:///book/ch2.xhtml", entityContent, epub.FileTypeXHTML,
+			(&xhtml.Validator{}).Validate("", entityContent, nil)},
+	}
+
+	ws := newMockWorkspace()
+	fixed := 0
+	for _, f := range files {
+		ws.files[f.uri] = f.content
+		ws.fileTypes[f.uri] = f.fileType
+
+		for _, d := range f.diags {
+			if d.Fix == nil {
+				continue
+			}
+			fixed++
+
+			withData := toLSPDiagnostic(d)
+			withoutData := withData
+			withoutData.Data = nil
+
+			var got [2][]CodeAction
+			for i, diag := range []Diagnostic{withData, withoutData} {
+				data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+					TextDocument: TextDocumentIdentifier{Uri: f.uri},
+					Context:      CodeActionContext{Diagnostics: []Diagnostic{diag}},
+				})
+				got[i] = unmarshalResult[[]CodeAction](t,
+					HandleCodeAction(t.Context(), data, ws))
+				if len(got[i]) != 1 {
+					t.Fatalf("%s: expected 1 action, got %d", d.Code, len(got[i]))
+				}
+				got[i][0].Diagnostics = nil
+			}
+
+			if !reflect.DeepEqual(got[0], got[1]) {
+				t.Errorf("%s: action differs with fix data:\n%+v\nwithout:\n%+v",
+					d.Code, got[0], got[1])
+			}
+		}
+	}
+
+	// Five metadata properties, alt, role, two entities, and one ampersand
+	if fixed != 10 {
+		t.Errorf("expected 10 diagnostics with fix data, got %d", fixed)
+	}
+}
+
+// whitespaceDiags validates content with only the whitespace validator.
+func whitespaceDiags(uri string, content []byte) []epub.Diagnostic {
+	return epublint.NewWorkspace(map[string][]byte{uri: content}, epublint.Options{
+		Validators: []string{epublint.ValidatorWhitespace},
+	}).ValidateFiles(uri)[0].Diagnostics
+}
+
+func TestHandleCodeAction_FixAllWhitespace(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/ch1.xhtml"
+	content := []byte("<html xmlns=\"http://www.w3.org/1999/xhtml\">\n" +
+		"  <head>\n" +
+		"    <title>One</title>  \n" +
+		"  </head>\n" +
+		"\t<body>\n" +
+		"    <p>Text</p>\t\n" +
+		"  </body>\n" +
+		"</html>")
+	ws.files[uri] = content
+	ws.fileTypes[uri] = epub.FileTypeXHTML
+	ws.fresh = map[string][]epub.Diagnostic{uri: whitespaceDiags(uri, content)}
+
+	data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Context:      CodeActionContext{Only: []string{"source.fixAll"}},
+	})
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	if len(actions) != 1 || actions[0].Edit == nil {
+		t.Fatalf("expected 1 source.fixAll action, got %d", len(actions))
+	}
+	if len(actions[0].Diagnostics) != 4 {
+		t.Errorf("expected all 4 whitespace diagnostics fixed, got %d",
+			len(actions[0].Diagnostics))
+	}
+
+	fixed := []byte(applyEdits(content, actions[0].Edit.Changes[uri]))
+	if diags := whitespaceDiags(uri, fixed); len(diags) != 0 {
+		t.Errorf("expected a clean file, got %v in:\n%s", diags, fixed)
+	}
+}
+
+func TestHandleCodeAction_WhitespaceQuickFixes(t *testing.T) {
+	content := []byte("a {\r\n  color: red; \r\n}")
+	uri := "file:///book/style.css"
+
+	tests := []struct {
+		code string
+		rng  Range
+		want string
+	}{
+		{
+			"WS_002",
+			Range{Start: Position{Line: 1, Character: 13}, End: Position{Line: 1, Character: 14}},
+			"a {\r\n  color: red;\r\n}",
+		},
+		{
+			"WS_003",
+			Range{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 1}},
+			"a {\r\n  color: red; \r\n}\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			// Without fix data the edit is recomputed from the code
+			diag := Diagnostic{Code: tt.code, Range: tt.rng}
+			action := codeActionForDiagnostic(uri, content, &diag, "")
+			if action == nil {
+				t.Fatal("expected a quick fix")
+			}
+			if got := applyEdits(content, action.Edit.Changes[uri]); got != tt.want {
+				t.Errorf("fixed content %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleCodeAction_Reindent(t *testing.T) {
+	content := []byte("a {\n\tcolor: red;\n\tmargin: 0;\n  padding: 0;\n}\n")
+	uri := "file:///book/style.css"
+	diag := Diagnostic{Code: "WS_001"}
+
+	action := codeActionForDiagnostic(uri, content, &diag, "")
+	if action == nil {
+		t.Fatal("expected a reindent action")
+	}
+	if action.Title != "Reindent with tabs" {
+		t.Errorf("title = %q", action.Title)
+	}
+	want := "a {\n\tcolor: red;\n\tmargin: 0;\n\tpadding: 0;\n}\n"
+	if got := applyEdits(content, action.Edit.Changes[uri]); got != want {
+		t.Errorf("fixed content %q, want %q", got, want)
+	}
+}
+
+func TestHandleCodeAction_CoverImage(t *testing.T) {
+	uri := "file:///book/content.opf"
+	opfContent := func(meta, item string) []byte {
+		return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:1</dc:identifier>
+    <dc:title>Title</dc:title>
+    <dc:language>en</dc:language>
+` + meta + `  </metadata>
+  <manifest>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    ` + item + `
+  </manifest>
+  <spine>
+    <itemref idref="ch1"/>
+  </spine>
+</package>
+`)
+	}
+
+	tests := []struct {
+		name     string
+		item     string
+		wantItem string
+	}{
+		{
+			"no properties",
+			`<item id="cover-img" href="cover.jpg" media-type="image/jpeg"/>`,
+			`<item id="cover-img" href="cover.jpg" media-type="image/jpeg" properties="cover-image"/>`,
+		},
+		{
+			"existing properties",
+			`<item id="cover-img" href="cover.svg" media-type="image/svg+xml" properties="svg"></item>`,
+			`<item id="cover-img" href="cover.svg" media-type="image/svg+xml" properties="svg cover-image"></item>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := opfContent(`    <meta name="cover" content="cover-img"/>
+`, tt.item)
+
+			var action *CodeAction
+			for _, d := range (&opf.Validator{}).Validate("", content, nil) {
+				if d.Code == "OPF_087" {
+					lspDiag := toLSPDiagnostic(d)
+					action = codeActionForDiagnostic(uri, content, &lspDiag, "")
+				}
+			}
+			if action == nil {
+				t.Fatal("expected a cover-image quick fix")
+			}
+			if want := `Use properties="cover-image" on item "cover-img"`; action.Title != want {
+				t.Errorf("title = %q, want %q", action.Title, want)
+			}
+
+			edits := action.Edit.Changes[uri]
+			if len(edits) != 2 {
+				t.Fatalf("expected 2 edits, got %d", len(edits))
+			}
+			if want := string(opfContent("", tt.wantItem)); applyEdits(content, edits) != want {
+				t.Errorf("fixed content:\n%s\nwant:\n%s", applyEdits(content, edits), want)
+			}
+		})
+	}
+}
+
+func TestHandleCodeAction_TitleAndBodyText(t *testing.T) {
+	const uri = "file:///book/ch1.xhtml"
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml">
+  <head>
+    <meta charset="utf-8"/>
+  </head>
+  <body>
+    <h1>Chapter 1</h1>
+    Opening <i>words</i>.
+  </body>
+</html>
+`)
+	ws := newMockWorkspace()
+	ws.files[uri] = content
+
+	var lspDiags []Diagnostic
+	for _, d := range (&xhtml.Validator{}).Validate(uri, content, nil) {
+		if d.Code == "title-missing" || d.Code == "body-text" {
+			lspDiags = append(lspDiags, toLSPDiagnostic(d))
+		}
+	}
+	if len(lspDiags) != 2 {
+		t.Fatalf("expected title-missing and body-text, got %+v", lspDiags)
+	}
+
+	data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Context:      CodeActionContext{Diagnostics: lspDiags},
+	})
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+
+	var edits []TextEdit
+	titles := make(map[string]bool)
+	for _, a := range actions {
+		if a.Kind != "quickfix" || a.Edit == nil {
+			continue
+		}
+		titles[a.Title] = true
+		edits = append(edits, a.Edit.Changes[uri]...)
+	}
+	if !titles["Add <title> from first heading"] || !titles["Wrap text in <p>"] {
+		t.Fatalf("expected both quick fixes, got %v", titles)
+	}
+
+	got := applyEdits(content, edits)
+	for _, want := range []string{
+		"<title>Chapter 1</title>\n",
+		"<p>Opening <i>words</i>.</p>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in result:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "<title>") > strings.Index(got, "</head>") {
+		t.Errorf("expected the title inside <head>:\n%s", got)
+	}
+}
+
+func TestHandleCodeAction_AddToManifest(t *testing.T) {
+	ws := newMockWorkspace()
+	opfURI := "file:///book/OEBPS/content.opf"
+	ws.files[opfURI] = []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <manifest>
+    <item id="ch1" href="text/ch1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="notes" href="text/endnotes.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+</package>`)
+	ws.fileTypes[opfURI] = epub.FileTypeOPF
+	ws.manifest = opf.ParseManifest(ws.files[opfURI])
+	ws.manifest.URI = opfURI
+
+	chapterURI := "file:///book/OEBPS/text/ch1.xhtml"
+	chapter := []byte(`<html><body><a href="notes.xhtml#n1">1</a> <a href="gone.xhtml">2</a></body></html>`)
+	ws.files[chapterURI] = chapter
+	ws.files["file:///book/OEBPS/text/notes.xhtml"] = []byte(`<html/>`)
+
+	diagAt := func(ref string) Diagnostic {
+		offset := findSubstring(chapter, ref)
+		return Diagnostic{
+			Code: "RSC_008",
+			Range: Range{
+				Start: lspPos(epub.ByteOffsetToPosition(chapter, offset)),
+				End:   lspPos(epub.ByteOffsetToPosition(chapter, offset+len(ref))),
+			},
+		}
+	}
+	data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: chapterURI},
+		Context: CodeActionContext{
+			Diagnostics: []Diagnostic{diagAt("notes.xhtml"), diagAt("gone.xhtml")},
+		},
+	})
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+
+	// Only the file the workspace holds can be added
+	if len(actions) != 1 {
+		t.Fatalf("expected 1 code action, got %+v", actions)
+	}
+	if actions[0].Title != "Add notes.xhtml to manifest" {
+		t.Errorf("title = %q", actions[0].Title)
+	}
+	edits := actions[0].Edit.Changes[opfURI]
+	if len(edits) != 1 {
+		t.Fatalf("expected one edit to the package document, got %+v", actions[0].Edit.Changes)
+	}
+	// The id is numbered past the existing "notes" item, and the item is
+	// indented like the others on the line before </manifest>
+	want := `    <item id="notes-2" href="text/notes.xhtml" media-type="application/xhtml+xml"/>` + "\n"
+	if edits[0].NewText != want {
+		t.Errorf("edit text = %q, want %q", edits[0].NewText, want)
+	}
+	if start := edits[0].Range.Start; start.Line != 5 || start.Character != 0 {
+		t.Errorf("edit at %+v, want the start of line 5", start)
+	}
+}
+
+func TestManifestItemID(t *testing.T) {
+	manifest := &validator.ManifestInfo{Items: []validator.ManifestItem{
+		{ID: "cover"}, {ID: "cover-2"},
+	}}
+	tests := map[string]string{
+		"file:///book/images/cover.jpg":       "cover-3",
+		"file:///book/text/chapter%201.xhtml": "chapter-1",
+		"file:///book/text/01.xhtml":          "item-01",
+		"file:///book/text/été.xhtml":         "été",
+	}
+	for uri, want := range tests {
+		if got := manifestItemID(uri, manifest); got != want {
+			t.Errorf("manifestItemID(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
+
+func TestHandleCodeAction_PreferredAlt(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/ch1.xhtml"
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<img src="images/harbor_at-dawn.jpg"/>
+<img src="a.png" title="The &quot;Lark&quot;"/>
+<img src="images/img_0042.png"/>
+</body></html>`)
+	ws.files[uri] = content
+	ws.fileTypes[uri] = epub.FileTypeXHTML
+
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{`<img src="images/harbor`, []string{`Add alt="Harbor at dawn"`, decorativeAltTitle}},
+		{`<img src="a.png"`, []string{`Add alt="The &quot;Lark&quot;"`, decorativeAltTitle}},
+		{`<img src="images/img_0042`, []string{decorativeAltTitle}},
+	}
+	for _, tt := range tests {
+		pos := lspPos(epub.ByteOffsetToPosition(content, findSubstring(content, tt.tag)+1))
+		data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+			TextDocument: TextDocumentIdentifier{Uri: uri},
+			Context: CodeActionContext{Diagnostics: []Diagnostic{
+				{Code: "HTM_008", Range: Range{Start: pos, End: pos}},
+			}},
+		})
+		actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+
+		var titles []string
+		for i, a := range actions {
+			titles = append(titles, a.Title)
+			if a.IsPreferred != (i == 0) {
+				t.Errorf("%s: %q preferred = %v", tt.tag, a.Title, a.IsPreferred)
+			}
+		}
+		if !slices.Equal(titles, tt.want) {
+			t.Errorf("%s: titles = %q, want %q", tt.tag, titles, tt.want)
+		}
+	}
+
+	// Fix all takes the preferred fix
+	ws.fresh = map[string][]epub.Diagnostic{
+		uri: epublint.NewWorkspace(ws.files, epublint.Options{}).
+			ValidateFiles(uri)[0].Diagnostics,
+	}
+	data := makeRequest(t, 2, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Context:      CodeActionContext{Only: []string{"source.fixAll"}},
+	})
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	if len(actions) != 1 {
+		t.Fatalf("expected 1 source.fixAll action, got %d", len(actions))
+	}
+	got := applyEdits(content, actions[0].Edit.Changes[uri])
+	for _, want := range []string{
+		`<img src="images/harbor_at-dawn.jpg" alt="Harbor at dawn"/>`,
+		`<img src="images/img_0042.png" alt=""/>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %s in:\n%s", want, got)
+		}
+	}
+}
+
+func TestHandleCodeAction_DisabledAddToManifest(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/OEBPS/text/ch1.xhtml"
+	content := []byte(`<html><body><a href="notes.xhtml">1</a></body></html>`)
+	ws.files[uri] = content
+	ws.files["file:///book/OEBPS/text/notes.xhtml"] = []byte(`<html/>`)
+
+	offset := findSubstring(content, "notes.xhtml")
+	diag := Diagnostic{
+		Code: "RSC_008",
+		Range: Range{
+			Start: lspPos(epub.ByteOffsetToPosition(content, offset)),
+			End:   lspPos(epub.ByteOffsetToPosition(content, offset+len("notes.xhtml"))),
+		},
+	}
+	request := func() []CodeAction {
+		data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+			TextDocument: TextDocumentIdentifier{Uri: uri},
+			Context:      CodeActionContext{Diagnostics: []Diagnostic{diag}},
+		})
+		return unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	}
+
+	if actions := request(); len(actions) != 0 {
+		t.Errorf("expected no actions without disabled support, got %+v", actions)
+	}
+
+	ws.settings = &ServerSettings{CodeActionDisabledSupport: true}
+	actions := request()
+	if len(actions) != 1 {
+		t.Fatalf("expected 1 disabled action, got %+v", actions)
+	}
+	a := actions[0]
+	if a.Title != "Add notes.xhtml to manifest" || a.Edit != nil || a.IsPreferred {
+		t.Errorf("unexpected action %+v", a)
+	}
+	if a.Disabled == nil || a.Disabled.Reason != "no package document is open" {
+		t.Errorf("disabled = %+v, want the missing package document", a.Disabled)
+	}
+}
+
+// undeclaredEpubDocument uses epub:type without declaring the epub prefix.
+const undeclaredEpubDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en">
+<head><title>One</title></head>
+<body><section epub:type="chapter"><p>Text</p></section></body>
+</html>
+`
+
+// declaredEpubDocument is undeclaredEpubDocument with the epub prefix
+// declared after the default namespace.
+var declaredEpubDocument = strings.Replace(undeclaredEpubDocument,
+	`xmlns="http://www.w3.org/1999/xhtml"`,
+	`xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"`, 1)
+
+func TestHandleCodeAction_DeclareEpubNamespace(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/ch1.xhtml"
+	content := []byte(undeclaredEpubDocument)
+	ws.files[uri] = content
+	ws.fileTypes[uri] = epub.FileTypeXHTML
+
+	var diags []Diagnostic
+	for _, d := range epublint.NewWorkspace(ws.files, epublint.Options{}).
+		ValidateFiles(uri)[0].Diagnostics {
+		if d.Code == "HTM_045" {
+			diags = append(diags, toLSPDiagnostic(d))
+		}
+	}
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 HTM_045, got %d", len(diags))
+	}
+
+	data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Context:      CodeActionContext{Diagnostics: diags},
+	})
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	if len(actions) != 1 || actions[0].Edit == nil {
+		t.Fatalf("expected 1 action, got %+v", actions)
+	}
+	got := applyEdits(content, actions[0].Edit.Changes[uri])
+	if got != declaredEpubDocument {
+		t.Errorf("fixed content:\n%s\nwant:\n%s", got, declaredEpubDocument)
+	}
+}
+
+func TestHandleCodeAction_RoleBundlesEpubNamespace(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/ch1.xhtml"
+	content := []byte(undeclaredEpubDocument)
+	ws.files[uri] = content
+	ws.fileTypes[uri] = epub.FileTypeXHTML
+
+	diag := Diagnostic{
+		Code:    "epub-type-has-matching-role",
+		Message: `epub:type="chapter" should have role="doc-chapter"`,
+		Range: Range{Start: lspPos(epub.ByteOffsetToPosition(content,
+			findSubstring(content, "<section")))},
+	}
+	data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Context:      CodeActionContext{Diagnostics: []Diagnostic{diag}},
+	})
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	if len(actions) != 1 || actions[0].Edit == nil {
+		t.Fatalf("expected 1 action, got %+v", actions)
+	}
+	edits := actions[0].Edit.Changes[uri]
+	if len(edits) != 2 {
+		t.Fatalf("expected the role and namespace edits, got %+v", edits)
+	}
+	want := strings.Replace(declaredEpubDocument, `epub:type="chapter">`,
+		`epub:type="chapter" role="doc-chapter">`, 1)
+	if got := applyEdits(content, edits); got != want {
+		t.Errorf("fixed content:\n%s\nwant:\n%s", got, want)
+	}
+
+	// With the prefix declared, the role is added alone
+	ws.files[uri] = []byte(declaredEpubDocument)
+	diag.Range.Start = lspPos(epub.ByteOffsetToPosition(ws.files[uri],
+		findSubstring(ws.files[uri], "<section")))
+	data = makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Context:      CodeActionContext{Diagnostics: []Diagnostic{diag}},
+	})
+	actions = unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	if len(actions) != 1 || len(actions[0].Edit.Changes[uri]) != 1 {
+		t.Errorf("expected only the role edit, got %+v", actions)
+	}
+}
+
+func TestHandleCodeAction_FixAllDeclaresEpubNamespaceOnce(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/ch1.xhtml"
+	content := []byte(undeclaredEpubDocument)
+	ws.files[uri] = content
+	ws.fileTypes[uri] = epub.FileTypeXHTML
+	section := epub.ByteOffsetToPosition(content, findSubstring(content, "<section"))
+	ws.fresh = map[string][]epub.Diagnostic{uri: {
+		{
+			Code:    "epub-type-has-matching-role",
+			Message: `epub:type="chapter" should have role="doc-chapter"`,
+			Range:   epub.Range{Start: section},
+		},
+		{
+			Code:  "HTM_045",
+			Range: epub.Range{Start: section},
+		},
+	}}
+
+	data := makeRequest(t, 1, MethodCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Context:      CodeActionContext{Only: []string{"source.fixAll"}},
+	})
+	actions := unmarshalResult[[]CodeAction](t, HandleCodeAction(t.Context(), data, ws))
+	if len(actions) != 1 || actions[0].Edit == nil {
+		t.Fatalf("expected 1 source.fixAll action, got %d", len(actions))
+	}
+	want := strings.Replace(declaredEpubDocument, `epub:type="chapter">`,
+		`epub:type="chapter" role="doc-chapter">`, 1)
+	if got := applyEdits(content, actions[0].Edit.Changes[uri]); got != want {
+		t.Errorf("fixed content:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,170 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sync/atomic"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// CodeLensParams holds parameters for textDocument/codeLens.
+type CodeLensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// CodeLens is a command shown above a range of a document.
+type CodeLens struct {
+	Range   Range    `json:"range"`
+	Command *Command `json:"command,omitempty"`
+}
+
+// Command is a command the client runs through workspace/executeCommand.
+// An empty Command makes a lens a plain label.
+type Command struct {
+	Title     string `json:"title"`
+	Command   string `json:"command"`
+	Arguments []any  `json:"arguments,omitempty"`
+}
+
+// CodeLensOptions describes code lens capabilities.
+type CodeLensOptions struct{}
+
+// ShowDocumentParams holds parameters for window/showDocument.
+type ShowDocumentParams struct {
+	URI       string `json:"uri"`
+	TakeFocus bool   `json:"takeFocus,omitempty"`
+}
+
+// serverRequestID numbers the requests the server sends to the client.
+var serverRequestID atomic.Int64
+
+// ShowDocumentRequest builds a window/showDocument request asking the
+// client to open uri.
+func ShowDocumentRequest(uri string) []byte {
+	req := RequestMessage[ShowDocumentParams]{
+		JsonRpc: JSONRPCVersion,
+		Id:      NumberID(serverRequestID.Add(1)),
+		Method:  MethodShowDocument,
+		Params:  ShowDocumentParams{URI: uri, TakeFocus: true},
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		slog.Error("error marshalling showDocument: " + err.Error())
+		return nil
+	}
+	return data
+}
+
+// HandleCodeLens processes textDocument/codeLens requests. Content
+// documents get lenses at the top showing their place in the spine, with
+// the previous and next documents as commands that open them.
+func HandleCodeLens(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
+	var req RequestMessage[CodeLensParams]
+	if err := json.Unmarshal(data, &req); err != nil {
+		Logger(ctx).Error("error unmarshalling codeLens: " + err.Error())
+		return marshalResponse(req.Id, []CodeLens{})
+	}
+
+	uri := req.Params.TextDocument.Uri
+	if ft := ws.GetFileType(uri); ft != epub.FileTypeXHTML && ft != epub.FileTypeNav {
+		return marshalResponse(req.Id, []CodeLens{})
+	}
+	return marshalResponse(req.Id, spineLenses(uri, ws))
+}
+
+// spineLenses returns the lenses for uri: the previous document, its
+// position in the spine, and the next document, or "not in spine".
+func spineLenses(uri string, ws WorkspaceReader) []CodeLens {
+	top := Range{}
+	spine := spineURIs(ws)
+	i := spineIndex(spine, uri)
+	if i < 0 {
+		return []CodeLens{{Range: top, Command: &Command{Title: "not in spine"}}}
+	}
+
+	var lenses []CodeLens
+	if i > 0 {
+		lenses = append(lenses, CodeLens{Range: top, Command: &Command{
+			Title:     "◀ " + path.Base(uriutil.Path(spine[i-1])),
+			Command:   CommandOpenPreviousInSpine,
+			Arguments: []any{uri},
+		}})
+	}
+	lenses = append(lenses, CodeLens{Range: top, Command: &Command{
+		Title: fmt.Sprintf("spine %d/%d", i+1, len(spine)),
+	}})
+	if i < len(spine)-1 {
+		lenses = append(lenses, CodeLens{Range: top, Command: &Command{
+			Title:     path.Base(uriutil.Path(spine[i+1])) + " ▶",
+			Command:   CommandOpenNextInSpine,
+			Arguments: []any{uri},
+		}})
+	}
+	return lenses
+}
+
+// spineURIs returns the URIs of the spine documents in reading order,
+// resolved against the package document. Itemrefs naming no manifest item
+// are skipped.
+func spineURIs(ws WorkspaceReader) []string {
+	return spineURIsWhere(ws, func(validator.SpineItem) bool { return true })
+}
+
+// linearSpineURIs returns the URIs of the spine documents in the linear
+// reading order, leaving out itemrefs with linear="no".
+func linearSpineURIs(ws WorkspaceReader) []string {
+	return spineURIsWhere(ws, func(item validator.SpineItem) bool { return item.Linear })
+}
+
+// spineURIsWhere returns the URIs of the spine documents whose itemrefs
+// keep accepts, in reading order.
+func spineURIsWhere(ws WorkspaceReader, keep func(validator.SpineItem) bool) []string {
+	manifest := ws.GetManifest()
+	opfURI := packageDocumentURI(ws)
+	if manifest == nil || opfURI == "" {
+		return nil
+	}
+
+	hrefs := make(map[string]string, len(manifest.Items))
+	for _, item := range manifest.Items {
+		hrefs[item.ID] = item.Href
+	}
+	var uris []string
+	for _, itemref := range manifest.Spine {
+		if !keep(itemref) {
+			continue
+		}
+		if href, ok := hrefs[itemref.IDRef]; ok && href != "" {
+			uris = append(uris, uriutil.ResolveRelative(opfURI, epub.StripFragment(href)))
+		}
+	}
+	return uris
+}
+
+// spineIndex returns the position of uri in spine, or -1.
+func spineIndex(spine []string, uri string) int {
+	want := uriutil.Path(uri)
+	for i, u := range spine {
+		if uriutil.Path(u) == want {
+			return i
+		}
+	}
+	return -1
+}
+
+// spineNeighbor returns the document step places from uri in the spine,
+// or "" when there is none.
+func spineNeighbor(ws WorkspaceReader, uri string, step int) string {
+	spine := spineURIs(ws)
+	i := spineIndex(spine, uri)
+	if i < 0 || i+step < 0 || i+step >= len(spine) {
+		return ""
+	}
+	return spine[i+step]
+}
@@ -0,0 +1,144 @@
+package lsp
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// spineWorkspace returns a workspace whose spine holds ch1 to ch3, with an
+// extra content document outside the spine.
+func spineWorkspace() *mockWorkspace {
+	ws := newMockWorkspace()
+	ws.files["file:///book/OEBPS/content.opf"] = []byte(`<package/>`)
+	ws.fileTypes["file:///book/OEBPS/content.opf"] = epub.FileTypeOPF
+	for _, name := range []string{"ch1", "ch2", "ch3", "notes"} {
+		uri := "file:///book/OEBPS/text/" + name + ".xhtml"
+		ws.files[uri] = []byte(`<html/>`)
+		ws.fileTypes[uri] = epub.FileTypeXHTML
+	}
+	ws.manifest = &validator.ManifestInfo{
+		Items: []validator.ManifestItem{
+			{ID: "ch1", Href: "text/ch1.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "ch2", Href: "text/ch2.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "ch3", Href: "text/ch3.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "notes", Href: "text/notes.xhtml", MediaType: "application/xhtml+xml"},
+		},
+		Spine: []validator.SpineItem{
+			{IDRef: "ch1", Linear: true},
+			{IDRef: "ch2", Linear: true},
+			{IDRef: "ch3", Linear: true},
+		},
+	}
+	return ws
+}
+
+func codeLensTitles(t *testing.T, ws *mockWorkspace, uri string) ([]string, []CodeLens) {
+	t.Helper()
+	data := makeRequest(t, 1, MethodCodeLens, CodeLensParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+	})
+	lenses := unmarshalResult[[]CodeLens](t, HandleCodeLens(t.Context(), data, ws))
+	titles := make([]string, len(lenses))
+	for i, lens := range lenses {
+		if lens.Command == nil {
+			t.Fatalf("lens %d has no command", i)
+		}
+		if lens.Range != (Range{}) {
+			t.Errorf("lens %d range = %+v, want the top of the document", i, lens.Range)
+		}
+		titles[i] = lens.Command.Title
+	}
+	return titles, lenses
+}
+
+func TestHandleCodeLens_Spine(t *testing.T) {
+	tests := []struct {
+		name, doc string
+		want      []string
+	}{
+		{"first", "ch1", []string{"spine 1/3", "ch2.xhtml ▶"}},
+		{"middle", "ch2", []string{"◀ ch1.xhtml", "spine 2/3", "ch3.xhtml ▶"}},
+		{"last", "ch3", []string{"◀ ch2.xhtml", "spine 3/3"}},
+		{"not in spine", "notes", []string{"not in spine"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri := "file:///book/OEBPS/text/" + tt.doc + ".xhtml"
+			titles, lenses := codeLensTitles(t, spineWorkspace(), uri)
+			if !slices.Equal(titles, tt.want) {
+				t.Fatalf("titles = %q, want %q", titles, tt.want)
+			}
+			for _, lens := range lenses {
+				switch lens.Command.Command {
+				case "":
+				case CommandOpenNextInSpine, CommandOpenPreviousInSpine:
+					if len(lens.Command.Arguments) != 1 || lens.Command.Arguments[0] != uri {
+						t.Errorf("%q arguments = %v, want [%s]", lens.Command.Title, lens.Command.Arguments, uri)
+					}
+				default:
+					t.Errorf("unexpected command %q", lens.Command.Command)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleCodeLens_NotContentDocument(t *testing.T) {
+	titles, _ := codeLensTitles(t, spineWorkspace(), "file:///book/OEBPS/content.opf")
+	if len(titles) != 0 {
+		t.Errorf("expected no lenses on the package document, got %q", titles)
+	}
+}
+
+func TestHandleExecuteCommand_OpenInSpine(t *testing.T) {
+	ws := spineWorkspace()
+	ch2 := json.RawMessage(`"file:///book/OEBPS/text/ch2.xhtml"`)
+
+	data := makeRequest(t, 1, MethodExecuteCommand, ExecuteCommandParams{
+		Command:   CommandOpenNextInSpine,
+		Arguments: []json.RawMessage{ch2},
+	})
+	response, requests := HandleExecuteCommand(t.Context(), data, ws)
+	if got := unmarshalResult[string](t, response); got != "file:///book/OEBPS/text/ch3.xhtml" {
+		t.Errorf("next = %q", got)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected no showDocument request without client support, got %d", len(requests))
+	}
+
+	ws.settings = &ServerSettings{ShowDocumentSupport: true}
+	data = makeRequest(t, 2, MethodExecuteCommand, ExecuteCommandParams{
+		Command:   CommandOpenPreviousInSpine,
+		Arguments: []json.RawMessage{ch2},
+	})
+	response, requests = HandleExecuteCommand(t.Context(), data, ws)
+	if got := unmarshalResult[string](t, response); got != "file:///book/OEBPS/text/ch1.xhtml" {
+		t.Errorf("previous = %q", got)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 showDocument request, got %d", len(requests))
+	}
+	var req RequestMessage[ShowDocumentParams]
+	if err := json.Unmarshal(requests[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != MethodShowDocument || req.Params.URI != "file:///book/OEBPS/text/ch1.xhtml" ||
+		!req.Params.TakeFocus || req.Id == "" {
+		t.Errorf("unexpected showDocument request %s", requests[0])
+	}
+
+	// The first document has no previous one
+	data = makeRequest(t, 3, MethodExecuteCommand, ExecuteCommandParams{
+		Command:   CommandOpenPreviousInSpine,
+		Arguments: []json.RawMessage{json.RawMessage(`"file:///book/OEBPS/text/ch1.xhtml"`)},
+	})
+	response, requests = HandleExecuteCommand(t.Context(), data, ws)
+	if got := unmarshalResult[*string](t, response); got != nil || len(requests) != 0 {
+		t.Errorf("expected a null result and no request, got %v and %d requests", got, len(requests))
+	}
+}
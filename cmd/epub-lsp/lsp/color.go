@@ -0,0 +1,108 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/formatter"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// DocumentColorParams holds parameters for textDocument/documentColor.
+type DocumentColorParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// Color is an RGBA color with components from 0 to 1.
+type Color struct {
+	Red   float64 `json:"red"`
+	Green float64 `json:"green"`
+	Blue  float64 `json:"blue"`
+	Alpha float64 `json:"alpha"`
+}
+
+// ColorInformation is a color value and where it appears in a document.
+type ColorInformation struct {
+	Range Range `json:"range"`
+	Color Color `json:"color"`
+}
+
+// ColorPresentationParams holds parameters for
+// textDocument/colorPresentation.
+type ColorPresentationParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Color        Color                  `json:"color"`
+	Range        Range                  `json:"range"`
+}
+
+// ColorPresentation is one way to write a color, with the edit that
+// writes it.
+type ColorPresentation struct {
+	Label    string    `json:"label"`
+	TextEdit *TextEdit `json:"textEdit,omitempty"`
+}
+
+// HandleDocumentColor processes textDocument/documentColor requests,
+// returning the colors in a stylesheet or in the style attributes of a
+// content document.
+func HandleDocumentColor(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
+	var req RequestMessage[DocumentColorParams]
+	if err := json.Unmarshal(data, &req); err != nil {
+		Logger(ctx).Error("error unmarshalling documentColor: " + err.Error())
+		return marshalResponse(req.Id, []ColorInformation{})
+	}
+
+	uri := req.Params.TextDocument.Uri
+	content := ws.GetContent(uri)
+	if content == nil {
+		return marshalResponse(req.Id, []ColorInformation{})
+	}
+
+	lines := epub.NewLineIndex(content)
+	colors := []ColorInformation{}
+	add := func(matches []formatter.ColorMatch, offset int) {
+		for _, m := range matches {
+			colors = append(colors, ColorInformation{
+				Range: Range{
+					Start: lspPos(lines.Position(offset + m.Start)),
+					End:   lspPos(lines.Position(offset + m.End)),
+				},
+				Color: Color(m.Color),
+			})
+		}
+	}
+
+	switch ws.GetFileType(uri) {
+	case epub.FileTypeCSS:
+		add(formatter.FindColors(string(content), false), 0)
+	case epub.FileTypeXHTML, epub.FileTypeNav:
+		parser.ScanAttrs(content, func(name string, offset int, value string) {
+			if name == "style" {
+				add(formatter.FindColors(value, true), offset)
+			}
+		})
+	}
+	return marshalResponse(req.Id, colors)
+}
+
+// HandleColorPresentation processes textDocument/colorPresentation
+// requests, offering the picked color as hex and as rgb().
+func HandleColorPresentation(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
+	var req RequestMessage[ColorPresentationParams]
+	if err := json.Unmarshal(data, &req); err != nil {
+		Logger(ctx).Error("error unmarshalling colorPresentation: " + err.Error())
+		return marshalResponse(req.Id, []ColorPresentation{})
+	}
+
+	p := req.Params
+	color := formatter.Color(p.Color)
+	clamper := newRangeClamper(ctx, ws, MethodColorPresentation)
+	var presentations []ColorPresentation
+	for _, label := range []string{formatter.FormatHex(color), formatter.FormatRGB(color)} {
+		edit := TextEdit{Range: p.Range, NewText: label}
+		clamper.clamp(p.TextDocument.Uri, &edit.Range)
+		presentations = append(presentations, ColorPresentation{Label: label, TextEdit: &edit})
+	}
+	return marshalResponse(req.Id, presentations)
+}
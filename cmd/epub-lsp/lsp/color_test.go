@@ -0,0 +1,126 @@
+package lsp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+func TestHandleDocumentColor_CSS(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/style.css"
+	ws.files[uri] = []byte(`#main { color: #f00; }
+h1 { color: #00ff0080; }
+h2 { color: #0000ff; }
+p { color: rgb(100%, 0%, 0%); }
+em { color: rgba(0, 0, 255, 0.5); }
+strong { color: hsl(120, 100%, 25%); }
+a { color: navy; }
+`)
+	ws.fileTypes[uri] = epub.FileTypeCSS
+
+	data := makeRequest(t, 1, MethodDocumentColor, DocumentColorParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+	})
+	colors := unmarshalResult[[]ColorInformation](t, HandleDocumentColor(t.Context(), data, ws))
+
+	want := []struct {
+		line, start, end uint
+		color            Color
+	}{
+		{0, 15, 19, Color{1, 0, 0, 1}},
+		{1, 12, 21, Color{0, 1, 0, 128.0 / 255}},
+		{2, 12, 19, Color{0, 0, 1, 1}},
+		{3, 11, 28, Color{1, 0, 0, 1}},
+		{4, 12, 32, Color{0, 0, 1, 0.5}},
+		{5, 16, 35, Color{0, 0.5, 0, 1}},
+		{6, 11, 15, Color{0, 0, 128.0 / 255, 1}},
+	}
+	if len(colors) != len(want) {
+		t.Fatalf("expected %d colors, got %d: %+v", len(want), len(colors), colors)
+	}
+	for i, w := range want {
+		got := colors[i]
+		r := Range{Start: Position{w.line, w.start}, End: Position{w.line, w.end}}
+		if got.Range != r {
+			t.Errorf("color %d range = %+v, want %+v", i, got.Range, r)
+		}
+		if !lspColorsEqual(got.Color, w.color) {
+			t.Errorf("color %d = %+v, want %+v", i, got.Color, w.color)
+		}
+	}
+}
+
+func TestHandleDocumentColor_StyleAttribute(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/chapter1.xhtml"
+	ws.files[uri] = []byte(`<html xmlns="http://www.w3.org/1999/xhtml">
+<body>
+<p style="color: #336699; margin: 0">Text</p>
+<p class="red">Red class</p>
+</body>
+</html>`)
+	ws.fileTypes[uri] = epub.FileTypeXHTML
+
+	data := makeRequest(t, 1, MethodDocumentColor, DocumentColorParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+	})
+	colors := unmarshalResult[[]ColorInformation](t, HandleDocumentColor(t.Context(), data, ws))
+
+	if len(colors) != 1 {
+		t.Fatalf("expected 1 color, got %d: %+v", len(colors), colors)
+	}
+	r := Range{Start: Position{2, 17}, End: Position{2, 24}}
+	if colors[0].Range != r {
+		t.Errorf("range = %+v, want %+v", colors[0].Range, r)
+	}
+}
+
+func TestHandleColorPresentation(t *testing.T) {
+	ws := newMockWorkspace()
+	uri := "file:///book/style.css"
+	ws.files[uri] = []byte("p { color: red; }\n")
+	ws.fileTypes[uri] = epub.FileTypeCSS
+
+	r := Range{Start: Position{0, 11}, End: Position{0, 14}}
+	tests := []struct {
+		name  string
+		color Color
+		want  []string
+	}{
+		{"opaque", Color{1, 0.5, 0, 1}, []string{"#ff8000", "rgb(255, 128, 0)"}},
+		{"translucent", Color{0, 0, 1, 0.25}, []string{"#0000ff40", "rgba(0, 0, 255, 0.25)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := makeRequest(t, 1, MethodColorPresentation, ColorPresentationParams{
+				TextDocument: TextDocumentIdentifier{Uri: uri},
+				Color:        tt.color,
+				Range:        r,
+			})
+			presentations := unmarshalResult[[]ColorPresentation](t,
+				HandleColorPresentation(t.Context(), data, ws))
+
+			if len(presentations) != len(tt.want) {
+				t.Fatalf("expected %d presentations, got %+v", len(tt.want), presentations)
+			}
+			for i, want := range tt.want {
+				p := presentations[i]
+				if p.Label != want {
+					t.Errorf("presentation %d label = %q, want %q", i, p.Label, want)
+				}
+				if p.TextEdit == nil || p.TextEdit.NewText != want || p.TextEdit.Range != r {
+					t.Errorf("presentation %d edit = %+v", i, p.TextEdit)
+				}
+			}
+		})
+	}
+}
+
+func lspColorsEqual(a, b Color) bool {
+	const eps = 0.005
+	return math.Abs(a.Red-b.Red) < eps && math.Abs(a.Green-b.Green) < eps &&
+		math.Abs(a.Blue-b.Blue) < eps && math.Abs(a.Alpha-b.Alpha) < eps
+}
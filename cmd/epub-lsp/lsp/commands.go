@@ -0,0 +1,575 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/epublint"
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/export"
+	"github.com/toba/epub-lsp/internal/epub/ignore"
+	"github.com/toba/epub-lsp/internal/epub/packager"
+	"github.com/toba/epub-lsp/internal/epub/sarif"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+	"github.com/toba/epub-lsp/internal/epub/validator/opf"
+	"github.com/toba/epub-lsp/internal/epub/validator/resource"
+	"github.com/toba/lsp/pathutil"
+)
+
+const (
+	// CommandFindOrphans lists workspace files that the package manifest
+	// does not reference.
+	CommandFindOrphans = "epub-lsp.findOrphans"
+	// CommandExportSarif converts the workspace diagnostics to a SARIF log.
+	CommandExportSarif = "epub-lsp.exportSarif"
+	// CommandPackage zips the workspace into a .epub file.
+	CommandPackage = "epub-lsp.package"
+	// CommandOpenNextInSpine opens the document after the argument's in
+	// the spine.
+	CommandOpenNextInSpine = "epub-lsp.openNextInSpine"
+	// CommandOpenPreviousInSpine opens the document before the argument's
+	// in the spine.
+	CommandOpenPreviousInSpine = "epub-lsp.openPreviousInSpine"
+	// CommandStats counts the words, characters, and images of each spine
+	// item and estimates its reading time.
+	CommandStats = "epub-lsp.stats"
+	// CommandSummarize summarizes the diagnostics of the files given as
+	// its argument.
+	CommandSummarize = "epub-lsp.summarize"
+	// CommandListTrackedFiles lists the files the server holds and how
+	// each is validated.
+	CommandListTrackedFiles = "epub-lsp.listTrackedFiles"
+	// CommandExportText writes the linear spine items as one plain text or
+	// Markdown file.
+	CommandExportText = "epub-lsp.exportText"
+	// CommandFindDuplicateResources lists manifest items with identical
+	// content.
+	CommandFindDuplicateResources = "epub-lsp.findDuplicateResources"
+)
+
+// Commands lists the commands served through workspace/executeCommand.
+var Commands = []string{
+	CommandFindOrphans,
+	CommandExportSarif,
+	CommandPackage,
+	CommandOpenNextInSpine,
+	CommandOpenPreviousInSpine,
+	CommandStats,
+	CommandSummarize,
+	CommandListTrackedFiles,
+	CommandExportText,
+	CommandFindDuplicateResources,
+}
+
+// ExecuteCommandParams holds parameters for workspace/executeCommand.
+type ExecuteCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+// FindOrphansOptions is the optional argument of CommandFindOrphans.
+type FindOrphansOptions struct {
+	// Publish reports an info diagnostic at the start of each orphan.
+	Publish bool `json:"publish"`
+}
+
+// ExportSarifOptions is the optional argument of CommandExportSarif.
+type ExportSarifOptions struct {
+	// Output is the file to write the log to, relative to the workspace
+	// root. When empty, the log is returned in the response.
+	Output string `json:"output"`
+}
+
+// PackageOptions is the argument of CommandPackage.
+type PackageOptions struct {
+	// Output is the .epub file to write, relative to the workspace root.
+	Output string `json:"output"`
+	// Force packages the book even when validation reports errors.
+	Force bool `json:"force"`
+}
+
+// PackageResult is the result of CommandPackage: the path written, or the
+// error diagnostics that stopped packaging, by URI.
+type PackageResult struct {
+	Output   string                  `json:"output,omitempty"`
+	Blocking map[string][]Diagnostic `json:"blocking,omitempty"`
+}
+
+// SummarizeResult is the result of CommandSummarize: the summary of the
+// listed files' diagnostics, with Text rendering it as the check output
+// does.
+type SummarizeResult struct {
+	epublint.Summary
+	Text string `json:"text"`
+}
+
+// FindDuplicateResourcesOptions is the optional argument of
+// CommandFindDuplicateResources.
+type FindDuplicateResourcesOptions struct {
+	// IncludeText also compares XHTML and CSS documents.
+	IncludeText bool `json:"includeText"`
+}
+
+// DuplicateResourcesResult is the result of CommandFindDuplicateResources:
+// the groups of manifest items with identical content, most wasted bytes
+// first, and the bytes all copies but the first of each take.
+type DuplicateResourcesResult struct {
+	Groups      []resource.DuplicateGroup `json:"groups"`
+	WastedBytes int64                     `json:"wastedBytes"`
+}
+
+// TrackedFile is a file the server holds, as listed by
+// CommandListTrackedFiles.
+type TrackedFile struct {
+	URI      string `json:"uri"`
+	FileType string `json:"fileType"`
+}
+
+// HandleExecuteCommand processes workspace/executeCommand requests. Along
+// with the response it returns any notifications the command produced, for
+// the caller to send after the response.
+func HandleExecuteCommand(
+	ctx context.Context,
+	data []byte,
+	ws WorkspaceReader,
+) ([]byte, [][]byte) {
+	var req RequestMessage[ExecuteCommandParams]
+	if err := json.Unmarshal(data, &req); err != nil {
+		Logger(ctx).Error("error unmarshalling executeCommand: " + err.Error())
+		return marshalResponse[any](req.Id, nil), nil
+	}
+
+	switch req.Params.Command {
+	case CommandFindOrphans:
+		var opts FindOrphansOptions
+		if len(req.Params.Arguments) > 0 {
+			if err := json.Unmarshal(req.Params.Arguments[0], &opts); err != nil {
+				Logger(ctx).Warn("ignoring findOrphans argument: " + err.Error())
+			}
+		}
+
+		orphans := findOrphans(ctx, ws)
+
+		var notifications [][]byte
+		if opts.Publish {
+			for _, uri := range orphans {
+				notifications = append(notifications, PublishDiagnosticsNotification(
+					uri, nil, []epub.Diagnostic{resource.OrphanDiagnostic()}))
+			}
+		}
+		return marshalResponse(req.Id, orphans), notifications
+
+	case CommandExportSarif:
+		var opts ExportSarifOptions
+		if len(req.Params.Arguments) > 0 {
+			if err := json.Unmarshal(req.Params.Arguments[0], &opts); err != nil {
+				Logger(ctx).Warn("ignoring exportSarif argument: " + err.Error())
+			}
+		}
+
+		log := exportSarif(ws)
+		if opts.Output == "" {
+			return marshalResponse(req.Id, log), nil
+		}
+		path, err := writeSarif(log, opts.Output, ws.GetRootPath())
+		if err != nil {
+			return marshalErrorResponse(req.Id, ErrorInternalError,
+				"error writing SARIF log: "+err.Error()), nil
+		}
+		return marshalResponse(req.Id, path), nil
+
+	case CommandPackage:
+		var opts PackageOptions
+		if len(req.Params.Arguments) > 0 {
+			if err := json.Unmarshal(req.Params.Arguments[0], &opts); err != nil {
+				return marshalErrorResponse(req.Id, ErrorInvalidParams,
+					"invalid package argument: "+err.Error()), nil
+			}
+		}
+		if opts.Output == "" {
+			return marshalErrorResponse(req.Id, ErrorInvalidParams,
+				"package requires an output path"), nil
+		}
+		opfURI := packageDocumentURI(ws)
+		if opfURI == "" {
+			return marshalErrorResponse(req.Id, ErrorInvalidParams,
+				"no package document in the workspace"), nil
+		}
+
+		if !opts.Force {
+			blocking := blockingDiagnostics(ctx, ws)
+			if ctx.Err() != nil {
+				return cancelledResponse(req.Id), nil
+			}
+			if len(blocking) > 0 {
+				return marshalResponse(req.Id, PackageResult{Blocking: blocking}), nil
+			}
+		}
+		path, err := writePackage(ws, opfURI, opts.Output)
+		if err != nil {
+			return marshalErrorResponse(req.Id, ErrorInternalError,
+				"error packaging EPUB: "+err.Error()), nil
+		}
+		return marshalResponse(req.Id, PackageResult{Output: path}), nil
+
+	case CommandOpenNextInSpine, CommandOpenPreviousInSpine:
+		var uri string
+		if len(req.Params.Arguments) > 0 {
+			if err := json.Unmarshal(req.Params.Arguments[0], &uri); err != nil {
+				return marshalErrorResponse(req.Id, ErrorInvalidParams,
+					"invalid document argument: "+err.Error()), nil
+			}
+		}
+		step := 1
+		if req.Params.Command == CommandOpenPreviousInSpine {
+			step = -1
+		}
+
+		target := spineNeighbor(ws, uri, step)
+		if target == "" {
+			return marshalResponse[any](req.Id, nil), nil
+		}
+		// Without showDocument support the client gets the target to open
+		// itself
+		if settings := ws.GetSettings(); settings == nil || !settings.ShowDocumentSupport {
+			return marshalResponse(req.Id, target), nil
+		}
+		return marshalResponse(req.Id, target), [][]byte{ShowDocumentRequest(target)}
+
+	case CommandStats:
+		return marshalResponse(req.Id, bookStats(ws)), nil
+
+	case CommandSummarize:
+		var files []string
+		if len(req.Params.Arguments) > 0 {
+			if err := json.Unmarshal(req.Params.Arguments[0], &files); err != nil {
+				return marshalErrorResponse(req.Id, ErrorInvalidParams,
+					"invalid summarize argument: "+err.Error()), nil
+			}
+		}
+
+		summary := summarizeFiles(ctx, ws, files)
+		if ctx.Err() != nil {
+			return cancelledResponse(req.Id), nil
+		}
+		return marshalResponse(req.Id, SummarizeResult{
+			Summary: summary,
+			Text:    summary.String(),
+		}), nil
+
+	case CommandFindDuplicateResources:
+		var opts FindDuplicateResourcesOptions
+		if len(req.Params.Arguments) > 0 {
+			if err := json.Unmarshal(req.Params.Arguments[0], &opts); err != nil {
+				Logger(ctx).Warn("ignoring findDuplicateResources argument: " + err.Error())
+			}
+		}
+		return marshalResponse(req.Id, findDuplicateResources(ws, opts.IncludeText)), nil
+
+	case CommandListTrackedFiles:
+		return marshalResponse(req.Id, trackedFiles(ws)), nil
+
+	case CommandExportText:
+		var opts ExportTextOptions
+		if len(req.Params.Arguments) > 0 {
+			if err := json.Unmarshal(req.Params.Arguments[0], &opts); err != nil {
+				return marshalErrorResponse(req.Id, ErrorInvalidParams,
+					"invalid exportText argument: "+err.Error()), nil
+			}
+		}
+		if opts.Format == "" {
+			opts.Format = export.FormatText
+		}
+		if !opts.Format.Valid() {
+			return marshalErrorResponse(req.Id, ErrorInvalidParams,
+				"unknown export format: "+string(opts.Format)), nil
+		}
+		if opts.Output == "" {
+			return marshalErrorResponse(req.Id, ErrorInvalidParams,
+				"exportText requires an output path"), nil
+		}
+
+		result, err := exportText(ws, opts)
+		if err != nil {
+			return marshalErrorResponse(req.Id, ErrorInternalError,
+				"error exporting text: "+err.Error()), nil
+		}
+		return marshalResponse(req.Id, result), nil
+	}
+
+	return marshalErrorResponse(req.Id, ErrorInvalidParams,
+		"unknown command: "+req.Params.Command), nil
+}
+
+// findOrphans returns the URIs of files on disk in the package document's
+// directory tree that its manifest does not list.
+func findOrphans(ctx context.Context, ws WorkspaceReader) []string {
+	opfURI := packageDocumentURI(ws)
+	if opfURI == "" {
+		return []string{}
+	}
+	files := ws.GetAllFiles()
+
+	// Scan from the workspace root so ignore patterns are relative to it
+	root, rel := bookRoot(ws, opfURI)
+
+	orphans, err := resource.OrphanedFiles(os.DirFS(root), rel,
+		opf.ParseManifest(files[opfURI]), IgnoreMatcher(root, ws.GetSettings()))
+	if err != nil {
+		Logger(ctx).Error("error scanning for orphaned files: " + err.Error())
+	}
+
+	uris := make([]string, len(orphans))
+	for i, p := range orphans {
+		uris[i] = pathutil.FilePathToURI(filepath.Join(root, filepath.FromSlash(p)))
+	}
+	return uris
+}
+
+// findDuplicateResources groups the package document's manifest items by
+// content, reading workspace files over the ones on disk.
+func findDuplicateResources(ws WorkspaceReader, includeText bool) DuplicateResourcesResult {
+	result := DuplicateResourcesResult{Groups: []resource.DuplicateGroup{}}
+	opfURI := packageDocumentURI(ws)
+	if opfURI == "" {
+		return result
+	}
+	files := ws.GetAllFiles()
+	manifest := opf.ParseManifest(files[opfURI])
+	if manifest == nil {
+		return result
+	}
+
+	ctx := &validator.WorkspaceContext{RootPath: ws.GetRootPath(), Files: files}
+	for _, group := range resource.FindDuplicates(ctx, opfURI, manifest.Items, includeText) {
+		result.Groups = append(result.Groups, group)
+		result.WastedBytes += group.Wasted
+	}
+	return result
+}
+
+// IgnoreMatcher returns the patterns skipped when scanning the workspace at
+// root: the defaults, then the root's .gitignore when settings ask for it,
+// then the ignore patterns of settings.
+func IgnoreMatcher(root string, settings *ServerSettings) *ignore.Matcher {
+	m := ignore.New(ignore.Defaults...)
+	if settings == nil {
+		return m
+	}
+	if settings.Gitignore && root != "" {
+		if err := m.AddFile(os.DirFS(root), ignore.GitignoreFile); err != nil {
+			slog.Warn("error reading " + ignore.GitignoreFile + ": " + err.Error())
+		}
+	}
+	m.Add(settings.Ignore...)
+	return m
+}
+
+// trackedFiles lists the files the workspace holds, in URI order.
+func trackedFiles(ws WorkspaceReader) []TrackedFile {
+	files := []TrackedFile{}
+	for uri := range ws.GetAllFiles() {
+		files = append(files, TrackedFile{URI: uri, FileType: ws.GetFileType(uri).String()})
+	}
+	slices.SortFunc(files, func(a, b TrackedFile) int {
+		return strings.Compare(a.URI, b.URI)
+	})
+	return files
+}
+
+// summarizeFiles summarizes the stored diagnostics of files, given as URIs
+// or as paths relative to the workspace root. Files no validation pass has
+// covered yet are validated now; files the workspace does not hold are
+// skipped. It stops early when ctx is cancelled.
+func summarizeFiles(ctx context.Context, ws WorkspaceReader, files []string) epublint.Summary {
+	all := ws.GetAllFiles()
+	root := ws.GetRootPath()
+
+	var results []epublint.FileDiagnostics
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return epublint.Summary{}
+		}
+		uri, ok := uriutil.Lookup(all, fileURI(file, root))
+		if !ok {
+			Logger(ctx).Debug("summarize skipping file outside the workspace", "file", file)
+			continue
+		}
+		diags := ws.GetDiagnostics(uri)
+		if !ws.Validated(uri) {
+			diags = ws.Validate(uri)
+		}
+		results = append(results, epublint.FileDiagnostics{
+			URI:         displayPath(uri, root),
+			Diagnostics: diags,
+		})
+	}
+	return epublint.Summarize(results)
+}
+
+// fileURI returns file as a URI, resolving paths against root.
+func fileURI(file, root string) string {
+	if strings.Contains(file, "://") {
+		return file
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(root, filepath.FromSlash(file))
+	}
+	return pathutil.FilePathToURI(file)
+}
+
+// displayPath returns the slash-separated path of uri relative to root, or
+// uri itself when it lies outside root.
+func displayPath(uri, root string) string {
+	if root == "" {
+		return uri
+	}
+	rel, err := filepath.Rel(root, pathutil.URIToFilePath(uri))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return uri
+	}
+	return filepath.ToSlash(rel)
+}
+
+// packageDocumentURI returns the URI of the workspace's package document,
+// the first in URI order when there are several, or "" if there is none.
+func packageDocumentURI(ws WorkspaceReader) string {
+	var opfURIs []string
+	for uri := range ws.GetAllFiles() {
+		if ws.GetFileType(uri) == epub.FileTypeOPF {
+			opfURIs = append(opfURIs, uri)
+		}
+	}
+	if len(opfURIs) == 0 {
+		return ""
+	}
+	return slices.Min(opfURIs)
+}
+
+// bookRoot returns the directory holding the unpacked book, the workspace
+// root unless the package document lies outside it, and the package
+// document's slash-separated path within it.
+func bookRoot(ws WorkspaceReader, opfURI string) (root, opfPath string) {
+	opfFile := pathutil.URIToFilePath(opfURI)
+	root = ws.GetRootPath()
+	rel, err := filepath.Rel(root, opfFile)
+	if root == "" || err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.Dir(opfFile), filepath.Base(opfFile)
+	}
+	return root, filepath.ToSlash(rel)
+}
+
+// blockingDiagnostics runs the validators over every workspace file and
+// returns the error diagnostics, by URI. It stops early when ctx is
+// cancelled.
+func blockingDiagnostics(
+	ctx context.Context,
+	ws WorkspaceReader,
+) map[string][]Diagnostic {
+	blocking := make(map[string][]Diagnostic)
+	for uri := range ws.GetAllFiles() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		for _, d := range ws.Validate(uri) {
+			if d.Severity == epub.SeverityError {
+				blocking[uri] = append(blocking[uri], toLSPDiagnostic(d))
+			}
+		}
+	}
+	return blocking
+}
+
+// writePackage zips the book holding the package document at opfURI into
+// output, resolved against the workspace root when relative, and returns
+// the path written. Workspace content is used over the files on disk, so
+// unsaved edits are included.
+func writePackage(ws WorkspaceReader, opfURI, output string) (string, error) {
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(ws.GetRootPath(), output)
+	}
+	root, opfPath := bookRoot(ws, opfURI)
+	fsys := workspaceFS{FS: os.DirFS(root), root: root, files: ws.GetAllFiles(), ws: ws}
+	if settings := ws.GetSettings(); settings != nil {
+		fsys.removeBOM = settings.BOM == "remove"
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return "", err
+	}
+	_, err = packager.Write(f, fsys, opfPath)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(output)
+		return "", err
+	}
+	return output, nil
+}
+
+// workspaceFS reads the files under root from the workspace when it holds
+// them, and from disk otherwise. Workspace files get back the byte order
+// mark they were read with unless removeBOM is set, which also strips the
+// mark from validated files read from disk.
+type workspaceFS struct {
+	fs.FS
+	root      string
+	files     map[string][]byte
+	ws        WorkspaceReader
+	removeBOM bool
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (w workspaceFS) ReadFile(name string) ([]byte, error) {
+	uri := pathutil.FilePathToURI(filepath.Join(w.root, filepath.FromSlash(name)))
+	if found, ok := uriutil.Lookup(w.files, uri); ok {
+		if !w.removeBOM && w.ws.HasBOM(found) {
+			return append([]byte(epub.BOM), w.files[found]...), nil
+		}
+		return w.files[found], nil
+	}
+	content, err := fs.ReadFile(w.FS, name)
+	if err == nil && w.removeBOM && epublint.IsTargetFile(name) {
+		content, _ = epub.StripBOM(content)
+	}
+	return content, err
+}
+
+// exportSarif builds a SARIF log of the stored diagnostics of every
+// workspace file.
+func exportSarif(ws WorkspaceReader) *sarif.Log {
+	diags := make(map[string][]epub.Diagnostic)
+	for uri := range ws.GetAllFiles() {
+		if d := ws.GetDiagnostics(uri); len(d) > 0 {
+			diags[uri] = d
+		}
+	}
+
+	var rootURI string
+	if root := ws.GetRootPath(); root != "" {
+		rootURI = pathutil.FilePathToURI(root)
+	}
+	return sarif.FromDiagnostics(rootURI, diags)
+}
+
+// writeSarif writes log as indented JSON to output, resolved against root
+// when relative, and returns the path written.
+func writeSarif(log *sarif.Log, output, root string) (string, error) {
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(root, output)
+	}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return output, os.WriteFile(output, append(data, '\n'), 0o644)
+}
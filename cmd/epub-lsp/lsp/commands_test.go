@@ -0,0 +1,432 @@
+package lsp
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/epublint"
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/sarif"
+	"github.com/toba/lsp/pathutil"
+)
+
+func TestHandleExecuteCommand_FindOrphans(t *testing.T) {
+	root := t.TempDir()
+	opfContent := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="cover" href="images/my%20cover.jpg" media-type="image/jpeg"/>
+  </manifest>
+</package>`)
+	for _, name := range []string{
+		"OEBPS/content.opf",
+		"OEBPS/chapter1.xhtml",
+		"OEBPS/images/my cover.jpg",
+		"OEBPS/images/old.png",
+		"OEBPS/drafts/chapter0.xhtml",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, opfContent, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws := newMockWorkspace()
+	opfURI := pathutil.FilePathToURI(filepath.Join(root, "OEBPS", "content.opf"))
+	ws.files[opfURI] = opfContent
+	ws.fileTypes[opfURI] = epub.FileTypeOPF
+	ws.rootPath = root
+	ws.settings = &ServerSettings{Ignore: []string{"drafts"}}
+
+	data := makeRequest(t, 1, MethodExecuteCommand, ExecuteCommandParams{
+		Command:   CommandFindOrphans,
+		Arguments: []json.RawMessage{json.RawMessage(`{"publish":true}`)},
+	})
+
+	response, notifications := HandleExecuteCommand(t.Context(), data, ws)
+
+	orphanURI := pathutil.FilePathToURI(filepath.Join(root, "OEBPS", "images", "old.png"))
+	orphans := unmarshalResult[[]string](t, response)
+	if !slices.Equal(orphans, []string{orphanURI}) {
+		t.Fatalf("expected orphans [%s], got %v", orphanURI, orphans)
+	}
+
+	if len(notifications) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(notifications))
+	}
+	var msg NotificationMessage[PublishDiagnosticsParams]
+	if err := json.Unmarshal(notifications[0], &msg); err != nil {
+		t.Fatal(err)
+	}
+	diags := msg.Params.Diagnostics
+	if msg.Params.Uri != orphanURI || len(diags) != 1 ||
+		diags[0].Severity != epub.SeverityInfo || diags[0].Range != (Range{}) {
+		t.Errorf("unexpected orphan diagnostics: %+v", msg.Params)
+	}
+}
+
+func TestHandleExecuteCommand_ExportSarif(t *testing.T) {
+	root := t.TempDir()
+	uri := pathutil.FilePathToURI(filepath.Join(root, "OEBPS", "chapter1.xhtml"))
+
+	ws := newMockWorkspace()
+	ws.rootPath = root
+	ws.files[uri] = []byte("<html/>")
+	ws.diagnostics[uri] = []epub.Diagnostic{{
+		Code:     "HTM_008",
+		Severity: epub.SeverityWarning,
+		Message:  "missing alt",
+		Range:    epub.Range{Start: epub.Position{Line: 2, Character: 4}},
+	}}
+
+	// Without an output path the log is returned
+	data := makeRequest(t, 1, MethodExecuteCommand, ExecuteCommandParams{
+		Command: CommandExportSarif,
+	})
+	response, _ := HandleExecuteCommand(t.Context(), data, ws)
+	log := unmarshalResult[sarif.Log](t, response)
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("expected one run with one result, got %+v", log.Runs)
+	}
+	result := log.Runs[0].Results[0]
+	loc := result.Locations[0].PhysicalLocation
+	if result.RuleID != "HTM_008" || result.Level != "warning" ||
+		loc.ArtifactLocation.URI != "OEBPS/chapter1.xhtml" ||
+		loc.Region.StartLine != 3 || loc.Region.StartColumn != 5 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// With one, the log is written there and the path returned
+	data = makeRequest(t, 2, MethodExecuteCommand, ExecuteCommandParams{
+		Command:   CommandExportSarif,
+		Arguments: []json.RawMessage{json.RawMessage(`{"output":"epub.sarif"}`)},
+	})
+	response, _ = HandleExecuteCommand(t.Context(), data, ws)
+	path := unmarshalResult[string](t, response)
+	if path != filepath.Join(root, "epub.sarif") {
+		t.Errorf("unexpected output path %q", path)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromFile sarif.Log
+	if err := json.Unmarshal(written, &fromFile); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromFile, log) {
+		t.Error("written log differs from the returned one")
+	}
+}
+
+func TestHandleExecuteCommand_Package(t *testing.T) {
+	root := t.TempDir()
+	opfContent := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="cover" href="images/cover.png" media-type="image/png"/>
+  </manifest>
+</package>`)
+	for name, content := range map[string]string{
+		"OEBPS/content.opf":       string(opfContent),
+		"OEBPS/chapter1.xhtml":    "<html>saved</html>",
+		"OEBPS/images/cover.png":  "\x89PNG",
+		"OEBPS/images/unused.png": "\x89PNG",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws := newMockWorkspace()
+	ws.rootPath = root
+	opfURI := pathutil.FilePathToURI(filepath.Join(root, "OEBPS", "content.opf"))
+	chapterURI := pathutil.FilePathToURI(filepath.Join(root, "OEBPS", "chapter1.xhtml"))
+	ws.files[opfURI] = opfContent
+	ws.fileTypes[opfURI] = epub.FileTypeOPF
+	ws.files[chapterURI] = []byte("<html>unsaved</html>")
+	ws.fileTypes[chapterURI] = epub.FileTypeXHTML
+	ws.fresh = map[string][]epub.Diagnostic{chapterURI: {
+		{Code: "HTM_049", Severity: epub.SeverityError, Message: "missing namespace"},
+		{Code: "HTM_008", Severity: epub.SeverityWarning, Message: "missing alt"},
+	}}
+
+	run := func(id int, args string) []byte {
+		data := makeRequest(t, id, MethodExecuteCommand, ExecuteCommandParams{
+			Command:   CommandPackage,
+			Arguments: []json.RawMessage{json.RawMessage(args)},
+		})
+		response, _ := HandleExecuteCommand(t.Context(), data, ws)
+		return response
+	}
+
+	// Errors block packaging
+	result := unmarshalResult[PackageResult](t, run(1, `{"output":"book.epub"}`))
+	blocking := result.Blocking[chapterURI]
+	if result.Output != "" || len(result.Blocking) != 1 || len(blocking) != 1 ||
+		blocking[0].Code != "HTM_049" {
+		t.Fatalf("expected only the chapter's error to block packaging, got %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(root, "book.epub")); err == nil {
+		t.Fatal("expected no archive to be written")
+	}
+
+	// Force packages anyway, with the workspace content of open files
+	result = unmarshalResult[PackageResult](t,
+		run(2, `{"output":"book.epub","force":true}`))
+	if result.Output != filepath.Join(root, "book.epub") || result.Blocking != nil {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	zr, err := zip.OpenReader(result.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	want := []string{
+		"mimetype",
+		"META-INF/container.xml",
+		"OEBPS/content.opf",
+		"OEBPS/chapter1.xhtml",
+		"OEBPS/images/cover.png",
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("expected entries %q, got %q", want, names)
+	}
+	f, err := zr.Open("OEBPS/chapter1.xhtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if chapter, _ := io.ReadAll(f); string(chapter) != "<html>unsaved</html>" {
+		t.Errorf("expected the workspace content of the chapter, got %q", chapter)
+	}
+}
+
+func TestWorkspaceFSByteOrderMarks(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "style.css"), []byte(epub.BOM+"p {}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ws := newMockWorkspace()
+	chapterURI := pathutil.FilePathToURI(filepath.Join(root, "chapter.xhtml"))
+	ws.files[chapterURI] = []byte("<html/>")
+	ws.boms = map[string]bool{chapterURI: true}
+
+	tests := []struct {
+		removeBOM            bool
+		wantChapter, wantCSS string
+	}{
+		{false, epub.BOM + "<html/>", epub.BOM + "p {}"},
+		{true, "<html/>", "p {}"},
+	}
+	for _, tt := range tests {
+		fsys := workspaceFS{FS: os.DirFS(root), root: root, files: ws.GetAllFiles(),
+			ws: ws, removeBOM: tt.removeBOM}
+		for name, want := range map[string]string{
+			"chapter.xhtml": tt.wantChapter,
+			"style.css":     tt.wantCSS,
+		} {
+			got, err := fsys.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("removeBOM=%v: %s = %q, want %q", tt.removeBOM, name, got, want)
+			}
+		}
+	}
+}
+
+func TestHandleExecuteCommand_PackageWithoutOutput(t *testing.T) {
+	data := makeRequest(t, 1, MethodExecuteCommand, ExecuteCommandParams{
+		Command: CommandPackage,
+	})
+	response, _ := HandleExecuteCommand(t.Context(), data, newMockWorkspace())
+
+	var resp ResponseMessage[any]
+	if err := json.Unmarshal(response, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != ErrorInvalidParams {
+		t.Errorf("expected an invalid params error, got %s", response)
+	}
+}
+
+func TestHandleExecuteCommand_Summarize(t *testing.T) {
+	ws := newMockWorkspace()
+	ws.rootPath = "/book"
+	chapter := "file:///book/OEBPS/ch1.xhtml"
+	style := "file:///book/OEBPS/style.css"
+	other := "file:///book/OEBPS/ch2.xhtml"
+	for _, uri := range []string{chapter, style, other} {
+		ws.files[uri] = []byte("content")
+	}
+	ws.diagnostics[chapter] = []epub.Diagnostic{
+		{Code: "HTM_008", Severity: epub.SeverityError, Message: "missing alt",
+			Range: epub.Range{Start: epub.Position{Line: 3}}},
+		{Code: "WS_002", Severity: epub.SeverityWarning, Message: "trailing whitespace",
+			Range: epub.Range{Start: epub.Position{Line: 1}}},
+	}
+	ws.diagnostics[other] = []epub.Diagnostic{
+		{Code: "HTM_008", Severity: epub.SeverityError, Message: "not listed"},
+	}
+	// The stylesheet has not been validated yet, so it is validated now
+	ws.fresh = map[string][]epub.Diagnostic{style: {
+		{Code: "CSS_032", Severity: epub.SeverityWarning, Message: "undeclared property"},
+	}}
+
+	summarize := func(files []string) SummarizeResult {
+		t.Helper()
+		args, err := json.Marshal(files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := makeRequest(t, 1, MethodExecuteCommand, ExecuteCommandParams{
+			Command:   CommandSummarize,
+			Arguments: []json.RawMessage{args},
+		})
+		response, _ := HandleExecuteCommand(t.Context(), data, ws)
+		return unmarshalResult[SummarizeResult](t, response)
+	}
+
+	result := summarize([]string{chapter, "OEBPS/style.css"})
+	if !result.Blocking || result.Errors != 1 || result.Warnings != 2 {
+		t.Errorf("summary = %+v, want blocking with 1 error and 2 warnings", result.Summary)
+	}
+	want := []epublint.SummaryMessage{
+		{File: "OEBPS/ch1.xhtml", Line: 4, Severity: epub.SeverityError, Code: "HTM_008", Message: "missing alt"},
+		{File: "OEBPS/ch1.xhtml", Line: 2, Severity: epub.SeverityWarning, Code: "WS_002", Message: "trailing whitespace"},
+		{File: "OEBPS/style.css", Line: 1, Severity: epub.SeverityWarning, Code: "CSS_032", Message: "undeclared property"},
+	}
+	if !slices.Equal(result.Messages, want) {
+		t.Errorf("messages = %+v, want %+v", result.Messages, want)
+	}
+	if !strings.HasPrefix(result.Text, "1 error, 2 warnings\nOEBPS/ch1.xhtml:4: error HTM_008 missing alt\n") {
+		t.Errorf("unexpected text:\n%s", result.Text)
+	}
+
+	result = summarize([]string{"OEBPS/style.css", "file:///book/OEBPS/gone.xhtml"})
+	if result.Blocking || result.Warnings != 1 || len(result.Messages) != 1 {
+		t.Errorf("summary = %+v, want one non-blocking warning", result.Summary)
+	}
+}
+
+func TestHandleExecuteCommand_ListTrackedFiles(t *testing.T) {
+	ws := newMockWorkspace()
+	ws.files["file:///book/OEBPS/content.opf"] = []byte("<package/>")
+	ws.fileTypes["file:///book/OEBPS/content.opf"] = epub.FileTypeOPF
+	ws.files["file:///book/OEBPS/chapter1.xhtml"] = []byte("<html/>")
+	ws.fileTypes["file:///book/OEBPS/chapter1.xhtml"] = epub.FileTypeXHTML
+	ws.files["file:///book/OEBPS/notes.txt"] = []byte("draft")
+
+	data := makeRequest(t, 1, MethodExecuteCommand, ExecuteCommandParams{
+		Command: CommandListTrackedFiles,
+	})
+	response, _ := HandleExecuteCommand(t.Context(), data, ws)
+
+	files := unmarshalResult[[]TrackedFile](t, response)
+	want := []TrackedFile{
+		{URI: "file:///book/OEBPS/chapter1.xhtml", FileType: epub.FileTypeXHTML.String()},
+		{URI: "file:///book/OEBPS/content.opf", FileType: epub.FileTypeOPF.String()},
+		{URI: "file:///book/OEBPS/notes.txt", FileType: epub.FileTypeUnknown.String()},
+	}
+	if !slices.Equal(files, want) {
+		t.Errorf("tracked files = %+v, want %+v", files, want)
+	}
+}
+
+func TestHandleExecuteCommand_FindDuplicateResources(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\ncover pixels")
+	ws := newMockWorkspace()
+	ws.files["file:///book/OEBPS/content.opf"] = []byte(`<package xmlns="http://www.idpf.org/2007/opf">
+<manifest>
+<item id="cover" href="images/cover.png" media-type="image/png"/>
+<item id="cover-copy" href="images/Cover%20Image.png" media-type="image/png"/>
+<item id="logo" href="images/logo.png" media-type="image/png"/>
+<item id="ch1" href="ch1.xhtml" media-type="application/xhtml+xml"/>
+<item id="ch2" href="ch2.xhtml" media-type="application/xhtml+xml"/>
+</manifest>
+</package>`)
+	ws.fileTypes["file:///book/OEBPS/content.opf"] = epub.FileTypeOPF
+	ws.files["file:///book/OEBPS/images/cover.png"] = png
+	ws.files["file:///book/OEBPS/images/Cover%20Image.png"] = png
+	ws.files["file:///book/OEBPS/images/logo.png"] = []byte("\x89PNG\r\n\x1a\nlogo")
+	ws.files["file:///book/OEBPS/ch1.xhtml"] = []byte("<html/>")
+	ws.files["file:///book/OEBPS/ch2.xhtml"] = []byte("<html/>")
+
+	run := func(args ...any) DuplicateResourcesResult {
+		t.Helper()
+		params := ExecuteCommandParams{Command: CommandFindDuplicateResources}
+		for _, arg := range args {
+			raw, err := json.Marshal(arg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params.Arguments = append(params.Arguments, raw)
+		}
+		response, _ := HandleExecuteCommand(t.Context(), makeRequest(t, 1,
+			MethodExecuteCommand, params), ws)
+		return unmarshalResult[DuplicateResourcesResult](t, response)
+	}
+
+	result := run()
+	if len(result.Groups) != 1 {
+		t.Fatalf("groups = %+v, want only the cover images", result.Groups)
+	}
+	group := result.Groups[0]
+	var ids []string
+	for _, item := range group.Items {
+		ids = append(ids, item.ID)
+	}
+	if !slices.Equal(ids, []string{"cover", "cover-copy"}) {
+		t.Errorf("ids = %v, want [cover cover-copy]", ids)
+	}
+	if group.Size != int64(len(png)) || result.WastedBytes != int64(len(png)) {
+		t.Errorf("size %d, wasted %d, want %d each", group.Size, result.WastedBytes, len(png))
+	}
+
+	result = run(FindDuplicateResourcesOptions{IncludeText: true})
+	if len(result.Groups) != 2 {
+		t.Errorf("groups with text = %+v, want the chapters too", result.Groups)
+	}
+}
+
+func TestHandleExecuteCommand_UnknownCommand(t *testing.T) {
+	data := makeRequest(t, 1, MethodExecuteCommand, ExecuteCommandParams{
+		Command: "epub-lsp.nope",
+	})
+
+	response, notifications := HandleExecuteCommand(t.Context(), data, newMockWorkspace())
+
+	var resp ResponseMessage[any]
+	if err := json.Unmarshal(response, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != ErrorInvalidParams {
+		t.Errorf("expected invalid params error, got %+v", resp.Error)
+	}
+	if len(notifications) != 0 {
+		t.Errorf("expected no notifications, got %d", len(notifications))
+	}
+}
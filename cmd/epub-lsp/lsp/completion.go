@@ -1,18 +1,28 @@
 package lsp
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"log/slog"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/langtag"
 	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+	"github.com/toba/epub-lsp/internal/epub/validator/accessibility"
+	"github.com/toba/epub-lsp/internal/epub/validator/opf"
+	"github.com/toba/epub-lsp/internal/epub/validator/xhtml"
 )
 
 // HandleCompletion processes textDocument/completion requests.
-func HandleCompletion(data []byte, ws WorkspaceReader) []byte {
+func HandleCompletion(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
 	var req RequestMessage[CompletionParams]
 	if err := json.Unmarshal(data, &req); err != nil {
-		slog.Error("error unmarshalling completion: " + err.Error())
+		Logger(ctx).Error("error unmarshalling completion: " + err.Error())
 		return marshalResponse(req.Id, CompletionList{})
 	}
 
@@ -29,29 +39,79 @@ func HandleCompletion(data []byte, ws WorkspaceReader) []byte {
 	}
 
 	fileType := ws.GetFileType(uri)
+	insert := snippetInsertion{
+		content:  content,
+		offset:   offset,
+		position: req.Params.Position,
+	}
+	if settings := ws.GetSettings(); settings != nil {
+		insert.snippets = settings.SnippetSupport
+	}
+
 	var items []CompletionItem
+	if fileType == epub.FileTypeCSS {
+		items = completionCSS(content, offset)
+	} else {
+		items = completionMarkup(fileType, ws, insert)
+	}
+
+	clamper := newRangeClamper(ctx, ws, MethodCompletion)
+	for i := range items {
+		if items[i].TextEdit != nil {
+			clamper.clamp(uri, &items[i].TextEdit.Range)
+		}
+	}
+	return marshalResponse(req.Id, CompletionList{Items: items})
+}
 
-	root, xmlDiags := parser.Parse(content)
+// completionMarkup returns the completions for the XML node at the
+// insertion offset of an OPF or XHTML document, or none when the document
+// does not parse.
+func completionMarkup(
+	fileType epub.FileType,
+	ws WorkspaceReader,
+	insert snippetInsertion,
+) []CompletionItem {
+	root, xmlDiags := parser.Parse(insert.content)
 	if len(xmlDiags) > 0 {
-		return marshalResponse(req.Id, CompletionList{})
+		return nil
 	}
 
-	result := parser.LocateAtPosition(root, content, offset)
+	result := parser.LocateAtPosition(root, insert.content, insert.offset)
 	if result == nil {
-		return marshalResponse(req.Id, CompletionList{})
+		return nil
 	}
 
 	switch fileType {
 	case epub.FileTypeOPF:
-		items = completionOPF(result, ws)
+		return completionOPF(result, ws, insert)
 	case epub.FileTypeXHTML, epub.FileTypeNav:
-		items = completionXHTML(result)
+		return completionXHTML(result, fileType, insert)
 	}
-
-	return marshalResponse(req.Id, CompletionList{Items: items})
+	return nil
 }
 
-func completionOPF(result *parser.LocateResult, ws WorkspaceReader) []CompletionItem {
+func completionOPF(
+	result *parser.LocateResult,
+	ws WorkspaceReader,
+	insert snippetInsertion,
+) []CompletionItem {
+	// Element content → suggest language tags in <dc:language>, values in
+	// <meta property="schema:...">, otherwise whole-element snippets for the
+	// parent
+	if result.InText {
+		node := result.Node
+		switch {
+		case node.Space == epub.NSDC && node.Local == "language":
+			start, end, _ := parser.ElementSpan(insert.content, int(node.Offset))
+			return languageTagCompletions(insert.content, start, end)
+		case node.Local == "meta" && node.Attr("property") != "":
+			start, end, _ := parser.ElementSpan(insert.content, int(node.Offset))
+			return metaValueCompletions(node.Attr("property"), insert, start, end)
+		}
+		return insert.items(opfSnippets[node.Local])
+	}
+
 	if result.Attr == nil || !result.InValue {
 		return nil
 	}
@@ -59,9 +119,14 @@ func completionOPF(result *parser.LocateResult, ws WorkspaceReader) []Completion
 	node := result.Node
 	attr := result.Attr
 
-	// <meta property="..."> → suggest schema: property names
+	// xml:lang="..." → suggest language tags
+	if attr.Local == "lang" && attr.Space == epub.NSXML {
+		return langAttrCompletions(node, "xml:lang", insert)
+	}
+
+	// <meta property="..."> → suggest schema: property names and prefixes
 	if node.Local == "meta" && attr.Local == "property" {
-		return schemaPropertyCompletions()
+		return append(schemaPropertyCompletions(), prefixCompletions(ws)...)
 	}
 
 	// <itemref idref="..."> → suggest manifest item IDs
@@ -69,29 +134,142 @@ func completionOPF(result *parser.LocateResult, ws WorkspaceReader) []Completion
 		return manifestIDCompletions(ws)
 	}
 
+	// <itemref properties="..."> → suggest properties for the token under
+	// the cursor
+	if node.Local == "itemref" && attr.Local == "properties" {
+		return itemrefPropertyCompletions(node, insert)
+	}
+
 	// <item media-type="..."> → suggest media types
 	if node.Local == "item" && attr.Local == "media-type" {
 		return mediaTypeCompletions()
 	}
 
+	// <link rel="..."> in metadata → suggest rel values for the token under
+	// the cursor
+	if node.Local == "link" && attr.Local == "rel" &&
+		node.Parent != nil && node.Parent.Local == "metadata" {
+		return linkRelCompletions(node, insert)
+	}
+
 	return nil
 }
 
-func completionXHTML(result *parser.LocateResult) []CompletionItem {
+func completionXHTML(
+	result *parser.LocateResult,
+	fileType epub.FileType,
+	insert snippetInsertion,
+) []CompletionItem {
+	// Element content → suggest whole-element snippets for the parent
+	if result.InText {
+		var snippets []elementSnippet
+		if fileType == epub.FileTypeNav {
+			snippets = navSnippets[result.Node.Local]
+		}
+		snippets = append(snippets, xhtmlSnippets[result.Node.Local]...)
+		return insert.items(snippets)
+	}
+
 	if result.Attr == nil || !result.InValue {
 		return nil
 	}
 
 	attr := result.Attr
 
-	// epub:type="..." → suggest valid epub:type values
+	// epub:type="..." → suggest valid epub:type values for the host element
 	if attr.Local == "type" && attr.Space == epub.NSEpub {
-		return epubTypeCompletions()
+		present := withoutTokenAt(attr.Value, result.ValueOffset)
+		return epubTypeCompletions(result.Node.Local, present)
+	}
+
+	// role="..." → suggest ARIA roles, led by those matching epub:type
+	if attr.Local == "role" && attr.Space == "" {
+		present := withoutTokenAt(attr.Value, result.ValueOffset)
+		return roleCompletions(result.Node.AttrNS(epub.NSEpub, "type"), present)
+	}
+
+	// dir="..." → suggest the text directions
+	if attr.Local == "dir" && attr.Space == "" {
+		return dirCompletions()
+	}
+
+	// lang="..." or xml:lang="..." → suggest language tags
+	if attr.Local == "lang" && attr.Space == "" {
+		return langAttrCompletions(result.Node, "lang", insert)
+	}
+	if attr.Local == "lang" && attr.Space == epub.NSXML {
+		return langAttrCompletions(result.Node, "xml:lang", insert)
 	}
 
 	return nil
 }
 
+// dirCompletions returns the values of the dir attribute.
+func dirCompletions() []CompletionItem {
+	items := make([]CompletionItem, 0, len(xhtml.DirValues))
+	for _, d := range xhtml.DirValues {
+		items = append(items, CompletionItem{
+			Label:  d.Name,
+			Kind:   CompletionKindEnum,
+			Detail: d.Detail,
+		})
+	}
+	return items
+}
+
+// langAttrCompletions suggests language tags replacing the value of the
+// named attribute of node.
+func langAttrCompletions(
+	node *parser.XMLNode,
+	name string,
+	insert snippetInsertion,
+) []CompletionItem {
+	start, end, ok := parser.AttrValueRange(insert.content, node, name)
+	if !ok || insert.offset < start || insert.offset > end {
+		return nil
+	}
+	return languageTagCompletions(insert.content, start, end)
+}
+
+// languageTagCompletions suggests common BCP 47 language tags, with the
+// language name as detail, replacing the text from start to end without
+// its surrounding whitespace.
+func languageTagCompletions(content []byte, start, end int) []CompletionItem {
+	rng := trimmedRange(content, start, end)
+
+	items := make([]CompletionItem, len(langtag.Common))
+	for i, l := range langtag.Common {
+		items[i] = CompletionItem{
+			Label:    l.Tag,
+			Kind:     CompletionKindValue,
+			Detail:   l.Name,
+			SortText: fmt.Sprintf("%03d", i),
+			TextEdit: &TextEdit{Range: rng, NewText: l.Tag},
+		}
+	}
+	return items
+}
+
+// trimmedRange returns the range of the text from start to end without its
+// surrounding whitespace.
+func trimmedRange(content []byte, start, end int) Range {
+	text := content[start:end]
+	start += len(text) - len(bytes.TrimLeft(text, " \t\r\n"))
+	end -= len(text) - len(bytes.TrimRight(text, " \t\r\n"))
+	end = max(start, end)
+	return Range{
+		Start: lspPos(epub.ByteOffsetToPosition(content, start)),
+		End:   lspPos(epub.ByteOffsetToPosition(content, end)),
+	}
+}
+
+// withoutTokenAt removes the whitespace-separated token containing offset
+// from value, leaving the tokens the user has already completed.
+func withoutTokenAt(value string, offset int) string {
+	start, end := epub.TokenAt(value, offset)
+	return value[:start] + " " + value[end:]
+}
+
 func schemaPropertyCompletions() []CompletionItem {
 	props := []struct {
 		name, detail string
@@ -117,6 +295,120 @@ func schemaPropertyCompletions() []CompletionItem {
 	return items
 }
 
+// accessModeCombinations are the schema:accessModeSufficient sets offered
+// for completion, those publications most often declare.
+var accessModeCombinations = []string{
+	"textual,visual", "auditory,textual", "auditory,visual", "auditory,textual,visual",
+}
+
+// metaValueCompletions suggests values for a meta element of the given
+// schema accessibility property whose content runs from start to end, from
+// the lists its validator checks against. Other properties get none.
+func metaValueCompletions(
+	property string,
+	insert snippetInsertion,
+	start, end int,
+) []CompletionItem {
+	var values []string
+	switch property {
+	case "schema:accessMode":
+		values = accessibility.AccessModes
+	case "schema:accessibilityFeature":
+		values = accessibility.AccessibilityFeatures
+	case "schema:accessibilityHazard":
+		values = accessibility.AccessibilityHazards
+	case "schema:accessModeSufficient":
+		return accessModeSufficientCompletions(insert, start, end)
+	default:
+		return nil
+	}
+
+	rng := trimmedRange(insert.content, start, end)
+	items := make([]CompletionItem, len(values))
+	for i, v := range values {
+		items[i] = CompletionItem{
+			Label:    v,
+			Kind:     CompletionKindEnum,
+			Detail:   property,
+			TextEdit: &TextEdit{Range: rng, NewText: v},
+		}
+	}
+	return items
+}
+
+// accessModeSufficientCompletions suggests common combinations of access
+// modes, replacing the whole value, followed by the individual modes,
+// replacing the comma-separated entry under the cursor. Modes already in
+// other entries are not suggested again.
+func accessModeSufficientCompletions(
+	insert snippetInsertion,
+	start, end int,
+) []CompletionItem {
+	value := string(insert.content[start:end])
+	cursor := min(max(insert.offset-start, 0), len(value))
+	entryStart := strings.LastIndexByte(value[:cursor], ',') + 1
+	entryEnd := len(value)
+	if i := strings.IndexByte(value[cursor:], ','); i >= 0 {
+		entryEnd = cursor + i
+	}
+
+	used := make(map[string]bool)
+	offset := 0
+	for entry := range strings.SplitSeq(value, ",") {
+		if offset != entryStart {
+			used[strings.TrimSpace(entry)] = true
+		}
+		offset += len(entry) + 1
+	}
+
+	whole := trimmedRange(insert.content, start, end)
+	items := make([]CompletionItem, 0,
+		len(accessModeCombinations)+len(accessibility.AccessModes))
+	for i, c := range accessModeCombinations {
+		items = append(items, CompletionItem{
+			Label:    c,
+			Kind:     CompletionKindValue,
+			Detail:   "Access modes sufficient together",
+			SortText: fmt.Sprintf("0%02d", i),
+			TextEdit: &TextEdit{Range: whole, NewText: c},
+		})
+	}
+
+	entry := trimmedRange(insert.content, start+entryStart, start+entryEnd)
+	for i, m := range accessibility.AccessModes {
+		if used[m] {
+			continue
+		}
+		items = append(items, CompletionItem{
+			Label:    m,
+			Kind:     CompletionKindEnum,
+			Detail:   "schema:accessModeSufficient",
+			SortText: fmt.Sprintf("1%02d", i),
+			TextEdit: &TextEdit{Range: entry, NewText: m},
+		})
+	}
+	return items
+}
+
+// prefixCompletions suggests the reserved prefixes and those declared in the
+// package prefix attribute.
+func prefixCompletions(ws WorkspaceReader) []CompletionItem {
+	prefixes := maps.Clone(opf.ReservedPrefixes)
+	if manifest := ws.GetManifest(); manifest != nil {
+		maps.Copy(prefixes, manifest.Prefixes)
+	}
+
+	items := make([]CompletionItem, 0, len(prefixes))
+	for _, name := range slices.Sorted(maps.Keys(prefixes)) {
+		items = append(items, CompletionItem{
+			Label:  name + ":",
+			Kind:   CompletionKindProperty,
+			Detail: prefixes[name],
+		})
+	}
+	return items
+}
+
 func manifestIDCompletions(ws WorkspaceReader) []CompletionItem {
 	manifest := ws.GetManifest()
 	if manifest == nil {
@@ -134,30 +426,83 @@ func manifestIDCompletions(ws WorkspaceReader) []CompletionItem {
 	return items
 }
 
+// itemrefPropertyCompletions suggests spine itemref properties that
+// replace the token under the cursor. Properties already present in the
+// value are not suggested again.
+func itemrefPropertyCompletions(node *parser.XMLNode, insert snippetInsertion) []CompletionItem {
+	start, end, ok := parser.AttrValueRange(insert.content, node, "properties")
+	if !ok || insert.offset < start || insert.offset > end {
+		return nil
+	}
+	value := string(insert.content[start:end])
+	tokenStart, tokenEnd := epub.TokenAt(value, insert.offset-start)
+	rng := Range{
+		Start: lspPos(epub.ByteOffsetToPosition(insert.content, start+tokenStart)),
+		End:   lspPos(epub.ByteOffsetToPosition(insert.content, start+tokenEnd)),
+	}
+
+	used := make(map[string]bool)
+	for _, token := range epub.Tokens(value) {
+		if token.Start != tokenStart {
+			used[token.Value] = true
+		}
+	}
+
+	items := make([]CompletionItem, 0, len(opf.ItemrefProperties))
+	for _, p := range opf.ItemrefProperties {
+		if used[p.Name] {
+			continue
+		}
+		items = append(items, CompletionItem{
+			Label:    p.Name,
+			Kind:     CompletionKindEnum,
+			Detail:   p.Detail,
+			TextEdit: &TextEdit{Range: rng, NewText: p.Name},
+		})
+	}
+	return items
+}
+
+// linkRelCompletions suggests metadata link rel values that replace the
+// token under the cursor. Values already present in the value are not
+// suggested again.
+func linkRelCompletions(node *parser.XMLNode, insert snippetInsertion) []CompletionItem {
+	start, end, ok := parser.AttrValueRange(insert.content, node, "rel")
+	if !ok || insert.offset < start || insert.offset > end {
+		return nil
+	}
+	value := string(insert.content[start:end])
+	tokenStart, tokenEnd := epub.TokenAt(value, insert.offset-start)
+	rng := Range{
+		Start: lspPos(epub.ByteOffsetToPosition(insert.content, start+tokenStart)),
+		End:   lspPos(epub.ByteOffsetToPosition(insert.content, start+tokenEnd)),
+	}
+
+	used := make(map[string]bool)
+	for _, token := range epub.Tokens(value) {
+		if token.Start != tokenStart {
+			used[token.Value] = true
+		}
+	}
+
+	items := make([]CompletionItem, 0, len(opf.LinkRels))
+	for _, r := range opf.LinkRels {
+		if used[r.Name] {
+			continue
+		}
+		items = append(items, CompletionItem{
+			Label:    r.Name,
+			Kind:     CompletionKindEnum,
+			Detail:   r.Detail,
+			TextEdit: &TextEdit{Range: rng, NewText: r.Name},
+		})
+	}
+	return items
+}
+
 func mediaTypeCompletions() []CompletionItem {
-	types := []string{
-		"application/xhtml+xml",
-		"application/x-dtbncx+xml",
-		"text/css",
-		"image/jpeg",
-		"image/png",
-		"image/gif",
-		"image/svg+xml",
-		"image/webp",
-		"application/javascript",
-		"audio/mpeg",
-		"audio/mp4",
-		"video/mp4",
-		"application/font-woff",
-		"application/font-sfnt",
-		"font/otf",
-		"font/ttf",
-		"font/woff",
-		"font/woff2",
-	}
-
-	items := make([]CompletionItem, len(types))
-	for i, t := range types {
+	items := make([]CompletionItem, len(validator.CoreMediaTypes))
+	for i, t := range validator.CoreMediaTypes {
 		items[i] = CompletionItem{
 			Label: t,
 			Kind:  CompletionKindEnum,
@@ -166,43 +511,198 @@ func mediaTypeCompletions() []CompletionItem {
 	return items
 }
 
-func epubTypeCompletions() []CompletionItem {
-	types := []struct {
-		name, detail string
-	}{
-		{"toc", "Table of Contents"},
-		{"landmarks", "Landmarks navigation"},
-		{"page-list", "Page list navigation"},
-		{"cover", "Cover image"},
-		{"titlepage", "Title page"},
-		{"frontmatter", "Front matter"},
-		{"bodymatter", "Body matter"},
-		{"backmatter", "Back matter"},
-		{"chapter", "Chapter"},
-		{"part", "Part"},
-		{"footnote", "Footnote"},
-		{"endnote", "Endnote"},
-		{"noteref", "Note reference"},
-		{"bibliography", "Bibliography"},
-		{"glossary", "Glossary"},
-		{"index", "Index"},
-		{"preface", "Preface"},
-		{"foreword", "Foreword"},
-		{"appendix", "Appendix"},
-		{"dedication", "Dedication"},
-		{"epigraph", "Epigraph"},
-		{"abstract", "Abstract"},
-		{"colophon", "Colophon"},
-		{"pagebreak", "Page break marker"},
-	}
-
-	items := make([]CompletionItem, len(types))
-	for i, t := range types {
-		items[i] = CompletionItem{
-			Label:  t.name,
-			Kind:   CompletionKindEnum,
-			Detail: t.detail,
+// epubTypeVocabulary lists the epub:type values offered for completion.
+var epubTypeVocabulary = []struct {
+	name, detail string
+}{
+	{"toc", "Table of Contents"},
+	{"landmarks", "Landmarks navigation"},
+	{"page-list", "Page list navigation"},
+	{"loi", "List of illustrations"},
+	{"lot", "List of tables"},
+	{"cover", "Cover image"},
+	{"titlepage", "Title page"},
+	{"frontmatter", "Front matter"},
+	{"bodymatter", "Body matter"},
+	{"backmatter", "Back matter"},
+	{"chapter", "Chapter"},
+	{"part", "Part"},
+	{"footnote", "Footnote"},
+	{"endnote", "Endnote"},
+	{"endnotes", "Endnotes section"},
+	{"noteref", "Note reference"},
+	{"biblioref", "Bibliography reference"},
+	{"glossref", "Glossary reference"},
+	{"backlink", "Link back to the referencing location"},
+	{"bibliography", "Bibliography"},
+	{"glossary", "Glossary"},
+	{"glossterm", "Glossary term"},
+	{"index", "Index"},
+	{"preface", "Preface"},
+	{"foreword", "Foreword"},
+	{"appendix", "Appendix"},
+	{"dedication", "Dedication"},
+	{"epigraph", "Epigraph"},
+	{"abstract", "Abstract"},
+	{"colophon", "Colophon"},
+	{"pagebreak", "Page break marker"},
+}
+
+// structuralEpubTypes are the epub:type values that describe sections.
+var structuralEpubTypes = []string{
+	"chapter", "part", "footnote", "endnote", "endnotes", "preface", "foreword",
+	"appendix", "dedication", "epigraph", "abstract", "colophon", "bibliography",
+	"glossary", "index", "titlepage", "cover", "frontmatter", "bodymatter",
+	"backmatter",
+}
+
+// epubTypesByHost lists the epub:type values suggested first on each element.
+var epubTypesByHost = map[string][]string{
+	"nav":     {"toc", "landmarks", "page-list", "loi", "lot"},
+	"section": structuralEpubTypes,
+	"aside":   structuralEpubTypes,
+	"a":       {"noteref", "biblioref", "glossref", "backlink"},
+	"span":    {"pagebreak", "glossterm", "noteref"},
+	"hr":      {"pagebreak"},
+}
+
+// epubTypeCompletions returns epub:type values for an element. Values suited
+// to the host element sort first; the rest of the vocabulary follows. Tokens
+// already present in the attribute value are not suggested again.
+func epubTypeCompletions(host, present string) []CompletionItem {
+	used := make(map[string]bool)
+	for token := range strings.FieldsSeq(present) {
+		used[token] = true
+	}
+	preferred := make(map[string]bool)
+	for _, name := range epubTypesByHost[host] {
+		preferred[name] = true
+	}
+
+	items := make([]CompletionItem, 0, len(epubTypeVocabulary))
+	for i, t := range epubTypeVocabulary {
+		if used[t.name] {
+			continue
+		}
+		tier := 1
+		if preferred[t.name] {
+			tier = 0
+		}
+		items = append(items, CompletionItem{
+			Label:    t.name,
+			Kind:     CompletionKindEnum,
+			Detail:   t.detail,
+			SortText: fmt.Sprintf("%d%02d", tier, i),
+		})
+	}
+	slices.SortStableFunc(items, func(a, b CompletionItem) int {
+		return strings.Compare(a.SortText, b.SortText)
+	})
+	return items
+}
+
+// roleVocabulary lists the ARIA roles offered for completion: the DPUB-ARIA
+// roles followed by the WAI-ARIA roles common in publications.
+var roleVocabulary = []struct {
+	name, detail string
+}{
+	{"doc-abstract", "Summary of the work"},
+	{"doc-acknowledgments", "Acknowledgments section"},
+	{"doc-afterword", "Closing statement from the author"},
+	{"doc-appendix", "Supplementary material"},
+	{"doc-backlink", "Link back to the referencing location"},
+	{"doc-biblioentry", "Bibliography entry"},
+	{"doc-bibliography", "Bibliography"},
+	{"doc-biblioref", "Bibliography reference"},
+	{"doc-chapter", "Chapter"},
+	{"doc-colophon", "Publishing details"},
+	{"doc-conclusion", "Concluding section"},
+	{"doc-cover", "Cover image"},
+	{"doc-credit", "Acknowledgment of a source"},
+	{"doc-credits", "Credits section"},
+	{"doc-dedication", "Dedication"},
+	{"doc-endnote", "Endnote"},
+	{"doc-endnotes", "Endnotes section"},
+	{"doc-epigraph", "Epigraph"},
+	{"doc-epilogue", "Epilogue"},
+	{"doc-errata", "Errata"},
+	{"doc-example", "Illustrative example"},
+	{"doc-footnote", "Footnote"},
+	{"doc-foreword", "Foreword"},
+	{"doc-glossary", "Glossary"},
+	{"doc-glossref", "Glossary reference"},
+	{"doc-index", "Index"},
+	{"doc-introduction", "Introduction"},
+	{"doc-noteref", "Note reference"},
+	{"doc-notice", "Notice or caution"},
+	{"doc-pagebreak", "Page break marker"},
+	{"doc-pagefooter", "Running page footer"},
+	{"doc-pageheader", "Running page header"},
+	{"doc-pagelist", "Page list navigation"},
+	{"doc-part", "Part"},
+	{"doc-preface", "Preface"},
+	{"doc-prologue", "Prologue"},
+	{"doc-pullquote", "Pull quote"},
+	{"doc-qna", "Questions and answers"},
+	{"doc-subtitle", "Subtitle"},
+	{"doc-tip", "Helpful tip"},
+	{"doc-toc", "Table of Contents"},
+	{"banner", "Site-oriented header"},
+	{"complementary", "Supporting section"},
+	{"contentinfo", "Information about the document"},
+	{"definition", "Definition of a term"},
+	{"directory", "List of references"},
+	{"figure", "Figure"},
+	{"group", "Group of related elements"},
+	{"img", "Image made of several elements"},
+	{"list", "List"},
+	{"listitem", "List item"},
+	{"main", "Main content"},
+	{"navigation", "Navigation links"},
+	{"none", "Remove implicit semantics"},
+	{"note", "Parenthetic content"},
+	{"presentation", "Remove implicit semantics"},
+	{"region", "Significant section"},
+	{"term", "Term being defined"},
+}
+
+// roleCompletions returns ARIA roles for an element with the given epub:type
+// value. Roles matching its epub:type tokens sort first and the first is
+// preselected; the rest of the vocabulary follows. Roles already present in
+// the attribute value are not suggested again.
+func roleCompletions(epubType, present string) []CompletionItem {
+	used := make(map[string]bool)
+	for token := range strings.FieldsSeq(present) {
+		used[token] = true
+	}
+	preferred := make(map[string]int)
+	for token := range strings.FieldsSeq(epubType) {
+		if role, ok := accessibility.RoleForEpubType(token); ok {
+			if _, seen := preferred[role]; !seen {
+				preferred[role] = len(preferred)
+			}
+		}
+	}
+
+	items := make([]CompletionItem, 0, len(roleVocabulary))
+	for i, r := range roleVocabulary {
+		if used[r.name] {
+			continue
+		}
+		item := CompletionItem{
+			Label:    r.name,
+			Kind:     CompletionKindEnum,
+			Detail:   r.detail,
+			SortText: fmt.Sprintf("1%02d", i),
+		}
+		if rank, ok := preferred[r.name]; ok {
+			item.SortText = fmt.Sprintf("0%02d", rank)
+			item.Preselect = rank == 0
 		}
+		items = append(items, item)
 	}
+	slices.SortStableFunc(items, func(a, b CompletionItem) int {
+		return strings.Compare(a.SortText, b.SortText)
+	})
 	return items
 }
@@ -0,0 +1,135 @@
+package lsp
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// elementSnippet is a whole-element completion. Body uses LSP snippet
+// syntax with "\n" line breaks and "\t" for one level of indentation.
+type elementSnippet struct {
+	label, detail, body string
+}
+
+var accessibilityMetadataSnippet = elementSnippet{
+	label:  "Accessibility metadata block",
+	detail: "schema.org accessibility metadata",
+	body: `<meta property="schema:accessMode">${1:textual}</meta>
+<meta property="schema:accessModeSufficient">${2:textual}</meta>
+<meta property="schema:accessibilityFeature">${3:structuralNavigation}</meta>
+<meta property="schema:accessibilityHazard">${4:none}</meta>
+<meta property="schema:accessibilitySummary">${5:summary}</meta>$0`,
+}
+
+var tocNavSnippet = elementSnippet{
+	label:  "toc nav skeleton",
+	detail: "<nav epub:type=\"toc\"> with a heading and list",
+	body: `<nav epub:type="toc" role="doc-toc" id="${1:toc}">
+	<h1>${2:Contents}</h1>
+	<ol>
+		<li><a href="${3:chapter1.xhtml}">${4:Chapter 1}</a></li>
+	</ol>
+</nav>$0`,
+}
+
+var figureSnippet = elementSnippet{
+	label:  "figure with figcaption",
+	detail: "<figure> with an image and caption",
+	body: `<figure>
+	<img src="${1:image.jpg}" alt="${2:description}"/>
+	<figcaption>${3:caption}</figcaption>
+</figure>$0`,
+}
+
+var pagebreakSnippet = elementSnippet{
+	label:  "pagebreak span",
+	detail: "print page marker",
+	body: `<span epub:type="pagebreak" role="doc-pagebreak" id="page${1:1}" ` +
+		`aria-label="${1:1}"/>$0`,
+}
+
+// opfSnippets maps a parent element in a package document to the snippets
+// offered in its content.
+var opfSnippets = map[string][]elementSnippet{
+	"metadata": {accessibilityMetadataSnippet},
+}
+
+// navSnippets maps a parent element in a navigation document to the
+// snippets offered in its content, ahead of the XHTML snippets.
+var navSnippets = map[string][]elementSnippet{
+	"body": {tocNavSnippet},
+}
+
+// xhtmlSnippets maps a parent element in a content document to the
+// snippets offered in its content.
+var xhtmlSnippets = map[string][]elementSnippet{
+	"body":    {figureSnippet, pagebreakSnippet},
+	"section": {figureSnippet, pagebreakSnippet},
+	"article": {figureSnippet, pagebreakSnippet},
+	"aside":   {figureSnippet, pagebreakSnippet},
+	"div":     {figureSnippet, pagebreakSnippet},
+	"p":       {pagebreakSnippet},
+}
+
+// snippetInsertion describes where element snippets are inserted and
+// whether the client can expand tab stops.
+type snippetInsertion struct {
+	content  []byte
+	offset   int
+	position Position
+	snippets bool
+}
+
+// items builds completion items for snippets, indenting continuation lines
+// to match the insertion line. Without client snippet support the tab
+// stops are replaced by their placeholder text.
+func (s snippetInsertion) items(snippets []elementSnippet) []CompletionItem {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	indent := detectIndent(s.content, s.offset)
+	unit := "\t"
+	if strings.HasPrefix(indent, " ") {
+		unit = "  "
+	}
+	newline := epub.DetectLineEnding(s.content)
+
+	items := make([]CompletionItem, len(snippets))
+	for i, snippet := range snippets {
+		text := strings.ReplaceAll(snippet.body, "\t", unit)
+		text = strings.ReplaceAll(text, "\n", newline+indent)
+
+		format := InsertTextFormatSnippet
+		if !s.snippets {
+			text = snippetToPlainText(text)
+			format = InsertTextFormatPlainText
+		}
+
+		items[i] = CompletionItem{
+			Label:            snippet.label,
+			Kind:             CompletionKindSnippet,
+			Detail:           snippet.detail,
+			InsertTextFormat: format,
+			TextEdit: &TextEdit{
+				Range:   Range{Start: s.position, End: s.position},
+				NewText: text,
+			},
+		}
+	}
+	return items
+}
+
+var (
+	snippetPlaceholder = regexp.MustCompile(`\$\{\d+:([^}]*)\}`)
+	snippetTabStop     = regexp.MustCompile(`\$\d+`)
+)
+
+// snippetToPlainText replaces snippet placeholders with their default text
+// and drops bare tab stops.
+func snippetToPlainText(snippet string) string {
+	text := snippetPlaceholder.ReplaceAllString(snippet, "$1")
+	return snippetTabStop.ReplaceAllString(text, "")
+}
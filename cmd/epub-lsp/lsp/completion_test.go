@@ -1,10 +1,14 @@
 package lsp
 
 import (
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/validator"
+	"github.com/toba/epub-lsp/internal/epub/validator/accessibility"
+	"github.com/toba/epub-lsp/internal/epub/validator/opf"
 )
 
 func TestHandleCompletion_MetaProperty(t *testing.T) {
@@ -28,7 +32,7 @@ func TestHandleCompletion_MetaProperty(t *testing.T) {
 		Position:     lspPosition,
 	})
 
-	resp := HandleCompletion(data, ws)
+	resp := HandleCompletion(t.Context(), data, ws)
 	result := unmarshalResult[CompletionList](t, resp)
 
 	if len(result.Items) < 5 {
@@ -39,6 +43,42 @@ func TestHandleCompletion_MetaProperty(t *testing.T) {
 	}
 }
 
+func TestHandleCompletion_MetaPropertyPrefixes(t *testing.T) {
+	ws := newMockWorkspace()
+	opfContent := []byte(`<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata>
+    <meta property=""></meta>
+  </metadata>
+</package>`)
+	ws.files["file:///book/content.opf"] = opfContent
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+	ws.manifest = &validator.ManifestInfo{
+		Prefixes: map[string]string{"ibooks": "http://example.com/ibooks/"},
+	}
+
+	offset := findSubstring(opfContent, `property=""`)
+	data := makeRequest(t, 1, MethodCompletion, CompletionParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+		Position:     lspPos(epub.ByteOffsetToPosition(opfContent, offset+10)),
+	})
+
+	result := unmarshalResult[CompletionList](t, HandleCompletion(t.Context(), data, ws))
+
+	details := make(map[string]string)
+	for _, item := range result.Items {
+		details[item.Label] = item.Detail
+	}
+	if details["ibooks:"] != "http://example.com/ibooks/" {
+		t.Errorf("expected declared ibooks: prefix, got %q", details["ibooks:"])
+	}
+	if _, ok := details["rendition:"]; !ok {
+		t.Error("expected reserved rendition: prefix")
+	}
+	if _, ok := details["se:"]; ok {
+		t.Error("undeclared se: prefix should not be suggested")
+	}
+}
+
 func TestHandleCompletion_ItemrefIdref(t *testing.T) {
 	ws := newMockWorkspace()
 	opfContent := []byte(`<?xml version="1.0"?>
@@ -70,7 +110,7 @@ func TestHandleCompletion_ItemrefIdref(t *testing.T) {
 		Position:     lspPosition,
 	})
 
-	resp := HandleCompletion(data, ws)
+	resp := HandleCompletion(t.Context(), data, ws)
 	result := unmarshalResult[CompletionList](t, resp)
 
 	if len(result.Items) != 2 {
@@ -99,7 +139,7 @@ func TestHandleCompletion_MediaType(t *testing.T) {
 		Position:     lspPosition,
 	})
 
-	resp := HandleCompletion(data, ws)
+	resp := HandleCompletion(t.Context(), data, ws)
 	result := unmarshalResult[CompletionList](t, resp)
 
 	if len(result.Items) < 5 {
@@ -114,10 +154,539 @@ func TestHandleCompletion_NoContent(t *testing.T) {
 		Position:     Position{Line: 0, Character: 0},
 	})
 
-	resp := HandleCompletion(data, ws)
+	resp := HandleCompletion(t.Context(), data, ws)
 	result := unmarshalResult[CompletionList](t, resp)
 
 	if len(result.Items) != 0 {
 		t.Fatalf("expected 0 completions, got %d", len(result.Items))
 	}
 }
+
+// epubTypeCompletionAt requests completions with the cursor placed after
+// marker in an XHTML document.
+func epubTypeCompletionAt(t *testing.T, body, marker string) []CompletionItem {
+	t.Helper()
+	ws := newMockWorkspace()
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml" ` +
+		`xmlns:epub="http://www.idpf.org/2007/ops"><body>` + body + `</body></html>`)
+	ws.files["file:///book/chapter.xhtml"] = content
+	ws.fileTypes["file:///book/chapter.xhtml"] = epub.FileTypeXHTML
+
+	offset := findSubstring(content, marker) + len(marker)
+	data := makeRequest(t, 1, MethodCompletion, CompletionParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/chapter.xhtml"},
+		Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+	})
+
+	return unmarshalResult[CompletionList](t,
+		HandleCompletion(t.Context(), data, ws)).Items
+}
+
+func completionLabels(items []CompletionItem) []string {
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.Label
+	}
+	return labels
+}
+
+func TestHandleCompletion_EpubTypeByHost(t *testing.T) {
+	tests := []struct {
+		host, body string
+		top        []string
+	}{
+		{
+			"nav", `<nav epub:type=""></nav>`,
+			[]string{"toc", "landmarks", "page-list", "loi", "lot"},
+		},
+		{"section", `<section epub:type=""></section>`, []string{"cover", "titlepage"}},
+		{"aside", `<aside epub:type=""></aside>`, []string{"cover", "titlepage"}},
+		{
+			"a", `<a href="#n1" epub:type="">1</a>`,
+			[]string{"noteref", "biblioref", "glossref", "backlink"},
+		},
+		{"span", `<span epub:type=""/>`, []string{"noteref", "glossterm", "pagebreak"}},
+		{"hr", `<hr epub:type=""/>`, []string{"pagebreak"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			items := epubTypeCompletionAt(t, tt.body, `epub:type="`)
+			labels := completionLabels(items)
+			if len(labels) != len(epubTypeVocabulary) {
+				t.Fatalf("expected full vocabulary, got %d items", len(labels))
+			}
+			for i, want := range tt.top {
+				if labels[i] != want {
+					t.Errorf("item %d = %q, want %q (got %v)", i, labels[i], want, labels)
+				}
+				if items[i].SortText >= items[len(items)-1].SortText {
+					t.Errorf("%q does not sort ahead of the remaining vocabulary", want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_EpubTypeSkipsPresentTokens(t *testing.T) {
+	items := epubTypeCompletionAt(t,
+		`<section epub:type="chapter bodymatter "></section>`, `bodymatter `)
+
+	for _, label := range completionLabels(items) {
+		if label == "chapter" || label == "bodymatter" {
+			t.Errorf("unexpected duplicate suggestion %q", label)
+		}
+	}
+	if len(items) != len(epubTypeVocabulary)-2 {
+		t.Errorf("expected %d items, got %d", len(epubTypeVocabulary)-2, len(items))
+	}
+}
+
+func TestHandleCompletion_EpubTypeKeepsTokenUnderCursor(t *testing.T) {
+	items := epubTypeCompletionAt(t, `<nav epub:type="toc"></nav>`, `epub:type="to`)
+
+	labels := completionLabels(items)
+	if len(labels) == 0 || labels[0] != "toc" {
+		t.Errorf("expected toc first while it is being typed, got %v", labels)
+	}
+}
+
+func TestHandleCompletion_RoleMatchesEpubType(t *testing.T) {
+	items := epubTypeCompletionAt(t,
+		`<section epub:type="chapter" role=""></section>`, `role="`)
+
+	if len(items) != len(roleVocabulary) {
+		t.Fatalf("expected full role vocabulary, got %d items", len(items))
+	}
+	if items[0].Label != "doc-chapter" || !items[0].Preselect {
+		t.Errorf("expected preselected doc-chapter first, got %+v", items[0])
+	}
+	for _, item := range items[1:] {
+		if item.Preselect {
+			t.Errorf("unexpected preselected %q", item.Label)
+		}
+	}
+}
+
+func TestHandleCompletion_RoleWithoutEpubType(t *testing.T) {
+	items := epubTypeCompletionAt(t, `<div role="doc-note"></div>`, `role="doc-`)
+
+	labels := completionLabels(items)
+	if len(labels) != len(roleVocabulary) {
+		t.Fatalf("expected full role vocabulary, got %d items", len(labels))
+	}
+	for i, r := range roleVocabulary {
+		if labels[i] != r.name {
+			t.Fatalf("item %d = %q, want vocabulary order %q", i, labels[i], r.name)
+		}
+	}
+	if items[0].Preselect {
+		t.Errorf("expected nothing preselected without epub:type")
+	}
+}
+
+func TestHandleCompletion_Dir(t *testing.T) {
+	items := epubTypeCompletionAt(t, `<p dir="">text</p>`, `dir="`)
+
+	labels := completionLabels(items)
+	if !slices.Equal(labels, []string{"ltr", "rtl", "auto"}) {
+		t.Fatalf("expected ltr, rtl, auto, got %v", labels)
+	}
+	for _, item := range items {
+		if item.Kind != CompletionKindEnum || item.Detail == "" {
+			t.Errorf("unexpected item %+v", item)
+		}
+	}
+}
+
+func TestRoleVocabularyCoversEpubTypeRoles(t *testing.T) {
+	known := make(map[string]bool)
+	for _, r := range roleVocabulary {
+		known[r.name] = true
+	}
+	for _, et := range epubTypeVocabulary {
+		if role, ok := accessibility.RoleForEpubType(et.name); ok && !known[role] {
+			t.Errorf("role %q for epub:type %q is not offered", role, et.name)
+		}
+	}
+}
+
+// snippetCompletionAt requests completions with the cursor on the blank,
+// indented line of content marked by "|", which is removed.
+func snippetCompletionAt(
+	t *testing.T,
+	uri string,
+	fileType epub.FileType,
+	marked string,
+	snippets bool,
+) []CompletionItem {
+	t.Helper()
+	offset := strings.Index(marked, "|")
+	content := []byte(strings.Replace(marked, "|", "", 1))
+
+	ws := newMockWorkspace()
+	ws.files[uri] = content
+	ws.fileTypes[uri] = fileType
+	ws.settings = &ServerSettings{SnippetSupport: snippets}
+
+	data := makeRequest(t, 1, MethodCompletion, CompletionParams{
+		TextDocument: TextDocumentIdentifier{Uri: uri},
+		Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+	})
+	return unmarshalResult[CompletionList](t,
+		HandleCompletion(t.Context(), data, ws)).Items
+}
+
+const metadataSnippetOPF = `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata>
+    |
+  </metadata>
+</package>`
+
+func TestHandleCompletion_MetadataSnippet(t *testing.T) {
+	items := snippetCompletionAt(t, "file:///book/content.opf", epub.FileTypeOPF,
+		metadataSnippetOPF, true)
+
+	if len(items) != 1 || items[0].Label != "Accessibility metadata block" {
+		t.Fatalf("expected accessibility snippet, got %v", completionLabels(items))
+	}
+	item := items[0]
+	if item.InsertTextFormat != InsertTextFormatSnippet || item.TextEdit == nil {
+		t.Fatalf("expected snippet text edit, got %+v", item)
+	}
+	if item.TextEdit.Range.Start != (Position{Line: 3, Character: 4}) {
+		t.Errorf("expected insertion at 3:4, got %v", item.TextEdit.Range.Start)
+	}
+
+	text := item.TextEdit.NewText
+	first := `<meta property="schema:accessMode">${1:textual}</meta>`
+	if !strings.HasPrefix(text, first) {
+		t.Errorf("unexpected snippet start:\n%s", text)
+	}
+	last := "\n    <meta property=\"schema:accessibilitySummary\">${5:"
+	if !strings.Contains(text, last) {
+		t.Errorf("expected continuation lines indented to the insertion line:\n%s", text)
+	}
+	if strings.Count(text, "<meta ") != 5 {
+		t.Errorf("expected 5 meta elements:\n%s", text)
+	}
+}
+
+func TestHandleCompletion_MetadataSnippetPlainText(t *testing.T) {
+	items := snippetCompletionAt(t, "file:///book/content.opf", epub.FileTypeOPF,
+		metadataSnippetOPF, false)
+
+	if len(items) != 1 || items[0].TextEdit == nil {
+		t.Fatalf("expected one plain-text completion, got %v", completionLabels(items))
+	}
+	if items[0].InsertTextFormat != InsertTextFormatPlainText {
+		t.Errorf("expected plain text format, got %d", items[0].InsertTextFormat)
+	}
+	text := items[0].TextEdit.NewText
+	if strings.Contains(text, "$") {
+		t.Errorf("expected tab stops to be removed:\n%s", text)
+	}
+	hazard := `<meta property="schema:accessibilityHazard">none</meta>`
+	if !strings.Contains(text, hazard) {
+		t.Errorf("expected placeholder defaults to be kept:\n%s", text)
+	}
+}
+
+func TestHandleCompletion_NavBodySnippets(t *testing.T) {
+	items := snippetCompletionAt(t, "file:///book/nav.xhtml", epub.FileTypeNav,
+		`<html xmlns="http://www.w3.org/1999/xhtml"><body>
+	|
+</body></html>`, true)
+
+	labels := completionLabels(items)
+	want := []string{"toc nav skeleton", "figure with figcaption", "pagebreak span"}
+	if strings.Join(labels, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, want %v", labels, want)
+	}
+	if !strings.Contains(items[0].TextEdit.NewText, "\n\t\t\t<li><a href=\"${3:") {
+		t.Errorf("expected tab-indented nav skeleton:\n%s", items[0].TextEdit.NewText)
+	}
+}
+
+func TestHandleCompletion_XHTMLSectionSnippets(t *testing.T) {
+	items := snippetCompletionAt(t, "file:///book/chapter.xhtml", epub.FileTypeXHTML,
+		`<html xmlns="http://www.w3.org/1999/xhtml"><body>
+  <section>
+    |
+  </section>
+</body></html>`, true)
+
+	labels := completionLabels(items)
+	if len(labels) != 2 || labels[0] != "figure with figcaption" ||
+		labels[1] != "pagebreak span" {
+		t.Fatalf("unexpected snippets %v", labels)
+	}
+	if !strings.Contains(items[0].TextEdit.NewText, "\n      <img src=") {
+		t.Errorf("expected two-space indentation under the section:\n%s",
+			items[0].TextEdit.NewText)
+	}
+}
+
+func TestHandleCompletion_ItemrefPropertiesMidToken(t *testing.T) {
+	ws := newMockWorkspace()
+	content := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <spine>
+    <itemref idref="ch1" properties="page-spread-left rendition:lay"/>
+  </spine>
+</package>`)
+	ws.files["file:///book/content.opf"] = content
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	// Cursor in the middle of "rendition:lay"
+	offset := findSubstring(content, "rendition:lay") + len("rendition:l")
+	data := makeRequest(t, 1, MethodCompletion, CompletionParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+		Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+	})
+	items := unmarshalResult[CompletionList](t, HandleCompletion(t.Context(), data, ws)).Items
+
+	if len(items) != len(opf.ItemrefProperties)-1 {
+		t.Fatalf("expected %d items, got %v", len(opf.ItemrefProperties)-1, completionLabels(items))
+	}
+	for _, item := range items {
+		if item.Label == "page-spread-left" {
+			t.Error("unexpected suggestion of a property already present")
+		}
+		if item.TextEdit == nil {
+			t.Fatalf("item %q has no text edit", item.Label)
+		}
+		// The edit replaces only the token under the cursor
+		r := item.TextEdit.Range
+		if r.Start.Line != 3 || r.Start.Character != 54 || r.End.Character != 67 {
+			t.Errorf("range = %+v, want line 3, characters 54-67", r)
+		}
+	}
+}
+
+func TestHandleCompletion_LinkRel(t *testing.T) {
+	ws := newMockWorkspace()
+	content := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata>
+    <link rel="record vo" href="voice.mp3"/>
+  </metadata>
+</package>`)
+	ws.files["file:///book/content.opf"] = content
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	// Cursor at the end of "vo"
+	offset := findSubstring(content, `vo"`) + len("vo")
+	data := makeRequest(t, 1, MethodCompletion, CompletionParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+		Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+	})
+	items := unmarshalResult[CompletionList](t, HandleCompletion(t.Context(), data, ws)).Items
+
+	labels := completionLabels(items)
+	if !slices.Contains(labels, "voicing") || !slices.Contains(labels, "onix-record") {
+		t.Fatalf("expected link rel values, got %v", labels)
+	}
+	if slices.Contains(labels, "record") {
+		t.Errorf("expected record, already present, to be skipped")
+	}
+	for _, item := range items {
+		if item.TextEdit == nil || item.TextEdit.NewText != item.Label {
+			t.Fatalf("unexpected item %+v", item)
+		}
+		start := epub.PositionToByteOffset(content, posToEpub(item.TextEdit.Range.Start))
+		end := epub.PositionToByteOffset(content, posToEpub(item.TextEdit.Range.End))
+		if string(content[start:end]) != "vo" {
+			t.Errorf("edit replaces %q, want the token under the cursor", content[start:end])
+		}
+	}
+}
+
+func TestHandleCompletion_LangAttribute(t *testing.T) {
+	for _, attr := range []string{"lang", "xml:lang"} {
+		items := epubTypeCompletionAt(t, `<p `+attr+`="en-">Hello</p>`, attr+`="en-`)
+
+		labels := completionLabels(items)
+		if !slices.Contains(labels, "en-GB") || !slices.Contains(labels, "zh-Hant") {
+			t.Fatalf("%s: expected language tags, got %v", attr, labels)
+		}
+		for _, item := range items {
+			if item.Detail == "" || item.TextEdit == nil {
+				t.Fatalf("%s: unexpected item %+v", attr, item)
+			}
+		}
+		if items[0].Label != "en" || items[0].Detail != "English" {
+			t.Errorf("%s: first item = %+v, want en (English)", attr, items[0])
+		}
+		// The edit replaces the whole value, hyphen included
+		if r := items[0].TextEdit.Range; r.End.Character-r.Start.Character != 3 {
+			t.Errorf("%s: edit range = %+v, want the value en-", attr, r)
+		}
+	}
+}
+
+func TestHandleCompletion_DCLanguage(t *testing.T) {
+	ws := newMockWorkspace()
+	content := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:language> fr </dc:language>
+  </metadata>
+</package>`)
+	ws.files["file:///book/content.opf"] = content
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	offset := findSubstring(content, "fr </dc:language>") + 1
+	data := makeRequest(t, 1, MethodCompletion, CompletionParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+		Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+	})
+	items := unmarshalResult[CompletionList](t, HandleCompletion(t.Context(), data, ws)).Items
+
+	labels := completionLabels(items)
+	if !slices.Contains(labels, "fr-CA") {
+		t.Fatalf("expected language tags, got %v", labels)
+	}
+	edit := items[0].TextEdit
+	start := epub.PositionToByteOffset(content, posToEpub(edit.Range.Start))
+	end := epub.PositionToByteOffset(content, posToEpub(edit.Range.End))
+	if got := string(content[start:end]); got != "fr" {
+		t.Errorf("edit replaces %q, want the trimmed element content", got)
+	}
+}
+
+// metaCompletions requests completion at the marker "|" in the content of
+// a meta element with property and returns the items and the content.
+func metaCompletions(t *testing.T, property, value string) ([]CompletionItem, []byte) {
+	t.Helper()
+	before, after, _ := strings.Cut(value, "|")
+	content := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata>
+    <meta property="` + property + `">` + before + after + `</meta>
+  </metadata>
+</package>`)
+	ws := newMockWorkspace()
+	ws.files["file:///book/content.opf"] = content
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	offset := findSubstring(content, `">`+before+after+"</meta>") + 2 + len(before)
+	data := makeRequest(t, 1, MethodCompletion, CompletionParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+		Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+	})
+	result := unmarshalResult[CompletionList](t, HandleCompletion(t.Context(), data, ws))
+	return result.Items, content
+}
+
+// editedText returns the text of content that edit replaces.
+func editedText(content []byte, edit *TextEdit) string {
+	start := epub.PositionToByteOffset(content, posToEpub(edit.Range.Start))
+	end := epub.PositionToByteOffset(content, posToEpub(edit.Range.End))
+	return string(content[start:end])
+}
+
+func TestHandleCompletion_MetaValues(t *testing.T) {
+	tests := []struct {
+		property, value string
+		want, notWant   string
+	}{
+		{"schema:accessMode", "|", "textual", "structuralNavigation"},
+		{"schema:accessibilityFeature", " tab|le ", "tableOfContents", "textual"},
+		{"schema:accessibilityHazard", "|", "noFlashingHazard", "textual"},
+	}
+	for _, tt := range tests {
+		items, content := metaCompletions(t, tt.property, tt.value)
+		labels := completionLabels(items)
+		if !slices.Contains(labels, tt.want) || slices.Contains(labels, tt.notWant) {
+			t.Errorf("%s: got %v, want %s and not %s", tt.property, labels, tt.want, tt.notWant)
+			continue
+		}
+		want := strings.TrimSpace(strings.ReplaceAll(tt.value, "|", ""))
+		if got := editedText(content, items[0].TextEdit); got != want {
+			t.Errorf("%s: edit replaces %q, want %q", tt.property, got, want)
+		}
+	}
+}
+
+func TestHandleCompletion_AccessModeSufficient(t *testing.T) {
+	items, content := metaCompletions(t, "schema:accessModeSufficient", "textual, vi|")
+	labels := completionLabels(items)
+	if !slices.Contains(labels, "textual,visual") || !slices.Contains(labels, "visual") {
+		t.Fatalf("expected combinations and modes, got %v", labels)
+	}
+	if slices.Contains(labels, "textual") {
+		t.Error("textual is already in the value and should not be suggested again")
+	}
+	for _, item := range items {
+		want := "vi"
+		if strings.Contains(item.Label, ",") {
+			want = "textual, vi"
+		}
+		if got := editedText(content, item.TextEdit); got != want {
+			t.Errorf("%s: edit replaces %q, want %q", item.Label, got, want)
+		}
+	}
+}
+
+func TestHandleCompletion_MetaUnknownProperty(t *testing.T) {
+	for _, property := range []string{"dcterms:modified", "schema:accessibilitySummary"} {
+		if items, _ := metaCompletions(t, property, "|"); len(items) != 0 {
+			t.Errorf("%s: expected no completions, got %v", property, completionLabels(items))
+		}
+	}
+}
+
+func TestHandleCompletion_MediaFeature(t *testing.T) {
+	const uri = "file:///book/style.css"
+	content := []byte("@media screen and (min-widht: 30em) and " +
+		"(prefers-color-scheme: ) {\n}\n")
+	ws := newMockWorkspace()
+	ws.files[uri] = content
+	ws.fileTypes[uri] = epub.FileTypeCSS
+
+	complete := func(offset int) []CompletionItem {
+		data := makeRequest(t, 1, MethodCompletion, CompletionParams{
+			TextDocument: TextDocumentIdentifier{Uri: uri},
+			Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+		})
+		resp := HandleCompletion(t.Context(), data, ws)
+		return unmarshalResult[CompletionList](t, resp).Items
+	}
+
+	// Inside the misspelled name, which the completion replaces
+	name := findSubstring(content, "min-widht")
+	items := complete(name + 4)
+	byLabel := func(label string) int {
+		return slices.IndexFunc(items, func(c CompletionItem) bool { return c.Label == label })
+	}
+	i := byLabel("min-width")
+	if i < 0 {
+		t.Fatalf("expected min-width among %d feature completions", len(items))
+	}
+	got := applyEdits(content, []TextEdit{*items[i].TextEdit})
+	if !strings.Contains(got, "(min-width: 30em)") {
+		t.Errorf("completion gave %q", got)
+	}
+	hover := byLabel("hover")
+	if hover < 0 || items[hover].Detail == "" ||
+		items[hover].SortText <= items[i].SortText {
+		t.Errorf("expected hover marked as poorly supported and sorted last")
+	}
+
+	// After the colon, the feature's values
+	value := findSubstring(content, "scheme: ") + len("scheme: ")
+	var labels []string
+	for _, item := range complete(value) {
+		labels = append(labels, item.Label)
+	}
+	if !slices.Equal(labels, []string{"light", "dark"}) {
+		t.Errorf("value completions = %v, want light and dark", labels)
+	}
+
+	// Outside the parentheses
+	if items := complete(findSubstring(content, "screen")); len(items) != 0 {
+		t.Errorf("expected no completions for the media type, got %d", len(items))
+	}
+}
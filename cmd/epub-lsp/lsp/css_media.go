@@ -0,0 +1,173 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator/css"
+)
+
+// mediaPreludeAt returns the @media rule of a stylesheet whose prelude
+// holds offset, counting the whitespace after it, which the prelude does
+// not include.
+func mediaPreludeAt(content []byte, offset int) (parser.CSSAtRule, bool) {
+	_, atRules, _ := parser.ScanCSS(content)
+	for _, at := range atRules {
+		end := at.PreludeOffset + len(at.Prelude)
+		for end < len(content) && strings.IndexByte(" \t\n\r\f", content[end]) >= 0 {
+			end++
+		}
+		if strings.EqualFold(at.Name, "@media") &&
+			offset >= at.PreludeOffset && offset <= end {
+			return at, true
+		}
+	}
+	return parser.CSSAtRule{}, false
+}
+
+// completionCSS suggests media feature names, and the values of the feature
+// being tested, when offset is inside the parentheses of an @media
+// prelude.
+func completionCSS(content []byte, offset int) []CompletionItem {
+	at, ok := mediaPreludeAt(content, offset)
+	if !ok {
+		return nil
+	}
+	before := string(content[at.PreludeOffset:offset])
+	open := openMediaParen(before)
+	if open < 0 {
+		return nil
+	}
+
+	start, end := offset, offset
+	for start > at.PreludeOffset+open+1 && isMediaWordByte(content[start-1]) {
+		start--
+	}
+	for end < len(content) && isMediaWordByte(content[end]) {
+		end++
+	}
+	rng := Range{
+		Start: lspPos(epub.ByteOffsetToPosition(content, start)),
+		End:   lspPos(epub.ByteOffsetToPosition(content, end)),
+	}
+
+	if name, _, ok := strings.Cut(before[open+1:], ":"); ok {
+		return mediaValueCompletions(strings.TrimSpace(name), rng)
+	}
+	return mediaFeatureCompletions(rng)
+}
+
+// openMediaParen returns the index in prelude of the innermost unclosed
+// parenthesis opening a media test, skipping those of functions such as
+// calc(), or -1 when there is none.
+func openMediaParen(prelude string) int {
+	var open []int
+	for i := range len(prelude) {
+		switch prelude[i] {
+		case '(':
+			if i > 0 && isMediaWordByte(prelude[i-1]) {
+				open = append(open, -1)
+			} else {
+				open = append(open, i)
+			}
+		case ')':
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	if len(open) == 0 {
+		return -1
+	}
+	// -1 when inside a function's arguments, which are values
+	return open[len(open)-1]
+}
+
+// isMediaWordByte reports whether c may appear in a media feature name or
+// a value such as 30em or 16/9.
+func isMediaWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '/'
+}
+
+// mediaFeatureCompletions returns the media feature names, replacing rng.
+// Features reading systems support poorly sort last.
+func mediaFeatureCompletions(rng Range) []CompletionItem {
+	names := css.MediaFeatureNames()
+	items := make([]CompletionItem, 0, len(names))
+	for i, name := range names {
+		info, _ := css.LookupMediaFeature(name)
+		item := CompletionItem{
+			Label:         name,
+			Kind:          CompletionKindProperty,
+			Documentation: info.Doc,
+			SortText:      fmt.Sprintf("0%03d", i),
+			TextEdit:      &TextEdit{Range: rng, NewText: name},
+		}
+		if info.Limited {
+			item.Detail = "poorly supported by reading systems"
+			item.SortText = fmt.Sprintf("1%03d", i)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// mediaValueCompletions returns the keywords, or example values, of the
+// named media feature, replacing rng.
+func mediaValueCompletions(name string, rng Range) []CompletionItem {
+	info, ok := css.LookupMediaFeature(name)
+	if !ok {
+		return nil
+	}
+	items := make([]CompletionItem, len(info.Values))
+	for i, value := range info.Values {
+		items[i] = CompletionItem{
+			Label:    value,
+			Kind:     CompletionKindValue,
+			SortText: fmt.Sprintf("%03d", i),
+			TextEdit: &TextEdit{Range: rng, NewText: value},
+		}
+	}
+	return items
+}
+
+// hoverCSS documents the media feature under offset in an @media prelude.
+func hoverCSS(content []byte, offset int) *Hover {
+	at, ok := mediaPreludeAt(content, offset)
+	if !ok {
+		return nil
+	}
+	for _, f := range parser.ParseMediaQuery(at.Prelude) {
+		start := at.PreludeOffset + f.Offset
+		if offset < start || offset > start+len(f.Name) {
+			continue
+		}
+		info, ok := css.LookupMediaFeature(f.Name)
+		if !ok {
+			return nil
+		}
+		text := "**" + strings.ToLower(f.Name) + "** (media feature)\n\n" + info.Doc
+		if len(info.Values) > 0 {
+			label := "Values"
+			if info.Range {
+				label = "Example values"
+			}
+			text += "\n\n" + label + ": `" + strings.Join(info.Values, "`, `") + "`"
+		}
+		if info.Limited {
+			text += "\n\nPoorly supported by reading systems; don't rely on it " +
+				"for content readers need."
+		}
+		return &Hover{
+			Contents: MarkupContent{Kind: "markdown", Value: text},
+			Range: &Range{
+				Start: lspPos(epub.ByteOffsetToPosition(content, start)),
+				End:   lspPos(epub.ByteOffsetToPosition(content, start+len(f.Name))),
+			},
+		}
+	}
+	return nil
+}
@@ -1,20 +1,20 @@
 package lsp
 
 import (
+	"context"
 	"encoding/json"
-	"log/slog"
-	"net/url"
 	"strings"
 
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
 )
 
 // HandleDefinition processes textDocument/definition requests.
-func HandleDefinition(data []byte, ws WorkspaceReader) []byte {
+func HandleDefinition(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
 	var req RequestMessage[DefinitionParams]
 	if err := json.Unmarshal(data, &req); err != nil {
-		slog.Error("error unmarshalling definition: " + err.Error())
+		Logger(ctx).Error("error unmarshalling definition: " + err.Error())
 		return marshalResponse(req.Id, []Location{})
 	}
 
@@ -30,6 +30,17 @@ func HandleDefinition(data []byte, ws WorkspaceReader) []byte {
 		return marshalResponse(req.Id, []Location{})
 	}
 
+	// Template names resolve across files whether or not the XML parses
+	if ref := templateRefAt(uri, content, offset); ref != nil {
+		refs := workspaceTemplateRefs(ctx, ref.name, ws)
+		if ctx.Err() != nil {
+			return cancelledResponse(req.Id)
+		}
+		locations := templateLocations(refs, ws, true, false)
+		newRangeClamper(ctx, ws, MethodDefinition).locations(locations)
+		return marshalResponse(req.Id, locations)
+	}
+
 	fileType := ws.GetFileType(uri)
 	var locations []Location
 
@@ -49,7 +60,11 @@ func HandleDefinition(data []byte, ws WorkspaceReader) []byte {
 	case epub.FileTypeXHTML, epub.FileTypeNav:
 		locations = definitionInXHTML(result, content, uri, ws)
 	}
+	if locations == nil {
+		locations = []Location{}
+	}
 
+	newRangeClamper(ctx, ws, MethodDefinition).locations(locations)
 	return marshalResponse(req.Id, locations)
 }
 
@@ -60,34 +75,32 @@ func definitionInOPF(
 	root *parser.XMLNode,
 	ws WorkspaceReader,
 ) []Location {
-	if result.Attr == nil || !result.InValue {
+	if !result.OnAttribute() {
 		return nil
 	}
 
 	node := result.Node
 	attr := result.Attr
 
-	// <itemref idref="x"> → jump to <item id="x">
-	if node.Local == "itemref" && attr.Local == "idref" {
+	// <itemref idref="x">, <spine toc="x">, <item media-overlay="x">
+	// → jump to <item id="x">
+	if isManifestIDRef(node.Local, attr.Local) {
 		return findManifestItemByID(root, content, uri, attr.Value)
 	}
 
 	// <item href="x"> → jump to the referenced file
 	if node.Local == "item" && attr.Local == "href" {
-		baseDir := dirFromURI(uri)
-		target := resolveToFileURI(baseDir, attr.Value, uri)
-		targetContent := ws.GetContent(target)
-		if targetContent != nil {
-			return []Location{{URI: target, Range: Range{}}}
-		}
-		// Try finding in all files by path suffix
-		for fileURI := range ws.GetAllFiles() {
-			if pathEndsWith(fileURI, attr.Value) {
-				return []Location{{URI: fileURI, Range: Range{}}}
-			}
+		target := uriutil.ResolveRelative(uri, attr.Value)
+		if fileURI, ok := uriutil.Lookup(ws.GetAllFiles(), target); ok {
+			return []Location{{URI: fileURI, Range: Range{}}}
 		}
 	}
 
+	// <meta refines="#x"> → jump to the refined element
+	if attr.Local == "refines" && strings.HasPrefix(attr.Value, "#") {
+		return findElementByID(root, content, uri, attr.Value[1:])
+	}
+
 	// unique-identifier="x" → jump to dc:identifier id="x"
 	if node.Local == "package" && attr.Local == "unique-identifier" {
 		return findElementByID(root, content, uri, attr.Value)
@@ -102,7 +115,7 @@ func definitionInXHTML(
 	uri string,
 	ws WorkspaceReader,
 ) []Location {
-	if result.Attr == nil || !result.InValue {
+	if !result.OnAttribute() {
 		return nil
 	}
 
@@ -119,32 +132,18 @@ func definitionInXHTML(
 func resolveHrefTarget(href string, _ []byte, uri string, ws WorkspaceReader) []Location {
 	filePart, fragment, hasFragment := strings.Cut(href, "#")
 
-	baseDir := dirFromURI(uri)
-
-	var targetURI string
-	var targetContent []byte
-
-	if filePart == "" {
-		// Same-file reference
-		targetURI = uri
-		targetContent = ws.GetContent(uri)
-	} else {
-		target := resolveToFileURI(baseDir, filePart, uri)
-		targetContent = ws.GetContent(target)
-		if targetContent != nil {
-			targetURI = target
-		} else {
-			// Try all files
-			for fileURI, c := range ws.GetAllFiles() {
-				if pathEndsWith(fileURI, filePart) {
-					targetURI = fileURI
-					targetContent = c
-					break
-				}
-			}
+	// Same-file reference
+	targetURI := uri
+	if filePart != "" {
+		var ok bool
+		target := uriutil.ResolveRelative(uri, filePart)
+		targetURI, ok = uriutil.Lookup(ws.GetAllFiles(), target)
+		if !ok {
+			return nil
 		}
 	}
 
+	targetContent := ws.GetContent(targetURI)
 	if targetContent == nil {
 		return nil
 	}
@@ -162,6 +161,16 @@ func resolveHrefTarget(href string, _ []byte, uri string, ws WorkspaceReader) []
 	return findElementByID(targetRoot, targetContent, targetURI, fragment)
 }
 
+// isManifestIDRef reports whether the named attribute of an OPF element
+// holds the id of a manifest item.
+func isManifestIDRef(element, attr string) bool {
+	switch element + "/" + attr {
+	case "itemref/idref", "spine/toc", "item/media-overlay":
+		return true
+	}
+	return false
+}
+
 func findManifestItemByID(
 	root *parser.XMLNode,
 	content []byte,
@@ -181,34 +190,26 @@ func findManifestItemByID(
 }
 
 func findElementByID(root *parser.XMLNode, content []byte, uri, id string) []Location {
-	return findElementByIDRecursive(root, content, uri, id)
+	node := findNodeByID(root, id)
+	if node == nil {
+		return nil
+	}
+	pos := epub.ByteOffsetToPosition(content, int(node.Offset))
+	return []Location{{
+		URI:   uri,
+		Range: Range{Start: lspPos(pos), End: lspPos(pos)},
+	}}
 }
 
-func findElementByIDRecursive(
-	node *parser.XMLNode,
-	content []byte,
-	uri, id string,
-) []Location {
+// findNodeByID returns the first element at or below node with the given id.
+func findNodeByID(node *parser.XMLNode, id string) *parser.XMLNode {
 	if node.Attr("id") == id {
-		pos := epub.ByteOffsetToPosition(content, int(node.Offset))
-		return []Location{{
-			URI:   uri,
-			Range: Range{Start: lspPos(pos), End: lspPos(pos)},
-		}}
+		return node
 	}
 	for _, child := range node.Children {
-		if locs := findElementByIDRecursive(child, content, uri, id); len(locs) > 0 {
-			return locs
+		if found := findNodeByID(child, id); found != nil {
+			return found
 		}
 	}
 	return nil
 }
-
-// pathEndsWith checks if a URI path ends with the given suffix.
-func pathEndsWith(uri, suffix string) bool {
-	if u, err := url.Parse(uri); err == nil {
-		p := u.Path
-		return p == suffix || strings.HasSuffix(p, "/"+suffix)
-	}
-	return strings.HasSuffix(uri, "/"+suffix)
-}
@@ -35,7 +35,7 @@ func TestHandleDefinition_ItemrefToItem(t *testing.T) {
 		Position:     lspPosition,
 	})
 
-	resp := HandleDefinition(data, ws)
+	resp := HandleDefinition(t.Context(), data, ws)
 	locations := unmarshalResult[[]Location](t, resp)
 
 	if len(locations) == 0 {
@@ -76,7 +76,7 @@ func TestHandleDefinition_HrefInXHTML(t *testing.T) {
 		Position:     lspPosition,
 	})
 
-	resp := HandleDefinition(data, ws)
+	resp := HandleDefinition(t.Context(), data, ws)
 	locations := unmarshalResult[[]Location](t, resp)
 
 	if len(locations) == 0 {
@@ -88,6 +88,47 @@ func TestHandleDefinition_HrefInXHTML(t *testing.T) {
 	}
 }
 
+func TestHandleDefinition_HrefExactPath(t *testing.T) {
+	ws := newMockWorkspace()
+	xhtmlContent := []byte(`<?xml version="1.0"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<body>
+  <a href="my%20notes.xhtml#n1">Notes</a>
+  <a href="chapter2.xhtml">Next</a>
+</body>
+</html>`)
+	notes := []byte(`<html><body><p id="n1"/></body></html>`)
+	files := map[string][]byte{
+		"file:///book/text/chapter1.xhtml":    xhtmlContent,
+		"file:///book/text/my%20notes.xhtml":  notes,
+		"file:///book/text/notchapter2.xhtml": []byte(`<html><body/></html>`),
+		"file:///book/other/chapter2.xhtml":   []byte(`<html><body/></html>`),
+	}
+	for uri, content := range files {
+		ws.files[uri] = content
+		ws.fileTypes[uri] = epub.FileTypeXHTML
+	}
+
+	definitionAt := func(marker string) []Location {
+		offset := findSubstring(xhtmlContent, marker)
+		data := makeRequest(t, 1, MethodDefinition, DefinitionParams{
+			TextDocument: TextDocumentIdentifier{Uri: "file:///book/text/chapter1.xhtml"},
+			Position:     lspPos(epub.ByteOffsetToPosition(xhtmlContent, offset+6)),
+		})
+		return unmarshalResult[[]Location](t, HandleDefinition(t.Context(), data, ws))
+	}
+
+	locations := definitionAt(`href="my%20notes.xhtml#n1"`)
+	if len(locations) != 1 || locations[0].URI != "file:///book/text/my%20notes.xhtml" {
+		t.Errorf("expected the percent-encoded file, got %v", locations)
+	}
+
+	// Files whose paths merely end with the href are not the target
+	if locations := definitionAt(`href="chapter2.xhtml"`); len(locations) != 0 {
+		t.Errorf("expected no location for a missing file, got %v", locations)
+	}
+}
+
 func TestHandleDefinition_NoContent(t *testing.T) {
 	ws := newMockWorkspace()
 	data := makeRequest(t, 1, MethodDefinition, DefinitionParams{
@@ -95,7 +136,7 @@ func TestHandleDefinition_NoContent(t *testing.T) {
 		Position:     Position{Line: 0, Character: 0},
 	})
 
-	resp := HandleDefinition(data, ws)
+	resp := HandleDefinition(t.Context(), data, ws)
 	locations := unmarshalResult[[]Location](t, resp)
 
 	if len(locations) != 0 {
@@ -113,3 +154,145 @@ func findSubstring(content []byte, substr string) int {
 	}
 	return -1
 }
+
+func TestHandleDefinition_OnAttributeName(t *testing.T) {
+	ws := newMockWorkspace()
+	opfContent := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <manifest>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref = 'ch1'/>
+  </spine>
+</package>`)
+	ws.files["file:///book/content.opf"] = opfContent
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	// Cursor on the "r" of idref
+	offset := findSubstring(opfContent, `idref = 'ch1'`)
+	pos := epub.ByteOffsetToPosition(opfContent, offset+3)
+
+	data := makeRequest(t, 1, MethodDefinition, DefinitionParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+		Position:     lspPos(pos),
+	})
+
+	resp := HandleDefinition(t.Context(), data, ws)
+	locations := unmarshalResult[[]Location](t, resp)
+
+	if len(locations) == 0 {
+		t.Fatal("expected a location when the cursor is on the attribute name")
+	}
+}
+
+// refinesOPF is a package document whose metadata uses refines.
+var refinesOPF = []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:creator id="creator01">Jane Doe</dc:creator>
+    <meta refines="#creator01" property="role" scheme="marc:relators">aut</meta>
+    <meta refines="#creator01" property="file-as">Doe, Jane</meta>
+    <meta refines="#missing" property="file-as">Nobody</meta>
+  </metadata>
+</package>`)
+
+func TestHandleDefinition_Refines(t *testing.T) {
+	ws := newMockWorkspace()
+	ws.files["file:///book/content.opf"] = refinesOPF
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	tests := []struct {
+		at   string
+		want int
+	}{
+		{`#creator01" property="role"`, 1},
+		{`#missing"`, 0},
+	}
+
+	for _, tt := range tests {
+		offset := findSubstring(refinesOPF, tt.at)
+		data := makeRequest(t, 1, MethodDefinition, DefinitionParams{
+			TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+			Position:     lspPos(epub.ByteOffsetToPosition(refinesOPF, offset+2)),
+		})
+
+		locations := unmarshalResult[[]Location](t,
+			HandleDefinition(t.Context(), data, ws))
+		if len(locations) != tt.want {
+			t.Fatalf("%s: expected %d locations, got %d", tt.at, tt.want, len(locations))
+		}
+		if tt.want == 0 {
+			continue
+		}
+
+		want := epub.ByteOffsetToPosition(refinesOPF,
+			findSubstring(refinesOPF, `<dc:creator id="creator01"`))
+		if locations[0].Range.Start != lspPos(want) {
+			t.Errorf("expected jump to dc:creator at %v, got %v",
+				want, locations[0].Range.Start)
+		}
+	}
+}
+
+var manifestRefsOPF = []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="smil1" href="ch1.smil" media-type="application/smil+xml"/>
+    <item id="ch1" href="ch1.xhtml" media-type="application/xhtml+xml" media-overlay="smil1"/>
+    <item id="ch2" href="ch2.xhtml" media-type="application/xhtml+xml" media-overlay="gone"/>
+  </manifest>
+  <spine toc="ncx">
+    <itemref idref="ch1"/>
+  </spine>
+</package>`)
+
+func TestHandleDefinition_ManifestIDRefs(t *testing.T) {
+	ws := newMockWorkspace()
+	ws.files["file:///book/content.opf"] = manifestRefsOPF
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	tests := []struct {
+		name, at, want string
+	}{
+		{"spine toc", `toc="ncx"`, `<item id="ncx"`},
+		{"media-overlay", `media-overlay="smil1"`, `<item id="smil1"`},
+		{"dangling media-overlay", `media-overlay="gone"`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset := findSubstring(manifestRefsOPF, tt.at) + len(tt.at) - 2
+			data := makeRequest(t, 1, MethodDefinition, DefinitionParams{
+				TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+				Position:     lspPos(epub.ByteOffsetToPosition(manifestRefsOPF, offset)),
+			})
+
+			resp := HandleDefinition(t.Context(), data, ws)
+			var result ResponseMessage[[]Location]
+			if err := unmarshalJSON(resp, &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Error != nil {
+				t.Fatalf("expected a result, got %s", resp)
+			}
+			locations := result.Result
+			if tt.want == "" {
+				if locations == nil || len(locations) != 0 {
+					t.Fatalf("expected an empty location list, got %s", resp)
+				}
+				return
+			}
+			if len(locations) != 1 {
+				t.Fatalf("expected 1 location, got %d", len(locations))
+			}
+			want := epub.ByteOffsetToPosition(manifestRefsOPF,
+				findSubstring(manifestRefsOPF, tt.want))
+			if locations[0].Range.Start != lspPos(want) {
+				t.Errorf("expected jump to %s at %v, got %v",
+					tt.want, want, locations[0].Range.Start)
+			}
+		})
+	}
+}
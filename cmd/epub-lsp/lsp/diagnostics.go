@@ -0,0 +1,66 @@
+package lsp
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/lsp/position"
+)
+
+// PublishDiagnosticsNotification builds a textDocument/publishDiagnostics
+// notification for uri. A nil version omits the version field.
+func PublishDiagnosticsNotification(
+	uri string,
+	version *int,
+	diags []epub.Diagnostic,
+) []byte {
+	lspDiags := make([]Diagnostic, len(diags))
+	for i, d := range diags {
+		lspDiags[i] = toLSPDiagnostic(d)
+	}
+
+	notification := NotificationMessage[PublishDiagnosticsParams]{
+		JsonRpc: JSONRPCVersion,
+		Method:  MethodPublishDiagnostics,
+		Params: PublishDiagnosticsParams{
+			Uri:         uri,
+			Version:     version,
+			Diagnostics: lspDiags,
+		},
+	}
+
+	data, err := json.Marshal(notification)
+	if err != nil {
+		slog.Error("error marshalling diagnostics: " + err.Error())
+		return nil
+	}
+	return data
+}
+
+// toLSPDiagnostic converts an epub.Diagnostic to its LSP representation.
+func toLSPDiagnostic(d epub.Diagnostic) Diagnostic {
+	var description *CodeDescription
+	if href := epub.CodeURL(d.Code); href != "" {
+		description = &CodeDescription{Href: href}
+	}
+
+	return Diagnostic{
+		Range: Range{
+			Start: Position{
+				Line:      position.IntToUint(d.Range.Start.Line),
+				Character: position.IntToUint(d.Range.Start.Character),
+			},
+			End: Position{
+				Line:      position.IntToUint(d.Range.End.Line),
+				Character: position.IntToUint(d.Range.End.Character),
+			},
+		},
+		Message:         d.Message,
+		Severity:        d.Severity,
+		Code:            d.Code,
+		Source:          d.Source,
+		Data:            d.Fix,
+		CodeDescription: description,
+	}
+}
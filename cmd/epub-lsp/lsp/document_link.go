@@ -1,22 +1,20 @@
 package lsp
 
 import (
+	"context"
 	"encoding/json"
-	"log/slog"
-	"net/url"
-	"path"
 	"strings"
 
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
-	"github.com/toba/lsp/position"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
 )
 
 // HandleDocumentLink processes textDocument/documentLink requests.
-func HandleDocumentLink(data []byte, ws WorkspaceReader) []byte {
+func HandleDocumentLink(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
 	var req RequestMessage[DocumentLinkParams]
 	if err := json.Unmarshal(data, &req); err != nil {
-		slog.Error("error unmarshalling documentLink: " + err.Error())
+		Logger(ctx).Error("error unmarshalling documentLink: " + err.Error())
 		return marshalResponse(req.Id, []DocumentLink{})
 	}
 
@@ -36,6 +34,10 @@ func HandleDocumentLink(data []byte, ws WorkspaceReader) []byte {
 		links = extractXHTMLLinks(content, uri)
 	}
 
+	clamper := newRangeClamper(ctx, ws, MethodDocumentLink)
+	for i := range links {
+		clamper.clamp(uri, &links[i].Range)
+	}
 	return marshalResponse(req.Id, links)
 }
 
@@ -45,7 +47,6 @@ func extractOPFLinks(content []byte, uri string) []DocumentLink {
 		return nil
 	}
 
-	baseDir := dirFromURI(uri)
 	var links []DocumentLink
 
 	// Find manifest items with href attributes
@@ -55,8 +56,8 @@ func extractOPFLinks(content []byte, uri string) []DocumentLink {
 		if href == "" || epub.IsRemoteURL(href) {
 			continue
 		}
-		if r, ok := findAttrValueRange(content, int(item.Offset), "href"); ok {
-			target := resolveToFileURI(baseDir, href, uri)
+		if r, ok := attrValueRange(content, item, "href"); ok {
+			target := uriutil.ResolveRelative(uri, href)
 			links = append(links, DocumentLink{Range: r, Target: target})
 		}
 	}
@@ -70,7 +71,6 @@ func extractXHTMLLinks(content []byte, uri string) []DocumentLink {
 		return nil
 	}
 
-	baseDir := dirFromURI(uri)
 	var links []DocumentLink
 
 	// <a href="...">
@@ -79,8 +79,8 @@ func extractXHTMLLinks(content []byte, uri string) []DocumentLink {
 		if href == "" || epub.IsRemoteURL(href) {
 			continue
 		}
-		if r, ok := findAttrValueRange(content, int(node.Offset), "href"); ok {
-			target := resolveToFileURI(baseDir, href, uri)
+		if r, ok := attrValueRange(content, node, "href"); ok {
+			target := uriutil.ResolveRelative(uri, href)
 			links = append(links, DocumentLink{Range: r, Target: target})
 		}
 	}
@@ -91,8 +91,8 @@ func extractXHTMLLinks(content []byte, uri string) []DocumentLink {
 		if src == "" || epub.IsRemoteURL(src) || strings.HasPrefix(src, "data:") {
 			continue
 		}
-		if r, ok := findAttrValueRange(content, int(node.Offset), "src"); ok {
-			target := resolveToFileURI(baseDir, src, uri)
+		if r, ok := attrValueRange(content, node, "src"); ok {
+			target := uriutil.ResolveRelative(uri, src)
 			links = append(links, DocumentLink{Range: r, Target: target})
 		}
 	}
@@ -103,8 +103,8 @@ func extractXHTMLLinks(content []byte, uri string) []DocumentLink {
 		if href == "" || epub.IsRemoteURL(href) {
 			continue
 		}
-		if r, ok := findAttrValueRange(content, int(node.Offset), "href"); ok {
-			target := resolveToFileURI(baseDir, href, uri)
+		if r, ok := attrValueRange(content, node, "href"); ok {
+			target := uriutil.ResolveRelative(uri, href)
 			links = append(links, DocumentLink{Range: r, Target: target})
 		}
 	}
@@ -116,8 +116,8 @@ func extractXHTMLLinks(content []byte, uri string) []DocumentLink {
 			if src == "" || epub.IsRemoteURL(src) || strings.HasPrefix(src, "data:") {
 				continue
 			}
-			if r, ok := findAttrValueRange(content, int(node.Offset), "src"); ok {
-				target := resolveToFileURI(baseDir, src, uri)
+			if r, ok := attrValueRange(content, node, "src"); ok {
+				target := uriutil.ResolveRelative(uri, src)
 				links = append(links, DocumentLink{Range: r, Target: target})
 			}
 		}
@@ -126,98 +126,14 @@ func extractXHTMLLinks(content []byte, uri string) []DocumentLink {
 	return links
 }
 
-// findAttrValueRange finds the range of an attribute value in raw content
-// starting from a tag offset. Returns the range covering just the value text.
-func findAttrValueRange(content []byte, tagOffset int, attrName string) (Range, bool) {
-	// Search for attrName= within the tag
-	searchStart := tagOffset
-	tagEnd := findStartTagEndByte(content, tagOffset)
-
-	for i := searchStart; i < tagEnd; i++ {
-		if i+len(attrName) >= len(content) {
-			break
-		}
-		if string(content[i:i+len(attrName)]) == attrName {
-			// Check it's followed by =
-			j := i + len(attrName)
-			for j < tagEnd && (content[j] == ' ' || content[j] == '\t') {
-				j++
-			}
-			if j < tagEnd && content[j] == '=' {
-				j++
-				for j < tagEnd && (content[j] == ' ' || content[j] == '\t') {
-					j++
-				}
-				if j < tagEnd && (content[j] == '"' || content[j] == '\'') {
-					quote := content[j]
-					valueStart := j + 1
-					valueEnd := valueStart
-					for valueEnd < len(content) && content[valueEnd] != quote {
-						valueEnd++
-					}
-					startPos := epub.ByteOffsetToPosition(content, valueStart)
-					endPos := epub.ByteOffsetToPosition(content, valueEnd)
-					return Range{
-						Start: Position{
-							Line:      position.IntToUint(startPos.Line),
-							Character: position.IntToUint(startPos.Character),
-						},
-						End: Position{
-							Line:      position.IntToUint(endPos.Line),
-							Character: position.IntToUint(endPos.Character),
-						},
-					}, true
-				}
-			}
-		}
-	}
-	return Range{}, false
-}
-
-// findStartTagEndByte finds the byte offset of '>' closing the start tag.
-func findStartTagEndByte(content []byte, tagStart int) int {
-	inString := byte(0)
-	for i := tagStart; i < len(content); i++ {
-		ch := content[i]
-		if inString != 0 {
-			if ch == inString {
-				inString = 0
-			}
-			continue
-		}
-		if ch == '"' || ch == '\'' {
-			inString = ch
-			continue
-		}
-		if ch == '>' {
-			return i
-		}
-	}
-	return len(content)
-}
-
-// dirFromURI extracts the directory portion of a URI.
-func dirFromURI(uri string) string {
-	if u, err := url.Parse(uri); err == nil && u.Path != "" {
-		return path.Dir(u.Path)
-	}
-	idx := strings.LastIndex(uri, "/")
-	if idx >= 0 {
-		return uri[:idx]
-	}
-	return ""
-}
-
-// resolveToFileURI resolves a relative href to a file:// URI.
-func resolveToFileURI(baseDir, href, originURI string) string {
-	if decoded, err := url.PathUnescape(href); err == nil {
-		href = decoded
-	}
-	resolved := path.Clean(baseDir + "/" + epub.StripFragment(href))
-
-	// Reconstruct as file URI using the origin URI's scheme
-	if u, err := url.Parse(originURI); err == nil && u.Scheme == "file" {
-		return "file://" + resolved
+// attrValueRange returns the range of the value of node's attribute name.
+func attrValueRange(content []byte, node *parser.XMLNode, name string) (Range, bool) {
+	start, end, ok := parser.AttrValueRange(content, node, name)
+	if !ok {
+		return Range{}, false
 	}
-	return resolved
+	return Range{
+		Start: lspPos(epub.ByteOffsetToPosition(content, start)),
+		End:   lspPos(epub.ByteOffsetToPosition(content, end)),
+	}, true
 }
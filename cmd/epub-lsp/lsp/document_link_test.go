@@ -22,7 +22,7 @@ func TestHandleDocumentLink_OPF(t *testing.T) {
 		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
 	})
 
-	resp := HandleDocumentLink(data, ws)
+	resp := HandleDocumentLink(t.Context(), data, ws)
 	links := unmarshalResult[[]DocumentLink](t, resp)
 
 	if len(links) < 2 {
@@ -54,7 +54,7 @@ func TestHandleDocumentLink_XHTML(t *testing.T) {
 		TextDocument: TextDocumentIdentifier{Uri: "file:///book/chapter1.xhtml"},
 	})
 
-	resp := HandleDocumentLink(data, ws)
+	resp := HandleDocumentLink(t.Context(), data, ws)
 	links := unmarshalResult[[]DocumentLink](t, resp)
 
 	if len(links) != 3 {
@@ -69,7 +69,7 @@ func TestHandleDocumentLink_NoContent(t *testing.T) {
 		TextDocument: TextDocumentIdentifier{Uri: "file:///nonexistent.opf"},
 	})
 
-	resp := HandleDocumentLink(data, ws)
+	resp := HandleDocumentLink(t.Context(), data, ws)
 	links := unmarshalResult[[]DocumentLink](t, resp)
 
 	if len(links) != 0 {
@@ -93,7 +93,7 @@ func TestHandleDocumentLink_SkipsRemoteURLs(t *testing.T) {
 		TextDocument: TextDocumentIdentifier{Uri: "file:///book/chapter1.xhtml"},
 	})
 
-	resp := HandleDocumentLink(data, ws)
+	resp := HandleDocumentLink(t.Context(), data, ws)
 	links := unmarshalResult[[]DocumentLink](t, resp)
 
 	if len(links) != 1 {
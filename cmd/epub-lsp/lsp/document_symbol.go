@@ -1,19 +1,25 @@
 package lsp
 
 import (
+	"cmp"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
-	"log/slog"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
 )
 
 // HandleDocumentSymbol processes textDocument/documentSymbol requests.
-func HandleDocumentSymbol(data []byte, ws WorkspaceReader) []byte {
+func HandleDocumentSymbol(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
 	var req RequestMessage[DocumentSymbolParams]
 	if err := json.Unmarshal(data, &req); err != nil {
-		slog.Error("error unmarshalling documentSymbol: " + err.Error())
+		Logger(ctx).Error("error unmarshalling documentSymbol: " + err.Error())
 		return marshalResponse(req.Id, []DocumentSymbol{})
 	}
 
@@ -28,17 +34,21 @@ func HandleDocumentSymbol(data []byte, ws WorkspaceReader) []byte {
 
 	switch fileType {
 	case epub.FileTypeOPF:
-		symbols = opfSymbols(content)
+		symbols = opfSymbols(uri, content, ws)
 	case epub.FileTypeXHTML, epub.FileTypeNav:
 		symbols = xhtmlSymbols(content)
 	case epub.FileTypeCSS:
 		symbols = cssSymbols(content)
 	}
 
+	newRangeClamper(ctx, ws, MethodDocumentSymbol).symbols(uri, symbols)
 	return marshalResponse(req.Id, symbols)
 }
 
-func opfSymbols(content []byte) []DocumentSymbol {
+// opfSymbols outlines the metadata, manifest, and spine of the package
+// document at uri. Spine entries are named by their place in reading
+// order and the title of the document they reference, read from ws.
+func opfSymbols(uri string, content []byte, ws WorkspaceReader) []DocumentSymbol {
 	root, diags := parser.Parse(content)
 	if len(diags) > 0 {
 		return nil
@@ -93,12 +103,23 @@ func opfSymbols(content []byte) []DocumentSymbol {
 	// Spine section
 	if spine := pkg.FindFirst("spine"); spine != nil {
 		spineSym := nodeSymbol(spine, "spine", SymbolKindNamespace, content)
+		items := make(map[string]*parser.XMLNode)
+		if manifest := pkg.FindFirst("manifest"); manifest != nil {
+			for _, item := range manifest.FindAll("item") {
+				items[item.Attr("id")] = item
+			}
+		}
+		files := ws.GetAllFiles()
+		position := 0
 		for _, itemref := range spine.Children {
 			if itemref.Local != "itemref" {
 				continue
 			}
-			idref := itemref.Attr("idref")
-			childSym := nodeSymbol(itemref, idref, SymbolKindKey, content)
+			position++
+			name, detail := spineEntry(uri, itemref, items[itemref.Attr("idref")], position, files)
+			childSym := nodeSymbol(itemref, name, SymbolKindKey, content)
+			childSym.Detail = detail
+			childSym.Range = nodeRange(itemref, content)
 			spineSym.Children = append(spineSym.Children, childSym)
 		}
 		symbols = append(symbols, spineSym)
@@ -107,6 +128,113 @@ func opfSymbols(content []byte) []DocumentSymbol {
 	return symbols
 }
 
+// spineEntry returns the symbol name of the itemref at position in
+// reading order, after the title of the document its manifest item
+// references, such as "3. The Long Winter (ch1.xhtml)", falling back to
+// the idref when the item or its title cannot be found. The detail gives
+// the media type and linear flag.
+func spineEntry(
+	opfURI string,
+	itemref, item *parser.XMLNode,
+	position int,
+	files map[string][]byte,
+) (name, detail string) {
+	title := itemref.Attr("idref")
+	var file, mediaType string
+	if item != nil {
+		href := epub.StripFragment(item.Attr("href"))
+		mediaType = item.Attr("media-type")
+		if href != "" {
+			file = path.Base(href)
+			target := uriutil.ResolveRelative(opfURI, href)
+			if doc, ok := spineContent(files, target); ok {
+				title = cmp.Or(spineTitles.title(doc), title)
+			}
+		}
+	}
+
+	name = strconv.Itoa(position) + ". " + cmp.Or(title, "itemref")
+	if file != "" {
+		name += " (" + file + ")"
+	}
+	detail = "linear"
+	if itemref.Attr("linear") == "no" {
+		name += " (non-linear)"
+		detail = "non-linear"
+	}
+	if mediaType != "" {
+		detail = mediaType + ", " + detail
+	}
+	return name, detail
+}
+
+// maxCachedTitles bounds titleCache, which is emptied when it fills.
+const maxCachedTitles = 4096
+
+// titleCache holds the titles of content documents by a hash of their
+// content, so outlining a large spine reparses only documents that
+// changed. It is safe for concurrent use.
+type titleCache struct {
+	mu     sync.Mutex
+	titles map[[sha256.Size]byte]string
+}
+
+// spineTitles caches the titles shown in spine symbols.
+var spineTitles = &titleCache{}
+
+// title returns the title of the content document content: the text of
+// its head title or, without one, of its first heading.
+func (c *titleCache) title(content []byte) string {
+	key := sha256.Sum256(content)
+	c.mu.Lock()
+	title, ok := c.titles[key]
+	c.mu.Unlock()
+	if ok {
+		return title
+	}
+
+	title = documentTitle(content)
+	c.mu.Lock()
+	if c.titles == nil || len(c.titles) >= maxCachedTitles {
+		c.titles = make(map[[sha256.Size]byte]string)
+	}
+	c.titles[key] = title
+	c.mu.Unlock()
+	return title
+}
+
+// documentTitle returns the whitespace-collapsed text of the head title of
+// content, or of its first heading when the title is missing or empty.
+func documentTitle(content []byte) string {
+	root, _ := parser.Parse(content)
+	if head := root.FindFirst("head"); head != nil {
+		if title := head.FindFirst("title"); title != nil {
+			if text := strings.Join(strings.Fields(title.Text()), " "); text != "" {
+				return text
+			}
+		}
+	}
+	if heading := firstHeading(root); heading != nil {
+		return strings.Join(strings.Fields(heading.Text()), " ")
+	}
+	return ""
+}
+
+// firstHeading returns the first h1 to h6 element under node in document
+// order, or nil.
+func firstHeading(node *parser.XMLNode) *parser.XMLNode {
+	for _, child := range node.Children {
+		if len(child.Local) == 2 && child.Local[0] == 'h' &&
+			child.Local[1] >= '1' && child.Local[1] <= '6' {
+			return child
+		}
+		if heading := firstHeading(child); heading != nil {
+			return heading
+		}
+	}
+	return nil
+}
+
 func xhtmlSymbols(content []byte) []DocumentSymbol {
 	root, diags := parser.Parse(content)
 	if len(diags) > 0 {
@@ -119,8 +247,11 @@ func xhtmlSymbols(content []byte) []DocumentSymbol {
 	headings := []string{"h1", "h2", "h3", "h4", "h5", "h6"}
 	for _, h := range headings {
 		for _, node := range root.FindAll(h) {
-			text := strings.TrimSpace(node.CharData)
-			if text == "" {
+			text := node.CharData
+			if !node.PreservesSpace() {
+				text = strings.TrimSpace(text)
+			}
+			if strings.TrimSpace(text) == "" {
 				text = "<" + h + ">"
 			}
 			sym := nodeSymbol(node, text, SymbolKindString, content)
@@ -176,3 +307,13 @@ func nodeSymbol(
 		SelectionRange: Range{Start: lp, End: lp},
 	}
 }
+
+// nodeRange returns the range of node from its start tag to the end of its
+// end tag, or a point at its start tag when its end is unknown.
+func nodeRange(node *parser.XMLNode, content []byte) Range {
+	start := lspPos(epub.ByteOffsetToPosition(content, int(node.Offset)))
+	if node.End <= node.Offset {
+		return Range{Start: start, End: start}
+	}
+	return Range{Start: start, End: lspPos(epub.ByteOffsetToPosition(content, int(node.End)))}
+}
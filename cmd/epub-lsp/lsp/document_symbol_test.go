@@ -1,6 +1,7 @@
 package lsp
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/toba/epub-lsp/internal/epub"
@@ -28,7 +29,7 @@ func TestHandleDocumentSymbol_OPF(t *testing.T) {
 		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
 	})
 
-	resp := HandleDocumentSymbol(data, ws)
+	resp := HandleDocumentSymbol(t.Context(), data, ws)
 	symbols := unmarshalResult[[]DocumentSymbol](t, resp)
 
 	// Expect 3 top-level symbols: metadata, manifest, spine
@@ -60,6 +61,69 @@ func TestHandleDocumentSymbol_OPF(t *testing.T) {
 	}
 }
 
+func TestHandleDocumentSymbol_SpineTitles(t *testing.T) {
+	ws := newMockWorkspace()
+	opfContent := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <manifest>
+    <item id="id_x7f3" href="text/ch1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="id_a01" href="text/ch2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="gone" href="text/missing.xhtml" media-type="application/xhtml+xml"/>
+    <item id="notes" href="text/notes.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="id_x7f3"/>
+    <itemref idref="id_a01"/>
+    <itemref idref="gone"/>
+    <itemref idref="notes" linear="no"/>
+  </spine>
+</package>`)
+	ws.files["file:///book/OEBPS/content.opf"] = opfContent
+	ws.fileTypes["file:///book/OEBPS/content.opf"] = epub.FileTypeOPF
+	ws.files["file:///book/OEBPS/text/ch1.xhtml"] = []byte(
+		`<html><head><title>The  Long
+Winter</title></head><body><h1>Chapter One</h1></body></html>`)
+	ws.files["file:///book/OEBPS/text/ch2.xhtml"] = []byte(
+		`<html><head><title> </title></head><body><section><h2>Thaw</h2></section></body></html>`)
+	ws.files["file:///book/OEBPS/text/notes.xhtml"] = []byte(
+		`<html><head><title>Notes</title></head><body/></html>`)
+
+	data := makeRequest(t, 1, MethodDocumentSymbol, DocumentSymbolParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/OEBPS/content.opf"},
+	})
+	symbols := unmarshalResult[[]DocumentSymbol](t, HandleDocumentSymbol(t.Context(), data, ws))
+	spine := symbols[len(symbols)-1]
+	if spine.Name != "spine" {
+		t.Fatalf("expected the spine last, got %q", spine.Name)
+	}
+
+	want := []struct{ name, detail string }{
+		{"1. The Long Winter (ch1.xhtml)", "application/xhtml+xml, linear"},
+		{"2. Thaw (ch2.xhtml)", "application/xhtml+xml, linear"},
+		{"3. gone (missing.xhtml)", "application/xhtml+xml, linear"},
+		{"4. Notes (notes.xhtml) (non-linear)", "application/xhtml+xml, non-linear"},
+	}
+	if len(spine.Children) != len(want) {
+		t.Fatalf("expected %d spine symbols, got %d", len(want), len(spine.Children))
+	}
+	for i, w := range want {
+		got := spine.Children[i]
+		if got.Name != w.name || got.Detail != w.detail {
+			t.Errorf("spine symbol %d = %q (%q), want %q (%q)",
+				i, got.Name, got.Detail, w.name, w.detail)
+		}
+	}
+
+	// The range spans the whole itemref element
+	first := spine.Children[0].Range
+	start := findSubstring(opfContent, `<itemref idref="id_x7f3"/>`)
+	end := start + len(`<itemref idref="id_x7f3"/>`)
+	if first.Start != lspPos(epub.ByteOffsetToPosition(opfContent, start)) ||
+		first.End != lspPos(epub.ByteOffsetToPosition(opfContent, end)) {
+		t.Errorf("expected the range to span the itemref, got %+v", first)
+	}
+}
+
 func TestHandleDocumentSymbol_XHTML(t *testing.T) {
 	ws := newMockWorkspace()
 	xhtmlContent := []byte(`<?xml version="1.0"?>
@@ -77,7 +141,7 @@ func TestHandleDocumentSymbol_XHTML(t *testing.T) {
 		TextDocument: TextDocumentIdentifier{Uri: "file:///book/chapter1.xhtml"},
 	})
 
-	resp := HandleDocumentSymbol(data, ws)
+	resp := HandleDocumentSymbol(t.Context(), data, ws)
 	symbols := unmarshalResult[[]DocumentSymbol](t, resp)
 
 	if len(symbols) != 3 {
@@ -89,6 +153,36 @@ func TestHandleDocumentSymbol_XHTML(t *testing.T) {
 	}
 }
 
+func TestHandleDocumentSymbol_PreservedHeadingText(t *testing.T) {
+	ws := newMockWorkspace()
+	ws.files["file:///book/poem.xhtml"] = []byte(`<?xml version="1.0"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<body>
+  <section xml:space="preserve">
+    <h1>  Ode  </h1>
+    <h2 xml:space="default">  Stanza  </h2>
+  </section>
+  <h2>  Notes  </h2>
+</body>
+</html>`)
+	ws.fileTypes["file:///book/poem.xhtml"] = epub.FileTypeXHTML
+
+	data := makeRequest(t, 1, MethodDocumentSymbol, DocumentSymbolParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/poem.xhtml"},
+	})
+	symbols := unmarshalResult[[]DocumentSymbol](t,
+		HandleDocumentSymbol(t.Context(), data, ws))
+
+	var names []string
+	for _, sym := range symbols {
+		names = append(names, sym.Name)
+	}
+	want := []string{"  Ode  ", "Stanza", "Notes"}
+	if !slices.Equal(names, want) {
+		t.Errorf("expected %q, got %q", want, names)
+	}
+}
+
 func TestHandleDocumentSymbol_CSS(t *testing.T) {
 	ws := newMockWorkspace()
 	cssContent := []byte(`@charset "utf-8";
@@ -103,7 +197,7 @@ body { color: red; }`)
 		TextDocument: TextDocumentIdentifier{Uri: "file:///book/style.css"},
 	})
 
-	resp := HandleDocumentSymbol(data, ws)
+	resp := HandleDocumentSymbol(t.Context(), data, ws)
 	symbols := unmarshalResult[[]DocumentSymbol](t, resp)
 
 	if len(symbols) < 2 {
@@ -120,7 +214,7 @@ func TestHandleDocumentSymbol_NoContent(t *testing.T) {
 		TextDocument: TextDocumentIdentifier{Uri: "file:///nonexistent.opf"},
 	})
 
-	resp := HandleDocumentSymbol(data, ws)
+	resp := HandleDocumentSymbol(t.Context(), data, ws)
 	symbols := unmarshalResult[[]DocumentSymbol](t, resp)
 
 	if len(symbols) != 0 {
@@ -0,0 +1,63 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/toba/epub-lsp/internal/epub/export"
+	"github.com/toba/epub-lsp/internal/epub/stats"
+)
+
+// ExportTextOptions is the argument of CommandExportText.
+type ExportTextOptions struct {
+	// Format is "text" or "markdown". When empty, plain text is written.
+	Format export.Format `json:"format"`
+	// Output is the file to write, relative to the workspace root.
+	Output string `json:"output"`
+}
+
+// ExportedChapter is the word count of one spine item written by
+// CommandExportText.
+type ExportedChapter struct {
+	URI   string `json:"uri"`
+	Words int    `json:"words"`
+}
+
+// ExportTextResult is the result of CommandExportText: the path written
+// and the word count of each chapter in reading order.
+type ExportTextResult struct {
+	Output   string            `json:"output"`
+	Chapters []ExportedChapter `json:"chapters"`
+}
+
+// exportText renders the linear spine items in reading order into
+// opts.Output, resolved against the workspace root when relative.
+// Workspace content is used over the files on disk, so unsaved edits are
+// included; spine items that cannot be read are left out.
+func exportText(ws WorkspaceReader, opts ExportTextOptions) (ExportTextResult, error) {
+	output := opts.Output
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(ws.GetRootPath(), output)
+	}
+
+	files := ws.GetAllFiles()
+	result := ExportTextResult{Output: output, Chapters: []ExportedChapter{}}
+	var chapters [][]byte
+	for _, uri := range linearSpineURIs(ws) {
+		content, ok := spineContent(files, uri)
+		if !ok {
+			continue
+		}
+		chapters = append(chapters, content)
+		result.Chapters = append(result.Chapters, ExportedChapter{
+			URI:   uri,
+			Words: stats.Document(content).Words,
+		})
+	}
+
+	text := export.Book(chapters, opts.Format)
+	if err := os.WriteFile(output, []byte(text), 0o644); err != nil {
+		return ExportTextResult{}, err
+	}
+	return result, nil
+}
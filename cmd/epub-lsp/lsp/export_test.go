@@ -0,0 +1,77 @@
+package lsp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandleExecuteCommand_ExportText(t *testing.T) {
+	ws, _ := statsWorkspace()
+	ws.rootPath = t.TempDir()
+	ws.files["file:///book/OEBPS/text/ch1.xhtml"] = []byte(
+		`<html><body><h1>One</h1><p>First  words.</p><img src="a.png" alt="A map"/></body></html>`)
+	ws.manifest.Spine[1].Linear = false
+
+	for format, want := range map[string]string{
+		"text":     "One\n\nFirst words.\n\n[image: A map]\n\n日本語です\n",
+		"markdown": "# One\n\nFirst words.\n\n[image: A map]\n\n日本語です\n",
+	} {
+		data := makeRequest(t, 1, MethodExecuteCommand, ExecuteCommandParams{
+			Command: CommandExportText,
+			Arguments: []json.RawMessage{
+				json.RawMessage(`{"format":"` + format + `","output":"book.txt"}`),
+			},
+		})
+		resp, _ := HandleExecuteCommand(t.Context(), data, ws)
+		result := unmarshalResult[ExportTextResult](t, resp)
+
+		if want := filepath.Join(ws.rootPath, "book.txt"); result.Output != want {
+			t.Errorf("%s: output = %q, want %q", format, result.Output, want)
+		}
+		got, err := os.ReadFile(result.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: exported %q, want %q", format, got, want)
+		}
+
+		// The non-linear second chapter is left out
+		wantChapters := []ExportedChapter{
+			{URI: "file:///book/OEBPS/text/ch1.xhtml", Words: 3},
+			{URI: "file:///book/OEBPS/text/ch3.xhtml", Words: 3},
+		}
+		if len(result.Chapters) != len(wantChapters) {
+			t.Fatalf("%s: chapters = %+v, want %+v", format, result.Chapters, wantChapters)
+		}
+		for i, w := range wantChapters {
+			if result.Chapters[i] != w {
+				t.Errorf("%s: chapter %d = %+v, want %+v", format, i, result.Chapters[i], w)
+			}
+		}
+	}
+}
+
+func TestHandleExecuteCommand_ExportTextInvalid(t *testing.T) {
+	ws, _ := statsWorkspace()
+	for name, args := range map[string]string{
+		"unknown format": `{"format":"html","output":"book.html"}`,
+		"no output":      `{"format":"markdown"}`,
+	} {
+		data := makeRequest(t, 1, MethodExecuteCommand, ExecuteCommandParams{
+			Command:   CommandExportText,
+			Arguments: []json.RawMessage{json.RawMessage(args)},
+		})
+		response, _ := HandleExecuteCommand(t.Context(), data, ws)
+
+		var resp ResponseMessage[any]
+		if err := json.Unmarshal(response, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error == nil || resp.Error.Code != ErrorInvalidParams {
+			t.Errorf("%s: expected an invalid params error, got %s", name, response)
+		}
+	}
+}
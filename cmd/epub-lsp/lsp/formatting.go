@@ -1,78 +1,193 @@
 package lsp
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"path/filepath"
 	"strings"
 
+	"github.com/toba/epub-lsp/epublint"
+	"github.com/toba/epub-lsp/internal/editorconfig"
 	"github.com/toba/epub-lsp/internal/epub"
-	"github.com/toba/epub-lsp/internal/epub/formatter"
+	"github.com/toba/lsp/pathutil"
 	"github.com/toba/lsp/position"
 )
 
-// HandleFormatting processes textDocument/formatting requests.
-func HandleFormatting(data []byte, ws WorkspaceReader) []byte {
+// HandleFormatting processes textDocument/formatting requests. When the
+// formatted document would differ from the original in more than layout,
+// no edits are returned and a window/logMessage notification says why.
+func HandleFormatting(ctx context.Context, data []byte, ws WorkspaceReader) ([]byte, [][]byte) {
 	var req RequestMessage[DocumentFormattingParams]
 	if err := json.Unmarshal(data, &req); err != nil {
-		slog.Error("error unmarshalling formatting: " + err.Error())
-		return marshalResponse(req.Id, []TextEdit{})
+		Logger(ctx).Error("error unmarshalling formatting: " + err.Error())
+		return marshalResponse(req.Id, []TextEdit{}), nil
 	}
 
 	uri := req.Params.TextDocument.Uri
 	content := ws.GetContent(uri)
 	if content == nil {
-		return marshalResponse(req.Id, []TextEdit{})
+		return marshalResponse(req.Id, []TextEdit{}), nil
 	}
 
-	fileType := ws.GetFileType(uri)
-	indent := "  "
-	if !req.Params.Options.InsertSpaces {
-		indent = "\t"
-	} else if req.Params.Options.TabSize > 0 {
-		indent = ""
-		var indentSb31 strings.Builder
-		for range req.Params.Options.TabSize {
-			indentSb31.WriteString(" ")
-		}
-		indent += indentSb31.String()
+	layout := resolveLayout(uri, ws.GetFileType(uri), req.Params.Options,
+		ws.GetSettings(), ws.GetRootPath())
+	edit, err := formatDocumentEdit(uri, content, layout.indent, proseWrap(ws),
+		layout.finalNewline)
+	if errors.Is(err, epublint.ErrContentChanged) {
+		message := "formatting of " + uri + " skipped by a safety check: " + err.Error()
+		Logger(ctx).Warn(message)
+		return marshalResponse(req.Id, []TextEdit{}),
+			[][]byte{LogMessageNotification(MessageTypeWarning, message)}
 	}
-
-	var formatted string
-	var err error
-
-	switch fileType {
-	case epub.FileTypeOPF, epub.FileTypeXHTML, epub.FileTypeNav:
-		formatted, err = formatter.FormatXML(content, indent)
-	case epub.FileTypeCSS:
-		formatted, err = formatter.FormatCSS(content, indent)
-	default:
-		return marshalResponse(req.Id, []TextEdit{})
+	if err != nil {
+		Logger(ctx).Warn("formatting failed: " + err.Error())
+		return marshalResponse(req.Id, []TextEdit{}), nil
+	}
+	if edit == nil {
+		return marshalResponse(req.Id, []TextEdit{}), nil
 	}
 
+	edits := []TextEdit{*edit}
+	newRangeClamper(ctx, ws, MethodFormatting).edits(uri, edits)
+	return marshalResponse(req.Id, edits), nil
+}
+
+// formatDocumentEdit formats content with indent, laying out prose as
+// proseWrap says, and returns an edit replacing the entire document, or nil
+// when it is already formatted. Unless finalNewline is set, content without
+// a final newline is left without one.
+func formatDocumentEdit(
+	uri string,
+	content []byte,
+	indent, proseWrap string,
+	finalNewline bool,
+) (*TextEdit, error) {
+	formatted, err := epublint.FormatWrapped(uri, content, indent, proseWrap)
 	if err != nil {
-		slog.Warn("formatting failed: " + err.Error())
-		return marshalResponse(req.Id, []TextEdit{})
+		return nil, err
+	}
+	if !finalNewline && !bytes.HasSuffix(content, []byte("\n")) {
+		formatted = strings.TrimRight(formatted, "\r\n")
 	}
-
 	if formatted == string(content) {
-		return marshalResponse(req.Id, []TextEdit{})
+		return nil, nil
 	}
 
-	// Replace entire document
 	endPos := epub.ByteOffsetToPosition(content, len(content))
-
-	edits := []TextEdit{
-		{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End: Position{
-					Line:      position.IntToUint(endPos.Line),
-					Character: position.IntToUint(endPos.Character),
-				},
+	return &TextEdit{
+		Range: Range{
+			Start: Position{Line: 0, Character: 0},
+			End: Position{
+				Line:      position.IntToUint(endPos.Line),
+				Character: position.IntToUint(endPos.Character),
 			},
-			NewText: formatted,
 		},
+		NewText: formatted,
+	}, nil
+}
+
+// proseWrap returns the proseWrap setting, empty when there are no
+// settings.
+func proseWrap(ws WorkspaceReader) string {
+	if settings := ws.GetSettings(); settings != nil {
+		return settings.ProseWrap
+	}
+	return ""
+}
+
+// defaultIndent indents formatted documents when neither the client, the
+// settings, nor .editorconfig says how.
+const defaultIndent = "  "
+
+// layout is how a formatted document is indented and ended.
+type layout struct {
+	indent       string
+	finalNewline bool
+}
+
+// resolveLayout decides the layout of the document at uri. Each property
+// comes from the first of these that sets it: the client's options, the
+// format settings for the file type, the .editorconfig file at the
+// workspace root, and the defaults of two-space indentation and a final
+// newline.
+func resolveLayout(
+	uri string,
+	fileType epub.FileType,
+	options FormattingOptions,
+	settings *ServerSettings,
+	root string,
+) layout {
+	result := layout{indent: clientIndent(options), finalNewline: true}
+	if result.indent == "" && settings != nil {
+		result.indent = settingsIndent(settings.Format, fileType)
+	}
+	finalNewline := options.InsertFinalNewline
+	if (result.indent == "" || finalNewline == nil) && root != "" {
+		props := editorConfigProperties(uri, root)
+		if indent, ok := props.Indent(); ok && result.indent == "" {
+			result.indent = indent
+		}
+		if value, ok := props.FinalNewline(); ok && finalNewline == nil {
+			finalNewline = &value
+		}
+	}
+	if result.indent == "" {
+		result.indent = defaultIndent
 	}
+	if finalNewline != nil {
+		result.finalNewline = *finalNewline
+	}
+	return result
+}
+
+// clientIndent returns the indentation the client's options ask for, or ""
+// when they set neither tabSize nor insertSpaces.
+func clientIndent(options FormattingOptions) string {
+	switch {
+	case options.InsertSpaces != nil && !*options.InsertSpaces:
+		return "\t"
+	case options.TabSize != nil && *options.TabSize > 0:
+		return strings.Repeat(" ", *options.TabSize)
+	case options.InsertSpaces != nil || options.TabSize != nil:
+		return defaultIndent
+	}
+	return ""
+}
 
-	return marshalResponse(req.Id, edits)
+// settingsIndent returns the indent the format settings give fileType, or
+// "" when they give none or one that is not all spaces and tabs.
+func settingsIndent(format FormatSettings, fileType epub.FileType) string {
+	var indent string
+	switch fileType {
+	case epub.FileTypeOPF:
+		indent = format.OPF.Indent
+	case epub.FileTypeXHTML, epub.FileTypeNav:
+		indent = format.XHTML.Indent
+	case epub.FileTypeCSS:
+		indent = format.CSS.Indent
+	}
+	if strings.Trim(indent, " \t") != "" {
+		return ""
+	}
+	return indent
+}
+
+// editorConfigProperties returns the properties the .editorconfig file at
+// root gives the file at uri, empty when there is no such file or uri lies
+// outside root.
+func editorConfigProperties(uri, root string) editorconfig.Properties {
+	file, err := editorconfig.Load(root)
+	if err != nil {
+		slog.Warn("error reading " + editorconfig.FileName + ": " + err.Error())
+		return editorconfig.Properties{}
+	}
+	rel, err := filepath.Rel(root, pathutil.URIToFilePath(uri))
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return editorconfig.Properties{}
+	}
+	return file.Properties(filepath.ToSlash(rel))
 }
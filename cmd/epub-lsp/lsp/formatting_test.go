@@ -1,9 +1,14 @@
 package lsp
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/lsp/pathutil"
 )
 
 func TestHandleFormatting_XML(t *testing.T) {
@@ -17,12 +22,12 @@ func TestHandleFormatting_XML(t *testing.T) {
 	data := makeRequest(t, 1, MethodFormatting, DocumentFormattingParams{
 		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
 		Options: FormattingOptions{
-			TabSize:      2,
-			InsertSpaces: true,
+			TabSize:      new(2),
+			InsertSpaces: new(true),
 		},
 	})
 
-	resp := HandleFormatting(data, ws)
+	resp, _ := HandleFormatting(t.Context(), data, ws)
 	edits := unmarshalResult[[]TextEdit](t, resp)
 
 	if len(edits) != 1 {
@@ -43,12 +48,12 @@ func TestHandleFormatting_CSS(t *testing.T) {
 	data := makeRequest(t, 1, MethodFormatting, DocumentFormattingParams{
 		TextDocument: TextDocumentIdentifier{Uri: "file:///book/style.css"},
 		Options: FormattingOptions{
-			TabSize:      2,
-			InsertSpaces: true,
+			TabSize:      new(2),
+			InsertSpaces: new(true),
 		},
 	})
 
-	resp := HandleFormatting(data, ws)
+	resp, _ := HandleFormatting(t.Context(), data, ws)
 	edits := unmarshalResult[[]TextEdit](t, resp)
 
 	if len(edits) != 1 {
@@ -61,12 +66,12 @@ func TestHandleFormatting_NoContent(t *testing.T) {
 	data := makeRequest(t, 1, MethodFormatting, DocumentFormattingParams{
 		TextDocument: TextDocumentIdentifier{Uri: "file:///nonexistent.css"},
 		Options: FormattingOptions{
-			TabSize:      2,
-			InsertSpaces: true,
+			TabSize:      new(2),
+			InsertSpaces: new(true),
 		},
 	})
 
-	resp := HandleFormatting(data, ws)
+	resp, _ := HandleFormatting(t.Context(), data, ws)
 	edits := unmarshalResult[[]TextEdit](t, resp)
 
 	if len(edits) != 0 {
@@ -83,14 +88,197 @@ func TestHandleFormatting_TabIndent(t *testing.T) {
 	data := makeRequest(t, 1, MethodFormatting, DocumentFormattingParams{
 		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
 		Options: FormattingOptions{
-			InsertSpaces: false,
+			InsertSpaces: new(false),
 		},
 	})
 
-	resp := HandleFormatting(data, ws)
+	resp, _ := HandleFormatting(t.Context(), data, ws)
 	edits := unmarshalResult[[]TextEdit](t, resp)
 
 	if len(edits) != 1 {
 		t.Fatalf("expected 1 edit, got %d", len(edits))
 	}
 }
+
+func TestHandleFormatting_ProseWrap(t *testing.T) {
+	ws := newMockWorkspace()
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body><p>One. <em>Two.</em></p></body></html>`)
+	ws.files["file:///book/ch1.xhtml"] = content
+	ws.fileTypes["file:///book/ch1.xhtml"] = epub.FileTypeXHTML
+	ws.settings = &ServerSettings{ProseWrap: "sentence"}
+
+	data := makeRequest(t, 1, MethodFormatting, DocumentFormattingParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/ch1.xhtml"},
+		Options:      FormattingOptions{TabSize: new(2), InsertSpaces: new(true)},
+	})
+	resp, _ := HandleFormatting(t.Context(), data, ws)
+	edits := unmarshalResult[[]TextEdit](t, resp)
+
+	want := `<html xmlns="http://www.w3.org/1999/xhtml">
+  <body>
+    <p>
+      One.
+      <em>Two.</em>
+    </p>
+  </body>
+</html>
+`
+	if len(edits) != 1 || edits[0].NewText != want {
+		t.Errorf("expected sentence-wrapped paragraph, got %+v", edits)
+	}
+}
+
+func TestHandleFormatting_SafetyCheck(t *testing.T) {
+	ws := newMockWorkspace()
+	// The formatter puts the text around the comment on lines of its own,
+	// which would split "xy" into "x y"
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body><div>x<!-- note -->y</div></body></html>`)
+	ws.files["file:///book/ch1.xhtml"] = content
+	ws.fileTypes["file:///book/ch1.xhtml"] = epub.FileTypeXHTML
+
+	data := makeRequest(t, 1, MethodFormatting, DocumentFormattingParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/ch1.xhtml"},
+		Options:      FormattingOptions{TabSize: new(2), InsertSpaces: new(true)},
+	})
+	resp, notifications := HandleFormatting(t.Context(), data, ws)
+
+	if edits := unmarshalResult[[]TextEdit](t, resp); len(edits) != 0 {
+		t.Errorf("expected no edits, got %+v", edits)
+	}
+	if len(notifications) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(notifications))
+	}
+	var msg NotificationMessage[LogMessageParams]
+	if err := json.Unmarshal(notifications[0], &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Method != MethodLogMessage || msg.Params.Type != MessageTypeWarning ||
+		!strings.Contains(msg.Params.Message, "safety check") ||
+		!strings.Contains(msg.Params.Message, "/html/body/div: text") {
+		t.Errorf("unexpected notification %+v", msg)
+	}
+}
+
+// writeEditorConfig returns a temporary workspace root holding an
+// .editorconfig file with content.
+func writeEditorConfig(t *testing.T, content string) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".editorconfig"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestResolveLayout(t *testing.T) {
+	root := writeEditorConfig(t, "[*]\nindent_size = 4\ninsert_final_newline = false\n"+
+		"[*.css]\nindent_style = tab\n")
+	opfURI := pathutil.FilePathToURI(filepath.Join(root, "OEBPS", "content.opf"))
+	cssURI := pathutil.FilePathToURI(filepath.Join(root, "OEBPS", "book.css"))
+	settings := &ServerSettings{Format: FormatSettings{
+		OPF:   FileFormatSettings{Indent: " "},
+		XHTML: FileFormatSettings{Indent: "\t"},
+		CSS:   FileFormatSettings{Indent: "two"},
+	}}
+
+	cases := []struct {
+		name     string
+		uri      string
+		fileType epub.FileType
+		options  FormattingOptions
+		settings *ServerSettings
+		root     string
+		want     layout
+	}{
+		{
+			"client over settings and editorconfig",
+			opfURI, epub.FileTypeOPF,
+			FormattingOptions{TabSize: new(3), InsertSpaces: new(true),
+				InsertFinalNewline: new(true)},
+			settings, root,
+			layout{indent: "   ", finalNewline: true},
+		},
+		{
+			"client tabs",
+			opfURI, epub.FileTypeOPF,
+			FormattingOptions{TabSize: new(4), InsertSpaces: new(false)},
+			settings, root,
+			layout{indent: "\t", finalNewline: false},
+		},
+		{
+			"settings over editorconfig",
+			opfURI, epub.FileTypeOPF, FormattingOptions{}, settings, root,
+			layout{indent: " ", finalNewline: false},
+		},
+		{
+			"settings for navigation documents",
+			opfURI, epub.FileTypeNav, FormattingOptions{}, settings, root,
+			layout{indent: "\t", finalNewline: false},
+		},
+		{
+			"editorconfig when the setting is not whitespace",
+			cssURI, epub.FileTypeCSS, FormattingOptions{}, settings, root,
+			layout{indent: "\t", finalNewline: false},
+		},
+		{
+			"editorconfig without settings",
+			opfURI, epub.FileTypeOPF, FormattingOptions{}, nil, root,
+			layout{indent: "    ", finalNewline: false},
+		},
+		{
+			"defaults",
+			opfURI, epub.FileTypeOPF, FormattingOptions{}, nil, "",
+			layout{indent: "  ", finalNewline: true},
+		},
+		{
+			"file outside the root",
+			"file:///elsewhere/content.opf", epub.FileTypeOPF,
+			FormattingOptions{}, nil, root,
+			layout{indent: "  ", finalNewline: true},
+		},
+	}
+	for _, tc := range cases {
+		got := resolveLayout(tc.uri, tc.fileType, tc.options, tc.settings, tc.root)
+		if got != tc.want {
+			t.Errorf("%s: layout = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestHandleFormatting_EditorConfig(t *testing.T) {
+	root := writeEditorConfig(t, "[*.css]\nindent_style = tab\ninsert_final_newline = false\n"+
+		"[*.opf]\nindent_size = 4\n")
+	cssURI := pathutil.FilePathToURI(filepath.Join(root, "book.css"))
+	opfURI := pathutil.FilePathToURI(filepath.Join(root, "content.opf"))
+
+	ws := newMockWorkspace()
+	ws.rootPath = root
+	ws.settings = &ServerSettings{Format: FormatSettings{
+		OPF: FileFormatSettings{Indent: "\t"},
+	}}
+	ws.files[cssURI] = []byte(`body{color:red}`)
+	ws.fileTypes[cssURI] = epub.FileTypeCSS
+	ws.files[opfURI] = []byte(
+		`<?xml version="1.0"?><package><metadata></metadata></package>`)
+	ws.fileTypes[opfURI] = epub.FileTypeOPF
+
+	format := func(uri string) string {
+		t.Helper()
+		// No options, as some clients send
+		data := []byte(`{"jsonrpc":"2.0","id":1,"method":"textDocument/formatting",` +
+			`"params":{"textDocument":{"uri":"` + uri + `"}}}`)
+		resp, _ := HandleFormatting(t.Context(), data, ws)
+		edits := unmarshalResult[[]TextEdit](t, resp)
+		if len(edits) != 1 {
+			t.Fatalf("expected 1 edit for %s, got %d", uri, len(edits))
+		}
+		return edits[0].NewText
+	}
+
+	if got := format(cssURI); got != "body {\n\tcolor: red;\n}" {
+		t.Errorf("CSS formatted as %q, want tabs and no final newline", got)
+	}
+	if got := format(opfURI); !strings.Contains(got, "\n\t<metadata") {
+		t.Errorf("OPF formatted as %q, want the tab from settings", got)
+	}
+}
@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// HealthResult is the result of the $/epubLsp/health request, a summary of
+// the server's state for editor status indicators.
+type HealthResult struct {
+	Version string `json:"version"`
+	// Files counts the files the server holds.
+	Files int `json:"files"`
+	// FileTypes counts the held files by type name, such as "XHTML".
+	FileTypes map[string]int `json:"fileTypes"`
+	// LastValidation describes the latest validation pass, or is null
+	// before the first.
+	LastValidation *ValidationMetrics `json:"lastValidation"`
+	// Diagnostics counts the published diagnostics by severity.
+	Diagnostics SeverityCounts `json:"diagnostics"`
+	Memory      MemoryStats    `json:"memory"`
+}
+
+// ValidationMetrics describes one validation pass.
+type ValidationMetrics struct {
+	// Finished is when the pass ended.
+	Finished time.Time `json:"finished"`
+	// DurationMs is how long the pass took, in milliseconds.
+	DurationMs float64 `json:"durationMs"`
+	// Files counts the files the pass validated.
+	Files int `json:"files"`
+}
+
+// SeverityCounts counts diagnostics by severity.
+type SeverityCounts struct {
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+	Infos    int `json:"infos"`
+	Hints    int `json:"hints"`
+}
+
+// Add counts diags.
+func (c *SeverityCounts) Add(diags []epub.Diagnostic) {
+	for _, d := range diags {
+		switch d.Severity {
+		case epub.SeverityError:
+			c.Errors++
+		case epub.SeverityWarning:
+			c.Warnings++
+		case epub.SeverityInfo:
+			c.Infos++
+		default:
+			c.Hints++
+		}
+	}
+}
+
+// MemoryStats is the part of runtime.MemStats worth showing, in bytes.
+type MemoryStats struct {
+	HeapAlloc uint64 `json:"heapAlloc"`
+	HeapSys   uint64 `json:"heapSys"`
+	Sys       uint64 `json:"sys"`
+	NumGC     uint32 `json:"numGC"`
+}
+
+// ReadMemoryStats returns the current memory statistics of the process.
+func ReadMemoryStats() MemoryStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return MemoryStats{
+		HeapAlloc: m.HeapAlloc,
+		HeapSys:   m.HeapSys,
+		Sys:       m.Sys,
+		NumGC:     m.NumGC,
+	}
+}
+
+// ProcessHealthRequest answers a $/epubLsp/health request with result.
+func ProcessHealthRequest(requestId ID, result HealthResult) []byte {
+	return marshalResponse(requestId, result)
+}
@@ -23,6 +23,27 @@ func marshalResponse[T any](id ID, result T) []byte {
 	return data
 }
 
+// marshalErrorResponse builds a JSON-RPC error response.
+func marshalErrorResponse(id ID, code int, message string) []byte {
+	res := ResponseMessage[any]{
+		JsonRpc: JSONRPCVersion,
+		Id:      id,
+		Error:   &ResponseError{Code: code, Message: message},
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		slog.Error("error marshalling error response: " + err.Error())
+		return nil
+	}
+	return data
+}
+
+// cancelledResponse builds the error response for a request the client
+// cancelled before it finished.
+func cancelledResponse(id ID) []byte {
+	return marshalErrorResponse(id, ErrorRequestCancelled, "request cancelled")
+}
+
 // lspPos converts an epub.Position to an lsp.Position.
 func lspPos(p epub.Position) Position {
 	return Position{
@@ -1,20 +1,25 @@
 package lsp
 
 import (
+	"bytes"
+	"cmp"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
 
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator/xhtml"
 )
 
 // HandleHover processes textDocument/hover requests.
-func HandleHover(data []byte, ws WorkspaceReader) []byte {
+func HandleHover(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
 	var req RequestMessage[HoverParams]
 	if err := json.Unmarshal(data, &req); err != nil {
-		slog.Error("error unmarshalling hover: " + err.Error())
+		Logger(ctx).Error("error unmarshalling hover: " + err.Error())
 		return marshalNullResponse(req.Id)
 	}
 
@@ -30,39 +35,146 @@ func HandleHover(data []byte, ws WorkspaceReader) []byte {
 		return marshalNullResponse(req.Id)
 	}
 
+	// Template actions are checked before parsing, since they often break
+	// well-formedness.
+	if hover, inBlock := hoverTemplate(content, offset); inBlock {
+		if hover == nil {
+			return marshalNullResponse(req.Id)
+		}
+		return marshalResponse(req.Id, hover)
+	}
+
+	fileType := ws.GetFileType(uri)
+
+	// Entity references are checked before parsing, since an undefined
+	// entity is itself a well-formedness error.
+	if fileType == epub.FileTypeXHTML || fileType == epub.FileTypeNav {
+		if hover := hoverEntity(content, offset); hover != nil {
+			return marshalResponse(req.Id, hover)
+		}
+	}
+
+	hover := hoverDocument(content, offset, fileType, ws)
+	if hover == nil {
+		hover = hoverDiagnostic(content, pos, ws.GetDiagnostics(uri))
+	}
+
+	if hover == nil {
+		return marshalNullResponse(req.Id)
+	}
+
+	return marshalResponse(req.Id, hover)
+}
+
+// hoverDocument returns hover content for the XML node at offset, or for
+// the media feature at offset in a stylesheet.
+func hoverDocument(
+	content []byte,
+	offset int,
+	fileType epub.FileType,
+	ws WorkspaceReader,
+) *Hover {
+	if fileType == epub.FileTypeCSS {
+		return hoverCSS(content, offset)
+	}
+
 	root, xmlDiags := parser.Parse(content)
 	if len(xmlDiags) > 0 {
-		return marshalNullResponse(req.Id)
+		return nil
 	}
 
 	result := parser.LocateAtPosition(root, content, offset)
 	if result == nil {
-		return marshalNullResponse(req.Id)
+		return nil
 	}
 
-	fileType := ws.GetFileType(uri)
-	var hover *Hover
-
 	switch fileType {
 	case epub.FileTypeOPF:
-		hover = hoverOPF(result, ws)
+		return hoverOPF(result, root, ws)
 	case epub.FileTypeXHTML, epub.FileTypeNav:
-		hover = hoverXHTML(result)
+		return hoverXHTML(result)
 	}
+	return nil
+}
 
-	if hover == nil {
-		return marshalNullResponse(req.Id)
+// hoverDiagnostic explains the first diagnostic whose range contains pos.
+// Zero-width diagnostics, which mark the start of an element, cover the
+// element's tag name.
+func hoverDiagnostic(
+	content []byte,
+	pos epub.Position,
+	diags []epub.Diagnostic,
+) *Hover {
+	for _, d := range diags {
+		if d.Explanation == "" {
+			continue
+		}
+
+		end := d.Range.End
+		if end == d.Range.Start {
+			end = tagNameEnd(content, d.Range.Start)
+		}
+		if comparePositions(pos, d.Range.Start) < 0 ||
+			comparePositions(pos, end) > 0 {
+			continue
+		}
+
+		var text strings.Builder
+		text.WriteString("**" + d.Code + "**: " + d.Message + "\n\n" + d.Explanation)
+		if url := epub.CodeURL(d.Code); url != "" {
+			text.WriteString("\n\n[Documentation](" + url + ")")
+		}
+		return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text.String()}}
 	}
+	return nil
+}
 
-	return marshalResponse(req.Id, hover)
+// tagNameEnd returns the position after the tag name of the element that
+// starts at start, or start itself when no tag begins there.
+func tagNameEnd(content []byte, start epub.Position) epub.Position {
+	offset := epub.PositionToByteOffset(content, start)
+	if offset < 0 || offset >= len(content) || content[offset] != '<' {
+		return start
+	}
+
+	end := offset + 1
+	for end < len(content) && isNameByte(content[end]) {
+		end++
+	}
+	return epub.ByteOffsetToPosition(content, end)
+}
+
+func isNameByte(c byte) bool {
+	return c == ':' || c == '-' || c == '_' || c == '.' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// comparePositions orders two positions, returning -1, 0, or 1.
+func comparePositions(a, b epub.Position) int {
+	if a.Line != b.Line {
+		return cmp.Compare(a.Line, b.Line)
+	}
+	return cmp.Compare(a.Character, b.Character)
 }
 
-func hoverOPF(result *parser.LocateResult, ws WorkspaceReader) *Hover {
+func hoverOPF(
+	result *parser.LocateResult,
+	root *parser.XMLNode,
+	ws WorkspaceReader,
+) *Hover {
 	node := result.Node
 
-	// <itemref idref="x"> → show manifest item details
-	if node.Local == "itemref" && result.Attr != nil && result.Attr.Local == "idref" &&
-		result.InValue {
+	// refines="#x" → show the refined element
+	if result.OnAttribute() && result.Attr.Local == "refines" &&
+		strings.HasPrefix(result.Attr.Value, "#") {
+		if target := findNodeByID(root, result.Attr.Value[1:]); target != nil {
+			return hoverRefinesTarget(target)
+		}
+	}
+
+	// <itemref idref="x">, <spine toc="x">, <item media-overlay="x">
+	// → show manifest item details
+	if result.OnAttribute() && isManifestIDRef(node.Local, result.Attr.Local) {
 		manifest := ws.GetManifest()
 		if manifest != nil {
 			for _, item := range manifest.Items {
@@ -79,18 +191,38 @@ func hoverOPF(result *parser.LocateResult, ws WorkspaceReader) *Hover {
 		}
 	}
 
-	// <meta property="schema:..."> → show property docs
+	// <spine> → show word counts and reading time for the book
+	if node.Local == "spine" && !result.OnAttribute() {
+		return hoverSpine(ws)
+	}
+
+	// <meta property="schema:...">, "media:...", or legacy <meta name="..."> → show docs
 	if node.Local == "meta" {
 		prop := node.Attr("property")
 		if doc, ok := schemaPropertyDocs[prop]; ok {
 			return &Hover{Contents: MarkupContent{Kind: "markdown", Value: doc}}
 		}
+		if doc, ok := mediaPropertyDocs[prop]; ok {
+			return &Hover{Contents: MarkupContent{Kind: "markdown", Value: doc}}
+		}
+		if doc, ok := legacyMetaDocs[node.Attr("name")]; ok {
+			return &Hover{Contents: MarkupContent{Kind: "markdown", Value: doc}}
+		}
+	}
+
+	// <link rel="..."> in metadata → show the rel value under the cursor
+	if result.InValue && node.Local == "link" && result.Attr.Local == "rel" &&
+		node.Parent != nil && node.Parent.Local == "metadata" {
+		value := result.Attr.Value
+		start, end := epub.TokenAt(value, result.ValueOffset)
+		if doc, ok := linkRelDocs[value[start:end]]; ok {
+			return &Hover{Contents: MarkupContent{Kind: "markdown", Value: doc}}
+		}
 	}
 
 	// epub:type values → show ARIA role mapping
-	if result.Attr != nil && result.Attr.Local == "type" &&
-		result.Attr.Space == epub.NSEpub &&
-		result.InValue {
+	if result.OnAttribute() && result.Attr.Local == "type" &&
+		result.Attr.Space == epub.NSEpub {
 		value := result.Attr.Value
 		// Check each token in the value
 		for token := range strings.FieldsSeq(value) {
@@ -110,20 +242,162 @@ func hoverOPF(result *parser.LocateResult, ws WorkspaceReader) *Hover {
 	return nil
 }
 
+// hoverRefinesTarget summarizes the element a refines attribute points at.
+func hoverRefinesTarget(target *parser.XMLNode) *Hover {
+	name := target.Local
+	if target.Space == epub.NSDC {
+		name = "dc:" + name
+	}
+
+	text := "**Refines** `<" + name + ` id="` + target.Attr("id") + `">`
+	if prop := target.Attr("property"); prop != "" {
+		text += "\n- **Property:** " + prop
+	}
+	if value := strings.TrimSpace(target.CharData); value != "" {
+		text += "\n- **Value:** " + value
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}}
+}
+
 func hoverXHTML(result *parser.LocateResult) *Hover {
 	// epub:type values
-	if result.Attr != nil && result.Attr.Local == "type" &&
-		result.Attr.Space == epub.NSEpub &&
-		result.InValue {
+	if result.OnAttribute() && result.Attr.Local == "type" &&
+		result.Attr.Space == epub.NSEpub {
 		for token := range strings.FieldsSeq(result.Attr.Value) {
 			if doc, ok := epubTypeDocs[token]; ok {
 				return &Hover{Contents: MarkupContent{Kind: "markdown", Value: doc}}
 			}
 		}
 	}
+
+	// dir attribute and <bdi>/<bdo> → explain bidirectional markup
+	if result.OnAttribute() {
+		if result.Attr.Local == "dir" && result.Attr.Space == "" {
+			return &Hover{Contents: MarkupContent{Kind: "markdown", Value: bidiDocs["dir"]}}
+		}
+	} else if name := result.Node.Local; name == "bdi" || name == "bdo" {
+		return &Hover{Contents: MarkupContent{Kind: "markdown", Value: bidiDocs[name]}}
+	}
 	return nil
 }
 
+// hoverTemplate explains the Go template token at offset, identified with
+// the semantic tokenizer. inBlock reports whether offset is within a
+// {{ ... }} action at all; strings, comments, and delimiters have no hover.
+func hoverTemplate(content []byte, offset int) (hover *Hover, inBlock bool) {
+	for _, block := range findTemplateBlocks(content) {
+		if offset < block.delimStart || offset >= block.delimEnd {
+			continue
+		}
+		if offset < block.innerStart || offset >= block.innerEnd {
+			return nil, true
+		}
+
+		lines := epub.NewLineIndex(content)
+		inner := string(content[block.innerStart:block.innerEnd])
+		for _, tok := range tokenizeInner(lines, block.innerStart, inner, nil) {
+			//nolint:gosec // token positions come from the line index
+			start := lines.Offset(epub.Position{Line: int(tok.line), Character: int(tok.startChar)})
+			end := start + int(tok.length) //nolint:gosec // length is a byte count
+			if offset < start || offset >= end {
+				continue
+			}
+			return templateTokenHover(string(content[start:end]), tok.tokenType), true
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+// templateTokenHover returns the documentation for a template token of the
+// given semantic token type.
+func templateTokenHover(word string, tokenType uint) *Hover {
+	var text string
+	switch tokenType {
+	case tokenKeyword, tokenFunction:
+		doc, ok := templateDocs[word]
+		if !ok {
+			doc = "**" + word + "**\n\nA function supplied to the template by the " +
+				"program that executes it."
+		}
+		text = doc
+	case tokenProperty:
+		text = "**" + word + "**\n\nField access on the current context (dot): the " +
+			"field, map key, or niladic method `" + word[1:] + "` of the value dot " +
+			"holds here."
+	case tokenVariable:
+		switch word {
+		case ".":
+			text = "**.** (dot)\n\nThe current context: the data passed to the " +
+				"template, or the value set by an enclosing `range` or `with`."
+		case "$":
+			text = "**$**\n\nThe data passed to the template when execution began."
+		default:
+			text = "**" + word + "**\n\nA template variable, declared with `" + word +
+				" := pipeline` and in scope until the `end` of the enclosing control " +
+				"structure."
+		}
+	default:
+		return nil
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}}
+}
+
+// maxEntityLength bounds the backward search for the '&' of an entity
+// reference under the cursor.
+const maxEntityLength = 32
+
+// hoverEntity describes the character or entity reference at offset.
+func hoverEntity(content []byte, offset int) *Hover {
+	start := -1
+	for i := min(offset, len(content)-1); i >= 0 && offset-i < maxEntityLength; i-- {
+		if content[i] == '&' {
+			start = i
+			break
+		}
+		if (content[i] == ';' && i < offset) || content[i] == '<' || content[i] == '>' {
+			return nil
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	end := bytes.IndexByte(content[start:], ';')
+	if end < 0 || start+end < offset {
+		return nil
+	}
+	ref := string(content[start+1 : start+end])
+
+	var r rune
+	switch {
+	case strings.HasPrefix(ref, "#x"):
+		n, err := strconv.ParseUint(ref[2:], 16, 32)
+		if err != nil {
+			return nil
+		}
+		r = rune(n)
+	case strings.HasPrefix(ref, "#"):
+		n, err := strconv.ParseUint(ref[1:], 10, 32)
+		if err != nil {
+			return nil
+		}
+		r = rune(n)
+	default:
+		var ok bool
+		if r, ok = xhtml.LookupEntity(ref); !ok {
+			return nil
+		}
+	}
+
+	text := fmt.Sprintf("**&%s;**\n- **Character:** %q\n- **Code point:** U+%04X",
+		ref, r, r)
+	if replacement, ok := xhtml.EntityReplacement(ref); ok {
+		text += "\n\nNot defined in XHTML; use `" + replacement + "` instead."
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}}
+}
+
 func marshalNullResponse(id ID) []byte {
 	res := ResponseMessage[any]{
 		JsonRpc: JSONRPCVersion,
@@ -30,6 +30,72 @@ var schemaPropertyDocs = map[string]string{
 		"specific accessibility features or deficiencies of the publication.",
 }
 
+// mediaPropertyDocs maps media overlay meta properties to documentation.
+var mediaPropertyDocs = map[string]string{
+	"media:duration": "**media:duration**\n\nThe playback time of the publication's media overlays as a " +
+		"SMIL clock value, e.g. `0:32:29.5`, `05:01.2`, or `12.5s`.\n\n" +
+		"Without `refines` it is the total for the publication; with `refines` pointing at a " +
+		"SMIL manifest item it is that overlay's duration. Both are required when items have " +
+		"a `media-overlay` attribute, and the overlays should add up to the total.",
+
+	"media:active-class": "**media:active-class**\n\nThe CSS class reading systems add to the " +
+		"element whose audio is playing, e.g. `-epub-media-overlay-active`. Set once, without `refines`.",
+
+	"media:playback-active-class": "**media:playback-active-class**\n\nThe CSS class reading systems " +
+		"add to the document element while media overlay playback is active, e.g. " +
+		"`-epub-media-overlay-playing`. Set once, without `refines`.",
+}
+
+// legacyMetaDocs maps EPUB 2 meta names, still common in EPUB 3 files, to
+// documentation.
+var legacyMetaDocs = map[string]string{
+	"cover": "**cover** (EPUB 2)\n\nNames the manifest item of the cover image in `content`. " +
+		"EPUB 3 reading systems use `properties=\"cover-image\"` on that item instead; " +
+		"the meta may be kept for older readers.",
+
+	"calibre:series": "**calibre:series** (calibre)\n\nThe name of the series the book belongs " +
+		"to, written by calibre. EPUB 3 reading systems ignore it; use a `belongs-to-collection` " +
+		"meta with `collection-type` `series`.",
+
+	"calibre:series_index": "**calibre:series_index** (calibre)\n\nThe book's position in its " +
+		"calibre series, e.g. `2.0`. EPUB 3 reading systems ignore it; refine the " +
+		"`belongs-to-collection` meta with `group-position`.",
+
+	"generator": "**generator**\n\nThe tool that produced the publication, e.g. `Sigil` or " +
+		"`calibre`. Informational only; reading systems ignore it.",
+}
+
+// linkRelDocs maps the rel values of metadata links to documentation.
+var linkRelDocs = map[string]string{
+	"acquire": "**acquire**\n\nWhere to acquire the full publication, as from a preview " +
+		"edition. `href` is usually a remote store or library page.",
+
+	"alternate": "**alternate**\n\nAn alternate version of the linked resource, such as " +
+		"the same record in another format. May not be combined with other rel values.",
+
+	"record": "**record**\n\nA metadata record for the publication, local or remote. " +
+		"`media-type` is required and names the format, e.g. `application/marcxml+xml`; " +
+		"an ONIX record adds `properties=\"onix\"`.",
+
+	"voicing": "**voicing**\n\nAn audio file pronouncing the title or creator named by " +
+		"`refines`, for text-to-speech and read-aloud. `media-type` gives the audio format.",
+
+	"marc21xml-record": "**marc21xml-record** (deprecated)\n\nA MARC 21 XML record. Use " +
+		"`rel=\"record\"` with `media-type=\"application/marcxml+xml\"`.",
+
+	"mods-record": "**mods-record** (deprecated)\n\nA MODS record. Use `rel=\"record\"` " +
+		"with `media-type=\"application/mods+xml\"`.",
+
+	"onix-record": "**onix-record** (deprecated)\n\nAn ONIX record. Use `rel=\"record\"` " +
+		"with `properties=\"onix\"` and `media-type=\"application/xml\"`.",
+
+	"xmp-record": "**xmp-record** (deprecated)\n\nAn XMP record. Use `rel=\"record\"` " +
+		"with `media-type=\"application/rdf+xml\"`.",
+
+	"xml-signature": "**xml-signature** (deprecated)\n\nAn XML signature for the " +
+		"publication. Signatures belong in `META-INF/signatures.xml`.",
+}
+
 // epubTypeDocs maps epub:type values to documentation with expected ARIA roles.
 var epubTypeDocs = map[string]string{
 	"toc":          "**toc** — Table of Contents\n\nExpected ARIA role: `doc-toc`\n\nA navigation list of references to the content.",
@@ -58,6 +124,24 @@ var epubTypeDocs = map[string]string{
 	"pagebreak":    "**pagebreak** — Page Break\n\nExpected ARIA role: `doc-pagebreak`\n\nA location representing a page break from a static page source.",
 }
 
+// bidiDocs documents the dir attribute and the <bdi> and <bdo> elements,
+// which EPUB content uses in place of the CSS direction and unicode-bidi
+// properties.
+var bidiDocs = map[string]string{
+	"dir": "**dir** — Text Direction\n\n`ltr`, `rtl`, or `auto`. Sets the base direction of " +
+		"the element's text; `auto` takes it from the first strong character. EPUB " +
+		"stylesheets may not use the `direction` property (CSS_001), so set `dir` on " +
+		"`<html>` for right-to-left books and on elements whose text runs the other way.",
+	"bdi": "**bdi** — Bidirectional Isolate\n\nIsolates its text from the surrounding " +
+		"direction, as for user names or numbers of unknown direction inside a sentence. " +
+		"The markup equivalent of `unicode-bidi: isolate`, which EPUB stylesheets may not " +
+		"use (CSS_001).",
+	"bdo": "**bdo** — Bidirectional Override\n\nDisplays its text in the direction of its " +
+		"required `dir` attribute, `ltr` or `rtl`, whatever the characters' own " +
+		"direction. The markup equivalent of `unicode-bidi: bidi-override`, which EPUB " +
+		"stylesheets may not use (CSS_001).",
+}
+
 // dcElementDocs maps Dublin Core element names to documentation.
 var dcElementDocs = map[string]string{
 	"title":       "**dc:title**\n\nThe title of the publication. Every EPUB must have at least one `dc:title`.",
@@ -76,3 +160,56 @@ var dcElementDocs = map[string]string{
 	"relation":    "**dc:relation**\n\nA related resource.",
 	"coverage":    "**dc:coverage**\n\nThe spatial or temporal coverage of the content.",
 }
+
+// templateDocs maps Go template keywords and predefined functions to
+// documentation.
+var templateDocs = map[string]string{
+	"if": "**if** — `{{if pipeline}} T1 {{else}} T0 {{end}}`\n\nRenders T1 when the pipeline's " +
+		"value is non-empty, otherwise T0. Empty values are false, 0, nil, and empty " +
+		"strings, slices, and maps.",
+	"else": "**else**\n\nStarts the branch of an `if`, `range`, or `with` rendered when the " +
+		"value is empty. `{{else if pipeline}}` and `{{else with pipeline}}` chain conditions.",
+	"end": "**end**\n\nCloses the innermost `if`, `range`, `with`, `define`, or `block`.",
+	"range": "**range** — `{{range pipeline}} T1 {{else}} T0 {{end}}`\n\nRenders T1 once for " +
+		"each element of an array, slice, map, channel, or integer, with dot set to the " +
+		"element. T0 renders when there are none. `{{range $i, $e := pipeline}}` also " +
+		"binds the index or key and the element.",
+	"with": "**with** — `{{with pipeline}} T1 {{else}} T0 {{end}}`\n\nRenders T1 with dot set " +
+		"to the pipeline's value when it is non-empty, otherwise T0.",
+	"define": "**define** — `{{define \"name\"}} T1 {{end}}`\n\nDefines a named template " +
+		"that `template` and `block` can render.",
+	"template": "**template** — `{{template \"name\" pipeline}}`\n\nRenders the named " +
+		"template with dot set to the pipeline's value, or nil without one.",
+	"block": "**block** — `{{block \"name\" pipeline}} T1 {{end}}`\n\nDefines a named " +
+		"template with T1 as its body and renders it in place; a later `define` can " +
+		"replace it.",
+	"break":    "**break**\n\nEnds the innermost `range` early.",
+	"continue": "**continue**\n\nSkips to the next iteration of the innermost `range`.",
+	"nil":      "**nil**\n\nThe untyped nil value, usable only as a function argument.",
+
+	"and": "**and** — `and arg1 arg2 ...`\n\nReturns the first empty argument or the last " +
+		"argument. Evaluation stops at the first empty one.",
+	"or": "**or** — `or arg1 arg2 ...`\n\nReturns the first non-empty argument or the last " +
+		"argument. Evaluation stops at the first non-empty one.",
+	"not": "**not** — `not arg`\n\nReturns the boolean negation of its single argument.",
+	"call": "**call** — `call fn arg1 arg2 ...`\n\nCalls the function value fn with the " +
+		"remaining arguments. The function must return one value, or a value and an error.",
+	"html":     "**html** — `html args...`\n\nReturns the escaped HTML equivalent of the textual representation of its arguments.",
+	"js":       "**js** — `js args...`\n\nReturns the escaped JavaScript equivalent of the textual representation of its arguments.",
+	"urlquery": "**urlquery** — `urlquery args...`\n\nReturns the textual representation of its arguments escaped for a URL query.",
+	"index": "**index** — `index item key1 key2 ...`\n\nReturns the result of indexing item " +
+		"by the keys in turn: `index x 1 2 3` is `x[1][2][3]`. Item must be a map, slice, " +
+		"or array.",
+	"slice": "**slice** — `slice item 1 2`\n\nReturns item sliced by the arguments: " +
+		"`slice x 1 2` is `x[1:2]`. Item must be a string, slice, or array.",
+	"len":     "**len** — `len item`\n\nReturns the integer length of its argument.",
+	"print":   "**print** — `print args...`\n\nAn alias for `fmt.Sprint`.",
+	"printf":  "**printf** — `printf format args...`\n\nAn alias for `fmt.Sprintf`.",
+	"println": "**println** — `println args...`\n\nAn alias for `fmt.Sprintln`.",
+	"eq":      "**eq** — `eq arg1 arg2 ...`\n\nReturns whether arg1 equals any of the other arguments.",
+	"ne":      "**ne** — `ne arg1 arg2`\n\nReturns whether arg1 != arg2.",
+	"lt":      "**lt** — `lt arg1 arg2`\n\nReturns whether arg1 < arg2.",
+	"le":      "**le** — `le arg1 arg2`\n\nReturns whether arg1 <= arg2.",
+	"gt":      "**gt** — `gt arg1 arg2`\n\nReturns whether arg1 > arg2.",
+	"ge":      "**ge** — `ge arg1 arg2`\n\nReturns whether arg1 >= arg2.",
+}
@@ -30,7 +30,7 @@ func TestHandleHover_MetaProperty(t *testing.T) {
 		Position:     lspPosition,
 	})
 
-	resp := HandleHover(data, ws)
+	resp := HandleHover(t.Context(), data, ws)
 
 	// Should get hover info about schema:accessMode
 	var result ResponseMessage[*Hover]
@@ -48,6 +48,87 @@ func TestHandleHover_MetaProperty(t *testing.T) {
 	}
 }
 
+func TestHandleHover_MediaProperty(t *testing.T) {
+	ws := newMockWorkspace()
+	opfContent := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata>
+    <meta property="media:duration">0:32:29</meta>
+    <meta property="media:active-class">-epub-media-overlay-active</meta>
+    <meta property="media:playback-active-class">-epub-media-overlay-playing</meta>
+  </metadata>
+</package>`)
+	ws.files["file:///book/content.opf"] = opfContent
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	for _, prop := range []string{
+		"media:duration", "media:active-class", "media:playback-active-class",
+	} {
+		offset := findSubstring(opfContent, `<meta property="`+prop+`"`)
+		data := makeRequest(t, 1, MethodHover, HoverParams{
+			TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+			Position:     lspPos(epub.ByteOffsetToPosition(opfContent, offset+1)),
+		})
+
+		var result ResponseMessage[*Hover]
+		if err := unmarshalJSON(HandleHover(t.Context(), data, ws), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Result == nil {
+			t.Errorf("%s: expected hover", prop)
+			continue
+		}
+		if !strings.Contains(result.Result.Contents.Value, "**"+prop+"**") {
+			t.Errorf("%s: expected hover docs, got %q", prop, result.Result.Contents.Value)
+		}
+	}
+}
+
+func TestHandleHover_LegacyMeta(t *testing.T) {
+	ws := newMockWorkspace()
+	opfContent := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata>
+    <meta name="cover" content="cover-img"/>
+    <meta name="calibre:series" content="Dune"/>
+    <meta name="calibre:series_index" content="1.0"/>
+    <meta name="generator" content="Sigil"/>
+  </metadata>
+</package>`)
+	ws.files["file:///book/content.opf"] = opfContent
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"cover", `properties="cover-image"`},
+		{"calibre:series", "belongs-to-collection"},
+		{"calibre:series_index", "group-position"},
+		{"generator", "reading systems ignore it"},
+	}
+	for _, tt := range tests {
+		offset := findSubstring(opfContent, `<meta name="`+tt.name+`"`)
+		data := makeRequest(t, 1, MethodHover, HoverParams{
+			TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+			Position:     lspPos(epub.ByteOffsetToPosition(opfContent, offset+1)),
+		})
+
+		var result ResponseMessage[*Hover]
+		if err := unmarshalJSON(HandleHover(t.Context(), data, ws), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Result == nil {
+			t.Errorf("%s: expected hover", tt.name)
+			continue
+		}
+		value := result.Result.Contents.Value
+		if !strings.Contains(value, "**"+tt.name+"**") || !strings.Contains(value, tt.want) {
+			t.Errorf("%s: unexpected hover docs %q", tt.name, value)
+		}
+	}
+}
+
 func TestHandleHover_ItemrefIdref(t *testing.T) {
 	ws := newMockWorkspace()
 	opfContent := []byte(`<?xml version="1.0"?>
@@ -77,7 +158,7 @@ func TestHandleHover_ItemrefIdref(t *testing.T) {
 		Position:     lspPosition,
 	})
 
-	resp := HandleHover(data, ws)
+	resp := HandleHover(t.Context(), data, ws)
 
 	var result ResponseMessage[*Hover]
 	if err := unmarshalJSON(resp, &result); err != nil {
@@ -101,7 +182,7 @@ func TestHandleHover_NoContent(t *testing.T) {
 		Position:     Position{Line: 0, Character: 0},
 	})
 
-	resp := HandleHover(data, ws)
+	resp := HandleHover(t.Context(), data, ws)
 
 	var result ResponseMessage[*Hover]
 	if err := unmarshalJSON(resp, &result); err != nil {
@@ -116,3 +197,346 @@ func TestHandleHover_NoContent(t *testing.T) {
 func unmarshalJSON(data []byte, v any) error {
 	return json.Unmarshal(data, v)
 }
+
+func TestHandleHover_Entity(t *testing.T) {
+	ws := newMockWorkspace()
+	content := []byte(`<html><body><p>one&mdash;two &#160;</p></body></html>`)
+	ws.files["file:///book/ch1.xhtml"] = content
+	ws.fileTypes["file:///book/ch1.xhtml"] = epub.FileTypeXHTML
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"mdash;", []string{"U+2014", "`—`"}},
+		{"160;", []string{"U+00A0"}},
+	}
+
+	for _, tt := range tests {
+		offset := findSubstring(content, tt.target)
+		data := makeRequest(t, 1, MethodHover, HoverParams{
+			TextDocument: TextDocumentIdentifier{Uri: "file:///book/ch1.xhtml"},
+			Position:     lspPos(epub.ByteOffsetToPosition(content, offset+2)),
+		})
+
+		var result ResponseMessage[*Hover]
+		if err := unmarshalJSON(HandleHover(t.Context(), data, ws), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Result == nil {
+			t.Fatalf("expected hover for %q", tt.target)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(result.Result.Contents.Value, want) {
+				t.Errorf("expected hover to contain %q, got %q",
+					want, result.Result.Contents.Value)
+			}
+		}
+	}
+}
+
+func TestHandleHover_Refines(t *testing.T) {
+	ws := newMockWorkspace()
+	ws.files["file:///book/content.opf"] = refinesOPF
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	offset := findSubstring(refinesOPF, `#creator01"`)
+	data := makeRequest(t, 1, MethodHover, HoverParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+		Position:     lspPos(epub.ByteOffsetToPosition(refinesOPF, offset+2)),
+	})
+
+	var result ResponseMessage[*Hover]
+	if err := unmarshalJSON(HandleHover(t.Context(), data, ws), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Result == nil {
+		t.Fatal("expected hover for refines value")
+	}
+	for _, want := range []string{"dc:creator", "Jane Doe"} {
+		if !strings.Contains(result.Result.Contents.Value, want) {
+			t.Errorf("expected hover to contain %q, got %q",
+				want, result.Result.Contents.Value)
+		}
+	}
+}
+
+func TestHandleHover_ManifestIDRefs(t *testing.T) {
+	ws := newMockWorkspace()
+	ws.files["file:///book/content.opf"] = manifestRefsOPF
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+	ws.manifest = &validator.ManifestInfo{
+		Items: []validator.ManifestItem{
+			{ID: "ncx", Href: "toc.ncx", MediaType: validator.NCXMediaType},
+			{ID: "smil1", Href: "ch1.smil", MediaType: "application/smil+xml"},
+		},
+	}
+
+	tests := []struct {
+		at   string
+		want []string
+	}{
+		{`toc="ncx"`, []string{"toc.ncx", validator.NCXMediaType}},
+		{`media-overlay="smil1"`, []string{"ch1.smil", "application/smil+xml"}},
+	}
+
+	for _, tt := range tests {
+		offset := findSubstring(manifestRefsOPF, tt.at) + len(tt.at) - 2
+		data := makeRequest(t, 1, MethodHover, HoverParams{
+			TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+			Position:     lspPos(epub.ByteOffsetToPosition(manifestRefsOPF, offset)),
+		})
+
+		var result ResponseMessage[*Hover]
+		if err := unmarshalJSON(HandleHover(t.Context(), data, ws), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Result == nil {
+			t.Fatalf("%s: expected hover", tt.at)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(result.Result.Contents.Value, want) {
+				t.Errorf("%s: expected hover to contain %q, got %q",
+					tt.at, want, result.Result.Contents.Value)
+			}
+		}
+	}
+}
+
+func TestHandleHover_Diagnostic(t *testing.T) {
+	ws := newMockWorkspace()
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml">
+<body><img src="a.png"/></body>
+</html>`)
+	uri := "file:///book/ch1.xhtml"
+	ws.files[uri] = content
+	ws.fileTypes[uri] = epub.FileTypeXHTML
+
+	imgPos := epub.ByteOffsetToPosition(content, findSubstring(content, "<img"))
+	ws.diagnostics[uri] = []epub.Diagnostic{{
+		Code:        "HTM_008",
+		Message:     "<img> element missing alt attribute",
+		Range:       epub.Range{Start: imgPos, End: imgPos},
+		Explanation: epub.CodeExplanation("HTM_008"),
+	}}
+
+	hoverAt := func(pos epub.Position) *Hover {
+		data := makeRequest(t, 1, MethodHover, HoverParams{
+			TextDocument: TextDocumentIdentifier{Uri: uri},
+			Position:     lspPos(pos),
+		})
+		return unmarshalResult[*Hover](t, HandleHover(t.Context(), data, ws))
+	}
+
+	// The zero-width diagnostic covers the tag name
+	onName := imgPos
+	onName.Character += 2
+	hover := hoverAt(onName)
+	if hover == nil {
+		t.Fatal("expected hover over diagnostic")
+	}
+	for _, want := range []string{
+		"**HTM_008**",
+		epub.CodeExplanation("HTM_008"),
+		"(" + epub.CodeURL("HTM_008") + ")",
+	} {
+		if !strings.Contains(hover.Contents.Value, want) {
+			t.Errorf("expected hover to contain %q, got %q", want, hover.Contents.Value)
+		}
+	}
+
+	// Past the tag name the diagnostic no longer applies
+	onAttr := imgPos
+	onAttr.Character += 7
+	if hover := hoverAt(onAttr); hover != nil {
+		t.Errorf("expected no hover outside the diagnostic, got %q",
+			hover.Contents.Value)
+	}
+}
+
+func TestHandleHover_Template(t *testing.T) {
+	ws := newMockWorkspace()
+	content := []byte(`<html xmlns:epub="http://www.idpf.org/2007/ops"><body>
+{{range .Chapters}}<p>{{len .Title}} {{printf "%d pages" $n}}</p>{{end}}
+<section epub:type="chapter">x</section>
+</body></html>`)
+	ws.files["file:///book/ch1.xhtml"] = content
+	ws.fileTypes["file:///book/ch1.xhtml"] = epub.FileTypeXHTML
+
+	tests := []struct {
+		name   string
+		target string
+		// at is the offset of the cursor within target
+		at   int
+		want string
+	}{
+		{"range keyword", "range", 2, "**range**"},
+		{"builtin function", "len", 1, "`len item`"},
+		{"field", ".Title", 3, "Field access on the current context"},
+		{"variable", "$n", 1, "template variable"},
+		{"string literal", `"%d pages"`, 3, ""},
+		{"delimiter", "{{end", 0, ""},
+		{"outside any block", `"chapter"`, 3, "**chapter**"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset := findSubstring(content, tt.target) + tt.at
+			data := makeRequest(t, 1, MethodHover, HoverParams{
+				TextDocument: TextDocumentIdentifier{Uri: "file:///book/ch1.xhtml"},
+				Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+			})
+
+			var result ResponseMessage[*Hover]
+			if err := unmarshalJSON(HandleHover(t.Context(), data, ws), &result); err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if result.Result != nil {
+					t.Errorf("expected no hover, got %q", result.Result.Contents.Value)
+				}
+				return
+			}
+			if result.Result == nil {
+				t.Fatal("expected hover")
+			}
+			if !strings.Contains(result.Result.Contents.Value, tt.want) {
+				t.Errorf("expected hover to contain %q, got %q", tt.want, result.Result.Contents.Value)
+			}
+		})
+	}
+}
+
+func TestHandleHover_Bidi(t *testing.T) {
+	ws := newMockWorkspace()
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml" dir="rtl"><body>
+<p>User <bdi>إيان</bdi> wrote <bdo dir="ltr">abc</bdo>.</p>
+</body></html>`)
+	ws.files["file:///book/ch1.xhtml"] = content
+	ws.fileTypes["file:///book/ch1.xhtml"] = epub.FileTypeXHTML
+
+	tests := []struct {
+		name   string
+		target string
+		at     int
+		want   string
+	}{
+		{"dir attribute", `dir="rtl"`, 1, "**dir**"},
+		{"dir value", `dir="ltr"`, 6, "CSS_001"},
+		{"bdi element", "<bdi>", 2, "**bdi**"},
+		{"bdo element", "<bdo", 2, "bidi-override"},
+		{"other element", "<p>", 1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset := findSubstring(content, tt.target) + tt.at
+			data := makeRequest(t, 1, MethodHover, HoverParams{
+				TextDocument: TextDocumentIdentifier{Uri: "file:///book/ch1.xhtml"},
+				Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+			})
+
+			var result ResponseMessage[*Hover]
+			if err := unmarshalJSON(HandleHover(t.Context(), data, ws), &result); err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if result.Result != nil {
+					t.Errorf("expected no hover, got %q", result.Result.Contents.Value)
+				}
+				return
+			}
+			if result.Result == nil {
+				t.Fatal("expected hover")
+			}
+			if !strings.Contains(result.Result.Contents.Value, tt.want) {
+				t.Errorf("expected hover to contain %q, got %q", tt.want, result.Result.Contents.Value)
+			}
+		})
+	}
+}
+
+func TestHandleHover_LinkRel(t *testing.T) {
+	ws := newMockWorkspace()
+	opfContent := []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata>
+    <link rel="record onix-record" href="onix.xml" media-type="application/xml"/>
+  </metadata>
+</package>`)
+	ws.files["file:///book/content.opf"] = opfContent
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	tests := []struct {
+		token string
+		at    string // text starting at the cursor
+		want  string
+	}{
+		{"record", "ecord onix", "`media-type` is required"},
+		{"onix-record", "nix-record", "(deprecated)"},
+	}
+	for _, tt := range tests {
+		offset := findSubstring(opfContent, tt.at)
+		data := makeRequest(t, 1, MethodHover, HoverParams{
+			TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+			Position:     lspPos(epub.ByteOffsetToPosition(opfContent, offset)),
+		})
+
+		var result ResponseMessage[*Hover]
+		if err := unmarshalJSON(HandleHover(t.Context(), data, ws), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Result == nil {
+			t.Errorf("%s: expected hover", tt.token)
+			continue
+		}
+		value := result.Result.Contents.Value
+		if !strings.Contains(value, "**"+tt.token+"**") || !strings.Contains(value, tt.want) {
+			t.Errorf("%s: unexpected hover docs %q", tt.token, value)
+		}
+	}
+}
+
+func TestHandleHover_MediaFeature(t *testing.T) {
+	const uri = "file:///book/style.css"
+	content := []byte("@media (prefers-color-scheme: dark) and (min-width: 30em) {\n}\n")
+	ws := newMockWorkspace()
+	ws.files[uri] = content
+	ws.fileTypes[uri] = epub.FileTypeCSS
+
+	hover := func(offset int) *Hover {
+		data := makeRequest(t, 1, MethodHover, HoverParams{
+			TextDocument: TextDocumentIdentifier{Uri: uri},
+			Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+		})
+		var result ResponseMessage[*Hover]
+		if err := unmarshalJSON(HandleHover(t.Context(), data, ws), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result.Result
+	}
+
+	h := hover(findSubstring(content, "color-scheme"))
+	if h == nil {
+		t.Fatal("expected hover on prefers-color-scheme")
+	}
+	for _, want := range []string{
+		"**prefers-color-scheme**", "reading theme", "`light`, `dark`", "Poorly supported",
+	} {
+		if !strings.Contains(h.Contents.Value, want) {
+			t.Errorf("hover %q does not mention %q", h.Contents.Value, want)
+		}
+	}
+	if h.Range == nil || h.Range.Start.Character != 8 || h.Range.End.Character != 28 {
+		t.Errorf("range = %+v, want the feature name", h.Range)
+	}
+
+	h = hover(findSubstring(content, "min-width"))
+	if h == nil || !strings.Contains(h.Contents.Value, "viewport") ||
+		strings.Contains(h.Contents.Value, "Poorly supported") {
+		t.Errorf("hover on min-width = %+v", h)
+	}
+	if h := hover(findSubstring(content, "dark")); h != nil {
+		t.Errorf("expected no hover on a value, got %q", h.Contents.Value)
+	}
+}
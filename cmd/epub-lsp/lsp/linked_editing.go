@@ -0,0 +1,68 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// tagNamePattern is the word pattern of linked tag names, limiting the
+// mirrored edit to characters an XML name can hold.
+const tagNamePattern = `[A-Za-z_:][-A-Za-z0-9_:.]*`
+
+// LinkedEditingRangeParams holds parameters for
+// textDocument/linkedEditingRange.
+type LinkedEditingRangeParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// LinkedEditingRanges is the result of textDocument/linkedEditingRange:
+// ranges the client edits together, and the pattern their content must
+// keep matching.
+type LinkedEditingRanges struct {
+	Ranges      []Range `json:"ranges"`
+	WordPattern string  `json:"wordPattern,omitempty"`
+}
+
+// HandleLinkedEditingRange processes textDocument/linkedEditingRange
+// requests. On the name of a start or end tag it returns the names in both
+// tags, so renaming one renames the other; self-closing elements and other
+// positions get a null result.
+func HandleLinkedEditingRange(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
+	var req RequestMessage[LinkedEditingRangeParams]
+	if err := json.Unmarshal(data, &req); err != nil {
+		Logger(ctx).Error("error unmarshalling linkedEditingRange: " + err.Error())
+		return marshalNullResponse(req.Id)
+	}
+
+	uri := req.Params.TextDocument.Uri
+	content := ws.GetContent(uri)
+	if content == nil || ws.GetFileType(uri) == epub.FileTypeCSS {
+		return marshalNullResponse(req.Id)
+	}
+
+	offset := epub.PositionToByteOffset(content, posToEpub(req.Params.Position))
+	start, end, ok := parser.LinkedTagNames(content, offset)
+	if !ok {
+		return marshalNullResponse(req.Id)
+	}
+
+	spanRange := func(span [2]int) Range {
+		return Range{
+			Start: lspPos(epub.ByteOffsetToPosition(content, span[0])),
+			End:   lspPos(epub.ByteOffsetToPosition(content, span[1])),
+		}
+	}
+	ranges := []Range{spanRange(start), spanRange(end)}
+	clamper := newRangeClamper(ctx, ws, MethodLinkedEditingRange)
+	for i := range ranges {
+		clamper.clamp(uri, &ranges[i])
+	}
+	return marshalResponse(req.Id, LinkedEditingRanges{
+		Ranges:      ranges,
+		WordPattern: tagNamePattern,
+	})
+}
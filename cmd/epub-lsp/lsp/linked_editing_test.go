@@ -0,0 +1,72 @@
+package lsp
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// linkedEditingAt requests linked editing ranges at offset in content and
+// returns the offsets the ranges start at, or nil for a null result.
+func linkedEditingAt(t *testing.T, content []byte, offset int) []int {
+	t.Helper()
+	ws := newMockWorkspace()
+	ws.files["file:///book/ch1.xhtml"] = content
+	ws.fileTypes["file:///book/ch1.xhtml"] = epub.FileTypeXHTML
+
+	data := makeRequest(t, 1, MethodLinkedEditingRange, LinkedEditingRangeParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/ch1.xhtml"},
+		Position:     lspPos(epub.ByteOffsetToPosition(content, offset)),
+	})
+	resp := HandleLinkedEditingRange(t.Context(), data, ws)
+	result := unmarshalResult[*LinkedEditingRanges](t, resp)
+	if result == nil {
+		return nil
+	}
+	if result.WordPattern == "" {
+		t.Error("expected a word pattern")
+	}
+
+	var starts []int
+	for _, r := range result.Ranges {
+		start := epub.PositionToByteOffset(content, posToEpub(r.Start))
+		end := epub.PositionToByteOffset(content, posToEpub(r.End))
+		if name := string(content[start:end]); name != "div" {
+			t.Errorf("range covers %q, want div", name)
+		}
+		starts = append(starts, start)
+	}
+	return starts
+}
+
+func TestHandleLinkedEditingRange(t *testing.T) {
+	content := []byte(`<html><body>
+<div class="outer">
+  <div>inner</div>
+  <img src="a.png" alt=""/>
+</div>
+</body></html>`)
+	outer := findSubstring(content, `<div class`) + 1
+	inner := findSubstring(content, `<div>inner`) + 1
+	innerEnd := findSubstring(content, `</div>`) + 2
+	outerEnd := findSubstring(content, "</div>\n</body>") + 2
+
+	tests := []struct {
+		name   string
+		offset int
+		want   []int
+	}{
+		{"outer start tag", outer + 1, []int{outer, outerEnd}},
+		{"inner start tag", inner, []int{inner, innerEnd}},
+		{"inner end tag", innerEnd + 1, []int{inner, innerEnd}},
+		{"outer end tag", outerEnd + 3, []int{outer, outerEnd}},
+		{"self-closing", findSubstring(content, "<img") + 2, nil},
+		{"attribute", findSubstring(content, "class") + 1, nil},
+	}
+	for _, tt := range tests {
+		if got := linkedEditingAt(t, content, tt.offset); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: ranges start at %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+// Trace values of the initialize trace parameter and $/setTrace.
+const (
+	TraceOff      = "off"
+	TraceMessages = "messages"
+	TraceVerbose  = "verbose"
+)
+
+// logLevels maps the logLevel setting to slog levels.
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// ParseLogLevel returns the slog level named by a logLevel setting. It
+// reports false for names other than debug, info, warn, and error.
+func ParseLogLevel(name string) (slog.Level, bool) {
+	level, ok := logLevels[name]
+	return level, ok
+}
+
+// loggerKey is the context key of a request's logger.
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger, which handlers use for
+// their log lines so they share the request's attributes.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger carried by ctx, or the default logger.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// SetTraceParams holds parameters for $/setTrace.
+type SetTraceParams struct {
+	Value string `json:"value"`
+}
+
+// ProcessSetTraceNotification handles $/setTrace, returning the new trace
+// value. It reports false when the value is missing or not off, messages,
+// or verbose.
+func ProcessSetTraceNotification(data []byte) (string, bool) {
+	var request NotificationMessage[SetTraceParams]
+	if err := json.Unmarshal(data, &request); err != nil || !validTrace(request.Params.Value) {
+		slog.Warn("ignoring malformed '$/setTrace'")
+		return "", false
+	}
+	return request.Params.Value, true
+}
+
+// validTrace reports whether value is a trace value.
+func validTrace(value string) bool {
+	return value == TraceOff || value == TraceMessages || value == TraceVerbose
+}
+
+// DidChangeConfigurationParams holds parameters for
+// workspace/didChangeConfiguration.
+type DidChangeConfigurationParams struct {
+	Settings *ServerSettings `json:"settings"`
+}
+
+// ProcessDidChangeConfigurationNotification handles
+// workspace/didChangeConfiguration, returning the settings sent by the
+// client. It reports false when there are none.
+func ProcessDidChangeConfigurationNotification(data []byte) (*ServerSettings, bool) {
+	var request NotificationMessage[DidChangeConfigurationParams]
+	if err := json.Unmarshal(data, &request); err != nil || request.Params.Settings == nil {
+		slog.Warn("ignoring malformed 'workspace/didChangeConfiguration'")
+		return nil, false
+	}
+	return request.Params.Settings, true
+}
+
+// Message types of window/logMessage.
+const (
+	MessageTypeError   = 1
+	MessageTypeWarning = 2
+	MessageTypeInfo    = 3
+	MessageTypeLog     = 4
+)
+
+// LogMessageParams holds parameters for window/logMessage.
+type LogMessageParams struct {
+	Type    int    `json:"type"`
+	Message string `json:"message"`
+}
+
+// LogMessageNotification builds a window/logMessage notification asking
+// the client to log message with the given message type.
+func LogMessageNotification(messageType int, message string) []byte {
+	notification := NotificationMessage[LogMessageParams]{
+		JsonRpc: JSONRPCVersion,
+		Method:  MethodLogMessage,
+		Params:  LogMessageParams{Type: messageType, Message: message},
+	}
+	data, err := json.Marshal(notification)
+	if err != nil {
+		slog.Error("error marshalling logMessage: " + err.Error())
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,82 @@
+package lsp
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		level slog.Level
+		ok    bool
+	}{
+		{"debug", slog.LevelDebug, true},
+		{"info", slog.LevelInfo, true},
+		{"warn", slog.LevelWarn, true},
+		{"error", slog.LevelError, true},
+		{"verbose", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		level, ok := ParseLogLevel(tt.name)
+		if ok != tt.ok || level != tt.level {
+			t.Errorf("ParseLogLevel(%q) = %v, %v, want %v, %v",
+				tt.name, level, ok, tt.level, tt.ok)
+		}
+	}
+}
+
+func TestProcessSetTraceNotification(t *testing.T) {
+	for _, value := range []string{TraceOff, TraceMessages, TraceVerbose} {
+		data := makeRequest(t, 0, MethodSetTrace, SetTraceParams{Value: value})
+		if got, ok := ProcessSetTraceNotification(data); !ok || got != value {
+			t.Errorf("expected %q, got %q, %v", value, got, ok)
+		}
+	}
+
+	data := makeRequest(t, 0, MethodSetTrace, SetTraceParams{Value: "loud"})
+	if _, ok := ProcessSetTraceNotification(data); ok {
+		t.Error("expected an unknown trace value to be rejected")
+	}
+}
+
+func TestProcessInitializeReadsTrace(t *testing.T) {
+	data := makeRequest(t, 1, MethodInitialize, InitializeParams{Trace: TraceVerbose})
+	if _, _, settings := ProcessInitializeRequest(data, "epub-lsp", "test"); settings.Trace != TraceVerbose {
+		t.Errorf("expected trace %q, got %q", TraceVerbose, settings.Trace)
+	}
+
+	data = makeRequest(t, 2, MethodInitialize, InitializeParams{})
+	if _, _, settings := ProcessInitializeRequest(data, "epub-lsp", "test"); settings.Trace != TraceOff {
+		t.Errorf("expected trace to default to %q, got %q", TraceOff, settings.Trace)
+	}
+}
+
+func TestProcessDidChangeConfigurationNotification(t *testing.T) {
+	data := []byte(`{"jsonrpc":"2.0","method":"workspace/didChangeConfiguration",` +
+		`"params":{"settings":{"logLevel":"debug"}}}`)
+	settings, ok := ProcessDidChangeConfigurationNotification(data)
+	if !ok || settings.LogLevel != "debug" {
+		t.Errorf("expected logLevel debug, got %+v, %v", settings, ok)
+	}
+
+	data = []byte(`{"jsonrpc":"2.0","method":"workspace/didChangeConfiguration","params":{}}`)
+	if _, ok := ProcessDidChangeConfigurationNotification(data); ok {
+		t.Error("expected missing settings to be rejected")
+	}
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	if Logger(t.Context()) != slog.Default() {
+		t.Error("expected the default logger without one in the context")
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil)).With("method", MethodHover)
+	Logger(WithLogger(t.Context(), logger)).Error("failed")
+	if !bytes.Contains(buf.Bytes(), []byte("method="+MethodHover)) {
+		t.Errorf("expected the request attributes, got %q", buf.String())
+	}
+}
@@ -16,32 +16,52 @@ type WorkspaceReader interface {
 	GetFileType(uri string) epub.FileType
 	GetManifest() *validator.ManifestInfo
 	GetDiagnostics(uri string) []epub.Diagnostic
+	// Validated reports whether a validation pass has stored diagnostics
+	// for uri, even if it found none.
+	Validated(uri string) bool
+	// Validate runs the validators over the current content of uri, for
+	// handlers that cannot rely on diagnostics stored by an earlier pass.
+	Validate(uri string) []epub.Diagnostic
 	GetAllFiles() map[string][]byte
+	// HasBOM reports whether the content of uri started with a byte order
+	// mark, which GetContent and GetAllFiles leave out.
+	HasBOM(uri string) bool
 	GetRootPath() string
 	GetSettings() *ServerSettings
 }
 
 // ID represents a JSON-RPC request ID that can be either a string or number.
-type ID int
+// It holds the ID as the client encoded it, so a response echoes "7" and 7
+// back unchanged, and the zero ID encodes as null.
+type ID string
+
+// NumberID returns the ID of the numbered request n.
+func NumberID(n int64) ID {
+	return ID(strconv.FormatInt(n, 10))
+}
 
 func (id *ID) UnmarshalJSON(data []byte) error {
-	length := len(data)
-	if data[0] == '"' && data[length-1] == '"' {
-		data = data[1 : length-1]
+	if string(data) == "null" {
+		return nil
 	}
-
-	number, err := strconv.Atoi(string(data))
-	if err != nil {
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return errors.New("'ID' expected either a string or an integer")
+		}
+	} else if _, err := strconv.ParseInt(string(data), 10, 64); err != nil {
 		return errors.New("'ID' expected either a string or an integer")
 	}
 
-	*id = ID(number)
+	*id = ID(data)
 	return nil
 }
 
-func (id *ID) MarshalJSON() ([]byte, error) {
-	val := strconv.Itoa(int(*id))
-	return []byte(val), nil
+func (id ID) MarshalJSON() ([]byte, error) {
+	if id == "" {
+		return []byte("null"), nil
+	}
+	return []byte(id), nil
 }
 
 // RequestMessage represents a JSON-RPC request.
@@ -52,12 +72,32 @@ type RequestMessage[T any] struct {
 	Params  T      `json:"params"`
 }
 
-// ResponseMessage represents a JSON-RPC response.
+// ResponseMessage represents a JSON-RPC response. It encodes either result
+// or error, never both: a response with an Error leaves result out, and a
+// successful one sends result even when it is null.
 type ResponseMessage[T any] struct {
 	JsonRpc string         `json:"jsonrpc"`
 	Id      ID             `json:"id"`
 	Result  T              `json:"result"`
-	Error   *ResponseError `json:"error"`
+	Error   *ResponseError `json:"error,omitempty"`
+}
+
+func (r ResponseMessage[T]) MarshalJSON() ([]byte, error) {
+	wire := struct {
+		JsonRpc string          `json:"jsonrpc"`
+		Id      ID              `json:"id"`
+		Result  json.RawMessage `json:"result,omitempty"`
+		Error   *ResponseError  `json:"error,omitempty"`
+	}{JsonRpc: r.JsonRpc, Id: r.Id, Error: r.Error}
+
+	if r.Error == nil {
+		result, err := json.Marshal(r.Result)
+		if err != nil {
+			return nil, err
+		}
+		wire.Result = result
+	}
+	return json.Marshal(wire)
 }
 
 // ResponseError represents a JSON-RPC error.
@@ -76,16 +116,117 @@ type NotificationMessage[T any] struct {
 // ServerSettings holds configuration options sent by the editor.
 type ServerSettings struct {
 	Accessibility string `json:"accessibility"`
+	// EpubVersion forces EPUB 2 ("2.0") or EPUB 3 ("3.0") validation until
+	// a package document declaring its version is open.
+	EpubVersion string `json:"epubVersion"`
+	// Validators lists the validators to run, by epublint name. Empty runs
+	// all of them.
+	Validators []string `json:"validators"`
+	// Ignore lists .gitignore-style path patterns skipped when scanning
+	// the workspace on disk, on top of hidden files, node_modules, and
+	// archives.
+	Ignore []string `json:"ignore"`
+	// Gitignore also skips the paths matched by the workspace root's
+	// .gitignore. Ignore patterns take precedence over it.
+	Gitignore bool `json:"gitignore"`
+	// DiskPollSeconds, when positive, is how often to check the workspace
+	// on disk for changed files that are not open in the editor, for
+	// clients that send no file change events.
+	DiskPollSeconds int `json:"diskPollSeconds"`
+	// AltRedundantPhrases replaces the phrases, such as "image of",
+	// reported at the start of image alt text.
+	AltRedundantPhrases []string `json:"altRedundantPhrases"`
+	// FakeHeadings enables the fake-heading check for paragraphs styled
+	// to look like headings.
+	FakeHeadings bool `json:"fakeHeadings"`
+	// MultipleH1 enables the check for content documents with more than
+	// one h1.
+	MultipleH1 bool `json:"multipleH1"`
+	// Whitespace, when false, turns off the hints about mixed indentation,
+	// trailing whitespace, and missing final newlines.
+	Whitespace *bool `json:"whitespace"`
+	// DisabledCodes lists diagnostic codes, such as "SCP_001", that are
+	// not reported.
+	DisabledCodes []string `json:"disabledCodes"`
+	// ProseWrap decides how formatting lays out the text of paragraphs,
+	// list items, and other prose blocks: "off" (the default) puts text
+	// and inline elements on lines of their own, "preserve" keeps the
+	// existing line breaks, and "sentence" starts each sentence on a new
+	// line.
+	ProseWrap string `json:"proseWrap"`
+	// Format sets formatting defaults by file type, used when the client
+	// sends no indentation of its own.
+	Format FormatSettings `json:"format"`
+	// ProseLineLength, when positive, reports lines in prose blocks
+	// longer than this many characters.
+	ProseLineLength int `json:"proseLineLength"`
+	// DuplicateResources enables the summary, on the manifest, of
+	// resources with identical content.
+	DuplicateResources bool `json:"duplicateResources"`
+	// ValidationConcurrency bounds how many files are validated at once.
+	// Zero or less uses GOMAXPROCS.
+	ValidationConcurrency int `json:"validationConcurrency"`
+	// BOM decides whether files that started with a byte order mark keep
+	// it when the server writes them, as in a packaged book: "preserve"
+	// (the default) or "remove".
+	BOM string `json:"bom"`
+	// LogLevel is the minimum level written to the log file: "debug",
+	// "info" (the default), "warn", or "error".
+	LogLevel string `json:"logLevel"`
+	// Log is where the log goes: "file" (the default), "stderr", or
+	// "off". The log command-line flag takes precedence.
+	Log string `json:"log"`
+	// LogMaxFiles is how many rotated log files are kept. Zero or less
+	// keeps 3.
+	LogMaxFiles int `json:"logMaxFiles"`
+	// Client is taken from the initialize clientInfo parameter.
+	Client ClientInfo `json:"-"`
+	// Trace is taken from the initialize trace parameter and changed with
+	// $/setTrace. Unless it is "off", messages to and from the client are
+	// logged at debug level.
+	Trace string `json:"-"`
+	// SnippetSupport is taken from the client's completion capabilities
+	// rather than from initializationOptions.
+	SnippetSupport bool `json:"-"`
+	// ShowDocumentSupport is taken from the client's window capabilities
+	// and tells whether the server may ask it to open documents.
+	ShowDocumentSupport bool `json:"-"`
+	// CodeActionDisabledSupport is taken from the client's code action
+	// capabilities and tells whether it shows disabled actions with their
+	// reason.
+	CodeActionDisabledSupport bool `json:"-"`
+}
+
+// FormatSettings holds formatting defaults for package documents, content
+// and navigation documents, and stylesheets.
+type FormatSettings struct {
+	OPF   FileFormatSettings `json:"opf"`
+	XHTML FileFormatSettings `json:"xhtml"`
+	CSS   FileFormatSettings `json:"css"`
+}
+
+// FileFormatSettings holds the formatting defaults of one file type.
+type FileFormatSettings struct {
+	// Indent is the string indenting one level, such as "  " or "\t".
+	// Empty leaves it to .editorconfig.
+	Indent string `json:"indent"`
 }
 
 // InitializeParams holds parameters for the initialize request.
 type InitializeParams struct {
-	ProcessId             int             `json:"processId"`
-	Capabilities          map[string]any  `json:"capabilities"`
-	ClientInfo            ClientInfo      `json:"clientInfo"`
-	RootUri               string          `json:"rootUri"`
-	WorkspaceFolders      any             `json:"workspaceFolders"`
-	InitializationOptions *ServerSettings `json:"initializationOptions"`
+	ProcessId             int               `json:"processId"`
+	Capabilities          map[string]any    `json:"capabilities"`
+	ClientInfo            ClientInfo        `json:"clientInfo"`
+	RootUri               string            `json:"rootUri"`
+	WorkspaceFolders      []WorkspaceFolder `json:"workspaceFolders"`
+	InitializationOptions *ServerSettings   `json:"initializationOptions"`
+	Trace                 string            `json:"trace"`
+}
+
+// WorkspaceFolder describes a workspace folder opened by the client.
+type WorkspaceFolder struct {
+	Uri  string `json:"uri"`
+	Name string `json:"name"`
 }
 
 // ClientInfo describes the connecting editor.
@@ -102,6 +243,11 @@ type CodeActionOptions struct {
 	CodeActionKinds []string `json:"codeActionKinds,omitempty"`
 }
 
+// ExecuteCommandOptions lists the commands served by workspace/executeCommand.
+type ExecuteCommandOptions struct {
+	Commands []string `json:"commands"`
+}
+
 // ServerCapabilities describes the capabilities this server supports.
 type ServerCapabilities struct {
 	TextDocumentSync           int                    `json:"textDocumentSync"`
@@ -109,11 +255,44 @@ type ServerCapabilities struct {
 	DocumentSymbolProvider     bool                   `json:"documentSymbolProvider,omitempty"`
 	DefinitionProvider         bool                   `json:"definitionProvider,omitempty"`
 	ReferencesProvider         bool                   `json:"referencesProvider,omitempty"`
+	RenameProvider             bool                   `json:"renameProvider,omitempty"`
 	HoverProvider              bool                   `json:"hoverProvider,omitempty"`
 	CodeActionProvider         *CodeActionOptions     `json:"codeActionProvider,omitempty"`
 	CompletionProvider         *CompletionOptions     `json:"completionProvider,omitempty"`
+	SignatureHelpProvider      *SignatureHelpOptions  `json:"signatureHelpProvider,omitempty"`
 	DocumentFormattingProvider bool                   `json:"documentFormattingProvider,omitempty"`
 	SemanticTokensProvider     *SemanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+	ExecuteCommandProvider     *ExecuteCommandOptions `json:"executeCommandProvider,omitempty"`
+	CodeLensProvider           *CodeLensOptions       `json:"codeLensProvider,omitempty"`
+	ColorProvider              bool                   `json:"colorProvider,omitempty"`
+	LinkedEditingRangeProvider bool                   `json:"linkedEditingRangeProvider,omitempty"`
+	Workspace                  *WorkspaceCapabilities `json:"workspace,omitempty"`
+}
+
+// WorkspaceCapabilities describes workspace-level capabilities.
+type WorkspaceCapabilities struct {
+	FileOperations *FileOperationOptions `json:"fileOperations,omitempty"`
+}
+
+// FileOperationOptions lists the file operations the server is notified of.
+type FileOperationOptions struct {
+	WillRename *FileOperationRegistrationOptions `json:"willRename,omitempty"`
+}
+
+// FileOperationRegistrationOptions selects the files an operation applies to.
+type FileOperationRegistrationOptions struct {
+	Filters []FileOperationFilter `json:"filters"`
+}
+
+// FileOperationFilter matches files by URI scheme and glob pattern.
+type FileOperationFilter struct {
+	Scheme  string               `json:"scheme,omitempty"`
+	Pattern FileOperationPattern `json:"pattern"`
+}
+
+// FileOperationPattern is a glob pattern for file operation filters.
+type FileOperationPattern struct {
+	Glob string `json:"glob"`
 }
 
 // SemanticTokensLegend describes the token types and modifiers used by semantic tokens.
@@ -158,6 +337,11 @@ type CompletionOptions struct {
 	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
 }
 
+// SignatureHelpOptions describes signature help capabilities.
+type SignatureHelpOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+}
+
 // InitializeResult is the response to the initialize request.
 type InitializeResult struct {
 	Capabilities ServerCapabilities `json:"capabilities"`
@@ -171,8 +355,11 @@ type ServerInfo struct {
 }
 
 // PublishDiagnosticsParams holds parameters for publishing diagnostics.
+// Version is the document version the diagnostics were computed from, or
+// nil when the file is not open in the client.
 type PublishDiagnosticsParams struct {
 	Uri         string       `json:"uri"`
+	Version     *int         `json:"version,omitempty"`
 	Diagnostics []Diagnostic `json:"diagnostics"`
 }
 
@@ -183,6 +370,16 @@ type Diagnostic struct {
 	Severity int    `json:"severity"`
 	Code     string `json:"code,omitempty"`
 	Source   string `json:"source,omitempty"`
+	// Data carries the structured fix for auto-fixable diagnostics; clients
+	// return it unchanged in codeAction requests.
+	Data *epub.FixData `json:"data,omitempty"`
+	// CodeDescription links to the documentation of Code.
+	CodeDescription *CodeDescription `json:"codeDescription,omitempty"`
+}
+
+// CodeDescription holds the documentation URL of a diagnostic code.
+type CodeDescription struct {
+	Href string `json:"href"`
 }
 
 // Position represents a position in a text document.
@@ -210,7 +407,8 @@ type TextDocumentIdentifier struct {
 	Uri string `json:"uri"`
 }
 
-// ProcessInitializeRequest handles the initialize request.
+// ProcessInitializeRequest handles the initialize request. When the params
+// are malformed, settings is nil and response is an invalid params error.
 func ProcessInitializeRequest(
 	data []byte,
 	lspName, lspVersion string,
@@ -219,9 +417,9 @@ func ProcessInitializeRequest(
 
 	err := json.Unmarshal(data, &req)
 	if err != nil {
-		msg := "error while unmarshalling data during 'initialize' phase: " + err.Error()
-		slog.Error(msg)
-		panic(msg)
+		slog.Error("error while unmarshalling data during 'initialize' phase: " + err.Error())
+		return marshalErrorResponse(req.Id, ErrorInvalidParams, "invalid 'initialize' params"),
+			"", nil
 	}
 
 	res := ResponseMessage[InitializeResult]{
@@ -234,6 +432,7 @@ func ProcessInitializeRequest(
 				DocumentSymbolProvider: true,
 				DefinitionProvider:     true,
 				ReferencesProvider:     true,
+				RenameProvider:         true,
 				HoverProvider:          true,
 				CodeActionProvider: &CodeActionOptions{
 					CodeActionKinds: []string{"quickfix", "source.fixAll"},
@@ -241,6 +440,9 @@ func ProcessInitializeRequest(
 				CompletionProvider: &CompletionOptions{
 					TriggerCharacters: []string{"<", "\"", ":", " "},
 				},
+				SignatureHelpProvider: &SignatureHelpOptions{
+					TriggerCharacters: []string{",", ">"},
+				},
 				DocumentFormattingProvider: true,
 				SemanticTokensProvider: &SemanticTokensOptions{
 					Legend: SemanticTokensLegend{
@@ -249,6 +451,22 @@ func ProcessInitializeRequest(
 					},
 					Full: true,
 				},
+				ExecuteCommandProvider: &ExecuteCommandOptions{
+					Commands: Commands,
+				},
+				CodeLensProvider:           &CodeLensOptions{},
+				ColorProvider:              true,
+				LinkedEditingRangeProvider: true,
+				Workspace: &WorkspaceCapabilities{
+					FileOperations: &FileOperationOptions{
+						WillRename: &FileOperationRegistrationOptions{
+							Filters: []FileOperationFilter{{
+								Scheme:  "file",
+								Pattern: FileOperationPattern{Glob: "**/*"},
+							}},
+						},
+					},
+				},
 			},
 			ServerInfo: ServerInfo{
 				Name:    lspName,
@@ -259,12 +477,46 @@ func ProcessInitializeRequest(
 
 	response, err = json.Marshal(res)
 	if err != nil {
-		msg := "error while marshalling data during 'initialize' phase: " + err.Error()
-		slog.Error(msg)
-		panic(msg)
+		slog.Error("error while marshalling data during 'initialize' phase: " + err.Error())
 	}
 
-	return response, req.Params.RootUri, req.Params.InitializationOptions
+	rootURI = req.Params.RootUri
+	if len(req.Params.WorkspaceFolders) > 0 {
+		rootURI = req.Params.WorkspaceFolders[0].Uri
+	}
+
+	settings = req.Params.InitializationOptions
+	if settings == nil {
+		settings = &ServerSettings{}
+	}
+	settings.SnippetSupport = capabilityEnabled(req.Params.Capabilities,
+		"textDocument", "completion", "completionItem", "snippetSupport")
+	settings.ShowDocumentSupport = capabilityEnabled(req.Params.Capabilities,
+		"window", "showDocument", "support")
+	settings.CodeActionDisabledSupport = capabilityEnabled(req.Params.Capabilities,
+		"textDocument", "codeAction", "disabledSupport")
+	settings.Client = req.Params.ClientInfo
+	settings.Trace = TraceOff
+	if validTrace(req.Params.Trace) {
+		settings.Trace = req.Params.Trace
+	}
+
+	return response, rootURI, settings
+}
+
+// capabilityEnabled reports whether the boolean client capability at the
+// given key path is true.
+func capabilityEnabled(capabilities map[string]any, keys ...string) bool {
+	var value any = capabilities
+	for _, key := range keys {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return false
+		}
+		value = m[key]
+	}
+	enabled, _ := value.(bool)
+	return enabled
 }
 
 // ProcessShutdownRequest handles the shutdown request.
@@ -278,9 +530,8 @@ func ProcessShutdownRequest(jsonVersion string, requestId ID) []byte {
 
 	responseText, err := json.Marshal(response)
 	if err != nil {
-		msg := "error while marshalling shutdown response: " + err.Error()
-		slog.Error(msg)
-		panic(msg)
+		slog.Error("error while marshalling shutdown response: " + err.Error())
+		return nil
 	}
 
 	return responseText
@@ -300,9 +551,69 @@ func ProcessIllegalRequestAfterShutdown(jsonVersion string, requestId ID) []byte
 
 	responseText, err := json.Marshal(response)
 	if err != nil {
-		msg := "error while marshalling error response: " + err.Error()
-		slog.Error(msg)
-		panic(msg)
+		slog.Error("error while marshalling error response: " + err.Error())
+		return nil
+	}
+
+	return responseText
+}
+
+// ProcessInvalidRequest returns an error for a request sent without an ID,
+// which JSON-RPC answers with a null ID.
+func ProcessInvalidRequest(method string) []byte {
+	return marshalErrorResponse("", ErrorInvalidRequest,
+		"'"+method+"' must be a request with an id")
+}
+
+// ProcessInvalidMessage returns the error for valid JSON that is not a
+// request object, such as an array or a message with a boolean ID.
+func ProcessInvalidMessage() []byte {
+	return marshalErrorResponse("", ErrorInvalidRequest, "message is not a valid request")
+}
+
+// ProcessParseError returns the error for a message that is not valid
+// JSON, which JSON-RPC answers with a null ID.
+func ProcessParseError() []byte {
+	return marshalErrorResponse("", ErrorParseError, "message is not valid JSON")
+}
+
+// ProcessMethodNotFound returns an error for requests the server does not handle.
+func ProcessMethodNotFound(jsonVersion string, requestId ID, method string) []byte {
+	response := ResponseMessage[any]{
+		JsonRpc: jsonVersion,
+		Id:      requestId,
+		Result:  nil,
+		Error: &ResponseError{
+			Code:    ErrorMethodNotFound,
+			Message: "method not supported: " + method,
+		},
+	}
+
+	responseText, err := json.Marshal(response)
+	if err != nil {
+		slog.Error("error while marshalling error response: " + err.Error())
+		return nil
+	}
+
+	return responseText
+}
+
+// ProcessInternalError returns an error for a request whose handler failed.
+func ProcessInternalError(jsonVersion string, requestId ID, message string) []byte {
+	response := ResponseMessage[any]{
+		JsonRpc: jsonVersion,
+		Id:      requestId,
+		Result:  nil,
+		Error: &ResponseError{
+			Code:    ErrorInternalError,
+			Message: message,
+		},
+	}
+
+	responseText, err := json.Marshal(response)
+	if err != nil {
+		slog.Error("error while marshalling error response: " + err.Error())
+		return nil
 	}
 
 	return responseText
@@ -313,20 +624,21 @@ type DidOpenTextDocumentParams struct {
 	TextDocument TextDocumentItem `json:"textDocument"`
 }
 
-// ProcessDidOpenTextDocumentNotification handles textDocument/didOpen.
+// ProcessDidOpenTextDocumentNotification handles textDocument/didOpen. It
+// returns an empty URI when the params are malformed.
 func ProcessDidOpenTextDocumentNotification(
 	data []byte,
-) (fileURI string, fileContent []byte) {
+) (fileURI string, fileContent []byte, version int, languageID string) {
 	request := RequestMessage[DidOpenTextDocumentParams]{}
 
 	err := json.Unmarshal(data, &request)
 	if err != nil {
-		msg := "error while unmarshalling 'textDocument/didOpen': " + err.Error()
-		slog.Error(msg)
-		panic(msg)
+		slog.Warn("ignoring malformed 'textDocument/didOpen': " + err.Error())
+		return "", nil, 0, ""
 	}
 
-	return request.Params.TextDocument.Uri, []byte(request.Params.TextDocument.Text)
+	doc := request.Params.TextDocument
+	return doc.Uri, []byte(doc.Text), doc.Version, doc.LanguageId
 }
 
 // TextDocumentContentChangeEvent represents a content change event.
@@ -341,25 +653,43 @@ type DidChangeTextDocumentParams struct {
 }
 
 // ProcessDidChangeTextDocumentNotification handles textDocument/didChange.
+// It returns an empty URI when the params are malformed.
 func ProcessDidChangeTextDocumentNotification(
 	data []byte,
-) (fileURI string, fileContent []byte) {
+) (fileURI string, fileContent []byte, version int) {
 	var request RequestMessage[DidChangeTextDocumentParams]
 
 	err := json.Unmarshal(data, &request)
 	if err != nil {
-		msg := "error while unmarshalling 'textDocument/didChange': " + err.Error()
-		slog.Error(msg)
-		panic(msg)
+		slog.Warn("ignoring malformed 'textDocument/didChange': " + err.Error())
+		return "", nil, 0
 	}
 
 	changes := request.Params.ContentChanges
 	if len(changes) == 0 {
 		slog.Warn("'contentChanges' field is empty")
-		return "", nil
+		return "", nil, 0
 	}
 
-	return request.Params.TextDocument.Uri, []byte(changes[0].Text)
+	doc := request.Params.TextDocument
+	return doc.Uri, []byte(changes[0].Text), doc.Version
+}
+
+// CancelParams holds parameters for $/cancelRequest.
+type CancelParams struct {
+	Id *ID `json:"id"`
+}
+
+// ProcessCancelRequestNotification handles $/cancelRequest, returning the
+// ID of the request to cancel. It reports false when the ID is missing or
+// malformed.
+func ProcessCancelRequestNotification(data []byte) (ID, bool) {
+	var request RequestMessage[CancelParams]
+	if err := json.Unmarshal(data, &request); err != nil || request.Params.Id == nil {
+		slog.Warn("ignoring malformed '$/cancelRequest'")
+		return "", false
+	}
+	return *request.Params.Id, true
 }
 
 // --- New LSP types for interactive features ---
@@ -456,6 +786,34 @@ type Hover struct {
 	Range    *Range        `json:"range,omitempty"`
 }
 
+// SignatureHelpParams holds parameters for textDocument/signatureHelp.
+type SignatureHelpParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// SignatureHelp describes the signatures available at a position and which
+// parameter is being edited.
+type SignatureHelp struct {
+	Signatures      []SignatureInformation `json:"signatures"`
+	ActiveSignature uint                   `json:"activeSignature"`
+	ActiveParameter uint                   `json:"activeParameter"`
+}
+
+// SignatureInformation describes one signature and its parameters.
+type SignatureInformation struct {
+	Label         string                 `json:"label"`
+	Documentation string                 `json:"documentation,omitempty"`
+	Parameters    []ParameterInformation `json:"parameters,omitempty"`
+}
+
+// ParameterInformation describes a parameter of a signature. Label is a
+// substring of the signature label.
+type ParameterInformation struct {
+	Label         string `json:"label"`
+	Documentation string `json:"documentation,omitempty"`
+}
+
 // MarkupContent represents documentation content.
 type MarkupContent struct {
 	Kind  string `json:"kind"`
@@ -477,10 +835,20 @@ type CodeActionContext struct {
 
 // CodeAction represents a code action.
 type CodeAction struct {
-	Title       string         `json:"title"`
-	Kind        string         `json:"kind,omitempty"`
-	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
-	Edit        *WorkspaceEdit `json:"edit,omitempty"`
+	Title       string       `json:"title"`
+	Kind        string       `json:"kind,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// IsPreferred marks the safest of the fixes for a diagnostic, which
+	// clients apply for auto-fix.
+	IsPreferred bool `json:"isPreferred,omitempty"`
+	// Disabled explains why the action cannot be applied now.
+	Disabled *CodeActionDisabled `json:"disabled,omitempty"`
+	Edit     *WorkspaceEdit      `json:"edit,omitempty"`
+}
+
+// CodeActionDisabled gives the reason a code action is disabled.
+type CodeActionDisabled struct {
+	Reason string `json:"reason"`
 }
 
 // WorkspaceEdit represents changes to workspace resources.
@@ -494,6 +862,24 @@ type TextEdit struct {
 	NewText string `json:"newText"`
 }
 
+// RenameParams holds parameters for textDocument/rename.
+type RenameParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+	NewName      string                 `json:"newName"`
+}
+
+// RenameFilesParams holds parameters for workspace/willRenameFiles.
+type RenameFilesParams struct {
+	Files []FileRename `json:"files"`
+}
+
+// FileRename describes a file or directory being renamed.
+type FileRename struct {
+	OldUri string `json:"oldUri"`
+	NewUri string `json:"newUri"`
+}
+
 // CompletionParams holds parameters for textDocument/completion.
 type CompletionParams struct {
 	TextDocument TextDocumentIdentifier `json:"textDocument"`
@@ -507,8 +893,20 @@ type CompletionItem struct {
 	Detail        string `json:"detail,omitempty"`
 	Documentation string `json:"documentation,omitempty"`
 	InsertText    string `json:"insertText,omitempty"`
+	SortText      string `json:"sortText,omitempty"`
+	Preselect     bool   `json:"preselect,omitempty"`
+	// InsertTextFormat is InsertTextFormatSnippet when the insert text or
+	// edit contains tab stops.
+	InsertTextFormat int       `json:"insertTextFormat,omitempty"`
+	TextEdit         *TextEdit `json:"textEdit,omitempty"`
 }
 
+// Insert text format constants.
+const (
+	InsertTextFormatPlainText = 1
+	InsertTextFormatSnippet   = 2
+)
+
 // Completion kind constants.
 const (
 	CompletionKindText     = 1
@@ -516,6 +914,7 @@ const (
 	CompletionKindValue    = 12
 	CompletionKindEnum     = 13
 	CompletionKindKeyword  = 14
+	CompletionKindSnippet  = 15
 )
 
 // CompletionList represents a list of completion items.
@@ -530,8 +929,13 @@ type DocumentFormattingParams struct {
 	Options      FormattingOptions      `json:"options"`
 }
 
-// FormattingOptions describes formatting options.
+// FormattingOptions describes formatting options. The protocol requires
+// tabSize and insertSpaces, but some clients leave them out, so each is
+// nil when not sent.
 type FormattingOptions struct {
-	TabSize      int  `json:"tabSize"`
-	InsertSpaces bool `json:"insertSpaces"`
+	TabSize      *int  `json:"tabSize,omitempty"`
+	InsertSpaces *bool `json:"insertSpaces,omitempty"`
+	// InsertFinalNewline, when false, keeps a document that lacks a final
+	// newline without one.
+	InsertFinalNewline *bool `json:"insertFinalNewline,omitempty"`
 }
@@ -0,0 +1,211 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+func TestProcessDidOpenReturnsVersion(t *testing.T) {
+	data := makeRequest(t, 0, MethodDidOpen, DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{
+			Uri:        "file:///book/chapter.xhtml",
+			Version:    4,
+			LanguageId: "xhtml",
+			Text:       "<html/>",
+		},
+	})
+
+	uri, content, version, languageID := ProcessDidOpenTextDocumentNotification(data)
+	if uri != "file:///book/chapter.xhtml" || string(content) != "<html/>" {
+		t.Errorf("unexpected document %q: %q", uri, content)
+	}
+	if version != 4 {
+		t.Errorf("expected version 4, got %d", version)
+	}
+	if languageID != "xhtml" {
+		t.Errorf("expected language xhtml, got %q", languageID)
+	}
+}
+
+func TestProcessDidChangeReturnsVersion(t *testing.T) {
+	data := makeRequest(t, 0, MethodDidChange, DidChangeTextDocumentParams{
+		TextDocument: TextDocumentItem{Uri: "file:///book/chapter.xhtml", Version: 7},
+		ContentChanges: []TextDocumentContentChangeEvent{
+			{Text: "<html></html>"},
+		},
+	})
+
+	_, content, version := ProcessDidChangeTextDocumentNotification(data)
+	if string(content) != "<html></html>" {
+		t.Errorf("unexpected content %q", content)
+	}
+	if version != 7 {
+		t.Errorf("expected version 7, got %d", version)
+	}
+}
+
+func TestProcessInitializePrefersWorkspaceFolder(t *testing.T) {
+	data := makeRequest(t, 1, MethodInitialize, InitializeParams{
+		RootUri: "file:///old",
+		WorkspaceFolders: []WorkspaceFolder{
+			{Uri: "file:///book", Name: "book"},
+		},
+	})
+
+	_, rootURI, _ := ProcessInitializeRequest(data, "epub-lsp", "test")
+	if rootURI != "file:///book" {
+		t.Errorf("expected workspace folder root, got %q", rootURI)
+	}
+}
+
+func TestPublishDiagnosticsNotificationOmitsNilVersion(t *testing.T) {
+	data := PublishDiagnosticsNotification("file:///book/style.css", nil, nil)
+
+	var raw struct {
+		Params map[string]json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw.Params["version"]; ok {
+		t.Error("expected version to be omitted")
+	}
+	if string(raw.Params["diagnostics"]) != "[]" {
+		t.Errorf("expected empty diagnostics array, got %s", raw.Params["diagnostics"])
+	}
+}
+
+func TestPublishDiagnosticsNotificationCodeDescription(t *testing.T) {
+	data := PublishDiagnosticsNotification("file:///book/ch1.xhtml", nil,
+		[]epub.Diagnostic{{Code: "HTM_008"}, {Code: "UNKNOWN_123"}})
+
+	var msg NotificationMessage[PublishDiagnosticsParams]
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	diags := msg.Params.Diagnostics
+	if diags[0].CodeDescription == nil ||
+		diags[0].CodeDescription.Href != epub.CodeURL("HTM_008") {
+		t.Errorf("expected HTM_008 documentation link, got %+v", diags[0].CodeDescription)
+	}
+	if diags[1].CodeDescription != nil {
+		t.Errorf("expected no link for unknown code, got %+v", diags[1].CodeDescription)
+	}
+}
+
+func TestProcessInitializeReadsSnippetSupport(t *testing.T) {
+	data := makeRequest(t, 1, MethodInitialize, InitializeParams{
+		Capabilities: map[string]any{
+			"textDocument": map[string]any{
+				"completion": map[string]any{
+					"completionItem": map[string]any{"snippetSupport": true},
+				},
+			},
+		},
+	})
+
+	_, _, settings := ProcessInitializeRequest(data, "epub-lsp", "test")
+	if settings == nil || !settings.SnippetSupport {
+		t.Error("expected snippet support from client capabilities")
+	}
+
+	data = makeRequest(t, 2, MethodInitialize, InitializeParams{})
+	_, _, settings = ProcessInitializeRequest(data, "epub-lsp", "test")
+	if settings == nil || settings.SnippetSupport {
+		t.Error("expected snippet support to default to false")
+	}
+}
+
+func TestProcessInitializeReadsCodeActionDisabledSupport(t *testing.T) {
+	data := makeRequest(t, 1, MethodInitialize, InitializeParams{
+		Capabilities: map[string]any{
+			"textDocument": map[string]any{
+				"codeAction": map[string]any{"disabledSupport": true},
+			},
+		},
+	})
+
+	_, _, settings := ProcessInitializeRequest(data, "epub-lsp", "test")
+	if settings == nil || !settings.CodeActionDisabledSupport {
+		t.Error("expected disabled code action support from client capabilities")
+	}
+}
+
+func TestProcessInitializeReadsClientInfo(t *testing.T) {
+	data := makeRequest(t, 1, MethodInitialize, InitializeParams{
+		ClientInfo: ClientInfo{Name: "Zed", Version: "0.150.0"},
+	})
+	_, _, settings := ProcessInitializeRequest(data, "epub-lsp", "test")
+	if settings.Client != (ClientInfo{Name: "Zed", Version: "0.150.0"}) {
+		t.Errorf("expected the client info, got %+v", settings.Client)
+	}
+}
+
+func TestProcessInitializeReadsShowDocumentSupport(t *testing.T) {
+	data := makeRequest(t, 1, MethodInitialize, InitializeParams{
+		Capabilities: map[string]any{
+			"window": map[string]any{
+				"showDocument": map[string]any{"support": true},
+			},
+		},
+	})
+
+	_, _, settings := ProcessInitializeRequest(data, "epub-lsp", "test")
+	if settings == nil || !settings.ShowDocumentSupport {
+		t.Error("expected showDocument support from client capabilities")
+	}
+
+	data = makeRequest(t, 2, MethodInitialize, InitializeParams{})
+	_, _, settings = ProcessInitializeRequest(data, "epub-lsp", "test")
+	if settings == nil || settings.ShowDocumentSupport {
+		t.Error("expected showDocument support to default to false")
+	}
+}
+
+func TestIDEchoesRequestID(t *testing.T) {
+	for _, raw := range []string{`7`, `"7"`, `"a1"`, `null`} {
+		var request RequestMessage[any]
+		if err := json.Unmarshal([]byte(`{"id":`+raw+`}`), &request); err != nil {
+			t.Fatalf("%s: %v", raw, err)
+		}
+		got, err := json.Marshal(request.Id)
+		if err != nil || string(got) != raw {
+			t.Errorf("%s: echoed as %s (%v)", raw, got, err)
+		}
+	}
+	for _, raw := range []string{`1.5`, `true`, `{}`} {
+		var request RequestMessage[any]
+		if err := json.Unmarshal([]byte(`{"id":`+raw+`}`), &request); err == nil {
+			t.Errorf("%s: expected an error", raw)
+		}
+	}
+}
+
+func TestResponseHasEitherResultOrError(t *testing.T) {
+	tests := []struct {
+		name     string
+		response []byte
+		key      string
+	}{
+		{"null result", ProcessShutdownRequest(JSONRPCVersion, NumberID(1)), "result"},
+		{"result", marshalResponse(NumberID(2), []string{}), "result"},
+		{"error", ProcessMethodNotFound(JSONRPCVersion, NumberID(3), "x"), "error"},
+		{"null id error", ProcessParseError(), "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(tt.response, &fields); err != nil {
+				t.Fatal(err)
+			}
+			_, hasResult := fields["result"]
+			_, hasError := fields["error"]
+			if hasResult != (tt.key == "result") || hasError != (tt.key == "error") {
+				t.Errorf("expected only %q in %s", tt.key, tt.response)
+			}
+		})
+	}
+}
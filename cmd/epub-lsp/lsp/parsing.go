@@ -0,0 +1,130 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// MaxMessageSize bounds a single JSON-RPC message body. Whole documents are
+// sent on every change, so bufio's 64KB default is far too small.
+const MaxMessageSize = 64 << 20
+
+// maxHeaderSize bounds how much input is buffered while looking for the end
+// of a header block before the splitter gives up and resynchronizes.
+const maxHeaderSize = 4096
+
+var (
+	headerDelimiter = []byte("\r\n\r\n")
+	contentLength   = []byte("Content-Length:")
+)
+
+// ReceiveInput returns a scanner that yields one JSON-RPC message body per
+// call to Scan.
+func ReceiveInput(input io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxMessageSize+maxHeaderSize)
+	scanner.Split(SplitMessage)
+	return scanner
+}
+
+// SplitMessage is a bufio.SplitFunc that extracts Content-Length framed
+// message bodies. Headers and bodies may arrive split across any number of
+// reads. Headers other than Content-Length are ignored. A malformed header
+// block, or a body that turns out to be shorter than its declared length,
+// is skipped up to the next "Content-Length:" so one bad message cannot
+// desynchronize the stream.
+func SplitMessage(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	// Skipped bytes are consumed in the same call, since bufio.Scanner stops
+	// at EOF as soon as a split returns no token.
+	for len(data[advance:]) > 0 {
+		n, body := splitOne(data[advance:], atEOF)
+		if body != nil || n == 0 {
+			return advance + n, body, nil
+		}
+		advance += n
+	}
+	return advance, nil, nil
+}
+
+// splitOne extracts a single message from data. It returns the bytes to
+// consume and the message body, a skip count with a nil body when data
+// must be discarded, or zero when more input is needed.
+func splitOne(data []byte, atEOF bool) (int, []byte) {
+	headerEnd := bytes.Index(data, headerDelimiter)
+	if headerEnd < 0 {
+		if len(data) > maxHeaderSize {
+			slog.Warn("no header delimiter found, resynchronizing")
+			return resync(data), nil
+		}
+		if atEOF {
+			return len(data), nil
+		}
+		return 0, nil
+	}
+
+	length, ok := parseContentLength(data[:headerEnd])
+	if !ok {
+		slog.Warn("malformed message header, resynchronizing: " +
+			strconv.Quote(string(data[:headerEnd])))
+		if next := bytes.Index(data[1:], contentLength); next >= 0 && next < headerEnd {
+			return next + 1, nil
+		}
+		return headerEnd + len(headerDelimiter), nil
+	}
+
+	bodyStart := headerEnd + len(headerDelimiter)
+	body := data[bodyStart:]
+	if len(body) >= length {
+		return bodyStart + length, body[:length]
+	}
+
+	// The declared length overshoots if the next message's header shows up
+	// right after a complete JSON value.
+	if next := bytes.Index(body, contentLength); next >= 0 {
+		if short := bytes.TrimSpace(body[:next]); json.Valid(short) {
+			slog.Warn("Content-Length exceeds message body",
+				"declared", length, "actual", len(short))
+			return bodyStart + next, short
+		}
+	}
+
+	if atEOF {
+		slog.Warn("input ended inside a message body")
+		return len(data), nil
+	}
+	return 0, nil
+}
+
+// parseContentLength returns the Content-Length value from a header block.
+// Every line must be a "Name: value" pair and Content-Length is required.
+func parseContentLength(header []byte) (int, bool) {
+	length := -1
+	for line := range strings.SplitSeq(string(header), "\r\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return 0, false
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 || n > MaxMessageSize {
+				return 0, false
+			}
+			length = n
+		}
+	}
+	return length, length >= 0
+}
+
+// resync returns how many bytes to skip to reach the next plausible header.
+func resync(data []byte) int {
+	if next := bytes.Index(data[1:], contentLength); next >= 0 {
+		return next + 1
+	}
+	// Keep a tail that may hold the start of a split header name.
+	return len(data) - len(contentLength) + 1
+}
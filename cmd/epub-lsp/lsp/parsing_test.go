@@ -1,24 +1,35 @@
 package lsp
 
 import (
+	"bufio"
 	"bytes"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/toba/lsp/transport"
 )
 
+// scanAll returns every message body produced by ReceiveInput.
+func scanAll(t *testing.T, scanner *bufio.Scanner) []string {
+	t.Helper()
+	var messages []string
+	for scanner.Scan() {
+		messages = append(messages, string(scanner.Bytes()))
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scanner error: %v", err)
+	}
+	return messages
+}
+
 func TestEncodeDecode(t *testing.T) {
 	payload := []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
 	encoded := transport.Encode(payload)
 
-	scanner := transport.NewScanner(bytes.NewReader(encoded))
-	if !scanner.Scan() {
-		t.Fatal("expected to scan a token")
-	}
-
-	decoded := scanner.Bytes()
-	if !bytes.Equal(decoded, payload) {
-		t.Errorf("decoded %q, want %q", decoded, payload)
+	messages := scanAll(t, ReceiveInput(bytes.NewReader(encoded)))
+	if len(messages) != 1 || messages[0] != string(payload) {
+		t.Errorf("decoded %q, want %q", messages, payload)
 	}
 }
 
@@ -30,19 +41,73 @@ func TestDecodeMultipleMessages(t *testing.T) {
 	buf.Write(transport.Encode(msg1))
 	buf.Write(transport.Encode(msg2))
 
-	scanner := transport.NewScanner(&buf)
+	messages := scanAll(t, ReceiveInput(&buf))
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if messages[0] != string(msg1) || messages[1] != string(msg2) {
+		t.Errorf("got %q", messages)
+	}
+}
+
+func TestDecodeOneByteChunks(t *testing.T) {
+	payload := []byte(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`)
+	reader := iotest.OneByteReader(bytes.NewReader(transport.Encode(payload)))
 
-	if !scanner.Scan() {
-		t.Fatal("expected first message")
+	messages := scanAll(t, ReceiveInput(reader))
+	if len(messages) != 1 || messages[0] != string(payload) {
+		t.Errorf("decoded %q, want %q", messages, payload)
 	}
-	if !bytes.Equal(scanner.Bytes(), msg1) {
-		t.Errorf("first message: got %q, want %q", scanner.Bytes(), msg1)
+}
+
+func TestDecodeIgnoresContentType(t *testing.T) {
+	payload := `{"id":1}`
+	input := "Content-Length: 8\r\n" +
+		"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n" +
+		payload
+
+	messages := scanAll(t, ReceiveInput(strings.NewReader(input)))
+	if len(messages) != 1 || messages[0] != payload {
+		t.Errorf("decoded %q, want %q", messages, payload)
 	}
+}
+
+func TestDecodeCorruptedHeaderThenValid(t *testing.T) {
+	valid := `{"id":2}`
+	input := "Content-Lenght: 8\r\n\r\n{\"id\":1}" +
+		string(transport.Encode([]byte(valid)))
+
+	messages := scanAll(t, ReceiveInput(strings.NewReader(input)))
+	if len(messages) != 1 || messages[0] != valid {
+		t.Errorf("decoded %q, want [%q]", messages, valid)
+	}
+}
+
+func TestDecodeOverstatedContentLength(t *testing.T) {
+	first := `{"id":1}`
+	second := `{"id":2}`
+	input := "Content-Length: 50\r\n\r\n" + first +
+		string(transport.Encode([]byte(second)))
 
-	if !scanner.Scan() {
-		t.Fatal("expected second message")
+	messages := scanAll(t, ReceiveInput(iotest.HalfReader(strings.NewReader(input))))
+	if len(messages) != 2 || messages[0] != first || messages[1] != second {
+		t.Errorf("decoded %q, want [%q %q]", messages, first, second)
 	}
-	if !bytes.Equal(scanner.Bytes(), msg2) {
-		t.Errorf("second message: got %q, want %q", scanner.Bytes(), msg2)
+}
+
+func TestDecodeBodyContainingHeaderText(t *testing.T) {
+	payload := `{"text":"Content-Length: 3"}`
+	input := string(transport.Encode([]byte(payload)))
+
+	messages := scanAll(t, ReceiveInput(iotest.OneByteReader(strings.NewReader(input))))
+	if len(messages) != 1 || messages[0] != payload {
+		t.Errorf("decoded %q, want %q", messages, payload)
+	}
+}
+
+func TestSplitMessageWaitsForBody(t *testing.T) {
+	advance, token, err := SplitMessage([]byte("Content-Length: 8\r\n\r\n{\"id"), false)
+	if err != nil || advance != 0 || token != nil {
+		t.Errorf("expected to request more data, got %d %q %v", advance, token, err)
 	}
 }
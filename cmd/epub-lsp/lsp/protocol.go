@@ -7,7 +7,13 @@ const (
 
 	TextDocumentSyncFull = 1
 
+	ErrorParseError     = -32700
 	ErrorInvalidRequest = -32600
+	ErrorMethodNotFound = -32601
+	ErrorInvalidParams  = -32602
+	ErrorInternalError  = -32603
+
+	ErrorRequestCancelled = -32800
 )
 
 // LSP method names.
@@ -16,6 +22,8 @@ const (
 	MethodInitialized        = "initialized"
 	MethodShutdown           = "shutdown"
 	MethodExit               = "exit"
+	MethodCancelRequest      = "$/cancelRequest"
+	MethodSetTrace           = "$/setTrace"
 	MethodDidOpen            = "textDocument/didOpen"
 	MethodDidChange          = "textDocument/didChange"
 	MethodDidClose           = "textDocument/didClose"
@@ -27,6 +35,22 @@ const (
 	MethodHover              = "textDocument/hover"
 	MethodCodeAction         = "textDocument/codeAction"
 	MethodCompletion         = "textDocument/completion"
+	MethodSignatureHelp      = "textDocument/signatureHelp"
 	MethodFormatting         = "textDocument/formatting"
 	MethodSemanticTokensFull = "textDocument/semanticTokens/full"
+	MethodExecuteCommand     = "workspace/executeCommand"
+	MethodWillRenameFiles    = "workspace/willRenameFiles"
+	MethodRename             = "textDocument/rename"
+	MethodCodeLens           = "textDocument/codeLens"
+	MethodDocumentColor      = "textDocument/documentColor"
+	MethodShowDocument       = "window/showDocument"
+	MethodLogMessage         = "window/logMessage"
+
+	MethodDidChangeConfiguration = "workspace/didChangeConfiguration"
+	MethodColorPresentation      = "textDocument/colorPresentation"
+	MethodLinkedEditingRange     = "textDocument/linkedEditingRange"
+
+	// MethodHealth is a custom request for editor extensions that show the
+	// server's state.
+	MethodHealth = "$/epubLsp/health"
 )
@@ -1,19 +1,21 @@
 package lsp
 
 import (
+	"context"
 	"encoding/json"
-	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
 )
 
 // HandleReferences processes textDocument/references requests.
-func HandleReferences(data []byte, ws WorkspaceReader) []byte {
+func HandleReferences(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
 	var req RequestMessage[ReferenceParams]
 	if err := json.Unmarshal(data, &req); err != nil {
-		slog.Error("error unmarshalling references: " + err.Error())
+		Logger(ctx).Error("error unmarshalling references: " + err.Error())
 		return marshalResponse(req.Id, []Location{})
 	}
 
@@ -29,6 +31,19 @@ func HandleReferences(data []byte, ws WorkspaceReader) []byte {
 		return marshalResponse(req.Id, []Location{})
 	}
 
+	// Template names are referenced across files whether or not the XML
+	// parses
+	if ref := templateRefAt(uri, content, offset); ref != nil {
+		refs := workspaceTemplateRefs(ctx, ref.name, ws)
+		if ctx.Err() != nil {
+			return cancelledResponse(req.Id)
+		}
+		locations := templateLocations(refs, ws,
+			req.Params.Context.IncludeDeclaration, true)
+		newRangeClamper(ctx, ws, MethodReferences).locations(locations)
+		return marshalResponse(req.Id, locations)
+	}
+
 	root, xmlDiags := parser.Parse(content)
 	if len(xmlDiags) > 0 {
 		return marshalResponse(req.Id, []Location{})
@@ -44,35 +59,76 @@ func HandleReferences(data []byte, ws WorkspaceReader) []byte {
 
 	switch fileType {
 	case epub.FileTypeOPF:
-		locations = referencesInOPF(result, uri, ws)
+		locations = referencesInOPF(ctx, result, content, uri, root, ws)
 	case epub.FileTypeXHTML, epub.FileTypeNav:
-		locations = referencesInXHTML(result, uri, ws)
+		locations = referencesInXHTML(ctx, result, uri, ws)
+	}
+	if ctx.Err() != nil {
+		return cancelledResponse(req.Id)
 	}
 
+	newRangeClamper(ctx, ws, MethodReferences).locations(locations)
 	return marshalResponse(req.Id, locations)
 }
 
 func referencesInOPF(
+	ctx context.Context,
 	result *parser.LocateResult,
+	content []byte,
 	uri string,
+	root *parser.XMLNode,
 	ws WorkspaceReader,
 ) []Location {
 	node := result.Node
+	id := node.Attr("id")
+	if id == "" {
+		return nil
+	}
 
 	// On <item id="x"> → find all <itemref idref="x"> and href references
 	if node.Local == "item" {
-		id := node.Attr("id")
 		href := node.Attr("href")
-		if id == "" {
-			return nil
-		}
-		return findManifestItemReferences(id, href, uri, ws)
+		return append(
+			findManifestItemReferences(ctx, id, href, uri, ws),
+			findRefinesReferences(root, content, uri, id)...)
+	}
+
+	// On a metadata element with id="x" → find all refines="#x"
+	if metadata := root.FindFirst("metadata"); metadata != nil &&
+		slices.Contains(metadata.Children, node) {
+		return findRefinesReferences(root, content, uri, id)
 	}
 
 	return nil
 }
 
+// findRefinesReferences returns the metadata elements whose refines
+// attribute points at id.
+func findRefinesReferences(
+	root *parser.XMLNode,
+	content []byte,
+	uri, id string,
+) []Location {
+	metadata := root.FindFirst("metadata")
+	if metadata == nil {
+		return nil
+	}
+
+	var locations []Location
+	for _, child := range metadata.Children {
+		if child.Attr("refines") == "#"+id {
+			pos := epub.ByteOffsetToPosition(content, int(child.Offset))
+			locations = append(locations, Location{
+				URI:   uri,
+				Range: Range{Start: lspPos(pos), End: lspPos(pos)},
+			})
+		}
+	}
+	return locations
+}
+
 func referencesInXHTML(
+	ctx context.Context,
 	result *parser.LocateResult,
 	uri string,
 	ws WorkspaceReader,
@@ -80,20 +136,26 @@ func referencesInXHTML(
 	node := result.Node
 
 	// On element with id="x" → find all href="...#x" references
-	if result.Attr != nil && result.Attr.Local == "id" && result.InValue {
-		return findIDReferences(result.Attr.Value, uri, ws)
+	if result.OnAttribute() && result.Attr.Local == "id" {
+		return findIDReferences(ctx, result.Attr.Value, uri, ws)
 	}
 
 	// If on the element itself and it has an id, also look for references
 	id := node.Attr("id")
 	if id != "" {
-		return findIDReferences(id, uri, ws)
+		return findIDReferences(ctx, id, uri, ws)
 	}
 
 	return nil
 }
 
-func findManifestItemReferences(id, href, opfURI string, ws WorkspaceReader) []Location {
+// findManifestItemReferences returns the itemrefs naming the manifest item
+// id and the links to its file. It stops early when ctx is cancelled.
+func findManifestItemReferences(
+	ctx context.Context,
+	id, href, opfURI string,
+	ws WorkspaceReader,
+) []Location {
 	var locations []Location
 
 	// Search in OPF for <itemref idref="id">
@@ -113,30 +175,40 @@ func findManifestItemReferences(id, href, opfURI string, ws WorkspaceReader) []L
 		}
 	}
 
-	// Search all files for href references to this item's href
+	// Search all files for href references to this item's file
 	if href != "" {
+		target := uriutil.Path(uriutil.ResolveRelative(opfURI, href))
 		for fileURI, content := range ws.GetAllFiles() {
+			if ctx.Err() != nil {
+				return nil
+			}
 			ft := ws.GetFileType(fileURI)
 			if ft != epub.FileTypeXHTML && ft != epub.FileTypeNav {
 				continue
 			}
 			locations = append(
 				locations,
-				findHrefReferencesInFile(fileURI, content, href)...)
+				findHrefReferencesInFile(fileURI, content, target)...)
 		}
 	}
 
 	return locations
 }
 
-func findIDReferences(id, sourceURI string, ws WorkspaceReader) []Location {
+// findIDReferences returns the links to the element id in sourceURI. It
+// stops early when ctx is cancelled.
+func findIDReferences(
+	ctx context.Context,
+	id, sourceURI string,
+	ws WorkspaceReader,
+) []Location {
 	var locations []Location
-
-	// Determine the filename for this URI to match against hrefs
-	sourcePath := dirFromURI(sourceURI)
-	_ = sourcePath
+	sourcePath := uriutil.Path(sourceURI)
 
 	for fileURI, content := range ws.GetAllFiles() {
+		if ctx.Err() != nil {
+			return nil
+		}
 		ft := ws.GetFileType(fileURI)
 		if ft != epub.FileTypeXHTML && ft != epub.FileTypeNav && ft != epub.FileTypeOPF {
 			continue
@@ -147,14 +219,16 @@ func findIDReferences(id, sourceURI string, ws WorkspaceReader) []Location {
 			continue
 		}
 
-		// Search for href attributes containing #id
-		findHrefWithFragment(root, content, fileURI, id, &locations)
+		// Search for href attributes pointing at #id in the source file
+		findHrefWithFragment(root, content, fileURI, sourcePath, id, &locations)
 	}
 
 	return locations
 }
 
-func findHrefReferencesInFile(fileURI string, content []byte, href string) []Location {
+// findHrefReferencesInFile returns the links in a file to the file at the
+// path target.
+func findHrefReferencesInFile(fileURI string, content []byte, target string) []Location {
 	root, diags := parser.Parse(content)
 	if len(diags) > 0 {
 		return nil
@@ -163,9 +237,8 @@ func findHrefReferencesInFile(fileURI string, content []byte, href string) []Loc
 	var locations []Location
 
 	for _, a := range root.FindAll("a") {
-		aHref := a.Attr("href")
-		stripped := epub.StripFragment(aHref)
-		if stripped == href || strings.HasSuffix(stripped, "/"+href) {
+		resolved := uriutil.ResolveRelative(fileURI, a.Attr("href"))
+		if resolved != "" && uriutil.Path(resolved) == target {
 			pos := epub.ByteOffsetToPosition(content, int(a.Offset))
 			locations = append(locations, Location{
 				URI:   fileURI,
@@ -180,13 +253,14 @@ func findHrefReferencesInFile(fileURI string, content []byte, href string) []Loc
 func findHrefWithFragment(
 	node *parser.XMLNode,
 	content []byte,
-	fileURI, id string,
+	fileURI, targetPath, id string,
 	locations *[]Location,
 ) {
 	for _, attr := range node.Attrs {
 		if attr.Local == "href" {
-			_, fragment, hasFragment := strings.Cut(attr.Value, "#")
-			if hasFragment && fragment == id {
+			filePart, fragment, hasFragment := strings.Cut(attr.Value, "#")
+			if hasFragment && fragment == id &&
+				uriutil.Path(uriutil.ResolveRelative(fileURI, filePart)) == targetPath {
 				pos := epub.ByteOffsetToPosition(content, int(node.Offset))
 				*locations = append(*locations, Location{
 					URI:   fileURI,
@@ -196,6 +270,6 @@ func findHrefWithFragment(
 		}
 	}
 	for _, child := range node.Children {
-		findHrefWithFragment(child, content, fileURI, id, locations)
+		findHrefWithFragment(child, content, fileURI, targetPath, id, locations)
 	}
 }
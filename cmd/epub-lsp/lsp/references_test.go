@@ -1,6 +1,9 @@
 package lsp
 
 import (
+	"context"
+	"fmt"
+	"sync/atomic"
 	"testing"
 
 	"github.com/toba/epub-lsp/internal/epub"
@@ -30,7 +33,7 @@ func TestHandleReferences_ManifestItem(t *testing.T) {
 		Position:     lspPosition,
 	})
 
-	resp := HandleReferences(data, ws)
+	resp := HandleReferences(t.Context(), data, ws)
 	locations := unmarshalResult[[]Location](t, resp)
 
 	// Should find the <itemref idref="ch1"/> reference
@@ -68,7 +71,7 @@ func TestHandleReferences_IDInXHTML(t *testing.T) {
 		Position:     lspPosition,
 	})
 
-	resp := HandleReferences(data, ws)
+	resp := HandleReferences(t.Context(), data, ws)
 	locations := unmarshalResult[[]Location](t, resp)
 
 	if len(locations) == 0 {
@@ -83,10 +86,97 @@ func TestHandleReferences_NoContent(t *testing.T) {
 		Position:     Position{Line: 0, Character: 0},
 	})
 
-	resp := HandleReferences(data, ws)
+	resp := HandleReferences(t.Context(), data, ws)
 	locations := unmarshalResult[[]Location](t, resp)
 
 	if len(locations) != 0 {
 		t.Fatalf("expected 0 locations, got %d", len(locations))
 	}
 }
+
+func TestHandleReferences_Refines(t *testing.T) {
+	ws := newMockWorkspace()
+	ws.files["file:///book/content.opf"] = refinesOPF
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	tests := []struct {
+		at   string
+		want int
+	}{
+		{`<dc:creator id="creator01"`, 2},
+		{`<meta refines="#missing"`, 0},
+	}
+
+	for _, tt := range tests {
+		offset := findSubstring(refinesOPF, tt.at)
+		data := makeRequest(t, 1, MethodReferences, ReferenceParams{
+			TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+			Position:     lspPos(epub.ByteOffsetToPosition(refinesOPF, offset+2)),
+		})
+
+		locations := unmarshalResult[[]Location](t,
+			HandleReferences(t.Context(), data, ws))
+		if len(locations) != tt.want {
+			t.Errorf("%s: expected %d references, got %d", tt.at, tt.want, len(locations))
+		}
+	}
+}
+
+// cancellingWorkspace counts the file types looked up while scanning the
+// workspace and cancels the request after a set number of them.
+type cancellingWorkspace struct {
+	*mockWorkspace
+	cancelAfter int32
+	cancel      context.CancelFunc
+	lookups     atomic.Int32
+}
+
+func (c *cancellingWorkspace) GetFileType(uri string) epub.FileType {
+	if c.lookups.Add(1) == c.cancelAfter {
+		c.cancel()
+	}
+	return c.mockWorkspace.GetFileType(uri)
+}
+
+func TestHandleReferences_Cancelled(t *testing.T) {
+	const fileCount = 500
+	ws := newMockWorkspace()
+	target := []byte(`<html xmlns="http://www.w3.org/1999/xhtml">
+<body><div id="note">Note</div></body>
+</html>`)
+	ws.files["file:///book/target.xhtml"] = target
+	ws.fileTypes["file:///book/target.xhtml"] = epub.FileTypeXHTML
+	for i := range fileCount {
+		uri := fmt.Sprintf("file:///book/ch%03d.xhtml", i)
+		ws.files[uri] = []byte(`<html xmlns="http://www.w3.org/1999/xhtml">
+<body><a href="target.xhtml#note">See note</a></body>
+</html>`)
+		ws.fileTypes[uri] = epub.FileTypeXHTML
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	counting := &cancellingWorkspace{mockWorkspace: ws, cancelAfter: 10, cancel: cancel}
+
+	offset := findSubstring(target, `id="note"`)
+	data := makeRequest(t, 7, MethodReferences, ReferenceParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/target.xhtml"},
+		Position:     lspPos(epub.ByteOffsetToPosition(target, offset+1)),
+	})
+
+	var resp ResponseMessage[[]Location]
+	if err := unmarshalJSON(HandleReferences(ctx, data, counting), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != ErrorRequestCancelled {
+		t.Fatalf("expected a RequestCancelled error, got %+v", resp)
+	}
+	if resp.Id != NumberID(7) {
+		t.Errorf("expected the response to answer request 7, got %s", resp.Id)
+	}
+	// No file is looked up after the lookup that cancelled the request
+	if n := counting.lookups.Load(); n != counting.cancelAfter {
+		t.Errorf("expected the scan to stop after cancellation, "+
+			"but it looked up %d of %d files", n, fileCount+1)
+	}
+}
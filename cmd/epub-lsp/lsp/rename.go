@@ -0,0 +1,251 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+)
+
+// renameAttrs lists the attributes whose values are rewritten when the file
+// they reference is renamed, by local name.
+var renameAttrs = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"poster": true,
+	"data":   true,
+}
+
+// HandleWillRenameFiles processes workspace/willRenameFiles requests. It
+// returns the edits that keep manifest hrefs, links, and CSS url()
+// references pointing at renamed files and directories, or null when none
+// of the renamed files are in the workspace. Files count as in the
+// workspace when they are open or referenced from an open document.
+func HandleWillRenameFiles(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
+	var req RequestMessage[RenameFilesParams]
+	if err := json.Unmarshal(data, &req); err != nil {
+		Logger(ctx).Error("error unmarshalling willRenameFiles: " + err.Error())
+		return marshalResponse[*WorkspaceEdit](req.Id, nil)
+	}
+
+	files := ws.GetAllFiles()
+	uris := slices.Sorted(maps.Keys(files))
+	refs := make(map[string][]fileReference, len(files))
+	known := make(map[string]bool, len(files))
+	for _, uri := range uris {
+		if ctx.Err() != nil {
+			return cancelledResponse(req.Id)
+		}
+		known[uriutil.Path(uri)] = true
+		refs[uri] = fileReferences(uri, files[uri], ws.GetFileType(uri))
+		for _, ref := range refs[uri] {
+			known[ref.target] = true
+		}
+	}
+
+	moved := renamedPaths(req.Params.Files, known)
+	if len(moved) == 0 {
+		return marshalResponse[*WorkspaceEdit](req.Id, nil)
+	}
+
+	changes := make(map[string][]TextEdit)
+	for _, uri := range uris {
+		if edits := renameEdits(uri, files[uri], refs[uri], moved); len(edits) > 0 {
+			changes[uri] = edits
+		}
+	}
+	if len(changes) == 0 {
+		return marshalResponse[*WorkspaceEdit](req.Id, nil)
+	}
+
+	edit := &WorkspaceEdit{Changes: changes}
+	newRangeClamper(ctx, ws, MethodWillRenameFiles).workspaceEdit(edit)
+	return marshalResponse(req.Id, edit)
+}
+
+// HandleRename processes textDocument/rename requests. Only Go template
+// names in define, block, and template actions can be renamed; the quoted
+// name is replaced at every site in the workspace. It returns null when the
+// cursor is not on a template name.
+func HandleRename(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
+	var req RequestMessage[RenameParams]
+	if err := json.Unmarshal(data, &req); err != nil {
+		Logger(ctx).Error("error unmarshalling rename: " + err.Error())
+		return marshalResponse[*WorkspaceEdit](req.Id, nil)
+	}
+
+	uri := req.Params.TextDocument.Uri
+	content := ws.GetContent(uri)
+	if content == nil {
+		return marshalResponse[*WorkspaceEdit](req.Id, nil)
+	}
+	offset := epub.PositionToByteOffset(content, posToEpub(req.Params.Position))
+	if offset < 0 {
+		return marshalResponse[*WorkspaceEdit](req.Id, nil)
+	}
+	ref := templateRefAt(uri, content, offset)
+	if ref == nil {
+		return marshalResponse[*WorkspaceEdit](req.Id, nil)
+	}
+	if !validTemplateName(req.Params.NewName) {
+		return marshalErrorResponse(req.Id, ErrorInvalidParams,
+			"template names cannot be empty or contain quotes, backslashes, or line breaks")
+	}
+
+	refs := workspaceTemplateRefs(ctx, ref.name, ws)
+	if ctx.Err() != nil {
+		return cancelledResponse(req.Id)
+	}
+
+	ranges := templateRanger(ws)
+	changes := make(map[string][]TextEdit)
+	for _, r := range refs {
+		changes[r.uri] = append(changes[r.uri], TextEdit{
+			Range:   ranges(r),
+			NewText: req.Params.NewName,
+		})
+	}
+	edit := &WorkspaceEdit{Changes: changes}
+	newRangeClamper(ctx, ws, MethodRename).workspaceEdit(edit)
+	return marshalResponse(req.Id, edit)
+}
+
+// fileReference is a relative reference to another file: the offset and
+// text of its path, excluding any query or fragment, and the path it
+// resolves to.
+type fileReference struct {
+	offset int
+	path   string
+	target string
+}
+
+// fileReferences returns the relative file references in a document.
+func fileReferences(uri string, content []byte, fileType epub.FileType) []fileReference {
+	var refs []fileReference
+	add := func(offset int, value string) {
+		if i := strings.IndexAny(value, "?#"); i >= 0 {
+			value = value[:i]
+		}
+		if value == "" || strings.Contains(value, ":") {
+			return // same-document, remote, or data reference
+		}
+		target := uriutil.Path(uriutil.ResolveRelative(uri, value))
+		refs = append(refs, fileReference{offset, value, target})
+	}
+
+	switch fileType {
+	case epub.FileTypeOPF, epub.FileTypeXHTML, epub.FileTypeNav, epub.FileTypeNCX:
+		parser.ScanAttrs(content, func(name string, offset int, value string) {
+			if _, local, found := strings.Cut(name, ":"); found {
+				name = local
+			}
+			if renameAttrs[name] {
+				add(offset, value)
+			}
+		})
+	case epub.FileTypeCSS:
+		for _, u := range parser.CSSURLs(string(content)) {
+			add(u.Offset, u.Value)
+		}
+	}
+	return refs
+}
+
+// renamedPaths maps the path of every known file affected by renames to
+// its new path. A rename whose old URI is not a known file is treated as a
+// directory and moves every known file beneath it.
+func renamedPaths(renames []FileRename, known map[string]bool) map[string]string {
+	moved := make(map[string]string)
+	for _, rename := range renames {
+		oldPath := uriutil.Path(rename.OldUri)
+		newPath := uriutil.Path(rename.NewUri)
+		if known[oldPath] {
+			moved[oldPath] = newPath
+			continue
+		}
+
+		dir := strings.TrimSuffix(oldPath, "/") + "/"
+		for p := range known {
+			if rest, ok := strings.CutPrefix(p, dir); ok {
+				moved[p] = path.Join(newPath, rest)
+			}
+		}
+	}
+	return moved
+}
+
+// renameEdits returns the edits to the references in one file that must
+// change because the file itself or the files it references moved.
+func renameEdits(
+	uri string,
+	content []byte,
+	refs []fileReference,
+	moved map[string]string,
+) []TextEdit {
+	docPath := uriutil.Path(uri)
+	newDocPath, docMoved := moved[docPath]
+	if !docMoved {
+		newDocPath = docPath
+	}
+
+	var lines *epub.LineIndex
+	var edits []TextEdit
+	for _, ref := range refs {
+		newTarget, targetMoved := moved[ref.target]
+		if !targetMoved && !docMoved {
+			continue
+		}
+		if !targetMoved {
+			newTarget = ref.target
+		}
+
+		newRef := relativePath(path.Dir(newDocPath), newTarget)
+		if decoded, err := url.PathUnescape(ref.path); err == nil && decoded != ref.path {
+			newRef = (&url.URL{Path: newRef}).EscapedPath()
+		}
+		if newRef == ref.path {
+			continue
+		}
+
+		if lines == nil {
+			lines = epub.NewLineIndex(content)
+		}
+		edits = append(edits, TextEdit{
+			Range: Range{
+				Start: lspPos(lines.Position(ref.offset)),
+				End:   lspPos(lines.Position(ref.offset + len(ref.path))),
+			},
+			NewText: newRef,
+		})
+	}
+	return edits
+}
+
+// relativePath returns the slash-separated path of target relative to the
+// directory dir.
+func relativePath(dir, target string) string {
+	from := strings.Split(strings.Trim(dir, "/"), "/")
+	to := strings.Split(strings.Trim(target, "/"), "/")
+	if from[0] == "" {
+		from = nil
+	}
+
+	common := 0
+	for common < len(from) && common < len(to)-1 && from[common] == to[common] {
+		common++
+	}
+
+	parts := make([]string, 0, len(from)-common+len(to)-common)
+	for range from[common:] {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, to[common:]...)
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,180 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+const (
+	renameOPF = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="text/chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="text/chapter2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="css" href="css/style.css" media-type="text/css"/>
+    <item id="cover" href="images/cover.png" media-type="image/png"/>
+  </manifest>
+</package>`
+	renameNav = `<html xmlns="http://www.w3.org/1999/xhtml">
+<body><nav><ol>
+<li><a href="text/chapter1.xhtml#start">One</a></li>
+<li><a href="text/chapter2.xhtml">Two</a></li>
+</ol></nav></body></html>`
+	renameChapter2 = `<html xmlns="http://www.w3.org/1999/xhtml">
+<head><link rel="stylesheet" href="../css/style.css"/></head>
+<body><a href="chapter1.xhtml#end">back</a><img src="../images/cover.png" alt=""/>
+<a href="#local">here</a><a href="https://example.com/">out</a></body></html>`
+	renameCSS = `body { background: url("../images/cover.png") }`
+)
+
+func newRenameWorkspace() *mockWorkspace {
+	ws := newMockWorkspace()
+	files := map[string]struct {
+		content  string
+		fileType epub.FileType
+	}{
+		"file:///book/OEBPS/content.opf":         {renameOPF, epub.FileTypeOPF},
+		"file:///book/OEBPS/nav.xhtml":           {renameNav, epub.FileTypeNav},
+		"file:///book/OEBPS/text/chapter2.xhtml": {renameChapter2, epub.FileTypeXHTML},
+		"file:///book/OEBPS/css/style.css":       {renameCSS, epub.FileTypeCSS},
+	}
+	for uri, f := range files {
+		ws.files[uri] = []byte(f.content)
+		ws.fileTypes[uri] = f.fileType
+	}
+	return ws
+}
+
+func willRename(t *testing.T, ws *mockWorkspace, renames ...FileRename) *WorkspaceEdit {
+	t.Helper()
+	data := makeRequest(t, 1, MethodWillRenameFiles, RenameFilesParams{Files: renames})
+	return unmarshalResult[*WorkspaceEdit](t,
+		HandleWillRenameFiles(t.Context(), data, ws))
+}
+
+func TestHandleWillRenameFiles_File(t *testing.T) {
+	ws := newRenameWorkspace()
+	edit := willRename(t, ws, FileRename{
+		OldUri: "file:///book/OEBPS/images/cover.png",
+		NewUri: "file:///book/OEBPS/images/front.png",
+	})
+	if edit == nil {
+		t.Fatal("expected a workspace edit")
+	}
+
+	want := map[string]struct{ old, new string }{
+		"file:///book/OEBPS/content.opf": {
+			`href="images/cover.png"`, `href="images/front.png"`,
+		},
+		"file:///book/OEBPS/text/chapter2.xhtml": {
+			`src="../images/cover.png"`, `src="../images/front.png"`,
+		},
+		"file:///book/OEBPS/css/style.css": {
+			`url("../images/cover.png")`, `url("../images/front.png")`,
+		},
+	}
+	if len(edit.Changes) != len(want) {
+		t.Errorf("expected edits to %d files, got %v", len(want), edit.Changes)
+	}
+	for uri, w := range want {
+		got := applyEdits(ws.files[uri], edit.Changes[uri])
+		expected := strings.Replace(string(ws.files[uri]), w.old, w.new, 1)
+		if got != expected {
+			t.Errorf("%s after rename:\n%s\nwant:\n%s", uri, got, expected)
+		}
+	}
+}
+
+func TestHandleWillRenameFiles_KeepsFragments(t *testing.T) {
+	ws := newRenameWorkspace()
+	edit := willRename(t, ws, FileRename{
+		OldUri: "file:///book/OEBPS/text/chapter1.xhtml",
+		NewUri: "file:///book/OEBPS/text/ch01.xhtml",
+	})
+	if edit == nil {
+		t.Fatal("expected a workspace edit")
+	}
+
+	nav := applyEdits(ws.files["file:///book/OEBPS/nav.xhtml"],
+		edit.Changes["file:///book/OEBPS/nav.xhtml"])
+	if !strings.Contains(nav, `href="text/ch01.xhtml#start"`) {
+		t.Errorf("nav link not updated:\n%s", nav)
+	}
+	ch2 := applyEdits(ws.files["file:///book/OEBPS/text/chapter2.xhtml"],
+		edit.Changes["file:///book/OEBPS/text/chapter2.xhtml"])
+	if !strings.Contains(ch2, `href="ch01.xhtml#end"`) {
+		t.Errorf("chapter link not updated:\n%s", ch2)
+	}
+}
+
+func TestHandleWillRenameFiles_Directory(t *testing.T) {
+	ws := newRenameWorkspace()
+	edit := willRename(t, ws, FileRename{
+		OldUri: "file:///book/OEBPS/text",
+		NewUri: "file:///book/OEBPS/xhtml/chapters",
+	})
+	if edit == nil {
+		t.Fatal("expected a workspace edit")
+	}
+
+	opf := applyEdits(ws.files["file:///book/OEBPS/content.opf"],
+		edit.Changes["file:///book/OEBPS/content.opf"])
+	for _, href := range []string{
+		`href="xhtml/chapters/chapter1.xhtml"`,
+		`href="xhtml/chapters/chapter2.xhtml"`,
+		`href="css/style.css"`,
+	} {
+		if !strings.Contains(opf, href) {
+			t.Errorf("expected %s in package document:\n%s", href, opf)
+		}
+	}
+
+	// The moved chapter's links to files outside the directory are deeper
+	ch2 := applyEdits(ws.files["file:///book/OEBPS/text/chapter2.xhtml"],
+		edit.Changes["file:///book/OEBPS/text/chapter2.xhtml"])
+	for _, ref := range []string{
+		`href="../../css/style.css"`,
+		`href="chapter1.xhtml#end"`,
+		`src="../../images/cover.png"`,
+		`href="#local"`,
+		`href="https://example.com/"`,
+	} {
+		if !strings.Contains(ch2, ref) {
+			t.Errorf("expected %s in moved chapter:\n%s", ref, ch2)
+		}
+	}
+
+	if _, ok := edit.Changes["file:///book/OEBPS/css/style.css"]; ok {
+		t.Error("expected no edits to the stylesheet")
+	}
+}
+
+func TestHandleWillRenameFiles_UnknownFile(t *testing.T) {
+	ws := newRenameWorkspace()
+	edit := willRename(t, ws, FileRename{
+		OldUri: "file:///book/OEBPS/notes.txt",
+		NewUri: "file:///book/OEBPS/notes.md",
+	})
+	if edit != nil {
+		t.Errorf("expected null for a file outside the workspace, got %+v", edit)
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	tests := []struct{ dir, target, want string }{
+		{"/book/OEBPS", "/book/OEBPS/text/a.xhtml", "text/a.xhtml"},
+		{"/book/OEBPS/text", "/book/OEBPS/text/a.xhtml", "a.xhtml"},
+		{"/book/OEBPS/text", "/book/OEBPS/css/a.css", "../css/a.css"},
+		{"/book/OEBPS/a/b", "/book/OEBPS/c.png", "../../c.png"},
+		{"/", "/a.png", "a.png"},
+	}
+	for _, tt := range tests {
+		if got := relativePath(tt.dir, tt.target); got != tt.want {
+			t.Errorf("relativePath(%q, %q) = %q, want %q",
+				tt.dir, tt.target, got, tt.want)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package lsp
 
 import (
+	"context"
 	"encoding/json"
-	"log/slog"
 	"strings"
 	"unicode"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/lsp/position"
 )
 
 // Token type indices matching SemanticTokenTypes legend.
@@ -47,10 +50,10 @@ type templateBlock struct {
 }
 
 // HandleSemanticTokens processes textDocument/semanticTokens/full requests.
-func HandleSemanticTokens(data []byte, ws WorkspaceReader) []byte {
+func HandleSemanticTokens(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
 	var req RequestMessage[SemanticTokensParams]
 	if err := json.Unmarshal(data, &req); err != nil {
-		slog.Error("error unmarshalling semantic tokens: " + err.Error())
+		Logger(ctx).Error("error unmarshalling semantic tokens: " + err.Error())
 		return marshalNullResponse(req.Id)
 	}
 
@@ -112,24 +115,17 @@ func findTemplateBlocks(content []byte) []templateBlock {
 	return blocks
 }
 
-// byteOffsetToLineChar converts a byte offset to (line, character) using the content.
-func byteOffsetToLineChar(content []byte, offset int) (uint, uint) {
-	line := uint(0)
-	lineStart := 0
-
-	for i := 0; i < offset && i < len(content); i++ {
-		if content[i] == '\n' {
-			line++
-			lineStart = i + 1
-		}
-	}
-
-	return line, uint(offset - lineStart) //nolint:gosec // offset >= lineStart
+// byteOffsetToLineChar converts a byte offset to (line, character) using the
+// line index of the content being tokenized.
+func byteOffsetToLineChar(lines *epub.LineIndex, offset int) (uint, uint) {
+	pos := lines.Position(offset)
+	return position.IntToUint(pos.Line), position.IntToUint(pos.Character)
 }
 
 // tokenizeTemplates extracts semantic tokens from Go template blocks in content.
 func tokenizeTemplates(content []byte) []semanticToken {
 	blocks := findTemplateBlocks(content)
+	lines := epub.NewLineIndex(content)
 	var tokens []semanticToken
 	text := string(content)
 
@@ -139,7 +135,7 @@ func tokenizeTemplates(content []byte) []semanticToken {
 
 		// Comment blocks: {{/* ... */}}
 		if strings.HasPrefix(trimmed, "/*") {
-			line, char := byteOffsetToLineChar(content, block.delimStart)
+			line, char := byteOffsetToLineChar(lines, block.delimStart)
 			// Token spans from {{ to }}
 			//nolint:gosec // delimEnd > delimStart
 			length := uint(block.delimEnd - block.delimStart)
@@ -153,7 +149,7 @@ func tokenizeTemplates(content []byte) []semanticToken {
 		openLen := max(block.innerStart-block.delimStart,
 			// includes trim marker
 			2)
-		oLine, oChar := byteOffsetToLineChar(content, block.delimStart)
+		oLine, oChar := byteOffsetToLineChar(lines, block.delimStart)
 		tokens = append(tokens, semanticToken{
 			line:      oLine,
 			startChar: oChar,
@@ -162,7 +158,7 @@ func tokenizeTemplates(content []byte) []semanticToken {
 		})
 
 		// Tokenize inner content
-		tokens = tokenizeInner(content, block.innerStart, inner, tokens)
+		tokens = tokenizeInner(lines, block.innerStart, inner, tokens)
 
 		// Closing }} delimiter as operator
 		closeStart := block.innerEnd
@@ -170,7 +166,7 @@ func tokenizeTemplates(content []byte) []semanticToken {
 			closeStart = block.innerEnd
 		}
 		closeLen := block.delimEnd - closeStart
-		cLine, cChar := byteOffsetToLineChar(content, closeStart)
+		cLine, cChar := byteOffsetToLineChar(lines, closeStart)
 		tokens = append(tokens, semanticToken{
 			line:      cLine,
 			startChar: cChar,
@@ -184,7 +180,7 @@ func tokenizeTemplates(content []byte) []semanticToken {
 
 // tokenizeInner tokenizes the content inside a template block.
 func tokenizeInner(
-	content []byte,
+	lines *epub.LineIndex,
 	baseOffset int,
 	inner string,
 	tokens []semanticToken,
@@ -214,7 +210,7 @@ func tokenizeInner(
 			if i < len(inner) {
 				i++ // closing quote
 			}
-			line, char := byteOffsetToLineChar(content, baseOffset+start)
+			line, char := byteOffsetToLineChar(lines, baseOffset+start)
 			tokens = append(tokens, semanticToken{
 				line:      line,
 				startChar: char,
@@ -230,7 +226,7 @@ func tokenizeInner(
 			for i < len(inner) && (inner[i] >= '0' && inner[i] <= '9' || inner[i] == '.') {
 				i++
 			}
-			line, char := byteOffsetToLineChar(content, baseOffset+start)
+			line, char := byteOffsetToLineChar(lines, baseOffset+start)
 			tokens = append(tokens, semanticToken{
 				line:      line,
 				startChar: char,
@@ -256,7 +252,7 @@ func tokenizeInner(
 					i++
 				}
 			}
-			line, char := byteOffsetToLineChar(content, baseOffset+start)
+			line, char := byteOffsetToLineChar(lines, baseOffset+start)
 			tokens = append(tokens, semanticToken{
 				line:      line,
 				startChar: char,
@@ -268,7 +264,7 @@ func tokenizeInner(
 
 		// := operator
 		if ch == ':' && i+1 < len(inner) && inner[i+1] == '=' {
-			line, char := byteOffsetToLineChar(content, baseOffset+i)
+			line, char := byteOffsetToLineChar(lines, baseOffset+i)
 			tokens = append(tokens, semanticToken{
 				line: line, startChar: char, length: 2, tokenType: tokenOperator,
 			})
@@ -283,7 +279,7 @@ func tokenizeInner(
 			for i < len(inner) && (isIdentChar(inner[i])) {
 				i++
 			}
-			line, char := byteOffsetToLineChar(content, baseOffset+start)
+			line, char := byteOffsetToLineChar(lines, baseOffset+start)
 			tokens = append(tokens, semanticToken{
 				line:      line,
 				startChar: char,
@@ -301,7 +297,7 @@ func tokenizeInner(
 				for i < len(inner) && isIdentChar(inner[i]) {
 					i++
 				}
-				line, char := byteOffsetToLineChar(content, baseOffset+start)
+				line, char := byteOffsetToLineChar(lines, baseOffset+start)
 				tokens = append(tokens, semanticToken{
 					line:      line,
 					startChar: char,
@@ -311,7 +307,7 @@ func tokenizeInner(
 				continue
 			}
 			// Lone dot (current context)
-			line, char := byteOffsetToLineChar(content, baseOffset+i)
+			line, char := byteOffsetToLineChar(lines, baseOffset+i)
 			tokens = append(tokens, semanticToken{
 				line: line, startChar: char, length: 1, tokenType: tokenVariable,
 			})
@@ -332,7 +328,7 @@ func tokenizeInner(
 				tokenType = tokenKeyword
 			}
 
-			line, char := byteOffsetToLineChar(content, baseOffset+start)
+			line, char := byteOffsetToLineChar(lines, baseOffset+start)
 			tokens = append(tokens, semanticToken{
 				line:      line,
 				startChar: char,
@@ -291,7 +291,7 @@ func TestHandleSemanticTokens(t *testing.T) {
 		TextDocument: TextDocumentIdentifier{Uri: uri},
 	})
 
-	response := HandleSemanticTokens(data, ws)
+	response := HandleSemanticTokens(t.Context(), data, ws)
 	result := unmarshalResult[SemanticTokensResult](t, response)
 
 	if len(result.Data) == 0 {
@@ -314,7 +314,7 @@ func TestHandleSemanticTokensEmptyFile(t *testing.T) {
 		TextDocument: TextDocumentIdentifier{Uri: uri},
 	})
 
-	response := HandleSemanticTokens(data, ws)
+	response := HandleSemanticTokens(t.Context(), data, ws)
 	result := unmarshalResult[SemanticTokensResult](t, response)
 
 	if len(result.Data) != 0 {
@@ -329,7 +329,7 @@ func TestHandleSemanticTokensMissingFile(t *testing.T) {
 		TextDocument: TextDocumentIdentifier{Uri: "file:///missing.xhtml"},
 	})
 
-	response := HandleSemanticTokens(data, ws)
+	response := HandleSemanticTokens(t.Context(), data, ws)
 	result := unmarshalResult[SemanticTokensResult](t, response)
 
 	if len(result.Data) != 0 {
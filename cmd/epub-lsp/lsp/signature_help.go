@@ -0,0 +1,155 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// metaSignature describes the value syntax of a meta property. The value
+// is split into parameters at any of the separator bytes; when repeat is
+// set, the last parameter applies to every further list entry.
+type metaSignature struct {
+	label      string
+	doc        string
+	separators string
+	repeat     bool
+	params     []ParameterInformation
+}
+
+// metaSignatures maps meta properties to the syntax of their values.
+var metaSignatures = map[string]metaSignature{
+	"schema:accessModeSufficient": {
+		label:      "auditory | tactile | textual | visual, …",
+		doc:        "Comma-separated list of: auditory | tactile | textual | visual",
+		separators: ",",
+		repeat:     true,
+		params: []ParameterInformation{{
+			Label: "auditory | tactile | textual | visual",
+			Documentation: "An access mode that, together with the others in the " +
+				"list, is sufficient to understand all the content.",
+		}},
+	},
+	"dcterms:modified": {
+		label:      "CCYY-MM-DDThh:mm:ssZ",
+		doc:        "Last modification date, as a UTC timestamp without fractions.",
+		separators: "-T:",
+		params: []ParameterInformation{
+			{Label: "CCYY", Documentation: "Four-digit year"},
+			{Label: "MM", Documentation: "Month, 01–12"},
+			{Label: "DD", Documentation: "Day of the month, 01–31"},
+			{Label: "hh", Documentation: "Hour, 00–23"},
+			{Label: "mm", Documentation: "Minute, 00–59"},
+			{Label: "ssZ", Documentation: "Second, 00–59, followed by Z for UTC"},
+		},
+	},
+	"media:duration": {
+		label:      "hh:mm:ss.fraction",
+		doc:        "Duration of a media overlay, as a SMIL clock value.",
+		separators: ":.",
+		params: []ParameterInformation{
+			{Label: "hh", Documentation: "Hours"},
+			{Label: "mm", Documentation: "Minutes, 00–59"},
+			{Label: "ss", Documentation: "Seconds, 00–59"},
+			{Label: "fraction", Documentation: "Optional fraction of a second"},
+		},
+	},
+}
+
+// HandleSignatureHelp processes textDocument/signatureHelp requests. Inside
+// the text of a meta element with a known property it shows the expected
+// value syntax, with the part being typed as the active parameter.
+func HandleSignatureHelp(ctx context.Context, data []byte, ws WorkspaceReader) []byte {
+	var req RequestMessage[SignatureHelpParams]
+	if err := json.Unmarshal(data, &req); err != nil {
+		Logger(ctx).Error("error unmarshalling signatureHelp: " + err.Error())
+		return marshalNullResponse(req.Id)
+	}
+
+	uri := req.Params.TextDocument.Uri
+	content := ws.GetContent(uri)
+	if content == nil || ws.GetFileType(uri) != epub.FileTypeOPF {
+		return marshalNullResponse(req.Id)
+	}
+
+	offset := epub.PositionToByteOffset(content, posToEpub(req.Params.Position))
+	if offset < 0 {
+		return marshalNullResponse(req.Id)
+	}
+
+	property, typed, ok := metaTextAt(content, offset)
+	if !ok {
+		return marshalNullResponse(req.Id)
+	}
+	sig, ok := metaSignatures[property]
+	if !ok {
+		return marshalNullResponse(req.Id)
+	}
+
+	active := 0
+	for i := range len(typed) {
+		if strings.IndexByte(sig.separators, typed[i]) >= 0 {
+			active++
+		}
+	}
+	if active >= len(sig.params) {
+		if !sig.repeat {
+			return marshalNullResponse(req.Id)
+		}
+		active = len(sig.params) - 1
+	}
+
+	return marshalResponse(req.Id, &SignatureHelp{
+		Signatures: []SignatureInformation{{
+			Label:         sig.label,
+			Documentation: property + ": " + sig.doc,
+			Parameters:    sig.params,
+		}},
+		ActiveParameter: uint(active),
+	})
+}
+
+// metaTextAt returns the property of the meta element whose text contains
+// offset and the text from the end of its start tag to offset. The document
+// is scanned rather than parsed, since it is rarely well formed while a
+// value is being typed.
+func metaTextAt(content []byte, offset int) (string, string, bool) {
+	start := bytes.LastIndexByte(content[:offset], '<')
+	if start < 0 {
+		return "", "", false
+	}
+	end := bytes.IndexByte(content[start:offset], '>')
+	if end < 0 {
+		return "", "", false // inside a tag
+	}
+	tag := content[start : start+end+1]
+	if bytes.HasSuffix(tag, []byte("/>")) {
+		return "", "", false
+	}
+
+	name := tag[1:]
+	if i := bytes.IndexAny(name, " \t\r\n/>"); i >= 0 {
+		name = name[:i]
+	}
+	if i := bytes.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	if string(name) != "meta" {
+		return "", "", false
+	}
+
+	property := ""
+	parser.ScanAttrs(tag, func(name string, _ int, value string) {
+		if name == "property" {
+			property = strings.TrimSpace(value)
+		}
+	})
+	if property == "" {
+		return "", "", false
+	}
+	return property, string(content[start+end+1 : offset]), true
+}
@@ -0,0 +1,91 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+const signatureOPF = `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf">
+  <metadata>
+    <meta property="schema:accessModeSufficient">textual,visual</meta>
+    <meta property="dcterms:modified">2024-01-15T10:30:00Z</meta>
+    <meta property="media:duration">0:32:29.123</meta>
+    <meta property="schema:accessibilitySummary">No known issues</meta>
+  </metadata>
+</package>`
+
+// signatureHelpAt requests signature help at the end of the first
+// occurrence of marker in signatureOPF.
+func signatureHelpAt(t *testing.T, marker string) *SignatureHelp {
+	t.Helper()
+	content := []byte(signatureOPF)
+	offset := strings.Index(signatureOPF, marker)
+	if offset < 0 {
+		t.Fatalf("marker %q not found", marker)
+	}
+
+	ws := newMockWorkspace()
+	ws.files["file:///book/content.opf"] = content
+	ws.fileTypes["file:///book/content.opf"] = epub.FileTypeOPF
+
+	data := makeRequest(t, 1, MethodSignatureHelp, SignatureHelpParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/content.opf"},
+		Position:     lspPos(epub.ByteOffsetToPosition(content, offset+len(marker))),
+	})
+	return unmarshalResult[*SignatureHelp](t, HandleSignatureHelp(t.Context(), data, ws))
+}
+
+func TestHandleSignatureHelp(t *testing.T) {
+	tests := []struct {
+		name   string
+		marker string
+		label  string
+		param  string // label of the active parameter
+	}{
+		{"access mode start", `accessModeSufficient">`, "auditory | tactile", "auditory"},
+		{"access mode list", "textual,vis", "auditory | tactile", "auditory"},
+		{"modified year", `modified">20`, "CCYY-MM-DDThh:mm:ssZ", "CCYY"},
+		{"modified day", "2024-01-1", "CCYY-MM-DDThh:mm:ssZ", "DD"},
+		{"modified minute", "2024-01-15T10:3", "CCYY-MM-DDThh:mm:ssZ", "mm"},
+		{"duration seconds", "0:32:2", "hh:mm:ss.fraction", "ss"},
+		{"duration fraction", "0:32:29.1", "hh:mm:ss.fraction", "fraction"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			help := signatureHelpAt(t, tt.marker)
+			if help == nil || len(help.Signatures) != 1 {
+				t.Fatalf("expected one signature, got %+v", help)
+			}
+			sig := help.Signatures[0]
+			if !strings.HasPrefix(sig.Label, tt.label) {
+				t.Errorf("label = %q, want prefix %q", sig.Label, tt.label)
+			}
+			if int(help.ActiveParameter) >= len(sig.Parameters) {
+				t.Fatalf("active parameter %d out of range", help.ActiveParameter)
+			}
+			param := sig.Parameters[help.ActiveParameter]
+			if !strings.HasPrefix(param.Label, tt.param) {
+				t.Errorf("active parameter = %q, want %q", param.Label, tt.param)
+			}
+			if !strings.Contains(sig.Label, param.Label) {
+				t.Errorf("parameter %q is not part of label %q", param.Label, sig.Label)
+			}
+		})
+	}
+}
+
+func TestHandleSignatureHelp_None(t *testing.T) {
+	for _, marker := range []string{
+		"No known",             // meta without a known signature
+		`<meta property="dcte`, // inside the start tag
+		"<metadata>",           // not a meta element
+	} {
+		if help := signatureHelpAt(t, marker); help != nil {
+			t.Errorf("%q: expected null, got %+v", marker, help)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/toba/epub-lsp/internal/epub/stats"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/lsp/pathutil"
+)
+
+// StatsEntry holds the counts and estimated reading time of one spine
+// item, or of the whole book when URI is empty.
+type StatsEntry struct {
+	URI string `json:"uri,omitempty"`
+	stats.Counts
+	ReadingMinutes float64 `json:"readingMinutes"`
+}
+
+// StatsResult is the result of CommandStats: an entry for each spine item
+// in reading order, and their totals.
+type StatsResult struct {
+	Documents []StatsEntry `json:"documents"`
+	Total     StatsEntry   `json:"total"`
+}
+
+// bookStats counts every spine item, reading workspace content over the
+// files on disk so unsaved edits are included. Spine items that cannot be
+// read are left out.
+func bookStats(ws WorkspaceReader) StatsResult {
+	files := ws.GetAllFiles()
+	result := StatsResult{Documents: []StatsEntry{}}
+	var total stats.Counts
+	for _, uri := range spineURIs(ws) {
+		content, ok := spineContent(files, uri)
+		if !ok {
+			continue
+		}
+		counts := stats.Document(content)
+		result.Documents = append(result.Documents, StatsEntry{
+			URI:            uri,
+			Counts:         counts,
+			ReadingMinutes: counts.ReadingMinutes(),
+		})
+		total = total.Add(counts)
+	}
+	result.Total = StatsEntry{Counts: total, ReadingMinutes: total.ReadingMinutes()}
+	return result
+}
+
+// spineContent returns the workspace content of uri, or the file on disk
+// when the workspace does not hold it.
+func spineContent(files map[string][]byte, uri string) ([]byte, bool) {
+	if found, ok := uriutil.Lookup(files, uri); ok {
+		return files[found], true
+	}
+	content, err := os.ReadFile(pathutil.URIToFilePath(uri))
+	return content, err == nil
+}
+
+// hoverSpine summarizes the book's statistics, such as
+// "12 chapters · 84,310 words · ~5.6 h".
+func hoverSpine(ws WorkspaceReader) *Hover {
+	result := bookStats(ws)
+	if len(result.Documents) == 0 {
+		return nil
+	}
+	text := fmt.Sprintf("%s · %s · %s",
+		countOf(len(result.Documents), "chapter"),
+		countOf(result.Total.Words, "word"),
+		stats.FormatReadingTime(result.Total.ReadingMinutes))
+	if images := result.Total.Images; images > 0 {
+		text += " · " + countOf(images, "image")
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}}
+}
+
+// countOf renders n with noun, pluralized by adding "s".
+func countOf(n int, noun string) string {
+	if n != 1 {
+		noun += "s"
+	}
+	return stats.FormatCount(n) + " " + noun
+}
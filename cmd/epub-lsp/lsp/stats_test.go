@@ -0,0 +1,82 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/stats"
+)
+
+// statsWorkspace returns spineWorkspace with text in each chapter and a
+// spine in the package document.
+func statsWorkspace() (*mockWorkspace, []byte) {
+	ws := spineWorkspace()
+	opf := []byte(`<package xmlns="http://www.idpf.org/2007/opf">
+  <spine>
+    <itemref idref="ch1"/><itemref idref="ch2"/><itemref idref="ch3"/>
+  </spine>
+</package>`)
+	ws.files["file:///book/OEBPS/content.opf"] = opf
+	ws.files["file:///book/OEBPS/text/ch1.xhtml"] = []byte(
+		`<html><body><p>One two three.</p><img src="a.png" alt=""/></body></html>`)
+	ws.files["file:///book/OEBPS/text/ch2.xhtml"] = []byte(
+		`<html><body><p>Four {{ .Five }} five</p><script>six</script></body></html>`)
+	ws.files["file:///book/OEBPS/text/ch3.xhtml"] = []byte(
+		`<html><body><p>日本語です</p></body></html>`)
+	return ws, opf
+}
+
+func TestHandleExecuteCommand_Stats(t *testing.T) {
+	ws, _ := statsWorkspace()
+	data := makeRequest(t, 1, MethodExecuteCommand, ExecuteCommandParams{Command: CommandStats})
+	resp, notifications := HandleExecuteCommand(t.Context(), data, ws)
+	if len(notifications) > 0 {
+		t.Errorf("unexpected notifications: %d", len(notifications))
+	}
+	result := unmarshalResult[StatsResult](t, resp)
+
+	want := []StatsEntry{
+		{URI: "file:///book/OEBPS/text/ch1.xhtml", Counts: stats.Counts{Words: 3, Characters: 12, Images: 1}},
+		{URI: "file:///book/OEBPS/text/ch2.xhtml", Counts: stats.Counts{Words: 2, Characters: 8}},
+		{URI: "file:///book/OEBPS/text/ch3.xhtml", Counts: stats.Counts{Words: 3, Characters: 5}},
+	}
+	if len(result.Documents) != len(want) {
+		t.Fatalf("documents = %+v, want %d", result.Documents, len(want))
+	}
+	for i, w := range want {
+		got := result.Documents[i]
+		if got.URI != w.URI || got.Counts != w.Counts {
+			t.Errorf("document %d = %+v, want %+v", i, got, w)
+		}
+		if got.ReadingMinutes != w.Counts.ReadingMinutes() {
+			t.Errorf("document %d reading minutes = %v", i, got.ReadingMinutes)
+		}
+	}
+	if total := (stats.Counts{Words: 8, Characters: 25, Images: 1}); result.Total.Counts != total {
+		t.Errorf("total = %+v, want %+v", result.Total.Counts, total)
+	}
+	if result.Total.URI != "" {
+		t.Errorf("total URI = %q, want none", result.Total.URI)
+	}
+}
+
+func TestHandleHover_Spine(t *testing.T) {
+	ws, opf := statsWorkspace()
+	offset := findSubstring(opf, "<spine>")
+	data := makeRequest(t, 1, MethodHover, HoverParams{
+		TextDocument: TextDocumentIdentifier{Uri: "file:///book/OEBPS/content.opf"},
+		Position:     lspPos(epub.ByteOffsetToPosition(opf, offset+2)),
+	})
+
+	var result ResponseMessage[*Hover]
+	if err := unmarshalJSON(HandleHover(t.Context(), data, ws), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Result == nil {
+		t.Fatal("expected hover on <spine>")
+	}
+	want := "3 chapters · 8 words · ~1 min · 1 image"
+	if got := result.Result.Contents.Value; got != want {
+		t.Errorf("hover = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+package lsp
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// templateNameKeywords are the actions whose first argument names a
+// template. define and block declare it; template and block invoke it.
+var templateNameKeywords = map[string]bool{
+	"define":   true,
+	"block":    true,
+	"template": true,
+}
+
+// templateRef is a template name in a define, block, or template action.
+type templateRef struct {
+	uri  string
+	name string
+	// start and end are the byte offsets of the name inside its quotes.
+	start, end int
+	// defines is set for define and block, which declare the template.
+	defines bool
+}
+
+// templateRefs returns the template names in the actions of content, in
+// source order.
+func templateRefs(uri string, content []byte) []templateRef {
+	var refs []templateRef
+	for _, block := range findTemplateBlocks(content) {
+		i := block.innerStart
+		for i < block.innerEnd && isTemplateSpace(content[i]) {
+			i++
+		}
+		keywordStart := i
+		for i < block.innerEnd && isIdentChar(content[i]) {
+			i++
+		}
+		keyword := string(content[keywordStart:i])
+		if !templateNameKeywords[keyword] || i == keywordStart ||
+			i >= block.innerEnd || !isTemplateSpace(content[i]) {
+			continue
+		}
+		for i < block.innerEnd && isTemplateSpace(content[i]) {
+			i++
+		}
+		if i >= block.innerEnd || (content[i] != '"' && content[i] != '`') {
+			continue
+		}
+		quote := content[i]
+		nameStart := i + 1
+		nameEnd := nameStart
+		for nameEnd < block.innerEnd && content[nameEnd] != quote {
+			if quote == '"' && content[nameEnd] == '\\' {
+				nameEnd++
+			}
+			nameEnd++
+		}
+		if nameEnd >= block.innerEnd {
+			continue
+		}
+		refs = append(refs, templateRef{
+			uri:     uri,
+			name:    string(content[nameStart:nameEnd]),
+			start:   nameStart,
+			end:     nameEnd,
+			defines: keyword != "template",
+		})
+	}
+	return refs
+}
+
+// isTemplateSpace reports whether c separates words in a template action.
+func isTemplateSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// templateRefAt returns the template name in content whose quoted text
+// contains offset, or nil.
+func templateRefAt(uri string, content []byte, offset int) *templateRef {
+	for _, ref := range templateRefs(uri, content) {
+		if ref.start <= offset && offset <= ref.end {
+			return &ref
+		}
+	}
+	return nil
+}
+
+// workspaceTemplateRefs returns the sites of the template name in every
+// workspace file, ordered by URI and then position. It returns nil when ctx
+// is cancelled.
+func workspaceTemplateRefs(ctx context.Context, name string, ws WorkspaceReader) []templateRef {
+	files := ws.GetAllFiles()
+	var refs []templateRef
+	for _, uri := range slices.Sorted(maps.Keys(files)) {
+		if ctx.Err() != nil {
+			return nil
+		}
+		for _, ref := range templateRefs(uri, files[uri]) {
+			if ref.name == name {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// templateLocations converts template name sites to locations, keeping
+// declarations only when withDefines is set and invocations only when
+// withInvocations is set.
+func templateLocations(
+	refs []templateRef,
+	ws WorkspaceReader,
+	withDefines, withInvocations bool,
+) []Location {
+	ranges := templateRanger(ws)
+	locations := []Location{}
+	for _, ref := range refs {
+		if ref.defines && !withDefines || !ref.defines && !withInvocations {
+			continue
+		}
+		locations = append(locations, Location{URI: ref.uri, Range: ranges(ref)})
+	}
+	return locations
+}
+
+// templateRanger returns a function giving the range of a template name in
+// its file, indexing the lines of each file once.
+func templateRanger(ws WorkspaceReader) func(templateRef) Range {
+	indexes := make(map[string]*epub.LineIndex)
+	return func(ref templateRef) Range {
+		lines, ok := indexes[ref.uri]
+		if !ok {
+			lines = epub.NewLineIndex(ws.GetContent(ref.uri))
+			indexes[ref.uri] = lines
+		}
+		return Range{
+			Start: lspPos(lines.Position(ref.start)),
+			End:   lspPos(lines.Position(ref.end)),
+		}
+	}
+}
+
+// validTemplateName reports whether name can replace a template name in
+// every site without changing how the actions parse.
+func validTemplateName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "\"`\\\r\n")
+}
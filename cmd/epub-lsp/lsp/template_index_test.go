@@ -0,0 +1,171 @@
+package lsp
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+const (
+	templatesURI = "file:///book/templates.xhtml"
+	chapter1URI  = "file:///book/chapter1.xhtml"
+	chapter2URI  = "file:///book/chapter2.xhtml"
+)
+
+// newTemplateWorkspace returns a workspace where templates.xhtml defines
+// chapter-header and both chapters invoke it.
+func newTemplateWorkspace() *mockWorkspace {
+	ws := newMockWorkspace()
+	ws.files[templatesURI] = []byte(`{{define "chapter-header"}}
+<header><h1>{{.Title}}</h1></header>
+{{end}}
+{{define "footer"}}<footer/>{{end}}`)
+	ws.files[chapter1URI] = []byte(`<html xmlns="http://www.w3.org/1999/xhtml">
+<body>
+{{template "chapter-header" .}}
+{{- template "footer" .}}
+</body>
+</html>`)
+	ws.files[chapter2URI] = []byte(`<html xmlns="http://www.w3.org/1999/xhtml">
+<body>{{ template "chapter-header" . }}</body>
+</html>`)
+	for uri := range ws.files {
+		ws.fileTypes[uri] = epub.FileTypeXHTML
+	}
+	return ws
+}
+
+// templatePosition returns the position just inside the first occurrence
+// of marker in uri.
+func templatePosition(ws *mockWorkspace, uri, marker string) Position {
+	content := ws.files[uri]
+	return lspPos(epub.ByteOffsetToPosition(content, findSubstring(content, marker)+2))
+}
+
+func TestTemplateRefs(t *testing.T) {
+	content := []byte("{{define \"a\"}}{{end}}{{block `b` .}}{{end}}" +
+		"{{/* template \"c\" */}}{{template \"d\"}}{{ .template }}")
+
+	var got []string
+	for _, ref := range templateRefs("x", content) {
+		name := string(content[ref.start:ref.end])
+		if name != ref.name {
+			t.Errorf("span %q does not match name %q", name, ref.name)
+		}
+		got = append(got, ref.name)
+		if want := ref.name != "d"; ref.defines != want {
+			t.Errorf("%q defines = %v, want %v", ref.name, ref.defines, want)
+		}
+	}
+	if want := []string{"a", "b", "d"}; !slices.Equal(got, want) {
+		t.Errorf("names = %v, want %v", got, want)
+	}
+}
+
+func TestHandleDefinition_Template(t *testing.T) {
+	ws := newTemplateWorkspace()
+
+	data := makeRequest(t, 1, MethodDefinition, DefinitionParams{
+		TextDocument: TextDocumentIdentifier{Uri: chapter2URI},
+		Position:     templatePosition(ws, chapter2URI, `"chapter-header"`),
+	})
+	locations := unmarshalResult[[]Location](t, HandleDefinition(t.Context(), data, ws))
+
+	want := Location{
+		URI: templatesURI,
+		Range: Range{
+			Start: Position{Line: 0, Character: 10},
+			End:   Position{Line: 0, Character: 24},
+		},
+	}
+	if len(locations) != 1 || locations[0] != want {
+		t.Errorf("expected %v, got %v", want, locations)
+	}
+}
+
+func TestHandleReferences_Template(t *testing.T) {
+	ws := newTemplateWorkspace()
+
+	for _, include := range []bool{true, false} {
+		data := makeRequest(t, 1, MethodReferences, ReferenceParams{
+			TextDocument: TextDocumentIdentifier{Uri: templatesURI},
+			Position:     templatePosition(ws, templatesURI, `"chapter-header"`),
+			Context:      ReferenceContext{IncludeDeclaration: include},
+		})
+		locations := unmarshalResult[[]Location](t, HandleReferences(t.Context(), data, ws))
+
+		var uris []string
+		for _, loc := range locations {
+			uris = append(uris, loc.URI)
+		}
+		want := []string{chapter1URI, chapter2URI}
+		if include {
+			want = append(want, templatesURI)
+		}
+		if !slices.Equal(uris, want) {
+			t.Errorf("includeDeclaration=%v: got %v, want %v", include, uris, want)
+		}
+	}
+}
+
+func TestHandleRename_Template(t *testing.T) {
+	ws := newTemplateWorkspace()
+
+	data := makeRequest(t, 1, MethodRename, RenameParams{
+		TextDocument: TextDocumentIdentifier{Uri: chapter1URI},
+		Position:     templatePosition(ws, chapter1URI, `"chapter-header"`),
+		NewName:      "chapter-title",
+	})
+	edit := unmarshalResult[*WorkspaceEdit](t, HandleRename(t.Context(), data, ws))
+	if edit == nil {
+		t.Fatal("expected a workspace edit")
+	}
+	if len(edit.Changes) != 3 {
+		t.Fatalf("expected edits in 3 files, got %v", edit.Changes)
+	}
+
+	for uri, edits := range edit.Changes {
+		got := applyEdits(ws.files[uri], edits)
+		if findSubstring([]byte(got), `"chapter-title"`) < 0 ||
+			findSubstring([]byte(got), `"chapter-header"`) >= 0 {
+			t.Errorf("%s not renamed:\n%s", uri, got)
+		}
+		if uri == chapter1URI && findSubstring([]byte(got), `"footer"`) < 0 {
+			t.Errorf("other templates must keep their names:\n%s", got)
+		}
+	}
+}
+
+func TestHandleRename_InvalidName(t *testing.T) {
+	ws := newTemplateWorkspace()
+
+	data := makeRequest(t, 1, MethodRename, RenameParams{
+		TextDocument: TextDocumentIdentifier{Uri: chapter1URI},
+		Position:     templatePosition(ws, chapter1URI, `"footer"`),
+		NewName:      `foot"er`,
+	})
+	response := HandleRename(t.Context(), data, ws)
+
+	var resp ResponseMessage[any]
+	if err := json.Unmarshal(response, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != ErrorInvalidParams {
+		t.Errorf("expected an invalid params error, got %s", response)
+	}
+}
+
+func TestHandleRename_NotATemplate(t *testing.T) {
+	ws := newTemplateWorkspace()
+
+	data := makeRequest(t, 1, MethodRename, RenameParams{
+		TextDocument: TextDocumentIdentifier{Uri: chapter1URI},
+		Position:     templatePosition(ws, chapter1URI, "<body>"),
+		NewName:      "main",
+	})
+	if edit := unmarshalResult[*WorkspaceEdit](t, HandleRename(t.Context(), data, ws)); edit != nil {
+		t.Errorf("expected null outside template names, got %v", edit)
+	}
+}
@@ -3,6 +3,7 @@ package lsp
 import (
 	"encoding/json"
 	"maps"
+	"slices"
 	"testing"
 
 	"github.com/toba/epub-lsp/internal/epub"
@@ -17,6 +18,11 @@ type mockWorkspace struct {
 	manifest    *validator.ManifestInfo
 	rootPath    string
 	settings    *ServerSettings
+	// fresh, when set for a URI, is returned by Validate in place of the
+	// stored diagnostics.
+	fresh map[string][]epub.Diagnostic
+	// boms marks the files whose content started with a byte order mark.
+	boms map[string]bool
 }
 
 func (m *mockWorkspace) GetContent(
@@ -37,7 +43,21 @@ func (m *mockWorkspace) GetDiagnostics(
 ) []epub.Diagnostic {
 	return m.diagnostics[uri]
 }
+
+func (m *mockWorkspace) Validated(uri string) bool {
+	_, ok := m.diagnostics[uri]
+	return ok
+}
+
+func (m *mockWorkspace) Validate(uri string) []epub.Diagnostic {
+	if diags, ok := m.fresh[uri]; ok {
+		return diags
+	}
+	return m.diagnostics[uri]
+}
+
 func (m *mockWorkspace) GetRootPath() string          { return m.rootPath }
+func (m *mockWorkspace) HasBOM(uri string) bool       { return m.boms[uri] }
 func (m *mockWorkspace) GetSettings() *ServerSettings { return m.settings }
 func (m *mockWorkspace) GetAllFiles() map[string][]byte {
 	result := make(map[string][]byte, len(m.files))
@@ -58,7 +78,7 @@ func makeRequest[T any](t *testing.T, id int, method string, params T) []byte {
 	t.Helper()
 	req := RequestMessage[T]{
 		JsonRpc: JSONRPCVersion,
-		Id:      ID(id),
+		Id:      NumberID(int64(id)),
 		Method:  method,
 		Params:  params,
 	}
@@ -78,3 +98,20 @@ func unmarshalResult[T any](t *testing.T, data []byte) T {
 	}
 	return resp.Result
 }
+
+// applyEdits applies non-overlapping edits to content the way clients do:
+// insertions at the same position keep their array order.
+func applyEdits(content []byte, edits []TextEdit) string {
+	lines := epub.NewLineIndex(content)
+	sorted := slices.Clone(edits)
+	slices.SortStableFunc(sorted, func(a, b TextEdit) int {
+		return comparePositions(posToEpub(a.Range.Start), posToEpub(b.Range.Start))
+	})
+	result := string(content)
+	for _, e := range slices.Backward(sorted) {
+		start := lines.Offset(posToEpub(e.Range.Start))
+		end := lines.Offset(posToEpub(e.Range.End))
+		result = result[:start] + e.NewText + result[end:]
+	}
+	return result
+}
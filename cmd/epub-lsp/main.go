@@ -4,27 +4,29 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"maps"
+	"net"
 	"os"
+	"path/filepath"
+	"runtime/debug"
 	"strings"
 	"sync"
-
-	"go.lsp.dev/protocol"
+	"sync/atomic"
+	"time"
 
 	"github.com/toba/epub-lsp/cmd/epub-lsp/lsp"
+	"github.com/toba/epub-lsp/epublint"
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/validator"
-	"github.com/toba/epub-lsp/internal/epub/validator/accessibility"
-	"github.com/toba/epub-lsp/internal/epub/validator/css"
-	"github.com/toba/epub-lsp/internal/epub/validator/nav"
-	"github.com/toba/epub-lsp/internal/epub/validator/opf"
-	"github.com/toba/epub-lsp/internal/epub/validator/resource"
-	"github.com/toba/epub-lsp/internal/epub/validator/xhtml"
+	"github.com/toba/epub-lsp/internal/logging"
+	"github.com/toba/epub-lsp/internal/replay"
 	"github.com/toba/lsp/pathutil"
-	"github.com/toba/lsp/server"
+	"github.com/toba/lsp/transport"
 )
 
 // version is set by goreleaser at build time.
@@ -32,608 +34,582 @@ var version = "dev"
 
 const serverName = "epub-lsp"
 
-// TargetFileExtensions lists the file extensions this LSP supports.
-var TargetFileExtensions = []string{
-	"opf", "xhtml", "html", "css", "ncx",
+// requestHandlers maps request methods to the lsp package handlers that
+// answer them.
+var requestHandlers = map[string]func(
+	context.Context, []byte, lsp.WorkspaceReader,
+) []byte{
+	lsp.MethodHover:              lsp.HandleHover,
+	lsp.MethodCompletion:         lsp.HandleCompletion,
+	lsp.MethodSignatureHelp:      lsp.HandleSignatureHelp,
+	lsp.MethodDefinition:         lsp.HandleDefinition,
+	lsp.MethodReferences:         lsp.HandleReferences,
+	lsp.MethodCodeAction:         lsp.HandleCodeAction,
+	lsp.MethodDocumentSymbol:     lsp.HandleDocumentSymbol,
+	lsp.MethodDocumentLink:       lsp.HandleDocumentLink,
+	lsp.MethodSemanticTokensFull: lsp.HandleSemanticTokens,
+	lsp.MethodWillRenameFiles:    lsp.HandleWillRenameFiles,
+	lsp.MethodRename:             lsp.HandleRename,
+	lsp.MethodCodeLens:           lsp.HandleCodeLens,
+	lsp.MethodDocumentColor:      lsp.HandleDocumentColor,
+	lsp.MethodColorPresentation:  lsp.HandleColorPresentation,
+	lsp.MethodLinkedEditingRange: lsp.HandleLinkedEditingRange,
 }
 
+// errExitBeforeShutdown reports that the client sent exit without first
+// asking the server to shut down.
+var errExitBeforeShutdown = errors.New("exit received before shutdown")
+
 func main() {
-	versionFlag := flag.Bool("version", false, "print the LSP version")
-	flag.Parse()
+	configureLogging()
 
-	if *versionFlag {
-		fmt.Printf("%s -- version %s\n", serverName, version)
-		os.Exit(0)
+	if err := run(os.Stdin, os.Stdout, os.Args[1:]); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
 	}
+}
 
-	registry := validator.NewRegistry()
-	registry.Register(&opf.Validator{})
-	registry.Register(&xhtml.Validator{})
-	registry.Register(&nav.Validator{})
-	registry.Register(&css.Validator{})
-	registry.Register(&resource.ManifestValidator{})
-	registry.Register(&resource.ContentValidator{})
-	registry.Register(&accessibility.MetadataValidator{})
-	registry.Register(&accessibility.PageValidator{})
-	registry.Register(&accessibility.OPFAccessibilityValidator{})
-	registry.Register(&accessibility.StructureValidator{})
-
-	handler := &epubHandler{
-		registry: registry,
-		store: &workspaceStore{
-			RawFiles:    make(map[string][]byte),
-			FileTypes:   make(map[string]epub.FileType),
-			Diagnostics: make(map[string][]epub.Diagnostic),
-		},
+// run parses args and serves LSP messages read from in and written to out,
+// over a TCP connection when the listen flag is given, or from a recorded
+// session when the replay flag is.
+func run(in io.Reader, out io.Writer, args []string) error {
+	flags := flag.NewFlagSet(serverName, flag.ContinueOnError)
+	versionFlag := flags.Bool("version", false, "print the LSP version")
+	listenFlag := flags.String("listen", "",
+		"serve a single TCP connection at `addr:port` instead of stdin/stdout")
+	stdioLogFlag := flags.Bool("stdio-log", false,
+		"record every message received to a session file in the user cache directory")
+	logSinkFlag := flags.String("log", "",
+		"send the log to `file` (the default), stderr, or off, overriding the log setting")
+	replayFlag := flags.String("replay", "",
+		"serve the messages recorded in session `file` and exit")
+	replayOutputFlag := flags.String("replay-output", "",
+		"write the replayed session's output to `file` instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
 	}
 
-	s := &server.Server{
-		Name:    serverName,
-		Version: version,
-		Handler: handler,
+	if *logSinkFlag != "" && !logging.ValidSink(*logSinkFlag) {
+		return fmt.Errorf("unknown log %q: want file, stderr, or off", *logSinkFlag)
+	}
+	if *versionFlag {
+		_, err := fmt.Fprintf(out, "%s -- version %s\n", serverName, version)
+		return err
 	}
 
-	if err := s.Run(context.Background()); err != nil {
-		slog.Error("server error", "err", err)
-		os.Exit(1)
+	startLogging(*logSinkFlag)
+
+	if *replayFlag != "" {
+		return replayFile(*replayFlag, *replayOutputFlag, out)
 	}
-}
 
-// epubHandler implements server.Handler and optional handler interfaces.
-type epubHandler struct {
-	registry *validator.Registry
-	store    *workspaceStore
-}
+	var recorder *replay.Recorder
+	if *stdioLogFlag {
+		path, err := replay.DefaultPath(serverName)
+		if err != nil {
+			return err
+		}
+		if recorder, err = replay.Create(path, replay.MaxFileSize); err != nil {
+			return err
+		}
+		defer recorder.Close()
+		slog.Info("recording session to " + path)
+	}
 
-// workspaceStore holds the state for a workspace.
-type workspaceStore struct {
-	mu          sync.RWMutex
-	RootPath    string
-	RawFiles    map[string][]byte
-	FileTypes   map[string]epub.FileType
-	Diagnostics map[string][]epub.Diagnostic
-	Manifest    *validator.ManifestInfo
-	Settings    *lsp.ServerSettings
-}
+	if *listenFlag != "" {
+		ln, err := net.Listen("tcp", *listenFlag)
+		if err != nil {
+			return err
+		}
+		slog.Info("listening on " + ln.Addr().String())
+		return serveListener(ln, recorder)
+	}
 
-func (s *workspaceStore) GetContent(uri string) []byte {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.RawFiles[uri]
+	return serve(in, out, recorder)
 }
 
-func (s *workspaceStore) GetFileType(uri string) epub.FileType {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.FileTypes[uri]
-}
+// serve handles LSP messages read from in, writing framed responses and
+// notifications to out, until the client exits or in is exhausted. Each
+// message is recorded to recorder first, unless it is nil. Diagnostics
+// still pending are published before it returns.
+func serve(in io.Reader, out io.Writer, recorder *replay.Recorder) error {
+	handler := newEpubHandler(out)
+	done := make(chan struct{})
+	go func() {
+		handler.runDiagnostics()
+		close(done)
+	}()
+
+	scanner := lsp.ReceiveInput(in)
+
+	for scanner.Scan() {
+		if recorder != nil {
+			if err := recorder.Record(scanner.Bytes()); err != nil {
+				slog.Warn("error recording message: " + err.Error())
+			}
+		}
+		if handler.handleMessage(scanner.Bytes()) {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("error reading input: " + err.Error())
+	}
 
-func (s *workspaceStore) GetManifest() *validator.ManifestInfo {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.Manifest
-}
+	handler.stopPolling()
+	handler.requests.Wait()
+	close(handler.pending)
+	<-done
 
-func (s *workspaceStore) GetDiagnostics(uri string) []epub.Diagnostic {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.Diagnostics[uri]
+	if !handler.shutdown {
+		return errExitBeforeShutdown
+	}
+	return nil
 }
 
-func (s *workspaceStore) GetAllFiles() map[string][]byte {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	result := make(map[string][]byte, len(s.RawFiles))
-	maps.Copy(result, s.RawFiles)
-	return result
+// epubHandler dispatches JSON-RPC messages and owns the workspace state.
+type epubHandler struct {
+	store *workspaceStore
+
+	// muStdout serializes writes from the message loop and the
+	// diagnostics goroutine.
+	muStdout sync.Mutex
+	output   io.Writer
+
+	// pending carries URIs whose content changed to the diagnostics
+	// goroutine.
+	pending chan string
+
+	// poller loads files changed on disk when diskPollSeconds is set.
+	poller *diskPoller
+
+	// inflight holds the cancel function of each request still being
+	// handled, by ID, so $/cancelRequest can stop it.
+	muRequests sync.Mutex
+	inflight   map[lsp.ID]context.CancelFunc
+	// requests counts the request goroutines still running.
+	requests sync.WaitGroup
+
+	// logger is the base of request loggers and writes traced messages.
+	logger *slog.Logger
+	// level is the minimum level logger writes, set by the logLevel setting.
+	level *slog.LevelVar
+	// tracing is set when the client asked for messages to be traced.
+	tracing atomic.Bool
+
+	shutdown bool
 }
 
-func (s *workspaceStore) GetRootPath() string {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.RootPath
+func newEpubHandler(output io.Writer) *epubHandler {
+	return &epubHandler{
+		store: &workspaceStore{
+			RawFiles:    make(map[string][]byte),
+			FileTypes:   make(map[string]epub.FileType),
+			Diagnostics: make(map[string][]epub.Diagnostic),
+			Versions:    make(map[string]int),
+			LanguageIDs: make(map[string]string),
+			BOMs:        make(map[string]bool),
+		},
+		output:   output,
+		pending:  make(chan string, 64),
+		inflight: make(map[lsp.ID]context.CancelFunc),
+		logger:   slog.Default(),
+		level:    logLevel,
+	}
 }
 
-func (s *workspaceStore) GetSettings() *lsp.ServerSettings {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.Settings
+// send writes a framed message to the client.
+func (h *epubHandler) send(data []byte) {
+	if data == nil {
+		return
+	}
+	h.traceMessage("sent", data)
+	h.muStdout.Lock()
+	defer h.muStdout.Unlock()
+	transport.Send(h.output, data)
 }
 
-// --- server.Handler ---
-
-func (h *epubHandler) Initialize(
-	_ context.Context,
-	params *protocol.InitializeParams,
-) (protocol.ServerCapabilities, error) {
-	// Extract root URI from workspace folders, falling back to RootURI.
-	var rootURI string
-	if len(params.WorkspaceFolders) > 0 {
-		rootURI = params.WorkspaceFolders[0].URI
-	} else {
-		rootURI = string(
-			params.RootURI,
-		) //nolint:staticcheck // fallback for older clients
-	}
-	h.store.mu.Lock()
-	h.store.RootPath = pathutil.URIToFilePath(rootURI)
-
-	// Extract settings from initialization options
-	if params.InitializationOptions != nil {
-		raw, err := json.Marshal(params.InitializationOptions)
-		if err == nil {
-			var settings lsp.ServerSettings
-			if json.Unmarshal(raw, &settings) == nil {
-				h.store.Settings = &settings
-			}
+// sendAll writes framed messages to the client without interleaving other
+// output.
+func (h *epubHandler) sendAll(messages [][]byte) {
+	h.muStdout.Lock()
+	defer h.muStdout.Unlock()
+	for _, data := range messages {
+		if data != nil {
+			h.traceMessage("sent", data)
+			transport.Send(h.output, data)
 		}
 	}
-	h.store.mu.Unlock()
-
-	return protocol.ServerCapabilities{
-		DocumentLinkProvider:   &protocol.DocumentLinkOptions{},
-		DocumentSymbolProvider: true,
-		DefinitionProvider:     true,
-		ReferencesProvider:     true,
-		HoverProvider:          true,
-		CodeActionProvider: &protocol.CodeActionOptions{
-			CodeActionKinds: []protocol.CodeActionKind{
-				protocol.QuickFix,
-				"source.fixAll",
-			},
-		},
-		CompletionProvider: &protocol.CompletionOptions{
-			TriggerCharacters: []string{"<", "\"", ":", " "},
-		},
-		DocumentFormattingProvider: true,
-		SemanticTokensProvider: map[string]any{
-			"legend": map[string]any{
-				"tokenTypes":     lsp.SemanticTokenTypes,
-				"tokenModifiers": lsp.SemanticTokenModifiers,
-			},
-			"full": true,
-		},
-	}, nil
 }
 
-func (h *epubHandler) Diagnostics(
-	_ context.Context,
-	uri protocol.DocumentURI,
-	content string,
-) ([]protocol.Diagnostic, error) {
-	uriStr := string(uri)
-
-	if !hasTargetExtension(uriStr) {
-		return nil, nil
+// handleMessage dispatches a single JSON-RPC message. It reports true when
+// the client asked the server to exit. A panic is logged with its stack,
+// so one bad message does not stop the server.
+func (h *epubHandler) handleMessage(data []byte) (exit bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			h.logger.Error("message handler panicked", "panic", r, "stack", string(debug.Stack()))
+			exit = false
+		}
+	}()
+	h.traceMessage("received", data)
+
+	var msg struct {
+		Method string  `json:"method"`
+		Id     *lsp.ID `json:"id"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		slog.Error("error unmarshalling message: " + err.Error())
+		if !json.Valid(data) {
+			h.send(lsp.ProcessParseError())
+		} else {
+			h.send(lsp.ProcessInvalidMessage())
+		}
+		return false
 	}
 
-	contentBytes := []byte(content)
-
-	h.store.mu.Lock()
-
-	// Update stored content
-	h.store.RawFiles[uriStr] = contentBytes
-
-	// Detect file type
-	fileType := epub.DetectFileType(uriStr, contentBytes)
-	h.store.FileTypes[uriStr] = fileType
-	opfChanged := fileType == epub.FileTypeOPF
-
-	// Build workspace context
-	ctx := &validator.WorkspaceContext{
-		RootPath:              h.store.RootPath,
-		Files:                 h.store.RawFiles,
-		FileTypes:             h.store.FileTypes,
-		AccessibilitySeverity: accessibilitySeverity(h.store.Settings),
+	// A message without a method is the client's response to a request
+	// the server sent, such as window/showDocument, and needs no answer
+	if msg.Method == "" {
+		return false
 	}
 
-	// Update manifest info from any OPF files
-	for u, c := range h.store.RawFiles {
-		if h.store.FileTypes[u] == epub.FileTypeOPF {
-			if m := opf.ParseManifest(c); m != nil {
-				ctx.Manifest = m
-				h.store.Manifest = m
-				break
-			}
+	if h.shutdown && msg.Method != lsp.MethodExit {
+		if msg.Id != nil {
+			h.send(lsp.ProcessIllegalRequestAfterShutdown(lsp.JSONRPCVersion, *msg.Id))
 		}
+		return false
 	}
 
-	// Resolve file types for all files if needed
-	for u, c := range h.store.RawFiles {
-		if h.store.FileTypes[u] == epub.FileTypeUnknown {
-			h.store.FileTypes[u] = epub.DetectFileType(u, c)
+	switch msg.Method {
+	case lsp.MethodInitialize:
+		response, rootURI, settings := lsp.ProcessInitializeRequest(
+			data, serverName, version,
+		)
+		if settings == nil {
+			h.send(response)
+			break
 		}
-	}
-
-	h.store.mu.Unlock()
-
-	// Validate the changed file
-	diags := h.registry.ValidateFile(uriStr, contentBytes, fileType, ctx)
-
-	h.store.mu.Lock()
-	h.store.Diagnostics[uriStr] = diags
-	h.store.mu.Unlock()
-
-	// If OPF changed, we should re-validate other files too, but the
-	// server harness only calls Diagnostics for the changed file. For now
-	// we handle the single-file case. Cross-file re-validation on OPF
-	// change would require a more advanced integration.
-	_ = opfChanged
-
-	// Convert to protocol diagnostics
-	result := make([]protocol.Diagnostic, len(diags))
-	for i, d := range diags {
-		result[i] = protocol.Diagnostic{
-			Range:    epubRangeToProtocol(d.Range),
-			Message:  d.Message,
-			Severity: protocol.DiagnosticSeverity(d.Severity),
-			Code:     d.Code,
-			Source:   d.Source,
+		h.store.mu.Lock()
+		// Without a root the server runs on single files, and may already
+		// have taken one from an opened package document
+		if root := pathutil.URIToFilePath(rootURI); root != "" {
+			h.store.RootPath = root
+		}
+		h.store.Settings = settings
+		h.store.mu.Unlock()
+		h.applyLogSettings(settings)
+		h.logSessionStart(settings)
+		h.setTrace(settings.Trace)
+		h.send(response)
+		h.loadWorkspace()
+		if settings != nil && settings.DiskPollSeconds > 0 {
+			h.startPolling(time.Duration(settings.DiskPollSeconds) * time.Second)
+		}
+	case lsp.MethodInitialized, lsp.MethodDidClose:
+		// nothing to do
+	case lsp.MethodShutdown:
+		if msg.Id == nil {
+			h.send(lsp.ProcessInvalidRequest(msg.Method))
+			return false
+		}
+		h.shutdown = true
+		h.send(lsp.ProcessShutdownRequest(lsp.JSONRPCVersion, *msg.Id))
+	case lsp.MethodExit:
+		return true
+	case lsp.MethodCancelRequest:
+		if id, ok := lsp.ProcessCancelRequestNotification(data); ok {
+			h.cancelRequest(id)
+		}
+	case lsp.MethodSetTrace:
+		if value, ok := lsp.ProcessSetTraceNotification(data); ok {
+			h.setTrace(value)
+		}
+	case lsp.MethodDidChangeConfiguration:
+		// Only the log settings apply without restarting the server
+		if settings, ok := lsp.ProcessDidChangeConfigurationNotification(data); ok {
+			h.applyLogSettings(settings)
+		}
+	case lsp.MethodDidOpen:
+		h.openDocument(lsp.ProcessDidOpenTextDocumentNotification(data))
+	case lsp.MethodDidChange:
+		h.updateDocument(lsp.ProcessDidChangeTextDocumentNotification(data))
+	case lsp.MethodHealth:
+		if msg.Id != nil {
+			h.send(lsp.ProcessHealthRequest(*msg.Id, h.health()))
+		}
+	case lsp.MethodFormatting:
+		h.startRequest(msg.Id, msg.Method, func(ctx context.Context) [][]byte {
+			response, notifications := lsp.HandleFormatting(ctx, data, h.store)
+			return append([][]byte{response}, notifications...)
+		})
+	case lsp.MethodExecuteCommand:
+		h.startRequest(msg.Id, msg.Method, func(ctx context.Context) [][]byte {
+			response, notifications := lsp.HandleExecuteCommand(ctx, data, h.store)
+			return append([][]byte{response}, notifications...)
+		})
+	default:
+		if handle, ok := requestHandlers[msg.Method]; ok {
+			h.startRequest(msg.Id, msg.Method, func(ctx context.Context) [][]byte {
+				return [][]byte{handle(ctx, data, h.store)}
+			})
+		} else if msg.Id != nil {
+			h.send(lsp.ProcessMethodNotFound(lsp.JSONRPCVersion, *msg.Id, msg.Method))
 		}
 	}
-
-	return result, nil
+	return false
 }
 
-func (h *epubHandler) Shutdown(_ context.Context) error {
-	return nil
+// startRequest runs handle on its own goroutine, so the message loop can
+// read a $/cancelRequest for it, and sends the messages it returns. The
+// context passed to handle is cancelled when the client cancels id, and
+// carries a logger with the request's id and method.
+func (h *epubHandler) startRequest(
+	id *lsp.ID,
+	method string,
+	handle func(context.Context) [][]byte,
+) {
+	logger := h.logger.With("method", method)
+	if id != nil {
+		logger = logger.With("id", *id)
+	}
+	ctx, cancel := context.WithCancel(lsp.WithLogger(context.Background(), logger))
+	if id != nil {
+		h.muRequests.Lock()
+		h.inflight[*id] = cancel
+		h.muRequests.Unlock()
+	}
+
+	h.requests.Go(func() {
+		defer cancel()
+		start := time.Now()
+		messages := handleSafely(ctx, logger, id, handle)
+		logger.Debug("handled request", "duration", time.Since(start))
+		if id != nil {
+			h.muRequests.Lock()
+			delete(h.inflight, *id)
+			h.muRequests.Unlock()
+		}
+		h.sendAll(messages)
+	})
 }
 
-// --- Optional handlers using JSON round-trip bridge ---
+// handleSafely calls handle. A panic is logged with its stack and answered
+// with an internal error response, so one failing request does not stop
+// the server.
+func handleSafely(
+	ctx context.Context,
+	logger *slog.Logger,
+	id *lsp.ID,
+	handle func(context.Context) [][]byte,
+) (messages [][]byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("request handler panicked", "panic", r, "stack", string(debug.Stack()))
+			messages = nil
+			if id != nil {
+				messages = [][]byte{lsp.ProcessInternalError(lsp.JSONRPCVersion, *id,
+					fmt.Sprintf("internal error: %v", r))}
+			}
+		}
+	}()
+	return handle(ctx)
+}
 
-// roundTrip marshals protocol params into a JSON-RPC request, calls the
-// existing handler, and unmarshals the result from the JSON-RPC response.
-func roundTrip[P any, R any](
-	id int,
-	method string,
-	params P,
-	handler func([]byte, lsp.WorkspaceReader) []byte,
-	ws lsp.WorkspaceReader,
-) (R, error) {
-	req := struct {
-		JsonRpc string `json:"jsonrpc"`
-		Id      int    `json:"id"`
-		Method  string `json:"method"`
-		Params  P      `json:"params"`
-	}{
-		JsonRpc: "2.0",
-		Id:      id,
-		Method:  method,
-		Params:  params,
+// cancelRequest cancels the context of the request id if it is still
+// running. Requests that already finished are ignored.
+func (h *epubHandler) cancelRequest(id lsp.ID) {
+	h.muRequests.Lock()
+	defer h.muRequests.Unlock()
+	if cancel, ok := h.inflight[id]; ok {
+		cancel()
 	}
+}
 
-	data, err := json.Marshal(req)
-	if err != nil {
-		var zero R
-		return zero, err
+// openDocument records the language of a document the client opened and
+// stores it.
+func (h *epubHandler) openDocument(
+	uri string,
+	content []byte,
+	version int,
+	languageID string,
+) {
+	if uri == "" {
+		return
 	}
+	h.store.mu.Lock()
+	h.store.LanguageIDs[uri] = languageID
+	h.store.mu.Unlock()
 
-	respData := handler(data, ws)
+	h.updateDocument(uri, content, version)
+}
 
-	var resp struct {
-		Result R `json:"result"`
+// updateDocument stores a client's copy of a document and queues it for
+// validation. Documents that are not EPUB sources by extension, language,
+// or root element are ignored. A leading byte order mark is stripped and
+// remembered, so positions count from after it as in the editor.
+func (h *epubHandler) updateDocument(uri string, content []byte, version int) {
+	if uri == "" {
+		return
 	}
-	if err := json.Unmarshal(respData, &resp); err != nil {
-		var zero R
-		return zero, err
+
+	content, bom := epub.StripBOM(content)
+	h.store.mu.Lock()
+	fileType := epub.DetectFileTypeWithLanguage(uri, content, h.store.LanguageIDs[uri])
+	if _, stored := h.store.RawFiles[uri]; fileType == epub.FileTypeUnknown && !stored {
+		h.store.mu.Unlock()
+		return
+	}
+	h.store.RawFiles[uri] = content
+	h.store.setBOM(uri, bom)
+	h.store.FileTypes[uri] = fileType
+	h.store.Versions[uri] = version
+	if h.store.RootPath == "" && fileType == epub.FileTypeOPF {
+		h.store.RootPath = inferredRoot(uri)
 	}
+	h.store.mu.Unlock()
 
-	return resp.Result, nil
+	h.pending <- uri
 }
 
-func (h *epubHandler) Hover(
-	_ context.Context,
-	params *protocol.HoverParams,
-) (*protocol.Hover, error) { //nolint:unparam // interface method
-	type hoverParams struct {
-		TextDocument struct {
-			Uri string `json:"uri"`
-		} `json:"textDocument"`
-		Position struct {
-			Line      uint32 `json:"line"`
-			Character uint32 `json:"character"`
-		} `json:"position"`
+// inferredRoot returns the directory of the package document at uri, which
+// stands in for the workspace root when the client opened files outside
+// any root. Documents that are not on disk give no root.
+func inferredRoot(uri string) string {
+	if !strings.HasPrefix(uri, "file:") {
+		return ""
 	}
-	p := hoverParams{}
-	p.TextDocument.Uri = string(params.TextDocument.URI)
-	p.Position.Line = params.Position.Line
-	p.Position.Character = params.Position.Character
-
-	result, err := roundTrip[hoverParams, *protocol.Hover](
-		1,
-		"textDocument/hover",
-		p,
-		lsp.HandleHover,
-		h.store,
-	)
-	if err != nil {
-		return nil, nil //nolint:nilerr // hover errors should return nil, not fail
+	path := pathutil.URIToFilePath(uri)
+	if path == "" {
+		return ""
 	}
-	return result, nil
+	return filepath.Dir(path)
 }
 
-func (h *epubHandler) Completion(
-	_ context.Context,
-	params *protocol.CompletionParams,
-) (*protocol.CompletionList, error) { //nolint:unparam // interface method
-	type completionParams struct {
-		TextDocument struct {
-			Uri string `json:"uri"`
-		} `json:"textDocument"`
-		Position struct {
-			Line      uint32 `json:"line"`
-			Character uint32 `json:"character"`
-		} `json:"position"`
-	}
-	p := completionParams{}
-	p.TextDocument.Uri = string(params.TextDocument.URI)
-	p.Position.Line = params.Position.Line
-	p.Position.Character = params.Position.Character
-
-	result, err := roundTrip[completionParams, *protocol.CompletionList](
-		1,
-		"textDocument/completion",
-		p,
-		lsp.HandleCompletion,
-		h.store,
-	)
-	if err != nil {
-		return nil, nil //nolint:nilerr // completion errors should return nil
-	}
-	return result, nil
+// workspaceStore holds the state for a workspace.
+type workspaceStore struct {
+	mu          sync.RWMutex
+	RootPath    string
+	RawFiles    map[string][]byte
+	FileTypes   map[string]epub.FileType
+	Diagnostics map[string][]epub.Diagnostic
+	// Versions holds the client's document version for each open file.
+	Versions map[string]int
+	// LanguageIDs holds the client's language ID for each opened file.
+	LanguageIDs map[string]string
+	// BOMs records the files whose content started with a byte order mark,
+	// which is stripped from RawFiles.
+	BOMs     map[string]bool
+	Manifest *validator.ManifestInfo
+	Settings *lsp.ServerSettings
+	// LastValidation describes the latest validation pass, for the health
+	// request.
+	LastValidation *lsp.ValidationMetrics
 }
 
-func (h *epubHandler) Definition(
-	_ context.Context,
-	params *protocol.DefinitionParams,
-) ([]protocol.Location, error) { //nolint:unparam // interface method
-	type definitionParams struct {
-		TextDocument struct {
-			Uri string `json:"uri"`
-		} `json:"textDocument"`
-		Position struct {
-			Line      uint32 `json:"line"`
-			Character uint32 `json:"character"`
-		} `json:"position"`
-	}
-	p := definitionParams{}
-	p.TextDocument.Uri = string(params.TextDocument.URI)
-	p.Position.Line = params.Position.Line
-	p.Position.Character = params.Position.Character
-
-	result, err := roundTrip[definitionParams, []protocol.Location](
-		1,
-		"textDocument/definition",
-		p,
-		lsp.HandleDefinition,
-		h.store,
-	)
-	if err != nil {
-		return nil, nil //nolint:nilerr // definition errors should return nil
-	}
-	return result, nil
+func (s *workspaceStore) GetContent(uri string) []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.RawFiles[uri]
 }
 
-func (h *epubHandler) Formatting(
-	_ context.Context,
-	params *protocol.DocumentFormattingParams,
-) ([]protocol.TextEdit, error) { //nolint:unparam // interface method
-	type formattingParams struct {
-		TextDocument struct {
-			Uri string `json:"uri"`
-		} `json:"textDocument"`
-		Options struct {
-			TabSize      int  `json:"tabSize"`
-			InsertSpaces bool `json:"insertSpaces"`
-		} `json:"options"`
-	}
-	p := formattingParams{}
-	p.TextDocument.Uri = string(params.TextDocument.URI)
-	p.Options.TabSize = int(params.Options.TabSize)
-	p.Options.InsertSpaces = params.Options.InsertSpaces
-
-	result, err := roundTrip[formattingParams, []protocol.TextEdit](
-		1,
-		"textDocument/formatting",
-		p,
-		lsp.HandleFormatting,
-		h.store,
-	)
-	if err != nil {
-		return nil, nil //nolint:nilerr // formatting errors should return nil
-	}
-	return result, nil
+func (s *workspaceStore) GetFileType(uri string) epub.FileType {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.FileTypes[uri]
 }
 
-func (h *epubHandler) CodeAction(
-	_ context.Context,
-	params *protocol.CodeActionParams,
-) ([]protocol.CodeAction, error) { //nolint:unparam // interface method
-	// Marshal the protocol params directly - the JSON shape is compatible
-	data, err := json.Marshal(struct {
-		JsonRpc string                     `json:"jsonrpc"`
-		Id      int                        `json:"id"`
-		Method  string                     `json:"method"`
-		Params  *protocol.CodeActionParams `json:"params"`
-	}{
-		JsonRpc: "2.0",
-		Id:      1,
-		Method:  "textDocument/codeAction",
-		Params:  params,
-	})
-	if err != nil {
-		return nil, nil //nolint:nilerr // code action errors should return nil
-	}
+func (s *workspaceStore) GetManifest() *validator.ManifestInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Manifest
+}
 
-	respData := lsp.HandleCodeAction(data, h.store)
+func (s *workspaceStore) GetDiagnostics(uri string) []epub.Diagnostic {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Diagnostics[uri]
+}
 
-	var resp struct {
-		Result []protocol.CodeAction `json:"result"`
-	}
-	if err := json.Unmarshal(respData, &resp); err != nil {
-		return nil, nil //nolint:nilerr // unmarshal errors should return nil
-	}
-	return resp.Result, nil
+func (s *workspaceStore) Validated(uri string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.Diagnostics[uri]
+	return ok
 }
 
-func (h *epubHandler) References(
-	_ context.Context,
-	params *protocol.ReferenceParams,
-) ([]protocol.Location, error) { //nolint:unparam // interface method
-	type referenceParams struct {
-		TextDocument struct {
-			Uri string `json:"uri"`
-		} `json:"textDocument"`
-		Position struct {
-			Line      uint32 `json:"line"`
-			Character uint32 `json:"character"`
-		} `json:"position"`
-		Context struct {
-			IncludeDeclaration bool `json:"includeDeclaration"`
-		} `json:"context"`
-	}
-	p := referenceParams{}
-	p.TextDocument.Uri = string(params.TextDocument.URI)
-	p.Position.Line = params.Position.Line
-	p.Position.Character = params.Position.Character
-	p.Context.IncludeDeclaration = params.Context.IncludeDeclaration
-
-	result, err := roundTrip[referenceParams, []protocol.Location](
-		1,
-		"textDocument/references",
-		p,
-		lsp.HandleReferences,
-		h.store,
-	)
-	if err != nil {
-		return nil, nil //nolint:nilerr // references errors should return nil
+func (s *workspaceStore) Validate(uri string) []epub.Diagnostic {
+	s.mu.RLock()
+	files := maps.Clone(s.RawFiles)
+	opts := s.lintOptions()
+	s.mu.RUnlock()
+
+	for _, result := range epublint.NewWorkspace(files, opts).ValidateFiles(uri) {
+		return result.Diagnostics
 	}
-	return result, nil
+	return nil
 }
 
-func (h *epubHandler) DocumentSymbol(
-	_ context.Context,
-	params *protocol.DocumentSymbolParams,
-) ([]any, error) { //nolint:unparam // interface method
-	type docSymbolParams struct {
-		TextDocument struct {
-			Uri string `json:"uri"`
-		} `json:"textDocument"`
-	}
-	p := docSymbolParams{}
-	p.TextDocument.Uri = string(params.TextDocument.URI)
-
-	result, err := roundTrip[docSymbolParams, []any](
-		1,
-		"textDocument/documentSymbol",
-		p,
-		lsp.HandleDocumentSymbol,
-		h.store,
-	)
-	if err != nil {
-		return nil, nil //nolint:nilerr // document symbol errors should return nil
-	}
-	return result, nil
+func (s *workspaceStore) GetAllFiles() map[string][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string][]byte, len(s.RawFiles))
+	maps.Copy(result, s.RawFiles)
+	return result
 }
 
-func (h *epubHandler) DocumentLink(
-	_ context.Context,
-	params *protocol.DocumentLinkParams,
-) ([]protocol.DocumentLink, error) { //nolint:unparam // interface method
-	type docLinkParams struct {
-		TextDocument struct {
-			Uri string `json:"uri"`
-		} `json:"textDocument"`
-	}
-	p := docLinkParams{}
-	p.TextDocument.Uri = string(params.TextDocument.URI)
-
-	result, err := roundTrip[docLinkParams, []protocol.DocumentLink](
-		1,
-		"textDocument/documentLink",
-		p,
-		lsp.HandleDocumentLink,
-		h.store,
-	)
-	if err != nil {
-		return nil, nil //nolint:nilerr // document link errors should return nil
-	}
-	return result, nil
+func (s *workspaceStore) HasBOM(uri string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.BOMs[uri]
 }
 
-func (h *epubHandler) SemanticTokensFull(
-	_ context.Context,
-	params *protocol.SemanticTokensParams,
-) (*protocol.SemanticTokens, error) { //nolint:unparam // interface method
-	type semTokenParams struct {
-		TextDocument struct {
-			Uri string `json:"uri"`
-		} `json:"textDocument"`
-	}
-	p := semTokenParams{}
-	p.TextDocument.Uri = string(params.TextDocument.URI)
-
-	result, err := roundTrip[semTokenParams, *protocol.SemanticTokens](
-		1,
-		"textDocument/semanticTokens/full",
-		p,
-		lsp.HandleSemanticTokens,
-		h.store,
-	)
-	if err != nil {
-		return nil, nil //nolint:nilerr // semantic token errors should return nil
+// setBOM records whether the content of uri started with a byte order mark.
+// The caller must hold s.mu.
+func (s *workspaceStore) setBOM(uri string, bom bool) {
+	if bom {
+		s.BOMs[uri] = true
+	} else {
+		delete(s.BOMs, uri)
 	}
-	return result, nil
 }
 
-// --- Conversion helpers ---
-
-// intToU32 converts an int to uint32, clamping negatives to 0.
-func intToU32(n int) uint32 {
-	if n < 0 {
-		return 0
-	}
-	return uint32(n) //nolint:gosec // line/character numbers fit in uint32
+func (s *workspaceStore) GetRootPath() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.RootPath
 }
 
-// epubRangeToProtocol converts an epub.Range to a protocol.Range.
-func epubRangeToProtocol(r epub.Range) protocol.Range {
-	return protocol.Range{
-		Start: protocol.Position{
-			Line:      intToU32(r.Start.Line),
-			Character: intToU32(r.Start.Character),
-		},
-		End: protocol.Position{
-			Line:      intToU32(r.End.Line),
-			Character: intToU32(r.End.Character),
-		},
-	}
+func (s *workspaceStore) GetSettings() *lsp.ServerSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Settings
 }
 
 // --- Utilities ---
 
-// hasTargetExtension checks if a URI has one of the target file extensions.
-func hasTargetExtension(uri string) bool {
-	lower := strings.ToLower(uri)
-	for _, ext := range TargetFileExtensions {
-		if strings.HasSuffix(lower, "."+ext) {
-			return true
-		}
-	}
-	return false
-}
-
-// accessibilitySeverity maps the settings string to an epub severity constant.
-func accessibilitySeverity(settings *lsp.ServerSettings) int {
-	if settings == nil {
-		return epub.SeverityWarning
-	}
-	switch settings.Accessibility {
-	case "ignore":
-		return 0
-	case "error":
-		return epub.SeverityError
-	default:
-		return epub.SeverityWarning
-	}
+// lintOptions maps the server settings and open document languages to
+// validation options. The caller must hold s.mu.
+func (s *workspaceStore) lintOptions() epublint.Options {
+	opts := epublint.Options{
+		RootPath:    s.RootPath,
+		LanguageIDs: maps.Clone(s.LanguageIDs),
+	}
+	if s.Settings != nil {
+		opts.Accessibility = s.Settings.Accessibility
+		opts.EPUBVersion = s.Settings.EpubVersion
+		opts.Validators = s.Settings.Validators
+		opts.AltRedundantPhrases = s.Settings.AltRedundantPhrases
+		opts.FakeHeadings = s.Settings.FakeHeadings
+		opts.MultipleH1 = s.Settings.MultipleH1
+		opts.SkipWhitespace = s.Settings.Whitespace != nil && !*s.Settings.Whitespace
+		opts.DisabledCodes = s.Settings.DisabledCodes
+		opts.ProseLineLength = s.Settings.ProseLineLength
+		opts.DuplicateResources = s.Settings.DuplicateResources
+		opts.Concurrency = s.Settings.ValidationConcurrency
+	}
+	return opts
 }
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/toba/epub-lsp/cmd/epub-lsp/lsp"
+	"github.com/toba/epub-lsp/epublint"
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/ignore"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/lsp/pathutil"
+)
+
+// fileStamp is the size and modification time of a file on disk, which the
+// disk poller compares to detect changes without reading content.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// diskPoller re-reads target files that change on disk, for clients that
+// send no file change events. It is enabled by the diskPollSeconds setting;
+// without it, only the workspace load at initialize scans the disk.
+type diskPoller struct {
+	// stamps holds the last seen stamp of each target file, by URI. It is
+	// nil until the first poll.
+	stamps map[string]fileStamp
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// loadWorkspace loads the target files under the workspace root that are
+// not ignored or open in the client, and queues them for validation. The
+// stamps it records keep a disk poller started later from loading them
+// again.
+func (h *epubHandler) loadWorkspace() {
+	for _, uri := range h.pollDisk() {
+		h.pending <- uri
+	}
+}
+
+// startPolling starts polling the workspace root every interval and
+// queueing files that changed on disk for validation.
+func (h *epubHandler) startPolling(interval time.Duration) {
+	var stamps map[string]fileStamp
+	if h.poller != nil {
+		stamps = h.poller.stamps
+	}
+	h.poller = &diskPoller{
+		stamps: stamps,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(h.poller.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			for _, uri := range h.pollDisk() {
+				select {
+				case h.pending <- uri:
+				case <-h.poller.stop:
+					return
+				}
+			}
+			select {
+			case <-h.poller.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// stopPolling stops the disk poller, if running, and waits for it to exit
+// so it no longer queues URIs.
+func (h *epubHandler) stopPolling() {
+	if h.poller == nil || h.poller.stop == nil {
+		return
+	}
+	close(h.poller.stop)
+	<-h.poller.done
+}
+
+// pollDisk stats the target files under the workspace root and loads those
+// whose size or modification time changed since the last poll, dropping
+// those deleted. The first poll loads every target file. Documents open in
+// the client are never replaced. It returns the URIs whose content changed.
+func (h *epubHandler) pollDisk() []string {
+	if h.poller == nil {
+		h.poller = &diskPoller{}
+	}
+
+	h.store.mu.RLock()
+	root := h.store.RootPath
+	settings := h.store.Settings
+	h.store.mu.RUnlock()
+	if root == "" {
+		return nil
+	}
+
+	stamps := scanStamps(root, lsp.IgnoreMatcher(root, settings))
+	previous := h.poller.stamps
+	h.poller.stamps = stamps
+
+	var changed []string
+	for uri, stamp := range stamps {
+		if old, ok := previous[uri]; ok && old == stamp {
+			continue
+		}
+		content, err := os.ReadFile(pathutil.URIToFilePath(uri))
+		if err != nil {
+			slog.Error("error reading changed file: " + err.Error())
+			continue
+		}
+		if h.loadFromDisk(uri, content) {
+			changed = append(changed, uri)
+		}
+	}
+	for uri := range previous {
+		if _, ok := stamps[uri]; !ok && h.loadFromDisk(uri, nil) {
+			changed = append(changed, uri)
+		}
+	}
+	return changed
+}
+
+// loadFromDisk stores content read from disk for uri, or removes the file
+// when content is nil, unless the client has the document open. It reports
+// whether the workspace changed.
+func (h *epubHandler) loadFromDisk(uri string, content []byte) bool {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+
+	p := uriutil.Path(uri)
+	for open := range h.store.Versions {
+		if uriutil.Path(open) == p {
+			return false
+		}
+	}
+
+	if content == nil {
+		if _, ok := h.store.RawFiles[uri]; !ok {
+			return false
+		}
+		delete(h.store.RawFiles, uri)
+		delete(h.store.FileTypes, uri)
+		delete(h.store.BOMs, uri)
+		return true
+	}
+	content, bom := epub.StripBOM(content)
+	h.store.RawFiles[uri] = content
+	h.store.setBOM(uri, bom)
+	h.store.FileTypes[uri] = epub.DetectFileType(uri, content)
+	return true
+}
+
+// scanStamps returns the stamps of the target files under root, skipping
+// those ignored matches.
+func scanStamps(root string, ignored *ignore.Matcher) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	fsys := os.DirFS(root)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable entries are skipped
+		}
+		if ignored.Match(p, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !epublint.IsTargetFile(p) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		uri := pathutil.FilePathToURI(filepath.Join(root, filepath.FromSlash(p)))
+		stamps[uri] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		slog.Error("error scanning workspace: " + err.Error())
+	}
+	return stamps
+}
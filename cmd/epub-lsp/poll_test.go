@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/toba/epub-lsp/cmd/epub-lsp/lsp"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/lsp/pathutil"
+)
+
+// newPollingHandler returns a handler whose workspace root is a temporary
+// directory holding style.css, and the stylesheet's path and URI.
+func newPollingHandler(t *testing.T, out *bytes.Buffer) (*epubHandler, string, string) {
+	t.Helper()
+	root := t.TempDir()
+	file := filepath.Join(root, "OEBPS", "style.css")
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, file, "p { margin: 0 }")
+
+	h := newEpubHandler(out)
+	h.store.RootPath = root
+	uri := pathutil.FilePathToURI(file)
+	if changed := h.pollDisk(); !slices.Equal(changed, []string{uri}) {
+		t.Fatalf("expected the first poll to load %s, got %v", uri, changed)
+	}
+	return h, file, uri
+}
+
+// writeFile writes content to name and moves its modification time forward,
+// so the change is seen even on file systems with coarse timestamps.
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(name, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPollDiskLoadsChangedFile(t *testing.T) {
+	var out bytes.Buffer
+	h, file, uri := newPollingHandler(t, &out)
+
+	writeFile(t, file, "p { margin: 0 }\n@import \"base.css\";")
+	changed := h.pollDisk()
+	if !slices.Equal(changed, []string{uri}) {
+		t.Fatalf("expected %s to change, got %v", uri, changed)
+	}
+	if changed := h.pollDisk(); len(changed) != 0 {
+		t.Errorf("expected no changes on the next poll, got %v", changed)
+	}
+
+	h.publish(h.validate(map[string]bool{uri: true}))
+	published := readPublished(t, &out)
+	if len(published) != 1 || published[0].Uri != uri {
+		t.Fatalf("expected diagnostics for %s, got %v", uri, published)
+	}
+	if !testutil.HasCode(h.store.GetDiagnostics(uri), "css-at-rule-order") {
+		t.Errorf("expected diagnostics for the updated content, got %v",
+			h.store.GetDiagnostics(uri))
+	}
+}
+
+func TestPollDiskLoadsFilesOnFirstPoll(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "OEBPS", "style.css")
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, file, "p { margin: 0 }\n@import \"base.css\";")
+	uri := pathutil.FilePathToURI(file)
+
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+	h.store.RootPath = root
+	changed := h.pollDisk()
+	if !slices.Equal(changed, []string{uri}) {
+		t.Fatalf("expected the first poll to load %s, got %v", uri, changed)
+	}
+
+	h.publish(h.validate(sliceSet(changed)))
+	readPublished(t, &out)
+	if !testutil.HasCode(h.store.GetDiagnostics(uri), "css-at-rule-order") {
+		t.Errorf("expected diagnostics for the file on disk, got %v",
+			h.store.GetDiagnostics(uri))
+	}
+}
+
+func TestPollDiskKeepsOpenDocument(t *testing.T) {
+	var out bytes.Buffer
+	h, file, uri := newPollingHandler(t, &out)
+
+	editorContent := []byte("p { margin: 1em }")
+	h.updateDocument(uri, editorContent, 1)
+
+	writeFile(t, file, "p { margin: 0 }\n@import \"base.css\";")
+	if changed := h.pollDisk(); len(changed) != 0 {
+		t.Errorf("expected the open document to be left alone, got %v", changed)
+	}
+	if got := h.store.GetContent(uri); !bytes.Equal(got, editorContent) {
+		t.Errorf("open document replaced with %q", got)
+	}
+}
+
+func TestPollDiskRemovesDeletedFile(t *testing.T) {
+	var out bytes.Buffer
+	h, file, uri := newPollingHandler(t, &out)
+
+	writeFile(t, file, "p { margin: 0 }\n@import \"base.css\";")
+	h.publish(h.validate(sliceSet(h.pollDisk())))
+	readPublished(t, &out)
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	changed := h.pollDisk()
+	if !slices.Equal(changed, []string{uri}) {
+		t.Fatalf("expected %s to be removed, got %v", uri, changed)
+	}
+	if h.store.GetContent(uri) != nil {
+		t.Error("expected the deleted file to leave the workspace")
+	}
+
+	h.publish(h.validate(sliceSet(changed)))
+	published := readPublished(t, &out)
+	if len(published) != 1 || len(published[0].Diagnostics) != 0 {
+		t.Errorf("expected the file's diagnostics to be cleared, got %v", published)
+	}
+}
+
+func TestPollingStopsCleanly(t *testing.T) {
+	var out bytes.Buffer
+	h, file, uri := newPollingHandler(t, &out)
+	h.poller = nil
+
+	h.startPolling(10 * time.Millisecond)
+	for _, event := range []string{"loaded", "changed"} {
+		select {
+		case got := <-h.pending:
+			if got != uri {
+				t.Errorf("expected %s to be queued, got %s", uri, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s file was never queued", event)
+		}
+		writeFile(t, file, "p { margin: 2em }")
+	}
+
+	h.stopPolling()
+	select {
+	case <-h.poller.done:
+	default:
+		t.Error("poller still running after stopPolling")
+	}
+}
+
+// sliceSet returns the set of the strings in s.
+func sliceSet(s []string) map[string]bool {
+	set := make(map[string]bool, len(s))
+	for _, v := range s {
+		set[v] = true
+	}
+	return set
+}
+
+func TestScanStampsSkipsIgnoredPaths(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"OEBPS/content.opf",
+		"OEBPS/chapter1.xhtml",
+		"OEBPS/style.css",
+		"OEBPS/drafts/chapter0.xhtml",
+		"OEBPS/.backup/chapter1.xhtml",
+		"dist/OEBPS/chapter1.xhtml",
+		"dist/keep.css",
+		"node_modules/theme/theme.css",
+		"book.epub",
+		"notes.log",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, path, "")
+	}
+	writeFile(t, filepath.Join(root, ".gitignore"), "dist/\n!dist/keep.css\n")
+
+	tests := []struct {
+		name     string
+		settings *lsp.ServerSettings
+		want     []string
+	}{
+		{
+			name: "defaults",
+			want: []string{
+				"OEBPS/chapter1.xhtml", "OEBPS/content.opf",
+				"OEBPS/drafts/chapter0.xhtml", "OEBPS/style.css",
+				"dist/OEBPS/chapter1.xhtml", "dist/keep.css",
+			},
+		},
+		{
+			name:     "settings and gitignore",
+			settings: &lsp.ServerSettings{Ignore: []string{"drafts/"}, Gitignore: true},
+			want: []string{
+				"OEBPS/chapter1.xhtml", "OEBPS/content.opf", "OEBPS/style.css",
+			},
+		},
+		{
+			name:     "settings negate defaults",
+			settings: &lsp.ServerSettings{Ignore: []string{"!node_modules/"}},
+			want: []string{
+				"OEBPS/chapter1.xhtml", "OEBPS/content.opf",
+				"OEBPS/drafts/chapter0.xhtml", "OEBPS/style.css",
+				"dist/OEBPS/chapter1.xhtml", "dist/keep.css",
+				"node_modules/theme/theme.css",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stamps := scanStamps(root, lsp.IgnoreMatcher(root, tt.settings))
+			var got []string
+			for uri := range stamps {
+				rel, err := filepath.Rel(root, pathutil.URIToFilePath(uri))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("scanned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitializeLoadsWorkspaceSkippingIgnoredPaths(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"OEBPS/content.opf",
+		"OEBPS/chapter1.xhtml",
+		"dist/OEBPS/chapter1.xhtml",
+		"node_modules/theme/theme.css",
+		"book.epub",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, path, "")
+	}
+
+	h := newEpubHandler(io.Discard)
+	h.handleMessage(fmt.Appendf(nil, `{"jsonrpc":"2.0","id":1,"method":"initialize",`+
+		`"params":{"rootUri":%q,"initializationOptions":{"ignore":["dist/"]}}}`,
+		pathutil.FilePathToURI(root)))
+
+	var got []string
+	for uri := range h.store.GetAllFiles() {
+		rel, err := filepath.Rel(root, pathutil.URIToFilePath(uri))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	slices.Sort(got)
+	want := []string{"OEBPS/chapter1.xhtml", "OEBPS/content.opf"}
+	if !slices.Equal(got, want) {
+		t.Errorf("loaded %v, want %v", got, want)
+	}
+	if len(h.pending) != len(want) {
+		t.Errorf("expected %d files queued for validation, got %d", len(want), len(h.pending))
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/toba/epub-lsp/internal/replay"
+)
+
+// replayFile serves the messages recorded in the session file at path,
+// writing the server's output to the file at output, or to out when output
+// is empty. A session cut short before shutdown and exit, as by the crash
+// being reproduced, is not an error.
+func replayFile(path, output string, out io.Writer) (err error) {
+	file, err := os.Open(path) //nolint:gosec // path given on the command line
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if output != "" {
+		f, err := os.Create(output) //nolint:gosec // path given on the command line
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		out = f
+	}
+
+	in := replay.Frame(file)
+	defer in.Close()
+	if err := serve(in, out, nil); err != nil && !errors.Is(err, errExitBeforeShutdown) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/replay"
+	"github.com/toba/lsp/transport"
+)
+
+// replayMessages is a synthetic session: a document is opened and hovered
+// before the server shuts down.
+var replayMessages = []string{
+	`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"rootUri":"file:///book"}}`,
+	`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+	`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///book/chapter.xhtml","languageId":"xhtml","version":1,"text":"<?xml version=\"1.0\"?>\n<html xmlns=\"http://www.w3.org/1999/xhtml\" lang=\"en\" xml:lang=\"en\">\n<head><title>T</title></head>\n<body><img src=\"a.png\"/></body>\n</html>\n"}}}`,
+	`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///book/chapter.xhtml"},"position":{"line":3,"character":8}}}`,
+	`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+	`{"jsonrpc":"2.0","method":"exit"}`,
+}
+
+// sortedOutput returns the messages framed in out, normalized and sorted,
+// since requests are answered from their own goroutines.
+func sortedOutput(t *testing.T, out []byte) []string {
+	t.Helper()
+	var got []string
+	scanner := transport.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		msg, err := json.Marshal(normalizeMessage(t, scanner.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(msg))
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestReplayMatchesDirectRun(t *testing.T) {
+	var in bytes.Buffer
+	for _, msg := range replayMessages {
+		in.Write(frame([]byte(msg)))
+	}
+	input := in.Bytes()
+
+	var direct bytes.Buffer
+	if err := run(bytes.NewReader(input), &direct, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "epub-lsp.session")
+	recorder, err := replay.Create(path, replay.MaxFileSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded bytes.Buffer
+	if err := serve(bytes.NewReader(input), &recorded, recorder); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "replay.out")
+	if err := run(strings.NewReader(""), nil, []string{
+		"-replay", path, "-replay-output", output,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := os.ReadFile(output) //nolint:gosec // test temp path
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := sortedOutput(t, direct.Bytes())
+	if len(want) == 0 {
+		t.Fatal("direct run produced no output")
+	}
+	if got := sortedOutput(t, recorded.Bytes()); !slices.Equal(got, want) {
+		t.Errorf("recording changed the output:\n%s\nwant:\n%s", got, want)
+	}
+	if got := sortedOutput(t, replayed); !slices.Equal(got, want) {
+		t.Errorf("replayed output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReplayTruncatedSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "epub-lsp.session")
+	recorder, err := replay.Create(path, replay.MaxFileSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A crash leaves the session without shutdown and exit
+	for _, msg := range replayMessages[:4] {
+		if err := recorder.Record([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run(strings.NewReader(""), &out, []string{"-replay", path}); err != nil {
+		t.Fatalf("expected a cut-short session to replay, got %v", err)
+	}
+	if got := sortedOutput(t, out.Bytes()); len(got) == 0 {
+		t.Error("expected replayed output")
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toba/epub-lsp/cmd/epub-lsp/lsp"
+	"github.com/toba/lsp/transport"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// sessionTimeout bounds how long a replay waits for the server to answer.
+const sessionTimeout = 5 * time.Second
+
+// sessionStep is one line of a recorded session: a message for the client
+// to send and how many messages the server answers it with.
+type sessionStep struct {
+	Send   json.RawMessage `json:"send"`
+	Expect int             `json:"expect"`
+}
+
+func TestSessions(t *testing.T) {
+	for _, name := range []string{"session", "after_shutdown", "string_ids", "bad_params"} {
+		t.Run(name, func(t *testing.T) {
+			got := replaySession(t, filepath.Join("testdata", name+".jsonl"))
+			compareGolden(t, filepath.Join("testdata", name+".golden.json"), got)
+		})
+	}
+}
+
+func TestRunExitBeforeShutdown(t *testing.T) {
+	var in bytes.Buffer
+	in.Write(frame([]byte(`{"jsonrpc":"2.0","method":"exit"}`)))
+
+	if err := run(&in, io.Discard, nil); !errors.Is(err, errExitBeforeShutdown) {
+		t.Errorf("expected errExitBeforeShutdown, got %v", err)
+	}
+}
+
+func TestRunVersion(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(strings.NewReader(""), &out, []string{"-version"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), serverName+" -- version ") {
+		t.Errorf("unexpected version output %q", out.String())
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	var out bytes.Buffer
+	h := newEpubHandler(&out)
+
+	id := lsp.NumberID(4)
+	started := make(chan struct{})
+	h.startRequest(&id, lsp.MethodHover, func(ctx context.Context) [][]byte {
+		close(started)
+		select {
+		case <-ctx.Done():
+			return [][]byte{[]byte(`{"cancelled":true}`)}
+		case <-time.After(sessionTimeout):
+			return [][]byte{[]byte(`{"cancelled":false}`)}
+		}
+	})
+	<-started
+
+	// Cancelling an unknown request is ignored, and the string "4" is not
+	// the same ID as the number 4
+	cancel := `{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":%s}}`
+	h.handleMessage(fmt.Appendf(nil, cancel, "9"))
+	h.handleMessage(fmt.Appendf(nil, cancel, `"4"`))
+	h.handleMessage(fmt.Appendf(nil, cancel, "4"))
+	h.requests.Wait()
+
+	if !strings.Contains(out.String(), `{"cancelled":true}`) {
+		t.Errorf("expected the request to see its context cancelled, got %q",
+			out.String())
+	}
+	h.muRequests.Lock()
+	defer h.muRequests.Unlock()
+	if len(h.inflight) != 0 {
+		t.Errorf("expected no requests in flight, got %v", h.inflight)
+	}
+}
+
+func TestMalformedMessageGetsErrorResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		code    int
+	}{
+		{"invalid JSON", `{"jsonrpc":"2.0","id":1,`, lsp.ErrorParseError},
+		{"boolean id", `{"jsonrpc":"2.0","id":true,"method":"hover"}`, lsp.ErrorInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			h := newEpubHandler(&out)
+			if h.handleMessage([]byte(tt.message)) {
+				t.Fatal("a malformed message should not stop the server")
+			}
+
+			_, body, ok := strings.Cut(out.String(), "\r\n\r\n")
+			if !ok {
+				t.Fatalf("expected a framed response, got %q", out.String())
+			}
+			var resp lsp.ResponseMessage[any]
+			if err := json.Unmarshal([]byte(body), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Error == nil || resp.Error.Code != tt.code || resp.Id != "" {
+				t.Errorf("expected error %d with a null id, got %s", tt.code, body)
+			}
+		})
+	}
+}
+
+// replaySession runs the server over a pipe, sending each recorded message
+// and waiting for the expected number of answers before the next, so the
+// transcript does not depend on goroutine scheduling. Each step's answers
+// are normalized and returned in order.
+func replaySession(t *testing.T, path string) []any {
+	t.Helper()
+	steps := readSteps(t, path)
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- run(inR, outW, nil)
+		_ = outW.Close()
+	}()
+
+	messages := make(chan []byte, 64)
+	go func() {
+		scanner := transport.NewScanner(outR)
+		for scanner.Scan() {
+			messages <- slices.Clone(scanner.Bytes())
+		}
+		close(messages)
+	}()
+
+	var transcript []any
+	for i, step := range steps {
+		if _, err := inW.Write(frame(step.Send)); err != nil {
+			t.Fatalf("step %d: write failed: %v", i, err)
+		}
+
+		var answers []any
+		for range step.Expect {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					t.Fatalf("step %d: server closed output early", i)
+				}
+				answers = append(answers, normalizeMessage(t, msg))
+			case <-time.After(sessionTimeout):
+				t.Fatalf("step %d: timed out waiting for answer", i)
+			}
+		}
+		sortNotifications(answers)
+		transcript = append(transcript, answers...)
+	}
+	_ = inW.Close()
+
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Fatalf("run returned %v", err)
+		}
+	case <-time.After(sessionTimeout):
+		t.Fatal("timed out waiting for run to return")
+	}
+
+	for msg := range messages {
+		t.Errorf("unexpected message after session: %s", msg)
+	}
+
+	return transcript
+}
+
+func readSteps(t *testing.T, path string) []sessionStep {
+	t.Helper()
+	data, err := os.ReadFile(path) //nolint:gosec // test fixture path
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var steps []sessionStep
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var step sessionStep
+		if err := json.Unmarshal(scanner.Bytes(), &step); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// frame encodes a message the way editors do, with a Content-Type header
+// after Content-Length.
+func frame(body []byte) []byte {
+	header := "Content-Length: " + strconv.Itoa(len(body)) + "\r\n" +
+		"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n"
+	return append([]byte(header), body...)
+}
+
+// normalizeMessage decodes msg and replaces fields that vary between
+// builds, such as the server version.
+func normalizeMessage(t *testing.T, msg []byte) any {
+	t.Helper()
+	var decoded map[string]any
+	if err := json.Unmarshal(msg, &decoded); err != nil {
+		t.Fatalf("invalid message %q: %v", msg, err)
+	}
+
+	if result, ok := decoded["result"].(map[string]any); ok {
+		if info, ok := result["serverInfo"].(map[string]any); ok {
+			info["version"] = "<version>"
+		}
+	}
+	return decoded
+}
+
+// sortNotifications orders diagnostics published within one step by URI,
+// since a validation pass publishes its files in map order.
+func sortNotifications(answers []any) {
+	uri := func(a any) string {
+		msg, _ := a.(map[string]any)
+		if msg["method"] != lsp.MethodPublishDiagnostics {
+			return ""
+		}
+		params, _ := msg["params"].(map[string]any)
+		s, _ := params["uri"].(string)
+		return s
+	}
+	slices.SortStableFunc(answers, func(a, b any) int {
+		return strings.Compare(uri(a), uri(b))
+	})
+}
+
+func compareGolden(t *testing.T, path string, transcript []any) {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(transcript); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.Bytes()
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path) //nolint:gosec // test fixture path
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("transcript differs from %s (run with -update to accept):\n%s",
+			path, got)
+	}
+}
@@ -0,0 +1,469 @@
+// Package epublint validates and formats EPUB sources from Go programs. It
+// runs the same validators as the epub-lsp language server.
+package epublint
+
+import (
+	"io/fs"
+	"maps"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/formatter"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+	"github.com/toba/epub-lsp/internal/epub/validator/accessibility"
+	"github.com/toba/epub-lsp/internal/epub/validator/container"
+	"github.com/toba/epub-lsp/internal/epub/validator/css"
+	"github.com/toba/epub-lsp/internal/epub/validator/nav"
+	"github.com/toba/epub-lsp/internal/epub/validator/opf"
+	"github.com/toba/epub-lsp/internal/epub/validator/resource"
+	"github.com/toba/epub-lsp/internal/epub/validator/whitespace"
+	"github.com/toba/epub-lsp/internal/epub/validator/xhtml"
+)
+
+type (
+	// Diagnostic is a validation issue found in a file.
+	Diagnostic = epub.Diagnostic
+	// Range is a span of a file in zero-based lines and UTF-16 characters.
+	Range = epub.Range
+	// Position is a zero-based line and UTF-16 character offset.
+	Position = epub.Position
+	// FixData describes the edit that resolves an auto-fixable diagnostic.
+	FixData = epub.FixData
+	// Manifest holds the parsed manifest, spine, and metadata of a package
+	// document.
+	Manifest = validator.ManifestInfo
+)
+
+// Diagnostic severities, matching the LSP values.
+const (
+	SeverityError   = epub.SeverityError
+	SeverityWarning = epub.SeverityWarning
+	SeverityInfo    = epub.SeverityInfo
+	SeverityHint    = epub.SeverityHint
+)
+
+// Validator names accepted by Options.Validators.
+const (
+	ValidatorOPF           = "opf"
+	ValidatorXHTML         = "xhtml"
+	ValidatorNav           = "nav"
+	ValidatorCSS           = "css"
+	ValidatorResource      = "resource"
+	ValidatorContainer     = "container"
+	ValidatorAccessibility = "accessibility"
+	ValidatorWhitespace    = "whitespace"
+)
+
+// validators maps each validator name to the validators it enables, in the
+// order they run.
+var validators = []struct {
+	name       string
+	validators []validator.Validator
+}{
+	{ValidatorOPF, []validator.Validator{&opf.Validator{}}},
+	{ValidatorXHTML, []validator.Validator{&xhtml.Validator{}}},
+	{ValidatorNav, []validator.Validator{&nav.Validator{}}},
+	{ValidatorCSS, []validator.Validator{&css.Validator{}}},
+	{ValidatorResource, []validator.Validator{
+		&resource.ManifestValidator{},
+		&resource.ContentValidator{},
+		&resource.InsecureValidator{},
+		&resource.URLSyntaxValidator{},
+		&resource.DuplicateValidator{},
+	}},
+	{ValidatorAccessibility, []validator.Validator{
+		&accessibility.MetadataValidator{},
+		&accessibility.PageValidator{},
+		&accessibility.OPFAccessibilityValidator{},
+		&accessibility.StructureValidator{},
+		&accessibility.SVGValidator{},
+	}},
+	{ValidatorContainer, []validator.Validator{
+		&container.ContainerValidator{},
+		&container.EncryptionValidator{},
+		&container.FontValidator{},
+		&container.LayoutValidator{},
+	}},
+	{ValidatorWhitespace, []validator.Validator{&whitespace.Validator{}}},
+}
+
+// TargetFileExtensions lists the extensions of the files that are validated.
+var TargetFileExtensions = []string{
+	"opf", "xhtml", "html", "css", "ncx",
+}
+
+// TargetFileNames lists container paths that are read regardless of
+// extension. The mimetype file is not validated itself, but its content
+// is checked from the package document.
+var TargetFileNames = []string{
+	"META-INF/container.xml", "META-INF/encryption.xml", "mimetype",
+}
+
+// IsTargetFile reports whether a URI or path has one of the target file
+// extensions or names one of the target container files.
+func IsTargetFile(uri string) bool {
+	lower := strings.ToLower(uri)
+	for _, ext := range TargetFileExtensions {
+		if strings.HasSuffix(lower, "."+ext) {
+			return true
+		}
+	}
+	for _, name := range TargetFileNames {
+		if epub.PathEndsWith(lower, strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configures validation. The zero value validates EPUB 3 with every
+// validator and reports accessibility issues as warnings.
+type Options struct {
+	// Accessibility is "warning" (the default), "error", or "ignore".
+	Accessibility string
+	// EPUBVersion forces EPUB 2 ("2.0") or EPUB 3 ("3.0") validation when
+	// no package document declares a version.
+	EPUBVersion string
+	// Validators lists the names of the validators to run. Nil runs all.
+	Validators []string
+	// RootPath is the directory on disk holding the book, for checks that
+	// look beyond the workspace files.
+	RootPath string
+	// LanguageIDs holds editor language IDs by URI, such as "epub-opf",
+	// which decide how files without a recognized extension are validated.
+	LanguageIDs map[string]string
+	// AltRedundantPhrases lists the phrases, such as "image of", reported
+	// at the start of image alt text. Nil uses the built-in list.
+	AltRedundantPhrases []string
+	// FakeHeadings reports paragraphs styled to look like headings. The
+	// check is a heuristic, so it is off by default.
+	FakeHeadings bool
+	// MultipleH1 reports content documents with more than one h1, which
+	// some style guides allow, so it is off by default.
+	MultipleH1 bool
+	// SkipWhitespace turns off the whitespace validator's hints about
+	// indentation, trailing whitespace, and final newlines, even when
+	// Validators names it.
+	SkipWhitespace bool
+	// DisabledCodes lists diagnostic codes, such as "SCP_001", that are
+	// not reported.
+	DisabledCodes []string
+	// ProseLineLength, when positive, reports lines in the paragraphs and
+	// other prose blocks of content documents longer than this many
+	// characters. The whitespace validator makes the check.
+	ProseLineLength int
+	// DuplicateResources reports manifest items with identical content,
+	// such as a cover image stored under several names, on the package
+	// document. Hashing every resource takes time, so it is off by
+	// default.
+	DuplicateResources bool
+	// Concurrency bounds how many files are validated at once. Zero or
+	// less uses GOMAXPROCS.
+	Concurrency int
+}
+
+// AccessibilitySeverity maps Options.Accessibility to a diagnostic severity,
+// with 0 meaning accessibility checks are skipped.
+func (o Options) AccessibilitySeverity() int {
+	switch o.Accessibility {
+	case "ignore":
+		return 0
+	case "error":
+		return SeverityError
+	default:
+		return SeverityWarning
+	}
+}
+
+// FileDiagnostics holds the diagnostics of one file.
+type FileDiagnostics struct {
+	URI         string
+	Diagnostics []Diagnostic
+}
+
+// Workspace is a set of EPUB source files validated together, so that
+// cross-file checks see the whole book.
+type Workspace struct {
+	files     map[string][]byte
+	fileTypes map[string]epub.FileType
+	manifest  *Manifest
+	registry  *validator.Registry
+	opts      Options
+}
+
+// NewWorkspace returns a workspace over files, keyed by URI or
+// slash-separated path. Files that are not validated, such as images, may
+// be included with empty content so that existence checks find them. A
+// leading byte order mark is dropped, since editors hide it and positions
+// count from after it.
+func NewWorkspace(files map[string][]byte, opts Options) *Workspace {
+	files = withoutBOMs(files)
+	w := &Workspace{
+		files:     files,
+		fileTypes: make(map[string]epub.FileType, len(files)),
+		registry:  newRegistry(opts.Validators, opts.SkipWhitespace),
+		opts:      opts,
+	}
+
+	uris := slices.Sorted(maps.Keys(files))
+	for _, uri := range uris {
+		w.fileTypes[uri] = epub.DetectFileTypeWithLanguage(uri, files[uri],
+			opts.LanguageIDs[uri])
+	}
+	for _, uri := range uris {
+		if w.fileTypes[uri] == epub.FileTypeOPF {
+			if m := opf.ParseManifest(files[uri]); m != nil {
+				m.URI = uri
+				w.manifest = m
+				break
+			}
+		}
+	}
+
+	return w
+}
+
+// withoutBOMs returns files with any leading byte order marks removed,
+// copying the map only when one has a mark.
+func withoutBOMs(files map[string][]byte) map[string][]byte {
+	cloned := false
+	for uri, content := range files {
+		stripped, ok := epub.StripBOM(content)
+		if !ok {
+			continue
+		}
+		if !cloned {
+			files = maps.Clone(files)
+			cloned = true
+		}
+		files[uri] = stripped
+	}
+	return files
+}
+
+// NewWorkspaceFromFS returns a workspace over every file in fsys, keyed by
+// its path. Only target files are read; other files are listed with empty
+// content. Hidden files and directories are skipped.
+func NewWorkspaceFromFS(fsys fs.FS, opts Options) (*Workspace, error) {
+	files := make(map[string][]byte)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != "." && strings.HasPrefix(path.Base(p), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !IsTargetFile(p) {
+			files[p] = []byte{}
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		files[p] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewWorkspace(files, opts), nil
+}
+
+// Manifest returns the manifest of the workspace's package document, or
+// nil when it has none.
+func (w *Workspace) Manifest() *Manifest {
+	return w.manifest
+}
+
+// FileType reports how a workspace file is validated.
+func (w *Workspace) FileType(uri string) epub.FileType {
+	return w.fileTypes[uri]
+}
+
+// Validate validates every file in the workspace and returns the results
+// in URI order. Files that are not validated are omitted; validated files
+// without issues have an empty Diagnostics slice.
+func (w *Workspace) Validate() []FileDiagnostics {
+	var uris []string
+	for uri, fileType := range w.fileTypes {
+		if fileType != epub.FileTypeUnknown {
+			uris = append(uris, uri)
+		}
+	}
+	return w.ValidateFiles(uris...)
+}
+
+// ValidateFiles validates the named workspace files against the whole
+// workspace and returns the results in URI order. Names not in the
+// workspace are ignored.
+func (w *Workspace) ValidateFiles(uris ...string) []FileDiagnostics {
+	ctx := &validator.WorkspaceContext{
+		RootPath:              w.opts.RootPath,
+		Files:                 w.files,
+		FileTypes:             w.fileTypes,
+		Manifest:              w.manifest,
+		AccessibilitySeverity: w.opts.AccessibilitySeverity(),
+		Version:               w.opts.EPUBVersion,
+		AltRedundantPhrases:   w.opts.AltRedundantPhrases,
+		FakeHeadings:          w.opts.FakeHeadings,
+		MultipleH1:            w.opts.MultipleH1,
+		DisabledCodes:         disabledCodes(w.opts.DisabledCodes),
+		ProseLineLength:       w.opts.ProseLineLength,
+		DuplicateResources:    w.opts.DuplicateResources,
+	}
+
+	var results []FileDiagnostics
+	for _, uri := range uris {
+		if _, ok := w.files[uri]; ok {
+			results = append(results, FileDiagnostics{URI: uri})
+		}
+	}
+	slices.SortFunc(results, func(a, b FileDiagnostics) int {
+		return strings.Compare(a.URI, b.URI)
+	})
+	results = slices.CompactFunc(results, func(a, b FileDiagnostics) bool {
+		return a.URI == b.URI
+	})
+
+	work := make(chan int, len(results))
+	for _, i := range w.workOrder(results) {
+		work <- i
+	}
+	close(work)
+
+	var wg sync.WaitGroup
+	for range min(w.concurrency(), len(results)) {
+		wg.Go(func() {
+			for i := range work {
+				uri := results[i].URI
+				diags := w.registry.ValidateFile(uri, w.files[uri], w.fileTypes[uri], ctx)
+				if diags == nil {
+					diags = []Diagnostic{}
+				}
+				results[i].Diagnostics = diags
+			}
+		})
+	}
+	wg.Wait()
+
+	return results
+}
+
+// concurrency returns how many files ValidateFiles validates at once.
+func (w *Workspace) concurrency() int {
+	if w.opts.Concurrency > 0 {
+		return w.opts.Concurrency
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// workOrder returns the indexes of results in the order to validate them:
+// package documents first, since they are the largest cross-file checks
+// and the manifest they declare matters most to the rest, then the other
+// files in URI order.
+func (w *Workspace) workOrder(results []FileDiagnostics) []int {
+	order := make([]int, 0, len(results))
+	for i, r := range results {
+		if w.fileTypes[r.URI] == epub.FileTypeOPF {
+			order = append(order, i)
+		}
+	}
+	for i, r := range results {
+		if w.fileTypes[r.URI] != epub.FileTypeOPF {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// ErrContentChanged is returned by Format and FormatWrapped when the
+// formatted document would differ from the original in more than
+// whitespace and attribute order.
+var ErrContentChanged = formatter.ErrContentChanged
+
+// Format formats an OPF, XHTML, or CSS file, choosing the formatter from
+// its path and content. Other files are returned unchanged.
+func Format(path string, content []byte, indent string) (string, error) {
+	return FormatWrapped(path, content, indent, ProseWrapOff)
+}
+
+// Prose wrap modes for FormatWrapped.
+const (
+	// ProseWrapOff puts the text and inline elements of prose blocks on
+	// lines of their own, as Format does.
+	ProseWrapOff = string(formatter.ProseWrapOff)
+	// ProseWrapPreserve keeps the line breaks in prose blocks.
+	ProseWrapPreserve = string(formatter.ProseWrapPreserve)
+	// ProseWrapSentence starts each sentence of a prose block on a new
+	// line.
+	ProseWrapSentence = string(formatter.ProseWrapSentence)
+)
+
+// FormatWrapped formats like Format, laying out the text of paragraphs,
+// list items, block quotes, definitions, and figure captions in content
+// documents as proseWrap says. An empty or unknown proseWrap is
+// ProseWrapOff. XML output whose elements, attributes, or text differ
+// from the original's is refused with an error wrapping ErrContentChanged.
+func FormatWrapped(path string, content []byte, indent, proseWrap string) (string, error) {
+	var formatted string
+	var err error
+	switch epub.DetectFileType(path, content) {
+	case epub.FileTypeOPF:
+		formatted, err = formatter.FormatXML(content, indent)
+	case epub.FileTypeXHTML, epub.FileTypeNav:
+		formatted, err = formatter.FormatXMLWrapped(
+			content, indent, formatter.ProseWrap(proseWrap))
+	case epub.FileTypeCSS:
+		return formatter.FormatCSS(content, indent)
+	default:
+		return string(content), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	// A formatter bug must not silently change a book's content
+	if err := formatter.CheckRoundTrip(content, formatted); err != nil {
+		return "", err
+	}
+	return formatted, nil
+}
+
+// newRegistry returns a registry with the named validators, or all of them
+// when names is nil, leaving out the whitespace validator when
+// skipWhitespace is set.
+func newRegistry(names []string, skipWhitespace bool) *validator.Registry {
+	registry := validator.NewRegistry()
+	for _, group := range validators {
+		if names != nil && !slices.Contains(names, group.name) ||
+			skipWhitespace && group.name == ValidatorWhitespace {
+			continue
+		}
+		for _, v := range group.validators {
+			registry.Register(v)
+		}
+	}
+	return registry
+}
+
+// disabledCodes returns codes as a set, or nil when there are none.
+func disabledCodes(codes []string) map[string]bool {
+	if len(codes) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
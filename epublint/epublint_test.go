@@ -0,0 +1,224 @@
+package epublint
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/metrics"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var missingAlt = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Chapter</title></head>
+<body><img src="a.png"/><table><tr><td>1</td></tr></table></body>
+</html>`)
+
+func codes(results []FileDiagnostics) map[string]bool {
+	found := make(map[string]bool)
+	for _, r := range results {
+		for _, d := range r.Diagnostics {
+			found[d.Code] = true
+		}
+	}
+	return found
+}
+
+func TestNewWorkspaceFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"OEBPS/chapter.xhtml": {Data: missingAlt},
+		"OEBPS/a.png":         {Data: []byte{0x89, 'P', 'N', 'G'}},
+		".git/config":         {Data: []byte("[core]")},
+	}
+
+	ws, err := NewWorkspaceFromFS(fsys, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := ws.files[".git/config"]; ok {
+		t.Error("expected hidden directories to be skipped")
+	}
+	if content, ok := ws.files["OEBPS/a.png"]; !ok || len(content) != 0 {
+		t.Error("expected non-target files to be listed without content")
+	}
+
+	results := ws.Validate()
+	if len(results) != 1 || results[0].URI != "OEBPS/chapter.xhtml" {
+		t.Fatalf("expected results for the chapter only, got %+v", results)
+	}
+	if !codes(results)["HTM_008"] {
+		t.Error("expected HTM_008")
+	}
+}
+
+func TestOptionsValidators(t *testing.T) {
+	files := map[string][]byte{"chapter.xhtml": missingAlt}
+
+	all := codes(NewWorkspace(files, Options{}).Validate())
+	if !all["HTM_008"] || !all["table-caption"] {
+		t.Fatalf("expected xhtml and accessibility codes, got %v", all)
+	}
+
+	only := codes(NewWorkspace(files, Options{
+		Validators: []string{ValidatorAccessibility},
+	}).Validate())
+	if only["HTM_008"] || !only["table-caption"] {
+		t.Errorf("expected accessibility codes only, got %v", only)
+	}
+
+	ignored := codes(NewWorkspace(files, Options{Accessibility: "ignore"}).Validate())
+	if ignored["table-caption"] {
+		t.Error("expected accessibility checks to be skipped")
+	}
+}
+
+func TestOptionsSkipWhitespace(t *testing.T) {
+	files := map[string][]byte{"style.css": []byte("p {\n  margin: 0; \n}")}
+
+	if all := codes(NewWorkspace(files, Options{}).Validate()); !all["WS_002"] || !all["WS_003"] {
+		t.Fatalf("expected whitespace codes, got %v", all)
+	}
+	skipped := codes(NewWorkspace(files, Options{SkipWhitespace: true}).Validate())
+	if skipped["WS_002"] || skipped["WS_003"] {
+		t.Errorf("expected whitespace checks to be skipped, got %v", skipped)
+	}
+}
+
+func TestOptionsDisabledCodes(t *testing.T) {
+	files := map[string][]byte{"chapter.xhtml": []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Quiz</title><script src="quiz.js"></script></head>
+<body><div onclick="check()">Check</div></body>
+</html>
+`)}
+
+	all := codes(NewWorkspace(files, Options{}).Validate())
+	if !all["SCP_001"] || !all["SCP_009"] || !all["SCP_010"] {
+		t.Fatalf("expected scripting codes, got %v", all)
+	}
+	disabled := codes(NewWorkspace(files, Options{DisabledCodes: []string{"SCP_001", "SCP_010"}}).Validate())
+	if disabled["SCP_001"] || disabled["SCP_010"] || !disabled["SCP_009"] {
+		t.Errorf("expected only SCP_001 and SCP_010 to be disabled, got %v", disabled)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	got, err := Format("style.css", []byte("p{margin:0}"), "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == "p{margin:0}" {
+		t.Error("expected CSS to be formatted")
+	}
+
+	raw := []byte("not an epub source")
+	if got, _ := Format("notes.txt", raw, "  "); got != string(raw) {
+		t.Errorf("expected unsupported files unchanged, got %q", got)
+	}
+}
+
+// syntheticBook returns a package document listing n chapters, each with
+// an image missing its alt text, and the chapters themselves.
+func syntheticBook(n int) map[string][]byte {
+	files := make(map[string][]byte, n+1)
+	var items, itemrefs strings.Builder
+	for i := range n {
+		name := fmt.Sprintf("ch%03d.xhtml", i)
+		fmt.Fprintf(&items, `<item id="c%d" href="%s" media-type="application/xhtml+xml"/>`, i, name)
+		fmt.Fprintf(&itemrefs, `<itemref idref="c%d"/>`, i)
+		files["OEBPS/"+name] = missingAlt
+	}
+	files["OEBPS/content.opf"] = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="3.0">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="uid">urn:uuid:1</dc:identifier><dc:title>T</dc:title><dc:language>en</dc:language>
+</metadata>
+<manifest>` + items.String() + `</manifest>
+<spine>` + itemrefs.String() + `</spine>
+</package>`)
+	return files
+}
+
+func TestValidateConcurrency(t *testing.T) {
+	files := syntheticBook(40)
+
+	// One worker per file validates as the unbounded version did
+	want := NewWorkspace(files, Options{Concurrency: len(files)}).Validate()
+	if len(want) != len(files) || !codes(want)["HTM_008"] {
+		t.Fatalf("expected results for every file, got %d", len(want))
+	}
+	for _, concurrency := range []int{0, 1, 3} {
+		got := NewWorkspace(files, Options{Concurrency: concurrency}).Validate()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("concurrency %d: results differ from one worker per file", concurrency)
+		}
+	}
+}
+
+func TestWorkOrder(t *testing.T) {
+	w := NewWorkspace(map[string][]byte{
+		"a.css":       []byte("p {}"),
+		"b.opf":       syntheticBook(0)["OEBPS/content.opf"],
+		"c.xhtml":     missingAlt,
+		"d/other.opf": syntheticBook(0)["OEBPS/content.opf"],
+	}, Options{})
+	results := []FileDiagnostics{{URI: "a.css"}, {URI: "b.opf"}, {URI: "c.xhtml"}, {URI: "d/other.opf"}}
+	if got := w.workOrder(results); !slices.Equal(got, []int{1, 3, 0, 2}) {
+		t.Errorf("work order = %v, want package documents first", got)
+	}
+}
+
+// BenchmarkValidate validates a 500-file book with bounded workers and
+// with one goroutine per file, reporting the peak live heap of each.
+func BenchmarkValidate(b *testing.B) {
+	files := syntheticBook(499)
+	for _, bench := range []struct {
+		name        string
+		concurrency int
+	}{
+		{"gomaxprocs", 0},
+		{"per-file", len(files)},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			w := NewWorkspace(files, Options{Concurrency: bench.concurrency})
+			b.ReportAllocs()
+			peak := samplePeakHeap(b)
+			for b.Loop() {
+				w.Validate()
+			}
+			b.ReportMetric(float64(peak())/(1<<20), "peak-heap-MiB")
+		})
+	}
+}
+
+// samplePeakHeap samples the live heap until the returned function is
+// called, which reports the largest size seen.
+func samplePeakHeap(b *testing.B) func() uint64 {
+	b.Helper()
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	done := make(chan struct{})
+	result := make(chan uint64)
+	go func() {
+		var peak uint64
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			metrics.Read(sample)
+			peak = max(peak, sample[0].Value.Uint64())
+			select {
+			case <-done:
+				result <- peak
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() uint64 {
+		close(done)
+		return <-result
+	}
+}
@@ -0,0 +1,45 @@
+package epublint_test
+
+import (
+	"fmt"
+
+	"github.com/toba/epub-lsp/epublint"
+)
+
+func ExampleWorkspace_Validate() {
+	ws := epublint.NewWorkspace(map[string][]byte{
+		"OEBPS/content.opf": []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:1</dc:identifier>
+    <dc:title>Example</dc:title>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest>
+    <item id="ch1" href="chapter.xhtml" media-type="application/xhtml+xml"/>
+    <item id="cover" href="cover.jpg" media-type="image/jpeg"/>
+  </manifest>
+  <spine>
+    <itemref idref="ch1"/>
+  </spine>
+</package>
+`),
+		"OEBPS/chapter.xhtml": []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Chapter</title></head>
+<body><img src="cover.jpg"/></body>
+</html>
+`),
+		// Images are only checked for existence, so empty content will do.
+		"OEBPS/cover.jpg": {},
+	}, epublint.Options{Accessibility: "ignore"})
+
+	for _, file := range ws.Validate() {
+		for _, d := range file.Diagnostics {
+			fmt.Printf("%s:%d: %s %s\n",
+				file.URI, d.Range.Start.Line+1, d.Code, d.Message)
+		}
+	}
+	// Output:
+	// OEBPS/chapter.xhtml:4: HTM_008 <img> element missing alt attribute
+}
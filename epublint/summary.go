@@ -0,0 +1,140 @@
+package epublint
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// SummaryLimit is the number of messages a Summary lists.
+const SummaryLimit = 10
+
+// Summary condenses the diagnostics of a set of files, as for a
+// pre-commit check of the files in a change.
+type Summary struct {
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+	Infos    int `json:"infos"`
+	Hints    int `json:"hints"`
+	// Blocking is set when any diagnostic is an error.
+	Blocking bool `json:"blocking"`
+	// Messages lists up to SummaryLimit diagnostics, most severe first,
+	// then by file and line.
+	Messages []SummaryMessage `json:"messages"`
+}
+
+// SummaryMessage is one diagnostic listed in a Summary.
+type SummaryMessage struct {
+	File string `json:"file"`
+	// Line is one-based.
+	Line     int    `json:"line"`
+	Severity int    `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Summarize counts the diagnostics of files by severity and lists the most
+// severe. Messages name files by their URI field, so callers wanting
+// shorter names should set it to a relative path first.
+func Summarize(files []FileDiagnostics) Summary {
+	s := Summary{Messages: []SummaryMessage{}}
+	var all []SummaryMessage
+	for _, file := range files {
+		for _, d := range file.Diagnostics {
+			switch d.Severity {
+			case SeverityError:
+				s.Errors++
+			case SeverityWarning:
+				s.Warnings++
+			case SeverityInfo:
+				s.Infos++
+			default:
+				s.Hints++
+			}
+			all = append(all, SummaryMessage{
+				File:     file.URI,
+				Line:     d.Range.Start.Line + 1,
+				Severity: d.Severity,
+				Code:     d.Code,
+				Message:  d.Message,
+			})
+		}
+	}
+	s.Blocking = s.Errors > 0
+
+	slices.SortStableFunc(all, func(a, b SummaryMessage) int {
+		return cmp.Or(
+			cmp.Compare(a.Severity, b.Severity),
+			strings.Compare(a.File, b.File),
+			cmp.Compare(a.Line, b.Line),
+		)
+	})
+	s.Messages = append(s.Messages, all[:min(len(all), SummaryLimit)]...)
+	return s
+}
+
+// Total returns the number of diagnostics summarized.
+func (s Summary) Total() int {
+	return s.Errors + s.Warnings + s.Infos + s.Hints
+}
+
+// String renders the summary as text: a line of counts, then one line per
+// listed message in file:line form, and a count of those left out.
+//
+//	1 error, 2 warnings
+//	OEBPS/ch1.xhtml:4: error HTM_008 <img> element missing alt attribute
+func (s Summary) String() string {
+	if s.Total() == 0 {
+		return "no problems\n"
+	}
+
+	var counts []string
+	for _, c := range []struct {
+		n              int
+		single, plural string
+	}{
+		{s.Errors, "error", "errors"},
+		{s.Warnings, "warning", "warnings"},
+		{s.Infos, "info", "info"},
+		{s.Hints, "hint", "hints"},
+	} {
+		switch {
+		case c.n == 1:
+			counts = append(counts, "1 "+c.single)
+		case c.n > 1:
+			counts = append(counts, fmt.Sprintf("%d %s", c.n, c.plural))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(counts, ", "))
+	b.WriteByte('\n')
+	for _, m := range s.Messages {
+		fmt.Fprintf(&b, "%s:%d: %s", m.File, m.Line, severityName(m.Severity))
+		if m.Code != "" {
+			b.WriteString(" " + m.Code)
+		}
+		b.WriteString(" " + m.Message + "\n")
+	}
+	if more := s.Total() - len(s.Messages); more > 0 {
+		fmt.Fprintf(&b, "and %d more\n", more)
+	}
+	return b.String()
+}
+
+// severityName returns the lowercase name of a diagnostic severity.
+func severityName(severity int) string {
+	switch severity {
+	case epub.SeverityError:
+		return "error"
+	case epub.SeverityWarning:
+		return "warning"
+	case epub.SeverityInfo:
+		return "info"
+	default:
+		return "hint"
+	}
+}
@@ -0,0 +1,59 @@
+package epublint
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	var warnings []Diagnostic
+	for i := range 12 {
+		warnings = append(warnings, Diagnostic{
+			Code:     "WS_002",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("warning %d", i),
+			Range:    Range{Start: Position{Line: 20 - i}},
+		})
+	}
+	s := Summarize([]FileDiagnostics{
+		{URI: "b.xhtml", Diagnostics: warnings},
+		{URI: "a.xhtml", Diagnostics: []Diagnostic{
+			{Severity: SeverityInfo, Message: "note"},
+			{Code: "HTM_008", Severity: SeverityError, Message: "missing alt",
+				Range: Range{Start: Position{Line: 3}}},
+		}},
+	})
+
+	if s.Errors != 1 || s.Warnings != 12 || s.Infos != 1 || s.Hints != 0 || !s.Blocking {
+		t.Errorf("counts = %+v", s)
+	}
+	if len(s.Messages) != SummaryLimit {
+		t.Fatalf("listed %d messages, want %d", len(s.Messages), SummaryLimit)
+	}
+	if first := s.Messages[0]; first.File != "a.xhtml" || first.Line != 4 || first.Code != "HTM_008" {
+		t.Errorf("first message = %+v, want the error", first)
+	}
+	if second := s.Messages[1]; second.Line != 10 {
+		t.Errorf("second message = %+v, want the warning on line 10", second)
+	}
+
+	text := s.String()
+	wantStart := "1 error, 12 warnings, 1 info\na.xhtml:4: error HTM_008 missing alt\nb.xhtml:10: warning WS_002 warning 11\n"
+	if !strings.HasPrefix(text, wantStart) {
+		t.Errorf("text starts %q, want %q", text, wantStart)
+	}
+	if !strings.HasSuffix(text, "\nand 4 more\n") {
+		t.Errorf("text should end with the count left out:\n%s", text)
+	}
+}
+
+func TestSummarize_Clean(t *testing.T) {
+	s := Summarize([]FileDiagnostics{{URI: "a.xhtml"}})
+	if s.Blocking || s.Total() != 0 || s.Messages == nil {
+		t.Errorf("summary = %+v, want empty, non-blocking, with an empty message list", s)
+	}
+	if got := s.String(); got != "no problems\n" {
+		t.Errorf("text = %q", got)
+	}
+}
@@ -2,19 +2,4 @@ module github.com/toba/epub-lsp
 
 go 1.26.1
 
-require (
-	github.com/toba/lsp v0.2.1
-	go.lsp.dev/protocol v0.12.0
-)
-
-require (
-	github.com/segmentio/asm v1.1.3 // indirect
-	github.com/segmentio/encoding v0.3.4 // indirect
-	go.lsp.dev/jsonrpc2 v0.10.0 // indirect
-	go.lsp.dev/pkg v0.0.0-20210717090340-384b27a52fb2 // indirect
-	go.lsp.dev/uri v0.3.0 // indirect
-	go.uber.org/atomic v1.9.0 // indirect
-	go.uber.org/multierr v1.8.0 // indirect
-	go.uber.org/zap v1.21.0 // indirect
-	golang.org/x/sys v0.0.0-20220319134239-a9b59b0215f8 // indirect
-)
+require github.com/toba/lsp v0.2.1
@@ -0,0 +1,208 @@
+// Package editorconfig reads the indentation and final newline properties
+// of an .editorconfig file, for formatting files that neither the client
+// nor the server settings say how to lay out.
+package editorconfig
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name of an EditorConfig file.
+const FileName = ".editorconfig"
+
+// Properties holds the properties a file gets from the sections matching
+// it, lowercased, with "" for those no section sets.
+type Properties struct {
+	// IndentStyle is "tab" or "space".
+	IndentStyle string
+	// IndentSize is a number of columns, or "tab" to use TabWidth.
+	IndentSize string
+	// TabWidth is the number of columns a tab shows as.
+	TabWidth string
+	// InsertFinalNewline is "true" or "false".
+	InsertFinalNewline string
+}
+
+// Indent returns the string indenting one level: a tab for IndentStyle
+// "tab", and otherwise IndentSize spaces, or TabWidth spaces when
+// IndentSize is "tab". It reports false when the properties set neither
+// style nor size.
+func (p Properties) Indent() (string, bool) {
+	if p.IndentStyle == "tab" {
+		return "\t", true
+	}
+	size := p.IndentSize
+	if size == "tab" {
+		size = p.TabWidth
+	}
+	n, err := strconv.Atoi(size)
+	switch {
+	case err == nil && n > 0:
+		return strings.Repeat(" ", min(n, 8)), true
+	case p.IndentStyle == "space":
+		return "  ", true
+	}
+	return "", false
+}
+
+// FinalNewline returns whether a file should end with a newline, and
+// false for ok when InsertFinalNewline is not set.
+func (p Properties) FinalNewline() (value, ok bool) {
+	switch p.InsertFinalNewline {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
+
+// section is a glob and the properties it sets.
+type section struct {
+	pattern *regexp.Regexp
+	props   map[string]string
+}
+
+// File is a parsed .editorconfig file.
+type File struct {
+	sections []section
+}
+
+// Load parses the .editorconfig file in dir. It returns nil, and no error,
+// when there is none.
+func Load(dir string) (*File, error) {
+	f, err := os.Open(filepath.Join(dir, FileName)) //nolint:gosec // the workspace root
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads an .editorconfig file. Properties before the first section,
+// such as root, are ignored, as are sections whose glob cannot be used.
+func Parse(r io.Reader) (*File, error) {
+	file := &File{}
+	var current *section
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if glob, ok := strings.CutPrefix(line, "["); ok {
+			glob, ok = strings.CutSuffix(glob, "]")
+			current = nil
+			if pattern, err := compileGlob(glob); ok && err == nil {
+				file.sections = append(file.sections, section{
+					pattern: pattern,
+					props:   make(map[string]string),
+				})
+				current = &file.sections[len(file.sections)-1]
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			continue
+		}
+		current.props[strings.ToLower(strings.TrimSpace(key))] =
+			strings.ToLower(strings.TrimSpace(value))
+	}
+	return file, scanner.Err()
+}
+
+// Properties returns the properties of the file at rel, a slash-separated
+// path relative to the .editorconfig file's directory. Later sections take
+// precedence over earlier ones.
+func (f *File) Properties(rel string) Properties {
+	var p Properties
+	if f == nil {
+		return p
+	}
+	rel = strings.TrimPrefix(rel, "/")
+	for _, s := range f.sections {
+		if !s.pattern.MatchString(rel) {
+			continue
+		}
+		set := func(field *string, key string) {
+			if value, ok := s.props[key]; ok {
+				*field = value
+			}
+		}
+		set(&p.IndentStyle, "indent_style")
+		set(&p.IndentSize, "indent_size")
+		set(&p.TabWidth, "tab_width")
+		set(&p.InsertFinalNewline, "insert_final_newline")
+	}
+	return p
+}
+
+// compileGlob converts an EditorConfig glob to a regular expression
+// matching slash-separated paths. A glob without a slash matches file
+// names in any directory; one with a slash is anchored at the
+// .editorconfig file's directory. It supports *, **, ?, [...], [!...],
+// and {a,b}.
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	if !strings.Contains(glob, "/") {
+		b.WriteString("(?:.*/)?")
+	}
+	glob = strings.TrimPrefix(glob, "/")
+
+	braces := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			i++
+			b.WriteString(".*")
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if negated, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + negated
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '{':
+			braces++
+			b.WriteString("(?:")
+		case c == '}' && braces > 0:
+			braces--
+			b.WriteString(")")
+		case c == ',' && braces > 0:
+			b.WriteString("|")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	if braces > 0 {
+		return nil, errors.New("unclosed brace in " + glob)
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,136 @@
+package editorconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fixture = `# Top-level file
+root = true
+
+[*]
+indent_style = space
+indent_size = 4
+insert_final_newline = true
+
+[*.{opf,xhtml}]
+indent_size = 2
+
+[OEBPS/styles/**.css]
+indent_style = TAB
+
+; Vendored files keep their own layout
+[vendor/*.css]
+indent_style = space
+indent_size = tab
+tab_width = 3
+insert_final_newline = false
+`
+
+func TestProperties(t *testing.T) {
+	file, err := Parse(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path         string
+		indent       string
+		finalNewline bool
+	}{
+		{"OEBPS/content.opf", "  ", true},
+		{"OEBPS/text/ch1.xhtml", "  ", true},
+		{"OEBPS/toc.ncx", "    ", true},
+		{"OEBPS/styles/book.css", "\t", true},
+		{"OEBPS/styles/fonts/fonts.css", "\t", true},
+		{"styles/book.css", "    ", true},
+		{"vendor/reset.css", "   ", false},
+		{"OEBPS/vendor/reset.css", "    ", true},
+	}
+	for _, tc := range cases {
+		props := file.Properties(tc.path)
+		indent, ok := props.Indent()
+		if !ok || indent != tc.indent {
+			t.Errorf("%s: indent = %q, %v, want %q", tc.path, indent, ok, tc.indent)
+		}
+		if value, ok := props.FinalNewline(); !ok || value != tc.finalNewline {
+			t.Errorf("%s: final newline = %v, %v, want %v",
+				tc.path, value, ok, tc.finalNewline)
+		}
+	}
+}
+
+func TestPropertiesUnset(t *testing.T) {
+	file, err := Parse(strings.NewReader("indent_style = tab\n[*.md]\nindent_size = 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := file.Properties("content.opf")
+	if _, ok := props.Indent(); ok {
+		t.Error("properties before the first section should be ignored")
+	}
+	if _, ok := props.FinalNewline(); ok {
+		t.Error("expected no final newline property")
+	}
+	if _, ok := (*File)(nil).Properties("content.opf").Indent(); ok {
+		t.Error("expected no indent from a missing file")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if file, err := Load(dir); file != nil || err != nil {
+		t.Fatalf("Load without a file = %v, %v, want nil, nil", file, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, FileName),
+		[]byte("[*.css]\nindent_style = tab\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indent, _ := file.Properties("a/b.css").Indent(); indent != "\t" {
+		t.Errorf("indent = %q, want a tab", indent)
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	cases := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*.css", "book.css", true},
+		{"*.css", "styles/book.css", true},
+		{"*.css", "book.scss", false},
+		{"/styles/*.css", "styles/book.css", true},
+		{"styles/*.css", "styles/fonts/a.css", false},
+		{"styles/**.css", "styles/fonts/a.css", true},
+		{"ch?.xhtml", "ch1.xhtml", true},
+		{"ch?.xhtml", "ch10.xhtml", false},
+		{"ch[0-9].xhtml", "ch7.xhtml", true},
+		{"ch[!0-9].xhtml", "ch7.xhtml", false},
+		{"*.{opf,ncx}", "toc.ncx", true},
+		{"*.{opf,ncx}", "toc.xhtml", false},
+		{`a\*.css`, "a*.css", true},
+		{`a\*.css`, "ab.css", false},
+	}
+	for _, tc := range cases {
+		pattern, err := compileGlob(tc.glob)
+		if err != nil {
+			t.Errorf("%s: %v", tc.glob, err)
+			continue
+		}
+		if got := pattern.MatchString(tc.path); got != tc.match {
+			t.Errorf("%s matching %s = %v, want %v", tc.glob, tc.path, got, tc.match)
+		}
+	}
+
+	if _, err := compileGlob("*.{opf,ncx"); err == nil {
+		t.Error("expected an error for an unclosed brace")
+	}
+}
@@ -0,0 +1,24 @@
+package epub
+
+import "bytes"
+
+// BOM is the UTF-8 encoding of the byte order mark U+FEFF.
+const BOM = "\xef\xbb\xbf"
+
+// StripBOM returns content without a leading byte order mark and reports
+// whether it had one.
+func StripBOM(content []byte) ([]byte, bool) {
+	if rest, ok := bytes.CutPrefix(content, []byte(BOM)); ok {
+		return rest, true
+	}
+	return content, false
+}
+
+// bomLen returns the length of the byte order mark content starts with, or
+// zero.
+func bomLen(content []byte) int {
+	if bytes.HasPrefix(content, []byte(BOM)) {
+		return len(BOM)
+	}
+	return 0
+}
@@ -7,7 +7,16 @@ type DiagBuilder struct {
 
 // NewDiag creates a DiagBuilder with position computed from content and offset.
 func NewDiag(content []byte, offset int, source string) *DiagBuilder {
-	pos := ByteOffsetToPosition(content, offset)
+	return newDiag(ByteOffsetToPosition(content, offset), source)
+}
+
+// NewDiagAt creates a DiagBuilder with position computed from a line index,
+// for validators that report many diagnostics against the same content.
+func NewDiagAt(lines *LineIndex, offset int, source string) *DiagBuilder {
+	return newDiag(lines.Position(offset), source)
+}
+
+func newDiag(pos Position, source string) *DiagBuilder {
 	return &DiagBuilder{
 		diag: Diagnostic{
 			Source: source,
@@ -16,6 +25,12 @@ func NewDiag(content []byte, offset int, source string) *DiagBuilder {
 	}
 }
 
+// End extends the diagnostic range to end at pos.
+func (b *DiagBuilder) End(pos Position) *DiagBuilder {
+	b.diag.Range.End = pos
+	return b
+}
+
 // Code sets the diagnostic code.
 func (b *DiagBuilder) Code(code string) *DiagBuilder {
 	b.diag.Code = code
@@ -43,6 +58,19 @@ func (b *DiagBuilder) Info(msg string) *DiagBuilder {
 	return b
 }
 
+// Hint sets the message and severity to Hint.
+func (b *DiagBuilder) Hint(msg string) *DiagBuilder {
+	b.diag.Message = msg
+	b.diag.Severity = SeverityHint
+	return b
+}
+
+// Fix attaches the structured fix for an auto-fixable diagnostic.
+func (b *DiagBuilder) Fix(title, anchor, insertText string) *DiagBuilder {
+	b.diag.Fix = &FixData{Title: title, Anchor: anchor, InsertText: insertText}
+	return b
+}
+
 // Build returns the constructed Diagnostic.
 func (b *DiagBuilder) Build() Diagnostic {
 	return b.diag
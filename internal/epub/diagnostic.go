@@ -16,4 +16,9 @@ type Diagnostic struct {
 	Message  string `json:"message"`
 	Range    Range  `json:"range"`
 	Source   string `json:"source"`
+	// Fix is the structured fix for auto-fixable diagnostics, or nil.
+	Fix *FixData `json:"data,omitempty"`
+	// Explanation is markdown describing why the rule matters, shown when
+	// hovering over the diagnostic.
+	Explanation string `json:"-"`
 }
@@ -0,0 +1,27 @@
+package epub
+
+import "testing"
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "toc", 3},
+		{"chapter", "chapter", 0},
+		{"chaper", "chapter", 1},
+		{"front-matter", "frontmatter", 1},
+		{"kitten", "sitting", 3},
+		{"forword", "foreword", 1},
+		{"épilogue", "epilogue", 1},
+	}
+	for _, tt := range tests {
+		if got := Levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,914 @@
+package epub
+
+import (
+	"maps"
+	"slices"
+)
+
+const (
+	epub33Spec   = "https://www.w3.org/TR/epub-33/"
+	epub2OPFSpec = "https://idpf.org/epub/20/spec/OPF_2.0.1_draft.htm"
+	daisyKB      = "https://kb.daisy.org/publishing/docs/"
+	wcagDocs     = "https://www.w3.org/WAI/WCAG21/Understanding/"
+)
+
+// codeDoc documents a diagnostic code.
+type codeDoc struct {
+	// URL links to the rule in the relevant specification or knowledge base.
+	URL string
+	// Explanation describes in markdown why the rule matters.
+	Explanation string
+}
+
+// codeDocs maps every diagnostic code the validators emit to its
+// documentation.
+var codeDocs = map[string]codeDoc{
+	// Package document
+	"OPF_001": {
+		epub33Spec + "#sec-container-metainf-container.xml",
+		"Each `rootfile` in `container.xml` must point at an OPF package " +
+			"document; reading systems open the publication through it.",
+	},
+	"OPF_003": {
+		epub33Spec + "#sec-itemref-elem",
+		"The spine defines the reading order by referencing manifest items. " +
+			"An `idref` that matches no item leaves a hole in the reading order.",
+	},
+	"OPF_010": {
+		epub33Spec + "#sec-package-elem",
+		"The `package` element holds `metadata`, `manifest`, and `spine` " +
+			"in that order, followed by the optional `guide`, `bindings`, " +
+			"and `collection` elements. Strict reading systems reject " +
+			"packages whose sections are out of order.",
+	},
+	"OPF_011": {
+		epub33Spec + "#sec-package-elem",
+		"Only the package sections may appear directly inside `package`. " +
+			"Any other element is invalid and is ignored by reading systems.",
+	},
+	"OPF_012": {
+		epub33Spec + "#sec-metadata-elem",
+		"The `metadata` element holds Dublin Core elements, `meta`, and " +
+			"`link`. Other elements carry no defined meaning there.",
+	},
+	"OPF_013": {
+		epub33Spec + "#sec-link-elem",
+		"A metadata `link` needs `href` for the resource it points at and " +
+			"`rel` for how that resource relates to the publication.",
+	},
+	"OPF_014": {
+		epub33Spec + "#sec-link-elem",
+		"A metadata `link` to a local file, such as an ONIX record, points " +
+			"at a resource in the container, so the file must exist.",
+	},
+	"OPF_015": {
+		epub33Spec + "#sec-record",
+		"A `record` link must give the record's format in `media-type` so " +
+			"reading systems know how to read it.",
+	},
+	"OPF_016": {
+		epub33Spec + "#sec-container-metainf-container.xml",
+		"Without `full-path` a `rootfile` does not locate any package " +
+			"document, so reading systems cannot open the publication.",
+	},
+	"OPF_017": {
+		epub33Spec + "#sec-link-rel",
+		"Unprefixed `rel` values on a metadata `link` must come from the " +
+			"EPUB link relationship vocabulary, so a typo such as `recrod` " +
+			"leaves the link without meaning.",
+	},
+	"OPF_018": {
+		epub33Spec + "#sec-link-rel",
+		"The format-specific record relationships are deprecated. Use " +
+			"`record` and name the format with `media-type`, or with " +
+			"`properties=\"onix\"` for ONIX.",
+	},
+	"OPF_019": {
+		epub33Spec + "#sec-spine-elem",
+		"The `spine` element is required. It lists the content documents in " +
+			"reading order, and without it there is nothing to read.",
+	},
+	"OPF_020": {
+		epub33Spec + "#attrdef-spine-page-progression-direction",
+		"The spine `page-progression-direction` sets the reading direction " +
+			"and must be `ltr`, `rtl`, or `default`.",
+	},
+	"OPF_025": {
+		epub33Spec + "#sec-item-elem",
+		"Reading systems decide how to process a resource from its declared " +
+			"`media-type`, not from its file extension.",
+	},
+	"OPF_027": {
+		epub33Spec + "#sec-itemref-elem",
+		"Spine itemref `properties` take values from the package and " +
+			"rendition vocabularies, such as `page-spread-left` or " +
+			"`rendition:layout-pre-paginated`. Reading systems ignore " +
+			"anything else.",
+	},
+	"OPF_028": {
+		epub33Spec + "#sec-prefix-attr",
+		"Property prefixes other than the reserved ones must be mapped to a " +
+			"vocabulary URI in the package `prefix` attribute, or the property " +
+			"has no defined meaning.",
+	},
+	"OPF_030": {
+		epub33Spec + "#sec-opf-dcidentifier",
+		"Every package needs `metadata` with at least one `dc:identifier`, " +
+			"which reading systems and retailers use to tell publications apart.",
+	},
+	"OPF_031": {
+		epub33Spec + "#attrdef-package-unique-identifier",
+		"The package `unique-identifier` attribute names the `dc:identifier` " +
+			"that identifies this publication across versions.",
+	},
+	"OPF_032": {
+		epub33Spec + "#sec-opf-dctitle",
+		"A `dc:title` is required so reading systems can show the " +
+			"publication's name in libraries and on devices.",
+	},
+	"OPF_034": {
+		epub33Spec + "#sec-opf-dclanguage",
+		"A `dc:language` is required. Reading systems and assistive " +
+			"technologies use it to pick hyphenation, fonts, and " +
+			"text-to-speech voices.",
+	},
+	"OPF_040": {
+		epub33Spec + "#sec-manifest-fallbacks",
+		"Reading systems only have to support the core media types. A " +
+			"resource in another format needs a `fallback` chain that ends in " +
+			"a core media type so every reading system can render something.",
+	},
+	"OPF_041": {
+		epub33Spec + "#sec-manifest-fallbacks",
+		"A `fallback` attribute must reference the `id` of another manifest " +
+			"item, or the chain ends before reaching a usable resource.",
+	},
+	"OPF_045": {
+		epub33Spec + "#sec-manifest-fallbacks",
+		"Fallback chains must end. A chain that loops back on itself never " +
+			"reaches a core media type that reading systems can render.",
+	},
+	"OPF_050": {
+		epub2OPFSpec + "#Section2.4",
+		"EPUB 2 reading systems find the table of contents through the spine " +
+			"`toc` attribute, which must reference the NCX manifest item. EPUB 3 " +
+			"packages may keep it for compatibility, with the same requirement.",
+	},
+	"OPF_052": {
+		"https://www.loc.gov/marc/relators/relaterm.html",
+		"The `role` refinement with the `marc:relators` scheme takes a code " +
+			"from the MARC relator list, such as `aut` or `ill`.",
+	},
+	"OPF_053": {
+		epub33Spec + "#sec-opf-dcdate",
+		"`dc:date` must use the W3CDTF format (`YYYY`, `YYYY-MM`, " +
+			"`YYYY-MM-DD`, or a full timestamp) so it can be read by machines.",
+	},
+	"OPF_060": {
+		epub33Spec + "#sec-opf-dctitle",
+		"With several `dc:title` elements and no `title-type` or " +
+			"`display-seq` refinements, reading systems cannot tell the main " +
+			"title from the others and may show any of them.",
+	},
+	"OPF_061": {
+		epub33Spec + "#sec-container-filenames",
+		"File names in an OCF container must stay unique after Unicode case " +
+			"folding. Paths differing only in case collide when the EPUB is " +
+			"unzipped on a case-insensitive file system, and some reading " +
+			"systems load the wrong one.",
+	},
+	"OPF_064": {
+		epub33Spec + "#sec-display-seq",
+		"`display-seq` orders repeated metadata such as multiple titles or " +
+			"creators, and only positive integers are meaningful.",
+	},
+	"OPF_066": {
+		epub33Spec + "#attrdef-refines",
+		"A `refines` attribute adds detail to another element and must point " +
+			"at an existing `id` with a fragment identifier such as `#creator`, " +
+			"or the refinement is lost.",
+	},
+	"OPF_086": {
+		epub33Spec + "#sec-metadata-elem",
+		"EPUB 3 replaced the EPUB 2 `name`/`content` meta with `property` " +
+			"metadata. Reading systems ignore the old form, so values such as " +
+			"calibre series information reach only the tools that wrote them.",
+	},
+	"OPF_087": {
+		epub33Spec + "#sec-cover-image",
+		"EPUB 3 reading systems find the cover image through the " +
+			"`cover-image` property on its manifest item. The EPUB 2 cover " +
+			"meta is read only by older reading systems.",
+	},
+	"OPF_088": {
+		epub33Spec + "#sec-itemref-elem",
+		"An itemref can take only one value of each kind, such as one page " +
+			"spread or one layout. With contradictory values, reading systems " +
+			"pick one and may not render the page as intended.",
+	},
+	"OPF_089": {
+		epub33Spec + "#sec-itemref-elem",
+		"Repeating a property on an itemref has no effect.",
+	},
+	"fallback-chain": {
+		epub33Spec + "#sec-manifest-fallbacks",
+		"Reading systems that cannot render this item's media type follow " +
+			"its fallback chain to the core media type resource.",
+	},
+
+	// Media overlays
+	"MED_001": {
+		epub33Spec + "#sec-media-overlays-packaging",
+		"A publication with media overlays must give its total playback " +
+			"time in a `media:duration` meta without `refines`.",
+	},
+	"MED_002": {
+		epub33Spec + "#sec-media-overlays-packaging",
+		"Each media overlay needs a `media:duration` meta refining its " +
+			"manifest item so reading systems can show its playback time.",
+	},
+	"MED_003": {
+		epub33Spec + "#app-clock-examples",
+		"`media:duration` must be a SMIL clock value such as `0:32:29.5`, " +
+			"`05:01.2`, or `12.5s`.",
+	},
+	"MED_004": {
+		epub33Spec + "#sec-media-overlays-packaging",
+		"The global `media:duration` should equal the sum of the media " +
+			"overlay durations; a difference of more than a second usually " +
+			"means one of them is out of date.",
+	},
+
+	// Resources and container
+	"RSC_001": {
+		epub33Spec + "#sec-container-metainf",
+		"Files referenced from the `META-INF` directory must exist in the " +
+			"container, or reading systems fail to open them.",
+	},
+	"RSC_002": {
+		epub33Spec + "#sec-container-metainf",
+		"An unpacked EPUB needs a top-level `META-INF` directory holding " +
+			"`container.xml`, which tells reading systems where the package " +
+			"document is.",
+	},
+	"PKG_006": {
+		epub33Spec + "#sec-zip-container-mime",
+		"The top level of the book must hold a file named `mimetype`, " +
+			"which becomes the first entry of the EPUB archive and identifies " +
+			"it as an EPUB.",
+	},
+	"PKG_007": {
+		epub33Spec + "#sec-zip-container-mime",
+		"The `mimetype` file must contain exactly `application/epub+zip`, " +
+			"with no byte order mark, trailing newline, or other whitespace. " +
+			"Reading systems and epubcheck compare the bytes.",
+	},
+	"package-href-parent": {
+		epub33Spec + "#sec-item-elem",
+		"Resources outside the package document's directory are reached " +
+			"with `../` hrefs, which break when the package document moves " +
+			"and which some packaging tools and reading systems mishandle. " +
+			"Keep the content under the package document's directory.",
+	},
+	"RSC_003": {
+		epub33Spec + "#sec-container-metainf-container.xml",
+		"`container.xml` must declare a `rootfile` with media type " +
+			"`application/oebps-package+xml` so reading systems can find the " +
+			"package document.",
+	},
+	"RSC_004": {
+		epub33Spec + "#sec-container-metainf-encryption.xml",
+		"Encrypted resources cannot be checked. Only font obfuscation is " +
+			"commonly supported, so other encrypted content may not open.",
+	},
+	"PKG_026": {
+		epub33Spec + "#sec-font-obfuscation",
+		"Obfuscation applies only to fonts, and reading systems find the " +
+			"font to de-obfuscate through its manifest item. Any other " +
+			"resource, or one missing from the manifest, stays unreadable.",
+	},
+	"font-obfuscation-undeclared": {
+		epub33Spec + "#sec-font-obfuscation",
+		"This font does not start like an OpenType, TrueType, or WOFF file. " +
+			"It is either damaged or obfuscated, and reading systems only " +
+			"de-obfuscate fonts that META-INF/encryption.xml lists, so the " +
+			"text falls back to another font or shows missing glyphs.",
+	},
+	"RSC_005": {
+		epub33Spec + "#sec-container-metainf-container.xml",
+		"The `container` element must be in the OCF namespace " +
+			"`urn:oasis:names:tc:opendocument:xmlns:container`.",
+	},
+	"RSC_007": {
+		epub33Spec + "#sec-item-elem",
+		"Every manifest item and hyperlink must point at a file in the " +
+			"publication. Missing resources show up as broken content and " +
+			"dead links in reading systems.",
+	},
+	"RSC_008": {
+		epub33Spec + "#sec-manifest-elem",
+		"All publication resources must be listed in the manifest. Reading " +
+			"systems may refuse to load files that are not.",
+	},
+	"RSC_016": {
+		"https://www.w3.org/TR/xml/#syntax",
+		"XHTML is XML, where `&` always starts a character or entity " +
+			"reference. A literal ampersand must be written as `&amp;`.",
+	},
+	"XML_DUP_ATTR": {
+		"https://www.w3.org/TR/xml/#uniqattspec",
+		"An attribute name may appear only once in a start tag. Parsers " +
+			"disagree on which value wins, and strict ones reject the " +
+			"document.",
+	},
+	"ATTR_WS": {
+		"https://www.w3.org/TR/xml/#AVNormalize",
+		"XML turns each line break and tab in an attribute value into a " +
+			"space, so the value reading systems see differs from the one " +
+			"written. Collapsing the whitespace makes it match.",
+	},
+	"RSC_020": {
+		epub33Spec + "#sec-container-filenames",
+		"Paths in an EPUB are case-sensitive. An href that finds its file " +
+			"only on a case-insensitive file system, as on macOS or Windows, " +
+			"breaks in reading systems and on Linux.",
+	},
+	"RSC_025": {
+		"https://www.w3.org/TR/xml/#sec-predefined-ent",
+		"XML only predefines `&amp;`, `&lt;`, `&gt;`, `&quot;`, and " +
+			"`&apos;`. HTML named entities such as `&nbsp;` are undefined in " +
+			"XHTML and stop the document from parsing.",
+	},
+	"RSC_031": {
+		epub33Spec + "#sec-resource-locations",
+		"Remote resources should be fetched over `https`. Reading systems " +
+			"increasingly block plain `http` content, leaving images, fonts, " +
+			"and media missing.",
+	},
+	"url-invalid": {
+		epub33Spec + "#sec-container-iri",
+		"References must be valid URLs, as epubcheck's RSC_020 requires. A " +
+			"stray `%` or a control character makes the reference " +
+			"unparseable, so no reading system can follow it.",
+	},
+	"url-backslash": {
+		epub33Spec + "#sec-container-iri",
+		"URL paths are separated by `/`. Backslashes from Windows paths " +
+			"work in some reading systems and not others, which look for a " +
+			"file with a backslash in its name.",
+	},
+	"url-unencoded": {
+		epub33Spec + "#sec-container-iri",
+		"Spaces, non-ASCII letters, and characters such as `{` or `|` must " +
+			"be percent-encoded in URLs, as in `my%20chapter.xhtml`. Reading " +
+			"systems that do not encode them for you fail to find the file.",
+	},
+	"orphan-file": {
+		epub33Spec + "#sec-manifest-elem",
+		"This file is not listed in the manifest, so it is not part of the " +
+			"publication. Add it to the manifest or remove it.",
+	},
+	"duplicate-resources": {
+		epub33Spec + "#sec-manifest-elem",
+		"These manifest items hold identical content under different " +
+			"names, which bloats the package. Keep one copy and point the " +
+			"references to the others at it.",
+	},
+
+	// Content documents
+	"HTM_008": {
+		wcagDocs + "non-text-content.html",
+		"Screen readers announce an image through its `alt` text. Use " +
+			"`alt=\"\"` for decorative images so they are skipped.",
+	},
+	"HTM_017": {
+		wcagDocs + "language-of-page.html",
+		"`xml:lang` and `lang` must agree. Reading systems may read either, " +
+			"and a mismatch gives the wrong language to assistive technology.",
+	},
+	"HTM_045": {
+		epub33Spec + "#sec-xhtml-extensions",
+		"Attributes such as `epub:type` belong to the " +
+			"`http://www.idpf.org/2007/ops` namespace, which the document " +
+			"must declare with `xmlns:epub`, usually on `<html>`. Without " +
+			"it, XML processors reject the document or ignore the attribute.",
+	},
+	"HTM_049": {
+		epub33Spec + "#sec-xhtml-req",
+		"XHTML content documents must put the `html` element in the " +
+			"`http://www.w3.org/1999/xhtml` namespace to be treated as HTML.",
+	},
+	"title-missing": {
+		epub33Spec + "#sec-xhtml",
+		"Every content document needs a non-empty `<title>` in its `<head>`. " +
+			"Reading systems show it in history and navigation, and screen " +
+			"readers announce it when the document opens.",
+	},
+	"body-text": {
+		epub33Spec + "#sec-xhtml",
+		"Text placed directly in `<body>` has no block element to carry its " +
+			"styling and semantics, and XHTML 1.1 based validators such as " +
+			"epubcheck's EPUB 2 schema reject it. Wrap it in `<p>` or another " +
+			"block element.",
+	},
+	"dir-value": {
+		"https://html.spec.whatwg.org/multipage/dom.html#the-dir-attribute",
+		"`dir` takes `ltr`, `rtl`, or `auto`. Other values are ignored, so " +
+			"the text falls back to the inherited direction. EPUB forbids the " +
+			"CSS `direction` property (CSS_001), so `dir` is the only way to " +
+			"set it.",
+	},
+	"bdo-dir": {
+		"https://html.spec.whatwg.org/multipage/text-level-semantics.html#the-bdo-element",
+		"`<bdo>` overrides the bidirectional algorithm in the direction its " +
+			"`dir` gives, which must be `ltr` or `rtl`. It takes the place of " +
+			"`unicode-bidi: bidi-override`, which EPUB stylesheets may not use.",
+	},
+	"dir-rtl-language": {
+		"https://www.w3.org/International/questions/qa-html-dir",
+		"Without `dir=\"rtl\"`, right-to-left text is laid out in a " +
+			"left-to-right block, so punctuation, numbers, and mixed-script " +
+			"runs land on the wrong side.",
+	},
+	"lang-region-unlikely": {
+		"https://www.w3.org/International/questions/qa-choosing-language-tags",
+		"The region subtag of a language tag is an ISO 3166 country code " +
+			"such as `GB`, or a UN M.49 number such as `419`. An unknown " +
+			"region, often a language code like `UK` or `JA`, makes reading " +
+			"systems fall back to the bare language or ignore the tag.",
+	},
+	"SCP_001": {
+		epub33Spec + "#sec-scripted-content",
+		"Reading systems may not run scripts, and users may turn them off. " +
+			"A `<noscript>` fallback, or live regions and an `application` " +
+			"role that scripts keep accessible, shows the content still works " +
+			"without them.",
+	},
+	"SCP_009": {
+		epub33Spec + "#sec-resource-locations",
+		"An external script must be a file in the container; reading " +
+			"systems do not load a script that is missing, and whatever it " +
+			"builds never appears.",
+	},
+	"SCP_010": {
+		wcagDocs + "keyboard.html",
+		"An element that is not interactive by nature cannot take keyboard " +
+			"focus and has no role for assistive technology. Give it " +
+			"`tabindex` and a `role`, or use a `<button>` or link instead.",
+	},
+
+	// Navigation
+	"NAV_003": {
+		epub33Spec + "#sec-nav-toc",
+		"The EPUB 3 navigation document must contain exactly one " +
+			"`nav epub:type=\"toc\"`, which reading systems show as the " +
+			"table of contents.",
+	},
+	"NAV_010": {
+		epub33Spec + "#sec-nav-def-model",
+		"Table of contents and other navigation links must point at " +
+			"resources inside the publication, not remote URLs.",
+	},
+	"NAV_011": {
+		epub33Spec + "#sec-nav-toc",
+		"Table of contents entries should follow the spine reading order so " +
+			"navigation matches what readers page through.",
+	},
+	"NAV_013": {
+		epub33Spec + "#sec-nav-toc",
+		"Repeated table of contents entries for the same target clutter " +
+			"navigation without adding anything.",
+	},
+	"NAV_014": {
+		epub33Spec + "#sec-nav-toc",
+		"Table of contents links must point at content documents in the " +
+			"spine, not stylesheets, images, or other resources.",
+	},
+	"NAV_015": {
+		epub33Spec + "#sec-item-elem",
+		"Reading systems find the navigation document through the `nav` " +
+			"property on its manifest item. Without it the table of contents " +
+			"is not offered.",
+	},
+	"NAV_016": {
+		epub33Spec + "#sec-item-elem",
+		"Exactly one manifest item may declare the `nav` property. With " +
+			"several, reading systems pick one and ignore the others.",
+	},
+	"NAV_017": {
+		epub33Spec + "#sec-itemref-elem",
+		"A navigation document whose toc is `hidden` shows nothing when " +
+			"reached in the reading order. Mark its itemref `linear=\"no\"` " +
+			"or remove it from the spine.",
+	},
+
+	// Stylesheets
+	"CSS_001": {
+		epub33Spec + "#sec-css-req",
+		"`direction` and `unicode-bidi` must not be set in EPUB stylesheets. " +
+			"Use the `dir` attribute and the spine `page-progression-direction` " +
+			"instead.",
+	},
+	"CSS_003": {
+		epub33Spec + "#sec-css-req",
+		"Stylesheets must be encoded as UTF-8 (or UTF-16) so reading systems " +
+			"decode them correctly.",
+	},
+	"CSS_006": {
+		epub33Spec + "#sec-css-req",
+		"Paginated reading systems often ignore or misrender " +
+			"`position: fixed`, so content may vanish or overlap.",
+	},
+	"CSS_007": {
+		epub33Spec + "#sec-cmt-supported",
+		"Reading systems only have to support the core font formats: " +
+			"OpenType, TrueType, WOFF, and WOFF2.",
+	},
+	"CSS_008": {
+		"https://www.w3.org/TR/css-syntax-3/",
+		"A syntax error can make reading systems drop the rule or the rest of " +
+			"the stylesheet.",
+	},
+	"CSS_010": {
+		epub33Spec + "#sec-manifest-elem",
+		"An imported stylesheet must exist in the publication and be listed " +
+			"in the manifest, or its rules never apply.",
+	},
+	"CSS_017": {
+		epub33Spec + "#sec-css-req",
+		"`position: absolute` depends on the page box, which differs between " +
+			"reading systems and pagination modes.",
+	},
+	"CSS_028": {
+		"https://www.w3.org/TR/css-fonts-4/#font-family-prop",
+		"Many reading systems have few or no fonts of their own, so a family " +
+			"that no `@font-face` embeds silently falls back to a default. End " +
+			"the list with a generic family such as `serif`.",
+	},
+	"CSS_029": {
+		epub33Spec + "#sec-manifest-elem",
+		"Embedded fonts are publication resources and must be listed in the " +
+			"manifest, or reading systems may not load them.",
+	},
+	"CSS_032": {
+		"https://www.w3.org/TR/css-variables-1/#using-variables",
+		"A `var()` reading a custom property that no stylesheet declares makes " +
+			"the whole declaration invalid at computed-value time, so the " +
+			"property falls back to its inherited or initial value. Declare the " +
+			"property, for example under `:root`, or give `var()` a fallback.",
+	},
+	"css-at-rule-order": {
+		"https://www.w3.org/TR/css-cascade-4/#at-import",
+		"`@import` rules must precede all other rules except `@charset` and " +
+			"`@layer`, and `@namespace` rules may only follow them. Rules out of " +
+			"place are invalid and ignored.",
+	},
+	"css-import-cycle": {
+		"https://www.w3.org/TR/css-cascade-4/#at-import",
+		"Stylesheets that import each other in a loop are skipped by browsers " +
+			"but can hang tools that follow imports naively.",
+	},
+	"css-media-feature-unknown": {
+		"https://www.w3.org/TR/mediaqueries-5/#media-descriptor-table",
+		"A media query testing a feature the reader does not know is false, " +
+			"so a misspelled name such as `min-widht` silently turns off " +
+			"every rule in the `@media` block.",
+	},
+	"css-media-feature-limited": {
+		"https://www.w3.org/TR/mediaqueries-5/#media-descriptor-table",
+		"Reading systems answer features such as `hover`, `pointer`, and " +
+			"`prefers-color-scheme` inconsistently, or the same way on every " +
+			"device, so rules gated on them apply on some readers only. Hover " +
+			"over the feature for details.",
+	},
+
+	// Accessibility metadata
+	"metadata-accessmode": {
+		daisyKB + "metadata/schema.org/accessMode.html",
+		"`schema:accessMode` tells readers which senses are needed to " +
+			"consume the content, such as `textual` or `visual`.",
+	},
+	"metadata-accessmode-invalid": {
+		daisyKB + "metadata/schema.org/accessMode.html",
+		"`schema:accessMode` takes one of the schema.org access mode values, " +
+			"such as `auditory`, `textual`, or `visual`.",
+	},
+	"metadata-accessmodesufficient": {
+		daisyKB + "metadata/schema.org/accessModeSufficient.html",
+		"`schema:accessModeSufficient` lists the combinations of access " +
+			"modes that are enough to understand all the content.",
+	},
+	"metadata-accessmodesufficient-invalid": {
+		daisyKB + "metadata/schema.org/accessModeSufficient.html",
+		"Each `schema:accessModeSufficient` value is a comma-separated list " +
+			"of access modes, such as `textual,visual`, with no empty entries.",
+	},
+	"metadata-accessibilityfeature": {
+		daisyKB + "metadata/schema.org/accessibilityFeature.html",
+		"`schema:accessibilityFeature` lists features such as " +
+			"`structuralNavigation` or `alternativeText` that readers can " +
+			"rely on.",
+	},
+	"metadata-accessibilityfeature-invalid": {
+		daisyKB + "metadata/schema.org/accessibilityFeature.html",
+		"`schema:accessibilityFeature` takes values from the schema.org " +
+			"accessibility feature vocabulary.",
+	},
+	"metadata-accessibilityhazard": {
+		daisyKB + "metadata/schema.org/accessibilityHazard.html",
+		"`schema:accessibilityHazard` warns readers about flashing, motion " +
+			"simulation, or sound hazards, or states that there are `none`.",
+	},
+	"metadata-accessibilityhazard-invalid": {
+		daisyKB + "metadata/schema.org/accessibilityHazard.html",
+		"`schema:accessibilityHazard` takes values from the schema.org hazard " +
+			"vocabulary, and `none` cannot be combined with a hazard.",
+	},
+	"metadata-accessibilitysummary": {
+		daisyKB + "metadata/schema.org/accessibilitySummary.html",
+		"`schema:accessibilitySummary` is a human-readable description of " +
+			"the publication's accessibility.",
+	},
+	"metadata-accessibilitysummary-invalid": {
+		daisyKB + "metadata/schema.org/accessibilitySummary.html",
+		"An empty `schema:accessibilitySummary` tells readers nothing; " +
+			"it should describe the publication's accessibility.",
+	},
+	"epub-title": {
+		wcagDocs + "page-titled.html",
+		"Assistive technologies announce the publication by its `dc:title`.",
+	},
+	"epub-lang": {
+		wcagDocs + "language-of-page.html",
+		"Screen readers use `dc:language` to choose pronunciation rules.",
+	},
+
+	// Page navigation
+	"printPageNumbers-nopagelist": {
+		daisyKB + "navigation/pagelist.html",
+		"The `printPageNumbers` feature promises print page navigation, " +
+			"which requires a `page-list` nav in the navigation document.",
+	},
+	"printPageNumbers-nopagebreaks": {
+		daisyKB + "navigation/pagelist.html",
+		"The `printPageNumbers` feature promises print page locations, which " +
+			"are marked with `epub:type=\"pagebreak\"` in the content.",
+	},
+	"epub-pagesource": {
+		daisyKB + "navigation/pagelist.html",
+		"A page list should name the print edition it was taken from in a " +
+			"`dc:source`, so readers know which page numbers they get.",
+	},
+	"epub-pagelist-broken": {
+		daisyKB + "navigation/pagelist.html",
+		"Each page list entry must link to an existing page break; a broken " +
+			"link sends readers nowhere.",
+	},
+	"captions-notracks": {
+		daisyKB + "metadata/schema.org/accessibilityFeature.html",
+		"The `captions` feature promises captions for video, which are " +
+			"provided with `<track kind=\"captions\">` elements.",
+	},
+	"transcript-nolinks": {
+		daisyKB + "metadata/schema.org/accessibilityFeature.html",
+		"The `transcript` feature promises text transcripts of audio and " +
+			"video, linked from the media or referenced with `aria-describedby`.",
+	},
+	"feature-evidence-alt": {
+		daisyKB + "metadata/schema.org/accessibilityFeature.html",
+		"The `alternativeText` feature promises text alternatives for " +
+			"images; an image without an `alt` attribute makes the claim false.",
+	},
+	"feature-evidence-mathml": {
+		daisyKB + "metadata/schema.org/accessibilityFeature.html",
+		"The `MathML` feature promises equations marked up as MathML, but no " +
+			"content document has a `<math>` element.",
+	},
+	"feature-evidence-navigation": {
+		daisyKB + "metadata/schema.org/accessibilityFeature.html",
+		"The `structuralNavigation` feature promises headings or a table of " +
+			"contents to move through the book by, and the content has neither.",
+	},
+	"feature-evidence-transformability": {
+		daisyKB + "metadata/schema.org/accessibilityFeature.html",
+		"The `displayTransformability` feature promises that readers can " +
+			"resize text. Font sizes in absolute units such as `px` and `pt` " +
+			"may not follow the reader's setting, so many of them cast doubt " +
+			"on the claim.",
+	},
+
+	// Structure
+	"epub-type-has-matching-role": {
+		"https://www.w3.org/TR/dpub-aria-1.1/",
+		"`epub:type` is not exposed to assistive technologies. The " +
+			"matching DPUB-ARIA `role` gives them the same semantics.",
+	},
+	"epub-type-unknown": {
+		"https://www.w3.org/TR/epub-ssv-11/",
+		"`epub:type` terms come from the Structural Semantics Vocabulary. " +
+			"Other terms, often typos such as `front-matter`, are ignored by " +
+			"reading systems unless they use a prefix declared in `epub:prefix`.",
+	},
+	"pagebreak-label": {
+		"https://www.w3.org/TR/dpub-aria-1.1/#doc-pagebreak",
+		"Page breaks need an accessible name, usually the page number, so " +
+			"screen reader users know where they are.",
+	},
+	"heading-order": {
+		daisyKB + "html/headings.html",
+		"Skipped heading levels break the document outline that screen " +
+			"reader users navigate by.",
+	},
+	"no-headings": {
+		daisyKB + "html/headings.html",
+		"Headings let screen reader users skim a chapter and jump between " +
+			"its parts. A long stretch of bodymatter text without any " +
+			"leaves them to read it start to finish.",
+	},
+	"heading-first": {
+		daisyKB + "html/headings.html",
+		"A document's first heading should be its highest level, usually " +
+			"the chapter title. Starting lower, as with an h3 before an h2, " +
+			"puts the opening heading outside the outline the rest builds.",
+	},
+	"multiple-h1": {
+		daisyKB + "html/headings.html",
+		"Some house styles keep one h1 per document, for the chapter or " +
+			"section title, and use h2 and below inside it. This check is " +
+			"off unless `multipleH1` is set.",
+	},
+	"empty-heading": {
+		daisyKB + "html/headings.html",
+		"Screen readers announce a heading without text as an empty " +
+			"heading, cluttering the outline readers navigate by. Add text, " +
+			"an `aria-label`, or remove the heading.",
+	},
+	"empty-paragraph": {
+		wcagDocs + "info-and-relationships.html",
+		"Empty paragraphs used for vertical space are announced as blank " +
+			"lines by some screen readers. Use CSS margins instead. Only the " +
+			"first few are reported per file.",
+	},
+	"br-spacing": {
+		wcagDocs + "info-and-relationships.html",
+		"Runs of `<br/>` used for vertical space are read as blank lines by " +
+			"some screen readers. Use CSS margins or separate paragraphs " +
+			"instead. Only the first few are reported per file.",
+	},
+	"alt-redundant-phrase": {
+		wcagDocs + "non-text-content.html",
+		"Screen readers already announce an image as one, so alt text " +
+			"starting with \"image of\" or similar repeats it. Describe the " +
+			"content instead. The phrases can be set with `altRedundantPhrases`.",
+	},
+	"alt-duplicates-caption": {
+		wcagDocs + "non-text-content.html",
+		"When alt text repeats the figure caption, screen reader users hear " +
+			"the same text twice. Describe what the image shows, or use " +
+			"`alt=\"\"` if the caption already does.",
+	},
+	"alt-filename": {
+		wcagDocs + "non-text-content.html",
+		"A file name tells screen reader users nothing about the image. " +
+			"This is usually left over from an authoring tool.",
+	},
+	"presentational-bold": {
+		wcagDocs + "info-and-relationships.html",
+		"`<b>` only changes how text looks, so assistive technology does not " +
+			"convey its importance. Use `<strong>` when the text matters more " +
+			"than what surrounds it; keywords marked with `lang`, `epub:type`, " +
+			"or `role` are not reported.",
+	},
+	"presentational-italic": {
+		wcagDocs + "info-and-relationships.html",
+		"`<i>` only changes how text looks, so assistive technology does not " +
+			"convey the emphasis. Use `<em>` when the text is stressed; foreign " +
+			"phrases and terms marked with `lang`, `epub:type`, or `role` are " +
+			"not reported.",
+	},
+	"presentational-underline": {
+		wcagDocs + "info-and-relationships.html",
+		"Underlined text is easily mistaken for a link and carries no " +
+			"meaning for assistive technology. Use CSS `text-decoration` or " +
+			"semantic markup such as `<cite>` instead.",
+	},
+	"fake-list": {
+		daisyKB + "html/lists.html",
+		"Paragraphs that start with bullets or numbers look like a list but " +
+			"are read as separate paragraphs. In a `<ul>` or `<ol>`, screen " +
+			"readers announce the list and how many items it has.",
+	},
+	"single-item-list": {
+		daisyKB + "html/lists.html",
+		"A list with one item is announced as a list of one, which is " +
+			"confusing when the list only indents its content. Use CSS for " +
+			"indentation.",
+	},
+	"list-structure": {
+		wcagDocs + "info-and-relationships.html",
+		"`<ol>` and `<ul>` may only contain `<li>` elements, besides " +
+			"`<script>` and `<template>`. Other children break the list " +
+			"structure that assistive technology relies on.",
+	},
+	"fake-heading": {
+		daisyKB + "html/headings.html",
+		"A paragraph made large and bold looks like a heading but is missing " +
+			"from the outline that screen reader users navigate by. This " +
+			"heuristic is enabled with the `fakeHeadings` setting.",
+	},
+	"table-caption": {
+		wcagDocs + "info-and-relationships.html",
+		"A caption or label tells screen reader users what a table contains " +
+			"before they move through its cells.",
+	},
+	"input-label": {
+		wcagDocs + "labels-or-instructions.html",
+		"Form controls need a label so assistive technologies can announce " +
+			"what to enter.",
+	},
+	"link-name": {
+		wcagDocs + "link-purpose-in-context.html",
+		"Screen readers announce links by their text. A link without text " +
+			"or an `aria-label` is announced only as \"link\".",
+	},
+	"link-href-empty": {
+		wcagDocs + "link-purpose-in-context.html",
+		"Placeholder links such as `#` look actionable but go nowhere, " +
+			"which confuses readers.",
+	},
+	"media-captions": {
+		wcagDocs + "captions-prerecorded.html",
+		"Deaf and hard of hearing readers rely on captions to follow the " +
+			"spoken content of a video.",
+	},
+	"media-controls": {
+		wcagDocs + "audio-control.html",
+		"Without controls, keyboard and screen reader users cannot pause, " +
+			"stop, or adjust media playback.",
+	},
+	"media-transcript": {
+		wcagDocs + "audio-only-and-video-only-prerecorded.html",
+		"A transcript gives readers who cannot hear the audio the same " +
+			"information. This check looks for a nearby link mentioning a " +
+			"transcript, so it is only a hint.",
+	},
+	"nested-interactive": {
+		"https://html.spec.whatwg.org/multipage/dom.html#interactive-content",
+		"Interactive elements inside other interactive elements are invalid " +
+			"HTML. Assistive technologies and reading systems handle them " +
+			"unpredictably.",
+	},
+	"svg-title": {
+		daisyKB + "html/svg.html",
+		"An SVG image without a `<title>` or `aria-label` has no text " +
+			"alternative, so screen readers skip it or read its markup. Hide " +
+			"purely decorative images with `aria-hidden=\"true\"`.",
+	},
+
+	// Source hygiene
+	"WS_001": {
+		"https://google.github.io/styleguide/htmlcssguide.html#Indentation",
+		"Tabs and spaces render at different widths in different editors, so " +
+			"a file indented with both looks misaligned to anyone whose tab " +
+			"width differs from the author's.",
+	},
+	"WS_002": {
+		"https://google.github.io/styleguide/htmlcssguide.html#Trailing_Whitespace",
+		"Trailing whitespace is invisible in the editor but shows up as noise " +
+			"in diffs and reviews.",
+	},
+	"WS_003": {
+		"https://pubs.opengroup.org/onlinepubs/9699919799/basedefs/V1_chap03.html#tag_03_206",
+		"Text files end with a newline by convention. Without one, tools such " +
+			"as `cat` and `diff` treat the last line as incomplete.",
+	},
+	"prose-line-length": {
+		"https://sembr.org/",
+		"Long lines of prose make diffs hard to read, since changing one word " +
+			"marks the whole paragraph as changed. Formatting with `proseWrap` " +
+			"set to `sentence` starts each sentence on its own line.",
+	},
+
+	// Server
+	"internal-error": {
+		"https://github.com/toba/epub-lsp/issues",
+		"A validator failed on this file, so some of its checks did not run. " +
+			"Please report the message and, if you can, the file.",
+	},
+}
+
+// CodeURL returns the documentation URL for a diagnostic code, or "".
+func CodeURL(code string) string {
+	return codeDocs[code].URL
+}
+
+// CodeExplanation returns the markdown explanation of a diagnostic code,
+// or "".
+func CodeExplanation(code string) string {
+	return codeDocs[code].Explanation
+}
+
+// Codes returns every documented diagnostic code, sorted.
+func Codes() []string {
+	return slices.Sorted(maps.Keys(codeDocs))
+}
@@ -0,0 +1,65 @@
+package epub
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// emittedCode matches the diagnostic codes set through DiagBuilder.Code and
+// Diagnostic literals.
+var emittedCode = regexp.MustCompile(`Code(?:\(|:\s*)"([^"]+)"`)
+
+func TestEveryEmittedCodeIsDocumented(t *testing.T) {
+	codes := make(map[string]string)
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (d.Name() == "testutil" || d.Name() == "testdata") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") ||
+			strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		src, err := os.ReadFile(path) //nolint:gosec // walking the package tree
+		if err != nil {
+			return err
+		}
+		for _, m := range emittedCode.FindAllSubmatch(src, -1) {
+			codes[string(m[1])] = path
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) < 50 {
+		t.Fatalf("found only %d emitted codes; is the pattern still right?", len(codes))
+	}
+	for code, path := range codes {
+		doc, ok := codeDocs[code]
+		if !ok {
+			t.Errorf("%s: code %s has no documentation entry", path, code)
+			continue
+		}
+		if !strings.HasPrefix(doc.URL, "https://") || doc.Explanation == "" {
+			t.Errorf("code %s needs an https URL and an explanation", code)
+		}
+	}
+}
+
+func TestCodeDocLookup(t *testing.T) {
+	if CodeURL("HTM_008") == "" || CodeExplanation("HTM_008") == "" {
+		t.Error("expected documentation for HTM_008")
+	}
+	if CodeURL("UNKNOWN_123") != "" || CodeExplanation("UNKNOWN_123") != "" {
+		t.Error("expected no documentation for an unknown code")
+	}
+}
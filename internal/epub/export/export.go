@@ -0,0 +1,219 @@
+// Package export renders content documents as linear plain text or
+// Markdown, for proofreading a whole book with external tools.
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/stats"
+)
+
+// Format selects the markup of exported text.
+type Format string
+
+const (
+	// FormatText renders each block as a plain paragraph.
+	FormatText Format = "text"
+	// FormatMarkdown renders headings as # lines and list items as - lines.
+	FormatMarkdown Format = "markdown"
+)
+
+// Valid reports whether f is a known format.
+func (f Format) Valid() bool {
+	return f == FormatText || f == FormatMarkdown
+}
+
+// noteTypes holds the epub:type and role tokens marking footnotes and
+// endnotes, which are moved to the end of their chapter.
+var noteTypes = map[string]bool{
+	"footnote":      true,
+	"footnotes":     true,
+	"endnote":       true,
+	"endnotes":      true,
+	"rearnote":      true,
+	"rearnotes":     true,
+	"doc-footnote":  true,
+	"doc-endnote":   true,
+	"doc-endnotes":  true,
+	"doc-rearnote":  true,
+	"doc-rearnotes": true,
+}
+
+// Book renders chapters, the content documents of a book in reading
+// order, one after another with a blank line between them.
+func Book(chapters [][]byte, format Format) string {
+	var parts []string
+	for _, content := range chapters {
+		if text := Document(content, format); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, "\n\n") + "\n"
+}
+
+// Document renders the body of an XHTML content document in reading
+// order, one paragraph for each block with a blank line between them.
+// Images with alt text read as "[image: alt]", and footnotes and endnotes
+// follow the rest of the chapter. Whitespace within a block collapses to
+// single spaces and a <br> starts a new line. A document that is not
+// well-formed is rendered up to its first error.
+func Document(content []byte, format Format) string {
+	root, _ := parser.Parse(content)
+	r := &renderer{content: content, format: format}
+	body := root.FindFirst("body")
+	if body == nil {
+		body = root
+	}
+	r.children(body)
+	r.flush()
+
+	// Notes are rendered in full, even where they nest
+	r.inNotes = true
+	for _, note := range r.notes {
+		r.walk(note)
+		r.flush()
+	}
+	return strings.Join(r.blocks, "\n\n")
+}
+
+// renderer gathers the blocks of a document as it walks the tree.
+type renderer struct {
+	content []byte
+	format  Format
+	blocks  []string
+	// lines holds the finished lines of the current block, and line the
+	// text of the line being read.
+	lines []string
+	line  strings.Builder
+	// prefix starts the next block written, such as "## " for a heading.
+	prefix  string
+	notes   []*parser.XMLNode
+	inNotes bool
+}
+
+// walk renders node and its descendants.
+func (r *renderer) walk(node *parser.XMLNode) {
+	name := node.Local
+	switch {
+	case stats.IsSkipped(name):
+		return
+	case !r.inNotes && isNote(node):
+		r.notes = append(r.notes, node)
+		return
+	case name == "img":
+		if alt := strings.TrimSpace(node.Attr("alt")); alt != "" {
+			r.line.WriteString(" [image: " + alt + "] ")
+		}
+		return
+	case name == "br":
+		r.lines = append(r.lines, r.line.String())
+		r.line.Reset()
+		return
+	}
+
+	if stats.IsInline(name) {
+		r.children(node)
+		return
+	}
+	r.flush()
+	if r.format == FormatMarkdown {
+		if level := headingLevel(name); level > 0 {
+			r.prefix = strings.Repeat("#", level) + " "
+		} else if name == "li" {
+			r.prefix = "- "
+		}
+	}
+	r.children(node)
+	r.flush()
+	r.prefix = ""
+}
+
+// children renders the content of node: its text and child elements in
+// document order.
+func (r *renderer) children(node *parser.XMLNode) {
+	pos := 0
+	if node.Parent != nil {
+		pos, _, _ = parser.ElementSpan(r.content, int(node.Offset))
+	}
+	end := len(r.content)
+	if node.End > 0 {
+		end = bytes.LastIndexByte(r.content[:node.End], '<')
+	}
+
+	for _, child := range node.Children {
+		r.text(pos, int(child.Offset))
+		r.walk(child)
+		pos = int(child.End)
+		if child.End == 0 {
+			return // unclosed, so the document ends here
+		}
+	}
+	r.text(pos, end)
+}
+
+// text adds the character data of content[start:end] to the current line.
+func (r *renderer) text(start, end int) {
+	if start >= end {
+		return
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(r.content[start:end]))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		if data, ok := tok.(xml.CharData); ok {
+			r.line.Write(data)
+		}
+	}
+}
+
+// flush ends the current block, adding it to the blocks unless it has no
+// text.
+func (r *renderer) flush() {
+	lines := append(r.lines, r.line.String())
+	r.lines = nil
+	r.line.Reset()
+
+	var kept []string
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == 0 {
+		return
+	}
+	r.blocks = append(r.blocks, r.prefix+strings.Join(kept, "\n"))
+	r.prefix = ""
+}
+
+// isNote reports whether node is a footnote or endnote, or a section of
+// them.
+func isNote(node *parser.XMLNode) bool {
+	for _, value := range []string{node.AttrNS(epub.NSEpub, "type"), node.Attr("role")} {
+		for token := range strings.FieldsSeq(value) {
+			if noteTypes[token] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headingLevel returns the level of a heading element name, or 0.
+func headingLevel(name string) int {
+	if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+		return int(name[1] - '0')
+	}
+	return 0
+}
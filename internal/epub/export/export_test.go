@@ -0,0 +1,70 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestBook(t *testing.T) {
+	var chapters [][]byte
+	for _, name := range []string{"ch1.xhtml", "ch2.xhtml"} {
+		content, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		chapters = append(chapters, content)
+	}
+
+	for format, golden := range map[Format]string{
+		FormatText:     "book.txt",
+		FormatMarkdown: "book.md",
+	} {
+		t.Run(string(format), func(t *testing.T) {
+			got := []byte(Book(chapters, format))
+			path := filepath.Join("testdata", golden)
+			if *update {
+				if err := os.WriteFile(path, got, 0o600); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path) //nolint:gosec // test fixture path
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output differs from %s (run with -update to accept):\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestDocument_Malformed(t *testing.T) {
+	got := Document([]byte(`<html><body><p>Read this</p><p>and this<p>`), FormatText)
+	if got != "Read this\n\nand this" {
+		t.Errorf("Document = %q", got)
+	}
+}
+
+func TestDocument_NoBody(t *testing.T) {
+	got := Document([]byte(`<div><h2>Title</h2><p>Text</p></div>`), FormatMarkdown)
+	if got != "## Title\n\nText" {
+		t.Errorf("Document = %q", got)
+	}
+}
+
+func TestFormatValid(t *testing.T) {
+	for _, f := range []Format{FormatText, FormatMarkdown} {
+		if !f.Valid() {
+			t.Errorf("%q should be valid", f)
+		}
+	}
+	if Format("html").Valid() {
+		t.Error(`"html" should not be valid`)
+	}
+}
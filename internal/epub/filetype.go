@@ -16,11 +16,48 @@ const (
 	FileTypeNav
 	FileTypeCSS
 	FileTypeNCX
+	FileTypeContainer
+	FileTypeEncryption
+	FileTypeSVG
 )
 
+// languageFileTypes maps editor language IDs to the file type documents in
+// that language are validated as. Generic "xml" is absent, since XML
+// documents are told apart by their root element.
+var languageFileTypes = map[string]FileType{
+	"opf":        FileTypeOPF,
+	"epub-opf":   FileTypeOPF,
+	"xhtml":      FileTypeXHTML,
+	"html":       FileTypeXHTML,
+	"epub-xhtml": FileTypeXHTML,
+	"css":        FileTypeCSS,
+	"epub-css":   FileTypeCSS,
+	"ncx":        FileTypeNCX,
+	"epub-ncx":   FileTypeNCX,
+	"svg":        FileTypeSVG,
+	"epub-svg":   FileTypeSVG,
+}
+
 // DetectFileType determines the file type from extension and content.
 // Content sniffing is used to detect navigation documents (epub:type="toc").
+// META-INF files are detected by their path within the container.
 func DetectFileType(uri string, content []byte) FileType {
+	return DetectFileTypeWithLanguage(uri, content, "")
+}
+
+// DetectFileTypeWithLanguage is DetectFileType with the editor's language
+// ID for the document, which decides the type when the extension is
+// unknown or absent, as in untitled buffers. Failing both, the root
+// element of XML content decides.
+func DetectFileTypeWithLanguage(uri string, content []byte, languageID string) FileType {
+	lower := strings.ToLower(uri)
+	switch {
+	case PathEndsWith(lower, "meta-inf/container.xml"):
+		return FileTypeContainer
+	case PathEndsWith(lower, "meta-inf/encryption.xml"):
+		return FileTypeEncryption
+	}
+
 	ext := strings.ToLower(filepath.Ext(uri))
 
 	switch ext {
@@ -30,16 +67,77 @@ func DetectFileType(uri string, content []byte) FileType {
 		return FileTypeCSS
 	case ".ncx":
 		return FileTypeNCX
+	case ".svg":
+		return FileTypeSVG
 	case ".xhtml", ".html":
-		if isNavDocument(content) {
-			return FileTypeNav
+		return xhtmlFileType(content)
+	}
+
+	if ft, ok := languageFileTypes[strings.ToLower(languageID)]; ok {
+		if ft == FileTypeXHTML {
+			return xhtmlFileType(content)
 		}
-		return FileTypeXHTML
+		return ft
 	}
 
+	switch rootElement(content) {
+	case "package":
+		return FileTypeOPF
+	case "html":
+		return xhtmlFileType(content)
+	case "ncx":
+		return FileTypeNCX
+	case "svg":
+		return FileTypeSVG
+	}
 	return FileTypeUnknown
 }
 
+// xhtmlFileType returns FileTypeNav for navigation documents and
+// FileTypeXHTML for other content documents.
+func xhtmlFileType(content []byte) FileType {
+	if isNavDocument(content) {
+		return FileTypeNav
+	}
+	return FileTypeXHTML
+}
+
+// rootElement returns the local name of the first element in XML content,
+// skipping the declaration, comments, and doctype, or "" when content does
+// not start with markup.
+func rootElement(content []byte) string {
+	rest, _ := StripBOM(content)
+	for {
+		rest = bytes.TrimLeft(rest, " \t\r\n")
+		if len(rest) < 2 || rest[0] != '<' {
+			return ""
+		}
+		var end []byte
+		switch {
+		case bytes.HasPrefix(rest, []byte("<?")):
+			end = []byte("?>")
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			end = []byte("-->")
+		case rest[1] == '!':
+			end = []byte(">")
+		default:
+			name := rest[1:]
+			if i := bytes.IndexAny(name, " \t\r\n/>"); i >= 0 {
+				name = name[:i]
+			}
+			if i := bytes.IndexByte(name, ':'); i >= 0 {
+				name = name[i+1:]
+			}
+			return string(name)
+		}
+		i := bytes.Index(rest, end)
+		if i < 0 {
+			return ""
+		}
+		rest = rest[i+len(end):]
+	}
+}
+
 // isNavDocument checks if XHTML content is a navigation document.
 func isNavDocument(content []byte) bool {
 	return bytes.Contains(content, []byte(`epub:type="toc"`)) ||
@@ -59,6 +157,12 @@ func (ft FileType) String() string {
 		return "CSS"
 	case FileTypeNCX:
 		return "NCX"
+	case FileTypeContainer:
+		return "Container"
+	case FileTypeEncryption:
+		return "Encryption"
+	case FileTypeSVG:
+		return "SVG"
 	default:
 		return "Unknown"
 	}
@@ -14,6 +14,7 @@ func TestDetectFileType(t *testing.T) {
 		{"NCX file", "toc.ncx", nil, FileTypeNCX},
 		{"XHTML file", "chapter1.xhtml", nil, FileTypeXHTML},
 		{"HTML file", "chapter1.html", nil, FileTypeXHTML},
+		{"SVG file", "images/cover.svg", nil, FileTypeSVG},
 		{"Nav document", "nav.xhtml", []byte(`<nav epub:type="toc">`), FileTypeNav},
 		{
 			"Nav document single quotes",
@@ -21,6 +22,19 @@ func TestDetectFileType(t *testing.T) {
 			[]byte(`<nav epub:type='toc'>`),
 			FileTypeNav,
 		},
+		{
+			"Container file",
+			"file:///book/META-INF/container.xml",
+			nil,
+			FileTypeContainer,
+		},
+		{
+			"Encryption file",
+			"file:///book/META-INF/encryption.xml",
+			nil,
+			FileTypeEncryption,
+		},
+		{"Other XML file", "file:///book/container.xml", nil, FileTypeUnknown},
 		{"Unknown file", "image.png", nil, FileTypeUnknown},
 		{"Case insensitive", "PACKAGE.OPF", nil, FileTypeOPF},
 	}
@@ -35,6 +49,41 @@ func TestDetectFileType(t *testing.T) {
 	}
 }
 
+func TestDetectFileTypeWithLanguage(t *testing.T) {
+	opf := []byte(`<?xml version="1.0"?>
+<!-- generated -->
+<opf:package xmlns:opf="http://www.idpf.org/2007/opf"/>`)
+	nav := []byte(`<!DOCTYPE html><html><nav epub:type="toc"/></html>`)
+
+	tests := []struct {
+		name       string
+		uri        string
+		languageID string
+		content    []byte
+		want       FileType
+	}{
+		{"Untitled buffer", "untitled:Untitled-1", "epub-opf", nil, FileTypeOPF},
+		{"Unknown extension", "file:///book/style.txt", "CSS", nil, FileTypeCSS},
+		{"Extension wins", "file:///book/style.css", "html", nil, FileTypeCSS},
+		{"Nav by language", "untitled:Untitled-2", "html", nav, FileTypeNav},
+		{"Package root", "file:///book/package.xml", "xml", opf, FileTypeOPF},
+		{"HTML root", "untitled:Untitled-3", "", nav, FileTypeNav},
+		{"SVG root", "untitled:Untitled-5", "xml", []byte(`<svg:svg xmlns:svg="http://www.w3.org/2000/svg"/>`), FileTypeSVG},
+		{"Other root", "notes.xml", "xml", []byte(`<notes/>`), FileTypeUnknown},
+		{"Not XML", "untitled:Untitled-4", "plaintext", []byte("x"), FileTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectFileTypeWithLanguage(tt.uri, tt.content, tt.languageID)
+			if got != tt.want {
+				t.Errorf("DetectFileTypeWithLanguage(%q, %q) = %v, want %v",
+					tt.uri, tt.languageID, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestFileTypeString(t *testing.T) {
 	tests := []struct {
 		ft   FileType
@@ -45,6 +94,9 @@ func TestFileTypeString(t *testing.T) {
 		{FileTypeNav, "Nav"},
 		{FileTypeCSS, "CSS"},
 		{FileTypeNCX, "NCX"},
+		{FileTypeContainer, "Container"},
+		{FileTypeEncryption, "Encryption"},
+		{FileTypeSVG, "SVG"},
 		{FileTypeUnknown, "Unknown"},
 	}
 
@@ -0,0 +1,53 @@
+package epub
+
+import "strings"
+
+// Fix anchors name where a FixData edit applies. They are resolved against
+// the current document text when the fix is applied, rather than stored as
+// absolute offsets, so a fix stays valid across small edits elsewhere.
+const (
+	// AnchorBeforeClosingTag inserts InsertText on its own line before the
+	// first closing tag of the named element, as in
+	// "before-closing-tag:metadata". The line is indented like the closing
+	// tag's line and ends with the document's line ending.
+	AnchorBeforeClosingTag = "before-closing-tag:"
+	// AnchorEndOfStartTag inserts InsertText before the ">" or "/>" that
+	// ends the start tag beginning at the diagnostic's start position.
+	AnchorEndOfStartTag = "end-of-start-tag"
+	// AnchorReplaceRange replaces the diagnostic's range with InsertText.
+	AnchorReplaceRange = "replace-range"
+	// AnchorElementContent replaces the content of the element whose start
+	// tag begins at the diagnostic's start position with InsertText. A
+	// self-closing element is given a closing tag.
+	AnchorElementContent = "element-content"
+	// AnchorRenameElement renames the element whose start tag begins at the
+	// diagnostic's start position to InsertText, in its start and end tags.
+	AnchorRenameElement = "rename-element"
+	// AnchorNamespaceDeclaration inserts InsertText into the root
+	// element's start tag, after its last namespace declaration or, when
+	// it has none, its name.
+	AnchorNamespaceDeclaration = "namespace-declaration"
+)
+
+// FixData is the structured fix for an auto-fixable diagnostic, published
+// as the LSP diagnostic's data field so tools can apply fixes without a
+// codeAction request per diagnostic:
+//
+//	{
+//	  "title": "Add alt attribute",
+//	  "anchor": "end-of-start-tag",
+//	  "insertText": " alt=\"\""
+//	}
+type FixData struct {
+	// Title describes the fix for display, e.g. as a code action title.
+	Title string `json:"title"`
+	// Anchor is one of the Anchor* constants.
+	Anchor string `json:"anchor"`
+	// InsertText is the text to insert or to replace the range with.
+	InsertText string `json:"insertText"`
+}
+
+// ClosingTag returns the element name of an AnchorBeforeClosingTag anchor.
+func (f *FixData) ClosingTag() (string, bool) {
+	return strings.CutPrefix(f.Anchor, AnchorBeforeClosingTag)
+}
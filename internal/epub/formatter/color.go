@@ -0,0 +1,455 @@
+package formatter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Color is an RGBA color with components from 0 to 1.
+type Color struct {
+	Red, Green, Blue, Alpha float64
+}
+
+// ColorMatch is a color value found in CSS text.
+type ColorMatch struct {
+	Color
+	Start, End int // byte offsets of the value within the scanned text
+}
+
+// FindColors returns the hex colors, rgb(), rgba(), hsl(), and hsla()
+// functions, and named colors in the declaration values of CSS text,
+// skipping comments, strings, selectors, and at-rule preludes. When inline
+// is true text is a declaration list without braces, such as a style
+// attribute.
+func FindColors(text string, inline bool) []ColorMatch {
+	var colors, pending []ColorMatch
+	depth := 0
+	inValue := false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return append(colors, pending...)
+			}
+			i += end + 3
+		case c == '"' || c == '\'':
+			for i++; i < len(text) && text[i] != c; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+		case c == '{':
+			// The colon belonged to a selector such as a:hover
+			depth++
+			inValue = false
+			pending = pending[:0]
+		case c == '}' || c == ';':
+			if c == '}' && depth > 0 {
+				depth--
+			}
+			inValue = false
+			colors = append(colors, pending...)
+			pending = pending[:0]
+		case c == ':':
+			inValue = depth > 0 || inline
+		case !inValue:
+		case c == '#':
+			end := i + 1
+			for end < len(text) && isCSSNameByte(lower(text[end])) {
+				end++
+			}
+			if color, ok := ParseColor(text[i:end]); ok {
+				pending = append(pending, ColorMatch{color, i, end})
+			}
+			i = end - 1
+		case isCSSNameByte(lower(c)) && (i == 0 || !isCSSNameByte(lower(text[i-1]))):
+			end := i
+			for end < len(text) && isCSSNameByte(lower(text[end])) {
+				end++
+			}
+			if end < len(text) && text[end] == '(' {
+				if close := strings.IndexByte(text[end:], ')'); close >= 0 {
+					end += close + 1
+				}
+			}
+			if color, ok := ParseColor(text[i:end]); ok {
+				pending = append(pending, ColorMatch{color, i, end})
+			}
+			i = end - 1
+		}
+	}
+	return append(colors, pending...)
+}
+
+// ParseColor parses a hex color, an rgb(), rgba(), hsl(), or hsla()
+// function, or a named color.
+func ParseColor(s string) (Color, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if strings.HasPrefix(s, "#") {
+		return parseHex(s[1:])
+	}
+	if open := strings.IndexByte(s, '('); open > 0 && strings.HasSuffix(s, ")") {
+		args, ok := colorArgs(s[open+1 : len(s)-1])
+		if !ok {
+			return Color{}, false
+		}
+		switch s[:open] {
+		case "rgb", "rgba":
+			return parseRGB(args)
+		case "hsl", "hsla":
+			return parseHSL(args)
+		}
+		return Color{}, false
+	}
+	if rgb, ok := namedColors[s]; ok {
+		return Color{
+			Red:   float64(rgb>>16) / 255,
+			Green: float64(rgb>>8&0xff) / 255,
+			Blue:  float64(rgb&0xff) / 255,
+			Alpha: 1,
+		}, true
+	}
+	if s == "transparent" {
+		return Color{}, true
+	}
+	return Color{}, false
+}
+
+// FormatHex returns c as #rrggbb, or #rrggbbaa when it is not opaque.
+func FormatHex(c Color) string {
+	hex := fmt.Sprintf("#%02x%02x%02x", byteOf(c.Red), byteOf(c.Green), byteOf(c.Blue))
+	if byteOf(c.Alpha) < 255 {
+		hex += fmt.Sprintf("%02x", byteOf(c.Alpha))
+	}
+	return hex
+}
+
+// FormatRGB returns c as rgb(), or rgba() when it is not opaque, in the
+// comma-separated form older reading systems understand.
+func FormatRGB(c Color) string {
+	r, g, b := byteOf(c.Red), byteOf(c.Green), byteOf(c.Blue)
+	if byteOf(c.Alpha) < 255 {
+		alpha := strconv.FormatFloat(math.Round(c.Alpha*100)/100, 'f', -1, 64)
+		return fmt.Sprintf("rgba(%d, %d, %d, %s)", r, g, b, alpha)
+	}
+	return fmt.Sprintf("rgb(%d, %d, %d)", r, g, b)
+}
+
+// byteOf scales a component from 0–1 to 0–255.
+func byteOf(v float64) int {
+	return int(math.Round(clamp01(v) * 255))
+}
+
+// parseHex parses the digits of a #rgb, #rgba, #rrggbb, or #rrggbbaa
+// color.
+func parseHex(digits string) (Color, bool) {
+	var parts []string
+	switch len(digits) {
+	case 3, 4:
+		for i := range len(digits) {
+			parts = append(parts, strings.Repeat(digits[i:i+1], 2))
+		}
+	case 6, 8:
+		for i := 0; i < len(digits); i += 2 {
+			parts = append(parts, digits[i:i+2])
+		}
+	default:
+		return Color{}, false
+	}
+
+	values := []float64{0, 0, 0, 1}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return Color{}, false
+		}
+		values[i] = float64(n) / 255
+	}
+	return Color{values[0], values[1], values[2], values[3]}, true
+}
+
+// colorArgs splits the arguments of a color function, which are separated
+// by commas or by spaces with an optional "/" before the alpha.
+func colorArgs(s string) ([]string, bool) {
+	var args []string
+	if strings.Contains(s, ",") {
+		for arg := range strings.SplitSeq(s, ",") {
+			args = append(args, strings.TrimSpace(arg))
+		}
+	} else {
+		color, alpha, slash := strings.Cut(s, "/")
+		args = strings.Fields(color)
+		if slash {
+			args = append(args, strings.TrimSpace(alpha))
+		}
+	}
+	if len(args) != 3 && len(args) != 4 {
+		return nil, false
+	}
+	return args, true
+}
+
+// parseRGB parses red, green, and blue numbers from 0 to 255 or
+// percentages, and an optional alpha.
+func parseRGB(args []string) (Color, bool) {
+	var values [3]float64
+	for i, arg := range args[:3] {
+		if pct, ok := strings.CutSuffix(arg, "%"); ok {
+			n, err := strconv.ParseFloat(pct, 64)
+			if err != nil {
+				return Color{}, false
+			}
+			values[i] = clamp01(n / 100)
+			continue
+		}
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return Color{}, false
+		}
+		values[i] = clamp01(n / 255)
+	}
+	alpha, ok := parseAlpha(args)
+	return Color{values[0], values[1], values[2], alpha}, ok
+}
+
+// parseHSL parses a hue in degrees or with an angle unit, saturation and
+// lightness percentages, and an optional alpha.
+func parseHSL(args []string) (Color, bool) {
+	hue, ok := parseHue(args[0])
+	if !ok {
+		return Color{}, false
+	}
+	var sl [2]float64
+	for i, arg := range args[1:3] {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+		if err != nil {
+			return Color{}, false
+		}
+		sl[i] = clamp01(n / 100)
+	}
+	alpha, ok := parseAlpha(args)
+	if !ok {
+		return Color{}, false
+	}
+
+	// CSS Color 4, section 7.1
+	sat, light := sl[0], sl[1]
+	channel := func(n float64) float64 {
+		k := math.Mod(n+hue/30, 12)
+		a := sat * math.Min(light, 1-light)
+		return light - a*math.Max(-1, math.Min(math.Min(k-3, 9-k), 1))
+	}
+	return Color{channel(0), channel(8), channel(4), alpha}, true
+}
+
+// parseHue returns a hue in degrees from 0 up to 360.
+func parseHue(s string) (float64, bool) {
+	scale := 1.0
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{{"deg", 1}, {"grad", 0.9}, {"rad", 180 / math.Pi}, {"turn", 360}} {
+		if trimmed, ok := strings.CutSuffix(s, unit.suffix); ok {
+			s, scale = trimmed, unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	hue := math.Mod(n*scale, 360)
+	if hue < 0 {
+		hue += 360
+	}
+	return hue, true
+}
+
+// parseAlpha returns the fourth argument as a number or percentage, or 1
+// when there is none.
+func parseAlpha(args []string) (float64, bool) {
+	if len(args) < 4 {
+		return 1, true
+	}
+	arg := args[3]
+	scale := 1.0
+	if pct, ok := strings.CutSuffix(arg, "%"); ok {
+		arg, scale = pct, 100
+	}
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, false
+	}
+	return clamp01(n / scale), true
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func isCSSNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+// namedColors maps the CSS named colors to their 0xRRGGBB values.
+var namedColors = map[string]uint32{
+	"aliceblue":            0xf0f8ff,
+	"antiquewhite":         0xfaebd7,
+	"aqua":                 0x00ffff,
+	"aquamarine":           0x7fffd4,
+	"azure":                0xf0ffff,
+	"beige":                0xf5f5dc,
+	"bisque":               0xffe4c4,
+	"black":                0x000000,
+	"blanchedalmond":       0xffebcd,
+	"blue":                 0x0000ff,
+	"blueviolet":           0x8a2be2,
+	"brown":                0xa52a2a,
+	"burlywood":            0xdeb887,
+	"cadetblue":            0x5f9ea0,
+	"chartreuse":           0x7fff00,
+	"chocolate":            0xd2691e,
+	"coral":                0xff7f50,
+	"cornflowerblue":       0x6495ed,
+	"cornsilk":             0xfff8dc,
+	"crimson":              0xdc143c,
+	"cyan":                 0x00ffff,
+	"darkblue":             0x00008b,
+	"darkcyan":             0x008b8b,
+	"darkgoldenrod":        0xb8860b,
+	"darkgray":             0xa9a9a9,
+	"darkgreen":            0x006400,
+	"darkgrey":             0xa9a9a9,
+	"darkkhaki":            0xbdb76b,
+	"darkmagenta":          0x8b008b,
+	"darkolivegreen":       0x556b2f,
+	"darkorange":           0xff8c00,
+	"darkorchid":           0x9932cc,
+	"darkred":              0x8b0000,
+	"darksalmon":           0xe9967a,
+	"darkseagreen":         0x8fbc8f,
+	"darkslateblue":        0x483d8b,
+	"darkslategray":        0x2f4f4f,
+	"darkslategrey":        0x2f4f4f,
+	"darkturquoise":        0x00ced1,
+	"darkviolet":           0x9400d3,
+	"deeppink":             0xff1493,
+	"deepskyblue":          0x00bfff,
+	"dimgray":              0x696969,
+	"dimgrey":              0x696969,
+	"dodgerblue":           0x1e90ff,
+	"firebrick":            0xb22222,
+	"floralwhite":          0xfffaf0,
+	"forestgreen":          0x228b22,
+	"fuchsia":              0xff00ff,
+	"gainsboro":            0xdcdcdc,
+	"ghostwhite":           0xf8f8ff,
+	"gold":                 0xffd700,
+	"goldenrod":            0xdaa520,
+	"gray":                 0x808080,
+	"green":                0x008000,
+	"greenyellow":          0xadff2f,
+	"grey":                 0x808080,
+	"honeydew":             0xf0fff0,
+	"hotpink":              0xff69b4,
+	"indianred":            0xcd5c5c,
+	"indigo":               0x4b0082,
+	"ivory":                0xfffff0,
+	"khaki":                0xf0e68c,
+	"lavender":             0xe6e6fa,
+	"lavenderblush":        0xfff0f5,
+	"lawngreen":            0x7cfc00,
+	"lemonchiffon":         0xfffacd,
+	"lightblue":            0xadd8e6,
+	"lightcoral":           0xf08080,
+	"lightcyan":            0xe0ffff,
+	"lightgoldenrodyellow": 0xfafad2,
+	"lightgray":            0xd3d3d3,
+	"lightgreen":           0x90ee90,
+	"lightgrey":            0xd3d3d3,
+	"lightpink":            0xffb6c1,
+	"lightsalmon":          0xffa07a,
+	"lightseagreen":        0x20b2aa,
+	"lightskyblue":         0x87cefa,
+	"lightslategray":       0x778899,
+	"lightslategrey":       0x778899,
+	"lightsteelblue":       0xb0c4de,
+	"lightyellow":          0xffffe0,
+	"lime":                 0x00ff00,
+	"limegreen":            0x32cd32,
+	"linen":                0xfaf0e6,
+	"magenta":              0xff00ff,
+	"maroon":               0x800000,
+	"mediumaquamarine":     0x66cdaa,
+	"mediumblue":           0x0000cd,
+	"mediumorchid":         0xba55d3,
+	"mediumpurple":         0x9370db,
+	"mediumseagreen":       0x3cb371,
+	"mediumslateblue":      0x7b68ee,
+	"mediumspringgreen":    0x00fa9a,
+	"mediumturquoise":      0x48d1cc,
+	"mediumvioletred":      0xc71585,
+	"midnightblue":         0x191970,
+	"mintcream":            0xf5fffa,
+	"mistyrose":            0xffe4e1,
+	"moccasin":             0xffe4b5,
+	"navajowhite":          0xffdead,
+	"navy":                 0x000080,
+	"oldlace":              0xfdf5e6,
+	"olive":                0x808000,
+	"olivedrab":            0x6b8e23,
+	"orange":               0xffa500,
+	"orangered":            0xff4500,
+	"orchid":               0xda70d6,
+	"palegoldenrod":        0xeee8aa,
+	"palegreen":            0x98fb98,
+	"paleturquoise":        0xafeeee,
+	"palevioletred":        0xdb7093,
+	"papayawhip":           0xffefd5,
+	"peachpuff":            0xffdab9,
+	"peru":                 0xcd853f,
+	"pink":                 0xffc0cb,
+	"plum":                 0xdda0dd,
+	"powderblue":           0xb0e0e6,
+	"purple":               0x800080,
+	"rebeccapurple":        0x663399,
+	"red":                  0xff0000,
+	"rosybrown":            0xbc8f8f,
+	"royalblue":            0x4169e1,
+	"saddlebrown":          0x8b4513,
+	"salmon":               0xfa8072,
+	"sandybrown":           0xf4a460,
+	"seagreen":             0x2e8b57,
+	"seashell":             0xfff5ee,
+	"sienna":               0xa0522d,
+	"silver":               0xc0c0c0,
+	"skyblue":              0x87ceeb,
+	"slateblue":            0x6a5acd,
+	"slategray":            0x708090,
+	"slategrey":            0x708090,
+	"snow":                 0xfffafa,
+	"springgreen":          0x00ff7f,
+	"steelblue":            0x4682b4,
+	"tan":                  0xd2b48c,
+	"teal":                 0x008080,
+	"thistle":              0xd8bfd8,
+	"tomato":               0xff6347,
+	"turquoise":            0x40e0d0,
+	"violet":               0xee82ee,
+	"wheat":                0xf5deb3,
+	"white":                0xffffff,
+	"whitesmoke":           0xf5f5f5,
+	"yellow":               0xffff00,
+	"yellowgreen":          0x9acd32,
+}
@@ -0,0 +1,134 @@
+package formatter
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Color
+		ok    bool
+	}{
+		{"#f00", Color{1, 0, 0, 1}, true},
+		{"#F00", Color{1, 0, 0, 1}, true},
+		{"#0f08", Color{0, 1, 0, 0x88 / 255.0}, true},
+		{"#0000ff", Color{0, 0, 1, 1}, true},
+		{"#00000080", Color{0, 0, 0, 128 / 255.0}, true},
+		{"#12345", Color{}, false},
+		{"#ggg", Color{}, false},
+		{"rgb(255, 0, 0)", Color{1, 0, 0, 1}, true},
+		{"rgb(100%, 50%, 0%)", Color{1, 0.5, 0, 1}, true},
+		{"RGB(0 255 0)", Color{0, 1, 0, 1}, true},
+		{"rgba(0, 0, 255, 0.5)", Color{0, 0, 1, 0.5}, true},
+		{"rgb(0 0 255 / 25%)", Color{0, 0, 1, 0.25}, true},
+		{"rgb(300, -5, 0)", Color{1, 0, 0, 1}, true},
+		{"rgb(1, 2)", Color{}, false},
+		{"rgb(a, b, c)", Color{}, false},
+		{"hsl(0, 100%, 50%)", Color{1, 0, 0, 1}, true},
+		{"hsl(120 100% 25%)", Color{0, 0.5, 0, 1}, true},
+		{"hsla(240, 100%, 50%, 0.5)", Color{0, 0, 1, 0.5}, true},
+		{"hsl(0.5turn, 100%, 50%)", Color{0, 1, 1, 1}, true},
+		{"hsl(-120deg, 100%, 50%)", Color{0, 0, 1, 1}, true},
+		{"red", Color{1, 0, 0, 1}, true},
+		{"RebeccaPurple", Color{0x66 / 255.0, 0x33 / 255.0, 0x99 / 255.0, 1}, true},
+		{"transparent", Color{0, 0, 0, 0}, true},
+		{"inherit", Color{}, false},
+		{"lab(50% 0 0)", Color{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := ParseColor(tt.input)
+			if ok != tt.ok {
+				t.Fatalf("ParseColor(%q) ok = %v, want %v", tt.input, ok, tt.ok)
+			}
+			if ok && !colorsEqual(got, tt.want) {
+				t.Errorf("ParseColor(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatColor(t *testing.T) {
+	tests := []struct {
+		color   Color
+		hex     string
+		rgbText string
+	}{
+		{Color{1, 0, 0, 1}, "#ff0000", "rgb(255, 0, 0)"},
+		{Color{0, 0.5, 1, 1}, "#0080ff", "rgb(0, 128, 255)"},
+		{Color{0, 0, 0, 0.5}, "#00000080", "rgba(0, 0, 0, 0.5)"},
+		{Color{1, 1, 1, 0}, "#ffffff00", "rgba(255, 255, 255, 0)"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatHex(tt.color); got != tt.hex {
+			t.Errorf("FormatHex(%+v) = %q, want %q", tt.color, got, tt.hex)
+		}
+		if got := FormatRGB(tt.color); got != tt.rgbText {
+			t.Errorf("FormatRGB(%+v) = %q, want %q", tt.color, got, tt.rgbText)
+		}
+	}
+}
+
+func TestFindColors(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		inline bool
+		want   []string
+	}{
+		{
+			name: "declaration values",
+			text: "p { color: #333; background: rgba(0, 0, 0, 0.1) }",
+			want: []string{"#333", "rgba(0, 0, 0, 0.1)"},
+		},
+		{
+			name: "selectors and preludes",
+			text: "#add, .red:hover { color: red }\n@media (min-width: 30em) { a:visited { color: navy } }",
+			want: []string{"red", "navy"},
+		},
+		{
+			name: "comments strings and urls",
+			text: `p { /* color: red */ font-family: "Red Serif", blue; background: url(#fff) }`,
+			want: []string{"blue"},
+		},
+		{
+			name: "longer identifiers",
+			text: "p { border: 1px solid darkred; list-style: red-dots }",
+			want: []string{"darkred"},
+		},
+		{
+			name:   "style attribute",
+			text:   "color: hsl(120, 100%, 25%); border-color: Teal",
+			inline: true,
+			want:   []string{"hsl(120, 100%, 25%)", "Teal"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := FindColors(tt.text, tt.inline)
+			var got []string
+			for _, m := range matches {
+				got = append(got, tt.text[m.Start:m.End])
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("FindColors = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("match %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func colorsEqual(a, b Color) bool {
+	const eps = 0.005
+	return math.Abs(a.Red-b.Red) < eps && math.Abs(a.Green-b.Green) < eps &&
+		math.Abs(a.Blue-b.Blue) < eps && math.Abs(a.Alpha-b.Alpha) < eps
+}
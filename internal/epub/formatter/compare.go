@@ -0,0 +1,122 @@
+package formatter
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// ErrContentChanged reports formatted output whose element tree differs from
+// the original's, so that using it would change the document's content.
+var ErrContentChanged = errors.New("formatting would change the document's content")
+
+// CheckRoundTrip parses original and formatted and returns an error
+// wrapping ErrContentChanged, naming the first divergence, when their
+// element trees differ as CompareTrees sees them. It returns nil when
+// original is not well-formed, since there is then no tree to keep.
+func CheckRoundTrip(original []byte, formatted string) error {
+	original, _ = epub.StripBOM(original)
+	output, _ := epub.StripBOM([]byte(formatted))
+	before, diags := parser.Parse(original)
+	if len(diags) > 0 {
+		return nil
+	}
+	after, diags := parser.Parse(output)
+	if len(diags) > 0 {
+		return fmt.Errorf("%w: output is not well-formed", ErrContentChanged)
+	}
+	if divergence := CompareTrees(before, after); divergence != "" {
+		return fmt.Errorf("%w: %s", ErrContentChanged, divergence)
+	}
+	return nil
+}
+
+// CompareTrees compares the element names, attributes, and text of the
+// trees under a and b, ignoring attribute order and differences in
+// whitespace within text. It returns the first divergence as an element
+// path such as /html/body/p[2] followed by what differs, or an empty
+// string when the trees are equal.
+func CompareTrees(a, b *parser.XMLNode) string {
+	return compareNodes(a, b, "")
+}
+
+// compareNodes compares a and b, found at path, and then their children.
+func compareNodes(a, b *parser.XMLNode, path string) string {
+	switch {
+	case a.Space != b.Space || a.Local != b.Local:
+		return path + ": element " + a.Local + " became " + b.Local
+	case normalizeText(a.CharData) != normalizeText(b.CharData):
+		return path + ": text"
+	}
+	if name := attrDifference(a, b); name != "" {
+		return path + ": attribute " + name
+	}
+	if len(a.Children) != len(b.Children) {
+		return path + ": " + strconv.Itoa(len(a.Children)) + " child elements became " +
+			strconv.Itoa(len(b.Children))
+	}
+
+	seen := make(map[string]int)
+	for i, child := range a.Children {
+		seen[child.Local]++
+		childPath := path + "/" + child.Local
+		if n := seen[child.Local]; n > 1 || siblingCount(a, child.Local) > 1 {
+			childPath += "[" + strconv.Itoa(n) + "]"
+		}
+		if divergence := compareNodes(child, b.Children[i], childPath); divergence != "" {
+			return divergence
+		}
+	}
+	return ""
+}
+
+// attrDifference returns the name of the first attribute, in sorted order,
+// that a and b do not share with the same value, or an empty string.
+func attrDifference(a, b *parser.XMLNode) string {
+	before, after := attrMap(a), attrMap(b)
+	union := maps.Clone(before)
+	maps.Copy(union, after)
+	for _, name := range slices.Sorted(maps.Keys(union)) {
+		value, ok := after[name]
+		if !ok || value != before[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+// attrMap returns the attributes of n keyed by namespace and local name.
+func attrMap(n *parser.XMLNode) map[string]string {
+	attrs := make(map[string]string, len(n.Attrs))
+	for _, attr := range n.Attrs {
+		name := attr.Local
+		if attr.Space != "" {
+			name = "{" + attr.Space + "}" + name
+		}
+		attrs[name] = attr.Value
+	}
+	return attrs
+}
+
+// siblingCount returns the number of children of n named local.
+func siblingCount(n *parser.XMLNode, local string) int {
+	count := 0
+	for _, child := range n.Children {
+		if child.Local == local {
+			count++
+		}
+	}
+	return count
+}
+
+// normalizeText collapses each run of whitespace in s to a single space
+// and trims it.
+func normalizeText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,104 @@
+package formatter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// compare parses a and b and returns CompareTrees of the two.
+func compare(t *testing.T, a, b string) string {
+	t.Helper()
+	before, diags := parser.Parse([]byte(a))
+	if len(diags) > 0 {
+		t.Fatalf("parse %q: %v", a, diags)
+	}
+	after, diags := parser.Parse([]byte(b))
+	if len(diags) > 0 {
+		t.Fatalf("parse %q: %v", b, diags)
+	}
+	return CompareTrees(before, after)
+}
+
+func TestCompareTrees(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			"attribute order",
+			`<html><body><p id="a" class="x">Text</p></body></html>`,
+			`<html><body><p class="x" id="a">Text</p></body></html>`,
+			"",
+		},
+		{
+			"whitespace only",
+			`<html><body><p>Some   text</p><p>More</p></body></html>`,
+			"<html>\n  <body>\n    <p>\n      Some\n      text\n    </p>\n    <p>More</p>\n  </body>\n</html>\n",
+			"",
+		},
+		{
+			"dropped attribute",
+			`<html><body><p>One</p><p id="a" class="x">Two</p></body></html>`,
+			`<html><body><p>One</p><p id="a">Two</p></body></html>`,
+			"/html/body/p[2]: attribute class",
+		},
+		{
+			"changed attribute value",
+			`<html><body><a href="a.xhtml">Link</a></body></html>`,
+			`<html><body><a href="b.xhtml">Link</a></body></html>`,
+			"/html/body/a: attribute href",
+		},
+		{
+			"swallowed text",
+			`<html><body><p>Kept</p><p>Lost</p></body></html>`,
+			`<html><body><p>Kept</p><p></p></body></html>`,
+			"/html/body/p[2]: text",
+		},
+		{
+			"space inserted inside a word",
+			`<html><body><p>a<![CDATA[b]]>c</p></body></html>`,
+			"<html><body><p>\na\n<![CDATA[b]]>\nc\n</p></body></html>",
+			"/html/body/p: text",
+		},
+		{
+			"dropped element",
+			`<html><body><p>One</p><hr/></body></html>`,
+			`<html><body><p>One</p></body></html>`,
+			"/html/body: 2 child elements became 1",
+		},
+	}
+	for _, tc := range cases {
+		if got := compare(t, tc.a, tc.b); got != tc.want {
+			t.Errorf("%s: CompareTrees = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckRoundTrip(t *testing.T) {
+	original := []byte(`<html><body><div>x<!-- note -->y</div></body></html>`)
+	formatted, err := FormatXML(original, "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CheckRoundTrip(original, formatted)
+	if !errors.Is(err, ErrContentChanged) || !strings.Contains(err.Error(), "/html/body/div") {
+		t.Errorf("CheckRoundTrip = %v, want ErrContentChanged at /html/body/div", err)
+	}
+
+	original = []byte(`<html><body><p class="a">Text</p></body></html>`)
+	formatted, err = FormatXML(original, "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckRoundTrip(original, formatted); err != nil {
+		t.Errorf("CheckRoundTrip = %v, want nil", err)
+	}
+
+	if err := CheckRoundTrip(original, "<html><body>"); !errors.Is(err, ErrContentChanged) {
+		t.Errorf("CheckRoundTrip of malformed output = %v, want ErrContentChanged", err)
+	}
+}
@@ -3,11 +3,15 @@ package formatter
 import (
 	"strings"
 
+	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
 )
 
 // FormatCSS reformats CSS content with consistent indentation.
+// Output uses the dominant line ending of the input and keeps a leading
+// byte order mark.
 func FormatCSS(content []byte, indent string) (string, error) {
+	content, bom := epub.StripBOM(content)
 	tok := parser.NewCSSTokenizer(content)
 	var buf strings.Builder
 	depth := 0
@@ -65,29 +69,15 @@ func FormatCSS(content []byte, indent string) (string, error) {
 					needNewline = true
 				}
 			} else {
-				// Outside a block: selector
-				if needNewline {
-					buf.WriteByte('\n')
-					needNewline = false
-				}
-				writeIndent(&buf, indent, depth)
-				buf.WriteString(t.Value)
+				writeSelector(&buf, content, tok, t, indent, &depth, needNewline)
+				needNewline = false
+			}
 
-				// Collect remaining selector parts until brace open
-				for {
-					next := tok.Next()
-					if next.Type == parser.CSSTokenBraceOpen {
-						buf.WriteString(" {\n")
-						depth++
-						needNewline = false
-						break
-					}
-					if next.Type == parser.CSSTokenEOF {
-						break
-					}
-					buf.WriteByte(' ')
-					buf.WriteString(next.Value)
-				}
+		case parser.CSSTokenColon:
+			// A selector starting with a pseudo-class, such as :root
+			if depth == 0 {
+				writeSelector(&buf, content, tok, t, indent, &depth, needNewline)
+				needNewline = false
 			}
 
 		case parser.CSSTokenBraceClose:
@@ -117,7 +107,37 @@ func FormatCSS(content []byte, indent string) (string, error) {
 		result += "\n"
 	}
 
-	return result, nil
+	return withBOM(useLineEnding(result, epub.DetectLineEnding(content)), bom), nil
+}
+
+// writeSelector writes the selector starting at first up to its opening
+// brace, taking the text from content so that pseudo-classes and function
+// arguments keep their spelling, with runs of whitespace collapsed.
+func writeSelector(
+	buf *strings.Builder,
+	content []byte,
+	tok *parser.CSSTokenizer,
+	first parser.CSSToken,
+	indent string,
+	depth *int,
+	needNewline bool,
+) {
+	if needNewline {
+		buf.WriteByte('\n')
+	}
+	writeIndent(buf, indent, *depth)
+
+	for {
+		next := tok.Next()
+		if next.Type == parser.CSSTokenBraceOpen || next.Type == parser.CSSTokenEOF {
+			buf.WriteString(strings.Join(strings.Fields(string(content[first.Offset:next.Offset])), " "))
+			if next.Type == parser.CSSTokenBraceOpen {
+				buf.WriteString(" {\n")
+				*depth++
+			}
+			return
+		}
+	}
 }
 
 func writeIndent(buf *strings.Builder, indent string, depth int) {
@@ -3,6 +3,9 @@ package formatter
 import (
 	"strings"
 	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
 )
 
 func TestFormatCSS_BasicFormatting(t *testing.T) {
@@ -39,6 +42,36 @@ func TestFormatCSS_AtRules(t *testing.T) {
 	}
 }
 
+func TestFormatCSS_CustomProperties(t *testing.T) {
+	input := []byte(`:root{--brand-color:#336;--gap:  1em;--link:var(--brand-color,#000);}
+a:hover, p::first-line{color:var(--link, #000);background:url(http://example.com/a.png);}`)
+	want := `:root {
+  --brand-color: #336;
+  --gap: 1em;
+  --link: var(--brand-color,#000);
+}
+a:hover, p::first-line {
+  color: var(--link, #000);
+  background: url(http://example.com/a.png);
+}
+`
+	result, err := FormatCSS(input, "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != want {
+		t.Errorf("got:\n%s\nwant:\n%s", result, want)
+	}
+
+	again, err := FormatCSS([]byte(result), "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again != result {
+		t.Errorf("formatting is not stable, second pass:\n%s", again)
+	}
+}
+
 func TestFormatCSS_TabIndent(t *testing.T) {
 	input := []byte(`p{margin:0;}`)
 	result, err := FormatCSS(input, "\t")
@@ -87,3 +120,43 @@ body{color:red;}`)
 		t.Error("expected comment to be preserved")
 	}
 }
+
+func TestFormatCSS_PreservesCRLF(t *testing.T) {
+	inputs := []string{
+		"body{color:red;font-size:12px;}",
+		"@charset \"utf-8\";\n@font-face{font-family:\"Test\";}",
+		"/* comment */\nh1, h2 {\n  margin: 0;\n}\n",
+	}
+
+	for _, input := range inputs {
+		lf, err := FormatCSS([]byte(input), "  ")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		crlf, err := FormatCSS(testutil.CRLF([]byte(input)), "  ")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Input without any line break has no dominant CRLF ending.
+		if !strings.Contains(input, "\n") {
+			if crlf != lf {
+				t.Errorf("expected LF output for single-line input, got %q", crlf)
+			}
+			continue
+		}
+		if want := string(testutil.CRLF([]byte(lf))); crlf != want {
+			t.Errorf("CRLF output mismatch\nexpected:\n%q\ngot:\n%q", want, crlf)
+		}
+	}
+}
+
+func TestFormatCSS_PreservesBOM(t *testing.T) {
+	result, err := FormatCSS([]byte(epub.BOM+"p{color:red}"), "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := epub.BOM + "p {\n  color: red;\n}\n"; result != want {
+		t.Errorf("got %q, want %q", result, want)
+	}
+}
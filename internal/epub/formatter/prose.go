@@ -0,0 +1,157 @@
+package formatter
+
+import (
+	"strings"
+)
+
+// ProseWrap decides how FormatXMLWrapped lays out the text of prose blocks:
+// paragraphs, list items, block quotes, definitions, and figure captions
+// holding only text and inline elements.
+type ProseWrap string
+
+const (
+	// ProseWrapOff puts each run of text and each inline element on a
+	// line of its own, as FormatXML does.
+	ProseWrapOff ProseWrap = "off"
+	// ProseWrapPreserve keeps the line breaks of the text, with inline
+	// elements left where they are, and only re-indents each line.
+	ProseWrapPreserve ProseWrap = "preserve"
+	// ProseWrapSentence starts each sentence on a new line, which keeps
+	// diffs of translated prose to the sentences that changed.
+	ProseWrapSentence ProseWrap = "sentence"
+)
+
+// proseElements lists the block elements whose text is laid out as prose.
+var proseElements = map[string]bool{
+	"p":          true,
+	"li":         true,
+	"blockquote": true,
+	"dd":         true,
+	"figcaption": true,
+}
+
+// phrasingElements lists the inline elements a prose block may contain.
+// A block with any other element is formatted as usual.
+var phrasingElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true,
+	"br": true, "cite": true, "code": true, "data": true, "del": true,
+	"dfn": true, "em": true, "i": true, "img": true, "ins": true,
+	"kbd": true, "mark": true, "q": true, "rp": true, "rt": true,
+	"ruby": true, "s": true, "samp": true, "small": true, "span": true,
+	"strong": true, "sub": true, "sup": true, "time": true, "u": true,
+	"var": true, "wbr": true,
+}
+
+// proseLayout returns the lines of the prose block opened by the start
+// tag at i, and the index of its end tag. ok is false when wrap is off,
+// the element is not a prose block, or the block fits the single line
+// isInlineElement gives it.
+func proseLayout(tokens []xmlToken, i int, wrap ProseWrap) (lines []string, end int, ok bool) {
+	if wrap != ProseWrapPreserve && wrap != ProseWrapSentence ||
+		!proseElements[localName(tokens[i].name)] {
+		return nil, 0, false
+	}
+	end = matchingEndTag(tokens, i)
+	if end == len(tokens) {
+		return nil, 0, false
+	}
+
+	var flat strings.Builder
+	for _, tok := range tokens[i+1 : end] {
+		switch tok.kind {
+		case tokCharData:
+			if strings.Contains(tok.raw, "{{") {
+				return nil, 0, false // template actions keep their own lines
+			}
+			if wrap == ProseWrapSentence {
+				flat.WriteString(collapseSpace(tok.raw))
+			} else {
+				flat.WriteString(tok.raw)
+			}
+		case tokStartTag, tokEndTag, tokSelfClosing:
+			if !phrasingElements[localName(tok.name)] {
+				return nil, 0, false
+			}
+			flat.WriteString(normalizeTag(tok.raw))
+		default:
+			return nil, 0, false
+		}
+	}
+
+	if wrap == ProseWrapSentence {
+		lines = splitProse(strings.TrimSpace(flat.String()))
+	} else {
+		for line := range strings.SplitSeq(flat.String(), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) == 0 || len(lines) == 1 && isInlineElement(tokens, i) {
+		return nil, 0, false
+	}
+	return lines, end, true
+}
+
+// splitProse splits text, which may contain inline tags, into sentences.
+// Tags are skipped when finding sentence ends and stay with the text
+// beside them.
+func splitProse(text string) []string {
+	// Find the breaks in the text with the tags taken out, and map them
+	// back to offsets in text
+	raw := []byte(text)
+	var plain []byte
+	var offsets []int
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '<' {
+			i = scanTagEnd(raw, i) - 1
+			continue
+		}
+		plain = append(plain, raw[i])
+		offsets = append(offsets, i)
+	}
+
+	var lines []string
+	start := 0
+	for _, b := range sentenceBreaks(string(plain)) {
+		at := offsets[b]
+		if line := strings.TrimSpace(text[start:at]); line != "" {
+			lines = append(lines, line)
+		}
+		start = at
+	}
+	if line := strings.TrimSpace(text[start:]); line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// collapseSpace replaces each run of whitespace in s with one space.
+func collapseSpace(s string) string {
+	var buf strings.Builder
+	buf.Grow(len(s))
+	space := false
+	for i := range len(s) {
+		if isSpace(s[i]) {
+			space = true
+			continue
+		}
+		if space {
+			buf.WriteByte(' ')
+			space = false
+		}
+		buf.WriteByte(s[i])
+	}
+	if space {
+		buf.WriteByte(' ')
+	}
+	return buf.String()
+}
+
+// localName returns an element name without its prefix, lowercased.
+func localName(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,126 @@
+package formatter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// abbreviations lists words, lowercased and without their final period,
+// after which a period does not end a sentence.
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true,
+	"st": true, "sr": true, "jr": true, "rev": true, "gen": true,
+	"capt": true, "lt": true, "col": true, "sgt": true, "hon": true,
+	"e.g": true, "i.e": true, "cf": true, "vs": true, "viz": true,
+	"no": true, "nos": true, "vol": true, "vols": true, "fig": true,
+	"figs": true, "p": true, "pp": true, "ch": true, "chap": true,
+	"ed": true, "eds": true, "approx": true, "inc": true, "ltd": true,
+	"co": true, "corp": true, "mt": true, "ft": true, "a.m": true,
+	"p.m": true,
+}
+
+// closingPunct lists the characters that may follow a sentence's final
+// punctuation and still belong to the sentence.
+const closingPunct = `"')]”’»`
+
+// SplitSentences splits text into sentences, each with surrounding
+// whitespace removed. A sentence ends at ".", "?", or "!", optionally
+// followed by closing quotes or brackets, then whitespace. A period does
+// not end a sentence after a common abbreviation such as "e.g." or "Mr.",
+// after an initial such as "J.", after a leading list number such as
+// "1.", or when a digit follows, as in "p. 5". No sentence ends before a
+// word starting with a lowercase letter.
+func SplitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, b := range sentenceBreaks(text) {
+		if s := strings.TrimSpace(text[start:b]); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = b
+	}
+	if s := strings.TrimSpace(text[start:]); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// sentenceBreaks returns the offsets in text of the whitespace following
+// each sentence but the last.
+func sentenceBreaks(text string) []int {
+	var breaks []int
+	sentenceStart := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c != '.' && c != '?' && c != '!' {
+			continue
+		}
+
+		// Take in runs such as "?!" or "..." and closing punctuation
+		end := i + 1
+		for end < len(text) && strings.IndexByte(".?!", text[end]) >= 0 {
+			end++
+		}
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !strings.ContainsRune(closingPunct, r) {
+				break
+			}
+			end += size
+		}
+		if end >= len(text) || !isSpace(text[end]) {
+			i = end - 1
+			continue
+		}
+
+		next := end
+		for next < len(text) && isSpace(text[next]) {
+			next++
+		}
+		if next == len(text) {
+			break
+		}
+		r, _ := utf8.DecodeRuneInString(text[next:])
+		if unicode.IsLower(r) ||
+			c == '.' && end == i+1 && periodContinues(text[sentenceStart:i], r) {
+			i = end - 1
+			continue
+		}
+
+		breaks = append(breaks, end)
+		sentenceStart = next
+		i = next - 1
+	}
+	return breaks
+}
+
+// periodContinues reports whether a period after before, the sentence so
+// far, and followed by a word starting with next is not the end of the
+// sentence.
+func periodContinues(before string, next rune) bool {
+	if unicode.IsDigit(next) {
+		return true
+	}
+	word := before[strings.LastIndexFunc(before, unicode.IsSpace)+1:]
+	word = strings.TrimLeft(word, `"'([“‘«`)
+	if word == "" {
+		return false
+	}
+	if abbreviations[strings.ToLower(word)] {
+		return true
+	}
+	if r, size := utf8.DecodeRuneInString(word); size == len(word) && unicode.IsUpper(r) {
+		return true // an initial
+	}
+	// A list number starting the sentence, as in "1. First"
+	return strings.TrimSpace(before) == word && strings.IndexFunc(word, isNotDigit) < 0
+}
+
+func isNotDigit(r rune) bool {
+	return r < '0' || r > '9'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
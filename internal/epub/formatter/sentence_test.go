@@ -0,0 +1,51 @@
+package formatter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"whitespace only", " \n\t ", nil},
+		{"single sentence", "One sentence.", []string{"One sentence."}},
+		{"no final punctuation", "No ending", []string{"No ending"}},
+		{"two sentences", "First one. Second one.", []string{"First one.", "Second one."}},
+		{"question and exclamation", "Who? Me! Yes.", []string{"Who?", "Me!", "Yes."}},
+		{"runs of punctuation", "Really?! Yes... Fine.", []string{"Really?!", "Yes...", "Fine."}},
+		{"newlines between", "First.\n   Second.", []string{"First.", "Second."}},
+		{"closing quote", `He said "Stop." Then left.`, []string{`He said "Stop."`, "Then left."}},
+		{"closing curly quote", "She asked “Why?” Nobody knew.", []string{"She asked “Why?”", "Nobody knew."}},
+		{"closing parenthesis", "It works (mostly.) Try it.", []string{"It works (mostly.)", "Try it."}},
+		{"e.g.", "Use a tool, e.g. Sigil. It helps.", []string{"Use a tool, e.g. Sigil.", "It helps."}},
+		{"i.e. uppercase", "One format, i.e. EPUB, is used.", []string{"One format, i.e. EPUB, is used."}},
+		{"title", "Mr. Smith arrived. Dr. Jones left.", []string{"Mr. Smith arrived.", "Dr. Jones left."}},
+		{"title uppercase", "MRS. Brown waved.", []string{"MRS. Brown waved."}},
+		{"abbreviation in parentheses", "See the chart (Fig. 3) below.", []string{"See the chart (Fig. 3) below."}},
+		{"initials", "J. R. R. Tolkien wrote it.", []string{"J. R. R. Tolkien wrote it."}},
+		{"digit follows", "See p. 5 and ch. 2 for more.", []string{"See p. 5 and ch. 2 for more."}},
+		{"number follows sentence", "It ended. 1984 came next.", []string{"It ended. 1984 came next."}},
+		{"decimal", "Pi is 3.14 roughly. Yes.", []string{"Pi is 3.14 roughly.", "Yes."}},
+		{"year ends sentence", "It was 1984. Then it wasn't.", []string{"It was 1984.", "Then it wasn't."}},
+		{"list number", "1. First item", []string{"1. First item"}},
+		{"lowercase follows", "Apples, pears, etc. and more.", []string{"Apples, pears, etc. and more."}},
+		{"etc. ends sentence", "Apples, pears, etc. Then figs.", []string{"Apples, pears, etc.", "Then figs."}},
+		{"no space after period", "Visit example.com. Now.", []string{"Visit example.com.", "Now."}},
+		{"trailing whitespace", "Done.  ", []string{"Done."}},
+		{"non-ASCII start", "Fin. Élan follows.", []string{"Fin.", "Élan follows."}},
+		{"quote opens next sentence", `Done. "Next," she said.`, []string{"Done.", `"Next," she said.`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitSentences(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("SplitSentences(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
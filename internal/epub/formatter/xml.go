@@ -8,6 +8,8 @@ import (
 	"io"
 	"regexp"
 	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
 )
 
 var (
@@ -27,8 +29,20 @@ const (
 	tokEndTag
 	tokSelfClosing
 	tokCharData
+	tokCDATA
 )
 
+// preservedElements lists the elements whose content is whitespace
+// sensitive, and so is written verbatim, as with xml:space="preserve".
+var preservedElements = map[string]bool{
+	"pre":      true,
+	"script":   true,
+	"style":    true,
+	"textarea": true,
+}
+
+var xmlSpacePreserveRe = regexp.MustCompile(`\sxml:space\s*=\s*["']preserve["']`)
+
 type xmlToken struct {
 	kind xmlTokenKind
 	raw  string
@@ -37,13 +51,43 @@ type xmlToken struct {
 
 // FormatXML reformats XML content with consistent indentation.
 // It preserves namespace declarations, self-closing tags, and DOCTYPE formatting.
+// CDATA sections, and the content of pre, script, style, and textarea
+// elements or of elements with xml:space="preserve", are kept verbatim.
+// Output uses the dominant line ending of the input and keeps a leading
+// byte order mark.
 func FormatXML(content []byte, indent string) (string, error) {
+	return FormatXMLWrapped(content, indent, ProseWrapOff)
+}
+
+// FormatXMLWrapped formats like FormatXML, laying out the text of prose
+// blocks as wrap says.
+func FormatXMLWrapped(content []byte, indent string, wrap ProseWrap) (string, error) {
+	content, bom := epub.StripBOM(content)
 	if err := validateXML(content); err != nil {
 		return "", err
 	}
 
 	tokens := tokenizeRawXML(content)
-	return formatTokens(tokens, indent), nil
+	formatted := formatTokens(tokens, indent, wrap)
+	return withBOM(useLineEnding(formatted, epub.DetectLineEnding(content)), bom), nil
+}
+
+// withBOM returns formatted with a byte order mark in front when bom is set,
+// so that formatting keeps the mark a file started with.
+func withBOM(formatted string, bom bool) string {
+	if bom {
+		return epub.BOM + formatted
+	}
+	return formatted
+}
+
+// useLineEnding rewrites every line terminator in formatted to ending.
+func useLineEnding(formatted, ending string) string {
+	formatted = strings.ReplaceAll(formatted, "\r\n", "\n")
+	if ending == "\n" {
+		return formatted
+	}
+	return strings.ReplaceAll(formatted, "\n", ending)
 }
 
 // validateXML checks if the content is well-formed XML using the standard decoder.
@@ -139,7 +183,7 @@ func tokenizeRawXML(content []byte) []xmlToken {
 			}
 			tokens = append(
 				tokens,
-				xmlToken{kind: tokCharData, raw: string(remaining[:end])},
+				xmlToken{kind: tokCDATA, raw: string(remaining[:end])},
 			)
 			i += end
 
@@ -251,7 +295,7 @@ func normalizeTag(raw string) string {
 }
 
 // formatTokens renders tokens with proper indentation.
-func formatTokens(tokens []xmlToken, indent string) string {
+func formatTokens(tokens []xmlToken, indent string, wrap ProseWrap) string {
 	var buf strings.Builder
 	depth := 0
 
@@ -282,13 +326,44 @@ func formatTokens(tokens []xmlToken, indent string) string {
 			buf.WriteByte('\n')
 
 		case tokStartTag:
-			if isInlineElement(tokens, i) {
+			if preservesSpace(tok) {
+				// Content is written verbatim, so the end tag follows it
+				// without indentation.
+				end := matchingEndTag(tokens, i)
+				writeIndent(&buf, indent, depth)
+				buf.WriteString(normalizeTag(tok.raw))
+				for _, t := range tokens[i+1 : end] {
+					buf.WriteString(t.raw)
+				}
+				if end < len(tokens) {
+					buf.WriteString(strings.TrimSpace(tokens[end].raw))
+				}
+				buf.WriteByte('\n')
+				i = end
+			} else if lines, end, ok := proseLayout(tokens, i, wrap); ok {
+				writeIndent(&buf, indent, depth)
+				buf.WriteString(normalizeTag(tok.raw))
+				buf.WriteByte('\n')
+				for _, line := range lines {
+					writeIndent(&buf, indent, depth+1)
+					buf.WriteString(line)
+					buf.WriteByte('\n')
+				}
+				writeIndent(&buf, indent, depth)
+				buf.WriteString(strings.TrimSpace(tokens[end].raw))
+				buf.WriteByte('\n')
+				i = end
+			} else if isInlineElement(tokens, i) {
 				writeIndent(&buf, indent, depth)
 				buf.WriteString(normalizeTag(tok.raw))
 				i++
-				if i < len(tokens) && tokens[i].kind == tokCharData {
+				switch {
+				case i < len(tokens) && tokens[i].kind == tokCharData:
 					buf.WriteString(strings.TrimSpace(tokens[i].raw))
 					i++
+				case i < len(tokens) && tokens[i].kind == tokCDATA:
+					buf.WriteString(tokens[i].raw)
+					i++
 				}
 				if i < len(tokens) {
 					buf.WriteString(strings.TrimSpace(tokens[i].raw))
@@ -310,6 +385,12 @@ func formatTokens(tokens []xmlToken, indent string) string {
 			buf.WriteString(strings.TrimSpace(tok.raw))
 			buf.WriteByte('\n')
 
+		case tokCDATA:
+			// CDATA is never trimmed or re-wrapped
+			writeIndent(&buf, indent, depth)
+			buf.WriteString(tok.raw)
+			buf.WriteByte('\n')
+
 		case tokCharData:
 			text := strings.TrimSpace(tok.raw)
 			if text == "" {
@@ -366,10 +447,40 @@ func isInlineElement(tokens []xmlToken, i int) bool {
 		tokens[i+1].name == tokens[i].name {
 		return true
 	}
-	if i+2 < len(tokens) && tokens[i+1].kind == tokCharData &&
+	if i+2 < len(tokens) &&
+		(tokens[i+1].kind == tokCharData || tokens[i+1].kind == tokCDATA) &&
 		tokens[i+2].kind == tokEndTag &&
 		tokens[i+2].name == tokens[i].name {
 		return true
 	}
 	return false
 }
+
+// preservesSpace reports whether the content of a start tag's element is
+// whitespace sensitive.
+func preservesSpace(tok xmlToken) bool {
+	return preservedElements[localName(tok.name)] ||
+		xmlSpacePreserveRe.MatchString(tok.raw)
+}
+
+// matchingEndTag returns the index of the end tag closing the start tag at
+// i, counting nested elements of the same name, or len(tokens) if there is
+// none.
+func matchingEndTag(tokens []xmlToken, i int) int {
+	depth := 0
+	for j := i + 1; j < len(tokens); j++ {
+		if tokens[j].name != tokens[i].name {
+			continue
+		}
+		switch tokens[j].kind {
+		case tokStartTag:
+			depth++
+		case tokEndTag:
+			if depth == 0 {
+				return j
+			}
+			depth--
+		}
+	}
+	return len(tokens)
+}
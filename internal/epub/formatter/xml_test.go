@@ -3,6 +3,9 @@ package formatter
 import (
 	"strings"
 	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
 )
 
 func TestFormatXML_BasicIndentation(t *testing.T) {
@@ -299,3 +302,245 @@ func TestFormatXML_SelfClosingPreserved(t *testing.T) {
 		t.Errorf("expected self-closing img\n%s", result)
 	}
 }
+
+func TestFormatXML_PreservesCRLF(t *testing.T) {
+	inputs := []string{
+		"<root>\n<child>text</child>\n</root>",
+		`<?xml version="1.0" encoding="UTF-8"?>
+<package>
+<!-- a
+multi-line comment -->
+<metadata><title>Test</title></metadata>
+</package>`,
+		`<root>
+{{- if .Title}}
+<h1>{{.Title}}</h1>
+{{- else}}
+<p>untitled</p>
+{{- end}}
+</root>`,
+	}
+
+	for _, input := range inputs {
+		lf, err := FormatXML([]byte(input), "  ")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		crlf, err := FormatXML(testutil.CRLF([]byte(input)), "  ")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if want := string(testutil.CRLF([]byte(lf))); crlf != want {
+			t.Errorf("CRLF output mismatch\nexpected:\n%q\ngot:\n%q", want, crlf)
+		}
+	}
+}
+
+func TestFormatXML_MixedLineEndingsUseDominant(t *testing.T) {
+	input := []byte("<root>\r\n<a/>\r\n<b/>\n</root>\r\n")
+	result, err := FormatXML(input, "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Count(result, "\n") != strings.Count(result, "\r\n") {
+		t.Errorf("expected only CRLF line endings, got %q", result)
+	}
+}
+
+func TestFormatXML_PreservesWhitespaceSensitiveContent(t *testing.T) {
+	input := []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head>
+<script type="text/javascript">//<![CDATA[
+  if (a < b) {
+      run();
+  }
+//]]></script>
+<style>
+p   { margin: 0 }
+</style></head><body>
+<pre>   leading spaces
+	and a <b>tab</b></pre>
+<div   xml:space="preserve">  <span>kept</span>  </div>
+<p><![CDATA[  raw  ]]></p>
+</body></html>`)
+
+	result, err := FormatXML(input, "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `<html xmlns="http://www.w3.org/1999/xhtml">
+  <head>
+    <script type="text/javascript">//<![CDATA[
+  if (a < b) {
+      run();
+  }
+//]]></script>
+    <style>
+p   { margin: 0 }
+</style>
+  </head>
+  <body>
+    <pre>   leading spaces
+	and a <b>tab</b></pre>
+    <div xml:space="preserve">  <span>kept</span>  </div>
+    <p><![CDATA[  raw  ]]></p>
+  </body>
+</html>
+`
+	if result != expected {
+		t.Errorf("preserved content mismatch\nexpected:\n%s\ngot:\n%s", expected, result)
+	}
+
+	again, err := FormatXML([]byte(result), "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again != result {
+		t.Errorf("formatting is not stable\nfirst:\n%s\nsecond:\n%s", result, again)
+	}
+}
+
+func TestFormatXML_PreservedElementNesting(t *testing.T) {
+	input := []byte(`<root><pre> a <pre> b </pre> c </pre><child/></root>`)
+
+	result, err := FormatXML(input, "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `<root>
+  <pre> a <pre> b </pre> c </pre>
+  <child/>
+</root>
+`
+	if result != expected {
+		t.Errorf("nested preserve mismatch\nexpected:\n%s\ngot:\n%s", expected, result)
+	}
+}
+
+func TestFormatXML_CDATANotTrimmed(t *testing.T) {
+	input := []byte(
+		"<root>\n  <![CDATA[\n  line one\n    line two\n]]>\n  <child/>\n</root>")
+
+	result, err := FormatXML(input, "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(result, "<![CDATA[\n  line one\n    line two\n]]>") {
+		t.Errorf("expected CDATA to be kept verbatim, got:\n%s", result)
+	}
+}
+
+func TestFormatXML_PreservesBOM(t *testing.T) {
+	input := []byte(epub.BOM + `<?xml version="1.0" encoding="UTF-8"?>
+<package><metadata/></package>`)
+	want := epub.BOM + `<?xml version="1.0" encoding="UTF-8"?>
+<package>
+  <metadata/>
+</package>
+`
+
+	result, err := FormatXML(input, "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != want {
+		t.Errorf("got %q, want %q", result, want)
+	}
+
+	again, err := FormatXML([]byte(result), "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again != result {
+		t.Errorf("second pass changed the output: %q", again)
+	}
+}
+
+func TestFormatXMLWrapped_Sentence(t *testing.T) {
+	input := []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<p>The storm broke at dawn. It was <em>loud. Very
+   loud</em> and long. Mr. Hale slept through it.</p>
+<p>One short line.</p>
+<p>Short. Two sentences.</p>
+<ul><li>First, e.g. this. <a href="x.xhtml">Then that.</a></li></ul>
+<blockquote><p>Nested. Block.</p></blockquote>
+<div>Not prose. Left alone.</div>
+</body></html>`)
+
+	result, err := FormatXMLWrapped(input, "  ", ProseWrapSentence)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `<html xmlns="http://www.w3.org/1999/xhtml">
+  <body>
+    <p>
+      The storm broke at dawn.
+      It was <em>loud.
+      Very loud</em> and long.
+      Mr. Hale slept through it.
+    </p>
+    <p>One short line.</p>
+    <p>
+      Short.
+      Two sentences.
+    </p>
+    <ul>
+      <li>
+        First, e.g. this.
+        <a href="x.xhtml">Then that.</a>
+      </li>
+    </ul>
+    <blockquote>
+      <p>
+        Nested.
+        Block.
+      </p>
+    </blockquote>
+    <div>Not prose. Left alone.</div>
+  </body>
+</html>
+`
+	if result != expected {
+		t.Errorf("sentence wrap mismatch\nexpected:\n%s\ngot:\n%s", expected, result)
+	}
+
+	again, err := FormatXMLWrapped([]byte(result), "  ", ProseWrapSentence)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again != result {
+		t.Errorf("sentence wrap is not idempotent:\n%s", again)
+	}
+}
+
+func TestFormatXMLWrapped_Preserve(t *testing.T) {
+	input := []byte(`<body>
+<p>A line with <em>emphasis</em> kept.
+An  edited line. And more.</p>
+<p><span>Mixed</span> <b>inline</b></p>
+</body>`)
+
+	result, err := FormatXMLWrapped(input, "  ", ProseWrapPreserve)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `<body>
+  <p>
+    A line with <em>emphasis</em> kept.
+    An  edited line. And more.
+  </p>
+  <p>
+    <span>Mixed</span> <b>inline</b>
+  </p>
+</body>
+`
+	if result != expected {
+		t.Errorf("preserve wrap mismatch\nexpected:\n%s\ngot:\n%s", expected, result)
+	}
+}
@@ -0,0 +1,169 @@
+// Package ignore matches workspace paths against .gitignore-style
+// patterns.
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Defaults are the patterns every workspace scan skips: hidden files and
+// directories, installed packages of hybrid projects, and built archives.
+var Defaults = []string{".*", "node_modules/", "*.epub", "*.zip"}
+
+// GitignoreFile is the name of the ignore file read from the workspace
+// root.
+const GitignoreFile = ".gitignore"
+
+// rule is one parsed pattern.
+type rule struct {
+	segments []string // pattern split at slashes; "**" matches any depth
+	negate   bool
+	dirOnly  bool
+	anchored bool // matched from the root rather than at any depth
+}
+
+// Matcher holds an ordered list of patterns. As in .gitignore, the last
+// pattern matching a path decides whether it is ignored, and a path inside
+// an ignored directory is ignored whatever later patterns say.
+type Matcher struct {
+	rules []rule
+}
+
+// New returns a matcher for patterns.
+func New(patterns ...string) *Matcher {
+	m := &Matcher{}
+	m.Add(patterns...)
+	return m
+}
+
+// Add appends patterns, which take precedence over those already added.
+// Blank lines and lines starting with "#" are skipped; "\#" and "\!"
+// escape a leading "#" or "!".
+func (m *Matcher) Add(patterns ...string) {
+	for _, p := range patterns {
+		if r, ok := parseRule(p); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+}
+
+// AddFile appends the patterns in the file name of fsys. A missing file
+// adds nothing.
+func (m *Matcher) AddFile(fsys fs.FS, name string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		m.Add(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// Match reports whether the slash-separated path p, relative to the
+// workspace root, is ignored. isDir tells whether p is a directory, which
+// patterns ending in "/" require.
+func (m *Matcher) Match(p string, isDir bool) bool {
+	if m == nil || len(m.rules) == 0 {
+		return false
+	}
+	p = strings.Trim(p, "/")
+	if p == "" || p == "." {
+		return false
+	}
+	parts := strings.Split(p, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.matchOne(parts[:i], true) {
+			return true
+		}
+	}
+	return m.matchOne(parts, isDir)
+}
+
+// matchOne applies the rules to one path, ignoring its parents.
+func (m *Matcher) matchOne(parts []string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.matches(parts) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (r rule) matches(parts []string) bool {
+	if r.anchored {
+		return matchSegments(r.segments, parts)
+	}
+	// An unanchored pattern is a single name matched at any depth
+	ok, _ := path.Match(r.segments[0], parts[len(parts)-1])
+	return ok
+}
+
+// matchSegments matches path parts against pattern segments, where "**"
+// stands for zero or more parts.
+func matchSegments(segments, parts []string) bool {
+	for len(segments) > 0 {
+		if segments[0] == "**" {
+			segments = segments[1:]
+			if len(segments) == 0 {
+				return true
+			}
+			for i := range len(parts) + 1 {
+				if matchSegments(segments, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(segments[0], parts[0]); !ok {
+			return false
+		}
+		segments, parts = segments[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// parseRule parses one pattern line.
+func parseRule(line string) (rule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	// A slash anywhere but at the end anchors the pattern to the root;
+	// "**/" in front matches at any depth, which the segments handle
+	r.anchored = strings.Contains(line, "/")
+	r.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	return r, true
+}
@@ -0,0 +1,79 @@
+package ignore
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{"unanchored name at root", []string{"*.epub"}, "book.epub", false, true},
+		{"unanchored name nested", []string{"*.epub"}, "out/v1/book.epub", false, true},
+		{"unanchored no match", []string{"*.epub"}, "OEBPS/book.xhtml", false, false},
+		{"unanchored directory", []string{"drafts"}, "OEBPS/drafts", true, true},
+		{"inside unanchored directory", []string{"drafts"}, "OEBPS/drafts/ch1.xhtml", false, true},
+		{"anchored at root", []string{"/dist"}, "dist/book.xhtml", false, true},
+		{"anchored not nested", []string{"/dist"}, "src/dist/book.xhtml", false, false},
+		{"middle slash anchors", []string{"OEBPS/old"}, "OEBPS/old/a.css", false, true},
+		{"middle slash not nested", []string{"OEBPS/old"}, "x/OEBPS/old/a.css", false, false},
+		{"directory only matches directory", []string{"build/"}, "build", true, true},
+		{"directory only skips file", []string{"build/"}, "build", false, false},
+		{"directory only contents", []string{"build/"}, "a/build/out.xhtml", false, true},
+		{"leading double star", []string{"**/cache"}, "a/b/cache/x.css", false, true},
+		{"leading double star at root", []string{"**/cache"}, "cache", true, true},
+		{"trailing double star", []string{"tmp/**"}, "tmp/a/b.xhtml", false, true},
+		{"middle double star", []string{"a/**/z.css"}, "a/z.css", false, true},
+		{"middle double star deep", []string{"a/**/z.css"}, "a/b/c/z.css", false, true},
+		{"middle double star other root", []string{"a/**/z.css"}, "b/c/z.css", false, false},
+		{"negation", []string{"*.png", "!cover.png"}, "images/cover.png", false, false},
+		{"negation keeps others", []string{"*.png", "!cover.png"}, "images/old.png", false, true},
+		{"later pattern wins", []string{"!keep.css", "*.css"}, "keep.css", false, true},
+		{"negation inside ignored directory", []string{"out/", "!out/keep.xhtml"}, "out/keep.xhtml", false, true},
+		{"negated directory", []string{"*/", "!OEBPS/"}, "OEBPS/ch1.xhtml", false, false},
+		{"comment", []string{"# *.css"}, "a.css", false, false},
+		{"escaped hash", []string{`\#notes`}, "#notes", false, true},
+		{"hidden default", Defaults, "OEBPS/.DS_Store", false, true},
+		{"hidden directory default", Defaults, ".git/config", false, true},
+		{"node_modules default", Defaults, "tools/node_modules/x/index.js", false, true},
+		{"root never ignored", Defaults, ".", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.patterns...).Match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Match(%q) with %q = %v, want %v", tt.path, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore": {Data: []byte("# build output\ndist/\r\n\n*.log\n!keep.log\n")},
+	}
+
+	m := New()
+	if err := m.AddFile(fsys, GitignoreFile); err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[string]bool{
+		"dist/book.epub": true,
+		"debug.log":      true,
+		"keep.log":       false,
+		"OEBPS/ch1.html": false,
+	} {
+		if got := m.Match(p, false); got != want {
+			t.Errorf("Match(%q) = %v, want %v", p, got, want)
+		}
+	}
+
+	if err := New().AddFile(fsys, "missing/.gitignore"); err != nil {
+		t.Errorf("expected a missing file to add nothing, got %v", err)
+	}
+}
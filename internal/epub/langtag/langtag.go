@@ -0,0 +1,202 @@
+// Package langtag holds a small table of BCP 47 language tags and region
+// subtags, for completing lang values and catching implausible regions.
+package langtag
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// Language is a common language tag and the name of its language.
+type Language struct {
+	Tag  string
+	Name string
+}
+
+// Common lists the language tags offered for completion, led by the
+// languages most often seen in EPUB publications.
+var Common = []Language{
+	{"en", "English"},
+	{"en-US", "English (United States)"},
+	{"en-GB", "English (United Kingdom)"},
+	{"en-CA", "English (Canada)"},
+	{"en-AU", "English (Australia)"},
+	{"fr", "French"},
+	{"fr-FR", "French (France)"},
+	{"fr-CA", "French (Canada)"},
+	{"de", "German"},
+	{"de-DE", "German (Germany)"},
+	{"de-AT", "German (Austria)"},
+	{"de-CH", "German (Switzerland)"},
+	{"es", "Spanish"},
+	{"es-ES", "Spanish (Spain)"},
+	{"es-MX", "Spanish (Mexico)"},
+	{"es-419", "Spanish (Latin America)"},
+	{"it", "Italian"},
+	{"pt", "Portuguese"},
+	{"pt-BR", "Portuguese (Brazil)"},
+	{"pt-PT", "Portuguese (Portugal)"},
+	{"nl", "Dutch"},
+	{"sv", "Swedish"},
+	{"da", "Danish"},
+	{"nb", "Norwegian Bokmål"},
+	{"nn", "Norwegian Nynorsk"},
+	{"fi", "Finnish"},
+	{"is", "Icelandic"},
+	{"pl", "Polish"},
+	{"cs", "Czech"},
+	{"sk", "Slovak"},
+	{"hu", "Hungarian"},
+	{"ro", "Romanian"},
+	{"bg", "Bulgarian"},
+	{"hr", "Croatian"},
+	{"sr", "Serbian"},
+	{"sl", "Slovenian"},
+	{"el", "Greek"},
+	{"ru", "Russian"},
+	{"uk", "Ukrainian"},
+	{"tr", "Turkish"},
+	{"ca", "Catalan"},
+	{"eu", "Basque"},
+	{"ga", "Irish"},
+	{"cy", "Welsh"},
+	{"la", "Latin"},
+	{"grc", "Ancient Greek"},
+	{"ar", "Arabic"},
+	{"he", "Hebrew"},
+	{"fa", "Persian"},
+	{"ur", "Urdu"},
+	{"hi", "Hindi"},
+	{"bn", "Bengali"},
+	{"ta", "Tamil"},
+	{"th", "Thai"},
+	{"vi", "Vietnamese"},
+	{"id", "Indonesian"},
+	{"ms", "Malay"},
+	{"sw", "Swahili"},
+	{"ja", "Japanese"},
+	{"ko", "Korean"},
+	{"zh", "Chinese"},
+	{"zh-Hans", "Chinese (Simplified)"},
+	{"zh-Hant", "Chinese (Traditional)"},
+	{"zh-CN", "Chinese (China)"},
+	{"zh-TW", "Chinese (Taiwan)"},
+	{"zh-HK", "Chinese (Hong Kong)"},
+}
+
+// regions holds the two-letter region subtags of the IANA registry: the
+// ISO 3166-1 codes, the exceptionally reserved and historical codes the
+// registry keeps, and the private use ranges.
+var regions = func() map[string]bool {
+	codes := "AC AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ " +
+		"BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BU BV BW BY BZ " +
+		"CA CC CD CF CG CH CI CK CL CM CN CO CP CR CS CU CV CW CX CY CZ " +
+		"DD DE DG DJ DK DM DO DZ EA EC EE EG EH ER ES ET EU EZ " +
+		"FI FJ FK FM FO FR FX GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY " +
+		"HK HM HN HR HT HU IC ID IE IL IM IN IO IQ IR IS IT JE JM JO JP " +
+		"KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK LR LS LT LU LV LY " +
+		"MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ " +
+		"NA NC NE NF NG NI NL NO NP NR NT NU NZ OM " +
+		"PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW " +
+		"SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SU SV SX SY SZ " +
+		"TA TC TD TF TG TH TJ TK TL TM TN TO TP TR TT TV TW TZ " +
+		"UA UG UM UN US UY UZ VA VC VE VG VI VN VU WF WS YD YE YT YU " +
+		"ZA ZM ZR ZW AA QM QN QO QP QQ QR QS QT QU QV QW QX QY QZ " +
+		"XA XB XC XD XE XF XG XH XI XJ XK XL XM XN XO XP XQ XR XS XT XU XV XW XX XY XZ ZZ"
+	m := make(map[string]bool)
+	for code := range strings.FieldsSeq(codes) {
+		m[code] = true
+	}
+	return m
+}()
+
+// regionMistakes maps region subtags often written in place of a real one
+// to the code meant.
+var regionMistakes = map[string]string{
+	"UK": "GB",
+	"EN": "GB",
+	"JA": "JP",
+	"KO": "KR",
+	"ZH": "CN",
+	"DA": "DK",
+	"EL": "GR",
+}
+
+// RegionProblem describes a region subtag that is not in the registry.
+type RegionProblem struct {
+	// Start and End are the byte offsets of the subtag in the tag.
+	Start, End int
+	Region     string
+	// Suggestion is the region probably meant, or empty.
+	Suggestion string
+}
+
+// UnlikelyRegion finds the region subtag of tag and reports it when it is
+// two letters not assigned to any region. Three-digit UN M.49 regions,
+// such as 419 for Latin America, are accepted, as are tags without a
+// region and private use or grandfathered tags.
+func UnlikelyRegion(tag string) (RegionProblem, bool) {
+	subtags := strings.Split(tag, "-")
+	if len(subtags[0]) < 2 || len(subtags[0]) > 8 || !isAlpha(subtags[0]) {
+		return RegionProblem{}, false // private use, grandfathered, or malformed
+	}
+
+	offset := len(subtags[0]) + 1
+	for i, sub := range subtags[1:] {
+		switch {
+		case len(sub) == 3 && isAlpha(sub) && i < 3, // extended language
+			len(sub) == 4 && isAlpha(sub): // script
+			offset += len(sub) + 1
+			continue
+		case len(sub) == 2 && isAlpha(sub):
+			region := strings.ToUpper(sub)
+			if regions[region] {
+				return RegionProblem{}, false
+			}
+			return RegionProblem{
+				Start:      offset,
+				End:        offset + 2,
+				Region:     sub,
+				Suggestion: regionMistakes[region],
+			}, true
+		}
+		break // a numeric region, variant, or extension
+	}
+	return RegionProblem{}, false
+}
+
+// RegionDiag returns a lang-region-unlikely warning when the language tag
+// at offset has a region subtag that is not a region code, with a fix
+// replacing it when the region meant is clear.
+func RegionDiag(
+	lines *epub.LineIndex,
+	tag string,
+	offset int,
+	source string,
+) (epub.Diagnostic, bool) {
+	p, bad := UnlikelyRegion(tag)
+	if !bad {
+		return epub.Diagnostic{}, false
+	}
+	msg := `region "` + p.Region + `" in language tag "` + tag + `" is not an ISO 3166 region code`
+	b := epub.NewDiagAt(lines, offset+p.Start, source).
+		End(lines.Position(offset + p.End)).
+		Code("lang-region-unlikely")
+	if p.Suggestion == "" {
+		return b.Warning(msg).Build(), true
+	}
+	return b.Warning(msg+`; did you mean "`+p.Suggestion+`"?`).
+		Fix(`Replace with "`+p.Suggestion+`"`, epub.AnchorReplaceRange, p.Suggestion).
+		Build(), true
+}
+
+func isAlpha(s string) bool {
+	for i := range len(s) {
+		c := s[i] | 0x20
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return s != ""
+}
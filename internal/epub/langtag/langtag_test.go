@@ -0,0 +1,55 @@
+package langtag
+
+import "testing"
+
+func TestUnlikelyRegion(t *testing.T) {
+	tests := []struct {
+		tag        string
+		bad        bool
+		region     string
+		start      int
+		suggestion string
+	}{
+		{tag: "en"},
+		{tag: "en-US"},
+		{tag: "en-us"},
+		{tag: "es-419"},
+		{tag: "zh-Hant"},
+		{tag: "zh-Hant-TW"},
+		{tag: "zh-yue-HK"},
+		{tag: "sr-Latn-RS"},
+		{tag: "de-CH-1901"},
+		{tag: "en-XA"},
+		{tag: "x-klingon"},
+		{tag: "i-klingon"},
+		{tag: "en-UK", bad: true, region: "UK", start: 3, suggestion: "GB"},
+		{tag: "ja-JA", bad: true, region: "JA", start: 3, suggestion: "JP"},
+		{tag: "fr-QQ"},
+		{tag: "fr-XQ"},
+		{tag: "pt-Latn-BX", bad: true, region: "BX", start: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			p, bad := UnlikelyRegion(tt.tag)
+			if bad != tt.bad {
+				t.Fatalf("UnlikelyRegion(%q) = %v, want %v", tt.tag, bad, tt.bad)
+			}
+			if !bad {
+				return
+			}
+			if p.Region != tt.region || p.Start != tt.start || p.End != tt.start+2 ||
+				p.Suggestion != tt.suggestion {
+				t.Errorf("UnlikelyRegion(%q) = %+v", tt.tag, p)
+			}
+		})
+	}
+}
+
+func TestCommonTagsHaveKnownRegions(t *testing.T) {
+	for _, l := range Common {
+		if _, bad := UnlikelyRegion(l.Tag); bad {
+			t.Errorf("common tag %q has an unknown region", l.Tag)
+		}
+	}
+}
@@ -2,8 +2,12 @@ package epub
 
 // XML namespace constants used across EPUB validators.
 const (
-	NSEpub  = "http://www.idpf.org/2007/ops"
-	NSDC    = "http://purl.org/dc/elements/1.1/"
-	NSXHTML = "http://www.w3.org/1999/xhtml"
-	NSXML   = "http://www.w3.org/XML/1998/namespace"
+	NSEpub      = "http://www.idpf.org/2007/ops"
+	NSDC        = "http://purl.org/dc/elements/1.1/"
+	NSXHTML     = "http://www.w3.org/1999/xhtml"
+	NSXML       = "http://www.w3.org/XML/1998/namespace"
+	NSContainer = "urn:oasis:names:tc:opendocument:xmlns:container"
+	NSXMLEnc    = "http://www.w3.org/2001/04/xmlenc#"
+	NSSVG       = "http://www.w3.org/2000/svg"
+	NSXLink     = "http://www.w3.org/1999/xlink"
 )
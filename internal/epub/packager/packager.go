@@ -0,0 +1,161 @@
+// Package packager zips an unpacked EPUB into a .epub container following
+// the OCF rules: an uncompressed mimetype entry first, then META-INF, the
+// package document, and every file its manifest lists.
+package packager
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/validator/opf"
+)
+
+const (
+	// MimetypeName is the name of the first entry of every EPUB.
+	MimetypeName = "mimetype"
+	// Mimetype is the content of the mimetype entry.
+	Mimetype = "application/epub+zip"
+	// ContainerPath is the OCF container file naming the package document.
+	ContainerPath = "META-INF/container.xml"
+)
+
+// metaInfFiles lists the optional OCF files copied from META-INF when
+// present, after container.xml.
+var metaInfFiles = []string{
+	"META-INF/encryption.xml",
+	"META-INF/manifest.xml",
+	"META-INF/metadata.xml",
+	"META-INF/rights.xml",
+	"META-INF/signatures.xml",
+}
+
+// Write writes an EPUB archive of the book in fsys to w. opfPath is the
+// slash-separated path of the package document within fsys. The book's
+// META-INF/container.xml is used when present and otherwise generated to
+// point at opfPath. Remote manifest items are skipped; a missing local one
+// is an error. It returns the names of the entries written, in order.
+func Write(w io.Writer, fsys fs.FS, opfPath string) ([]string, error) {
+	opfContent, err := fs.ReadFile(fsys, opfPath)
+	if err != nil {
+		return nil, err
+	}
+	manifest := opf.ParseManifest(opfContent)
+	if manifest == nil {
+		return nil, errors.New("cannot parse package document " + opfPath)
+	}
+
+	container, err := fs.ReadFile(fsys, ContainerPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		container, err = []byte(containerXML(opfPath)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	zw := zip.NewWriter(w)
+	var names []string
+	written := make(map[string]bool)
+	add := func(name string, content []byte) error {
+		if written[name] {
+			return nil
+		}
+		written[name] = true
+		names = append(names, name)
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(content)
+		return err
+	}
+
+	if err := writeMimetype(zw); err != nil {
+		return nil, err
+	}
+	names = append(names, MimetypeName)
+	written[MimetypeName] = true
+
+	if err := add(ContainerPath, container); err != nil {
+		return nil, err
+	}
+	for _, name := range metaInfFiles {
+		content, err := fs.ReadFile(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := add(name, content); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(opfPath, opfContent); err != nil {
+		return nil, err
+	}
+
+	opfDir := path.Dir(opfPath)
+	for _, item := range manifest.Items {
+		if item.Href == "" || epub.IsRemoteURL(item.Href) {
+			continue
+		}
+		name := epub.ResolveHref(opfDir, epub.StripFragment(item.Href))
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("manifest item %q lies outside the book", item.Href)
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("manifest item %q: %w", item.Href, err)
+		}
+		if err := add(name, content); err != nil {
+			return nil, err
+		}
+	}
+
+	return names, zw.Close()
+}
+
+// writeMimetype writes the mimetype entry stored rather than compressed,
+// with its sizes in the local header and no extra field, so that readers
+// can find the media type at a fixed offset.
+func writeMimetype(zw *zip.Writer) error {
+	f, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               MimetypeName,
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE([]byte(Mimetype)),
+		CompressedSize64:   uint64(len(Mimetype)),
+		UncompressedSize64: uint64(len(Mimetype)),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, Mimetype)
+	return err
+}
+
+// containerXML returns a container document naming the package document
+// at opfPath.
+func containerXML(opfPath string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<container version="1.0" ` +
+		`xmlns="urn:oasis:names:tc:opendocument:xmlns:container">` + "\n")
+	b.WriteString("  <rootfiles>\n")
+	fmt.Fprintf(&b, `    <rootfile full-path="%s" `+
+		`media-type="application/oebps-package+xml"/>`+"\n", escapeAttr(opfPath))
+	b.WriteString("  </rootfiles>\n")
+	b.WriteString("</container>\n")
+	return b.String()
+}
+
+// escapeAttr escapes s for a double-quoted XML attribute value.
+func escapeAttr(s string) string {
+	return strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `"`, "&quot;").Replace(s)
+}
@@ -0,0 +1,153 @@
+package packager
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testOPF = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="text/My%20Chapter.xhtml" media-type="application/xhtml+xml"/>
+    <item id="css" href="../Styles/style.css" media-type="text/css"/>
+    <item id="img" href="images/cover.png" media-type="image/png"/>
+    <item id="font" href="https://example.com/font.woff" media-type="font/woff"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+
+// testBook returns an unpacked book without container.xml, plus a file the
+// manifest does not list.
+func testBook() fstest.MapFS {
+	return fstest.MapFS{
+		"OEBPS/content.opf":             {Data: []byte(testOPF)},
+		"OEBPS/nav.xhtml":               {Data: []byte("<html/>")},
+		"OEBPS/text/My Chapter.xhtml":   {Data: []byte("<html/>")},
+		"Styles/style.css":              {Data: []byte("p { margin: 0 }")},
+		"OEBPS/images/cover.png":        {Data: []byte("\x89PNG")},
+		"OEBPS/notes.txt":               {Data: []byte("not in the manifest")},
+		"META-INF/com.apple.ibooks.xml": {Data: []byte("<display_options/>")},
+	}
+}
+
+// pack writes fsys as an EPUB and returns the archive bytes and reader.
+func pack(t *testing.T, fsys fstest.MapFS) ([]byte, *zip.Reader) {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := Write(&buf, fsys, "OEBPS/content.opf"); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), zr
+}
+
+func TestWrite(t *testing.T) {
+	data, zr := pack(t, testBook())
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	want := []string{
+		"mimetype",
+		"META-INF/container.xml",
+		"OEBPS/content.opf",
+		"OEBPS/nav.xhtml",
+		"OEBPS/text/My Chapter.xhtml",
+		"Styles/style.css",
+		"OEBPS/images/cover.png",
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("expected entries %q, got %q", want, names)
+	}
+
+	mimetype := zr.File[0]
+	if mimetype.Method != zip.Store {
+		t.Errorf("expected mimetype to be stored, got method %d", mimetype.Method)
+	}
+	if len(mimetype.Extra) != 0 {
+		t.Errorf("expected no extra field on mimetype, got %d bytes", len(mimetype.Extra))
+	}
+	// Readers sniff the media type at a fixed offset in the local header
+	if got := string(data[30:38]); got != "mimetype" {
+		t.Errorf("expected mimetype name at offset 30, got %q", got)
+	}
+	if got := string(data[38 : 38+len(Mimetype)]); got != Mimetype {
+		t.Errorf("expected media type at offset 38, got %q", got)
+	}
+
+	for _, f := range zr.File[1:] {
+		if f.Method != zip.Deflate {
+			t.Errorf("%s: expected deflate, got method %d", f.Name, f.Method)
+		}
+	}
+
+	container := readEntry(t, zr, "META-INF/container.xml")
+	if !strings.Contains(container, `full-path="OEBPS/content.opf"`) {
+		t.Errorf("generated container.xml does not name the OPF:\n%s", container)
+	}
+	if got := readEntry(t, zr, "Styles/style.css"); got != "p { margin: 0 }" {
+		t.Errorf("unexpected stylesheet content %q", got)
+	}
+}
+
+func TestWriteKeepsContainer(t *testing.T) {
+	book := testBook()
+	container := `<container version="1.0" ` +
+		`xmlns="urn:oasis:names:tc:opendocument:xmlns:container"/>`
+	book["META-INF/container.xml"] = &fstest.MapFile{Data: []byte(container)}
+	book["META-INF/encryption.xml"] = &fstest.MapFile{Data: []byte("<encryption/>")}
+
+	_, zr := pack(t, book)
+	if got := readEntry(t, zr, "META-INF/container.xml"); got != container {
+		t.Errorf("expected the book's container.xml, got %q", got)
+	}
+	if zr.File[2].Name != "META-INF/encryption.xml" {
+		t.Errorf("expected encryption.xml after container.xml, got %s", zr.File[2].Name)
+	}
+}
+
+func TestWriteMissingItem(t *testing.T) {
+	book := testBook()
+	delete(book, "OEBPS/images/cover.png")
+
+	_, err := Write(io.Discard, book, "OEBPS/content.opf")
+	if err == nil || !strings.Contains(err.Error(), "images/cover.png") {
+		t.Errorf("expected an error naming the missing item, got %v", err)
+	}
+}
+
+func TestWriteItemOutsideBook(t *testing.T) {
+	book := fstest.MapFS{
+		"content.opf": {Data: []byte(`<package xmlns="http://www.idpf.org/2007/opf">` +
+			`<manifest><item id="x" href="../x.css" media-type="text/css"/></manifest>` +
+			`</package>`)},
+	}
+	if _, err := Write(io.Discard, book, "content.opf"); err == nil {
+		t.Error("expected an error for an item outside the book")
+	}
+}
+
+// readEntry returns the content of the named archive entry.
+func readEntry(t *testing.T, zr *zip.Reader, name string) string {
+	t.Helper()
+	f, err := zr.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
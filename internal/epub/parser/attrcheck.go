@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// ValidateAttrs scans the raw content for attribute problems the decoder
+// lets through: a name repeated within one start tag (XML_DUP_ATTR), which
+// XML forbids, and values holding literal newlines or tabs (ATTR_WS), which
+// XML attribute-value normalization turns into spaces. Values inside an
+// xml:space="preserve" element are left alone. Like the entity scan, it
+// runs independently of the parser and reports on malformed documents too.
+func ValidateAttrs(content []byte) []epub.Diagnostic {
+	var lines *epub.LineIndex
+	var diags []epub.Diagnostic
+	span := func(start, end int) *epub.DiagBuilder {
+		if lines == nil {
+			lines = epub.NewLineIndex(content)
+		}
+		return epub.NewDiagAt(lines, start, Source).End(lines.Position(end))
+	}
+
+	// preserve holds, for each open element, whether whitespace in its
+	// attribute values is significant.
+	var preserve []bool
+	ScanTags(content, func(tag RawTag) {
+		if tag.Close {
+			if len(preserve) > 0 {
+				preserve = preserve[:len(preserve)-1]
+			}
+			return
+		}
+
+		preserved := len(preserve) > 0 && preserve[len(preserve)-1]
+		seen := make(map[string]bool, len(tag.Attrs))
+		for _, attr := range tag.Attrs {
+			if attr.Name == "xml:space" {
+				preserved = attr.Value == "preserve"
+			}
+			if seen[attr.Name] {
+				diags = append(diags, span(attr.NameOffset, attr.NameOffset+len(attr.Name)).
+					Code("XML_DUP_ATTR").
+					Error("attribute "+attr.Name+" is repeated on <"+tag.Name+">").
+					Build())
+			}
+			seen[attr.Name] = true
+		}
+
+		if !preserved {
+			for _, attr := range tag.Attrs {
+				if !strings.ContainsAny(attr.Value, "\n\r\t") {
+					continue
+				}
+				normalized := strings.Join(strings.Fields(attr.Value), " ")
+				diags = append(diags, span(attr.ValueOffset, attr.ValueOffset+len(attr.Value)).
+					Code("ATTR_WS").
+					Info("value of "+attr.Name+" contains line breaks or tabs, "+
+						"which XML reads as spaces; normalize its whitespace").
+					Fix("Normalize whitespace", epub.AnchorReplaceRange, normalized).
+					Build())
+			}
+		}
+		if !tag.Empty {
+			preserve = append(preserve, preserved)
+		}
+	})
+
+	return diags
+}
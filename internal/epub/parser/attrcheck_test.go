@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// attrDiags returns the diagnostics ValidateAttrs reports with code.
+func attrDiags(content, code string) []epub.Diagnostic {
+	var found []epub.Diagnostic
+	for _, d := range ValidateAttrs([]byte(content)) {
+		if d.Code == code {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+func TestValidateAttrs_Duplicates(t *testing.T) {
+	content := "<html>\n<section epub:type=\"chapter\" id=\"c1\" epub:type=\"part\">" +
+		"</section>\n</html>"
+	diags := attrDiags(content, "XML_DUP_ATTR")
+	if len(diags) != 1 {
+		t.Fatalf("got %d XML_DUP_ATTR, want 1", len(diags))
+	}
+	d := diags[0]
+	if d.Severity != epub.SeverityError || !strings.Contains(d.Message, "epub:type") {
+		t.Errorf("unexpected diagnostic %v: %q", d.Severity, d.Message)
+	}
+	second := strings.LastIndex(content, "epub:type")
+	start := epub.ByteOffsetToPosition([]byte(content), second)
+	end := epub.ByteOffsetToPosition([]byte(content), second+len("epub:type"))
+	if d.Range.Start != start || d.Range.End != end {
+		t.Errorf("range = %v, want the second epub:type at %v-%v", d.Range, start, end)
+	}
+
+	if diags := attrDiags(`<p class="a" CLASS="b" xml:lang="en" lang="en">x</p>`,
+		"XML_DUP_ATTR"); len(diags) != 0 {
+		t.Errorf("names differing in case or prefix are distinct, got %v", diags)
+	}
+	if diags := attrDiags(`<p id="a"/><!-- <p id="b" id="c"> --><p id="d"/>`,
+		"XML_DUP_ATTR"); len(diags) != 0 {
+		t.Errorf("attributes across tags or in comments are not repeated, got %v", diags)
+	}
+}
+
+func TestValidateAttrs_Whitespace(t *testing.T) {
+	content := "<body>\n<img src=\"a.png\" alt=\"A long\n\tdescription\"/>\n</body>"
+	diags := attrDiags(content, "ATTR_WS")
+	if len(diags) != 1 {
+		t.Fatalf("got %d ATTR_WS, want 1", len(diags))
+	}
+	d := diags[0]
+	if d.Severity != epub.SeverityInfo || !strings.Contains(d.Message, "alt") {
+		t.Errorf("unexpected diagnostic %v: %q", d.Severity, d.Message)
+	}
+	if d.Range.Start.Line != 1 || d.Range.End.Line != 2 {
+		t.Errorf("range = %v, want the value spanning lines 1-2", d.Range)
+	}
+	if d.Fix == nil || d.Fix.InsertText != "A long description" {
+		t.Errorf("fix = %+v, want the normalized value", d.Fix)
+	}
+
+	preserved := "<body><pre xml:space=\"preserve\" title=\"a\tb\"><span title=\"c\td\"/>" +
+		"</pre><p title=\"e\tf\">x</p></body>"
+	diags = attrDiags(preserved, "ATTR_WS")
+	want := strings.Index(preserved, "e\tf")
+	if len(diags) != 1 || diags[0].Range.Start.Character != want {
+		t.Errorf("want only the value outside xml:space=\"preserve\", got %v", diags)
+	}
+}
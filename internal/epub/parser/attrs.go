@@ -0,0 +1,144 @@
+package parser
+
+import "strings"
+
+// RawAttr is an attribute as written in a start tag.
+type RawAttr struct {
+	// Name is the qualified name, with any prefix.
+	Name string
+	// NameOffset is the byte offset of the name.
+	NameOffset int
+	// ValueOffset is the byte offset of the value, just past its quote.
+	ValueOffset int
+	// Value is the raw value, with references unexpanded.
+	Value string
+}
+
+// RawTag is a start or end tag as written.
+type RawTag struct {
+	// Name is the qualified element name.
+	Name string
+	// Offset is the byte offset of the tag's "<".
+	Offset int
+	// Close reports an end tag, which has no attributes.
+	Close bool
+	// Empty reports a self-closing start tag.
+	Empty bool
+	// Attrs are the attributes with quoted values, in source order.
+	Attrs []RawAttr
+}
+
+// ScanTags calls fn with every start and end tag in content, in document
+// order. It works on malformed documents and skips comments, CDATA
+// sections, processing instructions, and declarations.
+func ScanTags(content []byte, fn func(tag RawTag)) {
+	for i := 0; i < len(content); i++ {
+		if content[i] != '<' {
+			continue
+		}
+		rest := content[i:]
+		switch {
+		case startsWith(rest, "<!--"):
+			i = skipPast(content, i, "-->")
+			continue
+		case startsWith(rest, "<![CDATA["):
+			i = skipPast(content, i, "]]>")
+			continue
+		case startsWith(rest, "<?"):
+			i = skipPast(content, i, "?>")
+			continue
+		case startsWith(rest, "<!"):
+			i = skipPast(content, i, ">")
+			continue
+		case startsWith(rest, "</"):
+			end := skipPast(content, i, ">")
+			name := strings.TrimSpace(string(content[i+2 : min(end, len(content))]))
+			fn(RawTag{Name: name, Offset: i, Close: true})
+			i = end
+			continue
+		}
+		tag := RawTag{Offset: i}
+		i = scanTag(content, i+1, &tag)
+		fn(tag)
+	}
+}
+
+// ScanAttrs calls fn with the qualified name, value offset, and raw value
+// of every attribute on every start tag in content, in document order. It
+// works on malformed documents and skips comments, CDATA sections,
+// processing instructions, and declarations.
+func ScanAttrs(content []byte, fn func(name string, offset int, value string)) {
+	ScanTags(content, func(tag RawTag) {
+		for _, attr := range tag.Attrs {
+			fn(attr.Name, attr.ValueOffset, attr.Value)
+		}
+	})
+}
+
+// scanTag reads the name and attributes of the start tag whose name begins
+// at i into tag and returns the offset of the tag's closing '>'.
+func scanTag(content []byte, i int, tag *RawTag) int {
+	nameStart := i
+	for i < len(content) && !isXMLSpace(content[i]) &&
+		content[i] != '>' && content[i] != '/' {
+		i++
+	}
+	tag.Name = string(content[nameStart:i])
+	for i < len(content) && content[i] != '>' {
+		if isXMLSpace(content[i]) || content[i] == '/' {
+			tag.Empty = content[i] == '/'
+			i++
+			continue
+		}
+		tag.Empty = false
+
+		nameStart := i
+		for i < len(content) && !isXMLSpace(content[i]) &&
+			content[i] != '=' && content[i] != '>' {
+			i++
+		}
+		name := string(content[nameStart:i])
+		for i < len(content) && isXMLSpace(content[i]) {
+			i++
+		}
+		if i >= len(content) || content[i] != '=' {
+			continue
+		}
+		i++
+		for i < len(content) && isXMLSpace(content[i]) {
+			i++
+		}
+		if i >= len(content) || content[i] != '"' && content[i] != '\'' {
+			continue
+		}
+
+		quote := content[i]
+		valueStart := i + 1
+		i = valueStart
+		for i < len(content) && content[i] != quote {
+			i++
+		}
+		tag.Attrs = append(tag.Attrs, RawAttr{
+			Name:        name,
+			NameOffset:  nameStart,
+			ValueOffset: valueStart,
+			Value:       string(content[valueStart:i]),
+		})
+		i++
+	}
+	return i
+}
+
+func startsWith(b []byte, prefix string) bool {
+	return len(b) >= len(prefix) && string(b[:len(prefix)]) == prefix
+}
+
+// skipPast returns the offset of the last byte of the first end marker
+// after i, or the end of content when there is none.
+func skipPast(content []byte, i int, end string) int {
+	idx := strings.Index(string(content[i:]), end)
+	if idx < 0 {
+		return len(content)
+	}
+	return i + idx + len(end) - 1
+}
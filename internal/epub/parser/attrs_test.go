@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScanAttrs(t *testing.T) {
+	content := `<?xml version="1.0"?><!DOCTYPE html><!-- <a href="skip"> -->` +
+		`<a href = 'one' xlink:href="two"><![CDATA[<b id="skip">]]>` +
+		`<img src="three" alt="" /></a><p id=bad class="four"`
+
+	var got []string
+	ScanAttrs([]byte(content), func(name string, offset int, value string) {
+		if content[offset:offset+len(value)] != value {
+			t.Errorf("%s offset %d does not point at %q", name, offset, value)
+		}
+		got = append(got, name+"="+value)
+	})
+
+	want := []string{"href=one", "xlink:href=two", "src=three", "alt=", "class=four"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ScanAttrs found %q, want %q", got, want)
+	}
+}
+
+func TestScanTags(t *testing.T) {
+	content := `<!-- <x> --><a href="one"><br/><img src="two" /></a ><p hidden>`
+
+	var got []string
+	ScanTags([]byte(content), func(tag RawTag) {
+		s := tag.Name
+		switch {
+		case tag.Close:
+			s = "/" + s
+		case tag.Empty:
+			s += "/"
+		}
+		if content[tag.Offset] != '<' {
+			t.Errorf("%s offset %d does not point at '<'", s, tag.Offset)
+		}
+		for _, attr := range tag.Attrs {
+			if content[attr.NameOffset:attr.NameOffset+len(attr.Name)] != attr.Name {
+				t.Errorf("%s name offset %d is wrong", attr.Name, attr.NameOffset)
+			}
+			s += " " + attr.Name
+		}
+		got = append(got, s)
+	})
+
+	want := []string{"a href", "br/", "img/ src", "/a", "p"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ScanTags found %q, want %q", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"unicode/utf8"
 
 	"github.com/toba/epub-lsp/internal/epub"
@@ -13,6 +14,11 @@ type CSSToken struct {
 	Offset int
 	Line   int
 	Col    int
+	// Prelude holds the text between an at-rule's name and the ';' or '{'
+	// that ends it, such as the url of an @import. The tokens of the
+	// prelude are still returned by later calls to Next.
+	Prelude       string
+	PreludeOffset int
 }
 
 // CSSTokenType identifies the kind of CSS token.
@@ -118,10 +124,17 @@ func (t *CSSTokenizer) Next() CSSToken {
 
 func (t *CSSTokenizer) advance() {
 	if t.pos < len(t.content) {
-		if t.content[t.pos] == '\n' {
+		switch {
+		case t.content[t.pos] == '\n':
 			t.line++
 			t.col = 0
-		} else {
+		case t.content[t.pos] == '\r':
+			// \r\n counts once, as the \n; a lone \r ends a line by itself
+			if t.pos+1 >= len(t.content) || t.content[t.pos+1] != '\n' {
+				t.line++
+				t.col = 0
+			}
+		default:
 			t.col++
 		}
 		t.pos++
@@ -186,23 +199,96 @@ func (t *CSSTokenizer) scanAtRule() CSSToken {
 		t.advance()
 	}
 
+	prelude, preludeOffset := t.peekPrelude()
 	return CSSToken{
-		Type:   CSSTokenAtRule,
-		Value:  string(t.content[start:t.pos]),
-		Offset: start,
-		Line:   startLine,
-		Col:    startCol,
+		Type:          CSSTokenAtRule,
+		Value:         string(t.content[start:t.pos]),
+		Offset:        start,
+		Line:          startLine,
+		Col:           startCol,
+		Prelude:       prelude,
+		PreludeOffset: preludeOffset,
 	}
 }
 
+// peekPrelude returns the trimmed at-rule prelude starting at the current
+// position and its offset, without consuming it. The prelude ends at the
+// first ';', '{', or '}' outside strings and parentheses.
+func (t *CSSTokenizer) peekPrelude() (string, int) {
+	i := t.pos
+	for i < len(t.content) && isCSSSpace(t.content[i]) {
+		i++
+	}
+	start := i
+
+	depth := 0
+	var quote byte
+	for ; i < len(t.content); i++ {
+		ch := t.content[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+			continue
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')' && depth > 0:
+			depth--
+		}
+		if depth == 0 && (ch == ';' || ch == '{' || ch == '}') {
+			break
+		}
+	}
+
+	end := i
+	for end > start && isCSSSpace(t.content[end-1]) {
+		end--
+	}
+	return string(t.content[start:end]), start
+}
+
+// scanIdent scans a run of text up to whitespace or CSS punctuation. Strings
+// and the arguments of functions such as var() and url() are kept whole,
+// with their colons, commas, and spaces, as far as the end of the block.
 func (t *CSSTokenizer) scanIdent() CSSToken {
 	start := t.pos
 	startLine := t.line
 	startCol := t.col
 
+	depth := 0
+	var quote byte
 	for t.pos < len(t.content) {
 		ch := t.content[t.pos]
-		if ch == '{' || ch == '}' || ch == ':' || ch == ';' || ch == '/' {
+		switch {
+		case quote != 0:
+			if ch == quote || ch == '\n' {
+				quote = 0
+			}
+			t.advance()
+			continue
+		case ch == '"' || ch == '\'':
+			quote = ch
+			t.advance()
+			continue
+		case ch == '(':
+			depth++
+		case ch == ')' && depth > 0:
+			depth--
+		}
+		if ch == '{' || ch == '}' {
+			break
+		}
+		if depth > 0 {
+			t.advance()
+			continue
+		}
+		if ch == ':' || ch == ';' {
+			break
+		}
+		if ch == '/' && t.pos+1 < len(t.content) && t.content[t.pos+1] == '*' {
 			break
 		}
 		if ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' {
@@ -228,6 +314,10 @@ type CSSPropertyDecl struct {
 	Offset   int
 	Line     int
 	Col      int
+	// AtRule is the index, in the at-rules returned with the declaration,
+	// of the at-rule whose block directly contains it, as for the
+	// descriptors of @font-face, or -1 inside a style rule.
+	AtRule int
 }
 
 // CSSAtRule represents an @-rule found by scanning.
@@ -236,6 +326,9 @@ type CSSAtRule struct {
 	Offset int
 	Line   int
 	Col    int
+	// Prelude is the text between the name and the rule's block or ';'.
+	Prelude       string
+	PreludeOffset int
 }
 
 // ScanCSS extracts property declarations and @-rules from CSS content.
@@ -259,6 +352,11 @@ func ScanCSS(content []byte) ([]CSSPropertyDecl, []CSSAtRule, []epub.Diagnostic)
 
 	tok := NewCSSTokenizer(content)
 	braceDepth := 0
+	// blocks holds, for each open block, the index of the at-rule that
+	// opened it or -1 for a style rule; pending is the at-rule whose
+	// block, if any, has not opened yet.
+	var blocks []int
+	pending := -1
 
 	for {
 		t := tok.Next()
@@ -278,18 +376,27 @@ func ScanCSS(content []byte) ([]CSSPropertyDecl, []CSSAtRule, []epub.Diagnostic)
 			})
 
 		case CSSTokenAtRule:
+			pending = len(atRules)
 			atRules = append(atRules, CSSAtRule{
-				Name:   t.Value,
-				Offset: t.Offset,
-				Line:   t.Line,
-				Col:    t.Col,
+				Name:          t.Value,
+				Offset:        t.Offset,
+				Line:          t.Line,
+				Col:           t.Col,
+				Prelude:       t.Prelude,
+				PreludeOffset: t.PreludeOffset,
 			})
 
+		case CSSTokenSemicolon:
+			pending = -1
+
 		case CSSTokenBraceOpen:
 			braceDepth++
+			blocks = append(blocks, pending)
+			pending = -1
 
 		case CSSTokenBraceClose:
 			braceDepth--
+			blocks = popBlock(blocks)
 			if braceDepth < 0 {
 				pos := epub.Position{Line: t.Line, Character: t.Col}
 				diags = append(diags, epub.Diagnostic{
@@ -304,41 +411,14 @@ func ScanCSS(content []byte) ([]CSSPropertyDecl, []CSSAtRule, []epub.Diagnostic)
 
 		case CSSTokenProperty:
 			if braceDepth > 0 {
-				propName := t.Value
-				propLine := t.Line
-				propCol := t.Col
-				propOffset := t.Offset
-
-				// Look for colon then value
-				next := tok.Next()
-				if next.Type == CSSTokenColon {
-					// Scan the value up to semicolon or brace close
-					valParts := ""
-					for {
-						vt := tok.Next()
-						if vt.Type == CSSTokenSemicolon ||
-							vt.Type == CSSTokenBraceClose ||
-							vt.Type == CSSTokenEOF {
-							if vt.Type == CSSTokenBraceClose {
-								braceDepth--
-							}
-							break
-						}
-						if valParts != "" {
-							valParts += " "
-						}
-						valParts += vt.Value
-					}
-					props = append(props, CSSPropertyDecl{
-						Property: propName,
-						Value:    valParts,
-						Offset:   propOffset,
-						Line:     propLine,
-						Col:      propCol,
-					})
-				} else {
-					// Not a property declaration (likely a selector); push back
-					tok.Unread(next)
+				decl, closed, ok := scanDeclaration(tok, t)
+				if ok {
+					decl.AtRule = blocks[len(blocks)-1]
+					props = append(props, decl)
+				}
+				if closed {
+					braceDepth--
+					blocks = popBlock(blocks)
 				}
 			}
 		}
@@ -357,3 +437,123 @@ func ScanCSS(content []byte) ([]CSSPropertyDecl, []CSSAtRule, []epub.Diagnostic)
 
 	return props, atRules, diags
 }
+
+// popBlock closes the innermost open block, if any.
+func popBlock(blocks []int) []int {
+	if len(blocks) == 0 {
+		return blocks
+	}
+	return blocks[:len(blocks)-1]
+}
+
+// ParseDeclarations parses a declaration list without braces, such as the
+// value of a style attribute. Offsets, lines, and columns are relative to
+// text.
+func ParseDeclarations(text string) []CSSPropertyDecl {
+	var decls []CSSPropertyDecl
+	tok := NewCSSTokenizer([]byte(text))
+	for {
+		t := tok.Next()
+		switch t.Type {
+		case CSSTokenEOF:
+			return decls
+		case CSSTokenProperty:
+			if decl, _, ok := scanDeclaration(tok, t); ok {
+				decls = append(decls, decl)
+			}
+		}
+	}
+}
+
+// scanDeclaration reads the value of the declaration whose property token
+// is prop, up to a semicolon or the end of the block. When prop is not
+// followed by a colon, as for a selector, the next token is pushed back and
+// ok is false. closed reports whether the value ended at a '}'.
+func scanDeclaration(
+	tok *CSSTokenizer,
+	prop CSSToken,
+) (decl CSSPropertyDecl, closed, ok bool) {
+	next := tok.Next()
+	if next.Type != CSSTokenColon {
+		tok.Unread(next)
+		return decl, false, false
+	}
+
+	value := ""
+	for {
+		vt := tok.Next()
+		if vt.Type == CSSTokenSemicolon ||
+			vt.Type == CSSTokenBraceClose ||
+			vt.Type == CSSTokenEOF {
+			closed = vt.Type == CSSTokenBraceClose
+			break
+		}
+		if value != "" {
+			value += " "
+		}
+		value += vt.Value
+	}
+	return CSSPropertyDecl{
+		Property: prop.Value,
+		Value:    value,
+		Offset:   prop.Offset,
+		Line:     prop.Line,
+		Col:      prop.Col,
+		AtRule:   -1,
+	}, closed, true
+}
+
+// CSSURL is the argument of a url() function.
+type CSSURL struct {
+	Value  string
+	Offset int // byte offset of Value within the scanned text
+}
+
+// CSSURLs returns the url() arguments in CSS text, unquoted, skipping any
+// that appear inside comments.
+func CSSURLs(text string) []CSSURL {
+	var urls []CSSURL
+	lower := strings.ToLower(text)
+	for i := 0; i < len(text); i++ {
+		if strings.HasPrefix(text[i:], "/*") {
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				break
+			}
+			i += end + 3
+			continue
+		}
+		if !strings.HasPrefix(lower[i:], "url(") || i > 0 && isCSSNameByte(lower[i-1]) {
+			continue
+		}
+
+		start := i + 4
+		for start < len(text) && isCSSSpace(text[start]) {
+			start++
+		}
+		end := start
+		if start < len(text) && (text[start] == '"' || text[start] == '\'') {
+			quote := text[start]
+			start++
+			end = start
+			for end < len(text) && text[end] != quote {
+				end++
+			}
+		} else {
+			for end < len(text) && text[end] != ')' && !isCSSSpace(text[end]) {
+				end++
+			}
+		}
+		urls = append(urls, CSSURL{Value: text[start:end], Offset: start})
+		i = end
+	}
+	return urls
+}
+
+func isCSSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isCSSNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
@@ -124,6 +124,98 @@ func TestScanCSS_AtRules(t *testing.T) {
 	}
 }
 
+func TestScanCSS_AtRuleScope(t *testing.T) {
+	content := []byte(`@import url("base.css");
+p { color: red; }
+@font-face { font-family: "A"; src: url(a.woff) }
+@media screen {
+  @font-face { font-family: "B" }
+  h1 { font-family: "B" }
+}
+em { font-style: normal }`)
+
+	props, atRules, _ := ScanCSS(content)
+	want := []struct{ property, atRule string }{
+		{"color", ""},
+		{"font-family", "@font-face"},
+		{"src", "@font-face"},
+		{"font-family", "@font-face"},
+		{"font-family", ""},
+		{"font-style", ""},
+	}
+	if len(props) != len(want) {
+		t.Fatalf("expected %d declarations, got %+v", len(want), props)
+	}
+	for i, prop := range props {
+		atRule := ""
+		if prop.AtRule >= 0 {
+			atRule = atRules[prop.AtRule].Name
+		}
+		if prop.Property != want[i].property || atRule != want[i].atRule {
+			t.Errorf("declaration %d: %s in %q, want %s in %q",
+				i, prop.Property, atRule, want[i].property, want[i].atRule)
+		}
+	}
+}
+
+func TestScanCSS_AtRulePrelude(t *testing.T) {
+	content := `@import url("a;b.css") screen and (min-width: 10px);
+@media print {}
+@font-face{}`
+
+	_, atRules, _ := ScanCSS([]byte(content))
+	want := []string{`url("a;b.css") screen and (min-width: 10px)`, "print", ""}
+	if len(atRules) != len(want) {
+		t.Fatalf("expected %d at-rules, got %d", len(want), len(atRules))
+	}
+	for i, at := range atRules {
+		if at.Prelude != want[i] {
+			t.Errorf("%s prelude = %q, want %q", at.Name, at.Prelude, want[i])
+		}
+		got := content[at.PreludeOffset : at.PreludeOffset+len(at.Prelude)]
+		if got != at.Prelude {
+			t.Errorf("%s prelude offset points at %q", at.Name, got)
+		}
+	}
+}
+
+func TestScanCSS_SlashInValue(t *testing.T) {
+	content := []byte(`p { background: url(images/bg.png); font: 12px/1.5 serif }`)
+
+	props, _, _ := ScanCSS(content)
+	if len(props) != 2 {
+		t.Fatalf("expected 2 properties, got %d", len(props))
+	}
+	if props[0].Value != "url(images/bg.png)" {
+		t.Errorf("unexpected background value %q", props[0].Value)
+	}
+}
+
+func TestScanCSS_CustomProperties(t *testing.T) {
+	content := []byte(`:root { --brand-color: #336; --link: var(--brand-color, #000) }
+a { color: var(--link); background: url(data:image/png;base64,AAAA) }`)
+
+	props, _, diags := ScanCSS(content)
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+	want := [][2]string{
+		{"--brand-color", "#336"},
+		{"--link", "var(--brand-color, #000)"},
+		{"color", "var(--link)"},
+		{"background", "url(data:image/png;base64,AAAA)"},
+	}
+	if len(props) != len(want) {
+		t.Fatalf("expected %d properties, got %+v", len(want), props)
+	}
+	for i, w := range want {
+		if props[i].Property != w[0] || props[i].Value != w[1] {
+			t.Errorf("property %d: expected %s: %s, got %s: %s",
+				i, w[0], w[1], props[i].Property, props[i].Value)
+		}
+	}
+}
+
 func TestScanCSS_PropertyValues(t *testing.T) {
 	content := []byte(`
 div {
@@ -152,3 +244,44 @@ div {
 		t.Errorf("expected direction: rtl, got %s: %s", props[1].Property, props[1].Value)
 	}
 }
+
+func TestParseDeclarations(t *testing.T) {
+	decls := ParseDeclarations(" font-size: 1.8em;font-weight:bold ; color : red")
+
+	want := []CSSPropertyDecl{
+		{Property: "font-size", Value: "1.8em", Offset: 1, Col: 1, AtRule: -1},
+		{Property: "font-weight", Value: "bold", Offset: 18, Col: 18, AtRule: -1},
+		{Property: "color", Value: "red", Offset: 37, Col: 37, AtRule: -1},
+	}
+	if len(decls) != len(want) {
+		t.Fatalf("expected %d declarations, got %+v", len(want), decls)
+	}
+	for i := range want {
+		if decls[i] != want[i] {
+			t.Errorf("declaration %d: expected %+v, got %+v", i, want[i], decls[i])
+		}
+	}
+
+	if got := ParseDeclarations("no-colon; ;"); len(got) != 0 {
+		t.Errorf("expected no declarations, got %+v", got)
+	}
+}
+
+func TestCSSURLs(t *testing.T) {
+	text := `a { background: url( "a.png" ) } /* url(skip.png) */` +
+		` b { src: URL(b.woff) format("woff"), url('c d.otf') } c { x: myurl(no) }`
+	urls := CSSURLs(text)
+
+	want := []string{"a.png", "b.woff", "c d.otf"}
+	if len(urls) != len(want) {
+		t.Fatalf("expected %d URLs, got %+v", len(want), urls)
+	}
+	for i, u := range urls {
+		if u.Value != want[i] {
+			t.Errorf("URL %d = %q, want %q", i, u.Value, want[i])
+		}
+		if got := text[u.Offset : u.Offset+len(u.Value)]; got != u.Value {
+			t.Errorf("URL %d offset points at %q", i, got)
+		}
+	}
+}
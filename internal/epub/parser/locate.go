@@ -3,13 +3,24 @@ package parser
 import (
 	"bytes"
 	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
 )
 
 // LocateResult describes what XML construct the cursor is on.
 type LocateResult struct {
-	Node    *XMLNode
-	Attr    *XMLAttr // nil if not on an attribute
-	InValue bool     // true if inside attribute value
+	Node        *XMLNode
+	Attr        *XMLAttr // nil if not on an attribute
+	OnName      bool     // true if on the attribute name
+	InValue     bool     // true if inside attribute value
+	ValueOffset int      // cursor offset within the value when InValue
+	InText      bool     // true if in the element's content after the start tag
+}
+
+// OnAttribute reports whether the cursor is on an attribute's name or value,
+// as opposed to the whitespace or '=' around it.
+func (r *LocateResult) OnAttribute() bool {
+	return r.Attr != nil && (r.OnName || r.InValue)
 }
 
 // LocateAtPosition walks an XML tree and the raw content to determine
@@ -31,13 +42,18 @@ func LocateAtPosition(root *XMLNode, content []byte, offset int) *LocateResult {
 
 	if offset >= tagStart && offset <= tagEnd {
 		// Within the start tag — check attributes
-		attr, inValue := locateAttribute(content, tagStart, tagEnd, offset, node)
-		if attr != nil {
-			return &LocateResult{Node: node, Attr: attr, InValue: inValue}
+		result := locateAttribute(content, tagStart, tagEnd, offset, node)
+		if result != nil {
+			return result
 		}
+		return &LocateResult{Node: node}
 	}
 
-	return &LocateResult{Node: node}
+	// A cursor just before the end tag's '<' is still in the content, which
+	// for an empty element is the only place it can be.
+	selfClosing := content[tagEnd-1] == '/'
+	inText := !selfClosing && offset <= closeTagStart(content, node, tagEnd)
+	return &LocateResult{Node: node, InText: inText}
 }
 
 // findDeepestNode returns the deepest XMLNode whose span covers offset.
@@ -49,8 +65,12 @@ func findDeepestNode(node *XMLNode, content []byte, offset int) *XMLNode {
 		if deeper := findDeepestNode(child, content, offset); deeper != nil {
 			return deeper
 		}
-		// Check if offset falls within this child's span
-		childEnd := findElementEnd(content, int(child.Offset), child.Local)
+		// Check if offset falls within this child's span, up to the '>' of
+		// its end tag
+		childEnd := int(child.End) - 1
+		if child.End == 0 {
+			childEnd = findElementEnd(content, int(child.Offset))
+		}
 		if offset >= int(child.Offset) && offset <= childEnd {
 			return child
 		}
@@ -85,115 +105,499 @@ func findStartTagEnd(content []byte, tagStart int) int {
 	return len(content) - 1
 }
 
-// findElementEnd finds the approximate end of an element by looking for
-// the closing tag. Falls back to end of start tag if not found.
-func findElementEnd(content []byte, tagStart int, local string) int {
-	// First find end of start tag
+// closeTagStart returns the offset of the '<' of node's end tag, whose
+// name may carry a prefix, or len(content) if it has none.
+func closeTagStart(content []byte, node *XMLNode, startTagEnd int) int {
+	if _, end := TagNameSpans(content, int(node.Offset)); end[0] >= 2 {
+		return end[0] - 2
+	}
+	return findCloseTagStart(content, startTagEnd, node.Local)
+}
+
+// findCloseTagStart returns the offset of the '<' of the end tag matching
+// the start tag ending at startTagEnd, for an element named local with any
+// prefix, skipping nested elements of that name, comments, and CDATA
+// sections. It returns len(content) if there is none.
+func findCloseTagStart(content []byte, startTagEnd int, local string) int {
+	name := []byte(local)
+	depth := 1
+	for i := startTagEnd + 1; i < len(content); i++ {
+		if content[i] != '<' {
+			continue
+		}
+		rest := content[i:]
+		switch {
+		case startsWith(rest, "<!--"):
+			i = skipPast(content, i, "-->")
+		case startsWith(rest, "<![CDATA["):
+			i = skipPast(content, i, "]]>")
+		case startsWith(rest, "</") && hasLocalName(rest[2:], name):
+			if depth--; depth == 0 {
+				return i
+			}
+		case hasLocalName(rest[1:], name):
+			tagEnd := findStartTagEnd(content, i)
+			if content[tagEnd-1] != '/' {
+				depth++
+			}
+			i = tagEnd
+		}
+	}
+	return len(content)
+}
+
+// findElementEnd finds the '>' of the end tag matching the start tag at
+// tagStart, skipping nested elements of the same name. It falls back to
+// the end of the start tag for self-closing elements and when there is no
+// end tag.
+func findElementEnd(content []byte, tagStart int) int {
+	_, end := TagNameSpans(content, tagStart)
+	if end[0] < 0 {
+		return findStartTagEnd(content, tagStart)
+	}
+	if idx := bytes.IndexByte(content[end[1]:], '>'); idx >= 0 {
+		return end[1] + idx
+	}
+	return len(content) - 1
+}
+
+// ElementSpan returns the content of the element whose start tag begins at
+// tagStart, from just past its start tag to the first closing tag after it,
+// and the offset just past the element. It is exact for elements without
+// child elements. A self-closing element has empty content at its "/>".
+func ElementSpan(content []byte, tagStart int) (contentStart, contentEnd, end int) {
 	startTagEnd := findStartTagEnd(content, tagStart)
+	if startTagEnd > 0 && content[startTagEnd-1] == '/' {
+		return startTagEnd - 1, startTagEnd - 1, startTagEnd + 1
+	}
+
+	contentStart = startTagEnd + 1
+	idx := bytes.Index(content[contentStart:], []byte("</"))
+	if idx < 0 {
+		return contentStart, len(content), len(content)
+	}
+	contentEnd = contentStart + idx
+	closeEnd := bytes.IndexByte(content[contentEnd:], '>')
+	if closeEnd < 0 {
+		return contentStart, contentEnd, len(content)
+	}
+	return contentStart, contentEnd, contentEnd + closeEnd + 1
+}
+
+// TagNameSpans returns the spans of the element name, prefix included, in
+// the start tag beginning at tagStart and in its matching end tag, skipping
+// nested elements of the same name, comments, and CDATA sections. The end
+// span is -1, -1 for a self-closing element or when there is no end tag.
+func TagNameSpans(content []byte, tagStart int) (start, end [2]int) {
+	end = [2]int{-1, -1}
+	nameEnd := tagStart + 1
+	for nameEnd < len(content) && !isXMLSpace(content[nameEnd]) &&
+		content[nameEnd] != '/' && content[nameEnd] != '>' {
+		nameEnd++
+	}
+	start = [2]int{tagStart + 1, nameEnd}
+	name := content[start[0]:start[1]]
 
-	// Check if self-closing
+	startTagEnd := findStartTagEnd(content, tagStart)
 	if startTagEnd > 0 && content[startTagEnd-1] == '/' {
-		return startTagEnd
-	}
-
-	// Look for closing tag
-	closeTag := []byte("</" + local)
-	idx := bytes.Index(content[startTagEnd:], closeTag)
-	if idx >= 0 {
-		endIdx := startTagEnd + idx + len(closeTag)
-		// Find the > of the close tag
-		for endIdx < len(content) {
-			if content[endIdx] == '>' {
-				return endIdx
+		return start, end
+	}
+
+	depth := 1
+	for i := startTagEnd + 1; i < len(content); i++ {
+		if content[i] != '<' {
+			continue
+		}
+		rest := content[i:]
+		switch {
+		case startsWith(rest, "<!--"):
+			i = skipPast(content, i, "-->")
+		case startsWith(rest, "<![CDATA["):
+			i = skipPast(content, i, "]]>")
+		case startsWith(rest, "</") && hasTagName(rest[2:], name):
+			if depth--; depth == 0 {
+				return start, [2]int{i + 2, i + 2 + len(name)}
+			}
+		case hasTagName(rest[1:], name):
+			tagEnd := findStartTagEnd(content, i)
+			if content[tagEnd-1] != '/' {
+				depth++
+			}
+			i = tagEnd
+		}
+	}
+	return start, end
+}
+
+// LinkedTagNames returns the spans of the element name in a start tag and
+// in its matching end tag when offset is on or just after either name, for
+// editing the two together. ok is false when offset is not on a tag name
+// or the element has no separate end tag.
+func LinkedTagNames(content []byte, offset int) (start, end [2]int, ok bool) {
+	if offset <= 0 || offset > len(content) {
+		return start, end, false
+	}
+	lt := bytes.LastIndexByte(content[:offset], '<')
+	if lt < 0 || lt+1 >= len(content) {
+		return start, end, false
+	}
+	closing := content[lt+1] == '/'
+	nameStart := lt + 1
+	if closing {
+		nameStart++
+	}
+	nameEnd := nameStart
+	for nameEnd < len(content) && isNameByte(content[nameEnd]) {
+		nameEnd++
+	}
+	if nameEnd == nameStart || offset < nameStart || offset > nameEnd || !isNameStartByte(content[nameStart]) {
+		return start, end, false
+	}
+
+	if !closing {
+		start, end = TagNameSpans(content, lt)
+		return start, end, end[0] >= 0
+	}
+	tagStart, found := matchingStartTag(content, lt, content[nameStart:nameEnd])
+	if !found {
+		return start, end, false
+	}
+	start, end = TagNameSpans(content, tagStart)
+	if end[0] != nameStart {
+		return start, end, false
+	}
+	return start, end, true
+}
+
+// matchingStartTag returns the offset of the start tag matched by the end
+// tag of name at endTag, pairing tags of the same name by balance and
+// skipping comments, CDATA sections, processing instructions, and
+// declarations.
+func matchingStartTag(content []byte, endTag int, name []byte) (int, bool) {
+	var open []int
+	for i := 0; i < endTag; i++ {
+		if content[i] != '<' {
+			continue
+		}
+		rest := content[i:]
+		switch {
+		case startsWith(rest, "<!--"):
+			i = skipPast(content, i, "-->")
+		case startsWith(rest, "<![CDATA["):
+			i = skipPast(content, i, "]]>")
+		case startsWith(rest, "<?"):
+			i = skipPast(content, i, "?>")
+		case startsWith(rest, "<!"):
+			i = findStartTagEnd(content, i)
+		case startsWith(rest, "</") && hasTagName(rest[2:], name):
+			if len(open) > 0 {
+				open = open[:len(open)-1]
 			}
-			endIdx++
+		case hasTagName(rest[1:], name):
+			tagEnd := findStartTagEnd(content, i)
+			if content[tagEnd-1] != '/' {
+				open = append(open, i)
+			}
+			i = tagEnd
 		}
 	}
+	if len(open) == 0 {
+		return 0, false
+	}
+	return open[len(open)-1], true
+}
 
-	return startTagEnd
+// isNameStartByte reports whether b can begin an XML name. Bytes of
+// multibyte characters are accepted.
+func isNameStartByte(b byte) bool {
+	return b == '_' || b == ':' || b >= 0x80 ||
+		'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// isNameByte reports whether b can appear in an XML name.
+func isNameByte(b byte) bool {
+	return isNameStartByte(b) || b == '-' || b == '.' || '0' <= b && b <= '9'
+}
+
+// hasTagName reports whether b starts with name followed by the end of a
+// tag name.
+func hasTagName(b, name []byte) bool {
+	if !bytes.HasPrefix(b, name) {
+		return false
+	}
+	if len(b) == len(name) {
+		return true
+	}
+	c := b[len(name)]
+	return isXMLSpace(c) || c == '/' || c == '>'
+}
+
+// hasLocalName reports whether b starts with a tag name whose local part,
+// after any prefix, is local.
+func hasLocalName(b, local []byte) bool {
+	n := 0
+	for n < len(b) && isNameByte(b[n]) {
+		n++
+	}
+	name := b[:n]
+	if i := bytes.LastIndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	return bytes.Equal(name, local)
+}
+
+// AttrValueSpan returns the offsets of the value of the attribute written
+// as name, prefix included, in the start tag at tagStart, from the first
+// byte after the opening quote to the closing quote. ok is false when the
+// tag has no such attribute.
+func AttrValueSpan(content []byte, tagStart int, name string) (start, end int, ok bool) {
+	if tagStart < 0 || tagStart >= len(content) {
+		return 0, 0, false
+	}
+	for _, span := range scanAttrSpans(content, tagStart, findStartTagEnd(content, tagStart)) {
+		if span.Name == name {
+			return span.ValueStart, span.ValueEnd, true
+		}
+	}
+	return 0, 0, false
+}
+
+// conventionalPrefixes maps the namespace prefixes EPUB documents
+// conventionally use to their namespaces, so attributes can be named as
+// they are usually written.
+var conventionalPrefixes = map[string]string{
+	"epub":  epub.NSEpub,
+	"xml":   epub.NSXML,
+	"xlink": epub.NSXLink,
+}
+
+// AttrValueRange returns the offsets of the value of node's attribute name
+// in content, from the first byte after the opening quote to the closing
+// quote. name is written as in the source, such as "href" or "epub:type";
+// a prefixed attribute is also found under another prefix the document
+// binds to the same namespace. When the tag repeats the name, the one
+// whose raw value matches the parsed value wins. ok is false when node has
+// no such attribute.
+func AttrValueRange(content []byte, node *XMLNode, name string) (start, end int, ok bool) {
+	tagStart := int(node.Offset)
+	if tagStart < 0 || tagStart >= len(content) {
+		return 0, 0, false
+	}
+
+	prefix, local, prefixed := strings.Cut(name, ":")
+	space := ""
+	if !prefixed {
+		local, prefix = prefix, ""
+	} else if space = conventionalPrefixes[prefix]; space == "" {
+		space = prefix
+	}
+	value, found := node.lookupAttr(space, local)
+	if !found && prefixed {
+		// An undeclared prefix keeps its name as the namespace
+		value, found = node.lookupAttr(prefix, local)
+	}
+	if !found {
+		return 0, 0, false
+	}
+
+	tagEnd := findStartTagEnd(content, tagStart)
+	bound := namespacePrefixes(string(content[tagStart : tagEnd+1]))
+
+	var exact, aliased []attrSpan
+	for _, span := range scanAttrSpans(content, tagStart, tagEnd) {
+		spanPrefix, spanLocal, spanPrefixed := strings.Cut(span.Name, ":")
+		if !spanPrefixed {
+			spanLocal, spanPrefix = spanPrefix, ""
+		}
+		switch {
+		case spanLocal != local || spanPrefixed != prefixed:
+		case spanPrefix == prefix:
+			exact = append(exact, span)
+		case bindsOther(spanPrefix, space, bound):
+		default:
+			aliased = append(aliased, span)
+		}
+	}
+
+	for _, spans := range [][]attrSpan{exact, aliased} {
+		for _, span := range spans {
+			if string(content[span.ValueStart:span.ValueEnd]) == value {
+				return span.ValueStart, span.ValueEnd, true
+			}
+		}
+	}
+	// The raw value differs from the parsed one when it has entity
+	// references, so settle for the first candidate
+	for _, spans := range [][]attrSpan{exact, aliased} {
+		if len(spans) > 0 {
+			return spans[0].ValueStart, spans[0].ValueEnd, true
+		}
+	}
+	return 0, 0, false
+}
+
+// bindsOther reports whether prefix is known to name a namespace other
+// than space, either on the tag itself (bound maps namespaces to prefixes)
+// or by convention.
+func bindsOther(prefix, space string, bound map[string]string) bool {
+	for uri, p := range bound {
+		if p == prefix {
+			return uri != space
+		}
+	}
+	if uri, ok := conventionalPrefixes[prefix]; ok {
+		return uri != space
+	}
+	return false
+}
+
+// AttrTokenSpans returns the offsets of each whitespace-separated token in
+// the value of the attribute written as name in the start tag at tagStart,
+// as for epub:type and class. The tokens are raw source text, so entity
+// references are not expanded.
+func AttrTokenSpans(content []byte, tagStart int, name string) [][2]int {
+	start, end, ok := AttrValueSpan(content, tagStart, name)
+	if !ok {
+		return nil
+	}
+
+	var spans [][2]int
+	for i := start; i < end; {
+		for i < end && isXMLSpace(content[i]) {
+			i++
+		}
+		tokenStart := i
+		for i < end && !isXMLSpace(content[i]) {
+			i++
+		}
+		if i > tokenStart {
+			spans = append(spans, [2]int{tokenStart, i})
+		}
+	}
+	return spans
+}
+
+// attrSpan records where an attribute's name and value sit in raw content.
+type attrSpan struct {
+	Name       string
+	NameStart  int
+	NameEnd    int // exclusive
+	ValueStart int // first byte after the opening quote
+	ValueEnd   int // offset of the closing quote
+}
+
+// scanAttrSpans scans the raw start tag spanning tagStart..tagEnd (the
+// closing '>') and returns its attributes in source order. Whitespace around
+// '=' and either quote style are allowed.
+func scanAttrSpans(content []byte, tagStart, tagEnd int) []attrSpan {
+	i := tagStart + 1
+	for i < tagEnd && !isXMLSpace(content[i]) && content[i] != '/' {
+		i++
+	}
+
+	var spans []attrSpan
+	for i < tagEnd {
+		for i < tagEnd && (isXMLSpace(content[i]) || content[i] == '/') {
+			i++
+		}
+		nameStart := i
+		for i < tagEnd && !isXMLSpace(content[i]) &&
+			content[i] != '=' && content[i] != '/' {
+			i++
+		}
+		nameEnd := i
+		for i < tagEnd && isXMLSpace(content[i]) {
+			i++
+		}
+		if i >= tagEnd || content[i] != '=' {
+			continue
+		}
+		i++
+		for i < tagEnd && isXMLSpace(content[i]) {
+			i++
+		}
+		if i >= tagEnd || (content[i] != '"' && content[i] != '\'') {
+			continue
+		}
+		quote := content[i]
+		i++
+		valueStart := i
+		for i < tagEnd && content[i] != quote {
+			i++
+		}
+		spans = append(spans, attrSpan{
+			Name:       string(content[nameStart:nameEnd]),
+			NameStart:  nameStart,
+			NameEnd:    nameEnd,
+			ValueStart: valueStart,
+			ValueEnd:   i,
+		})
+		i++
+	}
+	return spans
+}
+
+// isXMLSpace reports whether b is XML whitespace.
+func isXMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
 }
 
 // locateAttribute checks if the offset is within an attribute of the tag.
+// The attribute spans from the first byte of its name to its closing quote;
+// a cursor on the closing quote is at the end of the value.
 func locateAttribute(
 	content []byte,
 	tagStart, tagEnd, offset int,
 	node *XMLNode,
-) (*XMLAttr, bool) {
-	// Extract the tag text
+) *LocateResult {
 	if tagEnd >= len(content) {
 		tagEnd = len(content) - 1
 	}
-	tagText := string(content[tagStart : tagEnd+1])
-
-	for i := range node.Attrs {
-		attr := &node.Attrs[i]
-		// Find attribute in the tag text
-		attrName := attr.Local
-		if attr.Space != "" {
-			// For namespaced attributes, try the prefix form
-			// We search raw text for common patterns
-			prefixes := namespacePrefixes(tagText)
-			if prefix, ok := prefixes[attr.Space]; ok {
-				attrName = prefix + ":" + attr.Local
-			}
-		}
+	prefixes := namespacePrefixes(string(content[tagStart : tagEnd+1]))
 
-		// Find name="value" or name='value'
-		attrPos := findAttributeInTag(tagText, attrName, attr.Value)
-		if attrPos < 0 {
+	for _, span := range scanAttrSpans(content, tagStart, tagEnd) {
+		if offset < span.NameStart || offset > span.ValueEnd {
 			continue
 		}
-
-		absStart := tagStart + attrPos
-		// Entire attribute span: name="value"
-		attrLen := len(attrName) + 1 + 1 + len(attr.Value) + 1 // name="value"
-		absEnd := absStart + attrLen
-
-		if offset >= absStart && offset < absEnd {
-			// Determine if we're in the value portion
-			valueStart := absStart + len(attrName) + 2 // skip name="
-			inValue := offset >= valueStart && offset <= valueStart+len(attr.Value)
-			return attr, inValue
+		attr := matchAttr(node, span.Name, prefixes)
+		if attr == nil {
+			return nil
+		}
+		result := &LocateResult{
+			Node:   node,
+			Attr:   attr,
+			OnName: offset < span.NameEnd,
 		}
+		if offset >= span.ValueStart {
+			result.InValue = true
+			result.ValueOffset = offset - span.ValueStart
+		}
+		return result
 	}
 
-	return nil, false
+	return nil
 }
 
-// findAttributeInTag finds attribute name="value" in the tag text, returning
-// the byte offset of the attribute name within tagText.
-func findAttributeInTag(tagText, name, value string) int {
-	search := tagText
-	base := 0
-	for {
-		idx := strings.Index(search, name)
-		if idx < 0 {
-			return -1
-		}
+// matchAttr returns the parsed attribute of node written as rawName in the
+// source, resolving a namespace prefix through prefixes where declared on
+// the same tag.
+func matchAttr(node *XMLNode, rawName string, prefixes map[string]string) *XMLAttr {
+	prefix, local, hasPrefix := strings.Cut(rawName, ":")
+	if !hasPrefix {
+		local, prefix = prefix, ""
+	}
 
-		// Verify it's followed by = then a quote
-		rest := search[idx+len(name):]
-		rest = strings.TrimLeft(rest, " \t\n\r")
-		if rest != "" && rest[0] == '=' {
-			rest = rest[1:]
-			rest = strings.TrimLeft(rest, " \t\n\r")
-			if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
-				q := rest[0]
-				endQ := strings.IndexByte(rest[1:], q)
-				if endQ >= 0 {
-					foundValue := rest[1 : 1+endQ]
-					if foundValue == value {
-						return base + idx
-					}
-				}
-			}
+	for i := range node.Attrs {
+		attr := &node.Attrs[i]
+		if attr.Local != local || (attr.Space == "") != (prefix == "") {
+			continue
 		}
-
-		// Keep searching past this match
-		advance := idx + len(name)
-		search = search[advance:]
-		base += advance
+		if p, ok := prefixes[attr.Space]; ok && prefix != "" && p != prefix {
+			continue
+		}
+		return attr
 	}
+	return nil
 }
 
 // namespacePrefixes extracts xmlns:prefix="uri" declarations from tag text.
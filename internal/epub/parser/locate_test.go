@@ -1,6 +1,9 @@
 package parser
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestLocateAtPosition_OnElement(t *testing.T) {
 	content := []byte(`<root><child attr="value">text</child></root>`)
@@ -100,3 +103,326 @@ func TestNamespacePrefixes(t *testing.T) {
 		)
 	}
 }
+
+func TestLocateAtPosition_AttributeBoundaries(t *testing.T) {
+	content := []byte(`<root><a class="c" href = 'x.xhtml'>text</a></root>`)
+	root, _ := Parse(content)
+
+	name := strings.Index(string(content), "href")
+	eq := strings.Index(string(content[name:]), "=") + name
+	openQuote := strings.Index(string(content), "'")
+	closeQuote := strings.LastIndex(string(content), "'")
+
+	tests := []struct {
+		name        string
+		offset      int
+		wantAttr    bool
+		onName      bool
+		inValue     bool
+		valueOffset int
+	}{
+		{"before name", name - 1, false, false, false, 0},
+		{"start of name", name, true, true, false, 0},
+		{"on name", name + 3, true, true, false, 0},
+		{"on =", eq, true, false, false, 0},
+		{"on opening quote", openQuote, true, false, false, 0},
+		{"start of value", openQuote + 1, true, false, true, 0},
+		{"inside value", openQuote + 3, true, false, true, 2},
+		{"on closing quote", closeQuote, true, false, true, len("x.xhtml")},
+		{"just after", closeQuote + 1, false, false, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := LocateAtPosition(root, content, tt.offset)
+			if result == nil {
+				t.Fatal("expected non-nil result")
+			}
+			if result.Node.Local != "a" {
+				t.Fatalf("expected node 'a', got %q", result.Node.Local)
+			}
+			if !tt.wantAttr {
+				if result.Attr != nil {
+					t.Errorf("expected no attribute, got %q", result.Attr.Local)
+				}
+				return
+			}
+			if result.Attr == nil || result.Attr.Local != "href" {
+				t.Fatalf("expected href attribute, got %+v", result.Attr)
+			}
+			if result.OnName != tt.onName {
+				t.Errorf("OnName = %v, want %v", result.OnName, tt.onName)
+			}
+			if result.InValue != tt.inValue {
+				t.Errorf("InValue = %v, want %v", result.InValue, tt.inValue)
+			}
+			if result.ValueOffset != tt.valueOffset {
+				t.Errorf("ValueOffset = %d, want %d", result.ValueOffset, tt.valueOffset)
+			}
+		})
+	}
+}
+
+func TestLocateAtPosition_NamespacedAttribute(t *testing.T) {
+	content := []byte(
+		`<html xmlns:epub="http://www.idpf.org/2007/ops">` +
+			`<section epub:type="chapter" type="x"/></html>`,
+	)
+	root, _ := Parse(content)
+
+	offset := strings.Index(string(content), "chapter")
+	result := LocateAtPosition(root, content, offset)
+	if result == nil || result.Attr == nil {
+		t.Fatal("expected attribute to be located")
+	}
+	if result.Attr.Space != "http://www.idpf.org/2007/ops" ||
+		result.Attr.Value != "chapter" {
+		t.Errorf("expected epub:type, got %+v", result.Attr)
+	}
+
+	offset = strings.Index(string(content), `"x"`) + 1
+	result = LocateAtPosition(root, content, offset)
+	if result == nil || result.Attr == nil {
+		t.Fatal("expected attribute to be located")
+	}
+	if result.Attr.Space != "" || result.Attr.Value != "x" {
+		t.Errorf("expected unprefixed type, got %+v", result.Attr)
+	}
+}
+
+func TestLocateAtPosition_InText(t *testing.T) {
+	content := []byte(`<root><p id="a">some text</p></root>`)
+	root, _ := Parse(content)
+
+	result := LocateAtPosition(root, content, strings.Index(string(content), "some"))
+	if result == nil || result.Node.Local != "p" {
+		t.Fatal("expected p node")
+	}
+	if !result.InText {
+		t.Error("expected InText to be true")
+	}
+
+	closing := strings.Index(string(content), "</p>")
+	result = LocateAtPosition(root, content, closing+1)
+	if result == nil || result.InText {
+		t.Error("expected InText to be false on the closing tag")
+	}
+
+	content = []byte(`<root><meta property="x"></meta></root>`)
+	root, _ = Parse(content)
+	result = LocateAtPosition(root, content, strings.Index(string(content), "</meta>"))
+	if result == nil || result.Node.Local != "meta" || !result.InText {
+		t.Errorf("expected InText between the tags of an empty element, got %+v", result)
+	}
+}
+
+func TestTagNameSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		startTag string
+		endTag   string
+	}{
+		{`<b class="x">bold</b>`, "b", "b"},
+		{`<b>outer <b>inner</b> <b/> more</b>`, "b", "b"},
+		{`<i><!-- </i> --><![CDATA[</i>]]>text</i>`, "i", "i"},
+		{`<b><br/>x</b>`, "b", "b"},
+		{`<x:b>t</x:b>`, "x:b", "x:b"},
+		{`<b/>`, "b", ""},
+		{`<b>unclosed`, "b", ""},
+	}
+	for _, tt := range tests {
+		start, end := TagNameSpans([]byte(tt.input), 0)
+		if got := tt.input[start[0]:start[1]]; got != tt.startTag {
+			t.Errorf("%s: start tag name %q, want %q", tt.input, got, tt.startTag)
+		}
+		if tt.endTag == "" {
+			if end[0] != -1 {
+				t.Errorf("%s: expected no end tag, got %v", tt.input, end)
+			}
+			continue
+		}
+		if end[0] < 0 {
+			t.Errorf("%s: expected an end tag", tt.input)
+			continue
+		}
+		if got := tt.input[end[0]:end[1]]; got != tt.endTag {
+			t.Errorf("%s: end tag name %q, want %q", tt.input, got, tt.endTag)
+		}
+		if end[1] != len(tt.input)-1 {
+			t.Errorf("%s: expected the last end tag, got %v", tt.input, end)
+		}
+	}
+}
+
+func TestFindElementEnd_Nested(t *testing.T) {
+	content := []byte(`<div><div>inner</div> tail</div><p/>`)
+	if got, want := findElementEnd(content, 0), len(`<div><div>inner</div> tail</div`); got != want {
+		t.Errorf("findElementEnd = %d, want %d", got, want)
+	}
+	if got, want := findElementEnd(content, 5), len(`<div><div>inner</div`); got != want {
+		t.Errorf("findElementEnd of inner div = %d, want %d", got, want)
+	}
+	if got, want := findElementEnd(content, len(content)-4), len(content)-1; got != want {
+		t.Errorf("findElementEnd of self-closing p = %d, want %d", got, want)
+	}
+}
+
+func TestFindCloseTagStart(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{`<span><span>x</span></span>`, len(`<span><span>x</span>`)},
+		{`<span><span/><!-- </span> -->x</span>`, len(`<span><span/><!-- </span> -->x`)},
+		{`<span><x:span>x</x:span><spanx>y</spanx></x:span>`,
+			len(`<span><x:span>x</x:span><spanx>y</spanx>`)},
+		{`<span><span>x</span>`, len(`<span><span>x</span>`)},
+	}
+	for _, tt := range tests {
+		content := []byte(tt.input)
+		if got := findCloseTagStart(content, len("<span"), "span"); got != tt.want {
+			t.Errorf("%s: findCloseTagStart = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLinkedTagNames(t *testing.T) {
+	const content = `<div id="a"><div>inner</div><!-- </div> --><br/></div>`
+	outer := [2][2]int{{1, 4}, {len(content) - 4, len(content) - 1}}
+	inner := [2][2]int{{13, 16}, {24, 27}}
+	tests := []struct {
+		name   string
+		offset int
+		want   [2][2]int
+		ok     bool
+	}{
+		{"outer start tag", 2, outer, true},
+		{"end of outer start name", 4, outer, true},
+		{"inner start tag", 13, inner, true},
+		{"inner end tag", 25, inner, true},
+		{"outer end tag", len(content) - 2, outer, true},
+		{"attribute", 6, [2][2]int{}, false},
+		{"text", 19, [2][2]int{}, false},
+		{"self-closing", len(content) - 9, [2][2]int{}, false},
+		{"on the angle bracket", 0, [2][2]int{}, false},
+	}
+	for _, tt := range tests {
+		start, end, ok := LinkedTagNames([]byte(content), tt.offset)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && (start != tt.want[0] || end != tt.want[1]) {
+			t.Errorf("%s: spans %v %v, want %v", tt.name, start, end, tt.want)
+		}
+	}
+}
+
+func TestElementSpan(t *testing.T) {
+	tests := []struct {
+		input, text, element string
+	}{
+		{`<dc:title id="t">  </dc:title> x`, "  ", `<dc:title id="t">  </dc:title>`},
+		{`<meta property="a>b"></meta>`, "", `<meta property="a>b"></meta>`},
+		{`<meta property="x"/><p/>`, "", `<meta property="x"/>`},
+	}
+	for _, tt := range tests {
+		contentStart, contentEnd, end := ElementSpan([]byte(tt.input), 0)
+		if got := tt.input[contentStart:contentEnd]; got != tt.text {
+			t.Errorf("%s: content %q, want %q", tt.input, got, tt.text)
+		}
+		if got := tt.input[:end]; got != tt.element {
+			t.Errorf("%s: element %q, want %q", tt.input, got, tt.element)
+		}
+	}
+}
+
+func TestAttrTokenSpans(t *testing.T) {
+	tests := []struct {
+		input  string
+		name   string
+		tokens []string
+	}{
+		{`<section epub:type="chapter">`, "epub:type", []string{"chapter"}},
+		{`<section epub:type="  frontmatter	toc ">`, "epub:type", []string{"frontmatter", "toc"}},
+		{`<p class='a b' id="x">`, "class", []string{"a", "b"}},
+		{`<p epub:type="">`, "epub:type", nil},
+		{`<p type="chapter">`, "epub:type", nil},
+	}
+	for _, tt := range tests {
+		spans := AttrTokenSpans([]byte(tt.input), 0, tt.name)
+		if len(spans) != len(tt.tokens) {
+			t.Errorf("%s: got %d tokens, want %d", tt.input, len(spans), len(tt.tokens))
+			continue
+		}
+		for i, span := range spans {
+			if got := tt.input[span[0]:span[1]]; got != tt.tokens[i] {
+				t.Errorf("%s: token %d is %q, want %q", tt.input, i, got, tt.tokens[i])
+			}
+		}
+	}
+}
+
+func TestAttrValueRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		attr    string
+		want    string
+		wantPos int // byte offset of the value, or -1 when not found
+	}{
+		{"double quotes", `<a href="x.xhtml"/>`, "href", "x.xhtml", 9},
+		{"single quotes", `<a href='x.xhtml'/>`, "href", "x.xhtml", 9},
+		{"space around equals", "<a href \n= \t\"x.xhtml\"/>", "href", "x.xhtml", 13},
+		{"not a suffix of another name", `<a data-href="y" href="x"/>`, "href", "x", 23},
+		{"not in another value", `<a title="href='y'" href="x"/>`, "href", "x", 26},
+		{
+			"prefixed",
+			`<a xmlns:epub="http://www.idpf.org/2007/ops" epub:type="noteref" type="x"/>`,
+			"epub:type", "noteref", 56,
+		},
+		{
+			"unprefixed beside prefixed",
+			`<a xmlns:epub="http://www.idpf.org/2007/ops" epub:type="noteref" type="x"/>`,
+			"type", "x", 71,
+		},
+		{
+			"other prefix for the namespace",
+			`<a xmlns:ops="http://www.idpf.org/2007/ops" ops:type="noteref"/>`,
+			"epub:type", "noteref", 54,
+		},
+		{
+			"same local name matches the value",
+			`<a xmlns:ops="http://www.idpf.org/2007/ops" epub:type="b" ops:type="a"/>`,
+			"epub:type", "a", 68,
+		},
+		{"empty value", `<img alt=""/>`, "alt", "", 10},
+		{"missing", `<img src="a.png"/>`, "alt", "", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte(tt.input)
+			root, diags := Parse(content)
+			if len(diags) > 0 {
+				t.Fatalf("parse: %v", diags)
+			}
+			node := root.Children[0]
+
+			start, end, ok := AttrValueRange(content, node, tt.attr)
+			if tt.wantPos < 0 {
+				if ok {
+					t.Errorf("expected no range, got %d..%d", start, end)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("expected a range")
+			}
+			if start != tt.wantPos || string(content[start:end]) != tt.want {
+				t.Errorf("got %q at %d, want %q at %d",
+					content[start:end], start, tt.want, tt.wantPos)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package parser
+
+import "strings"
+
+// MediaFeature is a feature tested in a media query, such as min-width in
+// "(min-width: 30em)", with the byte offsets of its name and value in the
+// prelude. Value is "" for a boolean test such as "(color)".
+type MediaFeature struct {
+	Name        string
+	Offset      int
+	Value       string
+	ValueOffset int
+}
+
+// ParseMediaQuery returns the features tested in a media query list, such
+// as the prelude of an @media rule, in order. Each parenthesized test that
+// holds no other test is one feature: "name: value", a bare name, or a
+// range such as "width >= 30em" or "30em <= width < 50em". Media types,
+// the keywords and, or, not, and only, and the arguments of functions
+// such as calc() are skipped.
+func ParseMediaQuery(prelude string) []MediaFeature {
+	type group struct {
+		start  int
+		fn     bool
+		nested bool
+	}
+	var features []MediaFeature
+	var stack []group
+	var quote byte
+	for i := 0; i < len(prelude); i++ {
+		c := prelude[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			fn := i > 0 && isMediaNameByte(prelude[i-1])
+			if !fn && len(stack) > 0 {
+				stack[len(stack)-1].nested = true
+			}
+			stack = append(stack, group{start: i + 1, fn: fn})
+		case c == ')' && len(stack) > 0:
+			g := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if g.fn || g.nested {
+				continue
+			}
+			if f, ok := parseMediaFeature(prelude[g.start:i], g.start); ok {
+				features = append(features, f)
+			}
+		}
+	}
+	return features
+}
+
+// parseMediaFeature parses the text of one parenthesized media test
+// starting at offset in the prelude.
+func parseMediaFeature(text string, offset int) (MediaFeature, bool) {
+	if colon := strings.IndexByte(text, ':'); colon >= 0 {
+		nameStart, name := trimOffset(text[:colon])
+		valueStart, value := trimOffset(text[colon+1:])
+		if !isMediaName(name) {
+			return MediaFeature{}, false
+		}
+		return MediaFeature{
+			Name:        name,
+			Offset:      offset + nameStart,
+			Value:       value,
+			ValueOffset: offset + colon + 1 + valueStart,
+		}, true
+	}
+
+	// A range, or a boolean test when there is no comparison
+	start := 0
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && !strings.ContainsRune("<>=", rune(text[i])) {
+			continue
+		}
+		partStart, part := trimOffset(text[start:i])
+		if isMediaName(part) {
+			return MediaFeature{Name: part, Offset: offset + start + partStart}, true
+		}
+		start = i + 1
+	}
+	return MediaFeature{}, false
+}
+
+// trimOffset trims whitespace from s and returns the offset in s of what
+// remains.
+func trimOffset(s string) (int, string) {
+	trimmed := strings.TrimLeft(s, " \t\r\n\f")
+	return len(s) - len(trimmed), strings.TrimRight(trimmed, " \t\r\n\f")
+}
+
+// isMediaName reports whether s is an identifier, such as width or
+// -webkit-min-device-pixel-ratio, rather than a value such as 30em.
+func isMediaName(s string) bool {
+	t := strings.TrimLeft(s, "-")
+	if t == "" || !(t[0] >= 'a' && t[0] <= 'z' || t[0] >= 'A' && t[0] <= 'Z') {
+		return false
+	}
+	for i := range len(s) {
+		if !isMediaNameByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isMediaNameByte reports whether c may appear in a feature or function
+// name.
+func isMediaNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' || c == '-' || c == '_'
+}
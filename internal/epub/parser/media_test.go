@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestParseMediaQuery(t *testing.T) {
+	cases := []struct {
+		prelude string
+		want    []string
+	}{
+		{"screen", nil},
+		{"(min-width: 30em)", []string{"min-width@1=30em@12"}},
+		{
+			"screen and (min-width:30em) and (orientation : landscape)",
+			[]string{"min-width@12=30em@22", "orientation@33=landscape@47"},
+		},
+		{"(color), print and (monochrome)", []string{"color@1", "monochrome@20"}},
+		{"not all and (hover: hover)", []string{"hover@13=hover@20"}},
+		{"((hover) or (pointer: fine))", []string{"hover@2", "pointer@13=fine@22"}},
+		{"(width >= 30em)", []string{"width@1"}},
+		{"(30em <= width < 50em)", []string{"width@9"}},
+		{"(min-width: calc(10em + 2px))", []string{"min-width@1=calc(10em + 2px)@12"}},
+		{
+			"(-webkit-min-device-pixel-ratio: 2)",
+			[]string{"-webkit-min-device-pixel-ratio@1=2@33"},
+		},
+		{"(: 30em), (30em), ()", nil},
+	}
+	for _, tc := range cases {
+		var got []string
+		for _, f := range ParseMediaQuery(tc.prelude) {
+			s := fmt.Sprintf("%s@%d", f.Name, f.Offset)
+			if f.Value != "" {
+				s += fmt.Sprintf("=%s@%d", f.Value, f.ValueOffset)
+			}
+			got = append(got, s)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("ParseMediaQuery(%q) = %v, want %v", tc.prelude, got, tc.want)
+		}
+	}
+}
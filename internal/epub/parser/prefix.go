@@ -0,0 +1,89 @@
+package parser
+
+import "strings"
+
+// UndeclaredPrefixAttrs returns the attributes in content whose names use
+// prefix, as in "epub:type", on elements where neither the element nor an
+// ancestor declares xmlns:prefix. It scans the raw text, so it works on
+// malformed documents.
+func UndeclaredPrefixAttrs(content []byte, prefix string) []RawAttr {
+	declaration := "xmlns:" + prefix
+	prefix += ":"
+
+	var undeclared []RawAttr
+	// declared holds, for each open element, whether prefix is declared
+	// in its scope.
+	var declared []bool
+	ScanTags(content, func(tag RawTag) {
+		if tag.Close {
+			if len(declared) > 0 {
+				declared = declared[:len(declared)-1]
+			}
+			return
+		}
+
+		inScope := len(declared) > 0 && declared[len(declared)-1]
+		for _, attr := range tag.Attrs {
+			if attr.Name == declaration {
+				inScope = true
+			}
+		}
+		if !inScope {
+			for _, attr := range tag.Attrs {
+				if strings.HasPrefix(attr.Name, prefix) {
+					undeclared = append(undeclared, attr)
+				}
+			}
+		}
+		if !tag.Empty {
+			declared = append(declared, inScope)
+		}
+	})
+	return undeclared
+}
+
+// RootDeclares reports whether the root element's start tag declares the
+// namespace prefix, as xmlns:prefix.
+func RootDeclares(content []byte, prefix string) bool {
+	root, ok := rootTag(content)
+	if !ok {
+		return false
+	}
+	for _, attr := range root.Attrs {
+		if attr.Name == "xmlns:"+prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// NamespaceInsertOffset returns the offset in the root element's start tag
+// at which to insert a namespace declaration: just past its last xmlns or
+// xmlns:* attribute, or past its name when it has none. It returns -1 when
+// content has no start tag.
+func NamespaceInsertOffset(content []byte) int {
+	root, ok := rootTag(content)
+	if !ok {
+		return -1
+	}
+	offset := root.Offset + len("<") + len(root.Name)
+	for _, attr := range root.Attrs {
+		if attr.Name == "xmlns" || strings.HasPrefix(attr.Name, "xmlns:") {
+			// Past the value's closing quote
+			offset = attr.ValueOffset + len(attr.Value) + 1
+		}
+	}
+	return offset
+}
+
+// rootTag returns the first start tag in content.
+func rootTag(content []byte) (RawTag, bool) {
+	var root RawTag
+	found := false
+	ScanTags(content, func(tag RawTag) {
+		if !found && !tag.Close {
+			root, found = tag, true
+		}
+	})
+	return root, found
+}
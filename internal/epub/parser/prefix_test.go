@@ -0,0 +1,93 @@
+package parser
+
+import "testing"
+
+func TestUndeclaredPrefixAttrs(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			"declared on the root",
+			`<html xmlns:epub="http://www.idpf.org/2007/ops"><p epub:type="x"/></html>`,
+			nil,
+		},
+		{
+			"undeclared",
+			`<html><section epub:type="chapter"><p epub:type="x"/></section></html>`,
+			[]string{"epub:type", "epub:type"},
+		},
+		{
+			"declared on an element, out of scope after it",
+			`<html><aside xmlns:epub="e" epub:type="a"/>` +
+				`<div xmlns:epub="e"><p epub:type="b"/></div>` +
+				`<p epub:prefix="c"/></html>`,
+			[]string{"epub:prefix"},
+		},
+		{
+			"malformed",
+			`<html><p epub:type="x"><b></html>`,
+			[]string{"epub:type"},
+		},
+		{
+			"other prefixes",
+			`<html><p xml:lang="en" epubx:type="x"/></html>`,
+			nil,
+		},
+	}
+	for _, tc := range cases {
+		var got []string
+		for _, attr := range UndeclaredPrefixAttrs([]byte(tc.content), "epub") {
+			got = append(got, attr.Name)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestNamespaceInsertOffset(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		before  string
+	}{
+		{
+			"after the last declaration",
+			`<?xml version="1.0"?>` + "\n" +
+				`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:m='m' lang="en"><p/></html>`,
+			`<?xml version="1.0"?>` + "\n" +
+				`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:m='m'`,
+		},
+		{
+			"after the name",
+			`<!-- note --><html lang="en"/>`,
+			`<!-- note --><html`,
+		},
+	}
+	for _, tc := range cases {
+		got := NamespaceInsertOffset([]byte(tc.content))
+		if want := len(tc.before); got != want {
+			t.Errorf("%s: offset = %d, want %d", tc.name, got, want)
+		}
+	}
+	if got := NamespaceInsertOffset([]byte("text")); got != -1 {
+		t.Errorf("offset without a start tag = %d, want -1", got)
+	}
+}
+
+func TestRootDeclares(t *testing.T) {
+	if !RootDeclares([]byte(`<html xmlns:epub="e"><p/></html>`), "epub") {
+		t.Error("expected the root declaration to be found")
+	}
+	if RootDeclares([]byte(`<html><p xmlns:epub="e"/></html>`), "epub") {
+		t.Error("a declaration below the root does not count")
+	}
+}
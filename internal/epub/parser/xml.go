@@ -6,6 +6,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"io"
+	"strings"
 
 	"github.com/toba/epub-lsp/internal/epub"
 )
@@ -23,10 +24,17 @@ type XMLNode struct {
 	Local    string
 	Attrs    []XMLAttr
 	Children []*XMLNode
+	// Parent is the enclosing element, or the "#document" node for the root
+	// element. It is nil for the document node.
+	Parent   *XMLNode
 	CharData string
-	Offset   int64
-	Line     int
-	Col      int
+	// Offset is the byte offset of the start tag's "<".
+	Offset int64
+	// End is the byte offset just past the end tag, or past the "/>" of a
+	// self-closing element.
+	End  int64
+	Line int
+	Col  int
 }
 
 // Attr returns the value of the named attribute, or empty string if not found.
@@ -49,6 +57,55 @@ func (n *XMLNode) AttrNS(space, local string) string {
 	return ""
 }
 
+// lookupAttr returns the value of the attribute and whether it is set.
+func (n *XMLNode) lookupAttr(space, local string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Local == local && a.Space == space {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// EffectiveAttr returns the value of the named attribute on n or, failing
+// that, on its nearest ancestor that sets it, or empty string if none does.
+func (n *XMLNode) EffectiveAttr(local string) string {
+	return n.EffectiveAttrNS("", local)
+}
+
+// EffectiveAttrNS returns the value of the namespaced attribute on n or its
+// nearest ancestor that sets it, as for inherited attributes such as
+// xml:lang and xml:space. An empty value still overrides an ancestor's.
+func (n *XMLNode) EffectiveAttrNS(space, local string) string {
+	for node := n; node != nil; node = node.Parent {
+		if v, ok := node.lookupAttr(space, local); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// InheritedLang returns the language of n: the xml:lang or lang attribute
+// of n or its nearest ancestor with either, preferring xml:lang when an
+// element sets both.
+func (n *XMLNode) InheritedLang() string {
+	for node := n; node != nil; node = node.Parent {
+		if v, ok := node.lookupAttr(epub.NSXML, "lang"); ok {
+			return v
+		}
+		if v, ok := node.lookupAttr("", "lang"); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// PreservesSpace reports whether whitespace in n is significant, as set by
+// xml:space="preserve" on n or an ancestor.
+func (n *XMLNode) PreservesSpace() bool {
+	return n.EffectiveAttrNS(epub.NSXML, "space") == "preserve"
+}
+
 // HasAttr returns true if the element has the named attribute.
 func (n *XMLNode) HasAttr(local string) bool {
 	for _, a := range n.Attrs {
@@ -59,6 +116,25 @@ func (n *XMLNode) HasAttr(local string) bool {
 	return false
 }
 
+// Text returns the character data of n and all its descendants. A node's
+// own text precedes its children's, so the result suits emptiness and
+// keyword checks rather than display.
+func (n *XMLNode) Text() string {
+	if len(n.Children) == 0 {
+		return n.CharData
+	}
+	var b strings.Builder
+	n.writeText(&b)
+	return b.String()
+}
+
+func (n *XMLNode) writeText(b *strings.Builder) {
+	b.WriteString(n.CharData)
+	for _, child := range n.Children {
+		child.writeText(b)
+	}
+}
+
 // FindAll returns all descendant elements matching the given local name.
 func (n *XMLNode) FindAll(local string) []*XMLNode {
 	var results []*XMLNode
@@ -109,6 +185,12 @@ func (n *XMLNode) FindFirstNS(space, local string) *XMLNode {
 	return nil
 }
 
+// Source is the diagnostic source for XML well-formedness errors.
+const Source = "epub-xml"
+
+// WellFormednessError prefixes the message of every well-formedness error.
+const WellFormednessError = "XML well-formedness error"
+
 // Parse parses XML content into a tree of XMLNodes and returns
 // any well-formedness errors as diagnostics.
 func Parse(content []byte) (*XMLNode, []epub.Diagnostic) {
@@ -127,8 +209,8 @@ func Parse(content []byte) (*XMLNode, []epub.Diagnostic) {
 			if errors.Is(err, io.EOF) {
 				break
 			}
-			diags = append(diags, epub.NewDiag(content, int(offset), "epub-xml").
-				Error("XML well-formedness error: "+err.Error()).Build())
+			diags = append(diags, epub.NewDiag(content, int(offset), Source).
+				Error(WellFormednessError+": "+err.Error()).Build())
 			break
 		}
 
@@ -140,6 +222,7 @@ func Parse(content []byte) (*XMLNode, []epub.Diagnostic) {
 			node := &XMLNode{
 				Space:  t.Name.Space,
 				Local:  t.Name.Local,
+				Parent: parent,
 				Offset: offset,
 				Line:   pos.Line,
 				Col:    pos.Character,
@@ -156,6 +239,7 @@ func Parse(content []byte) (*XMLNode, []epub.Diagnostic) {
 
 		case xml.EndElement:
 			if len(stack) > 1 {
+				stack[len(stack)-1].End = decoder.InputOffset()
 				stack = stack[:len(stack)-1]
 			}
 
@@ -2,6 +2,8 @@ package parser
 
 import (
 	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
 )
 
 func TestParse_ValidXML(t *testing.T) {
@@ -98,6 +100,22 @@ func TestXMLNode_FindFirst(t *testing.T) {
 	}
 }
 
+func TestXMLNode_Text(t *testing.T) {
+	content := []byte(`<root><a>Go <b>to <i>next</i></b><img/></a><c/></root>`)
+
+	root, diags := Parse(content)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if got := root.FindFirst("a").Text(); got != "Go to next" {
+		t.Errorf("expected subtree text %q, got %q", "Go to next", got)
+	}
+	if got := root.FindFirst("c").Text(); got != "" {
+		t.Errorf("expected empty text, got %q", got)
+	}
+}
+
 func TestXMLNode_FindAllNS(t *testing.T) {
 	content := []byte(`<root xmlns:dc="http://purl.org/dc/elements/1.1/">
   <dc:title>Test</dc:title>
@@ -132,3 +150,105 @@ func TestXMLNode_HasAttr(t *testing.T) {
 		t.Error("expected HasAttr('missing') to be false")
 	}
 }
+
+func TestXMLNode_Parent(t *testing.T) {
+	root, diags := Parse([]byte(`<root><a><b/></a></root>`))
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	b := root.FindFirst("b")
+	if b.Parent == nil || b.Parent.Local != "a" || b.Parent.Parent.Local != "root" {
+		t.Fatalf("unexpected ancestors of <b>")
+	}
+	if root.Children[0].Parent != root || root.Parent != nil {
+		t.Error("expected the root element's parent to be the document node")
+	}
+}
+
+func TestParse_ElementEnd(t *testing.T) {
+	content := `<root><a>text <b/> more</a><c x="1" /></root>`
+	root, diags := Parse([]byte(content))
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	for _, tt := range []struct{ name, want string }{
+		{"root", content},
+		{"a", `<a>text <b/> more</a>`},
+		{"b", `<b/>`},
+		{"c", `<c x="1" />`},
+	} {
+		node := root.FindFirst(tt.name)
+		if got := content[node.Offset:node.End]; got != tt.want {
+			t.Errorf("<%s> spans %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestXMLNode_Inheritance(t *testing.T) {
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" ` +
+		`xml:space="preserve" class="top">
+<body>
+  <div id="inherit"><p><span id="deep"/></p></div>
+  <div id="override" xml:lang="fr" xml:space="default">
+    <p><span id="under-override"/></p>
+  </div>
+  <div lang="de"><p id="html-lang"/></div>
+  <div xml:lang=""><p id="unknown"/></div>
+</body>
+</html>`)
+
+	root, diags := Parse(content)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	tests := []struct {
+		id, lang string
+		preserve bool
+	}{
+		{"deep", "en", true},
+		{"override", "fr", false},
+		{"under-override", "fr", false},
+		{"html-lang", "de", true},
+		{"unknown", "", true},
+	}
+
+	for _, tt := range tests {
+		node := findByID(root, tt.id)
+		if node == nil {
+			t.Fatalf("no element with id %q", tt.id)
+		}
+		if got := node.InheritedLang(); got != tt.lang {
+			t.Errorf("%s: InheritedLang() = %q, want %q", tt.id, got, tt.lang)
+		}
+		if got := node.PreservesSpace(); got != tt.preserve {
+			t.Errorf("%s: PreservesSpace() = %v, want %v", tt.id, got, tt.preserve)
+		}
+		if got := node.EffectiveAttr("class"); got != "top" {
+			t.Errorf("%s: EffectiveAttr(class) = %q, want %q", tt.id, got, "top")
+		}
+	}
+
+	deep := findByID(root, "deep")
+	if got := deep.EffectiveAttrNS(epub.NSXML, "lang"); got != "en" {
+		t.Errorf("EffectiveAttrNS(xml, lang) = %q, want %q", got, "en")
+	}
+	if got := deep.EffectiveAttr("missing"); got != "" {
+		t.Errorf("expected no value for an unset attribute, got %q", got)
+	}
+}
+
+// findByID returns the first element at or below node with the given id.
+func findByID(node *XMLNode, id string) *XMLNode {
+	if node.Attr("id") == id {
+		return node
+	}
+	for _, child := range node.Children {
+		if found := findByID(child, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -1,5 +1,10 @@
 package epub
 
+import (
+	"bytes"
+	"sort"
+)
+
 // Position represents a zero-based position in a text document.
 type Position struct {
 	Line      int `json:"line"`
@@ -12,19 +17,136 @@ type Range struct {
 	End   Position `json:"end"`
 }
 
+// LineIndex records where each line of a content buffer starts and ends, so
+// offset and position conversions cost a binary search rather than a scan
+// from the start of the buffer. Build one per buffer when many conversions
+// are needed; the index is not updated if the buffer changes.
+//
+// As in the LSP specification, "\n", "\r\n", and a lone "\r" each end a
+// line, and terminator bytes never count as characters. A leading byte order
+// mark is not part of the first line, since editors hide it. The same rules
+// apply to PositionToByteOffset and ByteOffsetToPosition.
+type LineIndex struct {
+	starts []int
+	ends   []int // offset of each line's terminator, or the buffer size
+	size   int
+}
+
+// NewLineIndex builds the line index for content.
+func NewLineIndex(content []byte) *LineIndex {
+	start := bomLen(content)
+	idx := &LineIndex{starts: []int{start}, size: len(content)}
+	for i := start; i < len(content); i++ {
+		if n := lineBreakLen(content, i); n > 0 {
+			idx.ends = append(idx.ends, i)
+			idx.starts = append(idx.starts, i+n)
+			i += n - 1
+		}
+	}
+	idx.ends = append(idx.ends, len(content))
+	return idx
+}
+
+// Position converts a byte offset into a line/character position. Offsets
+// outside the buffer are clamped to its bounds.
+func (idx *LineIndex) Position(offset int) Position {
+	if offset < idx.starts[0] {
+		return Position{}
+	}
+	offset = min(offset, idx.size)
+	line := sort.Search(len(idx.starts), func(i int) bool {
+		return idx.starts[i] > offset
+	}) - 1
+	offset = min(offset, idx.ends[line])
+	return Position{Line: line, Character: offset - idx.starts[line]}
+}
+
+// Offset converts a line/character position to a byte offset. Returns -1
+// if the position is out of range.
+func (idx *LineIndex) Offset(pos Position) int {
+	if pos.Line < 0 || pos.Line >= len(idx.starts) || pos.Character < 0 {
+		return -1
+	}
+	start := idx.starts[pos.Line]
+	if pos.Character > idx.ends[pos.Line]-start {
+		return -1
+	}
+	return start + pos.Character
+}
+
+// ClampRange moves rng within the buffer: a line before the first moves
+// to the start of the buffer, a line past the last to its end, and a
+// character past the end of its line to the line's end. A range whose end
+// comes before its start is swapped. It reports whether rng changed.
+func (idx *LineIndex) ClampRange(rng Range) (Range, bool) {
+	start, end := idx.clamp(rng.Start), idx.clamp(rng.End)
+	if end.Line < start.Line || end.Line == start.Line && end.Character < start.Character {
+		start, end = end, start
+	}
+	clamped := Range{Start: start, End: end}
+	return clamped, clamped != rng
+}
+
+// clamp moves pos within the buffer.
+func (idx *LineIndex) clamp(pos Position) Position {
+	last := len(idx.starts) - 1
+	switch {
+	case pos.Line < 0:
+		return Position{}
+	case pos.Line > last:
+		return Position{Line: last, Character: idx.ends[last] - idx.starts[last]}
+	}
+	length := idx.ends[pos.Line] - idx.starts[pos.Line]
+	return Position{Line: pos.Line, Character: max(0, min(pos.Character, length))}
+}
+
+// ClampRange moves rng within content, as LineIndex.ClampRange does, and
+// reports whether it changed. Use a LineIndex when clamping many ranges in
+// the same buffer.
+func ClampRange(content []byte, rng Range) (Range, bool) {
+	return NewLineIndex(content).ClampRange(rng)
+}
+
+// lineBreakLen returns the length of the line terminator starting at
+// content[i], or zero if there is none.
+func lineBreakLen(content []byte, i int) int {
+	switch content[i] {
+	case '\n':
+		return 1
+	case '\r':
+		if i+1 < len(content) && content[i+1] == '\n' {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// DetectLineEnding returns the line terminator used by most lines of
+// content: "\r\n" when CRLF endings outnumber bare LF, otherwise "\n".
+func DetectLineEnding(content []byte) string {
+	crlf := bytes.Count(content, []byte("\r\n"))
+	if crlf > 0 && crlf >= bytes.Count(content, []byte{'\n'})-crlf {
+		return "\r\n"
+	}
+	return "\n"
+}
+
 // PositionToByteOffset converts a line/character position to a byte offset.
-// Returns -1 if the position is out of range.
+// Returns -1 if the position is out of range. Use a LineIndex when
+// converting many positions in the same buffer.
 func PositionToByteOffset(content []byte, pos Position) int {
 	line := 0
 	col := 0
 
-	for i := range content {
+	for i := bomLen(content); i < len(content); i++ {
 		if line == pos.Line && col == pos.Character {
 			return i
 		}
-		if content[i] == '\n' {
+		if n := lineBreakLen(content, i); n > 0 {
 			line++
 			col = 0
+			i += n - 1
 		} else {
 			col++
 		}
@@ -39,9 +161,10 @@ func PositionToByteOffset(content []byte, pos Position) int {
 }
 
 // ByteOffsetToPosition converts a byte offset into line/character position.
-// Lines and characters are zero-based.
+// Lines and characters are zero-based. Use a LineIndex when converting many
+// offsets in the same buffer.
 func ByteOffsetToPosition(content []byte, offset int) Position {
-	if offset < 0 {
+	if offset < bomLen(content) {
 		return Position{}
 	}
 	if offset > len(content) {
@@ -51,13 +174,19 @@ func ByteOffsetToPosition(content []byte, offset int) Position {
 	line := 0
 	col := 0
 
-	for i := range offset {
-		if content[i] == '\n' {
-			line++
-			col = 0
-		} else {
+	for i := bomLen(content); i < offset; i++ {
+		n := lineBreakLen(content, i)
+		if n == 0 {
 			col++
+			continue
+		}
+		if i+n > offset {
+			// offset falls between the \r and \n of a CRLF pair
+			break
 		}
+		line++
+		col = 0
+		i += n - 1
 	}
 
 	return Position{Line: line, Character: col}
@@ -1,6 +1,9 @@
 package epub
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+)
 
 func TestPositionToByteOffset(t *testing.T) {
 	content := []byte("line0\nline1\nline2")
@@ -42,3 +45,174 @@ func TestPositionToByteOffset_RoundTrip(t *testing.T) {
 		}
 	}
 }
+
+func TestPositionCRLF(t *testing.T) {
+	content := []byte("ab\r\ncd\re\nf")
+
+	tests := []struct {
+		offset int
+		want   Position
+	}{
+		{2, Position{Line: 0, Character: 2}},  // at \r
+		{3, Position{Line: 0, Character: 2}},  // between \r and \n
+		{4, Position{Line: 1, Character: 0}},  // c
+		{6, Position{Line: 1, Character: 2}},  // lone \r
+		{7, Position{Line: 2, Character: 0}},  // e
+		{9, Position{Line: 3, Character: 0}},  // f
+		{10, Position{Line: 3, Character: 1}}, // end of file
+	}
+
+	for _, tt := range tests {
+		if got := ByteOffsetToPosition(content, tt.offset); got != tt.want {
+			t.Errorf("ByteOffsetToPosition(%d) = %v, want %v", tt.offset, got, tt.want)
+		}
+	}
+
+	if got := PositionToByteOffset(content, Position{Line: 0, Character: 2}); got != 2 {
+		t.Errorf("end of CRLF line = %d, want 2", got)
+	}
+	if got := PositionToByteOffset(content, Position{Line: 0, Character: 3}); got != -1 {
+		t.Errorf("character past CRLF line end = %d, want -1", got)
+	}
+}
+
+func TestDetectLineEnding(t *testing.T) {
+	tests := []struct {
+		content, want string
+	}{
+		{"", "\n"},
+		{"a\nb\n", "\n"},
+		{"a\r\nb\r\n", "\r\n"},
+		{"a\r\nb\r\nc\n", "\r\n"},
+		{"a\r\nb\nc\n", "\n"},
+	}
+
+	for _, tt := range tests {
+		if got := DetectLineEnding([]byte(tt.content)); got != tt.want {
+			t.Errorf("DetectLineEnding(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestPositionSkipsBOM(t *testing.T) {
+	content := []byte(BOM + "<?xml version=\"1.0\"?>\n<package/>")
+
+	if got := ByteOffsetToPosition(content, len(BOM)+2); got != (Position{0, 2}) {
+		t.Errorf("ByteOffsetToPosition = %v, want 0:2", got)
+	}
+	if got := ByteOffsetToPosition(content, 1); got != (Position{}) {
+		t.Errorf("ByteOffsetToPosition inside the BOM = %v, want 0:0", got)
+	}
+	if got := PositionToByteOffset(content, Position{0, 0}); got != len(BOM) {
+		t.Errorf("PositionToByteOffset(0:0) = %d, want %d", got, len(BOM))
+	}
+	if got := NewLineIndex(content).Offset(Position{1, 1}); got != len(BOM)+23 {
+		t.Errorf("Offset(1:1) = %d, want %d", got, len(BOM)+23)
+	}
+
+	stripped, ok := StripBOM(content)
+	if !ok || string(stripped) != string(content[len(BOM):]) {
+		t.Errorf("StripBOM = %q, %v", stripped, ok)
+	}
+	if _, ok := StripBOM(stripped); ok {
+		t.Error("StripBOM reported a BOM in content without one")
+	}
+}
+
+func TestLineIndexAgreesWithScan(t *testing.T) {
+	inputs := []string{
+		"",
+		"single line",
+		"line0\nline1\nline2",
+		"trailing newline\n",
+		"\n\nblank lines\n\n",
+		"crlf\r\nline\r\nendings\r\n",
+		"mixed\r\nline\nendings\r",
+		"multibyte: café\n日本語のテキスト\n😀 emoji",
+		BOM + "<?xml version=\"1.0\"?>\n<a/>",
+		BOM,
+	}
+
+	for _, input := range inputs {
+		content := []byte(input)
+		idx := NewLineIndex(content)
+
+		for offset := -1; offset <= len(content)+1; offset++ {
+			want := ByteOffsetToPosition(content, offset)
+			if got := idx.Position(offset); got != want {
+				t.Errorf("%q: Position(%d) = %v, want %v", input, offset, got, want)
+			}
+		}
+
+		for line := -1; line <= len(content)+1; line++ {
+			for char := -1; char <= len(content)+1; char++ {
+				pos := Position{Line: line, Character: char}
+				want := PositionToByteOffset(content, pos)
+				if got := idx.Offset(pos); got != want {
+					t.Errorf("%q: Offset(%v) = %d, want %d", input, pos, got, want)
+				}
+			}
+		}
+	}
+}
+
+// largeContent returns a document of roughly size bytes made of short lines.
+func largeContent(size int) []byte {
+	line := []byte("<p>{{ .Title }} — a line of template-heavy chapter text</p>\n")
+	return bytes.Repeat(line, size/len(line)+1)
+}
+
+func BenchmarkByteOffsetToPosition(b *testing.B) {
+	content := largeContent(500 * 1024)
+	for b.Loop() {
+		for offset := 0; offset < len(content); offset += 256 {
+			ByteOffsetToPosition(content, offset)
+		}
+	}
+}
+
+func BenchmarkLineIndexPosition(b *testing.B) {
+	content := largeContent(500 * 1024)
+	for b.Loop() {
+		idx := NewLineIndex(content)
+		for offset := 0; offset < len(content); offset += 256 {
+			idx.Position(offset)
+		}
+	}
+}
+
+func TestClampRange(t *testing.T) {
+	content := []byte("line0\r\nline11\nend")
+	rng := func(sl, sc, el, ec int) Range {
+		return Range{Start: Position{Line: sl, Character: sc}, End: Position{Line: el, Character: ec}}
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		in      Range
+		want    Range
+		changed bool
+	}{
+		{"in bounds", content, rng(0, 1, 1, 6), rng(0, 1, 1, 6), false},
+		{"end of file", content, rng(2, 0, 2, 3), rng(2, 0, 2, 3), false},
+		{"past end of file", content, rng(2, 1, 9, 40), rng(2, 1, 2, 3), true},
+		{"whole range past end of file", content, rng(7, 2, 8, 0), rng(2, 3, 2, 3), true},
+		{"past end of line", content, rng(0, 2, 0, 99), rng(0, 2, 0, 5), true},
+		{"negative", content, rng(-1, -4, 0, -1), rng(0, 0, 0, 0), true},
+		{"reversed", content, rng(1, 4, 0, 2), rng(0, 2, 1, 4), true},
+		{"reversed on one line", content, rng(1, 4, 1, 1), rng(1, 1, 1, 4), true},
+		{"empty file", nil, rng(3, 1, 4, 10), rng(0, 0, 0, 0), true},
+		{"empty file at start", []byte{}, rng(0, 0, 0, 0), rng(0, 0, 0, 0), false},
+		{"after byte order mark", []byte(BOM + "ab"), rng(0, 0, 0, 5), rng(0, 0, 0, 2), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := ClampRange(tt.content, tt.in)
+			if got != tt.want || changed != tt.changed {
+				t.Errorf("ClampRange(%v) = %v, %v, want %v, %v", tt.in, got, changed, tt.want, tt.changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Package sarif converts diagnostics to a SARIF 2.1.0 log, the static
+// analysis format ingested by GitHub code scanning and QA dashboards.
+package sarif
+
+import (
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+)
+
+const (
+	// Version is the SARIF version of logs built by this package.
+	Version = "2.1.0"
+	// Schema is the JSON schema of SARIF 2.1.0 logs.
+	Schema = "https://json.schemastore.org/sarif-2.1.0.json"
+	// RootBaseID names the workspace root that artifact URIs are relative to.
+	RootBaseID = "%SRCROOT%"
+
+	toolName = "epub-lsp"
+	toolURI  = "https://github.com/toba/epub-lsp"
+)
+
+// Log is a SARIF log file.
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is one invocation of the tool and its results.
+type Run struct {
+	Tool Tool `json:"tool"`
+	// OriginalURIBaseIDs maps RootBaseID to the workspace root.
+	OriginalURIBaseIDs map[string]ArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []Result                    `json:"results"`
+}
+
+// Tool describes the analysis tool.
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the tool component that produced the results, with the rules
+// it checks.
+type Driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule describes a diagnostic code.
+type Rule struct {
+	ID               string   `json:"id"`
+	ShortDescription Message  `json:"shortDescription"`
+	FullDescription  *Message `json:"fullDescription,omitempty"`
+	HelpURI          string   `json:"helpUri,omitempty"`
+}
+
+// Message is plain text with an optional markdown rendering.
+type Message struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+// Result is one diagnostic.
+type Result struct {
+	RuleID    string     `json:"ruleId,omitempty"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations"`
+}
+
+// Location is where a result was found.
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a region of a file.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           Region           `json:"region"`
+}
+
+// ArtifactLocation is a file URI, relative to URIBaseID when that is set.
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// Region is a range of text with one-based lines and columns. The end is
+// omitted for an empty range.
+type Region struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// FromDiagnostics builds a log with one run holding the diagnostics of each
+// file URI. Files under rootURI, the workspace root, get URIs relative to
+// RootBaseID; rootURI may be empty. Every documented code is listed as a
+// rule, whether or not it has results.
+func FromDiagnostics(rootURI string, diags map[string][]epub.Diagnostic) *Log {
+	run := Run{
+		Tool: Tool{Driver: Driver{
+			Name:           toolName,
+			InformationURI: toolURI,
+			Rules:          rules(),
+		}},
+		Results: []Result{},
+	}
+
+	root := ""
+	if rootURI != "" {
+		root = strings.TrimSuffix(uriutil.Path(rootURI), "/") + "/"
+		run.OriginalURIBaseIDs = map[string]ArtifactLocation{
+			RootBaseID: {URI: strings.TrimSuffix(rootURI, "/") + "/"},
+		}
+	}
+
+	for _, uri := range slices.Sorted(maps.Keys(diags)) {
+		artifact := artifactLocation(root, uri)
+		for _, d := range diags[uri] {
+			run.Results = append(run.Results, Result{
+				RuleID:  d.Code,
+				Level:   level(d.Severity),
+				Message: Message{Text: d.Message},
+				Locations: []Location{{PhysicalLocation: PhysicalLocation{
+					ArtifactLocation: artifact,
+					Region:           region(d.Range),
+				}}},
+			})
+		}
+	}
+
+	return &Log{Version: Version, Schema: Schema, Runs: []Run{run}}
+}
+
+// rules returns a rule for every documented code, sorted by code.
+func rules() []Rule {
+	codes := epub.Codes()
+	rules := make([]Rule, len(codes))
+	for i, code := range codes {
+		explanation := epub.CodeExplanation(code)
+		rules[i] = Rule{
+			ID:               code,
+			ShortDescription: Message{Text: firstSentence(explanation)},
+			FullDescription:  &Message{Text: explanation, Markdown: explanation},
+			HelpURI:          epub.CodeURL(code),
+		}
+	}
+	return rules
+}
+
+// firstSentence returns text up to and including its first full stop.
+func firstSentence(text string) string {
+	if i := strings.Index(text, ". "); i >= 0 {
+		return text[:i+1]
+	}
+	return text
+}
+
+// artifactLocation returns the location of uri, relative to the root path
+// when it lies under it.
+func artifactLocation(root, uri string) ArtifactLocation {
+	if root != "" {
+		if rel, ok := strings.CutPrefix(uriutil.Path(uri), root); ok {
+			return ArtifactLocation{
+				URI:       (&url.URL{Path: rel}).EscapedPath(),
+				URIBaseID: RootBaseID,
+			}
+		}
+	}
+	return ArtifactLocation{URI: uri}
+}
+
+// level maps a diagnostic severity to a SARIF result level.
+func level(severity int) string {
+	switch severity {
+	case epub.SeverityError:
+		return "error"
+	case epub.SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// region converts a zero-based range to a one-based region.
+func region(r epub.Range) Region {
+	reg := Region{StartLine: r.Start.Line + 1, StartColumn: r.Start.Character + 1}
+	if r.End != r.Start {
+		reg.EndLine = r.End.Line + 1
+		reg.EndColumn = r.End.Character + 1
+	}
+	return reg
+}
@@ -0,0 +1,135 @@
+package sarif
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+func TestFromDiagnostics(t *testing.T) {
+	diags := map[string][]epub.Diagnostic{
+		"file:///book/OEBPS/My%20Chapter.xhtml": {{
+			Code:     "HTM_008",
+			Severity: epub.SeverityError,
+			Message:  "missing alt",
+			Range: epub.Range{
+				Start: epub.Position{Line: 4, Character: 2},
+				End:   epub.Position{Line: 4, Character: 9},
+			},
+		}},
+		"file:///elsewhere/style.css": {{
+			Code:     "media-transcript",
+			Severity: epub.SeverityInfo,
+			Message:  "no transcript",
+		}},
+	}
+
+	data, err := json.Marshal(FromDiagnostics("file:///book", diags))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decode generically so the test checks the JSON shape, not the structs
+	var log map[string]any
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log["version"] != "2.1.0" || log["$schema"] != Schema {
+		t.Errorf("unexpected version or schema: %v, %v", log["version"], log["$schema"])
+	}
+
+	runs := log["runs"].([]any)
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(runs))
+	}
+	run := runs[0].(map[string]any)
+
+	driver := run["tool"].(map[string]any)["driver"].(map[string]any)
+	if driver["name"] != "epub-lsp" {
+		t.Errorf("unexpected driver name %v", driver["name"])
+	}
+	rules := driver["rules"].([]any)
+	if len(rules) != len(epub.Codes()) {
+		t.Errorf("expected %d rules, got %d", len(epub.Codes()), len(rules))
+	}
+	for _, r := range rules {
+		rule := r.(map[string]any)
+		short := rule["shortDescription"].(map[string]any)
+		if rule["id"] == "" || short["text"] == "" {
+			t.Errorf("rule without id or short description: %v", rule)
+		}
+	}
+
+	base := run["originalUriBaseIds"].(map[string]any)[RootBaseID].(map[string]any)
+	if base["uri"] != "file:///book/" {
+		t.Errorf("unexpected root %v", base["uri"])
+	}
+
+	results := run["results"].([]any)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	first := results[0].(map[string]any)
+	if first["ruleId"] != "HTM_008" || first["level"] != "error" ||
+		first["message"].(map[string]any)["text"] != "missing alt" {
+		t.Errorf("unexpected result %v", first)
+	}
+	loc := physicalLocation(first)
+	artifact := loc["artifactLocation"].(map[string]any)
+	if artifact["uri"] != "OEBPS/My%20Chapter.xhtml" ||
+		artifact["uriBaseId"] != RootBaseID {
+		t.Errorf("unexpected artifact location %v", artifact)
+	}
+	region := loc["region"].(map[string]any)
+	want := map[string]any{
+		"startLine": 5.0, "startColumn": 3.0, "endLine": 5.0, "endColumn": 10.0,
+	}
+	for k, v := range want {
+		if region[k] != v {
+			t.Errorf("region %s = %v, want %v", k, region[k], v)
+		}
+	}
+
+	second := results[1].(map[string]any)
+	loc = physicalLocation(second)
+	artifact = loc["artifactLocation"].(map[string]any)
+	if second["level"] != "note" || artifact["uri"] != "file:///elsewhere/style.css" ||
+		artifact["uriBaseId"] != nil {
+		t.Errorf("unexpected result outside the root %v", second)
+	}
+	if _, ok := loc["region"].(map[string]any)["endLine"]; ok {
+		t.Error("expected no end for an empty range")
+	}
+}
+
+// physicalLocation returns the physical location of a decoded result's
+// first location.
+func physicalLocation(result map[string]any) map[string]any {
+	loc := result["locations"].([]any)[0].(map[string]any)
+	return loc["physicalLocation"].(map[string]any)
+}
+
+func TestFromDiagnosticsEmpty(t *testing.T) {
+	data, err := json.Marshal(FromDiagnostics("", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Runs []struct {
+			OriginalURIBaseIDs map[string]any `json:"originalUriBaseIds"`
+			Results            []any          `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 || log.Runs[0].Results == nil {
+		t.Errorf("expected one run with an empty results array: %s", data)
+	}
+	if log.Runs[0].OriginalURIBaseIDs != nil {
+		t.Error("expected no root without a workspace root")
+	}
+}
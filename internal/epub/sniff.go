@@ -0,0 +1,39 @@
+package epub
+
+import "bytes"
+
+// SniffLength is the number of leading bytes SniffMediaType needs.
+const SniffLength = 12
+
+// signatures maps the leading bytes of resource formats to their media
+// types.
+var signatures = []struct {
+	magic     string
+	mediaType string
+}{
+	{"\x00\x01\x00\x00", "font/ttf"},
+	{"true", "font/ttf"},
+	{"OTTO", "font/otf"},
+	{"ttcf", "font/collection"},
+	{"wOFF", "font/woff"},
+	{"wOF2", "font/woff2"},
+	{"\x89PNG\r\n\x1a\n", "image/png"},
+	{"\xff\xd8\xff", "image/jpeg"},
+	{"GIF87a", "image/gif"},
+	{"GIF89a", "image/gif"},
+}
+
+// SniffMediaType returns the media type of the resource whose content
+// starts with head, judged by its magic number, or "" when head matches no
+// known format. It recognizes fonts and raster images.
+func SniffMediaType(head []byte) string {
+	for _, s := range signatures {
+		if bytes.HasPrefix(head, []byte(s.magic)) {
+			return s.mediaType
+		}
+	}
+	if len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP" {
+		return "image/webp"
+	}
+	return ""
+}
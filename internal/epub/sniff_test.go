@@ -0,0 +1,26 @@
+package epub
+
+import "testing"
+
+func TestSniffMediaType(t *testing.T) {
+	tests := []struct {
+		head string
+		want string
+	}{
+		{"\x00\x01\x00\x00\x00\x10", "font/ttf"},
+		{"OTTO\x00\x0b", "font/otf"},
+		{"wOFF\x00\x01", "font/woff"},
+		{"wOF2\x00\x01", "font/woff2"},
+		{"\x89PNG\r\n\x1a\n", "image/png"},
+		{"\xff\xd8\xff\xe0", "image/jpeg"},
+		{"GIF89a", "image/gif"},
+		{"RIFF\x24\x00\x00\x00WEBPVP8 ", "image/webp"},
+		{"\x3e\x91\xc4\x07", ""},
+		{"OTT", ""},
+	}
+	for _, tt := range tests {
+		if got := SniffMediaType([]byte(tt.head)); got != tt.want {
+			t.Errorf("SniffMediaType(%q) = %q, want %q", tt.head, got, tt.want)
+		}
+	}
+}
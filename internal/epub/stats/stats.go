@@ -0,0 +1,231 @@
+// Package stats counts the words, characters, and images of content
+// documents and estimates how long they take to read.
+package stats
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// WordsPerMinute is the silent reading speed used for estimates, the
+// average reported for adult readers of non-fiction in English.
+const WordsPerMinute = 238
+
+// Counts holds the statistics of one document or of a whole book.
+type Counts struct {
+	// Words counts runs of letters and digits, plus one word for every two
+	// CJK characters, since those scripts do not separate words with
+	// spaces.
+	Words int `json:"words"`
+	// Characters counts the letters, digits, and punctuation of the text,
+	// leaving out whitespace.
+	Characters int `json:"characters"`
+	// Images counts img elements and SVG image elements.
+	Images int `json:"images"`
+}
+
+// Add returns the sum of c and o.
+func (c Counts) Add(o Counts) Counts {
+	return Counts{
+		Words:      c.Words + o.Words,
+		Characters: c.Characters + o.Characters,
+		Images:     c.Images + o.Images,
+	}
+}
+
+// ReadingMinutes estimates the time to read c at WordsPerMinute.
+func (c Counts) ReadingMinutes() float64 {
+	return float64(c.Words) / WordsPerMinute
+}
+
+// Document counts the body of an XHTML content document. Go template
+// actions, scripts, styles, and template elements are left out.
+func Document(content []byte) Counts {
+	text, images := BodyText(content)
+	return Counts{
+		Words:      CountWords(text),
+		Characters: CountCharacters(text),
+		Images:     images,
+	}
+}
+
+// skipped names the elements whose content is not read.
+var skipped = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"template": true,
+	"head":     true,
+}
+
+// inline names the elements that do not break words, so that
+// "<b>un</b>usual" reads as one word. Every other element boundary counts
+// as whitespace.
+var inline = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true,
+	"cite": true, "code": true, "data": true, "dfn": true, "em": true,
+	"i": true, "kbd": true, "mark": true, "q": true, "s": true,
+	"samp": true, "small": true, "span": true, "strong": true, "sub": true,
+	"sup": true, "time": true, "u": true, "var": true, "ins": true,
+	"del": true, "ruby": true,
+}
+
+// IsInline reports whether the element name is read as part of the text
+// around it rather than as a block of its own.
+func IsInline(name string) bool {
+	return inline[name]
+}
+
+// IsSkipped reports whether the content of the element name is left out
+// of the text.
+func IsSkipped(name string) bool {
+	return skipped[name]
+}
+
+// BodyText returns the text of the document's body in reading order, with
+// whitespace collapsed to single spaces, and the number of images in it.
+// Go template actions are removed before parsing and the parser is
+// lenient, so a document that is not yet well-formed still yields the text
+// up to its first unrecoverable error. Documents without a body element
+// are read in full.
+func BodyText(content []byte) (text string, images int) {
+	decoder := xml.NewDecoder(bytes.NewReader(stripTemplates(content)))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	hasBody := bytes.Contains(content, []byte("<body"))
+	var (
+		b      strings.Builder
+		inBody = !hasBody
+		skip   int
+	)
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "body":
+				inBody = true
+			case skip > 0 || skipped[name]:
+				skip++
+			case !inBody:
+			case name == "img" || name == "image":
+				images++
+			}
+			if !inline[name] {
+				b.WriteByte(' ')
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if name == "body" {
+				inBody = false
+			} else if skip > 0 {
+				skip--
+			}
+			if !inline[name] {
+				b.WriteByte(' ')
+			}
+		case xml.CharData:
+			if inBody && skip == 0 {
+				b.Write(t)
+			}
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " "), images
+}
+
+// stripTemplates removes Go template actions, {{ ... }}, from content.
+// An unterminated action runs to the end.
+func stripTemplates(content []byte) []byte {
+	if !bytes.Contains(content, []byte("{{")) {
+		return content
+	}
+	out := make([]byte, 0, len(content))
+	for {
+		start := bytes.Index(content, []byte("{{"))
+		if start < 0 {
+			return append(out, content...)
+		}
+		out = append(out, content[:start]...)
+		end := bytes.Index(content[start+2:], []byte("}}"))
+		if end < 0 {
+			return out
+		}
+		content = content[start+2+end+2:]
+	}
+}
+
+// CountWords counts the words of text. Runs of letters and digits count
+// as one word each, joined across apostrophes and hyphens, as in "don't"
+// and "well-known". Han, Hiragana, and Katakana characters are not
+// separated by spaces, so each two of them count as one word, rounding up.
+func CountWords(text string) int {
+	words, cjk := 0, 0
+	inWord := false
+	runes := []rune(text)
+	for i, r := range runes {
+		switch {
+		case isCJK(r):
+			cjk++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			if !inWord {
+				words++
+				inWord = true
+			}
+		case inWord && (r == '\'' || r == '’' || r == '-') &&
+			i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1])):
+			// Joins the word with what follows
+		default:
+			inWord = false
+		}
+	}
+	return words + (cjk+1)/2
+}
+
+// isCJK reports whether r belongs to a script written without spaces
+// between words.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// CountCharacters counts the runes of text other than whitespace.
+func CountCharacters(text string) int {
+	n := 0
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// FormatReadingTime renders minutes as "~25 min" under an hour and as
+// "~5.6 h" from there on.
+func FormatReadingTime(minutes float64) string {
+	if minutes < 59.5 {
+		return fmt.Sprintf("~%d min", max(1, int(math.Round(minutes))))
+	}
+	return fmt.Sprintf("~%.1f h", minutes/60)
+}
+
+// FormatCount renders n with thousands separators, as in "84,310".
+func FormatCount(n int) string {
+	if n < 0 {
+		return "-" + FormatCount(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
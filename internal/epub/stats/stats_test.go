@@ -0,0 +1,94 @@
+package stats
+
+import "testing"
+
+const mixed = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Not counted</title><style>p { color: red }</style></head>
+<body>
+  <h1>Chapter {{ .Number }} One</h1>
+  <p>An <em>un</em>usual, well-known   story&#8212;isn't it?</p>
+  <script>var words = "not counted either";</script>
+  <template><p>Nor this</p></template>
+  {{ if .Draft }}<p>Draft note</p>{{ end }}
+  <figure><img src="a.png" alt="A"/><figcaption>Figure&nbsp;1</figcaption></figure>
+  <svg xmlns="http://www.w3.org/2000/svg"><image href="b.png"/></svg>
+</body>
+</html>`
+
+func TestBodyText(t *testing.T) {
+	text, images := BodyText([]byte(mixed))
+
+	want := "Chapter One An unusual, well-known story—isn't it? Draft note Figure 1"
+	if text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+	if images != 2 {
+		t.Errorf("images = %d, want 2", images)
+	}
+}
+
+func TestBodyText_Malformed(t *testing.T) {
+	text, _ := BodyText([]byte(`<body><p>Open paragraph<br><p>Still read`))
+	if text != "Open paragraph Still read" {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	got := Document([]byte(mixed))
+	// Chapter One An unusual well-known story isn't it Draft note Figure 1
+	want := Counts{Words: 12, Characters: 60, Images: 2}
+	if got != want {
+		t.Errorf("Document = %+v, want %+v", got, want)
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"one two  three", 3},
+		{"don't stop — well-known", 3},
+		{"trailing- hyphen 'quoted'", 3},
+		{"日本語の文章です", 4},
+		{"吾輩は猫である", 4},
+		{"Tokyo 東京 2024", 3},
+		{"한국어 문장입니다", 2},
+		{"Ça déjà-vu", 2},
+	}
+	for _, tt := range tests {
+		if got := CountWords(tt.text); got != tt.want {
+			t.Errorf("CountWords(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCountCharacters(t *testing.T) {
+	if got := CountCharacters("日本 語\tab"); got != 5 {
+		t.Errorf("CountCharacters = %d, want 5", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := FormatCount(84310); got != "84,310" {
+		t.Errorf("FormatCount = %q", got)
+	}
+	if got := FormatCount(1234567); got != "1,234,567" {
+		t.Errorf("FormatCount = %q", got)
+	}
+	if got := FormatCount(999); got != "999" {
+		t.Errorf("FormatCount = %q", got)
+	}
+	if got := FormatReadingTime(336); got != "~5.6 h" {
+		t.Errorf("FormatReadingTime = %q", got)
+	}
+	if got := FormatReadingTime(0.2); got != "~1 min" {
+		t.Errorf("FormatReadingTime = %q", got)
+	}
+	if got := FormatReadingTime(25.4); got != "~25 min" {
+		t.Errorf("FormatReadingTime = %q", got)
+	}
+}
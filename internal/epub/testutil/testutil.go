@@ -2,6 +2,7 @@
 package testutil
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/toba/epub-lsp/internal/epub"
@@ -45,7 +46,63 @@ func SeverityName(s int) string {
 		return "Warning"
 	case epub.SeverityInfo:
 		return "Info"
+	case epub.SeverityHint:
+		return "Hint"
 	default:
 		return "Unknown"
 	}
 }
+
+// CRLF returns a copy of content with every "\n" line ending replaced by "\r\n".
+func CRLF(content []byte) []byte {
+	return bytes.ReplaceAll(content, []byte("\n"), []byte("\r\n"))
+}
+
+// ExpectSameDiagnostics fails the test unless got reports the same codes at
+// the same ranges as want, in order. It is used to check that CRLF variants
+// of a fixture produce the same diagnostics as the LF original.
+func ExpectSameDiagnostics(t *testing.T, want, got []epub.Diagnostic) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d: %v",
+			len(want), len(got), DiagCodes(got))
+	}
+	for i := range want {
+		if got[i].Code != want[i].Code || got[i].Range != want[i].Range {
+			t.Errorf("diagnostic %d: got %s at %v, want %s at %v",
+				i, got[i].Code, got[i].Range, want[i].Code, want[i].Range)
+		}
+	}
+}
+
+// EPUBNamespace declares the epub prefix, for an XHTMLDocument's HTMLAttrs.
+const EPUBNamespace = ` xmlns:epub="http://www.idpf.org/2007/ops"`
+
+// XHTMLDocument describes an XHTML content document for tests: an html
+// element declaring the XHTML namespace, a head titled "Test", and a body
+// holding what a test adds to it.
+type XHTMLDocument struct {
+	// HTMLAttrs and BodyAttrs are added, each with its leading space, to
+	// the html and body start tags.
+	HTMLAttrs string
+	BodyAttrs string
+	// Head, when set, replaces the head element.
+	Head string
+	// Body is the content of the body element, on lines of its own.
+	Body string
+}
+
+// Bytes returns the content document.
+func (d XHTMLDocument) Bytes() []byte {
+	head := d.Head
+	if head == "" {
+		head = "<head><title>Test</title></head>"
+	}
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"` + d.HTMLAttrs + `>
+` + head + `
+<body` + d.BodyAttrs + `>
+` + d.Body + `
+</body>
+</html>`)
+}
@@ -0,0 +1,45 @@
+package epub
+
+import "strings"
+
+// asciiWhitespace separates the tokens of space-separated attribute values
+// such as epub:type, role, class, and properties.
+const asciiWhitespace = " \t\n\r\f"
+
+// Token is one token of a space-separated attribute value, with its byte
+// offsets in the value.
+type Token struct {
+	Value      string
+	Start, End int
+}
+
+// Tokens splits a space-separated attribute value into its tokens.
+func Tokens(value string) []Token {
+	var tokens []Token
+	for i := 0; i < len(value); {
+		if strings.IndexByte(asciiWhitespace, value[i]) >= 0 {
+			i++
+			continue
+		}
+		end := len(value)
+		if j := strings.IndexAny(value[i:], asciiWhitespace); j >= 0 {
+			end = i + j
+		}
+		tokens = append(tokens, Token{Value: value[i:end], Start: i, End: end})
+		i = end
+	}
+	return tokens
+}
+
+// TokenAt returns the byte offsets of the token of value that contains
+// offset, or touches it from the left. Between tokens the span is empty,
+// at offset.
+func TokenAt(value string, offset int) (start, end int) {
+	offset = min(max(offset, 0), len(value))
+	start = strings.LastIndexAny(value[:offset], asciiWhitespace) + 1
+	end = len(value)
+	if i := strings.IndexAny(value[offset:], asciiWhitespace); i >= 0 {
+		end = offset + i
+	}
+	return start, end
+}
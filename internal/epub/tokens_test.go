@@ -0,0 +1,47 @@
+package epub
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	got := Tokens("  page-spread-left\trendition:layout-pre-paginated  x ")
+	want := []Token{
+		{"page-spread-left", 2, 18},
+		{"rendition:layout-pre-paginated", 19, 49},
+		{"x", 51, 52},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Tokens = %+v, want %+v", got, want)
+	}
+	if got := Tokens(" \t "); len(got) != 0 {
+		t.Errorf("Tokens of whitespace = %+v, want none", got)
+	}
+}
+
+func TestTokenAt(t *testing.T) {
+	value := "chapter  bodymatter"
+	tests := []struct {
+		name       string
+		offset     int
+		start, end int
+	}{
+		{"start of value", 0, 0, 7},
+		{"mid-token", 3, 0, 7},
+		{"end of token", 7, 0, 7},
+		{"between tokens", 8, 8, 8},
+		{"start of second token", 9, 9, 19},
+		{"end of value", 19, 9, 19},
+		{"past the end", 40, 9, 19},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := TokenAt(value, tt.offset)
+			if start != tt.start || end != tt.end {
+				t.Errorf("TokenAt(%d) = %d, %d, want %d, %d", tt.offset, start, end, tt.start, tt.end)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Package uriutil resolves references between workspace files and matches
+// them against the document URIs that key the workspace.
+//
+// Paths are compared exactly, after percent-decoding and cleaning. They are
+// case-sensitive, as EPUB paths are, except for Windows drive letters,
+// which clients send in either case.
+package uriutil
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Path returns the decoded, cleaned path of a file URI. Strings without a
+// scheme are treated as paths, and Windows paths and drive letters are
+// normalized to the form "/c:/dir/file". URIs with any other scheme are
+// returned unchanged.
+func Path(uri string) string {
+	p := uri
+	if isDrivePath(uri) {
+		p = "/" + strings.ReplaceAll(uri, `\`, "/")
+	} else if u, err := url.Parse(uri); err == nil {
+		if u.Scheme != "" && u.Scheme != "file" || u.Path == "" {
+			return uri
+		}
+		p = u.Path
+	}
+
+	if len(p) >= 3 && p[0] == '/' && isDrivePath(p[1:]) {
+		p = "/" + strings.ToLower(p[1:2]) + p[2:]
+	}
+	return path.Clean(p)
+}
+
+// Dir returns the directory of the path of a file URI.
+func Dir(uri string) string {
+	return path.Dir(Path(uri))
+}
+
+// NormalizeURI returns a file URI in canonical form, with its path
+// decoded, cleaned, and re-encoded, so that equal paths give equal URIs.
+// Strings without a scheme are normalized as paths.
+func NormalizeURI(uri string) string {
+	if !isFileURI(uri) {
+		if hasScheme(uri) {
+			return uri
+		}
+		return Path(uri)
+	}
+	return fileURI(Path(uri))
+}
+
+// ResolveRelative resolves ref, a possibly percent-encoded reference from
+// the document at baseURI, to the URI of the file it names. Queries and
+// fragments are dropped, so a same-document reference resolves to baseURI
+// itself. It returns "" for references with a scheme, such as remote or
+// data URLs. The result is a file URI when baseURI is one and a path
+// otherwise.
+func ResolveRelative(baseURI, ref string) string {
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		ref = ref[:i]
+	}
+	if hasScheme(ref) {
+		return ""
+	}
+	if decoded, err := url.PathUnescape(ref); err == nil {
+		ref = decoded
+	}
+
+	var p string
+	switch {
+	case ref == "":
+		p = Path(baseURI)
+	case path.IsAbs(ref):
+		p = path.Clean(ref)
+	default:
+		p = path.Join(Dir(baseURI), ref)
+	}
+
+	if isFileURI(baseURI) {
+		return fileURI(p)
+	}
+	return p
+}
+
+// Lookup returns the key of files whose path is the same as that of
+// resolved, a URI or path from ResolveRelative.
+func Lookup(files map[string][]byte, resolved string) (string, bool) {
+	if resolved == "" {
+		return "", false
+	}
+	if _, ok := files[resolved]; ok {
+		return resolved, true
+	}
+	want := Path(resolved)
+	for uri := range files {
+		if Path(uri) == want {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// fileURI returns the file URI of the slash-separated absolute path p.
+func fileURI(p string) string {
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+func isFileURI(uri string) bool {
+	return len(uri) >= 5 && strings.EqualFold(uri[:5], "file:")
+}
+
+// hasScheme reports whether ref starts with a URI scheme. A drive letter
+// is not a scheme.
+func hasScheme(ref string) bool {
+	i := strings.IndexByte(ref, ':')
+	if i <= 0 || isDrivePath(ref) {
+		return false
+	}
+	for j, c := range []byte(ref[:i]) {
+		switch {
+		case c|0x20 >= 'a' && c|0x20 <= 'z':
+		case j > 0 && (c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isDrivePath reports whether p starts with a Windows drive letter such as
+// "C:" followed by a separator or nothing.
+func isDrivePath(p string) bool {
+	if len(p) < 2 || p[1] != ':' || p[0]|0x20 < 'a' || p[0]|0x20 > 'z' {
+		return false
+	}
+	return len(p) == 2 || p[2] == '/' || p[2] == '\\'
+}
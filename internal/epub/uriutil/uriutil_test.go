@@ -0,0 +1,99 @@
+package uriutil
+
+import "testing"
+
+func TestPath(t *testing.T) {
+	tests := []struct{ uri, want string }{
+		{"file:///book/OEBPS/ch1.xhtml", "/book/OEBPS/ch1.xhtml"},
+		{"file:///book/OEBPS/text/../ch1.xhtml", "/book/OEBPS/ch1.xhtml"},
+		{"file:///book/My%20Book/ch%201.xhtml", "/book/My Book/ch 1.xhtml"},
+		{"file:///C:/Books/ch1.xhtml", "/c:/Books/ch1.xhtml"},
+		{"file:///c%3A/Books/ch1.xhtml", "/c:/Books/ch1.xhtml"},
+		{`C:\Books\ch1.xhtml`, "/c:/Books/ch1.xhtml"},
+		{"/book/ch1.xhtml", "/book/ch1.xhtml"},
+		{"ch1.xhtml", "ch1.xhtml"},
+		{"https://example.com/a.css", "https://example.com/a.css"},
+	}
+	for _, tt := range tests {
+		if got := Path(tt.uri); got != tt.want {
+			t.Errorf("Path(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeURI(t *testing.T) {
+	tests := []struct{ uri, want string }{
+		{"file:///book/a%20b.xhtml", "file:///book/a%20b.xhtml"},
+		{"file:///book/a b.xhtml", "file:///book/a%20b.xhtml"},
+		{"file:///book/./text/../a.xhtml", "file:///book/a.xhtml"},
+		{"file:///C:/Books/a.xhtml", "file:///c:/Books/a.xhtml"},
+		{"file:///c%3A/Books/a.xhtml", "file:///c:/Books/a.xhtml"},
+		{"urn:uuid:1234", "urn:uuid:1234"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeURI(tt.uri); got != tt.want {
+			t.Errorf("NormalizeURI(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestResolveRelative(t *testing.T) {
+	const base = "file:///book/OEBPS/text/ch1.xhtml"
+	tests := []struct{ base, ref, want string }{
+		{base, "ch2.xhtml", "file:///book/OEBPS/text/ch2.xhtml"},
+		{base, "ch2.xhtml#p3", "file:///book/OEBPS/text/ch2.xhtml"},
+		{base, "../images/a%20b.png", "file:///book/OEBPS/images/a%20b.png"},
+		{base, "../images/a b.png?v=2", "file:///book/OEBPS/images/a%20b.png"},
+		{base, "#note", base},
+		{base, "", base},
+		{base, "/book/OEBPS/css/style.css", "file:///book/OEBPS/css/style.css"},
+		{base, "https://example.com/a.png", ""},
+		{base, "data:image/png;base64,AAAA", ""},
+		{base, "mailto:a@example.com", ""},
+		{
+			"file:///C:/Books/OEBPS/ch1.xhtml", "ch2.xhtml",
+			"file:///c:/Books/OEBPS/ch2.xhtml",
+		},
+		{"OEBPS/content.opf", "text/ch1.xhtml", "OEBPS/text/ch1.xhtml"},
+	}
+	for _, tt := range tests {
+		if got := ResolveRelative(tt.base, tt.ref); got != tt.want {
+			t.Errorf("ResolveRelative(%q, %q) = %q, want %q",
+				tt.base, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	const (
+		chapter1 = "file:///book/OEBPS/text/chapter1.xhtml"
+		image    = "file:///book/OEBPS/My%20Images/a.png"
+		windows  = "file:///c%3A/Books/OEBPS/chapter2.xhtml"
+	)
+	files := map[string][]byte{
+		"file:///book/OEBPS/notchapter1.xhtml": nil,
+		chapter1:                               nil,
+		image:                                  nil,
+		windows:                                nil,
+	}
+	tests := []struct {
+		resolved string
+		want     string
+	}{
+		{chapter1, chapter1},
+		// A suffix of another file's name or path is not a match
+		{"file:///book/OEBPS/chapter1.xhtml", ""},
+		{"file:///OEBPS/text/chapter1.xhtml", ""},
+		{"file:///book/OEBPS/My Images/a.png", image},
+		{"/book/OEBPS/My Images/a.png", image},
+		{"file:///C:/Books/OEBPS/chapter2.xhtml", windows},
+		{"file:///c:/books/OEBPS/chapter2.xhtml", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := Lookup(files, tt.resolved)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Lookup(%q) = %q, %v, want %q", tt.resolved, got, ok, tt.want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package epub
 
 import (
+	"fmt"
+	"net/url"
+	"path"
 	"slices"
 	"strings"
 )
@@ -23,3 +26,66 @@ func StripFragment(ref string) string {
 func ContainsToken(tokenList, token string) bool {
 	return slices.Contains(strings.Fields(tokenList), token)
 }
+
+// DecodeHref returns href with its percent-encoding decoded, or href
+// unchanged when it holds an invalid escape.
+func DecodeHref(href string) string {
+	if decoded, err := url.PathUnescape(href); err == nil {
+		return decoded
+	}
+	return href
+}
+
+// ResolveHref resolves a relative, possibly percent-encoded href against a
+// base directory.
+func ResolveHref(baseDir, href string) string {
+	if href == "" {
+		return ""
+	}
+	href = DecodeHref(href)
+	if path.IsAbs(href) {
+		return href
+	}
+	return path.Clean(baseDir + "/" + href)
+}
+
+// PathEndsWith reports whether full equals suffix or ends with it on a path
+// segment boundary.
+func PathEndsWith(full, suffix string) bool {
+	if full == suffix {
+		return true
+	}
+	return strings.HasSuffix(full, "/"+suffix)
+}
+
+// EncodeURLPath percent-encodes the characters of href's path that a URL
+// may not hold literally: spaces and other ASCII controls, non-ASCII
+// characters, and `"<>^`{|}`. The query and fragment are left as they are,
+// as are backslashes and existing %XX escapes; a % that does not start one
+// is encoded as %25.
+func EncodeURLPath(href string) string {
+	end := len(href)
+	if i := strings.IndexAny(href, "?#"); i >= 0 {
+		end = i
+	}
+
+	var b strings.Builder
+	for i := 0; i < end; i++ {
+		c := href[i]
+		switch {
+		case c == '%' && i+2 < end && isHex(href[i+1]) && isHex(href[i+2]):
+			b.WriteByte(c)
+		case c == '%' || c <= ' ' || c >= 0x7f || strings.IndexByte("\"<>^`{|}", c) >= 0:
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteString(href[end:])
+	return b.String()
+}
+
+// isHex reports whether c is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,31 @@
+package epub
+
+import "testing"
+
+func TestEncodeURLPath(t *testing.T) {
+	tests := []struct {
+		href, want string
+	}{
+		{"chapter1.xhtml", "chapter1.xhtml"},
+		{"my chapter.xhtml", "my%20chapter.xhtml"},
+		{"my%20chapter.xhtml", "my%20chapter.xhtml"},
+		{"my%20new chapter.xhtml", "my%20new%20chapter.xhtml"},
+		{"100%.xhtml", "100%25.xhtml"},
+		{"50%zz.xhtml", "50%25zz.xhtml"},
+		{"café.xhtml", "caf%C3%A9.xhtml"},
+		{"text/my chapter.xhtml#sec 1", "text/my%20chapter.xhtml#sec 1"},
+		{"a b.xhtml?q=x y", "a%20b.xhtml?q=x y"},
+		{"a%2", "a%252"},
+		{"images\\my cover.jpg", "images\\my%20cover.jpg"},
+		{"{x}|y.png", "%7Bx%7D%7Cy.png"},
+	}
+	for _, tt := range tests {
+		if got := EncodeURLPath(tt.href); got != tt.want {
+			t.Errorf("EncodeURLPath(%q) = %q, want %q", tt.href, got, tt.want)
+		}
+		// Encoding is idempotent
+		if got := EncodeURLPath(tt.want); got != tt.want {
+			t.Errorf("EncodeURLPath(%q) = %q, want it unchanged", tt.want, got)
+		}
+	}
+}
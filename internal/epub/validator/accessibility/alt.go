@@ -0,0 +1,157 @@
+package accessibility
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// DefaultRedundantAltPhrases lists the phrases that make alt text redundant
+// when it starts with one, since screen readers already announce an image.
+var DefaultRedundantAltPhrases = []string{
+	"image of",
+	"picture of",
+	"graphic of",
+	"photo of",
+	"photograph of",
+	"illustration of",
+}
+
+// imageExtRe matches an image file extension at the end of a word.
+var imageExtRe = regexp.MustCompile(
+	`(?i)\.(?:jpe?g|png|gif|svg|webp|bmp|tiff?|avif)\b`,
+)
+
+// redundantAltPhrases returns the configured redundant alt phrases, or the
+// defaults when none are configured.
+func redundantAltPhrases(ctx *validator.WorkspaceContext) []string {
+	if ctx != nil && ctx.AltRedundantPhrases != nil {
+		return ctx.AltRedundantPhrases
+	}
+	return DefaultRedundantAltPhrases
+}
+
+// checkAltText checks image alt text for redundant phrases, file names,
+// and duplication of the figure caption.
+func checkAltText(
+	lines *epub.LineIndex,
+	root *parser.XMLNode,
+	phrases []string,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+
+	for _, img := range root.FindAll("img") {
+		alt := normalizeText(img.Attr("alt"))
+		if alt == "" {
+			continue
+		}
+
+		if phrase := redundantPrefix(alt, phrases); phrase != "" {
+			diags = append(diags, epub.NewDiagAt(lines, int(img.Offset), source).
+				Code("alt-redundant-phrase").
+				Info(`alt text starts with "`+phrase+`"; screen readers `+
+					"already announce images").
+				Build())
+		}
+
+		if looksLikeFileName(alt, img.Attr("src")) {
+			diags = append(diags, epub.NewDiagAt(lines, int(img.Offset), source).
+				Code("alt-filename").
+				Info("alt text looks like a file name rather than a description").
+				Build())
+		}
+	}
+
+	for _, figure := range root.FindAll("figure") {
+		caption := figure.FindFirst("figcaption")
+		if caption == nil {
+			continue
+		}
+		text := normalizeText(caption.Text())
+		if text == "" {
+			continue
+		}
+		for _, img := range figure.FindAll("img") {
+			if normalizeText(img.Attr("alt")) == text {
+				diags = append(diags, epub.NewDiagAt(lines, int(img.Offset), source).
+					Code("alt-duplicates-caption").
+					Warning("alt text repeats the <figcaption>, so it is read twice").
+					Build())
+			}
+		}
+	}
+
+	return diags
+}
+
+// redundantPrefix returns the first phrase that normalized alt text starts
+// with as whole words, or "" if there is none.
+func redundantPrefix(alt string, phrases []string) string {
+	for _, phrase := range phrases {
+		p := normalizeText(phrase)
+		rest, ok := strings.CutPrefix(alt, p)
+		if !ok || p == "" {
+			continue
+		}
+		if rest == "" || !isWordRune([]rune(rest)[0]) {
+			return phrase
+		}
+	}
+	return ""
+}
+
+// looksLikeFileName reports whether normalized alt text contains an image
+// file extension or is the base name of src, with or without extension.
+func looksLikeFileName(alt, src string) bool {
+	if imageExtRe.MatchString(alt) {
+		return true
+	}
+	src, _, _ = strings.Cut(src, "#")
+	src, _, _ = strings.Cut(src, "?")
+	base := normalizeText(path.Base(src))
+	if src == "" || base == "" {
+		return false
+	}
+	return alt == base || alt == strings.TrimSuffix(base, path.Ext(base))
+}
+
+// normalizeText collapses runs of whitespace, including non-breaking
+// spaces, to single spaces, trims the ends, and case folds the result so
+// that equal normalized strings match without regard to case.
+func normalizeText(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(foldRune(r))
+	}
+	return b.String()
+}
+
+// foldRune returns the smallest rune in r's Unicode simple case folding
+// orbit, so every case variant of a letter maps to the same rune.
+func foldRune(r rune) rune {
+	folded := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		folded = min(folded, f)
+	}
+	return folded
+}
+
+// isWordRune reports whether r continues a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,143 @@
+package accessibility
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"A Map", "a map", true},
+		{"  a\n\t map ", "a map", true},
+		{"a map", "a map", true},
+		{"ÉTÉ", "été", true},
+		{"ΣΟΦΙΑ", "σοφια", true},
+		{"Kelvin K", "kelvin k", true},
+		{"a map", "amap", false},
+		{"map", "maps", false},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeText(tt.a) == normalizeText(tt.b); got != tt.same {
+			t.Errorf("normalizeText(%q) == normalizeText(%q) is %v, want %v",
+				tt.a, tt.b, got, tt.same)
+		}
+	}
+
+	if got := normalizeText("   "); got != "" {
+		t.Errorf("expected whitespace to normalize to empty, got %q", got)
+	}
+	if got := normalizeText("a  b"); got != normalizeText("a b") {
+		t.Errorf("expected runs of spaces to collapse, got %q", got)
+	}
+}
+
+func TestRedundantPrefix(t *testing.T) {
+	phrases := []string{"image of", "Picture of"}
+	tests := []struct {
+		alt, want string
+	}{
+		{"Image of a cat", "image of"},
+		{"PICTURE  OF a dog", "Picture of"},
+		{"image of", "image of"},
+		{"image of: a map", "image of"},
+		{"image offset chart", ""},
+		{"a cat", ""},
+		{"an image of a cat", ""},
+	}
+
+	for _, tt := range tests {
+		if got := redundantPrefix(normalizeText(tt.alt), phrases); got != tt.want {
+			t.Errorf("redundantPrefix(%q) = %q, want %q", tt.alt, got, tt.want)
+		}
+	}
+}
+
+func TestAltTextChecks(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"descriptive alt", `<img src="cat.jpg" alt="A cat asleep on a rug"/>`, nil},
+		{"decorative image", `<img src="rule.png" alt=""/>`, nil},
+		{"redundant phrase", `<img src="cat.jpg" alt="Image of a cat"/>`,
+			[]string{"alt-redundant-phrase"}},
+		{"phrase mid-sentence", `<img src="cat.jpg" alt="A cat, not an image of one"/>`,
+			nil},
+		{"file name with extension", `<img src="images/cat.jpg" alt="IMG_0042.JPG"/>`,
+			[]string{"alt-filename"}},
+		{"src base name", `<img src="images/figure-3.png" alt="figure-3"/>`,
+			[]string{"alt-filename"}},
+		{"src base name with extension", `<img src="images/fig3.png" alt="Fig3.png"/>`,
+			[]string{"alt-filename"}},
+		{"word that is not an extension", `<img src="a.png" alt="A png-style icon"/>`,
+			nil},
+		{
+			"alt duplicates caption",
+			`<figure><img src="map.png" alt="Map of the  island"/>` +
+				`<figcaption>map of the island</figcaption></figure>`,
+			[]string{"alt-duplicates-caption"},
+		},
+		{
+			"alt differs from caption",
+			`<figure><img src="map.png" alt="Hand-drawn map with three harbors"/>` +
+				`<figcaption>Map of the island</figcaption></figure>`,
+			nil,
+		},
+		{
+			"caption with markup",
+			`<figure><img src="map.png" alt="The island in 1750"/>` +
+				`<figcaption><em>The Island in 1750</em></figcaption></figure>`,
+			[]string{"alt-duplicates-caption"},
+		},
+		{
+			"img outside figure matching other text",
+			`<p>Map of the island</p><img src="map.png" alt="Map of the island"/>`,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &StructureValidator{}
+			content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+			diags := v.Validate("chapter.xhtml", content, nil)
+			var got []string
+			for _, d := range diags {
+				got = append(got, d.Code)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAltRedundantPhrasesConfigured(t *testing.T) {
+	content := testutil.XHTMLDocument{
+		Body: `<img src="a.png" alt="Image of a cat"/>` +
+			`<img src="b.png" alt="Drawing of a dog"/>`,
+	}.Bytes()
+	ctx := &validator.WorkspaceContext{
+		AccessibilitySeverity: epub.SeverityWarning,
+		AltRedundantPhrases:   []string{"drawing of"},
+	}
+	diags := (&StructureValidator{}).Validate("chapter.xhtml", content, ctx)
+
+	second := len(`<img src="a.png" alt="Image of a cat"/>`)
+	if len(diags) != 1 || diags[0].Code != "alt-redundant-phrase" ||
+		diags[0].Range.Start.Character != second {
+		t.Fatalf("expected only the configured phrase to be reported, got %v", diags)
+	}
+	if diags[0].Severity != epub.SeverityInfo {
+		t.Errorf("expected info severity, got %d", diags[0].Severity)
+	}
+}
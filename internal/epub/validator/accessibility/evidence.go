@@ -0,0 +1,198 @@
+package accessibility
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// absoluteFontSizeLimit is how many absolute font-size declarations the
+// linked stylesheets may hold before displayTransformability is doubted.
+const absoluteFontSizeLimit = 10
+
+// absoluteUnits are the CSS length units that do not scale with the
+// reader's chosen font size.
+var absoluteUnits = []string{"px", "pt", "pc", "cm", "mm", "in", "q"}
+
+// contentEvidence summarizes what the content documents of a workspace
+// hold, for checking the accessibility features a package declares.
+type contentEvidence struct {
+	missingAlt  int
+	hasMath     bool
+	hasHeadings bool
+	hasTOC      bool
+	// stylesheets holds the workspace URIs of the stylesheets linked from
+	// content documents.
+	stylesheets map[string]bool
+}
+
+// gatherEvidence walks every content and navigation document in ctx.
+// Documents that are not well-formed are skipped.
+func gatherEvidence(ctx *validator.WorkspaceContext) contentEvidence {
+	ev := contentEvidence{stylesheets: make(map[string]bool)}
+	for uri, content := range ctx.Files {
+		ft := ctx.FileTypes[uri]
+		if ft != epub.FileTypeXHTML && ft != epub.FileTypeNav {
+			continue
+		}
+		root, diags := parser.Parse(content)
+		if len(diags) > 0 {
+			continue
+		}
+		walkElements(root, func(node, _ *parser.XMLNode) {
+			switch node.Local {
+			case "img":
+				if !node.HasAttr("alt") {
+					ev.missingAlt++
+				}
+			case "math":
+				ev.hasMath = true
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				ev.hasHeadings = true
+			case "nav":
+				if epub.ContainsToken(node.AttrNS(epub.NSEpub, "type"), "toc") {
+					ev.hasTOC = true
+				}
+			case "link":
+				href := node.Attr("href")
+				if href == "" || !epub.ContainsToken(strings.ToLower(node.Attr("rel")), "stylesheet") {
+					return
+				}
+				target := uriutil.ResolveRelative(uri, epub.StripFragment(href))
+				if found, ok := uriutil.Lookup(ctx.Files, target); ok {
+					ev.stylesheets[found] = true
+				}
+			}
+		})
+	}
+	return ev
+}
+
+// featureEvidence pairs an accessibility feature with the check that the
+// content backs it.
+type featureEvidence struct {
+	feature  string
+	code     string
+	severity int
+	// contradicted returns the message to report when the evidence
+	// contradicts the feature, or "".
+	contradicted func(ev contentEvidence, ctx *validator.WorkspaceContext) string
+}
+
+var featureEvidenceChecks = []featureEvidence{
+	{
+		"alternativeText", "feature-evidence-alt", epub.SeverityWarning,
+		func(ev contentEvidence, _ *validator.WorkspaceContext) string {
+			switch ev.missingAlt {
+			case 0:
+				return ""
+			case 1:
+				return "alternativeText feature declared but 1 image has no alt attribute"
+			}
+			return fmt.Sprintf(
+				"alternativeText feature declared but %d images have no alt attribute",
+				ev.missingAlt)
+		},
+	},
+	{
+		"MathML", "feature-evidence-mathml", epub.SeverityWarning,
+		func(ev contentEvidence, _ *validator.WorkspaceContext) string {
+			if ev.hasMath {
+				return ""
+			}
+			return "MathML feature declared but no content document has a <math> element"
+		},
+	},
+	{
+		"structuralNavigation", "feature-evidence-navigation", epub.SeverityWarning,
+		func(ev contentEvidence, _ *validator.WorkspaceContext) string {
+			if ev.hasTOC || ev.hasHeadings {
+				return ""
+			}
+			return "structuralNavigation feature declared but there is no toc nav " +
+				"and no content document has headings"
+		},
+	},
+	{
+		"displayTransformability", "feature-evidence-transformability", epub.SeverityInfo,
+		func(ev contentEvidence, ctx *validator.WorkspaceContext) string {
+			count := 0
+			for uri := range ev.stylesheets {
+				count += absoluteFontSizes(ctx.Files[uri])
+			}
+			if count <= absoluteFontSizeLimit {
+				return ""
+			}
+			return fmt.Sprintf("displayTransformability feature declared but linked "+
+				"stylesheets set %d absolute font sizes, which do not follow the "+
+				"reader's text size", count)
+		},
+	},
+}
+
+// checkFeatureEvidence reports accessibility features the package declares
+// that the content contradicts, at the meta declaring each.
+func checkFeatureEvidence(
+	content []byte,
+	metadata *parser.XMLNode,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	var (
+		diags    []epub.Diagnostic
+		ev       contentEvidence
+		gathered bool
+	)
+	for _, check := range featureEvidenceChecks {
+		meta := featureMeta(metadata, check.feature)
+		if meta == nil {
+			continue
+		}
+		if !gathered {
+			ev, gathered = gatherEvidence(ctx), true
+		}
+		message := check.contradicted(ev, ctx)
+		if message == "" {
+			continue
+		}
+		b := epub.NewDiag(content, int(meta.Offset), source).Code(check.code)
+		if check.severity == epub.SeverityInfo {
+			b = b.Info(message)
+		} else {
+			b = b.Warning(message)
+		}
+		diags = append(diags, b.Build())
+	}
+	return diags
+}
+
+// absoluteFontSizes counts the font-size declarations of a stylesheet
+// whose value is a non-zero absolute length.
+func absoluteFontSizes(css []byte) int {
+	decls, _, _ := parser.ScanCSS(css)
+	count := 0
+	for _, decl := range decls {
+		if strings.EqualFold(decl.Property, "font-size") && isAbsoluteLength(decl.Value) {
+			count++
+		}
+	}
+	return count
+}
+
+// isAbsoluteLength reports whether value is a non-zero length in an
+// absolute unit, such as 12px or 10pt.
+func isAbsoluteLength(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(
+		strings.TrimSpace(value), "!important")))
+	for _, unit := range absoluteUnits {
+		if number, ok := strings.CutSuffix(value, unit); ok {
+			size, err := strconv.ParseFloat(number, 64)
+			return err == nil && size != 0
+		}
+	}
+	return false
+}
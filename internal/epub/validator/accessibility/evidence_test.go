@@ -0,0 +1,100 @@
+package accessibility
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// featurePackage returns a package document declaring feature.
+func featurePackage(feature string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123</dc:identifier>
+    <dc:title>Test Book</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="schema:accessibilityFeature">` + feature + `</meta>
+  </metadata>
+  <manifest/>
+  <spine/>
+</package>`)
+}
+
+// validateEvidence validates a package declaring feature against a
+// workspace holding a chapter with body and a stylesheet with css.
+func validateEvidence(t *testing.T, feature, body, css string) []epub.Diagnostic {
+	t.Helper()
+	opfURI := "file:///book/OEBPS/package.opf"
+	opfContent := featurePackage(feature)
+	ctx := &validator.WorkspaceContext{
+		Files: map[string][]byte{
+			opfURI: opfContent,
+			"file:///book/OEBPS/ch1.xhtml": testutil.XHTMLDocument{
+				Body: `<link rel="stylesheet" href="style.css"/>` + body,
+			}.Bytes(),
+			"file:///book/OEBPS/style.css": []byte(css),
+		},
+		FileTypes: map[string]epub.FileType{
+			opfURI:                         epub.FileTypeOPF,
+			"file:///book/OEBPS/ch1.xhtml": epub.FileTypeXHTML,
+			"file:///book/OEBPS/style.css": epub.FileTypeCSS,
+		},
+		AccessibilitySeverity: epub.SeverityError,
+	}
+	return (&OPFAccessibilityValidator{}).Validate(opfURI, opfContent, ctx)
+}
+
+func TestFeatureEvidence(t *testing.T) {
+	fixedSizes := strings.Repeat("p { font-size: 12px }\nh1 { font-size: 18pt }\n", 6)
+	relativeSizes := strings.Repeat("p { font-size: 1em }\nh1 { font-size: 0 }\n", 6)
+
+	tests := []struct {
+		name, feature, body, css string
+		code                     string
+		want                     bool
+	}{
+		{"alt present", "alternativeText", `<img src="a.png" alt="A"/><img src="b.png" alt=""/>`, "", "feature-evidence-alt", false},
+		{"alt absent", "alternativeText", `<img src="a.png" alt="A"/><img src="b.png"/>`, "", "feature-evidence-alt", true},
+		{"math present", "MathML", `<math xmlns="http://www.w3.org/1998/Math/MathML"><mi>x</mi></math>`, "", "feature-evidence-mathml", false},
+		{"math absent", "MathML", `<p>x squared</p>`, "", "feature-evidence-mathml", true},
+		{"headings present", "structuralNavigation", `<h1>One</h1><p>Text</p>`, "", "feature-evidence-navigation", false},
+		{"toc present", "structuralNavigation", `<nav epub:type="toc" xmlns:epub="http://www.idpf.org/2007/ops"><ol/></nav>`, "", "feature-evidence-navigation", false},
+		{"headings absent", "structuralNavigation", `<p>Text</p>`, "", "feature-evidence-navigation", true},
+		{"relative font sizes", "displayTransformability", `<p>Text</p>`, relativeSizes, "feature-evidence-transformability", false},
+		{"absolute font sizes", "displayTransformability", `<p>Text</p>`, fixedSizes, "feature-evidence-transformability", true},
+		{"feature not declared", "tableOfContents", `<img src="a.png"/>`, fixedSizes, "feature-evidence-alt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := validateEvidence(t, tt.feature, tt.body, tt.css)
+			if got := testutil.HasCode(diags, tt.code); got != tt.want {
+				t.Errorf("%s reported = %v, want %v (got %v)",
+					tt.code, got, tt.want, testutil.DiagCodes(diags))
+			}
+		})
+	}
+}
+
+func TestFeatureEvidence_Details(t *testing.T) {
+	diags := validateEvidence(t, "alternativeText", `<img src="a.png"/><p><img src="b.png"/></p>`, "")
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, "2 images have no alt") {
+		t.Fatalf("expected one diagnostic counting 2 images, got %+v", diags)
+	}
+	// Reported at the meta declaring the feature, at the configured severity
+	if diags[0].Range.Start.Line != 6 || diags[0].Severity != epub.SeverityError {
+		t.Errorf("diagnostic at line %d with severity %d, want line 6 and error",
+			diags[0].Range.Start.Line, diags[0].Severity)
+	}
+
+	// The font size heuristic stays informational
+	fixedSizes := strings.Repeat("p { font-size: 12px }\n", 11)
+	diags = validateEvidence(t, "displayTransformability", `<p>Text</p>`, fixedSizes)
+	if len(diags) != 1 || diags[0].Severity != epub.SeverityInfo {
+		t.Errorf("expected one info diagnostic, got %+v", diags)
+	}
+}
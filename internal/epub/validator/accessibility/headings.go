@@ -0,0 +1,134 @@
+package accessibility
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/stats"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// noHeadingsMinWords is the least text a bodymatter document without
+// headings must hold to be reported, so image-only pages are not.
+const noHeadingsMinWords = 500
+
+// nonBodymatterTypes lists the epub:type values that mark a document, or
+// its top-level section, as front or back matter.
+var nonBodymatterTypes = map[string]bool{
+	"frontmatter":     true,
+	"backmatter":      true,
+	"cover":           true,
+	"titlepage":       true,
+	"halftitlepage":   true,
+	"copyright-page":  true,
+	"dedication":      true,
+	"epigraph":        true,
+	"toc":             true,
+	"landmarks":       true,
+	"colophon":        true,
+	"acknowledgments": true,
+	"imprint":         true,
+	"index":           true,
+	"glossary":        true,
+	"bibliography":    true,
+	"endnotes":        true,
+}
+
+// checkHeadingPresence reports bodymatter documents with much text and no
+// headings, a first heading below the highest level the document uses,
+// and, when ctx asks for it, more than one h1.
+func checkHeadingPresence(
+	uri string,
+	content []byte,
+	lines *epub.LineIndex,
+	root *parser.XMLNode,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	var headings []headingInfo
+	collectHeadings(root, &headings)
+
+	if len(headings) == 0 {
+		body := root.FindFirst("body")
+		if body == nil || !isBodymatter(uri, body, ctx) {
+			return nil
+		}
+		text, _ := stats.BodyText(content)
+		if words := stats.CountWords(text); words > noHeadingsMinWords {
+			return []epub.Diagnostic{epub.NewDiagAt(lines, int(body.Offset), source).
+				Code("no-headings").
+				Info("document has " + strconv.Itoa(words) + " words of text but no headings " +
+					"to navigate it by").
+				Build()}
+		}
+		return nil
+	}
+
+	var diags []epub.Diagnostic
+	top := headings[0].level
+	for _, h := range headings[1:] {
+		top = min(top, h.level)
+	}
+	if first := headings[0]; first.level != top {
+		diags = append(diags, epub.NewDiagAt(lines, int(first.offset), source).
+			Code("heading-first").
+			Warning("first heading is h"+strconv.Itoa(first.level)+
+				" but the document also uses h"+strconv.Itoa(top)).
+			Build())
+	}
+
+	if ctx != nil && ctx.MultipleH1 {
+		h1s := 0
+		for _, h := range headings {
+			if h.level != 1 {
+				continue
+			}
+			if h1s++; h1s > 1 {
+				diags = append(diags, epub.NewDiagAt(lines, int(h.offset), source).
+					Code("multiple-h1").
+					Warning("document has more than one h1").
+					Build())
+			}
+		}
+	}
+	return diags
+}
+
+// isBodymatter reports whether the document at uri is part of the main
+// text. The epub:type of its body or top-level sections decides when it
+// names bodymatter, front matter, or back matter; otherwise the document
+// is bodymatter when it is in the spine and is not the cover.
+func isBodymatter(uri string, body *parser.XMLNode, ctx *validator.WorkspaceContext) bool {
+	elements := []*parser.XMLNode{body}
+	for _, child := range body.Children {
+		if child.Local == "section" {
+			elements = append(elements, child)
+		}
+	}
+	for _, node := range elements {
+		for token := range strings.FieldsSeq(node.AttrNS(epub.NSEpub, "type")) {
+			if token == "bodymatter" {
+				return true
+			}
+			if nonBodymatterTypes[token] {
+				return false
+			}
+		}
+	}
+
+	if ctx == nil || ctx.Manifest == nil || ctx.FileTypes[uri] == epub.FileTypeNav {
+		return false
+	}
+	item := ctx.Manifest.ItemByPath(uriutil.Path(uri))
+	if item == nil {
+		return false
+	}
+	for _, itemref := range ctx.Manifest.Spine {
+		if itemref.IDRef == item.ID {
+			return !strings.Contains(strings.ToLower(item.ID+" "+item.Href), "cover")
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package accessibility
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// words returns a paragraph of n words.
+func words(n int) string {
+	return "<p>" + strings.TrimSpace(strings.Repeat("word ", n)) + "</p>"
+}
+
+// spineContext returns a context whose spine holds chapter.xhtml and
+// cover.xhtml.
+func spineContext() *validator.WorkspaceContext {
+	return &validator.WorkspaceContext{
+		Manifest: &validator.ManifestInfo{
+			Items: []validator.ManifestItem{
+				{ID: "cover", Href: "text/cover.xhtml", MediaType: "application/xhtml+xml"},
+				{ID: "ch1", Href: "text/chapter.xhtml", MediaType: "application/xhtml+xml"},
+			},
+			Spine: []validator.SpineItem{{IDRef: "cover"}, {IDRef: "ch1"}},
+		},
+		AccessibilitySeverity: epub.SeverityWarning,
+	}
+}
+
+func TestNoHeadings(t *testing.T) {
+	chapter := "file:///book/OEBPS/text/chapter.xhtml"
+	cover := "file:///book/OEBPS/text/cover.xhtml"
+	extra := "file:///book/OEBPS/text/extra.xhtml"
+	cases := []struct {
+		name string
+		uri  string
+		// bodyAttrs is added to the body start tag
+		bodyAttrs string
+		body      string
+		want      bool
+	}{
+		{"long spine chapter", chapter, "", words(600), true},
+		{"under the word threshold", chapter, "", words(400), false},
+		{"spine cover", cover, "", words(600), false},
+		{"not in spine", extra, "", words(600), false},
+		{
+			"explicit bodymatter outside spine",
+			extra,
+			` epub:type="bodymatter"`,
+			words(600),
+			true,
+		},
+		{
+			"backmatter section",
+			chapter,
+			"",
+			`<section epub:type="backmatter">` + words(600) + "</section>",
+			false,
+		},
+		{"has a heading", chapter, "", "<h2>Part</h2>" + words(600), false},
+	}
+
+	v := &StructureValidator{}
+	for _, tc := range cases {
+		content := testutil.XHTMLDocument{
+			HTMLAttrs: testutil.EPUBNamespace,
+			BodyAttrs: tc.bodyAttrs,
+			Body:      tc.body,
+		}.Bytes()
+		diags := v.Validate(tc.uri, content, spineContext())
+		if got := testutil.HasCode(diags, "no-headings"); got != tc.want {
+			t.Errorf("%s: no-headings = %v, want %v (%v)",
+				tc.name, got, tc.want, testutil.DiagCodes(diags))
+		}
+	}
+}
+
+func TestNoHeadings_Severity(t *testing.T) {
+	ctx := spineContext()
+	ctx.AccessibilitySeverity = epub.SeverityError
+	diags := (&StructureValidator{}).Validate(
+		"file:///book/OEBPS/text/chapter.xhtml",
+		testutil.XHTMLDocument{Body: words(600)}.Bytes(),
+		ctx,
+	)
+	for _, d := range diags {
+		if d.Code == "no-headings" && d.Severity != epub.SeverityInfo {
+			t.Errorf("no-headings severity = %v, want info", d.Severity)
+		}
+	}
+}
+
+func TestHeadingFirst(t *testing.T) {
+	v := &StructureValidator{}
+
+	content := testutil.XHTMLDocument{
+		Body: "<h3>Epigraph</h3><h2>Chapter</h2><h3>Part</h3>",
+	}.Bytes()
+	diags := v.Validate("chapter.xhtml", content, nil)
+	if !testutil.HasCode(diags, "heading-first") {
+		t.Errorf("expected heading-first, got %v", testutil.DiagCodes(diags))
+	}
+
+	content = testutil.XHTMLDocument{
+		Body: "<h2>Chapter</h2><h3>Part</h3><h2>Next</h2>",
+	}.Bytes()
+	diags = v.Validate("chapter.xhtml", content, nil)
+	if testutil.HasCode(diags, "heading-first") {
+		t.Error("unexpected heading-first when the first heading is the highest level")
+	}
+}
+
+func TestMultipleH1(t *testing.T) {
+	content := testutil.XHTMLDocument{
+		Body: "<h1>One</h1><h2>Part</h2><h1>Two</h1><h1>Three</h1>",
+	}.Bytes()
+	v := &StructureValidator{}
+
+	if diags := v.Validate("chapter.xhtml", content, nil); testutil.HasCode(diags, "multiple-h1") {
+		t.Error("multiple-h1 should be off by default")
+	}
+
+	diags := v.Validate("chapter.xhtml", content, &validator.WorkspaceContext{
+		AccessibilitySeverity: epub.SeverityWarning,
+		MultipleH1:            true,
+	})
+	count := 0
+	for _, d := range diags {
+		if d.Code == "multiple-h1" {
+			count++
+		}
+	}
+	if count != 2 {
+		t.Errorf("multiple-h1 reported %d times, want 2", count)
+	}
+}
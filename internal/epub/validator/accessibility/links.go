@@ -0,0 +1,96 @@
+package accessibility
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// interactiveElements lists the elements that may not nest inside a link
+// or button.
+var interactiveElements = map[string]bool{
+	"a": true, "button": true, "input": true, "select": true, "textarea": true,
+}
+
+// checkLinks checks that links have discernible text and real targets.
+func checkLinks(lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+
+	for _, a := range root.FindAll("a") {
+		if !a.HasAttr("href") {
+			continue
+		}
+
+		if href := strings.TrimSpace(a.Attr("href")); href == "" || href == "#" {
+			diags = append(diags, epub.NewDiagAt(lines, int(a.Offset), source).
+				Code("link-href-empty").
+				Warning("link href \""+href+"\" is a placeholder with no target").
+				Build())
+		}
+
+		if !hasLinkName(a) {
+			diags = append(diags, epub.NewDiagAt(lines, int(a.Offset), source).
+				Code("link-name").
+				Error("link has no discernible text (text content, aria-label, "+
+					"aria-labelledby, title, or image alt)").
+				Build())
+		}
+	}
+
+	return diags
+}
+
+// hasLinkName reports whether a link has an accessible name.
+func hasLinkName(a *parser.XMLNode) bool {
+	if a.Attr("aria-label") != "" || a.Attr("aria-labelledby") != "" ||
+		a.Attr("title") != "" {
+		return true
+	}
+	if strings.TrimSpace(a.Text()) != "" {
+		return true
+	}
+	for _, img := range a.FindAll("img") {
+		if strings.TrimSpace(img.Attr("alt")) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNestedInteractive checks that links and buttons contain no other
+// interactive controls.
+func checkNestedInteractive(
+	lines *epub.LineIndex,
+	root *parser.XMLNode,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+
+	for _, tagName := range []string{"a", "button"} {
+		for _, elem := range root.FindAll(tagName) {
+			nested := findInteractive(elem)
+			if nested == nil {
+				continue
+			}
+			diags = append(diags, epub.NewDiagAt(lines, int(elem.Offset), source).
+				Code("nested-interactive").
+				Warning("<"+nested.Local+"> is nested inside interactive <"+tagName+">").
+				Build())
+		}
+	}
+
+	return diags
+}
+
+// findInteractive returns the first interactive descendant of node, or nil.
+func findInteractive(node *parser.XMLNode) *parser.XMLNode {
+	for _, child := range node.Children {
+		if interactiveElements[child.Local] {
+			return child
+		}
+		if found := findInteractive(child); found != nil {
+			return found
+		}
+	}
+	return nil
+}
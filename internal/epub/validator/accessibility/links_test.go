@@ -0,0 +1,82 @@
+package accessibility
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestLinkName(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"text", `<a href="n.xhtml">Next</a>`, false},
+		{"nested text", `<a href="n.xhtml"><span><em>Next</em></span></a>`, false},
+		{"icon with alt", `<a href="n.xhtml"><img src="n.png" alt="Next"/></a>`, false},
+		{"aria-label", `<a href="n.xhtml" aria-label="Next"><img src="n.png"/></a>`,
+			false},
+		{"title", `<a href="n.xhtml" title="Next"></a>`, false},
+		{"icon without alt", `<a href="n.xhtml"><img src="n.png"/></a>`, true},
+		{"icon with empty alt", `<a href="n.xhtml"><img src="n.png" alt=" "/></a>`, true},
+		{"whitespace", `<a href="n.xhtml"> <span> </span> </a>`, true},
+		{"anchor without href", `<a id="start"></a>`, false},
+	}
+
+	for _, tt := range tests {
+		v := &StructureValidator{}
+		content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+		diags := v.Validate("chapter.xhtml", content, nil)
+
+		if got := testutil.HasCode(diags, "link-name"); got != tt.want {
+			t.Errorf("%s: link-name = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLinkHrefPlaceholder(t *testing.T) {
+	tests := []struct {
+		href string
+		want bool
+	}{
+		{`href=""`, true},
+		{`href="#"`, true},
+		{`href="#note1"`, false},
+		{`href="n.xhtml"`, false},
+	}
+
+	for _, tt := range tests {
+		v := &StructureValidator{}
+		content := testutil.XHTMLDocument{Body: `<a ` + tt.href + `>Link</a>`}.Bytes()
+		diags := v.Validate("chapter.xhtml", content, nil)
+
+		if got := testutil.HasCode(diags, "link-href-empty"); got != tt.want {
+			t.Errorf("%s: link-href-empty = %v, want %v", tt.href, got, tt.want)
+		}
+	}
+}
+
+func TestNestedInteractive(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"button in link", `<a href="n.xhtml">Next <button>Go</button></a>`, true},
+		{"input in button", `<button><span><input type="radio"/></span></button>`, true},
+		{"link in link", `<a href="a.xhtml"><span><a href="b">B</a></span></a>`, true},
+		{"plain link", `<a href="n.xhtml"><strong>Next</strong></a>`, false},
+		{"siblings", `<a href="n.xhtml">Next</a><button>Go</button>`, false},
+	}
+
+	for _, tt := range tests {
+		v := &StructureValidator{}
+		content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+		diags := v.Validate("chapter.xhtml", content, nil)
+
+		if got := testutil.HasCode(diags, "nested-interactive"); got != tt.want {
+			t.Errorf("%s: nested-interactive = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
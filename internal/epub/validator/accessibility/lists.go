@@ -0,0 +1,176 @@
+package accessibility
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// fakeListMinItems is the fewest consecutive paragraphs with list markers
+// taken for a list built from paragraphs.
+const fakeListMinItems = 3
+
+// listBullets are the characters that mark an item of a list typed into
+// paragraphs. All but "•" must be followed by a space, so that text such
+// as "-5 degrees" is not taken for a list item.
+var listBullets = []string{"•", "-", "–", "*"}
+
+// listChildren are the elements other than <li> allowed in <ol> and <ul>.
+var listChildren = map[string]bool{
+	"li":       true,
+	"script":   true,
+	"template": true,
+}
+
+// checkLists checks for lists typed into paragraphs, which assistive
+// technology cannot announce as lists, lists of a single item used for
+// indentation, and lists holding elements other than <li>.
+func checkLists(content []byte, lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+
+	diags = append(diags, checkFakeLists(content, lines, root)...)
+	walkElements(root, func(node, _ *parser.XMLNode) {
+		if node.Local != "ol" && node.Local != "ul" {
+			return
+		}
+
+		var items []*parser.XMLNode
+		for _, child := range node.Children {
+			if child.Local == "li" {
+				items = append(items, child)
+			}
+			if listChildren[child.Local] {
+				continue
+			}
+			diags = append(diags, epub.NewDiagAt(lines, int(child.Offset), source).
+				Code("list-structure").
+				Warning("<"+child.Local+"> inside <"+node.Local+">; lists may "+
+					"only contain <li> elements").
+				Build())
+		}
+
+		// A navigation list of one entry is still navigation
+		if len(items) == 1 && !insideNav(node) &&
+			items[0].FindFirst("ol") == nil && items[0].FindFirst("ul") == nil {
+			diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+				Code("single-item-list").
+				Info("<"+node.Local+"> has a single item; if it is only there for "+
+					"indentation, use CSS instead").
+				Build())
+		}
+	})
+
+	return diags
+}
+
+// insideNav reports whether node is within a <nav> element.
+func insideNav(node *parser.XMLNode) bool {
+	for p := node.Parent; p != nil; p = p.Parent {
+		if p.Local == "nav" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFakeLists reports runs of consecutive sibling paragraphs that start
+// with the same bullet or with ascending numbers.
+func checkFakeLists(content []byte, lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+
+	report := func(run []*parser.XMLNode, marker string) {
+		if len(run) < fakeListMinItems {
+			return
+		}
+		list := "<ul>"
+		if marker == "" {
+			list, marker = "<ol>", "ascending numbers"
+		} else {
+			marker = "\"" + marker + "\""
+		}
+		diags = append(diags, epub.NewDiagAt(lines, int(run[0].Offset), source).
+			Code("fake-list").
+			Info(strconv.Itoa(len(run))+" consecutive paragraphs start with "+marker+
+				"; use "+list+" so they are announced as a list").
+			Build())
+	}
+
+	var visit func(node *parser.XMLNode)
+	visit = func(node *parser.XMLNode) {
+		var run []*parser.XMLNode
+		var bullet string
+		var number int
+		for _, child := range node.Children {
+			var b string
+			var n int
+			ok := false
+			if child.Local == "p" {
+				b, n, ok = listMarker(leadingText(content, child))
+			}
+			if !ok {
+				report(run, bullet)
+				run = nil
+				visit(child)
+				continue
+			}
+			if len(run) > 0 && (b != bullet || b == "" && n != number+1) {
+				report(run, bullet)
+				run = nil
+			}
+			run = append(run, child)
+			bullet, number = b, n
+		}
+		report(run, bullet)
+	}
+	visit(root)
+
+	return diags
+}
+
+// listMarker returns the bullet, or with an empty bullet the number, that
+// text starts with as a list item.
+func listMarker(text string) (bullet string, number int, ok bool) {
+	for _, b := range listBullets {
+		rest, found := strings.CutPrefix(text, b)
+		if !found {
+			continue
+		}
+		if b == "•" || rest != "" && unicode.IsSpace([]rune(rest)[0]) {
+			return b, 0, true
+		}
+		return "", 0, false
+	}
+
+	digits := strings.IndexFunc(text, func(r rune) bool { return r < '0' || r > '9' })
+	if digits <= 0 || text[digits] != '.' && text[digits] != ')' {
+		return "", 0, false
+	}
+	if rest := text[digits+1:]; rest != "" && !unicode.IsSpace([]rune(rest)[0]) {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(text[:digits])
+	return "", n, err == nil
+}
+
+// leadingText returns the start of node's text, in document order, with
+// markup removed and entities decoded.
+func leadingText(content []byte, node *parser.XMLNode) string {
+	start, end, _ := parser.ElementSpan(content, int(node.Offset))
+	var b strings.Builder
+	inTag := false
+	for _, c := range content[start:min(end, start+64)] {
+		switch {
+		case c == '<':
+			inTag = true
+		case c == '>':
+			inTag = false
+		case !inTag:
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimLeftFunc(html.UnescapeString(b.String()), unicode.IsSpace)
+}
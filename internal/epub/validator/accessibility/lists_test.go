@@ -0,0 +1,99 @@
+package accessibility
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestListChecks(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"bulleted paragraphs", `<p>• One</p><p>• Two</p><p>•Three</p>`,
+			[]string{"fake-list"}},
+		{"dashed paragraphs", `<p>- One</p><p>- Two</p><p>- Three</p><p>- Four</p>`,
+			[]string{"fake-list"}},
+		{"bullet entity in markup", `<p><span>&#8226;</span> One</p><p>&#8226; Two</p><p>&#8226; Three</p>`,
+			[]string{"fake-list"}},
+		{"numbered paragraphs", `<p>1. One</p><p><b>2.</b> Two</p><p>3) Three</p>`,
+			[]string{"fake-list"}},
+		{"two bulleted paragraphs", `<p>• One</p><p>• Two</p>`, nil},
+		{"mixed bullets", `<p>• One</p><p>- Two</p><p>• Three</p>`, nil},
+		{"numbers out of order", `<p>1. One</p><p>3. Three</p><p>2. Two</p>`, nil},
+		{"interrupted by a heading", `<p>* One</p><p>* Two</p><h2>Next</h2><p>* Three</p>`, nil},
+		{"dash without space", `<p>-5 degrees</p><p>-6 degrees</p><p>-7 degrees</p>`, nil},
+		{"year-like numbers", `<p>1999.</p>`, nil},
+		{"real list", `<ul><li>One</li><li>Two</li></ul>`, nil},
+		{"single item list", `<ul><li>Indented</li></ul>`, []string{"single-item-list"}},
+		{"single item with nested list", `<ol><li>Part<ol><li>a</li><li>b</li></ol></li></ol>`, nil},
+		{"single entry in nav", `<nav><ol><li><a href="c1.xhtml">One</a></li></ol></nav>`, nil},
+		{"div in list", `<ul><li>One</li><div>Two</div><li>Three</li></ul>`,
+			[]string{"list-structure"}},
+		{"script and template in list", `<ol><li>1</li><script>x()</script><template><li>t</li></template><li>2</li></ol>`,
+			nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &StructureValidator{}
+			content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+			diags := v.Validate("chapter.xhtml", content, nil)
+			var got []string
+			for _, d := range diags {
+				if d.Code == "fake-list" || d.Code == "single-item-list" || d.Code == "list-structure" {
+					got = append(got, d.Code)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFakeListMessage(t *testing.T) {
+	content := testutil.XHTMLDocument{
+		Body: `<p>Intro</p><p>1. One</p><p>2. Two</p><p>3. Three</p>`,
+	}.Bytes()
+	diags := (&StructureValidator{}).Validate("chapter.xhtml", content, nil)
+
+	for _, d := range diags {
+		if d.Code != "fake-list" {
+			continue
+		}
+		if want := "3 consecutive paragraphs start with ascending numbers; use <ol> so they are announced as a list"; d.Message != want {
+			t.Errorf("message = %q, want %q", d.Message, want)
+		}
+		// Reported at the first numbered paragraph
+		if start := epub.ByteOffsetToPosition(content, bytes.Index(content, []byte("<p>1."))); d.Range.Start != start {
+			t.Errorf("start = %+v, want %+v", d.Range.Start, start)
+		}
+		return
+	}
+	t.Fatal("expected fake-list")
+}
+
+func TestListSeverity(t *testing.T) {
+	ctx := &validator.WorkspaceContext{AccessibilitySeverity: epub.SeverityError}
+	content := testutil.XHTMLDocument{
+		Body: `<ul><li>One</li></ul><ol><li>a</li><p>b</p></ol>`,
+	}.Bytes()
+	diags := (&StructureValidator{}).Validate("chapter.xhtml", content, ctx)
+
+	want := map[string]int{
+		"single-item-list": epub.SeverityInfo,
+		"list-structure":   epub.SeverityError,
+	}
+	for _, d := range diags {
+		if s, ok := want[d.Code]; ok && d.Severity != s {
+			t.Errorf("%s: severity %d, want %d", d.Code, d.Severity, s)
+		}
+	}
+}
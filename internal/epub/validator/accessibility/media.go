@@ -0,0 +1,100 @@
+package accessibility
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// checkMedia checks that video has captions, that audio and video can be
+// operated, and that audio has a transcript.
+func checkMedia(lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+
+	controlled := make(map[string]bool)
+	walkElements(root, func(node, _ *parser.XMLNode) {
+		for id := range strings.FieldsSeq(node.Attr("aria-controls")) {
+			controlled[id] = true
+		}
+	})
+
+	walkElements(root, func(node, parent *parser.XMLNode) {
+		if node.Local != "audio" && node.Local != "video" {
+			return
+		}
+
+		if node.Local == "video" && !hasCaptionTrack(node) {
+			diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+				Code("media-captions").
+				Warning(`<video> has no <track kind="captions"> or kind="subtitles"`).
+				Build())
+		}
+
+		if !node.HasAttr("controls") && !controlled[node.Attr("id")] {
+			diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+				Code("media-controls").
+				Warning("<"+node.Local+"> has no controls attribute or "+
+					"element with aria-controls pointing at it").
+				Fix("Add controls attribute",
+					epub.AnchorEndOfStartTag, ` controls="controls"`).
+				Build())
+		}
+
+		if node.Local == "audio" && !hasTranscript(node, parent) {
+			diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+				Code("media-transcript").
+				Info("<audio> has no adjacent transcript link or aria-describedby").
+				Build())
+		}
+	})
+
+	return diags
+}
+
+// hasCaptionTrack reports whether a video has a captions or subtitles
+// track.
+func hasCaptionTrack(video *parser.XMLNode) bool {
+	for _, track := range video.FindAll("track") {
+		switch strings.ToLower(strings.TrimSpace(track.Attr("kind"))) {
+		case "captions", "subtitles":
+			return true
+		}
+	}
+	return false
+}
+
+// hasTranscript reports whether media is described by another element or
+// has a transcript link beside it.
+func hasTranscript(media, parent *parser.XMLNode) bool {
+	return media.Attr("aria-describedby") != "" || hasTranscriptLink(parent)
+}
+
+// hasTranscriptLink reports whether the parent of a media element, and so
+// the media's siblings, contains a link whose text mentions a transcript.
+func hasTranscriptLink(parent *parser.XMLNode) bool {
+	if parent == nil {
+		return false
+	}
+	for _, a := range parent.FindAll("a") {
+		if isTranscriptLink(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTranscriptLink reports whether a link's text or label mentions a
+// transcript.
+func isTranscriptLink(a *parser.XMLNode) bool {
+	text := a.Text() + " " + a.Attr("aria-label") + " " + a.Attr("title")
+	return strings.Contains(strings.ToLower(text), "transcript")
+}
+
+// walkElements calls fn for every element below node with its parent.
+func walkElements(node *parser.XMLNode, fn func(node, parent *parser.XMLNode)) {
+	for _, child := range node.Children {
+		fn(child, node)
+		walkElements(child, fn)
+	}
+}
@@ -0,0 +1,129 @@
+package accessibility
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestMediaChecks(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			"video with captions and controls",
+			`<video src="v.mp4" controls="controls">` +
+				`<track kind="captions" src="v.vtt" srclang="en"/></video>`,
+			nil,
+		},
+		{
+			"video with subtitles",
+			`<video src="v.mp4" controls="controls">` +
+				`<track kind="Subtitles" src="v.vtt"/></video>`,
+			nil,
+		},
+		{
+			"video without tracks",
+			`<video src="v.mp4" controls="controls"/>`,
+			[]string{"media-captions"},
+		},
+		{
+			"video with descriptions only",
+			`<video src="v.mp4" controls="controls">` +
+				`<track kind="descriptions" src="d.vtt"/></video>`,
+			[]string{"media-captions"},
+		},
+		{
+			"video without controls",
+			`<video src="v.mp4"><track kind="captions" src="v.vtt"/></video>`,
+			[]string{"media-controls"},
+		},
+		{
+			"video with custom controls",
+			`<video id="v1" src="v.mp4"><track kind="captions" src="v.vtt"/></video>` +
+				`<button aria-controls="v1">Play</button>`,
+			nil,
+		},
+		{
+			"audio with transcript link",
+			`<div><audio src="a.mp3" controls="controls"/>` +
+				`<p><a href="a.xhtml">Read the Transcript</a></p></div>`,
+			nil,
+		},
+		{
+			"audio with aria-describedby",
+			`<audio src="a.mp3" controls="controls" aria-describedby="t1"/>` +
+				`<p id="t1">Spoken words.</p>`,
+			nil,
+		},
+		{
+			"audio without transcript",
+			`<div><audio src="a.mp3" controls="controls"/>` +
+				`<a href="b.xhtml">Next</a></div>`,
+			[]string{"media-transcript"},
+		},
+		{
+			"bare audio",
+			`<audio src="a.mp3"/>`,
+			[]string{"media-controls", "media-transcript"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &StructureValidator{}
+			content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+			diags := v.Validate("chapter.xhtml", content, nil)
+
+			var got []string
+			for _, d := range diags {
+				got = append(got, d.Code)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMediaTranscriptStaysInfo(t *testing.T) {
+	ctx := &validator.WorkspaceContext{AccessibilitySeverity: epub.SeverityError}
+	content := testutil.XHTMLDocument{Body: `<audio src="a.mp3"/>`}.Bytes()
+	diags := (&StructureValidator{}).Validate("chapter.xhtml", content, ctx)
+
+	for _, d := range diags {
+		want := epub.SeverityError
+		if d.Code == "media-transcript" {
+			want = epub.SeverityInfo
+		}
+		if d.Severity != want {
+			t.Errorf("%s: severity %d, want %d", d.Code, d.Severity, want)
+		}
+	}
+	testutil.ExpectCode(t, testutil.DiagCodes(diags), "media-transcript")
+}
+
+func TestMediaControlsFix(t *testing.T) {
+	content := testutil.XHTMLDocument{
+		Body: `<audio src="a.mp3" aria-describedby="t"/>`,
+	}.Bytes()
+	diags := (&StructureValidator{}).Validate("chapter.xhtml", content, nil)
+
+	if len(diags) != 1 || diags[0].Code != "media-controls" {
+		t.Fatalf("expected one media-controls diagnostic, got %v", diags)
+	}
+	fix := diags[0].Fix
+	if fix == nil || fix.Anchor != epub.AnchorEndOfStartTag ||
+		fix.InsertText != ` controls="controls"` {
+		t.Errorf("unexpected fix %+v", fix)
+	}
+}
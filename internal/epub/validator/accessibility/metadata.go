@@ -14,15 +14,15 @@ import (
 
 const source = "epub-accessibility"
 
-// validAccessModes lists the valid schema:accessMode values.
-var validAccessModes = []string{
+// AccessModes lists the valid schema:accessMode values.
+var AccessModes = []string{
 	"auditory", "chartOnVisual", "chemOnVisual", "colorDependent",
 	"diagramOnVisual", "mathOnVisual", "musicOnVisual", "tactile",
 	"textOnVisual", "textual", "visual",
 }
 
-// validAccessibilityFeatures lists the valid schema:accessibilityFeature values.
-var validAccessibilityFeatures = []string{
+// AccessibilityFeatures lists the valid schema:accessibilityFeature values.
+var AccessibilityFeatures = []string{
 	"alternativeText", "annotations", "audioDescription", "bookmarks",
 	"braille", "captions", "ChemML", "describedMath", "displayTransformability",
 	"displayTransformability/font-size", "displayTransformability/font-family",
@@ -39,8 +39,8 @@ var validAccessibilityFeatures = []string{
 	"pageBreakMarkers", "pageNavigation",
 }
 
-// validAccessibilityHazards lists the valid schema:accessibilityHazard values.
-var validAccessibilityHazards = []string{
+// AccessibilityHazards lists the valid schema:accessibilityHazard values.
+var AccessibilityHazards = []string{
 	"flashing", "noFlashingHazard",
 	"motionSimulation", "noMotionSimulationHazard",
 	"sound", "noSoundHazard",
@@ -54,6 +54,9 @@ var hazardContradictions = map[string]string{
 	"noSoundHazard":            "sound",
 }
 
+// defaultSummary is the schema:accessibilitySummary inserted by fixes.
+const defaultSummary = "This publication meets WCAG 2.0 Level AA."
+
 // MetadataValidator checks OPF accessibility metadata.
 type MetadataValidator struct{}
 
@@ -112,7 +115,10 @@ func validateAccessibilityMetadata(
 		switch property {
 		case "schema:accessMode":
 			accessModes = append(accessModes, value)
-			if !slices.Contains(validAccessModes, value) {
+			if value == "" {
+				diags = append(diags, emptyValueDiag(content, child,
+					"metadata-accessmode-invalid", "textual"))
+			} else if !slices.Contains(AccessModes, value) {
 				diags = append(diags, epub.NewDiag(content, int(child.Offset), source).
 					Code("metadata-accessmode-invalid").
 					Error("invalid access mode value: \""+value+"\"").Build())
@@ -120,10 +126,16 @@ func validateAccessibilityMetadata(
 
 		case "schema:accessModeSufficient":
 			accessModeSufficient = append(accessModeSufficient, value)
+			if d, ok := checkAccessModeSufficient(content, child, value); ok {
+				diags = append(diags, d)
+			}
 
 		case "schema:accessibilityFeature":
 			features = append(features, value)
-			if !slices.Contains(validAccessibilityFeatures, value) {
+			if value == "" {
+				diags = append(diags, emptyValueDiag(content, child,
+					"metadata-accessibilityfeature-invalid", "structuralNavigation"))
+			} else if !slices.Contains(AccessibilityFeatures, value) {
 				diags = append(diags, epub.NewDiag(content, int(child.Offset), source).
 					Code("metadata-accessibilityfeature-invalid").
 					Error("invalid accessibility feature value: \""+value+"\"").Build())
@@ -131,7 +143,10 @@ func validateAccessibilityMetadata(
 
 		case "schema:accessibilityHazard":
 			hazards = append(hazards, value)
-			if !slices.Contains(validAccessibilityHazards, value) {
+			if value == "" {
+				diags = append(diags, emptyValueDiag(content, child,
+					"metadata-accessibilityhazard-invalid", "none"))
+			} else if !slices.Contains(AccessibilityHazards, value) {
 				diags = append(diags, epub.NewDiag(content, int(child.Offset), source).
 					Code("metadata-accessibilityhazard-invalid").
 					Error("invalid accessibility hazard value: \""+value+"\"").Build())
@@ -139,6 +154,10 @@ func validateAccessibilityMetadata(
 
 		case "schema:accessibilitySummary":
 			hasSummary = true
+			if value == "" {
+				diags = append(diags, emptyValueDiag(content, child,
+					"metadata-accessibilitysummary-invalid", defaultSummary))
+			}
 		}
 	}
 
@@ -150,6 +169,7 @@ func validateAccessibilityMetadata(
 			Message:  "missing schema:accessMode metadata",
 			Source:   source,
 			Range:    rng,
+			Fix:      missingMetadataFix("schema:accessMode", "textual"),
 		})
 	}
 
@@ -160,6 +180,7 @@ func validateAccessibilityMetadata(
 			Message:  "missing schema:accessibilityFeature metadata",
 			Source:   source,
 			Range:    rng,
+			Fix:      missingMetadataFix("schema:accessibilityFeature", "structuralNavigation"),
 		})
 	}
 
@@ -170,6 +191,7 @@ func validateAccessibilityMetadata(
 			Message:  "missing schema:accessibilityHazard metadata",
 			Source:   source,
 			Range:    rng,
+			Fix:      missingMetadataFix("schema:accessibilityHazard", "none"),
 		})
 	}
 
@@ -180,6 +202,7 @@ func validateAccessibilityMetadata(
 			Message:  "missing schema:accessibilitySummary metadata",
 			Source:   source,
 			Range:    rng,
+			Fix:      missingMetadataFix("schema:accessibilitySummary", defaultSummary),
 		})
 	}
 
@@ -190,6 +213,7 @@ func validateAccessibilityMetadata(
 			Message:  "missing schema:accessModeSufficient metadata",
 			Source:   source,
 			Range:    rng,
+			Fix:      missingMetadataFix("schema:accessModeSufficient", "textual"),
 		})
 	}
 
@@ -213,3 +237,67 @@ func validateAccessibilityMetadata(
 
 	return diags
 }
+
+// missingMetadataFix inserts a meta element for property before </metadata>.
+func missingMetadataFix(property, value string) *epub.FixData {
+	return &epub.FixData{
+		Title:      "Add " + property + " metadata",
+		Anchor:     epub.AnchorBeforeClosingTag + "metadata",
+		InsertText: `<meta property="` + property + `">` + value + `</meta>`,
+	}
+}
+
+// emptyValueDiag reports a meta element whose value is only whitespace, with
+// a fix filling in placeholder. The element exists, so the fix for missing
+// metadata does not apply.
+func emptyValueDiag(
+	content []byte,
+	meta *parser.XMLNode,
+	code, placeholder string,
+) epub.Diagnostic {
+	return metaValueDiag(content, meta, code,
+		"empty value for "+meta.Attr("property")).
+		Fix("Fill in value", epub.AnchorElementContent, placeholder).
+		Build()
+}
+
+// checkAccessModeSufficient reports a schema:accessModeSufficient value that
+// is empty or has an empty entry in its comma-separated list.
+func checkAccessModeSufficient(
+	content []byte,
+	meta *parser.XMLNode,
+	value string,
+) (epub.Diagnostic, bool) {
+	const code = "metadata-accessmodesufficient-invalid"
+	if value == "" {
+		return emptyValueDiag(content, meta, code, "textual"), true
+	}
+
+	var modes []string
+	for mode := range strings.SplitSeq(value, ",") {
+		if mode = strings.TrimSpace(mode); mode != "" {
+			modes = append(modes, mode)
+		}
+	}
+	if len(modes) == strings.Count(value, ",")+1 {
+		return epub.Diagnostic{}, false
+	}
+	return metaValueDiag(content, meta, code,
+		"empty entry in schema:accessModeSufficient value \""+value+"\"").
+		Fix("Remove empty entries", epub.AnchorElementContent,
+			strings.Join(modes, ",")).
+		Build(), true
+}
+
+// metaValueDiag starts an error diagnostic spanning a meta element.
+func metaValueDiag(
+	content []byte,
+	meta *parser.XMLNode,
+	code, msg string,
+) *epub.DiagBuilder {
+	_, _, end := parser.ElementSpan(content, int(meta.Offset))
+	return epub.NewDiag(content, int(meta.Offset), source).
+		End(epub.ByteOffsetToPosition(content, end)).
+		Code(code).
+		Error(msg)
+}
@@ -165,3 +165,43 @@ func TestMetadataValidator_InvalidHazard(t *testing.T) {
 		"metadata-accessibilityhazard-invalid",
 	)
 }
+
+func TestMetadataValidator_EmptyValues(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="schema:accessMode"> </meta>
+    <meta property="schema:accessModeSufficient">textual, ,visual</meta>
+    <meta property="schema:accessibilityFeature">structuralNavigation</meta>
+    <meta property="schema:accessibilityHazard">none</meta>
+    <meta property="schema:accessibilitySummary"></meta>
+  </metadata>
+  <manifest/>
+  <spine/>
+</package>`)
+
+	diags := (&MetadataValidator{}).Validate("package.opf", content, nil)
+
+	want := map[string]string{
+		"metadata-accessmode-invalid":           "textual",
+		"metadata-accessmodesufficient-invalid": "textual,visual",
+		"metadata-accessibilitysummary-invalid": defaultSummary,
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %v", len(want), diags)
+	}
+	for _, d := range diags {
+		text, ok := want[d.Code]
+		if !ok {
+			t.Errorf("unexpected diagnostic [%s] %s", d.Code, d.Message)
+			continue
+		}
+		if d.Fix == nil || d.Fix.Anchor != epub.AnchorElementContent ||
+			d.Fix.InsertText != text {
+			t.Errorf("%s: expected fix filling in %q, got %+v", d.Code, text, d.Fix)
+		}
+	}
+}
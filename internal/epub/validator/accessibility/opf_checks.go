@@ -1,7 +1,10 @@
 package accessibility
 
 import (
+	"strings"
+
 	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
 	"github.com/toba/epub-lsp/internal/epub/validator"
 	"github.com/toba/epub-lsp/internal/epub/validator/opf"
 )
@@ -56,11 +59,100 @@ func (v *OPFAccessibilityValidator) Validate(
 		})
 	}
 
+	if ctx != nil && ctx.Files != nil {
+		diags = append(diags, checkMediaFeatures(content, metadata, ctx)...)
+		diags = append(diags, checkFeatureEvidence(content, metadata, ctx)...)
+	}
+
+	// Heuristic checks stay informational whatever the configured severity
 	if ctx != nil && ctx.AccessibilitySeverity != 0 {
 		for i := range diags {
-			diags[i].Severity = ctx.AccessibilitySeverity
+			if diags[i].Severity != epub.SeverityInfo {
+				diags[i].Severity = ctx.AccessibilitySeverity
+			}
 		}
 	}
 
 	return diags
 }
+
+// mediaFeatures lists the accessibility features that promise media
+// alternatives, with the content that must back them.
+var mediaFeatures = []struct {
+	feature string
+	code    string
+	message string
+	match   func(media, parent *parser.XMLNode) bool
+}{
+	{
+		"captions", "captions-notracks",
+		"captions feature declared but no <video> has a captions or subtitles <track>",
+		func(media, _ *parser.XMLNode) bool { return hasCaptionTrack(media) },
+	},
+	{
+		"transcript", "transcript-nolinks",
+		"transcript feature declared but no media has a transcript link or " +
+			"aria-describedby",
+		hasTranscript,
+	},
+}
+
+// checkMediaFeatures checks that the captions and transcript accessibility
+// features are backed by caption tracks and transcripts in the content
+// documents.
+func checkMediaFeatures(
+	content []byte,
+	metadata *parser.XMLNode,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+	for _, f := range mediaFeatures {
+		meta := featureMeta(metadata, f.feature)
+		if meta == nil || contentHasMedia(ctx, f.match) {
+			continue
+		}
+		diags = append(diags, epub.NewDiag(content, int(meta.Offset), source).
+			Code(f.code).Warning(f.message).Build())
+	}
+	return diags
+}
+
+// featureMeta returns the meta element declaring an accessibility feature,
+// or nil.
+func featureMeta(metadata *parser.XMLNode, feature string) *parser.XMLNode {
+	for _, meta := range metadata.FindAll("meta") {
+		if meta.Attr("property") == "schema:accessibilityFeature" &&
+			strings.TrimSpace(meta.CharData) == feature {
+			return meta
+		}
+	}
+	return nil
+}
+
+// contentHasMedia reports whether any audio or video element in the
+// content documents of the workspace satisfies match.
+func contentHasMedia(
+	ctx *validator.WorkspaceContext,
+	match func(media, parent *parser.XMLNode) bool,
+) bool {
+	for uri, content := range ctx.Files {
+		ft := ctx.FileTypes[uri]
+		if ft != epub.FileTypeXHTML && ft != epub.FileTypeNav {
+			continue
+		}
+		root, diags := parser.Parse(content)
+		if len(diags) > 0 {
+			continue
+		}
+		found := false
+		walkElements(root, func(node, parent *parser.XMLNode) {
+			if !found && (node.Local == "audio" || node.Local == "video") {
+				found = match(node, parent)
+			}
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
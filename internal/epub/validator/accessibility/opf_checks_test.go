@@ -3,7 +3,9 @@ package accessibility
 import (
 	"testing"
 
+	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
 )
 
 func TestOPFAccessibility_MissingTitle(t *testing.T) {
@@ -62,3 +64,68 @@ func TestOPFAccessibility_Complete(t *testing.T) {
 		}
 	}
 }
+
+func TestOPFAccessibility_MediaFeatures(t *testing.T) {
+	opfContent := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123</dc:identifier>
+    <dc:title>Test Book</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="schema:accessibilityFeature">captions</meta>
+    <meta property="schema:accessibilityFeature">transcript</meta>
+  </metadata>
+  <manifest/>
+  <spine/>
+</package>`)
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"no media", `<p>Text</p>`, []string{"captions-notracks", "transcript-nolinks"}},
+		{
+			"captioned video",
+			`<video src="v.mp4"><track kind="captions" src="v.vtt"/></video>`,
+			[]string{"transcript-nolinks"},
+		},
+		{
+			"audio with transcript",
+			`<p><audio src="a.mp3"/> <a href="t.xhtml">Transcript</a></p>`,
+			[]string{"captions-notracks"},
+		},
+		{
+			"both",
+			`<video src="v.mp4" aria-describedby="d">` +
+				`<track kind="subtitles" src="v.vtt"/></video><p id="d">Desc</p>`,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &validator.WorkspaceContext{
+				Files: map[string][]byte{
+					"file:///book/OEBPS/package.opf": opfContent,
+					"file:///book/OEBPS/ch1.xhtml":   testutil.XHTMLDocument{Body: tt.body}.Bytes(),
+				},
+				FileTypes: map[string]epub.FileType{
+					"file:///book/OEBPS/package.opf": epub.FileTypeOPF,
+					"file:///book/OEBPS/ch1.xhtml":   epub.FileTypeXHTML,
+				},
+				AccessibilitySeverity: epub.SeverityWarning,
+			}
+			v := &OPFAccessibilityValidator{}
+			diags := v.Validate("file:///book/OEBPS/package.opf", opfContent, ctx)
+
+			codes := testutil.DiagCodes(diags)
+			if len(codes) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, codes)
+			}
+			for _, code := range tt.want {
+				testutil.ExpectCode(t, codes, code)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
 	"github.com/toba/epub-lsp/internal/epub/validator"
 	"github.com/toba/epub-lsp/internal/epub/validator/opf"
 )
@@ -182,7 +183,8 @@ func checkPageListReferences(
 				targetFile := parts[0]
 				targetID := parts[1]
 
-				if !idExistsInFile(targetFile, targetID, ctx) {
+				target := uriutil.ResolveRelative(uri, targetFile)
+				if !idExistsInFile(target, targetID, ctx) {
 					diags = append(diags, epub.Diagnostic{
 						Code:     "epub-pagelist-broken",
 						Severity: epub.SeverityError,
@@ -198,21 +200,18 @@ func checkPageListReferences(
 	return diags
 }
 
-// idExistsInFile checks if an element with the given id exists in a workspace file.
-func idExistsInFile(filename, id string, ctx *validator.WorkspaceContext) bool {
-	for uri, content := range ctx.Files {
-		if !strings.HasSuffix(uri, filename) {
-			continue
-		}
-		root, diags := parser.Parse(content)
-		if len(diags) > 0 {
-			continue
-		}
-		if findElementByID(root, id) {
-			return true
-		}
+// idExistsInFile checks if an element with the given id exists in the
+// workspace file at target.
+func idExistsInFile(target, id string, ctx *validator.WorkspaceContext) bool {
+	uri, ok := uriutil.Lookup(ctx.Files, target)
+	if !ok {
+		return false
 	}
-	return false
+	root, diags := parser.Parse(ctx.Files[uri])
+	if len(diags) > 0 {
+		return false
+	}
+	return findElementByID(root, id)
 }
 
 func findElementByID(node *parser.XMLNode, id string) bool {
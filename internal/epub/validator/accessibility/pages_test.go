@@ -216,6 +216,36 @@ func TestPageValidator_BrokenPageListRef(t *testing.T) {
 	testutil.ExpectCode(t, testutil.DiagCodes(diags), "epub-pagelist-broken")
 }
 
+func TestPageValidator_PageListRefSuffixFile(t *testing.T) {
+	opfContent := makeOPFWithFeature("printPageNumbers")
+
+	// notch1.xhtml has both ids, but the page list links to ch1.xhtml
+	ctx := &validator.WorkspaceContext{
+		Manifest: &validator.ManifestInfo{
+			Metadata: validator.MetadataInfo{
+				AccessibilityFeatures: []string{"printPageNumbers"},
+				HasDCSource:           true,
+			},
+		},
+		Files: map[string][]byte{
+			"file:///book/OEBPS/package.opf":  opfContent,
+			"file:///book/OEBPS/nav.xhtml":    navWithPageList(),
+			"file:///book/OEBPS/notch1.xhtml": contentWithPageBreaks(),
+		},
+		FileTypes: map[string]epub.FileType{
+			"file:///book/OEBPS/package.opf":  epub.FileTypeOPF,
+			"file:///book/OEBPS/nav.xhtml":    epub.FileTypeNav,
+			"file:///book/OEBPS/notch1.xhtml": epub.FileTypeXHTML,
+		},
+		AccessibilitySeverity: defaultSeverity,
+	}
+
+	v := &PageValidator{}
+	diags := v.Validate("file:///book/OEBPS/package.opf", opfContent, ctx)
+
+	testutil.ExpectCode(t, testutil.DiagCodes(diags), "epub-pagelist-broken")
+}
+
 func TestPageValidator_NilContext(t *testing.T) {
 	v := &PageValidator{}
 	diags := v.Validate("package.opf", []byte("<package/>"), nil)
@@ -0,0 +1,141 @@
+package accessibility
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// presentationalElements maps <b> and <i> to the code reported for them
+// and the semantic element usually intended.
+var presentationalElements = map[string]struct{ code, replacement string }{
+	"b": {"presentational-bold", "strong"},
+	"i": {"presentational-italic", "em"},
+}
+
+// fakeHeadingMaxLength is the most characters of text a styled paragraph
+// can hold and still be taken for a heading.
+const fakeHeadingMaxLength = 80
+
+// checkPresentational checks for <b>, <i>, and <u> used for meaning, and,
+// when enabled, for paragraphs styled to look like headings.
+func checkPresentational(
+	lines *epub.LineIndex,
+	root *parser.XMLNode,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+	fakeHeadings := ctx != nil && ctx.FakeHeadings
+
+	walkElements(root, func(node, _ *parser.XMLNode) {
+		switch node.Local {
+		case "b", "i":
+			if hasSemanticHint(node) {
+				return
+			}
+			element := presentationalElements[node.Local]
+			replacement := element.replacement
+			diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+				Code(element.code).
+				Info("<"+node.Local+"> only changes the look of text; use <"+
+					replacement+"> if it carries emphasis").
+				Fix("Change to <"+replacement+">", epub.AnchorRenameElement, replacement).
+				Build())
+		case "u":
+			diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+				Code("presentational-underline").
+				Info("<u> underlines text, which readers mistake for a link; "+
+					"use CSS or semantic markup").
+				Build())
+		case "p":
+			if fakeHeadings && isFakeHeading(node) {
+				diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+					Code("fake-heading").
+					Info("paragraph styled as a heading; use <h1>–<h6> so it "+
+						"appears in the document outline").
+					Build())
+			}
+		}
+	})
+
+	return diags
+}
+
+// hasSemanticHint reports whether a <b> or <i> is marked up with a
+// language, epub:type, or role, as for foreign phrases and terms, which
+// are legitimate uses.
+func hasSemanticHint(node *parser.XMLNode) bool {
+	return node.HasAttr("lang") || node.AttrNS(epub.NSXML, "lang") != "" ||
+		node.AttrNS(epub.NSEpub, "type") != "" || node.HasAttr("role")
+}
+
+// isFakeHeading reports whether a paragraph's style attribute makes its
+// short text large and bold, either directly or through a <b> or <strong>
+// holding all of it.
+func isFakeHeading(p *parser.XMLNode) bool {
+	text := strings.TrimSpace(p.Text())
+	if text == "" || utf8.RuneCountInString(text) > fakeHeadingMaxLength {
+		return false
+	}
+
+	large, bold := false, false
+	for _, decl := range parser.ParseDeclarations(p.Attr("style")) {
+		value := strings.ToLower(strings.TrimSpace(
+			strings.TrimSuffix(decl.Value, "!important")))
+		switch strings.ToLower(decl.Property) {
+		case "font-size":
+			large = isLargeFontSize(value)
+		case "font-weight":
+			bold = isBoldWeight(value)
+		}
+	}
+	if !large {
+		return false
+	}
+	if bold {
+		return true
+	}
+
+	// Bold markup around all of the text counts too
+	if strings.TrimSpace(p.CharData) != "" || len(p.Children) != 1 {
+		return false
+	}
+	child := p.Children[0].Local
+	return child == "b" || child == "strong"
+}
+
+// isLargeFontSize reports whether a font-size value is larger than about
+// 1.5em.
+func isLargeFontSize(value string) bool {
+	switch value {
+	case "x-large", "xx-large", "xxx-large":
+		return true
+	}
+
+	for _, unit := range []struct {
+		suffix string
+		limit  float64
+	}{
+		{"rem", 1.5}, {"em", 1.5}, {"%", 150}, {"px", 24}, {"pt", 18},
+	} {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			size, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			return err == nil && size > unit.limit
+		}
+	}
+	return false
+}
+
+// isBoldWeight reports whether a font-weight value is bold.
+func isBoldWeight(value string) bool {
+	switch value {
+	case "bold", "bolder":
+		return true
+	}
+	weight, err := strconv.Atoi(value)
+	return err == nil && weight >= 600
+}
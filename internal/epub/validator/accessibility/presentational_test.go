@@ -0,0 +1,130 @@
+package accessibility
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestPresentationalChecks(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"semantic emphasis", `<p><strong>Stop</strong> and <em>look</em>.</p>`, nil},
+		{"bold", `<p><b>Stop</b> here.</p>`, []string{"presentational-bold"}},
+		{"italic", `<p>It was <i>very</i> cold.</p>`, []string{"presentational-italic"}},
+		{"foreign phrase", `<p>A certain <i lang="fr">je ne sais quoi</i>.</p>`, nil},
+		{"xml:lang phrase", `<p><i xml:lang="la">Carpe diem</i></p>`, nil},
+		{
+			"term",
+			`<p><i epub:type="z3998:taxonomy" ` +
+				`xmlns:epub="http://www.idpf.org/2007/ops">Felis catus</i></p>`,
+			nil,
+		},
+		{"role", `<p><b role="doc-glossref">Ship</b></p>`, nil},
+		{"underline", `<p><u>Important</u></p>`, []string{"presentational-underline"}},
+		{
+			"fake heading off by default",
+			`<p style="font-size: 2em; font-weight: bold">Chapter One</p>`,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := (&StructureValidator{}).Validate("chapter.xhtml",
+				testutil.XHTMLDocument{Body: tt.body}.Bytes(), nil)
+			var got []string
+			for _, d := range diags {
+				got = append(got, d.Code)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPresentationalFix(t *testing.T) {
+	for local, want := range map[string]struct{ code, replacement string }{
+		"b": {"presentational-bold", "strong"},
+		"i": {"presentational-italic", "em"},
+	} {
+		body := `<p><` + local + `>Stop</` + local + `></p>`
+		diags := (&StructureValidator{}).Validate("chapter.xhtml",
+			testutil.XHTMLDocument{Body: body}.Bytes(), nil)
+		if len(diags) != 1 {
+			t.Fatalf("<%s>: expected 1 diagnostic, got %v", local, diags)
+		}
+		d := diags[0]
+		if d.Code != want.code {
+			t.Errorf("<%s>: expected %s, got %s", local, want.code, d.Code)
+		}
+		if d.Severity != epub.SeverityInfo {
+			t.Errorf("<%s>: expected info severity, got %d", local, d.Severity)
+		}
+		if d.Range.Start.Line != 4 || d.Range.Start.Character != len("<p>") {
+			t.Errorf("<%s>: expected diagnostic at the start tag, got %+v",
+				local, d.Range.Start)
+		}
+		if d.Fix == nil || d.Fix.Anchor != epub.AnchorRenameElement ||
+			d.Fix.InsertText != want.replacement {
+			t.Errorf("<%s>: expected a fix renaming to %s, got %+v",
+				local, want.replacement, d.Fix)
+		}
+	}
+}
+
+func TestFakeHeading(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"large and bold", `<p style="font-size: 2em; font-weight: bold">Chapter One</p>`,
+			true},
+		{"pixels and numeric weight",
+			`<p style="font-weight:700;font-size:32px">Chapter One</p>`, true},
+		{"keyword size", `<p style="font-size: xx-large; font-weight: bolder">Part I</p>`,
+			true},
+		{"bold child", `<p style="font-size: 200%"><b>Chapter One</b></p>`, true},
+		{"strong child", `<p style="font-size: 1.8rem"><strong>Chapter One</strong></p>`,
+			true},
+		{"large only", `<p style="font-size: 2em">Chapter One</p>`, false},
+		{"bold only", `<p style="font-weight: bold">Chapter One</p>`, false},
+		{"small", `<p style="font-size: 1.2em; font-weight: bold">Chapter One</p>`,
+			false},
+		{"bold child with other text",
+			`<p style="font-size: 2em"><b>Note:</b> keep dry.</p>`, false},
+		{"long text",
+			`<p style="font-size: 2em; font-weight: bold">` +
+				`This paragraph runs well past the length that anyone would ` +
+				`reasonably give to a heading</p>`, false},
+		{"empty", `<p style="font-size: 2em; font-weight: bold"> </p>`, false},
+	}
+
+	ctx := &validator.WorkspaceContext{
+		AccessibilitySeverity: epub.SeverityWarning,
+		FakeHeadings:          true,
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := (&StructureValidator{}).Validate("chapter.xhtml",
+				testutil.XHTMLDocument{Body: tt.body}.Bytes(), ctx)
+			i := slices.IndexFunc(diags, func(d epub.Diagnostic) bool {
+				return d.Code == "fake-heading"
+			})
+			if got := i >= 0; got != tt.want {
+				t.Fatalf("expected fake-heading %v, got %v", tt.want, diags)
+			}
+			if i >= 0 && diags[i].Severity != epub.SeverityInfo {
+				t.Errorf("expected info severity, got %d", diags[i].Severity)
+			}
+		})
+	}
+}
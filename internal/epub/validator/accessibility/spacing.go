@@ -0,0 +1,130 @@
+package accessibility
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// spacingLimit caps the empty-paragraph and br-spacing diagnostics reported
+// per file, since converted books can use hundreds of them for spacing.
+const spacingLimit = 20
+
+// CodeLimits implements validator.CodeLimiter.
+func (v *StructureValidator) CodeLimits() map[string]int {
+	return map[string]int{
+		"empty-paragraph": spacingLimit,
+		"br-spacing":      spacingLimit,
+	}
+}
+
+// checkSpacing checks for headings without text, and for empty paragraphs
+// and runs of <br/> used for vertical spacing instead of CSS.
+func checkSpacing(
+	content []byte,
+	lines *epub.LineIndex,
+	root *parser.XMLNode,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+
+	walkElements(root, func(node, _ *parser.XMLNode) {
+		if headingLevel(node.Local) > 0 && !hasHeadingText(node) {
+			diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+				Code("empty-heading").
+				Warning("<"+node.Local+"> has no text").
+				Build())
+		}
+
+		if node.Local == "p" && isEmptyParagraph(node) {
+			diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+				Code("empty-paragraph").
+				Info("empty <p> used for spacing; use CSS margins instead").
+				Build())
+			return
+		}
+
+		diags = append(diags, checkBreakRuns(content, lines, node)...)
+	})
+
+	return diags
+}
+
+// hasHeadingText reports whether a heading has text, an ARIA label, or an
+// image with alternative text.
+func hasHeadingText(heading *parser.XMLNode) bool {
+	if strings.TrimSpace(heading.Text()) != "" ||
+		heading.Attr("aria-label") != "" || heading.Attr("aria-labelledby") != "" {
+		return true
+	}
+	for _, img := range heading.FindAll("img") {
+		if strings.TrimSpace(img.Attr("alt")) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyParagraph reports whether a paragraph holds only whitespace,
+// non-breaking spaces, and <br/> elements.
+func isEmptyParagraph(p *parser.XMLNode) bool {
+	if strings.TrimSpace(p.CharData) != "" {
+		return false
+	}
+	for _, child := range p.Children {
+		if child.Local != "br" {
+			return false
+		}
+	}
+	return true
+}
+
+// checkBreakRuns reports each run of two or more <br/> children of node
+// separated only by whitespace, once at its first <br/>.
+func checkBreakRuns(
+	content []byte,
+	lines *epub.LineIndex,
+	node *parser.XMLNode,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+
+	report := func(run []*parser.XMLNode) {
+		if len(run) < 2 {
+			return
+		}
+		diags = append(diags, epub.NewDiagAt(lines, int(run[0].Offset), source).
+			Code("br-spacing").
+			Info(strconv.Itoa(len(run))+
+				" consecutive <br/> elements used for spacing; use CSS margins instead").
+			Build())
+	}
+
+	var run []*parser.XMLNode
+	for _, child := range node.Children {
+		if child.Local != "br" {
+			report(run)
+			run = nil
+			continue
+		}
+		if len(run) > 0 && !onlySpaceBetween(content, run[len(run)-1], child) {
+			report(run)
+			run = nil
+		}
+		run = append(run, child)
+	}
+	report(run)
+
+	return diags
+}
+
+// onlySpaceBetween reports whether only whitespace separates the end of
+// prev from the start of next.
+func onlySpaceBetween(content []byte, prev, next *parser.XMLNode) bool {
+	_, _, end := parser.ElementSpan(content, int(prev.Offset))
+	start := int(next.Offset)
+	if end > start {
+		return false
+	}
+	return strings.TrimSpace(string(content[end:start])) == ""
+}
@@ -0,0 +1,103 @@
+package accessibility
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestSpacingChecks(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"heading with text", `<h1>Title</h1>`, nil},
+		{"empty heading", `<h1> </h1>`, []string{"empty-heading"}},
+		{"heading with empty span", `<h2><span>&#160;</span></h2>`,
+			[]string{"empty-heading"}},
+		{"heading with aria-label", `<h2 aria-label="Part One"></h2>`, nil},
+		{"heading with image alt", `<h1><img src="t.png" alt="Title"/></h1>`, nil},
+		{"heading with empty image alt", `<h1><img src="t.png" alt=""/></h1>`,
+			[]string{"empty-heading"}},
+		{"paragraph with text", `<p>Text<br/><br/>more</p>`, []string{"br-spacing"}},
+		{"empty paragraph", `<p></p>`, []string{"empty-paragraph"}},
+		{"nbsp paragraph", `<p>&#160;</p>`, []string{"empty-paragraph"}},
+		{"paragraph with only breaks", `<p><br/> <br/></p>`, []string{"empty-paragraph"}},
+		{"paragraph with image", `<p><img src="a.png" alt="Arrow"/></p>`, nil},
+		{"single break", `<div>a<br/>b</div>`, nil},
+		{"consecutive breaks", `<div>a<br/>
+<br/><br></br>b</div>`, []string{"br-spacing"}},
+		{"breaks separated by text", `<div>a<br/>b<br/>c</div>`, nil},
+		{"two runs", `<div><br/><br/><span>x</span><br/><br/></div>`,
+			[]string{"br-spacing", "br-spacing"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &StructureValidator{}
+			content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+			diags := v.Validate("chapter.xhtml", content, nil)
+			var got []string
+			for _, d := range diags {
+				got = append(got, d.Code)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSpacingSeverity(t *testing.T) {
+	ctx := &validator.WorkspaceContext{AccessibilitySeverity: epub.SeverityError}
+	content := testutil.XHTMLDocument{Body: `<h1></h1><p></p><div><br/><br/></div>`}.Bytes()
+	diags := (&StructureValidator{}).Validate("chapter.xhtml", content, ctx)
+
+	want := map[string]int{
+		"empty-heading":   epub.SeverityError,
+		"empty-paragraph": epub.SeverityInfo,
+		"br-spacing":      epub.SeverityInfo,
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %v", len(want), diags)
+	}
+	for _, d := range diags {
+		if d.Severity != want[d.Code] {
+			t.Errorf("%s: severity %d, want %d", d.Code, d.Severity, want[d.Code])
+		}
+	}
+}
+
+func TestSpacingCapped(t *testing.T) {
+	r := validator.NewRegistry()
+	r.Register(&StructureValidator{})
+
+	body := strings.Repeat("<p>&#160;</p>\n", 200) + "<div><br/><br/></div>"
+	content := testutil.XHTMLDocument{Body: body}.Bytes()
+	diags := r.ValidateFile("chapter.xhtml", content, epub.FileTypeXHTML, nil)
+
+	var empty []epub.Diagnostic
+	for _, d := range diags {
+		if d.Code == "empty-paragraph" {
+			empty = append(empty, d)
+		}
+	}
+	if len(empty) != spacingLimit+1 {
+		t.Fatalf("expected %d empty-paragraph diagnostics, got %d",
+			spacingLimit+1, len(empty))
+	}
+	summary := empty[spacingLimit]
+	if !strings.HasPrefix(summary.Message, "and 180 more") {
+		t.Errorf("unexpected summary message %q", summary.Message)
+	}
+	if summary.Range.Start.Line != empty[spacingLimit-1].Range.Start.Line+1 {
+		t.Errorf("expected the summary at the 21st paragraph, got line %d",
+			summary.Range.Start.Line)
+	}
+	testutil.ExpectCode(t, testutil.DiagCodes(diags), "br-spacing")
+}
@@ -52,6 +52,13 @@ var epubTypeToRole = map[string]string{
 	"toc":             "doc-toc",
 }
 
+// RoleForEpubType returns the ARIA role that matches an epub:type value, or
+// false when the value has no role equivalent.
+func RoleForEpubType(epubType string) (string, bool) {
+	role, ok := epubTypeToRole[epubType]
+	return role, ok
+}
+
 // StructureValidator checks epub:type / ARIA role mapping and accessibility
 // rules in XHTML content documents.
 type StructureValidator struct{}
@@ -61,7 +68,7 @@ func (v *StructureValidator) FileTypes() []epub.FileType {
 }
 
 func (v *StructureValidator) Validate(
-	_ string,
+	uri string,
 	content []byte,
 	ctx *validator.WorkspaceContext,
 ) []epub.Diagnostic {
@@ -74,16 +81,30 @@ func (v *StructureValidator) Validate(
 		return nil
 	}
 
-	var diags []epub.Diagnostic //nolint:prealloc // size unknown
-	diags = append(diags, checkEpubTypeRoles(content, root)...)
-	diags = append(diags, checkPageBreakLabels(content, root)...)
-	diags = append(diags, checkHeadingLevels(content, root)...)
-	diags = append(diags, checkTableCaptions(content, root)...)
-	diags = append(diags, checkFormLabels(content, root)...)
+	lines := epub.NewLineIndex(content)
 
+	var diags []epub.Diagnostic //nolint:prealloc // size unknown
+	diags = append(diags, checkEpubTypeRoles(lines, root)...)
+	diags = append(diags, checkEpubTypeVocab(content, lines, root)...)
+	diags = append(diags, checkPageBreakLabels(lines, root)...)
+	diags = append(diags, checkHeadingLevels(lines, root)...)
+	diags = append(diags, checkHeadingPresence(uri, content, lines, root, ctx)...)
+	diags = append(diags, checkTableCaptions(lines, root)...)
+	diags = append(diags, checkFormLabels(lines, root)...)
+	diags = append(diags, checkLinks(lines, root)...)
+	diags = append(diags, checkNestedInteractive(lines, root)...)
+	diags = append(diags, checkMedia(lines, root)...)
+	diags = append(diags, checkSpacing(content, lines, root)...)
+	diags = append(diags, checkLists(content, lines, root)...)
+	diags = append(diags, checkAltText(lines, root, redundantAltPhrases(ctx))...)
+	diags = append(diags, checkPresentational(lines, root, ctx)...)
+
+	// Heuristic checks stay informational whatever the configured severity
 	if ctx != nil && ctx.AccessibilitySeverity != 0 {
 		for i := range diags {
-			diags[i].Severity = ctx.AccessibilitySeverity
+			if diags[i].Severity != epub.SeverityInfo {
+				diags[i].Severity = ctx.AccessibilitySeverity
+			}
 		}
 	}
 
@@ -91,7 +112,7 @@ func (v *StructureValidator) Validate(
 }
 
 // checkEpubTypeRoles checks that elements with epub:type have a matching ARIA role.
-func checkEpubTypeRoles(content []byte, root *parser.XMLNode) []epub.Diagnostic {
+func checkEpubTypeRoles(lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
 	var diags []epub.Diagnostic
 	walkEpubTypes(root, func(node *parser.XMLNode, epubType string) {
 		for token := range strings.FieldsSeq(epubType) {
@@ -101,9 +122,11 @@ func checkEpubTypeRoles(content []byte, root *parser.XMLNode) []epub.Diagnostic
 			}
 			actualRole := node.Attr("role")
 			if actualRole == "" || !epub.ContainsToken(actualRole, expectedRole) {
-				diags = append(diags, epub.NewDiag(content, int(node.Offset), source).
+				diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
 					Code("epub-type-has-matching-role").
 					Warning("epub:type=\""+token+"\" should have role=\""+expectedRole+"\"").
+					Fix("Add role=\""+expectedRole+"\" attribute",
+						epub.AnchorEndOfStartTag, ` role="`+expectedRole+`"`).
 					Build())
 			}
 		}
@@ -112,7 +135,7 @@ func checkEpubTypeRoles(content []byte, root *parser.XMLNode) []epub.Diagnostic
 }
 
 // checkPageBreakLabels checks that pagebreak elements have accessible labels.
-func checkPageBreakLabels(content []byte, root *parser.XMLNode) []epub.Diagnostic {
+func checkPageBreakLabels(lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
 	var diags []epub.Diagnostic
 	walkEpubTypes(root, func(node *parser.XMLNode, epubType string) {
 		if !epub.ContainsToken(epubType, "pagebreak") {
@@ -124,7 +147,7 @@ func checkPageBreakLabels(content []byte, root *parser.XMLNode) []epub.Diagnosti
 		text := strings.TrimSpace(node.CharData)
 
 		if ariaLabel == "" && title == "" && text == "" {
-			diags = append(diags, epub.NewDiag(content, int(node.Offset), source).
+			diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
 				Code("pagebreak-label").
 				Warning("pagebreak element missing accessible label (aria-label, title, or text content)").
 				Build())
@@ -134,7 +157,7 @@ func checkPageBreakLabels(content []byte, root *parser.XMLNode) []epub.Diagnosti
 }
 
 // checkHeadingLevels checks that heading levels don't skip (e.g. h1 → h3).
-func checkHeadingLevels(content []byte, root *parser.XMLNode) []epub.Diagnostic {
+func checkHeadingLevels(lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
 	var diags []epub.Diagnostic
 	var headings []headingInfo
 
@@ -144,7 +167,7 @@ func checkHeadingLevels(content []byte, root *parser.XMLNode) []epub.Diagnostic
 		prev := headings[i-1]
 		curr := headings[i]
 		if curr.level > prev.level+1 {
-			diags = append(diags, epub.NewDiag(content, int(curr.offset), source).
+			diags = append(diags, epub.NewDiagAt(lines, int(curr.offset), source).
 				Code("heading-order").
 				Warning("heading level skipped from h"+strconv.Itoa(prev.level)+" to h"+strconv.Itoa(curr.level)).
 				Build())
@@ -187,7 +210,7 @@ func headingLevel(local string) int {
 }
 
 // checkTableCaptions checks that tables have a <caption> or aria-label.
-func checkTableCaptions(content []byte, root *parser.XMLNode) []epub.Diagnostic {
+func checkTableCaptions(lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
 	var diags []epub.Diagnostic
 
 	tables := root.FindAll("table")
@@ -197,7 +220,7 @@ func checkTableCaptions(content []byte, root *parser.XMLNode) []epub.Diagnostic
 		ariaLabelledBy := table.Attr("aria-labelledby")
 
 		if caption == nil && ariaLabel == "" && ariaLabelledBy == "" {
-			diags = append(diags, epub.NewDiag(content, int(table.Offset), source).
+			diags = append(diags, epub.NewDiagAt(lines, int(table.Offset), source).
 				Code("table-caption").
 				Warning("<table> missing <caption>, aria-label, or aria-labelledby").
 				Build())
@@ -208,7 +231,7 @@ func checkTableCaptions(content []byte, root *parser.XMLNode) []epub.Diagnostic
 }
 
 // checkFormLabels checks that form inputs have associated labels.
-func checkFormLabels(content []byte, root *parser.XMLNode) []epub.Diagnostic {
+func checkFormLabels(lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
 	var diags []epub.Diagnostic
 
 	// Collect all label for= values
@@ -230,7 +253,7 @@ func checkFormLabels(content []byte, root *parser.XMLNode) []epub.Diagnostic {
 		}
 
 		if !hasAssociatedLabel(input, labelFor) {
-			diags = append(diags, epub.NewDiag(content, int(input.Offset), source).
+			diags = append(diags, epub.NewDiagAt(lines, int(input.Offset), source).
 				Code("input-label").Warning("<input> missing associated label").Build())
 		}
 	}
@@ -239,7 +262,7 @@ func checkFormLabels(content []byte, root *parser.XMLNode) []epub.Diagnostic {
 	for _, tagName := range []string{"select", "textarea"} {
 		for _, elem := range root.FindAll(tagName) {
 			if !hasAssociatedLabel(elem, labelFor) {
-				diags = append(diags, epub.NewDiag(content, int(elem.Offset), source).
+				diags = append(diags, epub.NewDiagAt(lines, int(elem.Offset), source).
 					Code("input-label").
 					Warning("<"+tagName+"> missing associated label").Build())
 			}
@@ -0,0 +1,96 @@
+package accessibility
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// SVGValidator checks that SVG images, standalone or embedded in content
+// documents, have a text alternative.
+type SVGValidator struct{}
+
+func (v *SVGValidator) FileTypes() []epub.FileType {
+	return []epub.FileType{epub.FileTypeXHTML, epub.FileTypeNav, epub.FileTypeSVG}
+}
+
+func (v *SVGValidator) Validate(
+	_ string,
+	content []byte,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	if ctx != nil && ctx.AccessibilitySeverity == 0 {
+		return nil
+	}
+
+	root, xmlDiags := parser.Parse(content)
+	if len(xmlDiags) > 0 {
+		return nil
+	}
+
+	diags := checkSVGTitles(epub.NewLineIndex(content), root)
+	if ctx != nil {
+		for i := range diags {
+			diags[i].Severity = ctx.AccessibilitySeverity
+		}
+	}
+	return diags
+}
+
+// checkSVGTitles reports each outermost <svg> that has no <title> child,
+// aria-label, or aria-labelledby. Images hidden from assistive technology
+// or marked presentational are decorative and skipped.
+func checkSVGTitles(lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+	walkElements(root, func(node, parent *parser.XMLNode) {
+		if node.Local != "svg" || insideSVG(parent) || isDecorative(node) ||
+			hasSVGName(node) {
+			return
+		}
+		diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+			Code("svg-title").
+			Warning(`<svg> has no <title> or aria-label; add one, with role="img" `+
+				`when embedded, or aria-hidden="true" if it is decorative`).
+			Build())
+	})
+	return diags
+}
+
+// insideSVG reports whether node is an <svg> or lies within one.
+func insideSVG(node *parser.XMLNode) bool {
+	for ; node != nil; node = node.Parent {
+		if node.Local == "svg" {
+			return true
+		}
+	}
+	return false
+}
+
+// isDecorative reports whether an element is hidden from assistive
+// technology.
+func isDecorative(node *parser.XMLNode) bool {
+	if strings.TrimSpace(node.Attr("aria-hidden")) == "true" {
+		return true
+	}
+	switch strings.TrimSpace(node.Attr("role")) {
+	case "presentation", "none":
+		return true
+	}
+	return false
+}
+
+// hasSVGName reports whether an <svg> has an accessible name.
+func hasSVGName(svg *parser.XMLNode) bool {
+	if strings.TrimSpace(svg.Attr("aria-label")) != "" ||
+		strings.TrimSpace(svg.Attr("aria-labelledby")) != "" {
+		return true
+	}
+	for _, child := range svg.Children {
+		if child.Local == "title" && strings.TrimSpace(child.Text()) != "" {
+			return true
+		}
+	}
+	return false
+}
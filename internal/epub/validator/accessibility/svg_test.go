@@ -0,0 +1,97 @@
+package accessibility
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestSVGTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{
+			"embedded svg without title",
+			`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><circle r="5"/></svg>`,
+			1,
+		},
+		{
+			"embedded svg with title",
+			`<svg xmlns="http://www.w3.org/2000/svg" role="img"><title>A dot</title><circle r="5"/></svg>`,
+			0,
+		},
+		{
+			"empty title",
+			`<svg xmlns="http://www.w3.org/2000/svg"><title> </title></svg>`,
+			1,
+		},
+		{
+			"aria-label",
+			`<svg xmlns="http://www.w3.org/2000/svg" role="img" aria-label="A dot"/>`,
+			0,
+		},
+		{
+			"aria-labelledby",
+			`<p id="cap">A dot</p><svg xmlns="http://www.w3.org/2000/svg" aria-labelledby="cap"/>`,
+			0,
+		},
+		{
+			"decorative",
+			`<svg xmlns="http://www.w3.org/2000/svg" aria-hidden="true"><circle r="5"/></svg>`,
+			0,
+		},
+		{
+			"presentational",
+			`<svg xmlns="http://www.w3.org/2000/svg" role="presentation"/>`,
+			0,
+		},
+		{
+			"nested svg reported once",
+			`<svg xmlns="http://www.w3.org/2000/svg"><svg><circle r="5"/></svg></svg>`,
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+			diags := (&SVGValidator{}).Validate("chapter.xhtml", content, nil)
+			if len(diags) != tt.want {
+				t.Fatalf("expected %d diagnostics, got %v", tt.want, diags)
+			}
+			for _, d := range diags {
+				if d.Code != "svg-title" {
+					t.Errorf("unexpected code %q", d.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestSVGTitle_Standalone(t *testing.T) {
+	titled := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+  <title>A dot</title>
+  <circle r="5"/>
+</svg>`)
+	if diags := (&SVGValidator{}).Validate("image.svg", titled, nil); len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+
+	untitled := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+  <circle r="5"/>
+</svg>`)
+	ctx := &validator.WorkspaceContext{AccessibilitySeverity: epub.SeverityError}
+	diags := (&SVGValidator{}).Validate("image.svg", untitled, ctx)
+	if len(diags) != 1 {
+		t.Fatalf("expected one diagnostic, got %v", diags)
+	}
+	if diags[0].Severity != epub.SeverityError || diags[0].Range.Start.Line != 1 {
+		t.Errorf("unexpected diagnostic %+v", diags[0])
+	}
+}
@@ -0,0 +1,277 @@
+package accessibility
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator/opf"
+)
+
+// structuralSemantics is the EPUB Structural Semantics Vocabulary, the
+// unprefixed terms allowed in epub:type. Deprecated terms are included,
+// since they are still valid.
+var structuralSemantics = map[string]bool{
+	// Partitions and divisions
+	"cover":       true,
+	"frontmatter": true,
+	"bodymatter":  true,
+	"backmatter":  true,
+	"volume":      true,
+	"part":        true,
+	"chapter":     true,
+	"subchapter":  true,
+	"division":    true,
+	// Sections and components
+	"abstract":     true,
+	"foreword":     true,
+	"preface":      true,
+	"prologue":     true,
+	"introduction": true,
+	"preamble":     true,
+	"conclusion":   true,
+	"epilogue":     true,
+	"afterword":    true,
+	"epigraph":     true,
+	// Navigation
+	"toc":       true,
+	"toc-brief": true,
+	"landmarks": true,
+	"loa":       true,
+	"loi":       true,
+	"lot":       true,
+	"lov":       true,
+	// Reference sections
+	"appendix":              true,
+	"colophon":              true,
+	"credits":               true,
+	"keywords":              true,
+	"index":                 true,
+	"index-headnotes":       true,
+	"index-legend":          true,
+	"index-group":           true,
+	"index-entry-list":      true,
+	"index-entry":           true,
+	"index-term":            true,
+	"index-editor-note":     true,
+	"index-locator":         true,
+	"index-locator-list":    true,
+	"index-locator-range":   true,
+	"index-xref-preferred":  true,
+	"index-xref-related":    true,
+	"index-term-category":   true,
+	"index-term-categories": true,
+	"glossary":              true,
+	"glossterm":             true,
+	"glossdef":              true,
+	"bibliography":          true,
+	"biblioentry":           true,
+	// Preliminary sections
+	"titlepage":        true,
+	"halftitlepage":    true,
+	"copyright-page":   true,
+	"seriespage":       true,
+	"acknowledgments":  true,
+	"imprint":          true,
+	"imprimatur":       true,
+	"contributors":     true,
+	"other-credits":    true,
+	"errata":           true,
+	"dedication":       true,
+	"revision-history": true,
+	// Complementary content
+	"case-study": true,
+	"help":       true,
+	"marginalia": true,
+	"notice":     true,
+	"pullquote":  true,
+	"sidebar":    true,
+	"tip":        true,
+	"warning":    true,
+	// Titles and headings
+	"halftitle":  true,
+	"fulltitle":  true,
+	"covertitle": true,
+	"title":      true,
+	"subtitle":   true,
+	"label":      true,
+	"ordinal":    true,
+	"bridgehead": true,
+	// Educational content
+	"learning-objective":        true,
+	"learning-objectives":       true,
+	"learning-outcome":          true,
+	"learning-outcomes":         true,
+	"learning-resource":         true,
+	"learning-resources":        true,
+	"learning-standard":         true,
+	"learning-standards":        true,
+	"answer":                    true,
+	"answers":                   true,
+	"assessment":                true,
+	"assessments":               true,
+	"feedback":                  true,
+	"fill-in-the-blank-problem": true,
+	"general-problem":           true,
+	"qna":                       true,
+	"match-problem":             true,
+	"multiple-choice-problem":   true,
+	"practice":                  true,
+	"practices":                 true,
+	"question":                  true,
+	"true-false-problem":        true,
+	// Comics
+	"panel":       true,
+	"panel-group": true,
+	"balloon":     true,
+	"text-area":   true,
+	"sound-area":  true,
+	// Notes and references
+	"annotation": true,
+	"note":       true,
+	"footnote":   true,
+	"endnote":    true,
+	"rearnote":   true,
+	"footnotes":  true,
+	"endnotes":   true,
+	"rearnotes":  true,
+	"annoref":    true,
+	"biblioref":  true,
+	"glossref":   true,
+	"noteref":    true,
+	"backlink":   true,
+	"referrer":   true,
+	// Document text
+	"credit":              true,
+	"keyword":             true,
+	"topic-sentence":      true,
+	"concluding-sentence": true,
+	"pagebreak":           true,
+	"page-list":           true,
+	// Tables, lists, figures, and asides
+	"table":      true,
+	"table-row":  true,
+	"table-cell": true,
+	"list":       true,
+	"list-item":  true,
+	"figure":     true,
+	"aside":      true,
+	// Dictionaries
+	"dictionary":             true,
+	"dictentry":              true,
+	"condensed-entry":        true,
+	"def":                    true,
+	"derivation":             true,
+	"etymology":              true,
+	"example":                true,
+	"gram-info":              true,
+	"idiom":                  true,
+	"part-of-speech":         true,
+	"part-of-speech-list":    true,
+	"part-of-speech-group":   true,
+	"phonetic-transcription": true,
+	"phrase-list":            true,
+	"phrase-group":           true,
+	"sense-list":             true,
+	"sense-group":            true,
+	"tran":                   true,
+	"tran-info":              true,
+}
+
+// contentReservedPrefixes are the epub:type prefixes that content
+// documents may use without declaring them in epub:prefix. z3998 was
+// reserved in EPUB 3.0 and is still widely used undeclared.
+var contentReservedPrefixes = map[string]bool{
+	"msv":   true,
+	"prism": true,
+	"z3998": true,
+}
+
+// maxSuggestionDistance is the largest edit distance between an unknown
+// epub:type token and a vocabulary term for the term to be suggested.
+const maxSuggestionDistance = 2
+
+// checkEpubTypeVocab checks that every epub:type token is a term of the
+// structural semantics vocabulary or uses a declared prefix, suggesting the
+// closest term for likely typos.
+func checkEpubTypeVocab(
+	content []byte,
+	lines *epub.LineIndex,
+	root *parser.XMLNode,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+	walkEpubTypes(root, func(node *parser.XMLNode, epubType string) {
+		tokens := strings.Fields(epubType)
+		spans := parser.AttrTokenSpans(content, int(node.Offset), "epub:type")
+
+		for i, token := range tokens {
+			if prefix, _, ok := strings.Cut(token, ":"); ok {
+				if contentReservedPrefixes[prefix] || declaresPrefix(node, prefix) {
+					continue
+				}
+				diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+					Code("epub-type-unknown").
+					Warning("epub:type \""+token+"\" uses undeclared prefix \""+
+						prefix+"\"").
+					Build())
+				continue
+			}
+			if structuralSemantics[token] {
+				continue
+			}
+
+			msg := "epub:type \"" + token + "\" is not in the structural " +
+				"semantics vocabulary"
+			suggestion := suggestEpubType(token)
+			if suggestion != "" {
+				msg += "; did you mean \"" + suggestion + "\"?"
+			}
+
+			// The token's source text is only known when it matches the
+			// parsed value, i.e. without entity references
+			if len(spans) != len(tokens) ||
+				string(content[spans[i][0]:spans[i][1]]) != token {
+				diags = append(diags, epub.NewDiagAt(lines, int(node.Offset), source).
+					Code("epub-type-unknown").
+					Warning(msg).
+					Build())
+				continue
+			}
+			b := epub.NewDiagAt(lines, spans[i][0], source).
+				End(lines.Position(spans[i][1])).
+				Code("epub-type-unknown").
+				Warning(msg)
+			if suggestion != "" {
+				b.Fix("Replace with \""+suggestion+"\"", epub.AnchorReplaceRange, suggestion)
+			}
+			diags = append(diags, b.Build())
+		}
+	})
+	return diags
+}
+
+// declaresPrefix reports whether node or an ancestor declares prefix in
+// its epub:prefix attribute.
+func declaresPrefix(node *parser.XMLNode, prefix string) bool {
+	for n := node; n != nil; n = n.Parent {
+		if _, ok := opf.ParsePrefixes(n.AttrNS(epub.NSEpub, "prefix"))[prefix]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestEpubType returns the vocabulary term closest to token within
+// maxSuggestionDistance edits, preferring the alphabetically first of
+// equally close terms, or "" if none is close enough.
+func suggestEpubType(token string) string {
+	best, bestDistance := "", maxSuggestionDistance+1
+	for _, term := range slices.Sorted(maps.Keys(structuralSemantics)) {
+		if d := epub.Levenshtein(token, term); d < bestDistance {
+			best, bestDistance = term, d
+		}
+	}
+	return best
+}
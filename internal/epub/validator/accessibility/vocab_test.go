@@ -0,0 +1,105 @@
+package accessibility
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestEpubTypeVocabulary(t *testing.T) {
+	tests := []struct {
+		name string
+		// attrs is added to the html start tag
+		attrs string
+		body  string
+		want  bool
+	}{
+		{"known", "", `<div epub:type="frontmatter titlepage"/>`, false},
+		{"unknown", "", `<div epub:type="wibble"/>`, true},
+		{"typo", "", `<div epub:type="front-matter"/>`, true},
+		{"reserved prefix", "", `<div epub:type="msv:issue"/>`, false},
+		{"undeclared prefix", "", `<div epub:type="se:name.person"/>`, true},
+		{
+			"declared prefix",
+			` epub:prefix="se: https://standardebooks.org/vocab/1.0"`,
+			`<div epub:type="se:name.person"/>`,
+			false,
+		},
+		{
+			"prefix declared on ancestor",
+			"",
+			`<div epub:prefix="se: https://standardebooks.org/vocab/1.0">` +
+				`<span epub:type="se:name.person"/></div>`,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testutil.XHTMLDocument{
+				HTMLAttrs: testutil.EPUBNamespace + tt.attrs,
+				Body:      tt.body,
+			}.Bytes()
+			diags := checkEpubTypeDiags(t, content)
+			if got := len(diags) > 0; got != tt.want {
+				t.Errorf("epub-type-unknown = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEpubTypeTypoFix(t *testing.T) {
+	content := testutil.XHTMLDocument{
+		HTMLAttrs: testutil.EPUBNamespace,
+		Body:      `<section epub:type="bodymatter chaper"><h1>One</h1></section>`,
+	}.Bytes()
+	diags := checkEpubTypeDiags(t, content)
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diags)
+	}
+
+	d := diags[0]
+	if want := `epub:type "chaper" is not in the structural semantics ` +
+		`vocabulary; did you mean "chapter"?`; d.Message != want {
+		t.Errorf("message %q, want %q", d.Message, want)
+	}
+	want := epub.Range{
+		Start: epub.Position{Line: 4, Character: 31},
+		End:   epub.Position{Line: 4, Character: 37},
+	}
+	if d.Range != want {
+		t.Errorf("expected range %+v, got %+v", want, d.Range)
+	}
+	if d.Fix == nil || d.Fix.Anchor != epub.AnchorReplaceRange ||
+		d.Fix.InsertText != "chapter" {
+		t.Errorf("expected a fix replacing the token with chapter, got %+v", d.Fix)
+	}
+}
+
+func TestEpubTypeUnknownWithoutSuggestion(t *testing.T) {
+	content := testutil.XHTMLDocument{
+		HTMLAttrs: testutil.EPUBNamespace,
+		Body:      `<div epub:type="wibble"/>`,
+	}.Bytes()
+	diags := checkEpubTypeDiags(t, content)
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diags)
+	}
+	if diags[0].Fix != nil {
+		t.Errorf("expected no fix without a close term, got %+v", diags[0].Fix)
+	}
+}
+
+// checkEpubTypeDiags returns the epub-type-unknown diagnostics the
+// structure validator reports for content.
+func checkEpubTypeDiags(t *testing.T, content []byte) []epub.Diagnostic {
+	t.Helper()
+	var diags []epub.Diagnostic
+	for _, d := range (&StructureValidator{}).Validate("chapter.xhtml", content, nil) {
+		if d.Code == "epub-type-unknown" {
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
@@ -0,0 +1,56 @@
+package validator
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// clockPattern matches a SMIL 3.0 clock value as used by media:duration and
+// the clipBegin and clipEnd attributes of media overlays: a full clock
+// value (hh:mm:ss.fraction), a partial clock value (mm:ss.fraction), or a
+// timecount value with an optional h, min, s, or ms metric.
+var clockPattern = regexp.MustCompile(
+	`^(?:(?:(\d+):)?([0-5]\d):([0-5]\d(?:\.\d+)?)|(\d+(?:\.\d+)?)(h|min|s|ms)?)$`,
+)
+
+// timecountUnits maps timecount metrics to their length. A timecount
+// without a metric is in seconds.
+var timecountUnits = map[string]time.Duration{
+	"h":   time.Hour,
+	"min": time.Minute,
+	"s":   time.Second,
+	"":    time.Second,
+	"ms":  time.Millisecond,
+}
+
+// ParseClockValue parses a SMIL clock value such as "0:32:29.5", "05:01.2",
+// "12.5s", or "1.5h". ok is false when value is not a clock value;
+// surrounding whitespace is not allowed.
+func ParseClockValue(value string) (d time.Duration, ok bool) {
+	m := clockPattern.FindStringSubmatch(value)
+	if m == nil {
+		return 0, false
+	}
+
+	if m[4] != "" {
+		count, err := strconv.ParseFloat(m[4], 64)
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(math.Round(count * float64(timecountUnits[m[5]]))), true
+	}
+
+	var hours, minutes int
+	if m[1] != "" {
+		hours, _ = strconv.Atoi(m[1])
+	}
+	minutes, _ = strconv.Atoi(m[2])
+	seconds, err := strconv.ParseFloat(m[3], 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(math.Round(seconds*float64(time.Second))), true
+}
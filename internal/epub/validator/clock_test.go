@@ -0,0 +1,41 @@
+package validator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseClockValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"0:32:29.5", 32*time.Minute + 29500*time.Millisecond, true},
+		{"02:30:03", 2*time.Hour + 30*time.Minute + 3*time.Second, true},
+		{"50:00:10.25", 50*time.Hour + 10250*time.Millisecond, true},
+		{"02:33", 2*time.Minute + 33*time.Second, true},
+		{"00:10.5", 10500 * time.Millisecond, true},
+		{"3.2h", 3*time.Hour + 12*time.Minute, true},
+		{"45min", 45 * time.Minute, true},
+		{"30s", 30 * time.Second, true},
+		{"5ms", 5 * time.Millisecond, true},
+		{"12.467", 12467 * time.Millisecond, true},
+		{"", 0, false},
+		{"1:2:3", 0, false},
+		{"00:60", 0, false},
+		{"0:61:00", 0, false},
+		{"10 s", 0, false},
+		{" 30s", 0, false},
+		{"PT30S", 0, false},
+		{"-5s", 0, false},
+		{"1.5m", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseClockValue(tt.value)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseClockValue(%q) = %v, %v, want %v, %v",
+				tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
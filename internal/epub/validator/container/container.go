@@ -0,0 +1,225 @@
+// Package container validates the META-INF files of an unpacked EPUB:
+// container.xml and encryption.xml.
+package container
+
+import (
+	"path"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+const source = "epub-container"
+
+const packageMediaType = "application/oebps-package+xml"
+
+// fontMediaTypes lists manifest media types that may be obfuscated.
+var fontMediaTypes = map[string]bool{
+	"font/otf":                      true,
+	"font/ttf":                      true,
+	"font/woff":                     true,
+	"font/woff2":                    true,
+	"application/font-sfnt":         true,
+	"application/font-woff":         true,
+	"application/vnd.ms-opentype":   true,
+	"application/x-font-ttf":        true,
+	"application/x-font-opentype":   true,
+	"application/x-font-truetype":   true,
+	"application/vnd.ms-fontobject": true,
+}
+
+// obfuscationAlgorithms are the font obfuscation algorithms of the IDPF and
+// Adobe.
+var obfuscationAlgorithms = map[string]bool{
+	"http://www.idpf.org/2008/embedding": true,
+	"http://ns.adobe.com/pdf/enc#RC":     true,
+}
+
+// fontExtensions identifies fonts when the manifest is unavailable.
+var fontExtensions = map[string]bool{
+	".otf": true, ".ttf": true, ".woff": true, ".woff2": true,
+}
+
+// ContainerValidator checks META-INF/container.xml: the container
+// namespace, the presence of an OPF rootfile, and that each rootfile
+// full-path names an OPF in the workspace.
+type ContainerValidator struct{}
+
+func (v *ContainerValidator) FileTypes() []epub.FileType {
+	return []epub.FileType{epub.FileTypeContainer}
+}
+
+func (v *ContainerValidator) Validate(
+	uri string,
+	content []byte,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	root, diags := parser.Parse(content)
+	if len(diags) > 0 {
+		return diags
+	}
+
+	container := root.FindFirst("container")
+	if container == nil {
+		return diags
+	}
+
+	if container.Space != epub.NSContainer {
+		diags = append(diags, epub.NewDiag(content, int(container.Offset), source).
+			Code("RSC_005").
+			Error("container element must use namespace "+epub.NSContainer).Build())
+	}
+
+	rootDir := containerRoot(uri)
+	hasPackage := false
+
+	for _, rootfile := range container.FindAll("rootfile") {
+		if rootfile.Attr("media-type") != packageMediaType {
+			continue
+		}
+		hasPackage = true
+
+		fullPath := rootfile.Attr("full-path")
+		if fullPath == "" {
+			diags = append(diags, epub.NewDiag(content, int(rootfile.Offset), source).
+				Code("OPF_016").
+				Error("rootfile is missing the full-path attribute").Build())
+			continue
+		}
+
+		if ctx == nil || ctx.Files == nil {
+			continue
+		}
+		target, found := uriutil.Lookup(ctx.Files, epub.ResolveHref(rootDir, fullPath))
+		switch {
+		case !found:
+			diags = append(diags, epub.NewDiag(content, int(rootfile.Offset), source).
+				Code("RSC_001").
+				Error("rootfile full-path not found in workspace: "+fullPath).Build())
+		case ctx.FileTypes != nil && ctx.FileTypes[target] != epub.FileTypeOPF:
+			diags = append(diags, epub.NewDiag(content, int(rootfile.Offset), source).
+				Code("OPF_001").
+				Error("rootfile full-path is not a package document: "+fullPath).Build())
+		}
+	}
+
+	if !hasPackage {
+		diags = append(diags, epub.NewDiag(content, int(container.Offset), source).
+			Code("RSC_003").
+			Error("no rootfile with media-type \""+packageMediaType+"\" found").Build())
+	}
+
+	return diags
+}
+
+// EncryptionValidator checks META-INF/encryption.xml: encrypted resources
+// must exist, obfuscated resources must be fonts listed in the manifest,
+// and anything other than a font is reported since its content cannot be
+// read for validation.
+type EncryptionValidator struct{}
+
+func (v *EncryptionValidator) FileTypes() []epub.FileType {
+	return []epub.FileType{epub.FileTypeEncryption}
+}
+
+func (v *EncryptionValidator) Validate(
+	uri string,
+	content []byte,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	root, diags := parser.Parse(content)
+	if len(diags) > 0 {
+		return diags
+	}
+
+	rootDir := containerRoot(uri)
+
+	for _, ref := range root.FindAllNS(epub.NSXMLEnc, "CipherReference") {
+		href := ref.Attr("URI")
+		if href == "" || epub.IsRemoteURL(href) {
+			continue
+		}
+		resolved := epub.ResolveHref(rootDir, href)
+
+		if ctx != nil && ctx.Files != nil {
+			if _, ok := uriutil.Lookup(ctx.Files, resolved); !ok {
+				diags = append(diags, epub.NewDiag(content, int(ref.Offset), source).
+					Code("RSC_001").
+					Error("encrypted resource not found in workspace: "+href).Build())
+			}
+		}
+
+		if obfuscationAlgorithms[algorithm(ref)] {
+			if msg := checkObfuscated(resolved, ctx); msg != "" {
+				diags = append(diags, epub.NewDiag(content, int(ref.Offset), source).
+					Code("PKG_026").
+					Error(msg+": "+href).Build())
+			}
+			continue
+		}
+
+		if !isFont(resolved, ctx) {
+			diags = append(diags, epub.NewDiag(content, int(ref.Offset), source).
+				Code("RSC_004").
+				Warning("encrypted resource cannot be validated: "+href).Build())
+		}
+	}
+
+	return diags
+}
+
+// algorithm returns the Algorithm of the EncryptionMethod of the
+// EncryptedData holding ref, a CipherReference.
+func algorithm(ref *parser.XMLNode) string {
+	for n := ref.Parent; n != nil; n = n.Parent {
+		if n.Space == epub.NSXMLEnc && n.Local == "EncryptedData" {
+			if method := n.FindFirstNS(epub.NSXMLEnc, "EncryptionMethod"); method != nil {
+				return method.Attr("Algorithm")
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// checkObfuscated returns why the obfuscated resource at resolved is not a
+// font reading systems can de-obfuscate, or "" when it is. Obfuscation
+// applies only to fonts, and reading systems find the font by its manifest
+// item.
+func checkObfuscated(resolved string, ctx *validator.WorkspaceContext) string {
+	if ctx == nil || ctx.Manifest == nil {
+		if !isFont(resolved, ctx) {
+			return "obfuscated resource is not a font"
+		}
+		return ""
+	}
+	item := ctx.Manifest.ItemByPath(resolved)
+	switch {
+	case item == nil:
+		return "obfuscated resource is not listed in the manifest"
+	case !fontMediaTypes[item.MediaType]:
+		return "obfuscated resource must be a font, but the manifest declares it as " +
+			item.MediaType
+	}
+	return ""
+}
+
+// containerRoot returns the path of the directory holding META-INF, which
+// container-relative paths are resolved against.
+func containerRoot(uri string) string {
+	return path.Dir(uriutil.Dir(uri))
+}
+
+// isFont reports whether the resource at resolved is a font, by manifest
+// media type when the manifest lists it and by extension otherwise.
+func isFont(resolved string, ctx *validator.WorkspaceContext) bool {
+	if ctx != nil && ctx.Manifest != nil {
+		if item := ctx.Manifest.ItemByPath(resolved); item != nil {
+			return fontMediaTypes[item.MediaType]
+		}
+	}
+	return fontExtensions[strings.ToLower(path.Ext(resolved))]
+}
@@ -0,0 +1,187 @@
+package container
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+const containerURI = "file:///book/META-INF/container.xml"
+
+func containerXML(rootfiles string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+` + rootfiles + `
+  </rootfiles>
+</container>`)
+}
+
+func workspace(files ...string) *validator.WorkspaceContext {
+	ctx := &validator.WorkspaceContext{
+		Files:     make(map[string][]byte),
+		FileTypes: make(map[string]epub.FileType),
+	}
+	for _, f := range files {
+		ctx.Files[f] = []byte{}
+		ctx.FileTypes[f] = epub.DetectFileType(f, nil)
+	}
+	return ctx
+}
+
+func TestValidContainer(t *testing.T) {
+	content := containerXML(`    <rootfile full-path="OEBPS/package.opf" ` +
+		`media-type="application/oebps-package+xml"/>`)
+	ctx := workspace(containerURI, "file:///book/OEBPS/package.opf")
+
+	v := &ContainerValidator{}
+	diags := v.Validate(containerURI, content, ctx)
+
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", testutil.DiagCodes(diags))
+	}
+}
+
+func TestContainerWrongFullPath(t *testing.T) {
+	content := containerXML(`    <rootfile full-path="OPS/content.opf" ` +
+		`media-type="application/oebps-package+xml"/>`)
+	ctx := workspace(containerURI, "file:///book/OEBPS/package.opf")
+
+	v := &ContainerValidator{}
+	diags := v.Validate(containerURI, content, ctx)
+
+	if !testutil.HasCode(diags, "RSC_001") {
+		t.Errorf("expected RSC_001 for wrong full-path, got %v",
+			testutil.DiagCodes(diags))
+	}
+}
+
+func TestContainerFullPathNotOPF(t *testing.T) {
+	content := containerXML(`    <rootfile full-path="OEBPS/chapter1.xhtml" ` +
+		`media-type="application/oebps-package+xml"/>`)
+	ctx := workspace(containerURI, "file:///book/OEBPS/chapter1.xhtml")
+
+	v := &ContainerValidator{}
+	diags := v.Validate(containerURI, content, ctx)
+
+	if !testutil.HasCode(diags, "OPF_001") {
+		t.Errorf("expected OPF_001 for non-OPF rootfile, got %v",
+			testutil.DiagCodes(diags))
+	}
+}
+
+func TestContainerWithoutPackageRootfile(t *testing.T) {
+	content := containerXML(`    <rootfile full-path="OEBPS/book.pdf" ` +
+		`media-type="application/pdf"/>`)
+
+	v := &ContainerValidator{}
+	diags := v.Validate(containerURI, content, nil)
+
+	if !testutil.HasCode(diags, "RSC_003") {
+		t.Error("expected RSC_003 when no package rootfile exists")
+	}
+}
+
+func TestContainerWrongNamespace(t *testing.T) {
+	content := []byte(`<container version="1.0"><rootfiles>
+<rootfile full-path="package.opf" media-type="application/oebps-package+xml"/>
+</rootfiles></container>`)
+
+	v := &ContainerValidator{}
+	diags := v.Validate(containerURI, content, nil)
+
+	if !testutil.HasCode(diags, "RSC_005") {
+		t.Error("expected RSC_005 for missing container namespace")
+	}
+}
+
+func encryptionXML(uris ...string) []byte {
+	return encryptionXMLWith("http://www.idpf.org/2008/embedding", uris...)
+}
+
+// encryptionXMLWith returns an encryption.xml listing uris as encrypted
+// with algorithm.
+func encryptionXMLWith(algorithm string, uris ...string) []byte {
+	var entries string
+	for _, uri := range uris {
+		entries += `
+  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="` + algorithm + `"/>
+    <enc:CipherData><enc:CipherReference URI="` + uri + `"/></enc:CipherData>
+  </enc:EncryptedData>`
+	}
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container"
+    xmlns:enc="http://www.w3.org/2001/04/xmlenc#">` + entries + `
+</encryption>`)
+}
+
+func TestEncryptedFontsAllowed(t *testing.T) {
+	uri := "file:///book/META-INF/encryption.xml"
+	content := encryptionXML("OEBPS/fonts/body.otf")
+	ctx := workspace(uri, "file:///book/OEBPS/fonts/body.otf")
+
+	v := &EncryptionValidator{}
+	diags := v.Validate(uri, content, ctx)
+
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", testutil.DiagCodes(diags))
+	}
+}
+
+func TestEncryptedNonFontAndMissingResource(t *testing.T) {
+	uri := "file:///book/META-INF/encryption.xml"
+	content := encryptionXMLWith("http://www.w3.org/2001/04/xmlenc#aes256-cbc",
+		"OEBPS/chapter1.xhtml", "OEBPS/fonts/missing.woff")
+	ctx := workspace(uri, "file:///book/OEBPS/chapter1.xhtml")
+	ctx.Manifest = &validator.ManifestInfo{
+		Items: []validator.ManifestItem{
+			{ID: "ch1", Href: "chapter1.xhtml", MediaType: "application/xhtml+xml"},
+		},
+	}
+
+	v := &EncryptionValidator{}
+	diags := v.Validate(uri, content, ctx)
+
+	codes := testutil.DiagCodes(diags)
+	testutil.ExpectCode(t, codes, "RSC_004")
+	testutil.ExpectCode(t, codes, "RSC_001")
+	if len(diags) != 2 {
+		t.Errorf("expected 2 diagnostics, got %d: %v", len(diags), codes)
+	}
+}
+
+func TestObfuscatedResourceMustBeManifestFont(t *testing.T) {
+	uri := "file:///book/META-INF/encryption.xml"
+	content := encryptionXML("OEBPS/chapter1.xhtml", "OEBPS/fonts/extra.otf",
+		"OEBPS/fonts/body.otf")
+	ctx := workspace(uri, "file:///book/OEBPS/chapter1.xhtml",
+		"file:///book/OEBPS/fonts/extra.otf", "file:///book/OEBPS/fonts/body.otf")
+	ctx.Manifest = &validator.ManifestInfo{
+		Items: []validator.ManifestItem{
+			{ID: "ch1", Href: "chapter1.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "body", Href: "fonts/body.otf", MediaType: "font/otf"},
+		},
+	}
+
+	diags := (&EncryptionValidator{}).Validate(uri, content, ctx)
+
+	var messages []string
+	for _, d := range diags {
+		if d.Code != "PKG_026" || d.Severity != epub.SeverityError {
+			t.Errorf("unexpected %s: %s", d.Code, d.Message)
+		}
+		messages = append(messages, d.Message)
+	}
+	want := []string{
+		"obfuscated resource must be a font, but the manifest declares it as " +
+			"application/xhtml+xml: OEBPS/chapter1.xhtml",
+		"obfuscated resource is not listed in the manifest: OEBPS/fonts/extra.otf",
+	}
+	if !slices.Equal(messages, want) {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+}
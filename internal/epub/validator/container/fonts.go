@@ -0,0 +1,80 @@
+package container
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// FontValidator checks the package document's font items against
+// META-INF/encryption.xml. A font whose first bytes are no font format's
+// magic number has either been damaged or obfuscated, and reading systems
+// can only undo obfuscation that encryption.xml declares; otherwise the
+// text renders in a fallback font or as missing glyphs.
+type FontValidator struct{}
+
+func (v *FontValidator) FileTypes() []epub.FileType {
+	return []epub.FileType{epub.FileTypeOPF}
+}
+
+func (v *FontValidator) Validate(
+	uri string,
+	content []byte,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	root, diags := parser.Parse(content)
+	if len(diags) > 0 || ctx == nil {
+		return nil
+	}
+	manifest := root.FindFirst("manifest")
+	if manifest == nil {
+		return nil
+	}
+
+	declared := encryptedPaths(ctx)
+	for _, item := range manifest.FindAll("item") {
+		href := item.Attr("href")
+		if !fontMediaTypes[item.Attr("media-type")] ||
+			href == "" || epub.IsRemoteURL(href) {
+			continue
+		}
+		resolved := uriutil.ResolveRelative(uri, href)
+		if declared[uriutil.Path(resolved)] {
+			continue
+		}
+		head := ctx.ReadHead(resolved, epub.SniffLength)
+		if len(head) == 0 || strings.HasPrefix(epub.SniffMediaType(head), "font/") {
+			continue
+		}
+		diags = append(diags, epub.NewDiag(content, int(item.Offset), source).
+			Code("font-obfuscation-undeclared").
+			Warning("font file appears corrupted or obfuscated without an "+
+				"encryption declaration: "+href).Build())
+	}
+	return diags
+}
+
+// encryptedPaths returns the paths of the resources that the workspace's
+// encryption.xml files list.
+func encryptedPaths(ctx *validator.WorkspaceContext) map[string]bool {
+	paths := make(map[string]bool)
+	for uri, fileType := range ctx.FileTypes {
+		if fileType != epub.FileTypeEncryption {
+			continue
+		}
+		root, diags := parser.Parse(ctx.Files[uri])
+		if len(diags) > 0 {
+			continue
+		}
+		rootDir := containerRoot(uri)
+		for _, ref := range root.FindAllNS(epub.NSXMLEnc, "CipherReference") {
+			if href := ref.Attr("URI"); href != "" && !epub.IsRemoteURL(href) {
+				paths[epub.ResolveHref(rootDir, href)] = true
+			}
+		}
+	}
+	return paths
+}
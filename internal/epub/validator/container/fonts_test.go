@@ -0,0 +1,83 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+	"github.com/toba/lsp/pathutil"
+)
+
+// fontPackage lists fonts/body.woff2 and fonts/title.otf.
+var fontPackage = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <manifest>
+    <item id="body" href="fonts/body.woff2" media-type="font/woff2"/>
+    <item id="title" href="fonts/title.otf" media-type="font/otf"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+</package>`)
+
+// obfuscatedOTF is the start of an OpenType font whose first bytes have
+// been XORed with a key, as obfuscation leaves them.
+var obfuscatedOTF = []byte{
+	0x3e, 0x91, 0xc4, 0x07, 0x5a, 0x00, 0x0b, 0x80, 0x00, 0x03, 0x00, 0x30,
+}
+
+// fontBook writes the fonts to a book directory and returns a workspace
+// holding its package document, keyed by file URI.
+func fontBook(t *testing.T, title []byte) (string, *validator.WorkspaceContext) {
+	t.Helper()
+	dir := t.TempDir()
+	fonts := filepath.Join(dir, "OEBPS", "fonts")
+	if err := os.MkdirAll(fonts, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	woff2 := []byte("wOF2\x00\x01\x00\x00\x00\x00\x10\x00")
+	for name, data := range map[string][]byte{"body.woff2": woff2, "title.otf": title} {
+		if err := os.WriteFile(filepath.Join(fonts, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opfURI := pathutil.FilePathToURI(filepath.Join(dir, "OEBPS", "package.opf"))
+	ctx := workspace(opfURI)
+	ctx.Files[opfURI] = fontPackage
+	return opfURI, ctx
+}
+
+func TestFontValidator_ObfuscatedWithoutDeclaration(t *testing.T) {
+	uri, ctx := fontBook(t, obfuscatedOTF)
+
+	diags := (&FontValidator{}).Validate(uri, fontPackage, ctx)
+
+	if len(diags) != 1 || diags[0].Code != "font-obfuscation-undeclared" {
+		t.Fatalf("expected one font-obfuscation-undeclared, got %v",
+			testutil.DiagCodes(diags))
+	}
+	if diags[0].Severity != epub.SeverityWarning || diags[0].Range.Start.Line != 4 {
+		t.Errorf("expected a warning on the title.otf item, got %+v", diags[0])
+	}
+}
+
+func TestFontValidator_ValidHeaders(t *testing.T) {
+	uri, ctx := fontBook(t, []byte("OTTO\x00\x0b\x00\x80\x00\x03\x00\x30"))
+
+	if diags := (&FontValidator{}).Validate(uri, fontPackage, ctx); len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", testutil.DiagCodes(diags))
+	}
+}
+
+func TestFontValidator_DeclaredObfuscation(t *testing.T) {
+	uri, ctx := fontBook(t, obfuscatedOTF)
+	encURI := uri[:len(uri)-len("OEBPS/package.opf")] + "META-INF/encryption.xml"
+	ctx.Files[encURI] = encryptionXML("OEBPS/fonts/title.otf")
+	ctx.FileTypes[encURI] = epub.FileTypeEncryption
+
+	if diags := (&FontValidator{}).Validate(uri, fontPackage, ctx); len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", testutil.DiagCodes(diags))
+	}
+}
@@ -0,0 +1,181 @@
+package container
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/packager"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// metaInfName is the container's metadata directory.
+const metaInfName = "META-INF"
+
+// maxMimetypeQuote is the length past which a wrong mimetype is cut short
+// in messages.
+const maxMimetypeQuote = 40
+
+// LayoutValidator checks the top level of an unpacked EPUB on disk: the
+// mimetype file must hold exactly application/epub+zip, and META-INF must
+// exist. Zip scripts that add the directory as it is package such books
+// without complaint, and epubcheck then rejects them. It also warns about
+// manifest items that climb out of the package document's directory,
+// which break when the package document or the book is moved. The
+// findings are reported on the package document, and only when
+// WorkspaceContext.RootPath is set and the book root has a container.xml,
+// mimetype, or META-INF that marks it as an unpacked EPUB.
+type LayoutValidator struct{}
+
+func (v *LayoutValidator) FileTypes() []epub.FileType {
+	return []epub.FileType{epub.FileTypeOPF}
+}
+
+func (v *LayoutValidator) Validate(
+	uri string,
+	content []byte,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	if ctx == nil || ctx.RootPath == "" {
+		return nil
+	}
+	root, xmlDiags := parser.Parse(content)
+	if len(xmlDiags) > 0 {
+		return nil
+	}
+	pkg := root.FindFirst("package")
+	if pkg == nil {
+		return nil
+	}
+	opfPath := ctx.DiskPath(uri)
+	bookRoot, ok := bookRoot(ctx, opfPath)
+	if !ok {
+		return nil
+	}
+
+	lines := epub.NewLineIndex(content)
+	at := int(pkg.Offset)
+	var diags []epub.Diagnostic
+	mimetype, err := os.ReadFile(filepath.Join(bookRoot, packager.MimetypeName))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		diags = append(diags, epub.NewDiagAt(lines, at, source).
+			Code("PKG_006").
+			Error("the book has no mimetype file; add one at the top level "+
+				"holding "+packager.Mimetype).Build())
+	case err == nil:
+		if problem := mimetypeProblem(mimetype); problem != "" {
+			diags = append(diags, epub.NewDiagAt(lines, at, source).
+				Code("PKG_007").
+				Error("the mimetype file must contain exactly "+
+					packager.Mimetype+", but "+problem).Build())
+		}
+	}
+	info, err := os.Stat(filepath.Join(bookRoot, metaInfName))
+	if err != nil || !info.IsDir() {
+		diags = append(diags, epub.NewDiagAt(lines, at, source).
+			Code("RSC_002").
+			Error("the book has no META-INF directory, so reading systems "+
+				"cannot find META-INF/container.xml").Build())
+	}
+
+	if manifest := pkg.FindFirst("manifest"); manifest != nil {
+		for _, item := range manifest.FindAll("item") {
+			href := item.Attr("href")
+			if !climbsOut(href) {
+				continue
+			}
+			diags = append(diags, epub.NewDiagAt(lines, int(item.Offset), source).
+				Code("package-href-parent").
+				Warning("manifest item is outside the package document's "+
+					"directory: "+href).Build())
+		}
+	}
+	return diags
+}
+
+// bookRoot returns the directory holding the unpacked EPUB whose package
+// document is at opfPath: the parent of META-INF for a workspace
+// container.xml above the package document, and otherwise the workspace
+// root when it has a mimetype file or META-INF directory. It reports false
+// when neither marks an EPUB holding the package document.
+func bookRoot(ctx *validator.WorkspaceContext, opfPath string) (string, bool) {
+	if opfPath == "" {
+		return "", false
+	}
+	for uri, fileType := range ctx.FileTypes {
+		if fileType != epub.FileTypeContainer {
+			continue
+		}
+		dir := filepath.Dir(filepath.Dir(ctx.DiskPath(uri)))
+		if contains(dir, opfPath) {
+			return dir, true
+		}
+	}
+
+	root := ctx.RootPath
+	if !contains(root, opfPath) {
+		return "", false
+	}
+	for _, name := range []string{packager.MimetypeName, metaInfName} {
+		if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+// contains reports whether the file at name is inside dir.
+func contains(dir, name string) bool {
+	rel, err := filepath.Rel(dir, name)
+	return err == nil && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// mimetypeProblem describes how content differs from the expected mimetype,
+// or returns "" when it matches byte for byte.
+func mimetypeProblem(content []byte) string {
+	want := []byte(packager.Mimetype)
+	if bytes.Equal(content, want) {
+		return ""
+	}
+	rest, bom := epub.StripBOM(content)
+	trimmed := bytes.TrimRight(rest, " \t\r\n")
+	trailing := len(rest) > len(trimmed)
+	switch {
+	case len(bytes.TrimSpace(rest)) == 0:
+		return "it is empty"
+	case !bytes.Equal(trimmed, want):
+		text := string(bytes.TrimSpace(rest))
+		if len(text) > maxMimetypeQuote {
+			text = text[:maxMimetypeQuote] + "…"
+		}
+		return fmt.Sprintf("it holds %q", text)
+	case bom && trailing:
+		return "it starts with a byte order mark and ends with whitespace"
+	case bom:
+		return "it starts with a byte order mark"
+	case bytes.ContainsAny(rest[len(trimmed):], "\r\n"):
+		return "it ends with a newline"
+	}
+	return "it ends with whitespace"
+}
+
+// climbsOut reports whether the local href leads out of the directory of
+// the document holding it.
+func climbsOut(href string) bool {
+	if href == "" || epub.IsRemoteURL(href) {
+		return false
+	}
+	p, _, _ := strings.Cut(href, "#")
+	p, _, _ = strings.Cut(p, "?")
+	p = path.Clean(p)
+	return p == ".." || strings.HasPrefix(p, "../")
+}
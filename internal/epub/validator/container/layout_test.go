@@ -0,0 +1,151 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+	"github.com/toba/lsp/pathutil"
+)
+
+var layoutPackage = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <manifest>
+    <item id="ch1" href="text/chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+</package>`)
+
+// layoutBook writes files, keyed by slash-separated path, to a book
+// directory and returns a workspace rooted there holding the package
+// document at OEBPS/package.opf and any container.xml written.
+func layoutBook(
+	t *testing.T,
+	files map[string]string,
+) (string, *validator.WorkspaceContext) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opfURI := pathutil.FilePathToURI(filepath.Join(dir, "OEBPS", "package.opf"))
+	ctx := workspace(opfURI)
+	ctx.RootPath = dir
+	if _, ok := files["META-INF/container.xml"]; ok {
+		uri := pathutil.FilePathToURI(filepath.Join(dir, "META-INF", "container.xml"))
+		ctx.Files[uri] = nil
+		ctx.FileTypes[uri] = epub.FileTypeContainer
+	}
+	return opfURI, ctx
+}
+
+func TestLayoutValidator_Clean(t *testing.T) {
+	uri, ctx := layoutBook(t, map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": "",
+	})
+
+	if diags := (&LayoutValidator{}).Validate(uri, layoutPackage, ctx); len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", testutil.DiagCodes(diags))
+	}
+}
+
+func TestLayoutValidator_MissingMimetype(t *testing.T) {
+	uri, ctx := layoutBook(t, map[string]string{"META-INF/container.xml": ""})
+
+	diags := (&LayoutValidator{}).Validate(uri, layoutPackage, ctx)
+	if len(diags) != 1 || diags[0].Code != "PKG_006" {
+		t.Fatalf("expected one PKG_006, got %v", testutil.DiagCodes(diags))
+	}
+	if diags[0].Severity != epub.SeverityError || diags[0].Range.Start.Line != 1 {
+		t.Errorf("expected an error on the package element, got %+v", diags[0])
+	}
+}
+
+func TestLayoutValidator_MimetypeContent(t *testing.T) {
+	cases := []struct {
+		name, content, want string
+	}{
+		{"newline", "application/epub+zip\n", "ends with a newline"},
+		{"CRLF", "application/epub+zip\r\n", "ends with a newline"},
+		{"space", "application/epub+zip ", "ends with whitespace"},
+		{"BOM", epub.BOM + "application/epub+zip", "starts with a byte order mark"},
+		{"BOM and newline", epub.BOM + "application/epub+zip\n",
+			"byte order mark and ends with whitespace"},
+		{"wrong text", "application/zip\n", `holds "application/zip"`},
+		{"empty", "", "is empty"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			uri, ctx := layoutBook(t, map[string]string{
+				"mimetype":               tc.content,
+				"META-INF/container.xml": "",
+			})
+
+			diags := (&LayoutValidator{}).Validate(uri, layoutPackage, ctx)
+			if len(diags) != 1 || diags[0].Code != "PKG_007" {
+				t.Fatalf("expected one PKG_007, got %v", testutil.DiagCodes(diags))
+			}
+			if !strings.Contains(diags[0].Message, tc.want) {
+				t.Errorf("message = %q, want it to mention %q", diags[0].Message, tc.want)
+			}
+		})
+	}
+}
+
+func TestLayoutValidator_MissingMetaInf(t *testing.T) {
+	uri, ctx := layoutBook(t, map[string]string{"mimetype": "application/epub+zip"})
+
+	diags := (&LayoutValidator{}).Validate(uri, layoutPackage, ctx)
+	if len(diags) != 1 || diags[0].Code != "RSC_002" {
+		t.Errorf("expected one RSC_002, got %v", testutil.DiagCodes(diags))
+	}
+}
+
+func TestLayoutValidator_NotAnEPUB(t *testing.T) {
+	uri, ctx := layoutBook(t, nil)
+
+	if diags := (&LayoutValidator{}).Validate(uri, layoutPackage, ctx); len(diags) != 0 {
+		t.Errorf("expected nothing without a container, got %v", testutil.DiagCodes(diags))
+	}
+	ctx.RootPath = ""
+	if diags := (&LayoutValidator{}).Validate(uri, layoutPackage, ctx); len(diags) != 0 {
+		t.Errorf("expected nothing without a root, got %v", testutil.DiagCodes(diags))
+	}
+}
+
+func TestLayoutValidator_ParentHrefs(t *testing.T) {
+	uri, ctx := layoutBook(t, map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": "",
+	})
+	content := []byte(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="ch1" href="text/chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="css" href="../styles/book.css" media-type="text/css"/>
+    <item id="img" href="text/../../images/cover.png#x" media-type="image/png"/>
+    <item id="web" href="https://example.com/a/../b.png" media-type="image/png"/>
+  </manifest>
+</package>`)
+
+	diags := (&LayoutValidator{}).Validate(uri, content, ctx)
+	if len(diags) != 2 {
+		t.Fatalf("expected two package-href-parent, got %v", testutil.DiagCodes(diags))
+	}
+	for i, line := range []int{3, 4} {
+		if diags[i].Code != "package-href-parent" || diags[i].Range.Start.Line != line ||
+			diags[i].Severity != epub.SeverityWarning {
+			t.Errorf("diagnostic %d = %+v, want a warning on line %d", i, diags[i], line)
+		}
+	}
+}
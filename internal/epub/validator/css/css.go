@@ -36,11 +36,13 @@ func (v *Validator) FileTypes() []epub.FileType {
 }
 
 func (v *Validator) Validate(
-	_ string,
+	uri string,
 	content []byte,
-	_ *validator.WorkspaceContext,
+	ctx *validator.WorkspaceContext,
 ) []epub.Diagnostic {
 	props, atRules, diags := parser.ScanCSS(content)
+	diags = append(diags, validateImports(uri, content, atRules, ctx)...)
+	diags = append(diags, validateMediaQueries(content, atRules)...)
 
 	// Check properties
 	for _, prop := range props {
@@ -81,20 +83,15 @@ func (v *Validator) Validate(
 	}
 
 	// Check @font-face for non-standard font types
-	for _, atRule := range atRules {
-		if atRule.Name != "@font-face" {
-			continue
-		}
-
-		// Look for src properties that follow this @font-face
-		for _, prop := range props {
-			if prop.Property != "src" || prop.Offset < atRule.Offset {
-				continue
-			}
+	for _, prop := range props {
+		if prop.Property == "src" && inFontFace(prop, atRules) {
 			checkFontSrc(prop, &diags)
 		}
 	}
 
+	diags = append(diags, validateFontFamilies(uri, content, props, atRules, ctx)...)
+	diags = append(diags, validateVariables(uri, content, props, ctx)...)
+
 	return diags
 }
 
@@ -123,27 +120,23 @@ func checkFontSrc(prop parser.CSSPropertyDecl, diags *[]epub.Diagnostic) {
 	}
 
 	// Check URL extension if no format() hint
-	if idx := strings.Index(val, "url("); idx >= 0 {
-		end := strings.Index(val[idx:], ")")
-		if end > 0 {
-			urlVal := val[idx+4 : idx+end]
-			urlVal = strings.Trim(urlVal, `"' `)
-			hasValidExt := false
-			for ext := range allowedFontExtensions {
-				if strings.HasSuffix(urlVal, ext) {
-					hasValidExt = true
-					break
-				}
-			}
-			if !hasValidExt && urlVal != "" {
-				*diags = append(*diags, epub.Diagnostic{
-					Code:     "CSS_007",
-					Severity: epub.SeverityWarning,
-					Message:  "non-standard font type in @font-face src",
-					Source:   source,
-					Range:    rng,
-				})
+	if urls := parser.CSSURLs(val); len(urls) > 0 {
+		urlVal := strings.TrimSpace(urls[0].Value)
+		hasValidExt := false
+		for ext := range allowedFontExtensions {
+			if strings.HasSuffix(urlVal, ext) {
+				hasValidExt = true
+				break
 			}
 		}
+		if !hasValidExt && urlVal != "" {
+			*diags = append(*diags, epub.Diagnostic{
+				Code:     "CSS_007",
+				Severity: epub.SeverityWarning,
+				Message:  "non-standard font type in @font-face src",
+				Source:   source,
+				Range:    rng,
+			})
+		}
 	}
 }
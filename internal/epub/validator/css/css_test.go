@@ -172,3 +172,19 @@ func TestMultipleIssues(t *testing.T) {
 		t.Errorf("expected 1 CSS_006 diagnostic, got %d", css006Count)
 	}
 }
+
+func TestCRLFLineEndings(t *testing.T) {
+	fixtures := []string{
+		"\np {\n  direction: rtl;\n}\n",
+		"\n.box {\n  direction: ltr;\n  position: fixed;\n  unicode-bidi: isolate;\n}\n",
+		"@font-face {\n  src: url(font.xyz) format(\"bogus\");\n}\n",
+		"body {\n  margin: 0;\n",
+	}
+
+	for _, fixture := range fixtures {
+		v := &Validator{}
+		lf := v.Validate("style.css", []byte(fixture), nil)
+		crlf := v.Validate("style.css", testutil.CRLF([]byte(fixture)), nil)
+		testutil.ExpectSameDiagnostics(t, lf, crlf)
+	}
+}
@@ -0,0 +1,242 @@
+package css
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// genericFamilies lists the generic font families, which reading systems
+// always resolve to a font.
+var genericFamilies = map[string]bool{
+	"serif":         true,
+	"sans-serif":    true,
+	"monospace":     true,
+	"cursive":       true,
+	"fantasy":       true,
+	"system-ui":     true,
+	"ui-serif":      true,
+	"ui-sans-serif": true,
+	"ui-monospace":  true,
+	"ui-rounded":    true,
+	"math":          true,
+	"emoji":         true,
+	"fangsong":      true,
+}
+
+// cssWideKeywords are the values every property accepts, which name no
+// font family.
+var cssWideKeywords = map[string]bool{
+	"inherit":      true,
+	"initial":      true,
+	"unset":        true,
+	"revert":       true,
+	"revert-layer": true,
+}
+
+// fontFamily is one entry of a font-family list.
+type fontFamily struct {
+	name string
+	// quoted is set for a family given as a string, which is never generic.
+	quoted bool
+}
+
+// parseFontFamilies splits a font-family value into its families, removing
+// quotes, collapsing whitespace in unquoted names, and dropping !important.
+func parseFontFamilies(value string) []fontFamily {
+	value = strings.TrimSpace(value)
+	if i := strings.LastIndex(value, "!"); i >= 0 &&
+		strings.EqualFold(strings.TrimSpace(value[i+1:]), "important") {
+		value = value[:i]
+	}
+
+	var families []fontFamily
+	var quote byte
+	start := 0
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) {
+			switch c := value[i]; {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+				continue
+			case c == '"' || c == '\'':
+				quote = c
+				continue
+			case c != ',':
+				continue
+			}
+		}
+		entry := strings.TrimSpace(value[start:i])
+		start = i + 1
+		if entry == "" {
+			continue
+		}
+		if q := entry[0]; (q == '"' || q == '\'') && len(entry) > 1 && entry[len(entry)-1] == q {
+			families = append(families, fontFamily{name: entry[1 : len(entry)-1], quoted: true})
+			continue
+		}
+		families = append(families, fontFamily{name: strings.Join(strings.Fields(entry), " ")})
+	}
+	return families
+}
+
+// inFontFace reports whether prop is a descriptor of an @font-face rule.
+func inFontFace(prop parser.CSSPropertyDecl, atRules []parser.CSSAtRule) bool {
+	return prop.AtRule >= 0 && strings.EqualFold(atRules[prop.AtRule].Name, "@font-face")
+}
+
+// declaredFamilies returns the lowercased families declared by @font-face
+// rules in all stylesheets of the workspace, reading the stylesheet at uri
+// from content.
+func declaredFamilies(
+	uri string,
+	content []byte,
+	ctx *validator.WorkspaceContext,
+) map[string]bool {
+	declared := make(map[string]bool)
+	add := func(content []byte) {
+		props, atRules, _ := parser.ScanCSS(content)
+		for _, prop := range props {
+			if prop.Property == "font-family" && inFontFace(prop, atRules) {
+				for _, family := range parseFontFamilies(prop.Value) {
+					declared[strings.ToLower(family.name)] = true
+				}
+			}
+		}
+	}
+
+	add(content)
+	for other, data := range ctx.Files {
+		if other == uri {
+			continue
+		}
+		fileType, ok := ctx.FileTypes[other]
+		if !ok {
+			fileType = epub.DetectFileType(other, data)
+		}
+		if fileType == epub.FileTypeCSS {
+			add(data)
+		}
+	}
+	return declared
+}
+
+// validateFontFamilies checks that font-family lists without a generic
+// fallback only name families declared by an @font-face rule somewhere in
+// the workspace, and that @font-face sources are in the manifest.
+func validateFontFamilies(
+	uri string,
+	content []byte,
+	props []parser.CSSPropertyDecl,
+	atRules []parser.CSSAtRule,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	if ctx == nil || ctx.Files == nil {
+		return nil
+	}
+
+	lines := epub.NewLineIndex(content)
+	var diags []epub.Diagnostic
+	var declared map[string]bool
+
+	for _, prop := range props {
+		if prop.Property != "font-family" || inFontFace(prop, atRules) {
+			continue
+		}
+		families := parseFontFamilies(prop.Value)
+		if len(families) == 0 || hasGenericFallback(families) {
+			continue
+		}
+		if declared == nil {
+			declared = declaredFamilies(uri, content, ctx)
+		}
+
+		var missing []string
+		for _, family := range families {
+			if !declared[strings.ToLower(family.name)] {
+				missing = append(missing, `"`+family.name+`"`)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		diags = append(diags, epub.NewDiagAt(lines, prop.Offset, source).
+			End(lines.Position(prop.Offset+len(prop.Property))).
+			Code("CSS_028").
+			Warning("font family "+strings.Join(missing, ", ")+" is not declared by "+
+				"any @font-face and has no generic fallback such as serif").
+			Build())
+	}
+
+	if ctx.Manifest != nil {
+		diags = append(diags, fontSourceDiags(uri, content, lines, props, atRules, ctx.Manifest)...)
+	}
+
+	return diags
+}
+
+// hasGenericFallback reports whether a font-family list ends in a generic
+// family or is a CSS-wide keyword.
+func hasGenericFallback(families []fontFamily) bool {
+	last := families[len(families)-1]
+	name := strings.ToLower(last.name)
+	return !last.quoted && (genericFamilies[name] || cssWideKeywords[name])
+}
+
+// fontSourceDiags reports @font-face src URLs that resolve to files the
+// manifest does not list.
+func fontSourceDiags(
+	uri string,
+	content []byte,
+	lines *epub.LineIndex,
+	props []parser.CSSPropertyDecl,
+	atRules []parser.CSSAtRule,
+	manifest *validator.ManifestInfo,
+) []epub.Diagnostic {
+	// Name each @font-face rule by its family for the messages
+	families := make(map[int]string)
+	for _, prop := range props {
+		if prop.Property == "font-family" && inFontFace(prop, atRules) {
+			if names := parseFontFamilies(prop.Value); len(names) > 0 {
+				families[prop.AtRule] = names[0].name
+			}
+		}
+	}
+
+	var diags []epub.Diagnostic
+	for _, prop := range props {
+		if prop.Property != "src" || !inFontFace(prop, atRules) {
+			continue
+		}
+		from := prop.Offset
+		for _, u := range parser.CSSURLs(prop.Value) {
+			// Values are reassembled from tokens, so find the URL in the source
+			start, end := prop.Offset, prop.Offset+len(prop.Property)
+			if idx := bytes.Index(content[from:], []byte(u.Value)); idx >= 0 {
+				start, end = from+idx, from+idx+len(u.Value)
+				from = end
+			}
+
+			target := uriutil.ResolveRelative(uri, u.Value)
+			if target == "" || inManifest(manifest, uriutil.Path(target)) {
+				continue
+			}
+			rule := "@font-face"
+			if family := families[prop.AtRule]; family != "" {
+				rule += " \"" + family + "\""
+			}
+			diags = append(diags, epub.NewDiagAt(lines, start, source).
+				End(lines.Position(end)).
+				Code("CSS_029").
+				Warning(rule+" source is not in the manifest: "+u.Value).
+				Build())
+		}
+	}
+	return diags
+}
@@ -0,0 +1,96 @@
+package css
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestParseFontFamilies(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []fontFamily
+	}{
+		{`serif`, []fontFamily{{name: "serif"}}},
+		{`"Alegreya", serif`, []fontFamily{{name: "Alegreya", quoted: true}, {name: "serif"}}},
+		{`'Open Sans' , Times  New Roman`, []fontFamily{
+			{name: "Open Sans", quoted: true}, {name: "Times New Roman"},
+		}},
+		{`"A, B" !important`, []fontFamily{{name: "A, B", quoted: true}}},
+		{``, nil},
+	}
+	for _, tt := range tests {
+		if got := parseFontFamilies(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("parseFontFamilies(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFontFamilyDeclarations(t *testing.T) {
+	fonts := `@font-face {
+  font-family: "Alegreya";
+  src: url("../fonts/alegreya.woff2");
+}`
+
+	tests := []struct {
+		name string
+		rule string
+		want bool
+	}{
+		{"declared family", `body { font-family: "Alegreya" }`, false},
+		{"declared case-insensitively", `body { font-family: alegreya }`, false},
+		{"undeclared family", `body { font-family: "Baskerville" }`, true},
+		{"generic fallback", `body { font-family: "Baskerville", serif }`, false},
+		{"quoted generic", `body { font-family: "Baskerville", "serif" }`, true},
+		{"generic only", `code { font-family: monospace }`, false},
+		{"css-wide keyword", `em { font-family: inherit }`, false},
+		{"undeclared in @media", `@media screen { h1 { font-family: Baskerville } }`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := importContext(map[string]string{
+				"file:///book/OEBPS/css/fonts.css":        fonts,
+				"file:///book/OEBPS/css/main.css":         tt.rule,
+				"file:///book/OEBPS/fonts/alegreya.woff2": "",
+			})
+			uri := "file:///book/OEBPS/css/main.css"
+			diags := (&Validator{}).Validate(uri, ctx.Files[uri], ctx)
+			if got := testutil.HasCode(diags, "CSS_028"); got != tt.want {
+				t.Errorf("CSS_028 = %v, want %v (%v)", got, tt.want, diags)
+			}
+		})
+	}
+}
+
+func TestFontFamilyWithoutWorkspace(t *testing.T) {
+	content := []byte(`body { font-family: "Baskerville" }`)
+	if diags := (&Validator{}).Validate("style.css", content, nil); len(diags) != 0 {
+		t.Errorf("expected no diagnostics without a workspace, got %v", diags)
+	}
+}
+
+func TestFontFaceSourceNotInManifest(t *testing.T) {
+	ctx := importContext(map[string]string{
+		"file:///book/OEBPS/css/fonts.css": `@font-face {
+  font-family: "Alegreya";
+  src: url("../fonts/alegreya.woff2") format("woff2"), url(../fonts/alegreya.woff);
+}`,
+		"file:///book/OEBPS/fonts/alegreya.woff2": "",
+	})
+	uri := "file:///book/OEBPS/css/fonts.css"
+	diags := (&Validator{}).Validate(uri, ctx.Files[uri], ctx)
+
+	if len(diags) != 1 || diags[0].Code != "CSS_029" {
+		t.Fatalf("expected one CSS_029, got %v", diags)
+	}
+	want := epub.Range{
+		Start: epub.Position{Line: 2, Character: 59},
+		End:   epub.Position{Line: 2, Character: 81},
+	}
+	if diags[0].Range != want {
+		t.Errorf("expected range over the URL %v, got %v", want, diags[0].Range)
+	}
+}
@@ -0,0 +1,237 @@
+package css
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// cssImport is the URL of an @import rule and its offset.
+type cssImport struct {
+	url    string
+	offset int
+}
+
+// parseImports returns the @import rules of a stylesheet in order. Imports
+// without a URL are skipped.
+func parseImports(atRules []parser.CSSAtRule) []cssImport {
+	var imports []cssImport
+	for _, at := range atRules {
+		if !strings.EqualFold(at.Name, "@import") {
+			continue
+		}
+		ref, offset := importURL(at.Prelude)
+		if ref == "" {
+			continue
+		}
+		imports = append(imports, cssImport{url: ref, offset: at.PreludeOffset + offset})
+	}
+	return imports
+}
+
+// importURL extracts the URL from an @import prelude, given either as
+// url() or as a string, and its offset within the prelude.
+func importURL(prelude string) (string, int) {
+	if strings.HasPrefix(strings.ToLower(prelude), "url(") {
+		if urls := parser.CSSURLs(prelude); len(urls) > 0 {
+			return urls[0].Value, urls[0].Offset
+		}
+	}
+	if prelude == "" || prelude[0] != '"' && prelude[0] != '\'' {
+		return "", 0
+	}
+	end := strings.IndexByte(prelude[1:], prelude[0])
+	if end < 0 {
+		return "", 0
+	}
+	return prelude[1 : end+1], 1
+}
+
+// validateImports checks that imported stylesheets exist and are in the
+// manifest, that @import and @namespace rules come before all other rules,
+// and that imports do not form a cycle.
+func validateImports(
+	uri string,
+	content []byte,
+	atRules []parser.CSSAtRule,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	imports := parseImports(atRules)
+	misplaced := misplacedRules(content, atRules)
+	if len(imports) == 0 && len(misplaced) == 0 {
+		return nil
+	}
+
+	lines := epub.NewLineIndex(content)
+	var diags []epub.Diagnostic
+
+	for _, at := range misplaced {
+		diags = append(diags, epub.NewDiagAt(lines, at.Offset, source).
+			End(lines.Position(at.Offset+len(at.Name))).
+			Code("css-at-rule-order").
+			Warning(at.Name+" must come before all other rules and is ignored "+
+				"by reading systems").
+			Build())
+	}
+
+	if ctx == nil || ctx.Files == nil {
+		return diags
+	}
+
+	for _, imp := range imports {
+		target := uriutil.ResolveRelative(uri, imp.url)
+		if target == "" {
+			continue // remote or data URL
+		}
+		diag := epub.NewDiagAt(lines, imp.offset, source).
+			End(lines.Position(imp.offset + len(imp.url))).
+			Code("CSS_010")
+
+		if _, ok := uriutil.Lookup(ctx.Files, target); !ok {
+			diags = append(diags, diag.
+				Error("imported stylesheet not found: "+imp.url).Build())
+		} else if ctx.Manifest != nil && !inManifest(ctx.Manifest, uriutil.Path(target)) {
+			diags = append(diags, diag.
+				Error("imported stylesheet not in manifest: "+imp.url).Build())
+		}
+	}
+
+	if imp, cycle := findImportCycle(uri, imports, ctx.Files); cycle != nil {
+		names := make([]string, len(cycle))
+		for i, u := range cycle {
+			names[i] = path.Base(uriutil.Path(u))
+		}
+		diags = append(diags, epub.NewDiagAt(lines, imp.offset, source).
+			End(lines.Position(imp.offset+len(imp.url))).
+			Code("css-import-cycle").
+			Warning("circular @import: "+strings.Join(names, " → ")).
+			Build())
+	}
+
+	return diags
+}
+
+// misplacedRules returns the @import and @namespace rules that follow a
+// rule they must precede. @charset and @layer statements may come first;
+// @namespace may only follow @import, and @import may follow neither.
+func misplacedRules(content []byte, atRules []parser.CSSAtRule) []parser.CSSAtRule {
+	firstOther, firstNamespace := len(content), len(content)
+
+	tok := parser.NewCSSTokenizer(content)
+	statement := false // inside the prelude of an allowed at-rule
+scan:
+	for {
+		t := tok.Next()
+		switch t.Type {
+		case parser.CSSTokenEOF:
+			break scan
+		case parser.CSSTokenComment:
+		case parser.CSSTokenSemicolon:
+			statement = false
+		case parser.CSSTokenAtRule:
+			switch strings.ToLower(t.Value) {
+			case "@charset", "@import", "@layer":
+				statement = true
+			case "@namespace":
+				firstNamespace = min(firstNamespace, t.Offset)
+				statement = true
+			default:
+				firstOther = t.Offset
+				break scan
+			}
+		case parser.CSSTokenBraceOpen:
+			// A block ends the run of statements, even an @layer block
+			firstOther = t.Offset
+			break scan
+		default:
+			if !statement {
+				firstOther = t.Offset
+				break scan
+			}
+		}
+	}
+
+	var misplaced []parser.CSSAtRule
+	for _, at := range atRules {
+		switch strings.ToLower(at.Name) {
+		case "@import":
+			if at.Offset > min(firstOther, firstNamespace) {
+				misplaced = append(misplaced, at)
+			}
+		case "@namespace":
+			if at.Offset > firstOther {
+				misplaced = append(misplaced, at)
+			}
+		}
+	}
+	return misplaced
+}
+
+// findImportCycle looks for an import chain from the stylesheet at uri that
+// leads back to it. It returns the import that starts the cycle and the
+// URIs along it, beginning and ending with uri. A cycle is only reported
+// from the member with the smallest URI, so each is reported once.
+func findImportCycle(
+	uri string,
+	imports []cssImport,
+	files map[string][]byte,
+) (cssImport, []string) {
+	for _, imp := range imports {
+		next, ok := resolveImport(uri, imp.url, files)
+		if !ok {
+			continue
+		}
+		visited := map[string]bool{}
+		if chain := importChain(next, uri, files, visited); chain != nil {
+			cycle := append([]string{uri}, chain...)
+			if slices.Min(cycle) == uri {
+				return imp, cycle
+			}
+		}
+	}
+	return cssImport{}, nil
+}
+
+// importChain returns the URIs of an import chain from "from" to "to",
+// including both, or nil when there is none.
+func importChain(
+	from, to string,
+	files map[string][]byte,
+	visited map[string]bool,
+) []string {
+	if from == to {
+		return []string{to}
+	}
+	if visited[from] {
+		return nil
+	}
+	visited[from] = true
+
+	_, atRules, _ := parser.ScanCSS(files[from])
+	for _, imp := range parseImports(atRules) {
+		next, ok := resolveImport(from, imp.url, files)
+		if !ok {
+			continue
+		}
+		if chain := importChain(next, to, files, visited); chain != nil {
+			return append([]string{from}, chain...)
+		}
+	}
+	return nil
+}
+
+// resolveImport returns the workspace URI of the stylesheet that ref
+// imports from the stylesheet at uri.
+func resolveImport(uri, ref string, files map[string][]byte) (string, bool) {
+	return uriutil.Lookup(files, uriutil.ResolveRelative(uri, ref))
+}
+
+// inManifest reports whether the resolved path p names a manifest item.
+func inManifest(m *validator.ManifestInfo, p string) bool {
+	return m.ItemByPath(p) != nil
+}
@@ -0,0 +1,132 @@
+package css
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func importContext(files map[string]string) *validator.WorkspaceContext {
+	ctx := &validator.WorkspaceContext{
+		Files:    make(map[string][]byte, len(files)),
+		Manifest: &validator.ManifestInfo{},
+	}
+	for uri, content := range files {
+		ctx.Files[uri] = []byte(content)
+		ctx.Manifest.Items = append(ctx.Manifest.Items, validator.ManifestItem{
+			Href: strings.TrimPrefix(uri, "file:///book/OEBPS/"),
+		})
+	}
+	return ctx
+}
+
+func TestImportMissingTarget(t *testing.T) {
+	ctx := importContext(map[string]string{
+		"file:///book/OEBPS/css/main.css": `@import url("base.css");
+@import "../fonts/fonts.css";`,
+		"file:///book/OEBPS/fonts/fonts.css": `@font-face { font-family: A }`,
+	})
+	uri := "file:///book/OEBPS/css/main.css"
+	diags := (&Validator{}).Validate(uri, ctx.Files[uri], ctx)
+
+	if len(diags) != 1 || diags[0].Code != "CSS_010" {
+		t.Fatalf("expected one CSS_010, got %v", diags)
+	}
+	want := epub.Range{
+		Start: epub.Position{Line: 0, Character: 13},
+		End:   epub.Position{Line: 0, Character: 21},
+	}
+	if diags[0].Range != want {
+		t.Errorf("expected range over the URL %v, got %v", want, diags[0].Range)
+	}
+}
+
+func TestImportNotInManifest(t *testing.T) {
+	ctx := importContext(map[string]string{
+		"file:///book/OEBPS/main.css": `@import "extra.css";`,
+	})
+	ctx.Files["file:///book/OEBPS/extra.css"] = []byte(`p { margin: 0 }`)
+
+	uri := "file:///book/OEBPS/main.css"
+	diags := (&Validator{}).Validate(uri, ctx.Files[uri], ctx)
+	if !testutil.HasCode(diags, "CSS_010") ||
+		!strings.Contains(diags[0].Message, "not in manifest") {
+		t.Errorf("expected CSS_010 for an unlisted import, got %v", diags)
+	}
+}
+
+func TestImportAfterRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{
+			"leading statements allowed",
+			`@charset "utf-8"; /* base */ @layer base; @import "a.css";
+@import url(b.css) screen; @namespace svg url(http://www.w3.org/2000/svg);
+p { margin: 0 }`,
+			0,
+		},
+		{"import after rule", "p { margin: 0 }\n@import \"a.css\";", 1},
+		{
+			"import after media block",
+			"@media print { p { margin: 0 } }\n@import \"a.css\";",
+			1,
+		},
+		{
+			"import after namespace",
+			"@namespace svg url(http://www.w3.org/2000/svg);\n@import \"a.css\";",
+			1,
+		},
+		{
+			"namespace after rule",
+			"p { margin: 0 }\n@namespace svg url(http://www.w3.org/2000/svg);",
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := (&Validator{}).Validate("style.css", []byte(tt.content), nil)
+			got := 0
+			for _, d := range diags {
+				if d.Code == "css-at-rule-order" {
+					got++
+				}
+			}
+			if got != tt.want {
+				t.Errorf("expected %d css-at-rule-order, got %v", tt.want, diags)
+			}
+		})
+	}
+}
+
+func TestImportCycle(t *testing.T) {
+	ctx := importContext(map[string]string{
+		"file:///book/OEBPS/a.css": `@import "b.css";`,
+		"file:///book/OEBPS/b.css": `@import "a.css";`,
+		"file:///book/OEBPS/c.css": `@import "a.css";`,
+	})
+
+	cycles := map[string]int{}
+	for uri, content := range ctx.Files {
+		for _, d := range (&Validator{}).Validate(uri, content, ctx) {
+			if d.Code == "css-import-cycle" {
+				cycles[uri]++
+				if !strings.Contains(d.Message, "a.css → b.css → a.css") {
+					t.Errorf("unexpected cycle message %q", d.Message)
+				}
+			} else {
+				t.Errorf("%s: unexpected %s: %s", uri, d.Code, d.Message)
+			}
+		}
+	}
+
+	if len(cycles) != 1 || cycles["file:///book/OEBPS/a.css"] != 1 {
+		t.Errorf("expected the cycle reported once, in a.css; got %v", cycles)
+	}
+}
@@ -0,0 +1,290 @@
+package css
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// maxSuggestionDistance is the most edits between an unknown media feature
+// and a known one suggested in its place.
+const maxSuggestionDistance = 2
+
+// MediaFeatureInfo describes a media feature of Media Queries Level 5.
+type MediaFeatureInfo struct {
+	// Values lists the feature's keywords, or example values for
+	// features that take numbers or lengths.
+	Values []string
+	// Range is set for features that take min- and max- prefixes.
+	Range bool
+	// Limited is set for features that reading systems support poorly or
+	// answer the same way on every device.
+	Limited bool
+	// Doc describes the feature and how reading systems support it.
+	Doc string
+}
+
+// mediaFeatures maps the media feature names to their descriptions.
+var mediaFeatures = map[string]MediaFeatureInfo{
+	"width": {
+		Values: []string{"30em", "600px"}, Range: true,
+		Doc: "The width of the viewport, which in paginated reading systems is " +
+			"the page or, in a two-page spread, one page. Well supported.",
+	},
+	"height": {
+		Values: []string{"40em", "800px"}, Range: true,
+		Doc: "The height of the viewport. Well supported, though toolbars that " +
+			"come and go can change it while reading.",
+	},
+	"aspect-ratio": {
+		Values: []string{"1/1", "3/4", "16/9"}, Range: true,
+		Doc: "The width-to-height ratio of the viewport. Well supported.",
+	},
+	"orientation": {
+		Values: []string{"portrait", "landscape"},
+		Doc: "Whether the viewport is taller than it is wide. Well supported, " +
+			"and useful for fixed layouts and spreads.",
+	},
+	"resolution": {
+		Values: []string{"2dppx", "192dpi"}, Range: true,
+		Doc: "The pixel density of the screen. Supported by most reading " +
+			"systems built on browser engines.",
+	},
+	"color": {
+		Values: []string{"1", "8"}, Range: true,
+		Doc: "The bits per color component, 0 on monochrome screens. `(color)` " +
+			"is the usual way to tell color screens from e-ink ones.",
+	},
+	"color-index": {
+		Values: []string{"1", "256"}, Range: true,
+		Doc: "The number of entries in the screen's color lookup table, 0 on " +
+			"most screens.",
+	},
+	"monochrome": {
+		Values: []string{"0", "1", "8"}, Range: true,
+		Doc: "The bits per pixel on monochrome screens, 0 on color ones. " +
+			"`(monochrome)` matches many e-ink readers.",
+	},
+	"color-gamut": {
+		Values: []string{"srgb", "p3", "rec2020"}, Limited: true,
+		Doc: "The range of colors the screen can show. Reading systems rarely " +
+			"report it.",
+	},
+	"dynamic-range": {
+		Values: []string{"standard", "high"}, Limited: true,
+		Doc: "Whether the screen supports high dynamic range. Reading systems " +
+			"rarely report it.",
+	},
+	"video-dynamic-range": {
+		Values: []string{"standard", "high"}, Limited: true,
+		Doc: "Whether video can be shown in high dynamic range. Reading systems " +
+			"rarely report it.",
+	},
+	"grid": {
+		Values: []string{"0", "1"}, Limited: true,
+		Doc: "Whether the device is a grid device such as a terminal. No " +
+			"reading system is.",
+	},
+	"scan": {
+		Values: []string{"interlace", "progressive"}, Limited: true,
+		Doc: "The scanning process of the output device. Reading systems do " +
+			"not report it.",
+	},
+	"update": {
+		Values: []string{"none", "slow", "fast"}, Limited: true,
+		Doc: "How quickly the screen can change its content, `slow` on e-ink. " +
+			"Few reading systems report it.",
+	},
+	"overflow-block": {
+		Values: []string{"none", "scroll", "paged"}, Limited: true,
+		Doc: "How content that overflows the viewport vertically is handled. " +
+			"Reading systems that paginate seldom report `paged`.",
+	},
+	"overflow-inline": {
+		Values: []string{"none", "scroll"}, Limited: true,
+		Doc: "Whether content that overflows the viewport horizontally can be " +
+			"scrolled. Few reading systems report it.",
+	},
+	"hover": {
+		Values: []string{"none", "hover"}, Limited: true,
+		Doc: "Whether the primary pointer can hover. Touch reading devices " +
+			"report `none`, and desktop apps `hover`, so rules gated on it " +
+			"apply on some devices only.",
+	},
+	"any-hover": {
+		Values: []string{"none", "hover"}, Limited: true,
+		Doc: "Whether any pointer can hover. It varies by device and rarely " +
+			"matters for reading.",
+	},
+	"pointer": {
+		Values: []string{"none", "coarse", "fine"}, Limited: true,
+		Doc: "The accuracy of the primary pointer: `coarse` for touch, `fine` " +
+			"for a mouse. It varies by device, and e-ink readers may report " +
+			"`none`.",
+	},
+	"any-pointer": {
+		Values: []string{"none", "coarse", "fine"}, Limited: true,
+		Doc: "The accuracy of the most accurate pointer. It varies by device " +
+			"and rarely matters for reading.",
+	},
+	"prefers-color-scheme": {
+		Values: []string{"light", "dark"}, Limited: true,
+		Doc: "The system's light or dark appearance. Reading systems apply " +
+			"their own themes, usually by overriding colors, and may report the " +
+			"system setting, the reading theme, or always `light`, so dark " +
+			"styles can clash with a sepia or night theme. Prefer leaving text " +
+			"and background colors to the reading system.",
+	},
+	"prefers-contrast": {
+		Values: []string{"no-preference", "more", "less", "custom"}, Limited: true,
+		Doc: "Whether the reader asked for more or less contrast. Few reading " +
+			"systems pass it on.",
+	},
+	"prefers-reduced-motion": {
+		Values: []string{"no-preference", "reduce"},
+		Doc: "Whether the reader asked for less animation. Supported by " +
+			"reading systems built on browser engines; respect it in any " +
+			"animation.",
+	},
+	"prefers-reduced-transparency": {
+		Values: []string{"no-preference", "reduce"}, Limited: true,
+		Doc: "Whether the reader asked for less transparency. Few reading " +
+			"systems pass it on.",
+	},
+	"prefers-reduced-data": {
+		Values: []string{"no-preference", "reduce"}, Limited: true,
+		Doc: "Whether the reader asked to use less data. Reading systems do " +
+			"not report it, and a book's resources are local anyway.",
+	},
+	"forced-colors": {
+		Values: []string{"none", "active"}, Limited: true,
+		Doc: "Whether the system forces a limited color palette, as Windows " +
+			"high contrast mode does. Few reading systems report it.",
+	},
+	"inverted-colors": {
+		Values: []string{"none", "inverted"}, Limited: true,
+		Doc: "Whether the system inverts colors. Few reading systems report it.",
+	},
+	"scripting": {
+		Values: []string{"none", "initial-only", "enabled"}, Limited: true,
+		Doc: "Whether scripts run. Many reading systems do not run scripts and " +
+			"still report `enabled`.",
+	},
+	"display-mode": {
+		Values:  []string{"fullscreen", "standalone", "minimal-ui", "browser"},
+		Limited: true,
+		Doc: "How a web application is displayed. It has no meaning in a " +
+			"reading system.",
+	},
+	"device-width": {
+		Values: []string{"30em", "600px"}, Range: true, Limited: true,
+		Doc: "The width of the screen. Deprecated: use `width`, which is the " +
+			"page the content is laid out in.",
+	},
+	"device-height": {
+		Values: []string{"40em", "800px"}, Range: true, Limited: true,
+		Doc: "The height of the screen. Deprecated: use `height`.",
+	},
+	"device-aspect-ratio": {
+		Values: []string{"3/4", "16/9"}, Range: true, Limited: true,
+		Doc: "The width-to-height ratio of the screen. Deprecated: use " +
+			"`aspect-ratio`.",
+	},
+}
+
+// LookupMediaFeature returns the description of a media feature name,
+// which may have a min- or max- prefix for range features. Names are
+// matched without regard to case.
+func LookupMediaFeature(name string) (MediaFeatureInfo, bool) {
+	name = strings.ToLower(name)
+	if info, ok := mediaFeatures[name]; ok {
+		return info, true
+	}
+	for _, prefix := range []string{"min-", "max-"} {
+		if base, ok := strings.CutPrefix(name, prefix); ok {
+			if info, ok := mediaFeatures[base]; ok && info.Range {
+				return info, true
+			}
+		}
+	}
+	return MediaFeatureInfo{}, false
+}
+
+// MediaFeatureNames returns the media feature names in alphabetical order,
+// including the min- and max- forms of range features.
+func MediaFeatureNames() []string {
+	var names []string
+	for name, info := range mediaFeatures {
+		names = append(names, name)
+		if info.Range {
+			names = append(names, "min-"+name, "max-"+name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// validateMediaQueries checks the feature names in the preludes of @media
+// rules: unknown names, usually typos that disable the whole block, are
+// errors, and features reading systems support poorly are reported as
+// information. Vendor-prefixed names are skipped.
+func validateMediaQueries(content []byte, atRules []parser.CSSAtRule) []epub.Diagnostic {
+	var lines *epub.LineIndex
+	var diags []epub.Diagnostic
+	for _, at := range atRules {
+		if !strings.EqualFold(at.Name, "@media") {
+			continue
+		}
+		for _, f := range parser.ParseMediaQuery(at.Prelude) {
+			if strings.HasPrefix(f.Name, "-") {
+				continue
+			}
+			if lines == nil {
+				lines = epub.NewLineIndex(content)
+			}
+			start := at.PreludeOffset + f.Offset
+			b := epub.NewDiagAt(lines, start, source).
+				End(lines.Position(start + len(f.Name)))
+
+			info, known := LookupMediaFeature(f.Name)
+			switch {
+			case !known:
+				msg := "unknown media feature \"" + f.Name + "\"; the rules it " +
+					"guards never apply"
+				suggestion := suggestMediaFeature(f.Name)
+				if suggestion != "" {
+					msg += "; did you mean \"" + suggestion + "\"?"
+				}
+				b.Code("css-media-feature-unknown").Error(msg)
+				if suggestion != "" {
+					b.Fix("Replace with \""+suggestion+"\"",
+						epub.AnchorReplaceRange, suggestion)
+				}
+			case info.Limited:
+				b.Code("css-media-feature-limited").
+					Info("media feature \"" + f.Name + "\" is poorly supported " +
+						"by reading systems")
+			default:
+				continue
+			}
+			diags = append(diags, b.Build())
+		}
+	}
+	return diags
+}
+
+// suggestMediaFeature returns the media feature name closest to name within
+// maxSuggestionDistance edits, preferring the alphabetically first of
+// equally close names, or "" if none is close enough.
+func suggestMediaFeature(name string) string {
+	name = strings.ToLower(name)
+	best, bestDistance := "", maxSuggestionDistance+1
+	for _, known := range MediaFeatureNames() {
+		if d := epub.Levenshtein(name, known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
@@ -0,0 +1,69 @@
+package css
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestMediaFeatureTypo(t *testing.T) {
+	content := []byte("p { margin: 0 }\n" +
+		"@media screen and (min-widht: 30em) {\n  p { margin: 1em }\n}\n")
+	diags := (&Validator{}).Validate("file:///book/style.css", content, nil)
+
+	if len(diags) != 1 || diags[0].Code != "css-media-feature-unknown" {
+		t.Fatalf("expected one css-media-feature-unknown, got %v",
+			testutil.DiagCodes(diags))
+	}
+	d := diags[0]
+	if d.Severity != epub.SeverityError ||
+		!strings.Contains(d.Message, `did you mean "min-width"`) {
+		t.Errorf("diagnostic = %+v", d)
+	}
+	want := epub.Range{
+		Start: epub.Position{Line: 1, Character: 19},
+		End:   epub.Position{Line: 1, Character: 28},
+	}
+	if d.Range != want {
+		t.Errorf("range = %+v, want %+v", d.Range, want)
+	}
+	if d.Fix == nil || d.Fix.InsertText != "min-width" {
+		t.Errorf("fix = %+v, want a replacement with min-width", d.Fix)
+	}
+}
+
+func TestMediaFeatureLimited(t *testing.T) {
+	content := []byte(`@media (hover: hover) and (min-width: 30em),
+    (prefers-color-scheme: dark) {}
+@media (-webkit-min-device-pixel-ratio: 2), (MAX-WIDTH: 40em),
+    (orientation: portrait) {}
+@supports (display: grid) {}`)
+	diags := (&Validator{}).Validate("file:///book/style.css", content, nil)
+
+	got := testutil.DiagCodes(diags)
+	if len(diags) != 2 || diags[0].Severity != epub.SeverityInfo ||
+		!strings.Contains(diags[0].Message, `"hover"`) ||
+		!strings.Contains(diags[1].Message, `"prefers-color-scheme"`) {
+		t.Fatalf("expected hover and prefers-color-scheme reported, got %v", got)
+	}
+	for _, d := range diags {
+		if d.Code != "css-media-feature-limited" {
+			t.Errorf("code = %s, want css-media-feature-limited", d.Code)
+		}
+	}
+}
+
+func TestLookupMediaFeature(t *testing.T) {
+	for _, name := range []string{"width", "min-width", "MAX-Aspect-Ratio", "hover"} {
+		if _, ok := LookupMediaFeature(name); !ok {
+			t.Errorf("%s: expected a known feature", name)
+		}
+	}
+	for _, name := range []string{"min-hover", "widht", "min-"} {
+		if _, ok := LookupMediaFeature(name); ok {
+			t.Errorf("%s: expected an unknown feature", name)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package css
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// cssSpace holds the whitespace characters of CSS.
+const cssSpace = " \t\n\r\f"
+
+// varReferences returns the custom properties that var() calls in value
+// read without a fallback. A call with a fallback is skipped, but var()
+// calls within the fallback are not.
+func varReferences(value string) []string {
+	var refs []string
+	lower := strings.ToLower(value)
+	for i := 0; i < len(value); i++ {
+		if !strings.HasPrefix(lower[i:], "var(") ||
+			i > 0 && (lower[i-1] == '-' || lower[i-1] >= 'a' && lower[i-1] <= 'z') {
+			continue
+		}
+		start := i + 4
+		for start < len(value) && strings.IndexByte(cssSpace, value[start]) >= 0 {
+			start++
+		}
+		end := len(value)
+		if j := strings.IndexAny(value[start:], cssSpace+",)"); j >= 0 {
+			end = start + j
+		}
+		name := value[start:end]
+		fallback := strings.HasPrefix(strings.TrimLeft(value[end:], cssSpace), ",")
+		if strings.HasPrefix(name, "--") && !fallback {
+			refs = append(refs, name)
+		}
+		i = max(end-1, i)
+	}
+	return refs
+}
+
+// declaredCustomProperties returns the custom properties declared under any
+// selector in all stylesheets of the workspace, reading the stylesheet at
+// uri from content.
+func declaredCustomProperties(
+	uri string,
+	content []byte,
+	ctx *validator.WorkspaceContext,
+) map[string]bool {
+	declared := make(map[string]bool)
+	add := func(content []byte) {
+		props, _, _ := parser.ScanCSS(content)
+		for _, prop := range props {
+			if strings.HasPrefix(prop.Property, "--") {
+				declared[prop.Property] = true
+			}
+		}
+	}
+
+	add(content)
+	for other, data := range ctx.Files {
+		if other == uri {
+			continue
+		}
+		fileType, ok := ctx.FileTypes[other]
+		if !ok {
+			fileType = epub.DetectFileType(other, data)
+		}
+		if fileType == epub.FileTypeCSS {
+			add(data)
+		}
+	}
+	return declared
+}
+
+// validateVariables checks that var() calls without a fallback read custom
+// properties declared somewhere in the workspace. Custom property names are
+// case-sensitive.
+func validateVariables(
+	uri string,
+	content []byte,
+	props []parser.CSSPropertyDecl,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	if ctx == nil || ctx.Files == nil {
+		return nil
+	}
+
+	lines := epub.NewLineIndex(content)
+	var diags []epub.Diagnostic
+	var declared map[string]bool
+
+	for _, prop := range props {
+		from := prop.Offset + len(prop.Property)
+		for _, name := range varReferences(prop.Value) {
+			if declared == nil {
+				declared = declaredCustomProperties(uri, content, ctx)
+			}
+			// Values are reassembled from tokens, so find the name in the source
+			start, end := prop.Offset, prop.Offset+len(prop.Property)
+			if idx := bytes.Index(content[from:], []byte(name)); idx >= 0 {
+				start, end = from+idx, from+idx+len(name)
+				from = end
+			}
+			if declared[name] {
+				continue
+			}
+			diags = append(diags, epub.NewDiagAt(lines, start, source).
+				End(lines.Position(end)).
+				Code("CSS_032").
+				Warning("custom property "+name+" is not declared in any stylesheet "+
+					"and var() has no fallback").
+				Build())
+		}
+	}
+	return diags
+}
@@ -0,0 +1,81 @@
+package css
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestVarReferences(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{`var(--brand)`, []string{"--brand"}},
+		{`VAR( --brand )`, []string{"--brand"}},
+		{`var(--brand, #000)`, nil},
+		{`var(--a , var(--b))`, []string{"--b"}},
+		{`1px solid var(--rule) var(--other)`, []string{"--rule", "--other"}},
+		{`somevar(--x)`, nil},
+	}
+	for _, tt := range tests {
+		if got := varReferences(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("varReferences(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCustomPropertyDeclarations(t *testing.T) {
+	theme := `:root {
+  --brand-color: #336;
+}
+.dark { --rule: 1px solid white; }`
+
+	tests := []struct {
+		name string
+		rule string
+		want bool
+	}{
+		{"declared under :root", `a { color: var(--brand-color) }`, false},
+		{"declared under a class", `hr { border-top: var(--rule) }`, false},
+		{"declared in the same stylesheet", `p { --gap: 1em; margin: var(--gap) }`, false},
+		{"undeclared", `a { color: var(--accent) }`, true},
+		{"undeclared with fallback", `a { color: var(--accent, #000) }`, false},
+		{"names are case-sensitive", `a { color: var(--Brand-Color) }`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := importContext(map[string]string{
+				"file:///book/OEBPS/css/theme.css": theme,
+				"file:///book/OEBPS/css/main.css":  tt.rule,
+			})
+			uri := "file:///book/OEBPS/css/main.css"
+			diags := (&Validator{}).Validate(uri, ctx.Files[uri], ctx)
+			if got := testutil.HasCode(diags, "CSS_032"); got != tt.want {
+				t.Errorf("CSS_032 = %v, want %v (%v)", got, tt.want, diags)
+			}
+		})
+	}
+}
+
+func TestUndeclaredCustomPropertyRange(t *testing.T) {
+	ctx := importContext(map[string]string{
+		"file:///book/OEBPS/css/main.css": "a {\n  color: var(--accent);\n}",
+	})
+	uri := "file:///book/OEBPS/css/main.css"
+	diags := (&Validator{}).Validate(uri, ctx.Files[uri], ctx)
+
+	if len(diags) != 1 || diags[0].Code != "CSS_032" {
+		t.Fatalf("expected one CSS_032, got %v", diags)
+	}
+	want := epub.Range{
+		Start: epub.Position{Line: 1, Character: 13},
+		End:   epub.Position{Line: 1, Character: 21},
+	}
+	if diags[0].Range != want {
+		t.Errorf("range = %+v, want %+v", diags[0].Range, want)
+	}
+}
@@ -0,0 +1,52 @@
+package validator
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// diagKey identifies diagnostics that report the same issue.
+type diagKey struct {
+	code, message string
+	rng           epub.Range
+}
+
+// Dedupe removes diagnostics that several validators report for the same
+// issue. Exact duplicates (same code, range, and message) are dropped, and
+// XML well-formedness errors at the same position are collapsed into one,
+// preferring a validator's own source over the generic parser source.
+// Order of first occurrence is preserved.
+func Dedupe(diags []epub.Diagnostic) []epub.Diagnostic {
+	if len(diags) < 2 {
+		return diags
+	}
+
+	out := make([]epub.Diagnostic, 0, len(diags))
+	seen := make(map[diagKey]bool, len(diags))
+	wellFormedness := make(map[epub.Position]int)
+
+	for _, d := range diags {
+		if strings.HasPrefix(d.Message, parser.WellFormednessError) {
+			if i, ok := wellFormedness[d.Range.Start]; ok {
+				if out[i].Source == parser.Source && d.Source != parser.Source {
+					out[i] = d
+				}
+				continue
+			}
+			wellFormedness[d.Range.Start] = len(out)
+			out = append(out, d)
+			continue
+		}
+
+		key := diagKey{code: d.Code, message: d.Message, rng: d.Range}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, d)
+	}
+
+	return out
+}
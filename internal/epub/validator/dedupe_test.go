@@ -0,0 +1,142 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+func diagAt(line, char int, code, message, source string) epub.Diagnostic {
+	pos := epub.Position{Line: line, Character: char}
+	return epub.Diagnostic{
+		Code:     code,
+		Message:  message,
+		Source:   source,
+		Severity: epub.SeverityError,
+		Range:    epub.Range{Start: pos, End: pos},
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	xmlError := parser.WellFormednessError + ": unexpected EOF"
+
+	tests := []struct {
+		name string
+		in   []epub.Diagnostic
+		want []epub.Diagnostic
+	}{
+		{
+			name: "empty",
+			in:   nil,
+			want: nil,
+		},
+		{
+			name: "exact duplicate dropped",
+			in: []epub.Diagnostic{
+				diagAt(1, 2, "HTM_008", "missing alt", "epub-xhtml"),
+				diagAt(1, 2, "HTM_008", "missing alt", "epub-xhtml"),
+			},
+			want: []epub.Diagnostic{
+				diagAt(1, 2, "HTM_008", "missing alt", "epub-xhtml"),
+			},
+		},
+		{
+			name: "same code and message at different ranges kept",
+			in: []epub.Diagnostic{
+				diagAt(1, 2, "HTM_008", "missing alt", "epub-xhtml"),
+				diagAt(3, 2, "HTM_008", "missing alt", "epub-xhtml"),
+			},
+			want: []epub.Diagnostic{
+				diagAt(1, 2, "HTM_008", "missing alt", "epub-xhtml"),
+				diagAt(3, 2, "HTM_008", "missing alt", "epub-xhtml"),
+			},
+		},
+		{
+			name: "different codes at the same range kept",
+			in: []epub.Diagnostic{
+				diagAt(4, 0, "pagebreak-label", "missing label", "epub-accessibility"),
+				diagAt(4, 0, "epub-type-has-matching-role", "missing role",
+					"epub-accessibility"),
+			},
+			want: []epub.Diagnostic{
+				diagAt(4, 0, "pagebreak-label", "missing label", "epub-accessibility"),
+				diagAt(4, 0, "epub-type-has-matching-role", "missing role",
+					"epub-accessibility"),
+			},
+		},
+		{
+			name: "well-formedness errors at the same offset collapsed",
+			in: []epub.Diagnostic{
+				diagAt(2, 5, "", xmlError, parser.Source),
+				diagAt(2, 5, "", xmlError, parser.Source),
+			},
+			want: []epub.Diagnostic{
+				diagAt(2, 5, "", xmlError, parser.Source),
+			},
+		},
+		{
+			name: "specific source preferred over parser source",
+			in: []epub.Diagnostic{
+				diagAt(2, 5, "", xmlError, parser.Source),
+				diagAt(0, 0, "NAV_010", "remote link", "epub-nav"),
+				diagAt(2, 5, "", parser.WellFormednessError+": bad token", "epub-nav"),
+			},
+			want: []epub.Diagnostic{
+				diagAt(2, 5, "", parser.WellFormednessError+": bad token", "epub-nav"),
+				diagAt(0, 0, "NAV_010", "remote link", "epub-nav"),
+			},
+		},
+		{
+			name: "well-formedness errors at different offsets kept",
+			in: []epub.Diagnostic{
+				diagAt(2, 5, "", xmlError, parser.Source),
+				diagAt(7, 1, "", xmlError, parser.Source),
+			},
+			want: []epub.Diagnostic{
+				diagAt(2, 5, "", xmlError, parser.Source),
+				diagAt(7, 1, "", xmlError, parser.Source),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Dedupe(tt.in)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d diagnostics, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("diagnostic %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+type stubValidator struct {
+	diags []epub.Diagnostic
+}
+
+func (s *stubValidator) FileTypes() []epub.FileType {
+	return []epub.FileType{epub.FileTypeNav}
+}
+
+func (s *stubValidator) Validate(string, []byte, *WorkspaceContext) []epub.Diagnostic {
+	return s.diags
+}
+
+func TestValidateFileDedupesAcrossValidators(t *testing.T) {
+	xmlError := diagAt(0, 6, "", parser.WellFormednessError+": unexpected EOF",
+		parser.Source)
+
+	registry := NewRegistry()
+	registry.Register(&stubValidator{diags: []epub.Diagnostic{xmlError}})
+	registry.Register(&stubValidator{diags: []epub.Diagnostic{xmlError}})
+
+	diags := registry.ValidateFile("nav.xhtml", nil, epub.FileTypeNav, nil)
+	if len(diags) != 1 {
+		t.Errorf("expected 1 diagnostic after dedupe, got %d", len(diags))
+	}
+}
@@ -0,0 +1,112 @@
+package validator
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ManifestDiff describes how a package document changed between two
+// parses, so that re-validation can be limited to the files a change can
+// affect.
+type ManifestDiff struct {
+	// Added and Removed hold the manifest items whose id appears on only
+	// one side.
+	Added   []ManifestItem
+	Removed []ManifestItem
+	// Changed and ChangedFrom hold the new and old forms of the items whose
+	// href or media type changed, in the same order.
+	Changed     []ManifestItem
+	ChangedFrom []ManifestItem
+	// SpineChanged is set when itemrefs were added, removed, reordered, or
+	// had their linear attribute changed.
+	SpineChanged bool
+	// MetadataChanged is set when the parsed metadata changed.
+	MetadataChanged bool
+	// PackageChanged is set when the version or prefixes changed, or when
+	// items cannot be matched by id, so that any check may be affected.
+	PackageChanged bool
+}
+
+// Diff compares m with next, the manifest parsed from a later version of
+// the same package document.
+func (m *ManifestInfo) Diff(next *ManifestInfo) ManifestDiff {
+	var d ManifestDiff
+	d.PackageChanged = m.Version != next.Version || !maps.Equal(m.Prefixes, next.Prefixes)
+	d.SpineChanged = !slices.Equal(m.Spine, next.Spine)
+	d.MetadataChanged = !reflect.DeepEqual(m.Metadata, next.Metadata)
+
+	old, oldOK := itemsByID(m.Items)
+	cur, curOK := itemsByID(next.Items)
+	if !oldOK || !curOK {
+		d.PackageChanged = true
+		return d
+	}
+
+	for _, item := range next.Items {
+		prev, ok := old[item.ID]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, item)
+		case prev != item:
+			d.Changed = append(d.Changed, item)
+			d.ChangedFrom = append(d.ChangedFrom, prev)
+		}
+	}
+	for _, item := range m.Items {
+		if _, ok := cur[item.ID]; !ok {
+			d.Removed = append(d.Removed, item)
+		}
+	}
+	return d
+}
+
+// itemsByID indexes items by id. It reports false when an id is missing or
+// repeated.
+func itemsByID(items []ManifestItem) (map[string]ManifestItem, bool) {
+	byID := make(map[string]ManifestItem, len(items))
+	for _, item := range items {
+		if _, dup := byID[item.ID]; dup || item.ID == "" {
+			return nil, false
+		}
+		byID[item.ID] = item
+	}
+	return byID, true
+}
+
+// ItemsChanged reports whether manifest items were added, removed, or
+// changed.
+func (d ManifestDiff) ItemsChanged() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+// MetadataOnly reports whether nothing but the metadata changed, or nothing
+// at all.
+func (d ManifestDiff) MetadataOnly() bool {
+	return !d.PackageChanged && !d.SpineChanged && !d.ItemsChanged()
+}
+
+// String classifies the change for logging, such as
+// "items +1 -0 ~2, spine" or "metadata".
+func (d ManifestDiff) String() string {
+	var parts []string
+	if d.PackageChanged {
+		parts = append(parts, "package")
+	}
+	if d.ItemsChanged() {
+		parts = append(parts, fmt.Sprintf("items +%d -%d ~%d",
+			len(d.Added), len(d.Removed), len(d.Changed)))
+	}
+	if d.SpineChanged {
+		parts = append(parts, "spine")
+	}
+	if d.MetadataChanged {
+		parts = append(parts, "metadata")
+	}
+	if len(parts) == 0 {
+		return "unchanged"
+	}
+	return strings.Join(parts, ", ")
+}
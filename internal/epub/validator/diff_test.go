@@ -0,0 +1,117 @@
+package validator
+
+import (
+	"slices"
+	"testing"
+)
+
+func diffBase() *ManifestInfo {
+	return &ManifestInfo{
+		Version:  "3.0",
+		Prefixes: map[string]string{"rendition": "http://www.idpf.org/vocab/rendition/#"},
+		Items: []ManifestItem{
+			{ID: "nav", Href: "nav.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "ch1", Href: "ch1.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "ch2", Href: "ch2.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "css", Href: "style.css", MediaType: "text/css"},
+		},
+		Spine: []SpineItem{{IDRef: "ch1", Linear: true}, {IDRef: "ch2", Linear: true}},
+		Metadata: MetadataInfo{
+			HasTitle:              true,
+			HasLanguage:           true,
+			AccessModes:           []string{"textual"},
+			AccessibilityFeatures: []string{"tableOfContents"},
+		},
+	}
+}
+
+func TestManifestDiff(t *testing.T) {
+	tests := []struct {
+		name         string
+		edit         func(m *ManifestInfo)
+		want         string
+		metadataOnly bool
+	}{
+		{"unchanged", func(*ManifestInfo) {}, "unchanged", true},
+		{"metadata", func(m *ManifestInfo) {
+			m.Metadata.AccessibilitySummary = "Fully accessible."
+		}, "metadata", true},
+		{"metadata list", func(m *ManifestInfo) {
+			m.Metadata.AccessModes = append(m.Metadata.AccessModes, "visual")
+		}, "metadata", true},
+		{"item added", func(m *ManifestInfo) {
+			m.Items = append(m.Items, ManifestItem{ID: "img", Href: "a.png", MediaType: "image/png"})
+		}, "items +1 -0 ~0", false},
+		{"item removed", func(m *ManifestInfo) {
+			m.Items = slices.Delete(m.Items, 3, 4)
+		}, "items +0 -1 ~0", false},
+		{"href changed", func(m *ManifestInfo) {
+			m.Items[1].Href = "chapter1.xhtml"
+		}, "items +0 -0 ~1", false},
+		{"media type changed", func(m *ManifestInfo) {
+			m.Items[3].MediaType = "text/plain"
+		}, "items +0 -0 ~1", false},
+		{"items reordered", func(m *ManifestInfo) {
+			m.Items[1], m.Items[2] = m.Items[2], m.Items[1]
+		}, "unchanged", true},
+		{"spine reordered", func(m *ManifestInfo) {
+			m.Spine[0], m.Spine[1] = m.Spine[1], m.Spine[0]
+		}, "spine", false},
+		{"spine linear", func(m *ManifestInfo) {
+			m.Spine[1].Linear = false
+		}, "spine", false},
+		{"version", func(m *ManifestInfo) { m.Version = "2.0" }, "package", false},
+		{"prefixes", func(m *ManifestInfo) { m.Prefixes = nil }, "package", false},
+		{"duplicate id", func(m *ManifestInfo) {
+			m.Items[2].ID = "ch1"
+		}, "package", false},
+		{"missing id", func(m *ManifestInfo) {
+			m.Items = append(m.Items, ManifestItem{Href: "a.png"})
+		}, "package", false},
+		{"several", func(m *ManifestInfo) {
+			m.Items[1].Href = "chapter1.xhtml"
+			m.Spine = m.Spine[1:]
+			m.Metadata.HasTitle = false
+		}, "items +0 -0 ~1, spine, metadata", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := diffBase()
+			next.Items = slices.Clone(next.Items)
+			next.Spine = slices.Clone(next.Spine)
+			next.Metadata.AccessModes = slices.Clone(next.Metadata.AccessModes)
+			tt.edit(next)
+
+			d := diffBase().Diff(next)
+			if got := d.String(); got != tt.want {
+				t.Errorf("Diff = %q, want %q", got, tt.want)
+			}
+			if got := d.MetadataOnly(); got != tt.metadataOnly {
+				t.Errorf("MetadataOnly = %v, want %v", got, tt.metadataOnly)
+			}
+		})
+	}
+}
+
+func TestManifestDiffItems(t *testing.T) {
+	next := diffBase()
+	next.Items = []ManifestItem{
+		{ID: "nav", Href: "nav.xhtml", MediaType: "application/xhtml+xml"},
+		{ID: "ch1", Href: "text/ch1.xhtml", MediaType: "application/xhtml+xml"},
+		{ID: "css", Href: "style.css", MediaType: "text/css"},
+		{ID: "img", Href: "a.png", MediaType: "image/png"},
+	}
+
+	d := diffBase().Diff(next)
+	if len(d.Added) != 1 || d.Added[0].ID != "img" {
+		t.Errorf("Added = %+v, want img", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].ID != "ch2" {
+		t.Errorf("Removed = %+v, want ch2", d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Href != "text/ch1.xhtml" ||
+		len(d.ChangedFrom) != 1 || d.ChangedFrom[0].Href != "ch1.xhtml" {
+		t.Errorf("Changed = %+v from %+v, want ch1 moved to text/", d.Changed, d.ChangedFrom)
+	}
+}
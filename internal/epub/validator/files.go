@@ -0,0 +1,58 @@
+package validator
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/lsp/pathutil"
+)
+
+// ReadHead returns up to n leading bytes of the file at resolved, a URI or
+// path from uriutil.ResolveRelative. Workspace files with content are read
+// from memory. Others, such as the fonts and images listed without content
+// or not listed at all, are read from disk where DiskPath finds them. It
+// returns nil when the file cannot be read.
+func (c *WorkspaceContext) ReadHead(resolved string, n int) []byte {
+	if c == nil || resolved == "" {
+		return nil
+	}
+	if key, ok := uriutil.Lookup(c.Files, resolved); ok && len(c.Files[key]) > 0 {
+		return c.Files[key][:min(n, len(c.Files[key]))]
+	}
+
+	name := c.DiskPath(resolved)
+	if name == "" {
+		return nil
+	}
+	f, err := os.Open(name) //nolint:gosec // a publication resource
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	head := make([]byte, n)
+	read, err := io.ReadFull(f, head)
+	if err != nil && read == 0 {
+		return nil
+	}
+	return head[:read]
+}
+
+// DiskPath returns the file on disk for resolved, a URI or path from
+// uriutil.ResolveRelative: file URIs directly and relative paths under
+// RootPath. It returns "" when resolved names no local file.
+func (c *WorkspaceContext) DiskPath(resolved string) string {
+	switch {
+	case c == nil || resolved == "":
+		return ""
+	case strings.HasPrefix(resolved, "file:"):
+		return pathutil.URIToFilePath(resolved)
+	case filepath.IsAbs(filepath.FromSlash(resolved)):
+		return filepath.FromSlash(resolved)
+	case c.RootPath != "":
+		return filepath.Join(c.RootPath, filepath.FromSlash(resolved))
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package validator
+
+import (
+	"strconv"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// CodeLimiter is implemented by validators with noisy rules, to cap how many
+// diagnostics of a code are reported per file.
+type CodeLimiter interface {
+	// CodeLimits maps codes to the most diagnostics reported per file.
+	CodeLimits() map[string]int
+}
+
+// Cap keeps the first limits[code] diagnostics of each limited code and
+// replaces the rest with one summary diagnostic, placed at the first one
+// dropped, that says how many more there are. Codes without a positive
+// limit are kept whole. Order is otherwise preserved.
+func Cap(diags []epub.Diagnostic, limits map[string]int) []epub.Diagnostic {
+	if len(limits) == 0 {
+		return diags
+	}
+
+	counts := make(map[string]int)
+	for _, d := range diags {
+		counts[d.Code]++
+	}
+
+	seen := make(map[string]int)
+	out := diags[:0:0]
+	for _, d := range diags {
+		limit := limits[d.Code]
+		if limit <= 0 || counts[d.Code] <= limit {
+			out = append(out, d)
+			continue
+		}
+		seen[d.Code]++
+		switch n := seen[d.Code]; {
+		case n <= limit:
+			out = append(out, d)
+		case n == limit+1:
+			d.Message = "and " + strconv.Itoa(counts[d.Code]-limit) +
+				" more like this in this file"
+			d.Fix = nil
+			out = append(out, d)
+		}
+	}
+	return out
+}
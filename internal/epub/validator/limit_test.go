@@ -0,0 +1,49 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+func TestCap(t *testing.T) {
+	var diags []epub.Diagnostic
+	for i := range 25 {
+		d := diagAt(i, 0, "noisy", "noisy thing", "test")
+		d.Fix = &epub.FixData{Title: "Remove"}
+		diags = append(diags, d)
+		if i == 3 {
+			diags = append(diags, diagAt(i, 1, "other", "other thing", "test"))
+		}
+	}
+
+	got := Cap(diags, map[string]int{"noisy": 20, "other": 1})
+	if len(got) != 22 {
+		t.Fatalf("expected 20 kept, 1 summary, and 1 other, got %d", len(got))
+	}
+	if got[4].Code != "other" {
+		t.Errorf("expected order to be preserved, got %s at 4", got[4].Code)
+	}
+
+	summary := got[21]
+	if summary.Code != "noisy" || summary.Message != "and 5 more like this in this file" {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if summary.Range.Start.Line != 20 || summary.Fix != nil {
+		t.Errorf("expected the summary at the first one dropped, without a fix: %+v",
+			summary)
+	}
+}
+
+func TestCapWithinLimit(t *testing.T) {
+	diags := []epub.Diagnostic{
+		diagAt(0, 0, "noisy", "noisy thing", "test"),
+		diagAt(1, 0, "noisy", "noisy thing", "test"),
+	}
+	if got := Cap(diags, map[string]int{"noisy": 2}); len(got) != 2 {
+		t.Errorf("expected diagnostics within the limit to be kept, got %v", got)
+	}
+	if got := Cap(diags, nil); len(got) != 2 {
+		t.Errorf("expected no limits to keep everything, got %v", got)
+	}
+}
@@ -2,8 +2,12 @@
 package nav
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
 	"github.com/toba/epub-lsp/internal/epub/validator"
 )
 
@@ -17,7 +21,7 @@ func (v *Validator) FileTypes() []epub.FileType {
 }
 
 func (v *Validator) Validate(
-	_ string,
+	uri string,
 	content []byte,
 	ctx *validator.WorkspaceContext,
 ) []epub.Diagnostic {
@@ -26,12 +30,18 @@ func (v *Validator) Validate(
 		return diags
 	}
 
-	diags = append(diags, validateTocNav(content, root)...)
-	diags = append(diags, validateNavLinks(content, root)...)
-	diags = append(diags, validateNavTypes(content, root)...)
+	lines := epub.NewLineIndex(content)
+	// EPUB 2 reading systems navigate by the NCX, so a toc nav is optional
+	// when one exists.
+	requireToc := !ctx.EPUB2() || ctx.Manifest == nil || ctx.Manifest.NCX() == nil
+	diags = append(diags, validateTocNav(lines, root, requireToc)...)
+	diags = append(diags, validateNavLinks(content, lines, root)...)
+	diags = append(diags, validateNavTypes(lines, root)...)
+
+	diags = append(diags, validateTocTargets(lines, uri, root, ctx)...)
 
 	if ctx != nil && ctx.Manifest != nil {
-		diags = append(diags, validateTocSpineOrder(content, root, ctx)...)
+		diags = append(diags, validateTocSpineOrder(lines, uri, root, ctx)...)
 	}
 
 	return diags
@@ -47,8 +57,13 @@ func getEpubType(node *parser.XMLNode) string {
 	return node.AttrNS(epub.NSEpub, "type")
 }
 
-// validateTocNav checks that a <nav epub:type="toc"> element exists and has an <ol>.
-func validateTocNav(content []byte, root *parser.XMLNode) []epub.Diagnostic {
+// validateTocNav checks that a <nav epub:type="toc"> element exists, when
+// required, and has an <ol>.
+func validateTocNav(
+	lines *epub.LineIndex,
+	root *parser.XMLNode,
+	requireToc bool,
+) []epub.Diagnostic {
 	var diags []epub.Diagnostic
 
 	navs := findNavElements(root)
@@ -62,13 +77,16 @@ func validateTocNav(content []byte, root *parser.XMLNode) []epub.Diagnostic {
 	}
 
 	if tocNav == nil {
+		if !requireToc {
+			return diags
+		}
 		// Report at document root
 		html := root.FindFirst("html")
 		offset := 0
 		if html != nil {
 			offset = int(html.Offset)
 		}
-		diags = append(diags, epub.NewDiag(content, offset, source).
+		diags = append(diags, epub.NewDiagAt(lines, offset, source).
 			Code("NAV_003").Error(`no <nav epub:type="toc"> element found`).Build())
 		return diags
 	}
@@ -76,7 +94,7 @@ func validateTocNav(content []byte, root *parser.XMLNode) []epub.Diagnostic {
 	// Check for <ol> inside toc nav
 	ol := tocNav.FindFirst("ol")
 	if ol == nil {
-		diags = append(diags, epub.NewDiag(content, int(tocNav.Offset), source).
+		diags = append(diags, epub.NewDiagAt(lines, int(tocNav.Offset), source).
 			Warning("toc nav is missing required <ol> element").Build())
 	}
 
@@ -84,7 +102,11 @@ func validateTocNav(content []byte, root *parser.XMLNode) []epub.Diagnostic {
 }
 
 // validateNavLinks checks that nav links don't reference remote resources.
-func validateNavLinks(content []byte, root *parser.XMLNode) []epub.Diagnostic {
+func validateNavLinks(
+	content []byte,
+	lines *epub.LineIndex,
+	root *parser.XMLNode,
+) []epub.Diagnostic {
 	var diags []epub.Diagnostic
 
 	links := root.FindAll("a")
@@ -94,7 +116,12 @@ func validateNavLinks(content []byte, root *parser.XMLNode) []epub.Diagnostic {
 			continue
 		}
 		if epub.IsRemoteURL(href) {
-			diags = append(diags, epub.NewDiag(content, int(a.Offset), source).
+			start, end, ok := parser.AttrValueRange(content, a, "href")
+			if !ok {
+				start, end = int(a.Offset), int(a.Offset)
+			}
+			diags = append(diags, epub.NewDiagAt(lines, start, source).
+				End(lines.Position(end)).
 				Code("NAV_010").Error("nav links to remote resource: "+href).Build())
 		}
 	}
@@ -102,8 +129,69 @@ func validateNavLinks(content []byte, root *parser.XMLNode) []epub.Diagnostic {
 	return diags
 }
 
+// validateTocTargets checks that toc entries don't link to the same target
+// twice and that each linked file is a content document.
+func validateTocTargets(
+	lines *epub.LineIndex,
+	uri string,
+	root *parser.XMLNode,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+
+	var tocNav *parser.XMLNode
+	for _, nav := range findNavElements(root) {
+		if getEpubType(nav) == "toc" {
+			tocNav = nav
+			break
+		}
+	}
+	if tocNav == nil {
+		return diags
+	}
+
+	seen := make(map[string]bool)
+
+	for _, a := range tocNav.FindAll("a") {
+		href := strings.TrimSpace(a.Attr("href"))
+		if href == "" || epub.IsRemoteURL(href) {
+			continue
+		}
+
+		filePart, fragment, _ := strings.Cut(href, "#")
+		resolved := uriutil.Path(uriutil.ResolveRelative(uri, filePart))
+
+		target := resolved + "#" + fragment
+		if seen[target] {
+			diags = append(diags, epub.NewDiagAt(lines, int(a.Offset), source).
+				Code("NAV_013").
+				Warning("duplicate TOC entry for "+href).Build())
+		}
+		seen[target] = true
+
+		if filePart == "" || ctx == nil || ctx.Manifest == nil {
+			continue
+		}
+		item := ctx.Manifest.ItemByPath(resolved)
+		if item != nil && !isContentDocumentType(item.MediaType) {
+			diags = append(diags, epub.NewDiagAt(lines, int(a.Offset), source).
+				Code("NAV_014").
+				Error("TOC entry links to non-content resource "+href+
+					" ("+item.MediaType+")").Build())
+		}
+	}
+
+	return diags
+}
+
+// isContentDocumentType reports whether mediaType is a content document
+// that can appear in the spine.
+func isContentDocumentType(mediaType string) bool {
+	return mediaType == "application/xhtml+xml" || mediaType == "image/svg+xml"
+}
+
 // validateNavTypes checks for informational nav types.
-func validateNavTypes(content []byte, root *parser.XMLNode) []epub.Diagnostic {
+func validateNavTypes(lines *epub.LineIndex, root *parser.XMLNode) []epub.Diagnostic {
 	var diags []epub.Diagnostic
 
 	navs := findNavElements(root)
@@ -126,16 +214,22 @@ func validateNavTypes(content []byte, root *parser.XMLNode) []epub.Diagnostic {
 		if html != nil {
 			offset = int(html.Offset)
 		}
-		diags = append(diags, epub.NewDiag(content, offset, source).
+		diags = append(diags, epub.NewDiagAt(lines, offset, source).
 			Info("navigation document has no page-list or landmarks nav").Build())
 	}
 
 	return diags
 }
 
-// validateTocSpineOrder checks that TOC link order matches spine order.
+// validateTocSpineOrder checks that TOC entries follow spine order. Entries
+// are compared by the spine item their file resolves to, relative to the
+// navigation document, and only an item's first entry places it: later
+// links back into a chapter, such as to one of its sections, are not out
+// of order. Entries for non-linear spine items, fragment-only links, and
+// links outside the spine are skipped.
 func validateTocSpineOrder(
-	content []byte,
+	lines *epub.LineIndex,
+	uri string,
 	root *parser.XMLNode,
 	ctx *validator.WorkspaceContext,
 ) []epub.Diagnostic {
@@ -154,65 +248,56 @@ func validateTocSpineOrder(
 		return diags
 	}
 
-	// Extract hrefs from toc nav links (in order)
-	tocHrefs := extractNavHrefs(tocNav)
-	if len(tocHrefs) == 0 {
-		return diags
-	}
-
-	// Build spine order: idref -> index, then manifest id -> href
-	idToHref := make(map[string]string)
-	for _, item := range ctx.Manifest.Items {
-		idToHref[item.ID] = item.Href
-	}
-
-	spineHrefOrder := make([]string, 0, len(ctx.Manifest.Spine))
-	for _, s := range ctx.Manifest.Spine {
-		if href, ok := idToHref[s.IDRef]; ok {
-			spineHrefOrder = append(spineHrefOrder, href)
+	// Map manifest id to spine position, leaving out non-linear items
+	spineIndex := make(map[string]int)
+	for i, s := range ctx.Manifest.Spine {
+		if s.Linear {
+			spineIndex[s.IDRef] = i
 		}
 	}
 
-	// Map spine href to index
-	spineIndex := make(map[string]int)
-	for i, href := range spineHrefOrder {
-		spineIndex[href] = i
+	type tocEntry struct {
+		href  string
+		spine int
 	}
+	var (
+		placed []tocEntry
+		seen   = make(map[int]bool)
+	)
+	for _, a := range tocNav.FindAll("a") {
+		href := strings.TrimSpace(a.Attr("href"))
+		filePart, _, _ := strings.Cut(href, "#")
+		if filePart == "" || epub.IsRemoteURL(href) {
+			continue
+		}
+		item := ctx.Manifest.ItemByPath(uriutil.Path(uriutil.ResolveRelative(uri, filePart)))
+		if item == nil {
+			continue
+		}
+		si, ok := spineIndex[item.ID]
+		if !ok || seen[si] {
+			continue
+		}
+		seen[si] = true
 
-	// Check that toc hrefs are in non-decreasing spine order
-	lastSpineIdx := -1
-	for _, tocHref := range tocHrefs {
-		base := epub.StripFragment(tocHref)
-
-		if si, ok := spineIndex[base]; ok {
-			if si < lastSpineIdx {
-				diags = append(diags, epub.NewDiag(content, int(tocNav.Offset), source).
-					Code("NAV_011").
-					Warning("TOC link order doesn't match spine order").Build())
+		// The entry belongs before the first placed entry later in the spine
+		var before *tocEntry
+		for i := range placed {
+			if placed[i].spine > si {
+				before = &placed[i]
 				break
 			}
-			lastSpineIdx = si
 		}
+		if before == nil {
+			placed = append(placed, tocEntry{href, si})
+			continue
+		}
+		diags = append(diags, epub.NewDiagAt(lines, int(a.Offset), source).
+			Code("NAV_011").
+			Warning(fmt.Sprintf("TOC entry %s is spine item %d but comes after %s "+
+				"(spine item %d); expected it before that entry",
+				href, si+1, before.href, before.spine+1)).Build())
 	}
 
 	return diags
 }
-
-// extractNavHrefs returns all href values from <a> elements within a nav, in order.
-func extractNavHrefs(nav *parser.XMLNode) []string {
-	var hrefs []string
-	var walk func(node *parser.XMLNode)
-	walk = func(node *parser.XMLNode) {
-		for _, child := range node.Children {
-			if child.Local == "a" {
-				href := child.Attr("href")
-				if href != "" {
-					hrefs = append(hrefs, href)
-				}
-			}
-			walk(child)
-		}
-	}
-	walk(nav)
-	return hrefs
-}
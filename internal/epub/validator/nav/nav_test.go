@@ -1,6 +1,8 @@
 package nav
 
 import (
+	"slices"
+	"strconv"
 	"testing"
 
 	"github.com/toba/epub-lsp/internal/epub"
@@ -108,7 +110,15 @@ func TestRemoteNavLink(t *testing.T) {
 	diags := v.Validate("nav.xhtml", content, nil)
 
 	if !testutil.HasCode(diags, "NAV_010") {
-		t.Error("expected NAV_010 for remote link")
+		t.Fatal("expected NAV_010 for remote link")
+	}
+	// The range covers the href value
+	for _, d := range diags {
+		if d.Code == "NAV_010" {
+			if r := d.Range; r.Start.Line != 7 || r.Start.Character != 19 || r.End.Character != 47 {
+				t.Errorf("range = %+v, want the href value on line 7", r)
+			}
+		}
 	}
 }
 
@@ -210,3 +220,264 @@ func TestTocSpineOrderMatch(t *testing.T) {
 		t.Error("unexpected NAV_011 when TOC matches spine order")
 	}
 }
+
+// tocNavDocument wraps toc list items in a navigation document.
+func tocNavDocument(items string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en">
+<head><title>Navigation</title></head>
+<body>
+  <nav epub:type="toc">
+    <ol>
+` + items + `
+    </ol>
+  </nav>
+</body>
+</html>`)
+}
+
+// spineManifest returns a manifest whose spine lists hrefs in order, with
+// ids ch1, ch2, ... and the items named in nonLinear marked linear="no".
+func spineManifest(hrefs []string, nonLinear ...string) *validator.ManifestInfo {
+	m := &validator.ManifestInfo{}
+	for i, href := range hrefs {
+		id := "ch" + strconv.Itoa(i+1)
+		m.Items = append(m.Items, validator.ManifestItem{
+			ID: id, Href: href, MediaType: "application/xhtml+xml",
+		})
+		m.Spine = append(m.Spine, validator.SpineItem{
+			IDRef: id, Linear: !slices.Contains(nonLinear, id),
+		})
+	}
+	return m
+}
+
+func TestTocSpineOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		navURI    string
+		hrefs     []string
+		nonLinear []string
+		items     string
+		want      int
+	}{
+		{
+			"fragments and return to an earlier chapter", "file:///book/OEBPS/nav.xhtml",
+			[]string{"ch1.xhtml", "ch2.xhtml", "appendix.xhtml"}, nil, `
+      <li><a href="ch1.xhtml#sec1">Section 1</a></li>
+      <li><a href="ch1.xhtml#sec2">Section 2</a></li>
+      <li><a href="ch2.xhtml">Chapter 2</a></li>
+      <li><a href="appendix.xhtml">Appendix</a>
+        <ol><li><a href="ch1.xhtml#sec1">See section 1</a></li></ol></li>
+      <li><a href="#landmarks">Landmarks</a></li>`, 0,
+		},
+		{
+			"nav in a subfolder", "file:///book/OEBPS/nav/nav.xhtml",
+			[]string{"text/ch1.xhtml", "text/ch2.xhtml"}, nil, `
+      <li><a href="../text/ch1.xhtml">Chapter 1</a></li>
+      <li><a href="../text/ch2.xhtml">Chapter 2</a></li>`, 0,
+		},
+		{
+			"non-linear item interleaved", "file:///book/OEBPS/nav.xhtml",
+			[]string{"ch1.xhtml", "ch2.xhtml", "notes.xhtml"}, []string{"ch3"}, `
+      <li><a href="ch1.xhtml">Chapter 1</a></li>
+      <li><a href="notes.xhtml">Notes</a></li>
+      <li><a href="ch2.xhtml">Chapter 2</a></li>`, 0,
+		},
+		{
+			"reordered", "file:///book/OEBPS/nav/nav.xhtml",
+			[]string{"text/ch1.xhtml", "text/ch2.xhtml", "text/ch3.xhtml"}, nil, `
+      <li><a href="../text/ch1.xhtml">Chapter 1</a></li>
+      <li><a href="../text/ch3.xhtml">Chapter 3</a></li>
+      <li><a href="../text/ch2.xhtml#start">Chapter 2</a></li>`, 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &validator.WorkspaceContext{Manifest: spineManifest(tt.hrefs, tt.nonLinear...)}
+			diags := (&Validator{}).Validate(tt.navURI, tocNavDocument(tt.items), ctx)
+
+			var got []epub.Diagnostic
+			for _, d := range diags {
+				if d.Code == "NAV_011" {
+					got = append(got, d)
+				}
+			}
+			if len(got) != tt.want {
+				t.Fatalf("expected %d NAV_011, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTocSpineOrderNamesEntry(t *testing.T) {
+	content := tocNavDocument(`
+      <li><a href="ch1.xhtml">Chapter 1</a></li>
+      <li><a href="ch3.xhtml">Chapter 3</a></li>
+      <li><a href="ch2.xhtml">Chapter 2</a></li>`)
+	ctx := &validator.WorkspaceContext{
+		Manifest: spineManifest([]string{"ch1.xhtml", "ch2.xhtml", "ch3.xhtml"}),
+	}
+
+	diags := (&Validator{}).Validate("file:///book/nav.xhtml", content, ctx)
+	for _, d := range diags {
+		if d.Code != "NAV_011" {
+			continue
+		}
+		want := "TOC entry ch2.xhtml is spine item 2 but comes after ch3.xhtml " +
+			"(spine item 3); expected it before that entry"
+		if d.Message != want {
+			t.Errorf("message = %q, want %q", d.Message, want)
+		}
+		// The range is on the <a> for Chapter 2, on line 10
+		if d.Range.Start.Line != 9 {
+			t.Errorf("range starts on line %d, want 9", d.Range.Start.Line)
+		}
+		return
+	}
+	t.Fatal("expected NAV_011")
+}
+
+func TestDuplicateTocEntries(t *testing.T) {
+	content := tocNavDocument(`
+      <li><a href="chapter1.xhtml#s1">Section 1</a></li>
+      <li><a href="text/../chapter1.xhtml#s1">Section 1 again</a></li>
+      <li><a href="chapter1.xhtml#s2">Section 2</a></li>`)
+
+	v := &Validator{}
+	diags := v.Validate("file:///book/nav.xhtml", content, nil)
+
+	count := 0
+	for _, d := range diags {
+		if d.Code == "NAV_013" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected 1 NAV_013, got %d", count)
+	}
+}
+
+func TestTocEntryToImage(t *testing.T) {
+	content := tocNavDocument(`
+      <li><a href="chapter1.xhtml">Chapter 1</a></li>
+      <li><a href="images/cover.jpg">Cover</a></li>
+      <li><a href="#local">Local</a></li>`)
+
+	ctx := &validator.WorkspaceContext{
+		Manifest: &validator.ManifestInfo{
+			Items: []validator.ManifestItem{
+				{ID: "ch1", Href: "chapter1.xhtml", MediaType: "application/xhtml+xml"},
+				{ID: "cover", Href: "images/cover.jpg", MediaType: "image/jpeg"},
+			},
+		},
+	}
+
+	v := &Validator{}
+	diags := v.Validate("file:///book/nav.xhtml", content, ctx)
+
+	count := 0
+	for _, d := range diags {
+		if d.Code == "NAV_014" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected 1 NAV_014 for the image link, got %d", count)
+	}
+}
+
+func TestTocEntryToSVGSpineItem(t *testing.T) {
+	content := tocNavDocument(`
+      <li><a href="chapter1.xhtml">Chapter 1</a></li>
+      <li><a href="plates/plate1.svg">Plate 1</a></li>`)
+
+	ctx := &validator.WorkspaceContext{
+		Manifest: &validator.ManifestInfo{
+			Items: []validator.ManifestItem{
+				{ID: "ch1", Href: "chapter1.xhtml", MediaType: "application/xhtml+xml"},
+				{ID: "plate1", Href: "plates/plate1.svg", MediaType: "image/svg+xml"},
+			},
+			Spine: []validator.SpineItem{
+				{IDRef: "ch1", Linear: true},
+				{IDRef: "plate1", Linear: true},
+			},
+		},
+	}
+
+	v := &Validator{}
+	diags := v.Validate("file:///book/nav.xhtml", content, ctx)
+
+	if testutil.HasCode(diags, "NAV_014") {
+		t.Error("unexpected NAV_014 for SVG content document")
+	}
+	if testutil.HasCode(diags, "NAV_013") {
+		t.Error("unexpected NAV_013 for distinct entries")
+	}
+}
+
+func TestCRLFLineEndings(t *testing.T) {
+	fixtures := [][]byte{
+		tocNavDocument(`
+      <li><a href="chapter1.xhtml#s1">Section 1</a></li>
+      <li><a href="chapter1.xhtml#s1">Section 1 again</a></li>
+      <li><a href="https://example.com/chapter2">Chapter 2</a></li>`),
+		tocNavDocument(""),
+	}
+
+	for _, fixture := range fixtures {
+		v := &Validator{}
+		lf := v.Validate("file:///book/nav.xhtml", fixture, nil)
+		crlf := v.Validate("file:///book/nav.xhtml", testutil.CRLF(fixture), nil)
+		testutil.ExpectSameDiagnostics(t, lf, crlf)
+	}
+}
+
+func TestEPUB2NavWithoutToc(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en">
+<head><title>Navigation</title></head>
+<body>
+  <nav epub:type="landmarks">
+    <ol>
+      <li><a href="chapter1.xhtml">Start</a></li>
+    </ol>
+  </nav>
+</body>
+</html>`)
+
+	items := []validator.ManifestItem{
+		{ID: "ch1", Href: "chapter1.xhtml", MediaType: "application/xhtml+xml"},
+		{ID: "ncx", Href: "toc.ncx", MediaType: validator.NCXMediaType},
+	}
+
+	tests := []struct {
+		name    string
+		ctx     *validator.WorkspaceContext
+		wantToc bool
+	}{
+		{"epub3", &validator.WorkspaceContext{
+			Manifest: &validator.ManifestInfo{Version: "3.0", Items: items},
+		}, true},
+		{"epub2 package", &validator.WorkspaceContext{
+			Manifest: &validator.ManifestInfo{Version: "2.0", Items: items},
+		}, false},
+		{"epub2 setting", &validator.WorkspaceContext{
+			Manifest: &validator.ManifestInfo{Items: items},
+			Version:  "2.0",
+		}, false},
+		{"epub2 without ncx", &validator.WorkspaceContext{
+			Manifest: &validator.ManifestInfo{Version: "2.0", Items: items[:1]},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		v := &Validator{}
+		diags := v.Validate("file:///book/nav.xhtml", content, tt.ctx)
+
+		if got := testutil.HasCode(diags, "NAV_003"); got != tt.wantToc {
+			t.Errorf("%s: NAV_003 = %v, want %v", tt.name, got, tt.wantToc)
+		}
+	}
+}
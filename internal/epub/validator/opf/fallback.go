@@ -0,0 +1,113 @@
+package opf
+
+import (
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// maxFallbackDepth bounds how many fallback links a chain may follow.
+const maxFallbackDepth = 10
+
+// validateFallbacks checks manifest fallback chains. Spine items with a
+// non-core media type need a fallback, and every chain must resolve to a
+// core media type without dangling references or cycles.
+func validateFallbacks(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
+	manifest := pkg.FindFirst("manifest")
+	if manifest == nil {
+		return nil
+	}
+
+	items := make(map[string]*parser.XMLNode)
+	for _, item := range manifest.Children {
+		if item.Local == "item" {
+			if id := item.Attr("id"); id != "" {
+				items[id] = item
+			}
+		}
+	}
+
+	inSpine := make(map[string]bool)
+	if spine := pkg.FindFirst("spine"); spine != nil {
+		for _, itemref := range spine.FindAll("itemref") {
+			inSpine[itemref.Attr("idref")] = true
+		}
+	}
+
+	var diags []epub.Diagnostic
+	for _, item := range manifest.Children {
+		if item.Local != "item" {
+			continue
+		}
+
+		id := item.Attr("id")
+		fallback := item.Attr("fallback")
+		core := validator.IsCoreMediaType(item.Attr("media-type"))
+
+		if fallback == "" {
+			if !core && inSpine[id] {
+				diags = append(diags, epub.NewDiag(content, int(item.Offset), source).
+					Code("OPF_040").
+					Error("spine item \""+id+"\" has non-core media type \""+
+						item.Attr("media-type")+"\" and no fallback").
+					Build())
+			}
+			continue
+		}
+
+		// A core item's fallback only matters when it is broken
+		if diag, ok := followFallback(content, item, items); !ok || !core {
+			diags = append(diags, diag)
+		}
+	}
+
+	return diags
+}
+
+// followFallback walks the fallback chain starting at item. It reports
+// false with an error diagnostic when the chain is broken, and true with an
+// info diagnostic naming the core media type it resolves to otherwise.
+func followFallback(
+	content []byte,
+	item *parser.XMLNode,
+	items map[string]*parser.XMLNode,
+) (epub.Diagnostic, bool) {
+	id := item.Attr("id")
+	diag := epub.NewDiag(content, int(item.Offset), source)
+
+	visited := map[string]bool{id: true}
+	current := item
+	for depth := 0; ; depth++ {
+		next := current.Attr("fallback")
+		if next == "" {
+			if validator.IsCoreMediaType(current.Attr("media-type")) {
+				return diag.Code("fallback-chain").
+					Info("fallback chain for \"" + id +
+						"\" resolves to core media type \"" +
+						current.Attr("media-type") + "\"").Build(), true
+			}
+			return diag.Code("OPF_040").
+				Error("fallback chain for \"" + id +
+					"\" does not end in a core media type").Build(), false
+		}
+
+		target, ok := items[next]
+		if !ok {
+			return diag.Code("OPF_041").
+				Error("fallback references nonexistent manifest id: \"" +
+					next + "\"").Build(), false
+		}
+		if visited[next] {
+			return diag.Code("OPF_045").
+				Error("fallback chain for \"" + id + "\" contains a cycle at \"" +
+					next + "\"").Build(), false
+		}
+		if depth >= maxFallbackDepth {
+			return diag.Code("OPF_045").
+				Error("fallback chain for \"" + id + "\" is too long").Build(), false
+		}
+
+		visited[next] = true
+		current = target
+	}
+}
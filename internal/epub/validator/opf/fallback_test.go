@@ -0,0 +1,93 @@
+package opf
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+// fallbackItemref puts the item doc, which the tests declare, in the spine.
+const fallbackItemref = `    <itemref idref="doc"/>`
+
+func TestFallbackMissing(t *testing.T) {
+	content := testPackage{
+		Manifest: `    <item id="doc" href="doc.pdf" media-type="application/pdf"/>`,
+		Spine:    fallbackItemref,
+	}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	testutil.ExpectCode(t, testutil.DiagCodes(diags), "OPF_040")
+}
+
+func TestFallbackTwoStepChain(t *testing.T) {
+	content := testPackage{
+		Manifest: `
+    <item id="doc" href="doc.pdf" media-type="application/pdf" fallback="tiff"/>
+    <item id="tiff" href="doc.tiff" media-type="image/tiff" fallback="page"/>
+    <item id="page" href="doc.xhtml" media-type="application/xhtml+xml"/>`,
+		Spine: fallbackItemref,
+	}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	var info []epub.Diagnostic
+	for _, d := range diags {
+		if d.Severity == epub.SeverityError {
+			t.Errorf("unexpected error [%s] %s", d.Code, d.Message)
+		}
+		if d.Code == "fallback-chain" {
+			info = append(info, d)
+		}
+	}
+
+	// Both non-core items resolve to the XHTML page
+	if len(info) != 2 {
+		t.Fatalf("expected 2 fallback-chain diagnostics, got %d", len(info))
+	}
+	if info[0].Severity != epub.SeverityInfo {
+		t.Errorf("expected info severity, got %s",
+			testutil.SeverityName(info[0].Severity))
+	}
+}
+
+func TestFallbackCycle(t *testing.T) {
+	content := testPackage{
+		Manifest: `
+    <item id="doc" href="doc.pdf" media-type="application/pdf" fallback="tiff"/>
+    <item id="tiff" href="doc.tiff" media-type="image/tiff" fallback="doc"/>`,
+		Spine: fallbackItemref,
+	}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	count := 0
+	for _, d := range diags {
+		if d.Code == "OPF_045" {
+			count++
+		}
+	}
+	if count != 2 {
+		t.Errorf("expected OPF_045 on both items in the cycle, got %d", count)
+	}
+	if testutil.HasCode(diags, "OPF_040") {
+		t.Error("cyclic chain should not also report OPF_040")
+	}
+}
+
+func TestFallbackDangling(t *testing.T) {
+	content := testPackage{
+		Manifest: `
+    <item id="doc" href="doc.pdf" media-type="application/pdf" fallback="gone"/>`,
+		Spine: fallbackItemref,
+	}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	testutil.ExpectCode(t, testutil.DiagCodes(diags), "OPF_041")
+}
@@ -0,0 +1,109 @@
+package opf
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// ItemrefProperty is a property defined for spine itemrefs.
+type ItemrefProperty struct {
+	Name   string
+	Detail string
+	// group names the properties an itemref may have only one of.
+	group string
+}
+
+// ItemrefProperties lists the properties defined for spine itemrefs by
+// EPUB 3 and the rendition vocabulary.
+var ItemrefProperties = []ItemrefProperty{
+	{"page-spread-left", "Render on the left page of a spread", "page-spread"},
+	{"page-spread-right", "Render on the right page of a spread", "page-spread"},
+	{"rendition:page-spread-left", "Render on the left page of a spread", "page-spread"},
+	{"rendition:page-spread-right", "Render on the right page of a spread", "page-spread"},
+	{"rendition:page-spread-center", "Render centered across both pages of a spread", "page-spread"},
+	{"rendition:layout-pre-paginated", "Fixed layout", "layout"},
+	{"rendition:layout-reflowable", "Reflowable layout", "layout"},
+	{"rendition:orientation-auto", "Any device orientation", "orientation"},
+	{"rendition:orientation-landscape", "Landscape orientation", "orientation"},
+	{"rendition:orientation-portrait", "Portrait orientation", "orientation"},
+	{"rendition:spread-auto", "Reading system decides when to show spreads", "spread"},
+	{"rendition:spread-both", "Spreads in both orientations", "spread"},
+	{"rendition:spread-landscape", "Spreads in landscape orientation only", "spread"},
+	{"rendition:spread-none", "No spreads", "spread"},
+	{"rendition:spread-portrait", "Spreads in portrait orientation (deprecated)", "spread"},
+	{"rendition:flow-auto", "Reading system decides how content flows", "flow"},
+	{"rendition:flow-paginated", "Paginate overflowing content", "flow"},
+	{"rendition:flow-scrolled-continuous", "Scroll across documents", "flow"},
+	{"rendition:flow-scrolled-doc", "Scroll within each document", "flow"},
+	{"rendition:align-x-center", "Center content horizontally", ""},
+}
+
+// itemrefProperties indexes ItemrefProperties by name.
+var itemrefProperties = func() map[string]ItemrefProperty {
+	m := make(map[string]ItemrefProperty, len(ItemrefProperties))
+	for _, p := range ItemrefProperties {
+		m[p.Name] = p
+	}
+	return m
+}()
+
+// validateItemrefProperties checks the properties of spine itemrefs. Each
+// unprefixed or rendition token must be a defined property, and an itemref
+// may have only one property of each group, such as one page spread.
+// Tokens with other prefixes belong to vocabularies this validator does not
+// know.
+func validateItemrefProperties(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
+	spine := pkg.FindFirst("spine")
+	if spine == nil {
+		return nil
+	}
+
+	var diags []epub.Diagnostic
+	for _, itemref := range spine.FindAll("itemref") {
+		start, end, ok := parser.AttrValueRange(content, itemref, "properties")
+		if !ok {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		groups := make(map[string]string)
+		for _, token := range epub.Tokens(string(content[start:end])) {
+			diag := epub.NewDiag(content, start+token.Start, source).
+				End(epub.ByteOffsetToPosition(content, start+token.End))
+			name := token.Value
+
+			if seen[name] {
+				diags = append(diags, diag.Code("OPF_089").
+					Warning("duplicate itemref property \""+name+"\"").Build())
+				continue
+			}
+			seen[name] = true
+
+			prop, known := itemrefProperties[name]
+			if !known {
+				prefix, _, prefixed := strings.Cut(name, ":")
+				if !prefixed || prefix == "rendition" {
+					diags = append(diags, diag.Code("OPF_027").
+						Error("undefined itemref property \""+name+"\"").Build())
+				}
+				continue
+			}
+			if prop.group == "" {
+				continue
+			}
+
+			// rendition:page-spread-left repeats page-spread-left
+			value := strings.TrimPrefix(name, "rendition:")
+			if other, ok := groups[prop.group]; ok &&
+				strings.TrimPrefix(other, "rendition:") != value {
+				diags = append(diags, diag.Code("OPF_088").
+					Error("itemref property \""+name+"\" contradicts \""+other+"\"").Build())
+				continue
+			}
+			groups[prop.group] = name
+		}
+	}
+	return diags
+}
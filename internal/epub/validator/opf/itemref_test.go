@@ -0,0 +1,101 @@
+package opf
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestItemrefPropertiesValid(t *testing.T) {
+	content := testPackage{ItemrefAttrs: ` properties="page-spread-left ` +
+		`rendition:page-spread-left rendition:layout-pre-paginated ibooks:custom"`}.Bytes()
+
+	v := &Validator{}
+	codes := testutil.DiagCodes(v.Validate("package.opf", content, nil))
+	for _, code := range []string{"OPF_027", "OPF_088", "OPF_089"} {
+		if codes[code] {
+			t.Errorf("unexpected %s", code)
+		}
+	}
+}
+
+func TestItemrefPropertyUnknown(t *testing.T) {
+	content := testPackage{
+		ItemrefAttrs: ` properties="page-spread-left  rendition:spread-sometimes"`,
+	}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	var unknown []epub.Diagnostic
+	for _, d := range diags {
+		if d.Code == "OPF_027" {
+			unknown = append(unknown, d)
+		}
+	}
+	if len(unknown) != 1 {
+		t.Fatalf("expected 1 OPF_027, got %v", testutil.DiagCodes(diags))
+	}
+	if want := `undefined itemref property "rendition:spread-sometimes"`; unknown[0].Message != want {
+		t.Errorf("message = %q, want %q", unknown[0].Message, want)
+	}
+	// The range covers only the offending token
+	if r := unknown[0].Range; r.Start.Line != 11 || r.Start.Character != 55 || r.End.Character != 81 {
+		t.Errorf("range = %+v, want line 11, characters 55-81", r)
+	}
+}
+
+func TestItemrefPropertyContradictions(t *testing.T) {
+	tests := []struct {
+		name, properties, want string
+	}{
+		{
+			"page spreads", "page-spread-left page-spread-right",
+			`itemref property "page-spread-right" contradicts "page-spread-left"`,
+		},
+		{
+			"layouts", "rendition:layout-reflowable rendition:layout-pre-paginated",
+			`itemref property "rendition:layout-pre-paginated" contradicts "rendition:layout-reflowable"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validator{}
+			content := testPackage{ItemrefAttrs: ` properties="` + tt.properties + `"`}.Bytes()
+			diags := v.Validate("package.opf", content, nil)
+
+			var found []epub.Diagnostic
+			for _, d := range diags {
+				if d.Code == "OPF_088" {
+					found = append(found, d)
+				}
+			}
+			if len(found) != 1 {
+				t.Fatalf("expected 1 OPF_088, got %v", testutil.DiagCodes(diags))
+			}
+			if found[0].Message != tt.want {
+				t.Errorf("message = %q, want %q", found[0].Message, tt.want)
+			}
+			if found[0].Severity != epub.SeverityError {
+				t.Errorf("severity = %s, want error", testutil.SeverityName(found[0].Severity))
+			}
+		})
+	}
+}
+
+func TestItemrefPropertyDuplicate(t *testing.T) {
+	content := testPackage{
+		ItemrefAttrs: ` properties="rendition:spread-none rendition:spread-none"`,
+	}.Bytes()
+
+	v := &Validator{}
+	codes := testutil.DiagCodes(v.Validate("package.opf", content, nil))
+	if !codes["OPF_089"] {
+		t.Errorf("expected OPF_089, got %v", codes)
+	}
+	if codes["OPF_088"] {
+		t.Error("a repeated property should not contradict itself")
+	}
+}
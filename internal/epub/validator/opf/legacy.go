@@ -0,0 +1,64 @@
+package opf
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// validateLegacyMeta reports EPUB 2 name/content meta elements in an EPUB 3
+// package. Reading systems ignore them, except the cover meta, which is
+// kept for older readers but should be mirrored by the cover-image property
+// on the item it names.
+func validateLegacyMeta(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
+	metadata := pkg.FindFirst("metadata")
+	if metadata == nil {
+		return nil
+	}
+
+	items := make(map[string]*parser.XMLNode)
+	if manifest := pkg.FindFirst("manifest"); manifest != nil {
+		for _, item := range manifest.FindAll("item") {
+			if id := item.Attr("id"); id != "" {
+				items[id] = item
+			}
+		}
+	}
+
+	lines := epub.NewLineIndex(content)
+	var diags []epub.Diagnostic
+	for _, meta := range metadata.FindAll("meta") {
+		name := meta.Attr("name")
+		if name == "" {
+			continue
+		}
+		start, end, ok := parser.AttrValueRange(content, meta, "name")
+		if !ok {
+			start, end = int(meta.Offset), int(meta.Offset)
+		}
+
+		if name != "cover" {
+			diags = append(diags, epub.NewDiagAt(lines, start, source).
+				End(lines.Position(end)).
+				Code("OPF_086").
+				Info("legacy meta \""+name+"\" is ignored by EPUB 3 reading systems").
+				Build())
+			continue
+		}
+
+		id := meta.Attr("content")
+		item, ok := items[id]
+		if !ok || slices.Contains(strings.Fields(item.Attr("properties")), "cover-image") {
+			continue
+		}
+		diags = append(diags, epub.NewDiagAt(lines, start, source).
+			End(lines.Position(end)).
+			Code("OPF_087").
+			Hint("EPUB 3 reading systems find the cover through properties=\"cover-image\" "+
+				"on item \""+id+"\", not the cover meta").
+			Build())
+	}
+	return diags
+}
@@ -0,0 +1,82 @@
+package opf
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestLegacyMeta(t *testing.T) {
+	content := testPackage{Metadata: `
+    <meta name="calibre:series" content="Dune"/>
+    <meta name="calibre:series_index" content="1.0"/>`}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	var legacy []epub.Diagnostic
+	for _, d := range diags {
+		if d.Code == "OPF_086" {
+			legacy = append(legacy, d)
+		}
+	}
+	if len(legacy) != 2 {
+		t.Fatalf("expected 2 OPF_086, got %v", testutil.DiagCodes(diags))
+	}
+	if legacy[0].Severity != epub.SeverityInfo {
+		t.Errorf("severity = %s, want info", testutil.SeverityName(legacy[0].Severity))
+	}
+	if want := `legacy meta "calibre:series" is ignored by EPUB 3 reading systems`; legacy[0].Message != want {
+		t.Errorf("message = %q, want %q", legacy[0].Message, want)
+	}
+	// The range covers the name value
+	if r := legacy[0].Range; r.Start.Line != 7 || r.Start.Character != 16 || r.End.Character != 30 {
+		t.Errorf("range = %+v", r)
+	}
+}
+
+func TestLegacyMetaInEPUB2(t *testing.T) {
+	content := testPackage{Version: "2.0", Metadata: `
+    <meta name="calibre:series" content="Dune"/>`}.Bytes()
+
+	v := &Validator{}
+	codes := testutil.DiagCodes(v.Validate("package.opf", content, nil))
+	if codes["OPF_086"] {
+		t.Error("unexpected OPF_086 in an EPUB 2 package")
+	}
+}
+
+func TestCoverMeta(t *testing.T) {
+	tests := []struct {
+		name       string
+		meta       string
+		properties string
+		want       bool
+	}{
+		{"without cover-image", `<meta name="cover" content="ch1"/>`, "", true},
+		{"with cover-image", `<meta name="cover" content="ch1"/>`, ` properties="cover-image"`, false},
+		{"unknown item", `<meta name="cover" content="missing"/>`, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testPackage{Metadata: "    " + tt.meta, ItemAttrs: tt.properties}.Bytes()
+
+			v := &Validator{}
+			diags := v.Validate("package.opf", content, nil)
+			codes := testutil.DiagCodes(diags)
+			if codes["OPF_086"] {
+				t.Error("cover meta should not be reported as OPF_086")
+			}
+			if codes["OPF_087"] != tt.want {
+				t.Errorf("OPF_087 = %v, want %v", codes["OPF_087"], tt.want)
+			}
+			for _, d := range diags {
+				if d.Code == "OPF_087" && d.Severity != epub.SeverityHint {
+					t.Errorf("severity = %s, want hint", testutil.SeverityName(d.Severity))
+				}
+			}
+		})
+	}
+}
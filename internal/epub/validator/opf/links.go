@@ -0,0 +1,97 @@
+package opf
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// LinkRel is a rel value defined for metadata links.
+type LinkRel struct {
+	Name   string
+	Detail string
+	// Replacement is what to write instead of a deprecated value; empty
+	// for current values.
+	Replacement string
+	Deprecated  bool
+}
+
+// LinkRels lists the rel values of the EPUB 3 metadata link vocabulary.
+var LinkRels = []LinkRel{
+	{Name: "acquire", Detail: "Where to acquire the full publication"},
+	{Name: "alternate", Detail: "An alternate format of the linked resource"},
+	{Name: "record", Detail: "A metadata record for the publication, such as ONIX"},
+	{Name: "voicing", Detail: "Audio pronouncing the title or creator it refines"},
+	{
+		Name:        "marc21xml-record",
+		Detail:      "A MARC 21 XML record (deprecated)",
+		Replacement: `rel="record" with media-type "application/marcxml+xml"`,
+		Deprecated:  true,
+	},
+	{
+		Name:        "mods-record",
+		Detail:      "A MODS record (deprecated)",
+		Replacement: `rel="record" with media-type "application/mods+xml"`,
+		Deprecated:  true,
+	},
+	{
+		Name:        "onix-record",
+		Detail:      "An ONIX record (deprecated)",
+		Replacement: `rel="record" with properties="onix"`,
+		Deprecated:  true,
+	},
+	{
+		Name:        "xmp-record",
+		Detail:      "An XMP record (deprecated)",
+		Replacement: `rel="record" with media-type "application/rdf+xml"`,
+		Deprecated:  true,
+	},
+	{
+		Name:        "xml-signature",
+		Detail:      "An XML signature (deprecated)",
+		Replacement: "META-INF/signatures.xml",
+		Deprecated:  true,
+	},
+}
+
+// linkRels indexes LinkRels by name.
+var linkRels = func() map[string]LinkRel {
+	m := make(map[string]LinkRel, len(LinkRels))
+	for _, r := range LinkRels {
+		m[r.Name] = r
+	}
+	return m
+}()
+
+// validateLinkRel checks each rel token of a metadata link against the
+// vocabulary. Prefixed tokens belong to vocabularies this validator does
+// not know. A record link also needs a media-type naming the record's
+// format.
+func validateLinkRel(content []byte, lines *epub.LineIndex, link *parser.XMLNode) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+	if start, end, ok := parser.AttrValueRange(content, link, "rel"); ok {
+		for _, token := range epub.Tokens(string(content[start:end])) {
+			diag := epub.NewDiagAt(lines, start+token.Start, source).
+				End(lines.Position(start + token.End))
+			rel, known := linkRels[token.Value]
+			switch {
+			case !known && !strings.Contains(token.Value, ":"):
+				diags = append(diags, diag.Code("OPF_017").
+					Warning("unknown link rel \""+token.Value+"\"").Build())
+			case rel.Deprecated:
+				diags = append(diags, diag.Code("OPF_018").
+					Info("link rel \""+rel.Name+"\" is deprecated; use "+rel.Replacement).Build())
+			}
+		}
+	}
+
+	if epub.ContainsToken(link.Attr("rel"), "record") &&
+		strings.TrimSpace(link.Attr("media-type")) == "" {
+		diags = append(diags, tagNameDiag(content, lines, link).
+			Code("OPF_015").
+			Error("record <link> is missing a media-type").
+			Build())
+	}
+	return diags
+}
@@ -0,0 +1,100 @@
+package opf
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestLinkRel(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    string
+		code    string
+		message string
+		at      string
+	}{
+		{
+			name: "record with media-type",
+			link: `<link rel="record" href="https://example.com/onix.xml" media-type="application/xml" properties="onix"/>`,
+		},
+		{
+			name: "prefixed rel",
+			link: `<link rel="foaf:homepage" href="https://example.com/"/>`,
+		},
+		{
+			name:    "unknown rel",
+			link:    `<link rel="alternate recrod" href="https://example.com/onix.xml"/>`,
+			code:    "OPF_017",
+			message: `unknown link rel "recrod"`,
+			at:      `recrod"`,
+		},
+		{
+			name:    "deprecated rel",
+			link:    `<link rel="onix-record" href="https://example.com/onix.xml"/>`,
+			code:    "OPF_018",
+			message: `link rel "onix-record" is deprecated; use rel="record" with properties="onix"`,
+			at:      `onix-record"`,
+		},
+		{
+			name:    "record without media-type",
+			link:    `<link rel="record" href="https://example.com/record.xml"/>`,
+			code:    "OPF_015",
+			message: "record <link> is missing a media-type",
+			at:      "link rel",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testPackage{Metadata: "    " + tt.link}.Bytes()
+			diags := (&Validator{}).Validate("package.opf", content, nil)
+
+			var found []epub.Diagnostic
+			for _, d := range diags {
+				switch d.Code {
+				case "OPF_015", "OPF_017", "OPF_018":
+					found = append(found, d)
+				}
+			}
+			if tt.code == "" {
+				if len(found) != 0 {
+					t.Fatalf("expected no link diagnostics, got %+v", found)
+				}
+				return
+			}
+			if len(found) != 1 {
+				t.Fatalf("expected one %s, got %+v", tt.code, found)
+			}
+			d := found[0]
+			if d.Code != tt.code || d.Message != tt.message {
+				t.Errorf("got [%s] %q, want [%s] %q", d.Code, d.Message, tt.code, tt.message)
+			}
+			at := epub.PositionToByteOffset(content, d.Range.Start)
+			if got := string(content[at:]); len(got) < len(tt.at) || got[:len(tt.at)] != tt.at {
+				t.Errorf("diagnostic starts at %q, want %q", got[:min(len(got), 20)], tt.at)
+			}
+		})
+	}
+}
+
+func TestLinkRel_LocalFile(t *testing.T) {
+	content := testPackage{Metadata: `
+    <link rel="voicing" refines="#uid" href="audio/title.mp3" media-type="audio/mpeg"/>`}.Bytes()
+
+	ctx := &validator.WorkspaceContext{Files: map[string][]byte{
+		"file:///book/OEBPS/package.opf": content,
+	}}
+	diags := (&Validator{}).Validate("file:///book/OEBPS/package.opf", content, ctx)
+
+	var missing []string
+	for _, d := range diags {
+		if d.Code == "OPF_014" {
+			missing = append(missing, d.Message)
+		}
+	}
+	if len(missing) != 1 || missing[0] != `linked resource "audio/title.mp3" not found` {
+		t.Errorf("expected the voicing file to be reported, got %q", missing)
+	}
+}
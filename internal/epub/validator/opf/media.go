@@ -0,0 +1,121 @@
+package opf
+
+import (
+	"strings"
+	"time"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// durationTolerance is how far the global media:duration may be from the
+// sum of the media overlay durations, allowing for rounding.
+const durationTolerance = time.Second
+
+// validateMediaOverlays checks media:duration metadata: every value must be
+// a clock value and, when manifest items have media overlays, the
+// publication and each overlay need a duration, with the overlays adding up
+// to the publication's.
+func validateMediaOverlays(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
+	metadata := pkg.FindFirst("metadata")
+	if metadata == nil {
+		return nil
+	}
+
+	var diags []epub.Diagnostic
+
+	var (
+		global         *parser.XMLNode
+		globalDuration time.Duration
+		globalOK       bool
+	)
+	durations := make(map[string]time.Duration)
+	declared := make(map[string]bool)
+
+	for _, meta := range metadata.FindAll("meta") {
+		if meta.Attr("property") != "media:duration" {
+			continue
+		}
+		value := strings.TrimSpace(meta.CharData)
+		d, ok := validator.ParseClockValue(value)
+		if !ok {
+			_, _, end := parser.ElementSpan(content, int(meta.Offset))
+			diags = append(diags, epub.NewDiag(content, int(meta.Offset), source).
+				End(epub.ByteOffsetToPosition(content, end)).
+				Code("MED_003").
+				Error("media:duration \""+value+"\" is not a SMIL clock value").
+				Build())
+		}
+
+		refines := meta.Attr("refines")
+		if refines == "" {
+			if global == nil {
+				global, globalOK, globalDuration = meta, ok, d
+			}
+			continue
+		}
+		if id, found := strings.CutPrefix(refines, "#"); found {
+			declared[id] = true
+			if ok {
+				durations[id] = d
+			}
+		}
+	}
+
+	manifest := pkg.FindFirst("manifest")
+	if manifest == nil {
+		return diags
+	}
+
+	var overlays []string
+	seen := make(map[string]bool)
+	for _, item := range manifest.FindAll("item") {
+		overlay := item.Attr("media-overlay")
+		if overlay == "" || seen[overlay] {
+			continue
+		}
+		seen[overlay] = true
+		overlays = append(overlays, overlay)
+
+		if !declared[overlay] {
+			diags = append(diags, attrDiag(content, item, "media-overlay").
+				Code("MED_002").
+				Error("media overlay \""+overlay+"\" has no media:duration "+
+					"meta refining it").
+				Build())
+		}
+	}
+	if len(overlays) == 0 {
+		return diags
+	}
+
+	if global == nil {
+		diags = append(diags, epub.NewDiag(content, int(metadata.Offset), source).
+			Code("MED_001").
+			Error("publication with media overlays is missing a global "+
+				"media:duration meta").
+			Build())
+		return diags
+	}
+
+	var sum time.Duration
+	for _, overlay := range overlays {
+		d, ok := durations[overlay]
+		if !ok {
+			return diags
+		}
+		sum += d
+	}
+	if globalOK && (sum-globalDuration).Abs() > durationTolerance {
+		_, _, end := parser.ElementSpan(content, int(global.Offset))
+		diags = append(diags, epub.NewDiag(content, int(global.Offset), source).
+			End(epub.ByteOffsetToPosition(content, end)).
+			Code("MED_004").
+			Warning("media:duration is "+globalDuration.Round(time.Millisecond).String()+
+				" but the media overlays add up to "+sum.Round(time.Millisecond).String()).
+			Build())
+	}
+
+	return diags
+}
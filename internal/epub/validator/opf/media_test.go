@@ -0,0 +1,98 @@
+package opf
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+// overlayMetadata and overlayItems give ch1 and a second chapter, ch2,
+// media overlays, with the ch1 item's media-overlay attribute.
+const (
+	overlayMetadata = `    <meta property="media:active-class">-epub-media-overlay-active</meta>
+`
+	overlayItems = `    <item id="ch2" href="chapter2.xhtml" media-type="application/xhtml+xml" media-overlay="ch2_mo"/>
+    <item id="ch1_mo" href="chapter1.smil" media-type="application/smil+xml"/>
+    <item id="ch2_mo" href="chapter2.smil" media-type="application/smil+xml"/>`
+)
+
+func TestMediaOverlayDurations(t *testing.T) {
+	tests := []struct {
+		name string
+		meta string
+		want []string
+	}{
+		{
+			"correct",
+			`<meta property="media:duration">0:32:29.5</meta>
+    <meta property="media:duration" refines="#ch1_mo">0:20:00</meta>
+    <meta property="media:duration" refines="#ch2_mo">12:29.5</meta>`,
+			nil,
+		},
+		{
+			"within tolerance",
+			`<meta property="media:duration">0:32:30</meta>
+    <meta property="media:duration" refines="#ch1_mo">1200s</meta>
+    <meta property="media:duration" refines="#ch2_mo">12:29.5</meta>`,
+			nil,
+		},
+		{
+			"missing global",
+			`<meta property="media:duration" refines="#ch1_mo">0:20:00</meta>
+    <meta property="media:duration" refines="#ch2_mo">0:12:29.5</meta>`,
+			[]string{"MED_001"},
+		},
+		{
+			"missing per-overlay",
+			`<meta property="media:duration">0:32:29.5</meta>
+    <meta property="media:duration" refines="#ch1_mo">0:20:00</meta>`,
+			[]string{"MED_002"},
+		},
+		{
+			"invalid clock value",
+			`<meta property="media:duration">0:32:29.5</meta>
+    <meta property="media:duration" refines="#ch1_mo">20 minutes</meta>
+    <meta property="media:duration" refines="#ch2_mo">0:12:29.5</meta>`,
+			[]string{"MED_003"},
+		},
+		{
+			"sum mismatch",
+			`<meta property="media:duration">0:45:00</meta>
+    <meta property="media:duration" refines="#ch1_mo">0:20:00</meta>
+    <meta property="media:duration" refines="#ch2_mo">0:12:29.5</meta>`,
+			[]string{"MED_004"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validator{}
+			content := testPackage{
+				Metadata:  overlayMetadata + tt.meta,
+				ItemAttrs: ` media-overlay="ch1_mo"`,
+				Manifest:  overlayItems,
+				Spine:     `    <itemref idref="ch2"/>`,
+			}.Bytes()
+			codes := testutil.DiagCodes(v.Validate("package.opf", content, nil))
+			if len(codes) != len(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, codes)
+			}
+			for _, code := range tt.want {
+				testutil.ExpectCode(t, codes, code)
+			}
+		})
+	}
+}
+
+func TestMediaDurationWithoutOverlays(t *testing.T) {
+	content := testPackage{Metadata: `
+    <meta property="media:duration">soon</meta>`}.Bytes()
+
+	v := &Validator{}
+	codes := testutil.DiagCodes(v.Validate("package.opf", content, nil))
+
+	testutil.ExpectCode(t, codes, "MED_003")
+	if codes["MED_001"] {
+		t.Error("unexpected MED_001 without media overlays")
+	}
+}
@@ -1,7 +1,10 @@
 package opf
 
 import (
+	"strings"
+
 	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/langtag"
 	"github.com/toba/epub-lsp/internal/epub/parser"
 )
 
@@ -21,6 +24,8 @@ func validateMetadata(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
 		diags = append(diags, epub.NewDiag(content, int(metadata.Offset), source).
 			Code("OPF_030").Error("missing required <dc:identifier> in metadata").Build())
 	}
+	diags = append(diags, emptyElementDiags(content, identifiers, "OPF_030",
+		"dc:identifier", "urn:uuid:")...)
 
 	// Check unique-identifier references a valid dc:identifier
 	uniqueID := pkg.Attr("unique-identifier")
@@ -46,6 +51,8 @@ func validateMetadata(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
 		diags = append(diags, epub.NewDiag(content, int(metadata.Offset), source).
 			Code("OPF_032").Error("missing required <dc:title> in metadata").Build())
 	}
+	diags = append(diags, emptyElementDiags(content, titles, "OPF_032",
+		"dc:title", "Untitled")...)
 
 	// Check dc:language
 	languages := metadata.FindAllNS(epub.NSDC, "language")
@@ -53,6 +60,42 @@ func validateMetadata(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
 		diags = append(diags, epub.NewDiag(content, int(metadata.Offset), source).
 			Code("OPF_034").Error("missing required <dc:language> in metadata").Build())
 	}
+	diags = append(diags, emptyElementDiags(content, languages, "OPF_034",
+		"dc:language", "en")...)
+	lines := epub.NewLineIndex(content)
+	for _, lang := range languages {
+		start, end, _ := parser.ElementSpan(content, int(lang.Offset))
+		text := string(content[start:end])
+		tag := strings.TrimSpace(text)
+		offset := start + strings.Index(text, tag)
+		if d, bad := langtag.RegionDiag(lines, tag, offset, source); bad {
+			diags = append(diags, d)
+		}
+	}
 
 	return diags
 }
+
+// emptyElementDiags reports each of the required elements whose text is
+// only whitespace, which is as good as missing, with a fix filling in
+// placeholder.
+func emptyElementDiags(
+	content []byte,
+	elements []*parser.XMLNode,
+	code, name, placeholder string,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+	for _, el := range elements {
+		if strings.TrimSpace(el.Text()) != "" {
+			continue
+		}
+		_, _, end := parser.ElementSpan(content, int(el.Offset))
+		diags = append(diags, epub.NewDiag(content, int(el.Offset), source).
+			End(epub.ByteOffsetToPosition(content, end)).
+			Code(code).
+			Error("<"+name+"> is present but empty").
+			Fix("Fill in value", epub.AnchorElementContent, placeholder).
+			Build())
+	}
+	return diags
+}
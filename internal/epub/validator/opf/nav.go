@@ -0,0 +1,100 @@
+package opf
+
+import (
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// validateNavDocument cross-checks the manifest and spine against the
+// navigation documents in the workspace. Only one item may declare the nav
+// property, a nav document must be declared by it, and a nav whose toc is
+// hidden should not be in the linear reading order, where reading systems
+// would show it as an empty page.
+func validateNavDocument(
+	uri string,
+	content []byte,
+	pkg *parser.XMLNode,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	manifest := pkg.FindFirst("manifest")
+	if manifest == nil {
+		return nil
+	}
+
+	var diags []epub.Diagnostic
+	var navItems []*parser.XMLNode
+	for _, item := range manifest.FindAll("item") {
+		if !epub.ContainsToken(item.Attr("properties"), "nav") {
+			continue
+		}
+		navItems = append(navItems, item)
+		if len(navItems) > 1 {
+			diags = append(diags, attrDiag(content, item, "properties").
+				Code("NAV_016").
+				Warning("item \""+item.Attr("id")+"\" declares the nav property, "+
+					"already declared by item \""+navItems[0].Attr("id")+"\"").
+				Build())
+		}
+	}
+
+	if ctx == nil || ctx.Files == nil {
+		return diags
+	}
+
+	for _, item := range manifest.FindAll("item") {
+		if epub.ContainsToken(item.Attr("properties"), "nav") {
+			continue
+		}
+		key, ok := uriutil.Lookup(ctx.Files, uriutil.ResolveRelative(uri, item.Attr("href")))
+		if !ok || ctx.FileTypes[key] != epub.FileTypeNav {
+			continue
+		}
+		diags = append(diags, attrDiag(content, item, "href").
+			Code("NAV_015").
+			Error("navigation document \""+item.Attr("href")+
+				"\" is not declared with properties=\"nav\"").
+			Build())
+	}
+
+	spine := pkg.FindFirst("spine")
+	if spine == nil {
+		return diags
+	}
+	hidden := make(map[string]bool)
+	for _, item := range navItems {
+		key, ok := uriutil.Lookup(ctx.Files, uriutil.ResolveRelative(uri, item.Attr("href")))
+		if ok && hasHiddenToc(ctx.Files[key]) {
+			hidden[item.Attr("id")] = true
+		}
+	}
+	for _, itemref := range spine.FindAll("itemref") {
+		idref := itemref.Attr("idref")
+		if !hidden[idref] || itemref.Attr("linear") == "no" {
+			continue
+		}
+		diags = append(diags, attrDiag(content, itemref, "idref").
+			Code("NAV_017").
+			Warning("navigation document \""+idref+"\" hides its toc but is in the "+
+				"linear reading order; reading systems will show an empty page").
+			Build())
+	}
+
+	return diags
+}
+
+// hasHiddenToc reports whether the toc nav of a navigation document has
+// the hidden attribute.
+func hasHiddenToc(content []byte) bool {
+	root, diags := parser.Parse(content)
+	if len(diags) > 0 {
+		return false
+	}
+	for _, nav := range root.FindAll("nav") {
+		if epub.ContainsToken(nav.AttrNS(epub.NSEpub, "type"), "toc") {
+			return nav.HasAttr("hidden")
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package opf
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+const navOPFURI = "file:///book/OEBPS/package.opf"
+
+func navContext(toc string) *validator.WorkspaceContext {
+	nav := "file:///book/OEBPS/nav.xhtml"
+	return &validator.WorkspaceContext{
+		Files: map[string][]byte{
+			nav: []byte(`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<body>` + toc + `<ol><li><a href="chapter1.xhtml">One</a></li></ol></nav></body>
+</html>`),
+		},
+		FileTypes: map[string]epub.FileType{nav: epub.FileTypeNav},
+	}
+}
+
+func TestNavMissingProperty(t *testing.T) {
+	content := testPackage{
+		Manifest: `    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml"/>`,
+	}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate(navOPFURI, content, navContext(`<nav epub:type="toc">`))
+
+	var found *epub.Diagnostic
+	for i := range diags {
+		if diags[i].Code == "NAV_015" {
+			found = &diags[i]
+		}
+	}
+	if found == nil {
+		t.Fatalf("expected NAV_015, got %v", testutil.DiagCodes(diags))
+	}
+	if found.Severity != epub.SeverityError {
+		t.Errorf("severity = %s, want error", testutil.SeverityName(found.Severity))
+	}
+	// The range covers the href value
+	if r := found.Range; r.Start.Line != 9 || r.Start.Character != 25 || r.End.Character != 34 {
+		t.Errorf("range = %+v", r)
+	}
+
+	declared := testPackage{
+		Manifest: `    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>`,
+	}.Bytes()
+	codes := testutil.DiagCodes(v.Validate(navOPFURI, declared, navContext(`<nav epub:type="toc">`)))
+	if codes["NAV_015"] {
+		t.Error("unexpected NAV_015 for a declared nav item")
+	}
+}
+
+func TestMultipleNavItems(t *testing.T) {
+	content := testPackage{
+		Manifest: `    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="toc" href="toc.xhtml" media-type="application/xhtml+xml" properties="nav scripted"/>`,
+	}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate(navOPFURI, content, nil)
+
+	var navs []epub.Diagnostic
+	for _, d := range diags {
+		if d.Code == "NAV_016" {
+			navs = append(navs, d)
+		}
+	}
+	if len(navs) != 1 {
+		t.Fatalf("expected 1 NAV_016, got %v", testutil.DiagCodes(diags))
+	}
+	if want := `item "toc" declares the nav property, already declared by item "nav"`; navs[0].Message != want {
+		t.Errorf("message = %q, want %q", navs[0].Message, want)
+	}
+	if navs[0].Range.Start.Line != 10 {
+		t.Errorf("line = %d, want 10", navs[0].Range.Start.Line)
+	}
+}
+
+func TestHiddenTocInLinearSpine(t *testing.T) {
+	item := `    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>`
+	tests := []struct {
+		name    string
+		toc     string
+		itemref string
+		want    bool
+	}{
+		{"hidden and linear", `<nav epub:type="toc" hidden="">`, `<itemref idref="nav"/>`, true},
+		{"hidden and explicitly linear", `<nav epub:type="toc" hidden="">`, `<itemref idref="nav" linear="yes"/>`, true},
+		{"hidden and not linear", `<nav epub:type="toc" hidden="">`, `<itemref idref="nav" linear="no"/>`, false},
+		{"visible and linear", `<nav epub:type="toc">`, `<itemref idref="nav"/>`, false},
+		{"hidden and not in spine", `<nav epub:type="toc" hidden="">`, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validator{}
+			content := testPackage{Manifest: item, Spine: "    " + tt.itemref}.Bytes()
+			diags := v.Validate(navOPFURI, content, navContext(tt.toc))
+			codes := testutil.DiagCodes(diags)
+			if codes["NAV_017"] != tt.want {
+				t.Errorf("NAV_017 = %v, want %v (%v)", codes["NAV_017"], tt.want, codes)
+			}
+			for _, d := range diags {
+				if d.Code == "NAV_017" && d.Severity != epub.SeverityWarning {
+					t.Errorf("severity = %s, want warning", testutil.SeverityName(d.Severity))
+				}
+			}
+		})
+	}
+}
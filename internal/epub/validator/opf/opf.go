@@ -17,23 +17,40 @@ func (v *Validator) FileTypes() []epub.FileType {
 }
 
 func (v *Validator) Validate(
-	_ string,
+	uri string,
 	content []byte,
-	_ *validator.WorkspaceContext,
+	ctx *validator.WorkspaceContext,
 ) []epub.Diagnostic {
+	attrDiags := parser.ValidateAttrs(content)
+
 	root, diags := parser.Parse(content)
 	if len(diags) > 0 {
-		return diags
+		return append(attrDiags, diags...)
 	}
+	diags = attrDiags
 
 	pkg := root.FindFirst("package")
 	if pkg == nil {
 		return diags
 	}
 
+	// Without a version attribute, fall back to the configured version.
+	version := pkg.Attr("version")
+	epub2 := validator.IsEPUB2(version) || (version == "" && ctx.EPUB2())
+
+	diags = append(diags, validateStructure(uri, content, pkg, epub2, ctx)...)
 	diags = append(diags, validateMetadata(content, pkg)...)
+	diags = append(diags, validateRefines(content, pkg, epub2)...)
+	if !epub2 {
+		diags = append(diags, validatePrefixes(content, pkg)...)
+		diags = append(diags, validateMediaOverlays(content, pkg)...)
+		diags = append(diags, validateLegacyMeta(content, pkg)...)
+		diags = append(diags, validateNavDocument(uri, content, pkg, ctx)...)
+		diags = append(diags, validateItemrefProperties(content, pkg)...)
+	}
 	diags = append(diags, validateManifest(content, pkg)...)
-	diags = append(diags, validateSpine(content, pkg)...)
+	diags = append(diags, validateFallbacks(content, pkg)...)
+	diags = append(diags, validateSpine(content, pkg, epub2)...)
 
 	return diags
 }
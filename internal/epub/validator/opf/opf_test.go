@@ -1,10 +1,12 @@
 package opf
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
 )
 
 func TestValidOPF(t *testing.T) {
@@ -61,6 +63,40 @@ func TestMissingMetadataFields(t *testing.T) {
 	testutil.ExpectCode(t, codes, "OPF_034") // missing dc:language
 }
 
+func TestEmptyMetadataFields(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123456789</dc:identifier>
+    <dc:title>   </dc:title>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest/>
+  <spine/>
+</package>`)
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	if len(diags) != 1 || diags[0].Code != "OPF_032" {
+		t.Fatalf("expected one OPF_032 diagnostic, got %v", diags)
+	}
+	d := diags[0]
+	if d.Message != "<dc:title> is present but empty" {
+		t.Errorf("unexpected message %q", d.Message)
+	}
+	want := epub.Range{
+		Start: epub.Position{Line: 4, Character: 4},
+		End:   epub.Position{Line: 4, Character: 28},
+	}
+	if d.Range != want {
+		t.Errorf("expected range %+v, got %+v", want, d.Range)
+	}
+	if d.Fix == nil || d.Fix.Anchor != epub.AnchorElementContent {
+		t.Errorf("expected a fix filling in the element, got %+v", d.Fix)
+	}
+}
+
 func TestUniqueIdentifierMismatch(t *testing.T) {
 	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
 <package xmlns="http://www.idpf.org/2007/opf" unique-identifier="wrong" version="3.0">
@@ -172,3 +208,185 @@ func TestMalformedXML(t *testing.T) {
 		t.Error("expected diagnostics for malformed XML")
 	}
 }
+
+// legacyOPF is an EPUB 2-style package with an NCX but no spine toc
+// attribute and no version, so the mode comes from the workspace context.
+var legacyOPF = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123456789</dc:identifier>
+    <dc:title>Test Book</dc:title>
+    <dc:language>en</dc:language>
+    <meta refines="#missing" property="file-as">Nobody</meta>
+  </metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="ch1"/>
+  </spine>
+</package>`)
+
+func TestEPUBVersionModes(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []string
+		notWant []string
+	}{
+		{"", []string{"OPF_066"}, []string{"OPF_050"}},
+		{"3.0", []string{"OPF_066"}, []string{"OPF_050"}},
+		{"2.0", []string{"OPF_050"}, []string{"OPF_066"}},
+	}
+
+	for _, tt := range tests {
+		ctx := &validator.WorkspaceContext{Version: tt.version}
+		v := &Validator{}
+		codes := testutil.DiagCodes(v.Validate("package.opf", legacyOPF, ctx))
+
+		for _, code := range tt.want {
+			if !codes[code] {
+				t.Errorf("version %q: expected %s", tt.version, code)
+			}
+		}
+		for _, code := range tt.notWant {
+			if codes[code] {
+				t.Errorf("version %q: unexpected %s", tt.version, code)
+			}
+		}
+	}
+}
+
+func TestEPUB2SpineToc(t *testing.T) {
+	tests := []struct {
+		spine string
+		want  bool
+	}{
+		{`<spine toc="ncx">`, false},
+		{`<spine toc="ch1">`, true},
+		{`<spine toc="nope">`, true},
+	}
+
+	for _, tt := range tests {
+		content := bytes.Replace(legacyOPF, []byte("<spine>"), []byte(tt.spine), 1)
+		content = bytes.Replace(content,
+			[]byte(`unique-identifier="uid"`),
+			[]byte(`unique-identifier="uid" version="2.0"`), 1)
+
+		v := &Validator{}
+		diags := v.Validate("package.opf", content, nil)
+
+		if got := testutil.HasCode(diags, "OPF_050"); got != tt.want {
+			t.Errorf("%s: OPF_050 = %v, want %v", tt.spine, got, tt.want)
+		}
+	}
+}
+
+// ncxItem is an NCX manifest item for the spine toc attribute to name.
+const ncxItem = `    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>`
+
+func TestEPUB3SpineToc(t *testing.T) {
+	tests := []struct {
+		spine string
+		want  bool
+	}{
+		{``, false},
+		{` toc="ncx"`, false},
+		{` toc="ch1"`, true},
+		{` toc="nope"`, true},
+	}
+
+	for _, tt := range tests {
+		v := &Validator{}
+		content := testPackage{SpineAttrs: tt.spine, Manifest: ncxItem}.Bytes()
+		diags := v.Validate("package.opf", content, nil)
+
+		if got := testutil.HasCode(diags, "OPF_050"); got != tt.want {
+			t.Errorf("<spine%s>: OPF_050 = %v, want %v", tt.spine, got, tt.want)
+		}
+	}
+}
+
+func TestSpineTocRange(t *testing.T) {
+	v := &Validator{}
+	content := testPackage{SpineAttrs: ` toc="nope"`, Manifest: ncxItem}.Bytes()
+	diags := v.Validate("package.opf", content, nil)
+
+	if len(diags) != 1 || diags[0].Code != "OPF_050" {
+		t.Fatalf("expected a single OPF_050, got %v", testutil.DiagCodes(diags))
+	}
+	want := epub.Range{
+		Start: epub.Position{Line: 11, Character: 14},
+		End:   epub.Position{Line: 11, Character: 18},
+	}
+	if diags[0].Range != want {
+		t.Errorf("expected range %+v, got %+v", want, diags[0].Range)
+	}
+}
+
+func TestPageProgressionDirection(t *testing.T) {
+	tests := []struct {
+		spine string
+		want  bool
+	}{
+		{` page-progression-direction="ltr"`, false},
+		{` page-progression-direction="rtl"`, false},
+		{` page-progression-direction="default"`, false},
+		{` page-progression-direction="RTL"`, true},
+		{` page-progression-direction="ttb"`, true},
+		{` page-progression-direction=""`, true},
+	}
+
+	for _, tt := range tests {
+		v := &Validator{}
+		content := testPackage{SpineAttrs: tt.spine, Manifest: ncxItem}.Bytes()
+		diags := v.Validate("package.opf", content, nil)
+
+		if got := testutil.HasCode(diags, "OPF_020"); got != tt.want {
+			t.Errorf("<spine%s>: OPF_020 = %v, want %v", tt.spine, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageRegion(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123456789</dc:identifier>
+    <dc:title>Test Book</dc:title>
+    <dc:language> ja-JA </dc:language>
+    <dc:language>en-GB</dc:language>
+  </metadata>
+  <manifest>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="ch1"/>
+  </spine>
+</package>`)
+
+	diags := (&Validator{}).Validate("package.opf", content, nil)
+
+	var found []epub.Diagnostic
+	for _, d := range diags {
+		if d.Code == "lang-region-unlikely" {
+			found = append(found, d)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("expected one lang-region-unlikely, got %v", testutil.DiagCodes(diags))
+	}
+	// The range covers JA past the padding inside <dc:language>
+	if r := found[0].Range; r.Start != (epub.Position{Line: 5, Character: 21}) ||
+		r.End != (epub.Position{Line: 5, Character: 23}) {
+		t.Errorf("range = %+v", r)
+	}
+}
+
+func TestDuplicateAttribute(t *testing.T) {
+	content := testPackage{SpineAttrs: ` toc="ncx" toc="ncx"`, Manifest: ncxItem}.Bytes()
+	diags := (&Validator{}).Validate("package.opf", content, nil)
+	if !testutil.HasCode(diags, "XML_DUP_ATTR") {
+		t.Errorf("expected XML_DUP_ATTR, got %v", testutil.DiagCodes(diags))
+	}
+}
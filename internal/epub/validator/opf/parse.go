@@ -21,7 +21,10 @@ func ParseManifest(content []byte) *validator.ManifestInfo {
 		return nil
 	}
 
-	info := &validator.ManifestInfo{}
+	info := &validator.ManifestInfo{
+		Version:  pkg.Attr("version"),
+		Prefixes: ParsePrefixes(pkg.Attr("prefix")),
+	}
 
 	// Parse manifest items
 	manifest := pkg.FindFirst("manifest")
@@ -82,8 +85,9 @@ func parseMetadataInfo(metadata *parser.XMLNode, meta *validator.MetadataInfo) {
 	if len(metadata.FindAllNS(epub.NSDC, "title")) > 0 {
 		meta.HasTitle = true
 	}
-	if len(metadata.FindAllNS(epub.NSDC, "language")) > 0 {
+	for _, lang := range metadata.FindAllNS(epub.NSDC, "language") {
 		meta.HasLanguage = true
+		meta.Languages = append(meta.Languages, strings.TrimSpace(lang.CharData))
 	}
 	if len(metadata.FindAllNS(epub.NSDC, "source")) > 0 {
 		meta.HasDCSource = true
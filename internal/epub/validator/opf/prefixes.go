@@ -0,0 +1,76 @@
+package opf
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// ReservedPrefixes maps the prefixes that package metadata may use without
+// declaring them to their vocabulary URIs.
+var ReservedPrefixes = map[string]string{
+	"a11y":      "http://www.idpf.org/epub/vocab/package/a11y/#",
+	"dcterms":   "http://purl.org/dc/terms/",
+	"marc":      "http://id.loc.gov/vocabulary/",
+	"media":     "http://www.idpf.org/epub/vocab/overlays/#",
+	"onix":      "http://www.editeur.org/ONIX/book/codelists/current.html#",
+	"rendition": "http://www.idpf.org/vocab/rendition/#",
+	"schema":    "http://schema.org/",
+	"xsd":       "http://www.w3.org/2001/XMLSchema#",
+}
+
+// KnownPrefixes maps widely used vendor prefixes, which must be declared in
+// the package prefix attribute, to their vocabulary URIs.
+var KnownPrefixes = map[string]string{
+	"calibre": "https://calibre-ebook.com",
+	"ibooks":  "http://vocabulary.itunes.apple.com/rdf/ibooks/vocabulary-extensions-1.0/",
+	"se":      "https://standardebooks.org/vocab/1.0",
+	"z3998":   "http://www.daisy.org/z3998/2012/vocab/structure/#",
+}
+
+// ParsePrefixes parses a package prefix attribute, a whitespace-separated
+// list of "prefix: uri" pairs, into a map from prefix to URI.
+func ParsePrefixes(attr string) map[string]string {
+	prefixes := make(map[string]string)
+	fields := strings.Fields(attr)
+	for i := 0; i+1 < len(fields); i++ {
+		if name, ok := strings.CutSuffix(fields[i], ":"); ok && name != "" {
+			prefixes[name] = fields[i+1]
+			i++
+		}
+	}
+	return prefixes
+}
+
+// validatePrefixes checks that every meta property prefix is reserved or
+// declared in the package prefix attribute.
+func validatePrefixes(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
+	metadata := pkg.FindFirst("metadata")
+	if metadata == nil {
+		return nil
+	}
+
+	declared := ParsePrefixes(pkg.Attr("prefix"))
+
+	var diags []epub.Diagnostic
+	for _, meta := range metadata.FindAll("meta") {
+		property := meta.Attr("property")
+		prefix, _, ok := strings.Cut(property, ":")
+		if !ok {
+			continue
+		}
+		if _, ok := ReservedPrefixes[prefix]; ok {
+			continue
+		}
+		if _, ok := declared[prefix]; ok {
+			continue
+		}
+		diags = append(diags, epub.NewDiag(content, int(meta.Offset), source).
+			Code("OPF_028").
+			Error("undeclared prefix \""+prefix+"\" in property \""+property+"\"").
+			Build())
+	}
+
+	return diags
+}
@@ -0,0 +1,56 @@
+package opf
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+// prefixedMetadata uses the ibooks and rendition prefixes.
+const prefixedMetadata = `    <meta property="ibooks:version">1.0</meta>
+    <meta property="rendition:layout">reflowable</meta>`
+
+func TestParsePrefixes(t *testing.T) {
+	got := ParsePrefixes(
+		"ibooks: http://example.com/ibooks/\n  se:  https://se.example/ bad",
+	)
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 prefixes, got %v", got)
+	}
+	if got["ibooks"] != "http://example.com/ibooks/" ||
+		got["se"] != "https://se.example/" {
+		t.Errorf("unexpected prefixes %v", got)
+	}
+}
+
+func TestUndeclaredPrefix(t *testing.T) {
+	v := &Validator{}
+	diags := v.Validate("package.opf", testPackage{Metadata: prefixedMetadata}.Bytes(), nil)
+
+	var messages []string
+	for _, d := range diags {
+		if d.Code == "OPF_028" {
+			messages = append(messages, d.Message)
+		}
+	}
+
+	// rendition: is reserved, so only the ibooks: property is reported
+	want := `undeclared prefix "ibooks" in property "ibooks:version"`
+	if len(messages) != 1 || messages[0] != want {
+		t.Errorf("expected one OPF_028 %q, got %q", want, messages)
+	}
+}
+
+func TestDeclaredPrefix(t *testing.T) {
+	v := &Validator{}
+	content := testPackage{
+		PackageAttrs: ` prefix="ibooks: ` + KnownPrefixes["ibooks"] + `"`,
+		Metadata:     prefixedMetadata,
+	}.Bytes()
+	diags := v.Validate("package.opf", content, nil)
+
+	if testutil.HasCode(diags, "OPF_028") {
+		t.Error("expected no OPF_028 for declared prefix")
+	}
+}
@@ -0,0 +1,149 @@
+package opf
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// w3cdtfPattern matches the W3CDTF subset allowed for dc:date: YYYY,
+// YYYY-MM, YYYY-MM-DD, or a date and time with a time zone designator.
+var w3cdtfPattern = regexp.MustCompile(
+	`^\d{4}(-(0[1-9]|1[0-2])(-(0[1-9]|[12]\d|3[01])` +
+		`(T([01]\d|2[0-3]):[0-5]\d(:[0-5]\d(\.\d+)?)?` +
+		`(Z|[+-]([01]\d|2[0-3]):[0-5]\d))?)?)?$`,
+)
+
+// marcRelators lists the commonly used MARC relator codes.
+var marcRelators = map[string]bool{
+	"abr": true, "act": true, "adp": true, "aft": true, "anl": true,
+	"ann": true, "ant": true, "aqt": true, "arr": true, "art": true,
+	"asn": true, "aui": true, "aus": true, "aut": true, "bkd": true,
+	"bkp": true, "clb": true, "cmm": true, "cmp": true, "cnd": true,
+	"com": true, "cov": true, "cre": true, "ctb": true, "cwt": true,
+	"dsr": true, "dte": true, "dub": true, "edc": true, "edt": true,
+	"egr": true, "fmo": true, "ill": true, "isb": true, "itr": true,
+	"lyr": true, "mus": true, "nrt": true, "oth": true, "own": true,
+	"pbd": true, "pbl": true, "pht": true, "prf": true, "prg": true,
+	"prt": true, "red": true, "rev": true, "sng": true, "spk": true,
+	"stl": true, "trc": true, "trl": true, "tyg": true,
+}
+
+// validateRefines checks dc:date syntax and the integrity of meta
+// refinements: refines targets, MARC relator roles, and display-seq values.
+// EPUB 2 meta elements are name/content pairs, so only dates are checked.
+func validateRefines(content []byte, pkg *parser.XMLNode, epub2 bool) []epub.Diagnostic {
+	metadata := pkg.FindFirst("metadata")
+	if metadata == nil {
+		return nil
+	}
+
+	var diags []epub.Diagnostic
+
+	for _, date := range metadata.FindAllNS(epub.NSDC, "date") {
+		value := strings.TrimSpace(date.CharData)
+		if value != "" && !w3cdtfPattern.MatchString(value) {
+			diags = append(diags, epub.NewDiag(content, int(date.Offset), source).
+				Code("OPF_053").
+				Warning("dc:date \""+value+"\" is not a valid W3CDTF date").
+				Build())
+		}
+	}
+
+	if epub2 {
+		return diags
+	}
+
+	ids := make(map[string]bool)
+	collectIDs(pkg, ids)
+
+	for _, meta := range metadata.FindAll("meta") {
+		refines := meta.Attr("refines")
+		if id, ok := strings.CutPrefix(refines, "#"); ok && !ids[id] {
+			diags = append(diags, epub.NewDiag(content, int(meta.Offset), source).
+				Code("OPF_066").
+				Error("refines target \""+refines+"\" does not match any element id").
+				Build())
+		} else if !ok && meta.HasAttr("refines") {
+			diags = append(diags, epub.NewDiag(content, int(meta.Offset), source).
+				Code("OPF_066").
+				Error("refines value \""+refines+"\" must be a fragment identifier "+
+					"such as \"#"+refines+"\"").
+				Build())
+		}
+
+		value := strings.TrimSpace(meta.CharData)
+		switch meta.Attr("property") {
+		case "role":
+			if meta.Attr("scheme") == "marc:relators" && value != "" &&
+				!marcRelators[value] {
+				diags = append(diags, epub.NewDiag(content, int(meta.Offset), source).
+					Code("OPF_052").
+					Warning("unknown MARC relator code \""+value+"\"").
+					Build())
+			}
+		case "display-seq":
+			if n, err := strconv.Atoi(value); err != nil || n < 1 {
+				diags = append(diags, epub.NewDiag(content, int(meta.Offset), source).
+					Code("OPF_064").
+					Error("display-seq must be a positive integer, got \""+value+"\"").
+					Build())
+			}
+		}
+	}
+
+	diags = append(diags, duplicateTitleDiags(content, metadata)...)
+
+	return diags
+}
+
+// duplicateTitleDiags warns about each dc:title after the first that, like
+// the first, has no title-type or display-seq refinement, since reading
+// systems then pick which title to show arbitrarily.
+func duplicateTitleDiags(content []byte, metadata *parser.XMLNode) []epub.Diagnostic {
+	refined := make(map[string]bool)
+	for _, meta := range metadata.FindAll("meta") {
+		property := meta.Attr("property")
+		if property != "title-type" && property != "display-seq" {
+			continue
+		}
+		if id, ok := strings.CutPrefix(meta.Attr("refines"), "#"); ok && id != "" {
+			refined[id] = true
+		}
+	}
+
+	var unrefined []*parser.XMLNode
+	for _, title := range metadata.FindAllNS(epub.NSDC, "title") {
+		if !refined[title.Attr("id")] {
+			unrefined = append(unrefined, title)
+		}
+	}
+	if len(unrefined) < 2 {
+		return nil
+	}
+
+	diags := make([]epub.Diagnostic, 0, len(unrefined)-1)
+	for _, title := range unrefined[1:] {
+		_, _, end := parser.ElementSpan(content, int(title.Offset))
+		diags = append(diags, epub.NewDiag(content, int(title.Offset), source).
+			End(epub.ByteOffsetToPosition(content, end)).
+			Code("OPF_060").
+			Warning("duplicate <dc:title> without a title-type or display-seq "+
+				"refinement; reading systems may show either title").
+			Build())
+	}
+	return diags
+}
+
+// collectIDs records the id attribute of node and all its descendants.
+func collectIDs(node *parser.XMLNode, ids map[string]bool) {
+	if id := node.Attr("id"); id != "" {
+		ids[id] = true
+	}
+	for _, child := range node.Children {
+		collectIDs(child, ids)
+	}
+}
@@ -0,0 +1,181 @@
+package opf
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestValidRefinementCluster(t *testing.T) {
+	content := testPackage{Metadata: `
+    <dc:creator id="creator01">Jane Doe</dc:creator>
+    <meta refines="#creator01" property="role" scheme="marc:relators">aut</meta>
+    <meta refines="#creator01" property="file-as">Doe, Jane</meta>
+    <meta refines="#creator01" property="display-seq">1</meta>
+    <meta refines="#title" property="title-type">main</meta>
+    <meta refines="#ch1" property="media:duration">0:10:00</meta>
+    <dc:date>2024-03-15</dc:date>`}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", testutil.DiagCodes(diags))
+	}
+}
+
+func TestRefinesMissingTarget(t *testing.T) {
+	content := testPackage{Metadata: `
+    <meta refines="#creator99" property="file-as">Doe, Jane</meta>`}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	if !testutil.HasCode(diags, "OPF_066") {
+		t.Error("expected OPF_066 for refines without a target")
+	}
+}
+
+func TestRefinesWithoutFragment(t *testing.T) {
+	for _, refines := range []string{"creator01", ""} {
+		content := testPackage{Metadata: `
+    <dc:creator id="creator01">Jane Doe</dc:creator>
+    <meta refines="` + refines + `" property="file-as">Doe, Jane</meta>`}.Bytes()
+
+		v := &Validator{}
+		diags := v.Validate("package.opf", content, nil)
+		if !testutil.HasCode(diags, "OPF_066") {
+			t.Errorf("refines=%q: expected OPF_066, got %v",
+				refines, testutil.DiagCodes(diags))
+		}
+	}
+}
+
+func TestUnknownMarcRelator(t *testing.T) {
+	content := testPackage{Metadata: `
+    <dc:creator id="creator01">Jane Doe</dc:creator>
+    <meta refines="#creator01" property="role" scheme="marc:relators">writer</meta>`}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	if !testutil.HasCode(diags, "OPF_052") {
+		t.Error("expected OPF_052 for unknown MARC relator")
+	}
+}
+
+func TestRoleWithoutMarcSchemeNotChecked(t *testing.T) {
+	content := testPackage{Metadata: `
+    <dc:creator id="creator01">Jane Doe</dc:creator>
+    <meta refines="#creator01" property="role">writer</meta>`}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	if testutil.HasCode(diags, "OPF_052") {
+		t.Error("unexpected OPF_052 for role without marc:relators scheme")
+	}
+}
+
+func TestInvalidDisplaySeq(t *testing.T) {
+	for _, value := range []string{"0", "-1", "first", ""} {
+		content := testPackage{Metadata: `
+    <dc:creator id="creator01">Jane Doe</dc:creator>
+    <meta refines="#creator01" property="display-seq">` + value + `</meta>`}.Bytes()
+
+		v := &Validator{}
+		diags := v.Validate("package.opf", content, nil)
+
+		if !testutil.HasCode(diags, "OPF_064") {
+			t.Errorf("expected OPF_064 for display-seq %q", value)
+		}
+	}
+}
+
+func TestDCDateFormats(t *testing.T) {
+	valid := []string{
+		"2024",
+		"2024-03",
+		"2024-03-15",
+		"2024-03-15T10:30Z",
+		"2024-03-15T10:30:45Z",
+		"2024-03-15T10:30:45.5+01:00",
+	}
+	for _, value := range valid {
+		content := testPackage{Metadata: `<dc:date>` + value + `</dc:date>`}.Bytes()
+		v := &Validator{}
+		diags := v.Validate("package.opf", content, nil)
+		if testutil.HasCode(diags, "OPF_053") {
+			t.Errorf("unexpected OPF_053 for %q", value)
+		}
+	}
+
+	invalid := []string{
+		"15/03/2024",
+		"March 2024",
+		"2024-13",
+		"2024-03-32",
+		"2024-03-15 10:30",
+		"2024-03-15T25:00Z",
+		"2020-01-01T10:00",
+		"2020-01-01T10:00:30",
+	}
+	for _, value := range invalid {
+		content := testPackage{Metadata: `<dc:date>` + value + `</dc:date>`}.Bytes()
+		v := &Validator{}
+		diags := v.Validate("package.opf", content, nil)
+		if !testutil.HasCode(diags, "OPF_053") {
+			t.Errorf("expected OPF_053 for %q", value)
+		}
+	}
+}
+
+func TestCRLFLineEndings(t *testing.T) {
+	fixtures := [][]byte{
+		testPackage{Metadata: `
+    <dc:creator id="creator01">Jane Doe</dc:creator>
+    <meta refines="#creator99" property="file-as">Doe, Jane</meta>
+    <meta refines="#creator01" property="display-seq">first</meta>
+    <dc:date>March 2024</dc:date>`}.Bytes(),
+		testPackage{Metadata: `
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>`}.Bytes(),
+	}
+
+	for _, fixture := range fixtures {
+		v := &Validator{}
+		lf := v.Validate("package.opf", fixture, nil)
+		crlf := v.Validate("package.opf", testutil.CRLF(fixture), nil)
+		testutil.ExpectSameDiagnostics(t, lf, crlf)
+	}
+}
+
+func TestDuplicateTitleWithoutRefinements(t *testing.T) {
+	content := testPackage{Metadata: `
+    <dc:title>A Subtitle</dc:title>`}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	if len(diags) != 1 || diags[0].Code != "OPF_060" {
+		t.Fatalf("expected a single OPF_060, got %v", testutil.DiagCodes(diags))
+	}
+	if diags[0].Range.Start.Line != 7 {
+		t.Errorf("expected OPF_060 on the second title, got line %d",
+			diags[0].Range.Start.Line)
+	}
+}
+
+func TestDuplicateTitleWithRefinements(t *testing.T) {
+	content := testPackage{Metadata: `
+    <dc:title id="subtitle">A Subtitle</dc:title>
+    <meta refines="#title" property="title-type">main</meta>
+    <meta refines="#subtitle" property="title-type">subtitle</meta>
+    <meta refines="#subtitle" property="display-seq">2</meta>`}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("package.opf", content, nil)
+
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", testutil.DiagCodes(diags))
+	}
+}
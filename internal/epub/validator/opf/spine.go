@@ -3,9 +3,18 @@ package opf
 import (
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
 )
 
-func validateSpine(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
+// pageProgressionDirections lists the values of the spine
+// page-progression-direction attribute.
+var pageProgressionDirections = map[string]bool{
+	"ltr":     true,
+	"rtl":     true,
+	"default": true,
+}
+
+func validateSpine(content []byte, pkg *parser.XMLNode, epub2 bool) []epub.Diagnostic {
 	var diags []epub.Diagnostic
 
 	spine := pkg.FindFirst("spine")
@@ -15,19 +24,36 @@ func validateSpine(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
 		return diags
 	}
 
-	// Build a set of manifest item IDs
-	manifestIDs := make(map[string]bool)
+	// Map manifest item IDs to their media types
+	manifestIDs := make(map[string]string)
 	manifest := pkg.FindFirst("manifest")
 	if manifest != nil {
 		for _, item := range manifest.Children {
 			if item.Local == "item" {
 				if id := item.Attr("id"); id != "" {
-					manifestIDs[id] = true
+					manifestIDs[id] = item.Attr("media-type")
 				}
 			}
 		}
 	}
 
+	// The toc attribute is required in EPUB 2 and kept for compatibility
+	// in EPUB 3, where it must still name the NCX when present
+	if epub2 || spine.HasAttr("toc") {
+		diags = append(diags, validateSpineTOC(content, spine, manifestIDs)...)
+	}
+
+	if spine.HasAttr("page-progression-direction") {
+		ppd := spine.Attr("page-progression-direction")
+		if !pageProgressionDirections[ppd] {
+			diags = append(diags, attrDiag(content, spine, "page-progression-direction").
+				Code("OPF_020").
+				Error("page-progression-direction must be ltr, rtl, or default, got \""+
+					ppd+"\"").
+				Build())
+		}
+	}
+
 	// Check spine itemrefs reference valid manifest items
 	for _, itemref := range spine.Children {
 		if itemref.Local != "itemref" {
@@ -39,7 +65,7 @@ func validateSpine(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
 			continue
 		}
 
-		if !manifestIDs[idref] {
+		if _, ok := manifestIDs[idref]; !ok {
 			diags = append(diags, epub.NewDiag(content, int(itemref.Offset), source).
 				Code("OPF_003").
 				Error("spine itemref references nonexistent manifest id: \""+idref+"\"").
@@ -49,3 +75,47 @@ func validateSpine(content []byte, pkg *parser.XMLNode) []epub.Diagnostic {
 
 	return diags
 }
+
+// validateSpineTOC checks that the spine references the NCX document
+// through its toc attribute, which EPUB 2 requires.
+func validateSpineTOC(
+	content []byte,
+	spine *parser.XMLNode,
+	manifestIDs map[string]string,
+) []epub.Diagnostic {
+	toc := spine.Attr("toc")
+	if !spine.HasAttr("toc") {
+		return []epub.Diagnostic{epub.NewDiag(content, int(spine.Offset), source).
+			Code("OPF_050").
+			Error("EPUB 2 spine is missing the toc attribute referencing the NCX").
+			Build()}
+	}
+
+	mediaType, ok := manifestIDs[toc]
+	if !ok {
+		return []epub.Diagnostic{attrDiag(content, spine, "toc").
+			Code("OPF_050").
+			Error("spine toc \"" + toc + "\" does not match any manifest item id").
+			Build()}
+	}
+	if mediaType != validator.NCXMediaType {
+		return []epub.Diagnostic{attrDiag(content, spine, "toc").
+			Code("OPF_050").
+			Error("spine toc \"" + toc + "\" references a " + mediaType +
+				" item; it must reference the NCX (" + validator.NCXMediaType + ")").
+			Build()}
+	}
+
+	return nil
+}
+
+// attrDiag starts a diagnostic spanning the value of node's attribute
+// name, or at node when the value cannot be found.
+func attrDiag(content []byte, node *parser.XMLNode, name string) *epub.DiagBuilder {
+	start, end, ok := parser.AttrValueRange(content, node, name)
+	if !ok {
+		return epub.NewDiag(content, int(node.Offset), source)
+	}
+	return epub.NewDiag(content, start, source).
+		End(epub.ByteOffsetToPosition(content, end))
+}
@@ -0,0 +1,164 @@
+package opf
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// packageChildren ranks the elements allowed directly inside <package> in
+// the order the content model requires.
+var packageChildren = map[string]int{
+	"metadata":   0,
+	"manifest":   1,
+	"spine":      2,
+	"guide":      3,
+	"bindings":   4,
+	"collection": 5,
+}
+
+// epub2PackageChildren ranks the children of an EPUB 2 <package>, which
+// has <tours> but no bindings or collections.
+var epub2PackageChildren = map[string]int{
+	"metadata": 0,
+	"manifest": 1,
+	"spine":    2,
+	"tours":    3,
+	"guide":    4,
+}
+
+// validateStructure checks the children of <package> against the content
+// model: the sections must come in order and nothing else may appear. In
+// <metadata>, only Dublin Core elements, <meta>, and <link> are expected,
+// and each <link> needs rel and href. A link to a local file must point at
+// one in the workspace.
+func validateStructure(
+	uri string,
+	content []byte,
+	pkg *parser.XMLNode,
+	epub2 bool,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	lines := epub.NewLineIndex(content)
+	ranks := packageChildren
+	if epub2 {
+		ranks = epub2PackageChildren
+	}
+
+	var diags []epub.Diagnostic
+	var last *parser.XMLNode
+	for _, child := range pkg.Children {
+		rank, known := ranks[child.Local]
+		if !known || child.Space != pkg.Space {
+			diags = append(diags, tagNameDiag(content, lines, child).
+				Code("OPF_011").
+				Error("unexpected element <"+tagName(content, child)+"> in <package>").
+				Build())
+			continue
+		}
+		if last != nil && rank < ranks[last.Local] {
+			diags = append(diags, tagNameDiag(content, lines, child).
+				Code("OPF_010").
+				Warning("<"+child.Local+"> must come before <"+last.Local+">").
+				Build())
+			continue
+		}
+		last = child
+	}
+
+	if metadata := pkg.FindFirst("metadata"); metadata != nil {
+		diags = append(diags, validateMetadataChildren(uri, content, lines, pkg, metadata, epub2, ctx)...)
+	}
+	return diags
+}
+
+// validateMetadataChildren checks the elements directly inside <metadata>.
+func validateMetadataChildren(
+	uri string,
+	content []byte,
+	lines *epub.LineIndex,
+	pkg, metadata *parser.XMLNode,
+	epub2 bool,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+	for _, child := range metadata.Children {
+		switch {
+		case child.Space == epub.NSDC:
+			continue
+		case child.Space == pkg.Space && child.Local == "meta":
+			continue
+		case child.Space == pkg.Space && child.Local == "link":
+			diags = append(diags, validateMetadataLink(uri, content, lines, child, ctx)...)
+			continue
+		case epub2 && child.Space == pkg.Space &&
+			(child.Local == "dc-metadata" || child.Local == "x-metadata"):
+			// OPF 2.0 wrappers, deprecated but allowed
+			continue
+		}
+		diags = append(diags, tagNameDiag(content, lines, child).
+			Code("OPF_012").
+			Warning("unexpected element <"+tagName(content, child)+"> in <metadata>; "+
+				"expected Dublin Core elements, <meta>, or <link>").
+			Build())
+	}
+	return diags
+}
+
+// validateMetadataLink checks that a metadata <link> has rel and href, that
+// its rel values are defined, and that a link to a local file resolves.
+func validateMetadataLink(
+	uri string,
+	content []byte,
+	lines *epub.LineIndex,
+	link *parser.XMLNode,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+	for _, name := range []string{"rel", "href"} {
+		if strings.TrimSpace(link.Attr(name)) == "" {
+			diags = append(diags, tagNameDiag(content, lines, link).
+				Code("OPF_013").
+				Error("<link> is missing a "+name+" value").
+				Build())
+		}
+	}
+
+	diags = append(diags, validateLinkRel(content, lines, link)...)
+
+	href := link.Attr("href")
+	if href == "" || ctx == nil || ctx.Files == nil {
+		return diags
+	}
+	target := uriutil.ResolveRelative(uri, href)
+	if target == "" {
+		return diags // remote resource
+	}
+	if _, ok := uriutil.Lookup(ctx.Files, target); !ok {
+		kind := "linked resource"
+		if epub.ContainsToken(link.Attr("rel"), "record") {
+			kind = "record"
+		}
+		diags = append(diags, attrDiag(content, link, "href").
+			Code("OPF_014").
+			Error(kind+" \""+href+"\" not found").
+			Build())
+	}
+	return diags
+}
+
+// tagNameDiag starts a diagnostic spanning the name in node's start tag.
+func tagNameDiag(content []byte, lines *epub.LineIndex, node *parser.XMLNode) *epub.DiagBuilder {
+	name, _ := parser.TagNameSpans(content, int(node.Offset))
+	return epub.NewDiagAt(lines, name[0], source).End(lines.Position(name[1]))
+}
+
+// tagName returns node's name as written in its start tag, prefix
+// included.
+func tagName(content []byte, node *parser.XMLNode) string {
+	name, _ := parser.TagNameSpans(content, int(node.Offset))
+	return string(content[name[0]:name[1]])
+}
@@ -0,0 +1,206 @@
+package opf
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+const (
+	structureMetadata = `  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123456789</dc:identifier>
+    <dc:title>Test Book</dc:title>
+    <dc:language>en</dc:language>
+  </metadata>`
+	structureManifest = `  <manifest>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>`
+	structureSpine = `  <spine>
+    <itemref idref="ch1"/>
+  </spine>`
+)
+
+func TestStructure(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		code    string
+		message string
+		at      string
+	}{
+		{
+			name: "canonical with optional sections",
+			body: structureMetadata + "\n" + structureManifest + "\n" + structureSpine +
+				"\n  <guide/>\n  <collection role=\"index\"/>",
+		},
+		{
+			name:    "spine before manifest",
+			body:    structureMetadata + "\n" + structureSpine + "\n" + structureManifest,
+			code:    "OPF_010",
+			message: "<manifest> must come before <spine>",
+			at:      "manifest>\n    <item",
+		},
+		{
+			name:    "metadata last",
+			body:    structureManifest + "\n" + structureSpine + "\n" + structureMetadata,
+			code:    "OPF_010",
+			message: "<metadata> must come before <spine>",
+			at:      "metadata xmlns",
+		},
+		{
+			name:    "guide before spine",
+			body:    structureMetadata + "\n" + structureManifest + "\n  <guide/>\n" + structureSpine,
+			code:    "OPF_010",
+			message: "<spine> must come before <guide>",
+			at:      "spine>",
+		},
+		{
+			name:    "unknown package child",
+			body:    structureMetadata + "\n" + structureManifest + "\n" + structureSpine + "\n  <notes/>",
+			code:    "OPF_011",
+			message: "unexpected element <notes> in <package>",
+			at:      "notes/>",
+		},
+		{
+			name: "foreign namespace package child",
+			body: structureMetadata + "\n" + structureManifest + "\n" + structureSpine +
+				"\n  <x:spine xmlns:x=\"urn:example\"/>",
+			code:    "OPF_011",
+			message: "unexpected element <x:spine> in <package>",
+			at:      "x:spine",
+		},
+		{
+			name: "unknown metadata child",
+			body: `  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123456789</dc:identifier>
+    <dc:title>Test Book</dc:title>
+    <dc:language>en</dc:language>
+    <author>Someone</author>
+  </metadata>
+` + structureManifest + "\n" + structureSpine,
+			code:    "OPF_012",
+			message: "unexpected element <author> in <metadata>; expected Dublin Core elements, <meta>, or <link>",
+			at:      "author>Someone",
+		},
+		{
+			name: "link missing rel",
+			body: `  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123456789</dc:identifier>
+    <dc:title>Test Book</dc:title>
+    <dc:language>en</dc:language>
+    <link href="http://example.com/onix.xml"/>
+  </metadata>
+` + structureManifest + "\n" + structureSpine,
+			code:    "OPF_013",
+			message: "<link> is missing a rel value",
+			at:      "link href",
+		},
+		{
+			name: "link missing href",
+			body: `  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123456789</dc:identifier>
+    <dc:title>Test Book</dc:title>
+    <dc:language>en</dc:language>
+    <link rel="record" href=""/>
+  </metadata>
+` + structureManifest + "\n" + structureSpine,
+			code:    "OPF_013",
+			message: "<link> is missing a href value",
+			at:      "link rel",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testPackage{Children: tt.body}.Bytes()
+			diags := (&Validator{}).Validate("package.opf", content, nil)
+
+			var found []epub.Diagnostic
+			for _, d := range diags {
+				switch d.Code {
+				case "OPF_010", "OPF_011", "OPF_012", "OPF_013", "OPF_014":
+					found = append(found, d)
+				}
+			}
+			if tt.code == "" {
+				if len(found) != 0 {
+					t.Fatalf("expected no structure diagnostics, got %+v", found)
+				}
+				return
+			}
+			if len(found) != 1 {
+				t.Fatalf("expected one %s, got %+v", tt.code, found)
+			}
+			d := found[0]
+			if d.Code != tt.code || d.Message != tt.message {
+				t.Errorf("got [%s] %q, want [%s] %q", d.Code, d.Message, tt.code, tt.message)
+			}
+			at := epub.PositionToByteOffset(content, d.Range.Start)
+			if got := string(content[at:]); len(got) < len(tt.at) || got[:len(tt.at)] != tt.at {
+				t.Errorf("diagnostic starts at %q, want %q", got[:min(len(got), 20)], tt.at)
+			}
+		})
+	}
+}
+
+func TestStructure_EPUB2(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc-metadata>
+      <dc:identifier id="uid">urn:isbn:123456789</dc:identifier>
+      <dc:title>Test Book</dc:title>
+      <dc:language>en</dc:language>
+    </dc-metadata>
+  </metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx">
+    <itemref idref="ch1"/>
+  </spine>
+  <tours/>
+  <guide/>
+</package>`)
+
+	diags := (&Validator{}).Validate("package.opf", content, nil)
+	for _, d := range diags {
+		switch d.Code {
+		case "OPF_010", "OPF_011", "OPF_012":
+			t.Errorf("unexpected [%s] %s", d.Code, d.Message)
+		}
+	}
+}
+
+func TestStructure_RecordLink(t *testing.T) {
+	content := testPackage{Metadata: `
+    <link rel="record" href="meta/onix.xml" media-type="application/xml"/>
+    <link rel="record" href="meta/missing.xml" media-type="application/xml"/>
+    <link rel="record" href="https://example.com/record.xml"/>`}.Bytes()
+
+	ctx := &validator.WorkspaceContext{Files: map[string][]byte{
+		"file:///book/OEBPS/package.opf":    content,
+		"file:///book/OEBPS/meta/onix.xml":  []byte("<ONIXMessage/>"),
+		"file:///book/OEBPS/chapter1.xhtml": []byte("<html/>"),
+	}}
+	diags := (&Validator{}).Validate("file:///book/OEBPS/package.opf", content, ctx)
+
+	var records []epub.Diagnostic
+	for _, d := range diags {
+		if d.Code == "OPF_014" {
+			records = append(records, d)
+		}
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected one OPF_014, got %+v", records)
+	}
+	if want := `record "meta/missing.xml" not found`; records[0].Message != want {
+		t.Errorf("message = %q, want %q", records[0].Message, want)
+	}
+	at := epub.PositionToByteOffset(content, records[0].Range.Start)
+	if got := string(content[at : at+len("meta/missing.xml")]); got != "meta/missing.xml" {
+		t.Errorf("range starts at %q, want the href value", got)
+	}
+}
@@ -0,0 +1,73 @@
+package opf
+
+import "strings"
+
+// testPackage describes a package document for tests: a valid EPUB 3
+// package with an identifier, a title with the id "title", a language, and
+// the chapter ch1 in its manifest and spine, plus what a test adds to it.
+type testPackage struct {
+	// Version replaces the package version, 3.0 by default.
+	Version string
+	// PackageAttrs, ItemAttrs, SpineAttrs, and ItemrefAttrs are added, each
+	// with its leading space, to the package start tag, the ch1 manifest
+	// item, the spine start tag, and the ch1 itemref.
+	PackageAttrs string
+	ItemAttrs    string
+	SpineAttrs   string
+	ItemrefAttrs string
+	// Metadata, Manifest, and Spine are added after the metadata, manifest
+	// items, and itemref the package always has.
+	Metadata string
+	Manifest string
+	Spine    string
+	// Children, when set, replaces everything inside the package element,
+	// for tests of the sections themselves.
+	Children string
+}
+
+// Bytes returns the package document.
+func (p testPackage) Bytes() []byte {
+	version := p.Version
+	if version == "" {
+		version = "3.0"
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="` +
+		version + `"` + p.PackageAttrs + `>
+`)
+	if p.Children != "" {
+		writeLines(&b, p.Children)
+		b.WriteString(`</package>`)
+		return []byte(b.String())
+	}
+	b.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:isbn:123456789</dc:identifier>
+    <dc:title id="title">Test Book</dc:title>
+    <dc:language>en</dc:language>
+`)
+	writeLines(&b, p.Metadata)
+	b.WriteString(`  </metadata>
+  <manifest>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"` +
+		p.ItemAttrs + `/>
+`)
+	writeLines(&b, p.Manifest)
+	b.WriteString(`  </manifest>
+  <spine` + p.SpineAttrs + `>
+    <itemref idref="ch1"` + p.ItemrefAttrs + `/>
+`)
+	writeLines(&b, p.Spine)
+	b.WriteString(`  </spine>
+</package>`)
+	return []byte(b.String())
+}
+
+// writeLines writes s, when there is any, as lines of their own.
+func writeLines(b *strings.Builder, s string) {
+	if s != "" {
+		b.WriteString(s)
+		b.WriteString("\n")
+	}
+}
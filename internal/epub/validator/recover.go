@@ -0,0 +1,50 @@
+package validator
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// InternalErrorCode is the code of the diagnostic reported when a validator
+// panics.
+const InternalErrorCode = "internal-error"
+
+// validateSafely runs v on a file. A panic is logged with its stack and
+// reported as a single error diagnostic at the start of the file, so a
+// file that trips a bug in one validator is still checked by the others.
+func validateSafely(
+	v Validator,
+	uri string,
+	content []byte,
+	ctx *WorkspaceContext,
+) (diags []epub.Diagnostic) {
+	defer func() {
+		if r := recover(); r != nil {
+			name := validatorName(v)
+			slog.Error("validator panicked",
+				"validator", name, "uri", uri, "panic", r, "stack", string(debug.Stack()))
+			diags = []epub.Diagnostic{{
+				Code:     InternalErrorCode,
+				Severity: epub.SeverityError,
+				Message:  fmt.Sprintf("internal validator error in %s: %v — please report", name, r),
+				Source:   name,
+			}}
+		}
+	}()
+	return v.Validate(uri, content, ctx)
+}
+
+// validatorName names a validator after its package, the way validators
+// name the source of their diagnostics, such as "epub-accessibility".
+func validatorName(v Validator) string {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return "epub-" + path.Base(t.PkgPath())
+}
@@ -0,0 +1,60 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// panicValidator fails with an index out of range on every file.
+type panicValidator struct{}
+
+func (panicValidator) FileTypes() []epub.FileType { return []epub.FileType{epub.FileTypeXHTML} }
+
+func (panicValidator) Validate(string, []byte, *WorkspaceContext) []epub.Diagnostic {
+	var lines []int
+	_ = lines[len(lines)+1]
+	return nil
+}
+
+// fixedValidator reports one diagnostic on every file.
+type fixedValidator struct{}
+
+func (fixedValidator) FileTypes() []epub.FileType { return []epub.FileType{epub.FileTypeXHTML} }
+
+func (fixedValidator) Validate(string, []byte, *WorkspaceContext) []epub.Diagnostic {
+	return []epub.Diagnostic{diagAt(1, 0, "HTM_008", "img missing alt", "test")}
+}
+
+func TestValidateFileRecoversFromPanic(t *testing.T) {
+	r := NewRegistry()
+	r.Register(panicValidator{})
+	r.Register(fixedValidator{})
+
+	diags := r.ValidateFile("chapter.xhtml", []byte("<html/>"), epub.FileTypeXHTML, nil)
+
+	var fixed, internal []epub.Diagnostic
+	for _, d := range diags {
+		switch d.Code {
+		case "HTM_008":
+			fixed = append(fixed, d)
+		case InternalErrorCode:
+			internal = append(internal, d)
+		}
+	}
+	if len(fixed) != 1 {
+		t.Errorf("expected the other validators' diagnostic, got %+v", diags)
+	}
+	if len(internal) != 1 {
+		t.Fatalf("expected 1 internal error, got %+v", diags)
+	}
+	d := internal[0]
+	if d.Severity != epub.SeverityError || d.Source != "epub-validator" {
+		t.Errorf("severity = %d, source = %q", d.Severity, d.Source)
+	}
+	if !strings.HasPrefix(d.Message, "internal validator error in epub-validator: runtime error: index out of range") ||
+		!strings.HasSuffix(d.Message, "please report") {
+		t.Errorf("message = %q", d.Message)
+	}
+}
@@ -0,0 +1,47 @@
+package resource
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+)
+
+// foldPath returns p case-folded, so that paths which collide on
+// case-insensitive file systems fold to the same string.
+func foldPath(p string) string {
+	return strings.ToLower(strings.ToUpper(p))
+}
+
+// caseIndex maps the folded paths of workspace files to their paths.
+type caseIndex map[string][]string
+
+// newCaseIndex indexes the paths of files by their folded form.
+func newCaseIndex(files map[string][]byte) caseIndex {
+	index := make(caseIndex, len(files))
+	for uri := range files {
+		p := uriutil.Path(uri)
+		index[foldPath(p)] = append(index[foldPath(p)], p)
+	}
+	return index
+}
+
+// variant returns a workspace path equal to p under case folding but not
+// byte-equal to it, preferring the alphabetically first.
+func (index caseIndex) variant(p string) (string, bool) {
+	var found string
+	for _, other := range index[foldPath(p)] {
+		if other != p && (found == "" || other < found) {
+			found = other
+		}
+	}
+	return found, found != ""
+}
+
+// relativeTo returns p relative to dir when p is inside it, and p
+// otherwise.
+func relativeTo(dir, p string) string {
+	if rest, ok := strings.CutPrefix(p, strings.TrimSuffix(dir, "/")+"/"); ok {
+		return rest
+	}
+	return p
+}
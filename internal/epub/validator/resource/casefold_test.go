@@ -0,0 +1,84 @@
+package resource
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// caseVariantOPF lists cover.jpg under two spellings and a chapter whose
+// href differs in case from the file in the workspace.
+var caseVariantOPF = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid" version="3.0">
+  <manifest>
+    <item id="cover" href="Images/Cover.jpg" media-type="image/jpeg"/>
+    <item id="cover2" href="images/cover.jpg" media-type="image/jpeg"/>
+    <item id="ch1" href="Text/Chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="Text/chapter2.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+</package>`)
+
+func caseVariantDiags(t *testing.T) []epub.Diagnostic {
+	t.Helper()
+	ctx := &validator.WorkspaceContext{
+		Files: map[string][]byte{
+			"file:///book/OEBPS/content.opf":         caseVariantOPF,
+			"file:///book/OEBPS/Images/Cover.jpg":    {},
+			"file:///book/OEBPS/images/cover.jpg":    {},
+			"file:///book/OEBPS/Text/chapter1.xhtml": []byte("<html/>"),
+			"file:///book/OEBPS/Text/chapter2.xhtml": []byte("<html/>"),
+		},
+	}
+	v := &ManifestValidator{}
+	return v.Validate("file:///book/OEBPS/content.opf", caseVariantOPF, ctx)
+}
+
+func TestManifestValidator_CaseCollision(t *testing.T) {
+	var found []epub.Diagnostic
+	for _, d := range caseVariantDiags(t) {
+		if d.Code == "OPF_061" {
+			found = append(found, d)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("expected 1 OPF_061, got %v", found)
+	}
+
+	d := found[0]
+	want := epub.Range{
+		Start: epub.Position{Line: 4, Character: 28},
+		End:   epub.Position{Line: 4, Character: 44},
+	}
+	if d.Range != want {
+		t.Errorf("range = %v, want %v", d.Range, want)
+	}
+	if !strings.Contains(d.Message, `"images/cover.jpg"`) ||
+		!strings.Contains(d.Message, `"Images/Cover.jpg"`) {
+		t.Errorf("expected both hrefs in %q", d.Message)
+	}
+}
+
+func TestManifestValidator_CaseVariantFile(t *testing.T) {
+	diags := caseVariantDiags(t)
+	if testutil.HasCode(diags, "RSC_007") {
+		t.Error("a case variant on disk should be reported as RSC_020, not RSC_007")
+	}
+
+	var found []epub.Diagnostic
+	for _, d := range diags {
+		if d.Code == "RSC_020" {
+			found = append(found, d)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("expected 1 RSC_020, got %v", found)
+	}
+	if d := found[0]; d.Range.Start.Line != 5 ||
+		!strings.Contains(d.Message, `"Text/Chapter1.xhtml"`) ||
+		!strings.Contains(d.Message, `"Text/chapter1.xhtml"`) {
+		t.Errorf("expected RSC_020 on line 6 naming both paths, got %+v", d)
+	}
+}
@@ -0,0 +1,233 @@
+package resource
+
+import (
+	"cmp"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// MaxHashSize is the size past which a resource is not hashed when looking
+// for duplicates.
+const MaxHashSize = 50_000_000
+
+// maxDuplicateOffenders is the number of duplicate groups the manifest
+// diagnostic names.
+const maxDuplicateOffenders = 3
+
+// textMediaTypes lists the media types of the documents FindDuplicates
+// skips unless asked to include text.
+var textMediaTypes = map[string]bool{
+	"application/xhtml+xml": true,
+	"text/css":              true,
+}
+
+// hashSeed seeds the content hashes, which are only compared within one
+// process.
+var hashSeed = maphash.MakeSeed()
+
+// DuplicateItem is a manifest item in a DuplicateGroup.
+type DuplicateItem struct {
+	URI string `json:"uri"`
+	ID  string `json:"id"`
+}
+
+// DuplicateGroup lists manifest items with identical content, and the
+// bytes taken by every copy but the first.
+type DuplicateGroup struct {
+	Size   int64           `json:"size"`
+	Items  []DuplicateItem `json:"items"`
+	Wasted int64           `json:"wastedBytes"`
+}
+
+// FindDuplicates hashes the local manifest items, resolved against the
+// package document at opfURI, and returns the groups of two or more with
+// identical content, most wasted bytes first. XHTML and CSS documents are
+// skipped unless includeText is set, as are resources larger than
+// MaxHashSize and ones that cannot be read. Workspace content is hashed
+// over the files on disk, so unsaved edits are included.
+func FindDuplicates(
+	ctx *validator.WorkspaceContext,
+	opfURI string,
+	items []validator.ManifestItem,
+	includeText bool,
+) []DuplicateGroup {
+	type key struct {
+		size int64
+		sum  uint64
+	}
+	var order []key
+	groups := make(map[key][]DuplicateItem)
+	for _, item := range items {
+		if item.Href == "" || epub.IsRemoteURL(item.Href) ||
+			!includeText && textMediaTypes[item.MediaType] {
+			continue
+		}
+		resolved := uriutil.ResolveRelative(opfURI, item.Href)
+		size, sum, ok := hashResource(ctx, resolved)
+		if !ok {
+			continue
+		}
+		k := key{size, sum}
+		if groups[k] == nil {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], DuplicateItem{URI: resolved, ID: item.ID})
+	}
+
+	var duplicates []DuplicateGroup
+	for _, k := range order {
+		if found := groups[k]; len(found) > 1 {
+			duplicates = append(duplicates, DuplicateGroup{
+				Size:   k.size,
+				Items:  found,
+				Wasted: k.size * int64(len(found)-1),
+			})
+		}
+	}
+	slices.SortStableFunc(duplicates, func(a, b DuplicateGroup) int {
+		return cmp.Compare(b.Wasted, a.Wasted)
+	})
+	return duplicates
+}
+
+// hashResource returns the size and content hash of the file at resolved,
+// read from the workspace when it holds the file with content and streamed
+// from disk otherwise. It reports false for files larger than MaxHashSize
+// and ones that cannot be read.
+func hashResource(ctx *validator.WorkspaceContext, resolved string) (int64, uint64, bool) {
+	if key, ok := uriutil.Lookup(ctx.Files, resolved); ok && len(ctx.Files[key]) > 0 {
+		content := ctx.Files[key]
+		if len(content) > MaxHashSize {
+			return 0, 0, false
+		}
+		return int64(len(content)), maphash.Bytes(hashSeed, content), true
+	}
+
+	name := ctx.DiskPath(resolved)
+	if name == "" {
+		return 0, 0, false
+	}
+	f, err := os.Open(name) //nolint:gosec // a publication resource
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() > MaxHashSize {
+		return 0, 0, false
+	}
+	var h maphash.Hash
+	h.SetSeed(hashSeed)
+	size, err := io.Copy(&h, f)
+	if err != nil {
+		return 0, 0, false
+	}
+	return size, h.Sum64(), true
+}
+
+// DuplicateValidator summarizes, on the manifest element, the manifest
+// items with identical content. It runs on OPF files when
+// WorkspaceContext.DuplicateResources is set.
+type DuplicateValidator struct{}
+
+func (v *DuplicateValidator) FileTypes() []epub.FileType {
+	return []epub.FileType{epub.FileTypeOPF}
+}
+
+func (v *DuplicateValidator) Validate(
+	uri string,
+	content []byte,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	if ctx == nil || !ctx.DuplicateResources {
+		return nil
+	}
+
+	root, xmlDiags := parser.Parse(content)
+	if len(xmlDiags) > 0 {
+		return nil
+	}
+	pkg := root.FindFirst("package")
+	if pkg == nil {
+		return nil
+	}
+	manifest := pkg.FindFirst("manifest")
+	if manifest == nil {
+		return nil
+	}
+
+	var items []validator.ManifestItem
+	for _, item := range manifest.Children {
+		if item.Local == "item" {
+			items = append(items, validator.ManifestItem{
+				ID:        item.Attr("id"),
+				Href:      item.Attr("href"),
+				MediaType: item.Attr("media-type"),
+			})
+		}
+	}
+	groups := FindDuplicates(ctx, uri, items, false)
+	if len(groups) == 0 {
+		return nil
+	}
+
+	var wasted int64
+	copies := 0
+	for _, g := range groups {
+		wasted += g.Wasted
+		copies += len(g.Items) - 1
+	}
+	offenders := make([]string, 0, maxDuplicateOffenders)
+	for _, g := range groups[:min(len(groups), maxDuplicateOffenders)] {
+		ids := make([]string, len(g.Items))
+		for i, item := range g.Items {
+			ids[i] = item.ID
+		}
+		offenders = append(offenders, fmt.Sprintf("%s (%s; %s each)",
+			path.Base(uriutil.Path(g.Items[0].URI)), strings.Join(ids, ", "),
+			formatBytes(g.Size)))
+	}
+	message := fmt.Sprintf("%s wasted on %d duplicate %s: %s",
+		formatBytes(wasted), copies, plural(copies, "resource"),
+		strings.Join(offenders, "; "))
+	if more := len(groups) - len(offenders); more > 0 {
+		message += fmt.Sprintf("; and %d more %s", more, plural(more, "group"))
+	}
+
+	lines := epub.NewLineIndex(content)
+	return []epub.Diagnostic{
+		epub.NewDiagAt(lines, int(manifest.Offset), source).
+			Code("duplicate-resources").
+			Info(message).
+			Build(),
+	}
+}
+
+// formatBytes renders n bytes in B, KB, or MB, such as "1.5 MB".
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1f MB", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1f KB", float64(n)/1_000)
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// plural returns noun, adding "s" unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return noun + "s"
+}
@@ -0,0 +1,113 @@
+package resource
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+	"github.com/toba/lsp/pathutil"
+)
+
+const duplicatesOPF = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<manifest>
+<item id="cover" href="images/cover.png" media-type="image/png"/>
+<item id="img1" href="images/img1.png" media-type="image/png"/>
+<item id="logo" href="images/logo.png" media-type="image/png"/>
+<item id="disk" href="images/disk.png" media-type="image/png"/>
+<item id="css1" href="one.css" media-type="text/css"/>
+<item id="css2" href="two.css" media-type="text/css"/>
+<item id="remote" href="https://example.com/cover.png" media-type="image/png"/>
+</manifest>
+</package>`
+
+// duplicatesContext returns a workspace holding the package document at
+// dir, two identical PNG images, one unique image, two identical
+// stylesheets, and, on disk only, a third copy of the cover.
+func duplicatesContext(t *testing.T) (*validator.WorkspaceContext, string) {
+	t.Helper()
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\nthe same cover")
+	if err := os.MkdirAll(filepath.Join(dir, "images"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "images", "disk.png"), png, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	uri := func(name string) string {
+		return pathutil.FilePathToURI(filepath.Join(dir, filepath.FromSlash(name)))
+	}
+	opfURI := uri("content.opf")
+	return &validator.WorkspaceContext{
+		RootPath: dir,
+		Files: map[string][]byte{
+			opfURI:                  []byte(duplicatesOPF),
+			uri("images/cover.png"): png,
+			uri("images/img1.png"):  png,
+			uri("images/logo.png"):  []byte("\x89PNG\r\n\x1a\nlogo"),
+			uri("images/disk.png"):  nil,
+			uri("one.css"):          []byte("p { margin: 0 }"),
+			uri("two.css"):          []byte("p { margin: 0 }"),
+		},
+		DuplicateResources: true,
+	}, opfURI
+}
+
+func TestFindDuplicates(t *testing.T) {
+	ctx, opfURI := duplicatesContext(t)
+	items := []validator.ManifestItem{
+		{ID: "cover", Href: "images/cover.png", MediaType: "image/png"},
+		{ID: "img1", Href: "images/img1.png", MediaType: "image/png"},
+		{ID: "logo", Href: "images/logo.png", MediaType: "image/png"},
+		{ID: "disk", Href: "images/disk.png", MediaType: "image/png"},
+		{ID: "missing", Href: "images/missing.png", MediaType: "image/png"},
+		{ID: "css1", Href: "one.css", MediaType: "text/css"},
+		{ID: "css2", Href: "two.css", MediaType: "text/css"},
+	}
+
+	groups := FindDuplicates(ctx, opfURI, items, false)
+	if len(groups) != 1 {
+		t.Fatalf("groups = %+v, want one", groups)
+	}
+	var ids []string
+	for _, item := range groups[0].Items {
+		ids = append(ids, item.ID)
+	}
+	if got := strings.Join(ids, " "); got != "cover img1 disk" {
+		t.Errorf("ids = %q, want the cover, its copy, and the copy on disk", got)
+	}
+	if size := groups[0].Size; groups[0].Wasted != 2*size {
+		t.Errorf("wasted = %d, want two copies of %d", groups[0].Wasted, size)
+	}
+
+	groups = FindDuplicates(ctx, opfURI, items, true)
+	if len(groups) != 2 || groups[1].Items[0].ID != "css1" {
+		t.Errorf("groups with text = %+v, want the stylesheets second", groups)
+	}
+}
+
+func TestDuplicateValidator(t *testing.T) {
+	ctx, opfURI := duplicatesContext(t)
+	v := &DuplicateValidator{}
+
+	diags := v.Validate(opfURI, []byte(duplicatesOPF), ctx)
+	if len(diags) != 1 || diags[0].Code != "duplicate-resources" {
+		t.Fatalf("expected one duplicate-resources, got %v", testutil.DiagCodes(diags))
+	}
+	if msg := diags[0].Message; !strings.Contains(msg, "2 duplicate resources") ||
+		!strings.Contains(msg, "cover.png (cover, img1, disk;") {
+		t.Errorf("message = %q", msg)
+	}
+	if line := diags[0].Range.Start.Line; line != 2 {
+		t.Errorf("reported on line %d, want the manifest's", line)
+	}
+
+	ctx.DuplicateResources = false
+	if diags := v.Validate(opfURI, []byte(duplicatesOPF), ctx); len(diags) != 0 {
+		t.Errorf("expected nothing when turned off, got %v", testutil.DiagCodes(diags))
+	}
+}
@@ -0,0 +1,124 @@
+package resource
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// IdentifierURIPrefixes lists the prefixes of http:// URIs that identify
+// namespaces, vocabularies, and specifications rather than locate
+// resources. They are written with the http scheme by convention and are
+// never reported as insecure.
+var IdentifierURIPrefixes = []string{
+	"http://www.w3.org/",
+	"http://www.idpf.org/",
+	"http://purl.org/",
+	"http://schema.org/",
+	"http://www.daisy.org/",
+	"http://id.loc.gov/",
+	"http://ns.adobe.com/",
+}
+
+// urlAttrs lists the local names of attributes whose values are fetched
+// by reading systems.
+var urlAttrs = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"srcset": true,
+	"poster": true,
+	"data":   true,
+}
+
+// InsecureValidator reports references that load over plain http, which
+// reading systems increasingly block as mixed content. It runs on package,
+// content, navigation, and CSS files.
+type InsecureValidator struct{}
+
+func (v *InsecureValidator) FileTypes() []epub.FileType {
+	return []epub.FileType{
+		epub.FileTypeOPF,
+		epub.FileTypeXHTML,
+		epub.FileTypeNav,
+		epub.FileTypeNCX,
+		epub.FileTypeCSS,
+	}
+}
+
+func (v *InsecureValidator) Validate(
+	uri string,
+	content []byte,
+	_ *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	var offsets []int
+	if epub.DetectFileType(uri, content) == epub.FileTypeCSS {
+		for _, u := range parser.CSSURLs(string(content)) {
+			offsets = appendInsecure(offsets, u.Offset, u.Value)
+		}
+	} else {
+		parser.ScanAttrs(content, func(name string, offset int, value string) {
+			if name == "xmlns" || strings.HasPrefix(name, "xmlns:") {
+				return
+			}
+			if _, local, found := strings.Cut(name, ":"); found {
+				name = local
+			}
+			if !urlAttrs[name] {
+				return
+			}
+			if name != "srcset" {
+				offsets = appendInsecure(offsets, offset, value)
+				return
+			}
+			for i := range len(value) {
+				if i == 0 || value[i-1] == ',' || isSpace(value[i-1]) {
+					offsets = appendInsecure(offsets, offset+i, value[i:])
+				}
+			}
+		})
+	}
+
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	lines := epub.NewLineIndex(content)
+	diags := make([]epub.Diagnostic, 0, len(offsets))
+	for _, offset := range offsets {
+		ref := string(content[offset:referenceEnd(content, offset)])
+		diags = append(diags, epub.NewDiagAt(lines, offset, source).
+			End(lines.Position(offset+len("http"))).
+			Code("RSC_031").
+			Warning("insecure reference "+ref+" should use https").
+			Fix("Use https", epub.AnchorReplaceRange, "https").
+			Build())
+	}
+	return diags
+}
+
+// appendInsecure appends offset when ref begins with an http:// URL that
+// is not an identifier URI.
+func appendInsecure(offsets []int, offset int, ref string) []int {
+	lower := strings.ToLower(ref)
+	if !strings.HasPrefix(lower, "http://") {
+		return offsets
+	}
+	for _, prefix := range IdentifierURIPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return offsets
+		}
+	}
+	return append(offsets, offset)
+}
+
+// referenceEnd returns the offset just past the URL starting at offset.
+func referenceEnd(content []byte, offset int) int {
+	end := offset
+	for end < len(content) && !isSpace(content[end]) &&
+		!strings.ContainsRune(`"'),<>`, rune(content[end])) {
+		end++
+	}
+	return end
+}
@@ -0,0 +1,117 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+func TestInsecureValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		content string
+		want    []string // references reported, in order
+	}{
+		{
+			"namespaces not flagged",
+			"ch1.xhtml",
+			`<html xmlns="http://www.w3.org/1999/xhtml" ` +
+				`xmlns:epub="http://www.idpf.org/2007/ops"><body/></html>`,
+			nil,
+		},
+		{
+			"remote image flagged",
+			"ch1.xhtml",
+			`<html><body><img src="http://example.com/a.png" alt=""/></body></html>`,
+			[]string{"http://example.com/a.png"},
+		},
+		{
+			"https untouched",
+			"ch1.xhtml",
+			`<html><body><img src="https://example.com/a.png" alt=""/>` +
+				`<a href="https://example.com/">x</a></body></html>`,
+			nil,
+		},
+		{
+			"srcset candidates",
+			"ch1.xhtml",
+			`<img srcset="a.png 1x, http://example.com/b.png 2x,HTTP://c.com/c.png 3x"/>`,
+			[]string{"http://example.com/b.png", "HTTP://c.com/c.png"},
+		},
+		{
+			"identifier URIs and text content",
+			"package.opf",
+			`<package><metadata><dc:source>http://example.com/</dc:source>` +
+				`<link rel="dcterms:conformsTo" ` +
+				`href="http://www.idpf.org/epub/a11y/accessibility-20170105.html"/>` +
+				`</metadata><manifest><item id="r" href="http://example.com/r.mp3"/>` +
+				`</manifest></package>`,
+			[]string{"http://example.com/r.mp3"},
+		},
+		{
+			"comments and xlink",
+			"ch1.xhtml",
+			`<!-- <a href="http://example.com/">x</a> -->` +
+				`<svg><image xlink:href='http://example.com/i.svg'/></svg>`,
+			[]string{"http://example.com/i.svg"},
+		},
+		{
+			"css url",
+			"style.css",
+			`/* url(http://example.com/old.woff) */` +
+				`@font-face { src: url("http://example.com/f.woff") }` +
+				`body { background: URL(http://example.com/bg.png) }` +
+				`p { background: url(https://example.com/ok.png) }`,
+			[]string{"http://example.com/f.woff", "http://example.com/bg.png"},
+		},
+	}
+
+	v := &InsecureValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte(tt.content)
+			diags := v.Validate(tt.uri, content, nil)
+			if len(diags) != len(tt.want) {
+				t.Fatalf("expected %d diagnostics, got %d: %v",
+					len(tt.want), len(diags), diags)
+			}
+
+			lines := epub.NewLineIndex(content)
+			for i, d := range diags {
+				if d.Code != "RSC_031" || d.Severity != epub.SeverityWarning {
+					t.Errorf("unexpected diagnostic %s severity %d", d.Code, d.Severity)
+				}
+				start := lines.Offset(d.Range.Start)
+				ref := tt.want[i]
+				if got := string(content[start : start+len(ref)]); got != ref {
+					t.Errorf("diagnostic %d starts at %q, want %q", i, got, ref)
+				}
+			}
+		})
+	}
+}
+
+func TestInsecureValidator_Fix(t *testing.T) {
+	content := []byte(`<p><img src="http://example.com/a.png" alt=""/></p>`)
+	diags := (&InsecureValidator{}).Validate("ch1.xhtml", content, nil)
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(diags))
+	}
+
+	d := diags[0]
+	if d.Fix == nil || d.Fix.Anchor != epub.AnchorReplaceRange {
+		t.Fatalf("expected a replace-range fix, got %+v", d.Fix)
+	}
+	lines := epub.NewLineIndex(content)
+	start, end := lines.Offset(d.Range.Start), lines.Offset(d.Range.End)
+	fixed := string(content[:start]) + d.Fix.InsertText + string(content[end:])
+
+	if want := `<p><img src="https://example.com/a.png" alt=""/></p>`; fixed != want {
+		t.Errorf("fixed content = %q, want %q", fixed, want)
+	}
+	diags = (&InsecureValidator{}).Validate("ch1.xhtml", []byte(fixed), nil)
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics after fix, got %v", diags)
+	}
+}
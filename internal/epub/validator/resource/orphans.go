@@ -0,0 +1,86 @@
+package resource
+
+import (
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/ignore"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// contentExtensions lists the extensions of files that belong in a
+// publication's manifest.
+var contentExtensions = map[string]bool{
+	".xhtml": true, ".html": true, ".htm": true, ".css": true, ".js": true,
+	".svg": true, ".smil": true, ".png": true, ".jpg": true, ".jpeg": true,
+	".gif": true, ".webp": true, ".ttf": true, ".otf": true, ".woff": true,
+	".woff2": true, ".mp3": true, ".m4a": true, ".mp4": true, ".ogg": true,
+	".opus": true, ".webm": true, ".vtt": true,
+}
+
+// OrphanedFiles returns the content files in the package document's
+// directory tree that are not manifest items. opfPath is the package
+// document's slash-separated path within fsys. Files ignored matches,
+// relative to fsys, and files above the package document's directory are
+// skipped. Returned paths are relative to fsys.
+func OrphanedFiles(
+	fsys fs.FS,
+	opfPath string,
+	manifest *validator.ManifestInfo,
+	ignored *ignore.Matcher,
+) ([]string, error) {
+	opfDir := path.Dir(opfPath)
+
+	listed := make(map[string]bool)
+	if manifest != nil {
+		for _, item := range manifest.Items {
+			if epub.IsRemoteURL(item.Href) {
+				continue
+			}
+			href := epub.StripFragment(item.Href)
+			if decoded, err := url.PathUnescape(href); err == nil {
+				href = decoded
+			}
+			listed[path.Join(opfDir, href)] = true
+		}
+	}
+
+	var orphans []string
+	err := fs.WalkDir(fsys, opfDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != opfDir && ignored.Match(p, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == "META-INF" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if contentExtensions[strings.ToLower(path.Ext(p))] && !listed[p] {
+			orphans = append(orphans, p)
+		}
+		return nil
+	})
+
+	return orphans, err
+}
+
+// OrphanDiagnostic returns the diagnostic reported at the start of an
+// orphaned file.
+func OrphanDiagnostic() epub.Diagnostic {
+	return epub.Diagnostic{
+		Code:     "orphan-file",
+		Severity: epub.SeverityInfo,
+		Message:  "file is not referenced by the package manifest",
+		Source:   source,
+	}
+}
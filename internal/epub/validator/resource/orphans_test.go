@@ -0,0 +1,80 @@
+package resource
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toba/epub-lsp/internal/epub/ignore"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestOrphanedFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"mimetype":                     {Data: []byte("application/epub+zip")},
+		"META-INF/container.xml":       {},
+		"cover-source.png":             {}, // above the package document
+		"OEBPS/content.opf":            {},
+		"OEBPS/toc.ncx":                {},
+		"OEBPS/chapter1.xhtml":         {},
+		"OEBPS/images/my cover.jpg":    {},
+		"OEBPS/images/old.png":         {},
+		"OEBPS/backup/chapter0.xhtml":  {},
+		"OEBPS/.DS_Store":              {},
+		"OEBPS/notes.txt":              {},
+		"OEBPS/styles/extra.css.orig":  {},
+		"OEBPS/styles/book.css":        {},
+		"OEBPS/.git/objects/image.png": {},
+	}
+
+	manifest := &validator.ManifestInfo{
+		Items: []validator.ManifestItem{
+			{ID: "ncx", Href: "toc.ncx"},
+			{ID: "ch1", Href: "chapter1.xhtml#start"},
+			{ID: "cover", Href: "images/my%20cover.jpg"},
+			{ID: "css", Href: "styles/book.css"},
+			{ID: "remote", Href: "https://example.com/font.woff"},
+		},
+	}
+
+	ignored := ignore.New(ignore.Defaults...)
+	ignored.Add("backup/")
+	orphans, err := OrphanedFiles(fsys, "OEBPS/content.opf", manifest, ignored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"OEBPS/images/old.png"}
+	if !slices.Equal(orphans, want) {
+		t.Errorf("expected orphans %v, got %v", want, orphans)
+	}
+}
+
+func TestOrphanedFilesIgnorePatterns(t *testing.T) {
+	fsys := fstest.MapFS{
+		"content.opf":       {},
+		"images/old.png":    {},
+		"images/draft.png":  {},
+		"text/chapter.html": {},
+	}
+
+	tests := []struct {
+		ignore []string
+		want   []string
+	}{
+		{nil, []string{"images/draft.png", "images/old.png", "text/chapter.html"}},
+		{[]string{"*.png"}, []string{"text/chapter.html"}},
+		{[]string{"images/draft.png"}, []string{"images/old.png", "text/chapter.html"}},
+		{[]string{"/text"}, []string{"images/draft.png", "images/old.png"}},
+	}
+
+	for _, tt := range tests {
+		orphans, err := OrphanedFiles(fsys, "content.opf", nil, ignore.New(tt.ignore...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(orphans, tt.want) {
+			t.Errorf("ignore %v: expected %v, got %v", tt.ignore, tt.want, orphans)
+		}
+	}
+}
@@ -2,12 +2,13 @@
 package resource
 
 import (
-	"net/url"
+	"bytes"
 	"path"
 	"strings"
 
 	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
 	"github.com/toba/epub-lsp/internal/epub/validator"
 )
 
@@ -45,10 +46,14 @@ func (v *ManifestValidator) Validate(
 		return nil
 	}
 
-	// Determine the OPF directory for resolving relative hrefs
-	opfDir := dirFromURI(uri)
+	lines := epub.NewLineIndex(content)
+	index := newCaseIndex(ctx.Files)
+	opfDir := uriutil.Path(uriutil.Dir(uri))
 
 	var diags []epub.Diagnostic
+	// firstHrefs maps folded manifest paths to the href of the first item
+	// resolving to them.
+	firstHrefs := make(map[string]string)
 
 	for _, item := range manifest.Children {
 		if item.Local != "item" {
@@ -65,25 +70,54 @@ func (v *ManifestValidator) Validate(
 			continue
 		}
 
-		// Resolve relative href against OPF directory
-		resolvedURI := resolveHref(opfDir, href)
+		resolved := uriutil.ResolveRelative(uri, href)
+		target := uriutil.Path(resolved)
+		if first, ok := firstHrefs[foldPath(target)]; !ok {
+			firstHrefs[foldPath(target)] = href
+		} else if target != uriutil.Path(uriutil.ResolveRelative(uri, first)) {
+			diags = append(diags, hrefDiag(content, lines, item).
+				Code("OPF_061").
+				Warning("manifest href \""+href+"\" differs from \""+first+
+					"\" only in case; the files collide on case-insensitive file systems").
+				Build())
+		}
 
-		if !fileExistsInWorkspace(resolvedURI, ctx.Files) {
-			diags = append(diags, epub.NewDiag(content, int(item.Offset), source).
-				Code("RSC_007").
-				Error("manifest item references missing file: "+href).Build())
+		if _, ok := uriutil.Lookup(ctx.Files, resolved); ok {
+			continue
+		}
+		if variant, ok := index.variant(target); ok {
+			diags = append(diags, hrefDiag(content, lines, item).
+				Code("RSC_020").
+				Warning("manifest href \""+href+"\" only matches \""+
+					relativeTo(opfDir, variant)+"\" when case is ignored; it will "+
+					"not be found on case-sensitive file systems").
+				Build())
+			continue
 		}
+		diags = append(diags, epub.NewDiagAt(lines, int(item.Offset), source).
+			Code("RSC_007").
+			Error("manifest item references missing file: "+href).Build())
 	}
 
 	return diags
 }
 
+// hrefDiag starts a diagnostic spanning the href value of a manifest item,
+// or at the item when the value cannot be found.
+func hrefDiag(content []byte, lines *epub.LineIndex, item *parser.XMLNode) *epub.DiagBuilder {
+	start, end, ok := parser.AttrValueRange(content, item, "href")
+	if !ok {
+		return epub.NewDiagAt(lines, int(item.Offset), source)
+	}
+	return epub.NewDiagAt(lines, start, source).End(lines.Position(end))
+}
+
 // ContentValidator checks that resources referenced in content documents
-// are listed in the manifest. It runs on XHTML and Nav files.
+// are listed in the manifest. It runs on XHTML, Nav, and SVG files.
 type ContentValidator struct{}
 
 func (v *ContentValidator) FileTypes() []epub.FileType {
-	return []epub.FileType{epub.FileTypeXHTML, epub.FileTypeNav}
+	return []epub.FileType{epub.FileTypeXHTML, epub.FileTypeNav, epub.FileTypeSVG}
 }
 
 func (v *ContentValidator) Validate(
@@ -100,149 +134,152 @@ func (v *ContentValidator) Validate(
 		return nil
 	}
 
-	// Build set of manifest hrefs
-	manifestHrefs := make(map[string]bool)
-	for _, item := range ctx.Manifest.Items {
-		manifestHrefs[item.Href] = true
+	c := &contentChecker{
+		content:    content,
+		lines:      epub.NewLineIndex(content),
+		contentDir: uriutil.Dir(uri),
+		manifest:   ctx.Manifest,
+		files:      ctx.Files,
 	}
+	c.walk(root, c.contentDir)
 
-	contentDir := dirFromURI(uri)
+	return c.diags
+}
 
-	var diags []epub.Diagnostic
+// referenceAttrs lists, by element, the attributes that reference
+// publication resources, with SVG's xlink:href written with its
+// conventional prefix.
+var referenceAttrs = map[string][]string{
+	"img":    {"src", "srcset"},
+	"link":   {"href"},
+	"image":  {"src", "href", "xlink:href"},
+	"source": {"src", "srcset"},
+	"audio":  {"src"},
+	"video":  {"src", "poster"},
+	"object": {"data"},
+}
 
-	// Check <img src="...">
-	imgs := root.FindAll("img")
-	for _, img := range imgs {
-		src := img.Attr("src")
-		if src == "" {
-			continue
-		}
-		if epub.IsRemoteURL(src) || strings.HasPrefix(src, "data:") {
-			continue
-		}
-		checkResourceInManifest(content, img, src, contentDir, manifestHrefs, &diags)
-	}
+// contentChecker collects RSC_007 and RSC_008 diagnostics for one content
+// document.
+type contentChecker struct {
+	content    []byte
+	lines      *epub.LineIndex
+	contentDir string
+	manifest   *validator.ManifestInfo
+	// files holds the workspace files, and paths their paths once a
+	// hyperlink needs them.
+	files map[string][]byte
+	paths map[string]bool
+	diags []epub.Diagnostic
+}
 
-	// Check <link href="..."> (typically CSS)
-	links := root.FindAll("link")
-	for _, link := range links {
-		href := link.Attr("href")
-		if href == "" {
-			continue
-		}
-		if epub.IsRemoteURL(href) {
-			continue
-		}
-		checkResourceInManifest(content, link, href, contentDir, manifestHrefs, &diags)
+// walk checks the references of node and its descendants, resolving them
+// against baseDir as rebased by any xml:base attributes along the way. An
+// empty baseDir means a remote base, under which nothing is checked.
+func (c *contentChecker) walk(node *parser.XMLNode, baseDir string) {
+	if base := node.AttrNS(epub.NSXML, "base"); base != "" && baseDir != "" {
+		baseDir = rebase(baseDir, base)
 	}
 
-	// Check <image> and <source> elements (for SVG/audio/video in XHTML)
-	for _, tagName := range []string{"image", "source", "audio", "video"} {
-		elems := root.FindAll(tagName)
-		for _, elem := range elems {
-			src := elem.Attr("src")
-			if src == "" {
-				src = elem.Attr("href")
+	if baseDir != "" {
+		for _, attr := range referenceAttrs[node.Local] {
+			value := node.Attr(attr)
+			if local, ok := strings.CutPrefix(attr, "xlink:"); ok {
+				value = node.AttrNS(epub.NSXLink, local)
 			}
-			if src == "" || epub.IsRemoteURL(src) ||
-				strings.HasPrefix(src, "data:") {
-				continue
+			if attr == "srcset" {
+				for _, ref := range parseSrcset(value) {
+					c.check(node, attr, ref, baseDir)
+				}
+			} else {
+				c.check(node, attr, value, baseDir)
 			}
-			checkResourceInManifest(content, elem, src, contentDir, manifestHrefs, &diags)
 		}
-	}
-
-	return diags
-}
-
-func checkResourceInManifest(
-	content []byte,
-	node *parser.XMLNode,
-	ref string,
-	contentDir string,
-	manifestHrefs map[string]bool,
-	diags *[]epub.Diagnostic,
-) {
-	ref = epub.StripFragment(ref)
-	if ref == "" {
-		return
-	}
-
-	// We need to resolve relative to the OPF location to match manifest hrefs.
-	// The manifest hrefs are relative to the OPF, so we need the path
-	// of this content file relative to the OPF.
-	// If we don't have an OPF path, we try a simpler approach.
-	resolved := resolveHref(contentDir, ref)
-
-	// Try to match against manifest hrefs.
-	// Manifest hrefs are relative to OPF. Content refs are relative to content file.
-	// We need a common resolution. Check if the resolved href ends with any manifest href.
-	found := false
-	for manifestHref := range manifestHrefs {
-		if pathEndsWith(resolved, manifestHref) {
-			found = true
-			break
+		if node.Local == "a" && node.Space != epub.NSSVG {
+			c.checkLink(node, baseDir)
 		}
 	}
 
-	// Also try the raw ref (in case content and OPF are in the same directory)
-	if !found && manifestHrefs[ref] {
-		found = true
+	for _, child := range node.Children {
+		c.walk(child, baseDir)
 	}
+}
 
-	if !found {
-		*diags = append(*diags, epub.NewDiag(content, int(node.Offset), source).
-			Code("RSC_008").Warning("resource not found in manifest: "+ref).Build())
+// rebase applies an xml:base value to baseDir, returning "" when the new
+// base is remote.
+func rebase(baseDir, base string) string {
+	if epub.IsRemoteURL(base) {
+		return ""
 	}
+	resolved := epub.ResolveHref(baseDir, base)
+	// Without a trailing slash the last segment names a file, not a
+	// directory.
+	if !strings.HasSuffix(base, "/") {
+		resolved = path.Dir(resolved)
+	}
+	return resolved
 }
 
-// dirFromURI returns the directory portion of a URI.
-func dirFromURI(uri string) string {
-	// Try to parse as URL first
-	if u, err := url.Parse(uri); err == nil && u.Path != "" {
-		return path.Dir(u.Path)
+func (c *contentChecker) check(node *parser.XMLNode, attr, ref, baseDir string) {
+	if ref == "" || epub.IsRemoteURL(ref) || strings.HasPrefix(ref, "data:") {
+		return
 	}
-	idx := strings.LastIndex(uri, "/")
-	if idx >= 0 {
-		return uri[:idx]
+	ref = epub.StripFragment(ref)
+	if ref == "" || c.inManifest(ref, baseDir) {
+		return
 	}
-	return ""
+	c.diags = append(c.diags, c.refDiag(node, attr, ref).
+		Code("RSC_008").Warning("resource not found in manifest: "+ref).Build())
 }
 
-// resolveHref resolves a relative href against a base directory.
-func resolveHref(baseDir, href string) string {
-	if href == "" {
-		return ""
+// checkLink checks the href of a hyperlink to a local file. A target the
+// workspace holds but the manifest does not list is a warning, since the
+// file is left out of the packaged book, and a target that does not exist
+// at all is an error.
+func (c *contentChecker) checkLink(node *parser.XMLNode, baseDir string) {
+	ref := node.Attr("href")
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		ref = ref[:i]
 	}
-	// URL-decode the href
-	if decoded, err := url.PathUnescape(href); err == nil {
-		href = decoded
+	if ref == "" || hasScheme(ref) {
+		return
 	}
-	if path.IsAbs(href) {
-		return href
+	if c.inManifest(ref, baseDir) {
+		return
 	}
-	return path.Clean(baseDir + "/" + href)
-}
 
-// fileExistsInWorkspace checks if a file URI exists in the workspace files.
-func fileExistsInWorkspace(resolvedPath string, files map[string][]byte) bool {
-	for fileURI := range files {
-		if u, err := url.Parse(fileURI); err == nil {
-			if u.Path == resolvedPath || strings.HasSuffix(u.Path, resolvedPath) {
-				return true
-			}
-		}
-		if strings.HasSuffix(fileURI, resolvedPath) {
-			return true
+	if c.paths == nil {
+		c.paths = make(map[string]bool, len(c.files))
+		for uri := range c.files {
+			c.paths[uriutil.Path(uri)] = true
 		}
 	}
-	return false
+	if c.paths[epub.ResolveHref(baseDir, ref)] {
+		c.diags = append(c.diags, c.refDiag(node, "href", ref).
+			Code("RSC_008").
+			Warning("hyperlink target is not in the manifest: "+ref).Build())
+		return
+	}
+	c.diags = append(c.diags, c.refDiag(node, "href", ref).
+		Code("RSC_007").
+		Error("hyperlink target does not exist: "+ref).Build())
 }
 
-// pathEndsWith checks if a full path ends with a suffix path.
-func pathEndsWith(full, suffix string) bool {
-	if full == suffix {
-		return true
+// inManifest reports whether ref, resolved against baseDir, names a
+// manifest item.
+func (c *contentChecker) inManifest(ref, baseDir string) bool {
+	return c.manifest.ItemByPath(epub.ResolveHref(baseDir, ref)) != nil
+}
+
+// refDiag starts a diagnostic spanning ref within the value of the named
+// attribute of node, which for srcset holds several references.
+func (c *contentChecker) refDiag(node *parser.XMLNode, attr, ref string) *epub.DiagBuilder {
+	start, end := int(node.Offset), int(node.Offset)
+	if valueStart, valueEnd, ok := parser.AttrValueRange(c.content, node, attr); ok {
+		start, end = valueStart, valueEnd
+		if i := bytes.Index(c.content[valueStart:valueEnd], []byte(ref)); i >= 0 {
+			start, end = valueStart+i, valueStart+i+len(ref)
+		}
 	}
-	return strings.HasSuffix(full, "/"+suffix)
+	return epub.NewDiagAt(c.lines, start, source).End(c.lines.Position(end))
 }
@@ -1,8 +1,11 @@
 package resource
 
 import (
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/toba/epub-lsp/internal/epub"
 	"github.com/toba/epub-lsp/internal/epub/testutil"
 	"github.com/toba/epub-lsp/internal/epub/validator"
 )
@@ -135,6 +138,10 @@ func TestContentValidator_ResourceNotInManifest(t *testing.T) {
 	for _, d := range diags {
 		if d.Code == "RSC_008" {
 			rsc008Count++
+			// The range covers the src value
+			if r := d.Range; r.Start.Line != 8 || r.Start.Character != 12 || r.End.Character != 21 {
+				t.Errorf("range = %+v, want the src value on line 8", r)
+			}
 		}
 	}
 	if rsc008Count != 1 {
@@ -172,6 +179,37 @@ func TestContentValidator_AllResourcesInManifest(t *testing.T) {
 	}
 }
 
+func TestContentValidator_SameNameInOtherDirectory(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en">
+<head><title>Test</title></head>
+<body><img src="a.png" alt="A"/></body>
+</html>`)
+
+	// The manifest lists OEBPS/a.png, not the OEBPS/text/a.png the image
+	// refers to
+	ctx := &validator.WorkspaceContext{
+		Manifest: &validator.ManifestInfo{
+			URI: "file:///book/OEBPS/content.opf",
+			Items: []validator.ManifestItem{
+				{ID: "c", Href: "text/c.xhtml", MediaType: "application/xhtml+xml"},
+				{ID: "a", Href: "a.png", MediaType: "image/png"},
+			},
+		},
+	}
+
+	diags := (&ContentValidator{}).Validate("file:///book/OEBPS/text/c.xhtml", content, ctx)
+	if !testutil.HasCode(diags, "RSC_008") {
+		t.Errorf("expected RSC_008 for text/a.png, got %v", testutil.DiagCodes(diags))
+	}
+	if item := ctx.Manifest.ItemByPath("/book/OEBPS/text/a.png"); item != nil {
+		t.Errorf("ItemByPath = %+v, want nil", item)
+	}
+	if item := ctx.Manifest.ItemByPath("/book/OEBPS/a.png"); item == nil || item.ID != "a" {
+		t.Errorf("ItemByPath = %+v, want a", item)
+	}
+}
+
 func TestContentValidator_SkipsRemoteAndDataURIs(t *testing.T) {
 	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
 <html xmlns="http://www.w3.org/1999/xhtml" lang="en">
@@ -210,3 +248,159 @@ func TestContentValidator_NilManifest(t *testing.T) {
 		t.Errorf("expected no diagnostics with nil context, got %d", len(diags))
 	}
 }
+
+func TestContentValidator_ResponsiveImagesAndMedia(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en">
+<head><title>Test</title></head>
+<body>
+  <img src="small.jpg" srcset="small.jpg 1x, big.jpg 2x" alt="A"/>
+  <picture>
+    <source srcset="wide.webp 800w, missing.webp 1600w"/>
+    <img src="small.jpg" alt="B"/>
+  </picture>
+  <video src="clip.mp4" poster="poster.png"></video>
+  <object data="chart.svg"></object>
+</body>
+</html>`)
+
+	ctx := &validator.WorkspaceContext{
+		Manifest: &validator.ManifestInfo{
+			Items: []validator.ManifestItem{
+				{ID: "small", Href: "small.jpg", MediaType: "image/jpeg"},
+				{ID: "big", Href: "big.jpg", MediaType: "image/jpeg"},
+				{ID: "wide", Href: "wide.webp", MediaType: "image/webp"},
+				{ID: "clip", Href: "clip.mp4", MediaType: "video/mp4"},
+			},
+		},
+	}
+
+	v := &ContentValidator{}
+	diags := v.Validate("file:///book/OEBPS/chapter1.xhtml", content, ctx)
+
+	var missing []string
+	for _, d := range diags {
+		missing = append(missing, d.Message)
+	}
+	want := []string{
+		"resource not found in manifest: missing.webp",
+		"resource not found in manifest: poster.png",
+		"resource not found in manifest: chart.svg",
+	}
+	if !slices.Equal(missing, want) {
+		t.Fatalf("expected %q, got %q", want, missing)
+	}
+	// A srcset candidate's range covers just its URL
+	if r := diags[0].Range; r.Start.Line != 6 || r.Start.Character != 36 || r.End.Character != 48 {
+		t.Errorf("range = %+v, want missing.webp on line 6", r)
+	}
+}
+
+func TestContentValidator_XMLBase(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en">
+<head><title>Test</title></head>
+<body>
+  <div xml:base="../images/">
+    <img src="cover.jpg" alt="Cover"/>
+    <img src="absent.jpg" alt="Absent"/>
+  </div>
+  <div xml:base="https://example.com/assets/">
+    <img src="remote.jpg" alt="Remote"/>
+  </div>
+</body>
+</html>`)
+
+	ctx := &validator.WorkspaceContext{
+		Manifest: &validator.ManifestInfo{
+			Items: []validator.ManifestItem{
+				{ID: "cover", Href: "images/cover.jpg", MediaType: "image/jpeg"},
+				{ID: "absent", Href: "text/absent.jpg", MediaType: "image/jpeg"},
+			},
+		},
+	}
+
+	v := &ContentValidator{}
+	diags := v.Validate("file:///book/OEBPS/text/chapter1.xhtml", content, ctx)
+
+	// absent.jpg exists next to the chapter, but xml:base moves it to images/
+	if len(diags) != 1 ||
+		diags[0].Message != "resource not found in manifest: absent.jpg" {
+		t.Errorf("expected only absent.jpg to be reported, got %v", diags)
+	}
+}
+
+func TestContentValidator_SVGXLinkHref(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en">
+<head><title>Test</title></head>
+<body>
+  <svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">
+    <title>Cover</title>
+    <image xlink:href="images/cover.jpg" width="600" height="800"/>
+    <image xlink:href="images/back.jpg" width="600" height="800"/>
+  </svg>
+</body>
+</html>`)
+
+	ctx := &validator.WorkspaceContext{
+		Manifest: &validator.ManifestInfo{
+			Items: []validator.ManifestItem{
+				{ID: "cover", Href: "images/cover.jpg", MediaType: "image/jpeg"},
+			},
+		},
+	}
+
+	v := &ContentValidator{}
+	diags := v.Validate("file:///book/OEBPS/cover.xhtml", content, ctx)
+
+	if len(diags) != 1 ||
+		diags[0].Message != "resource not found in manifest: images/back.jpg" {
+		t.Fatalf("expected only back.jpg to be reported, got %v", diags)
+	}
+	if r := diags[0].Range; r.Start.Line != 7 || r.Start.Character != 23 {
+		t.Errorf("range = %+v, want the xlink:href value on line 7", r)
+	}
+}
+
+func TestContentValidator_Hyperlinks(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en">
+<head><title>Test</title></head>
+<body>
+  <p><a href="chapter2.xhtml#s1">Next</a> <a href="#top">Top</a></p>
+  <p><a href="notes.xhtml#n1">Note</a> <a href="gone.xhtml">Gone</a></p>
+  <p><a href="mailto:editor@example.com">Mail</a> <a href="https://example.com/">Web</a></p>
+</body>
+</html>`)
+
+	ctx := &validator.WorkspaceContext{
+		Files: map[string][]byte{
+			"file:///book/OEBPS/chapter1.xhtml": content,
+			"file:///book/OEBPS/chapter2.xhtml": []byte("<html/>"),
+			"file:///book/OEBPS/notes.xhtml":    []byte("<html/>"),
+		},
+		Manifest: &validator.ManifestInfo{
+			Items: []validator.ManifestItem{
+				{ID: "ch1", Href: "chapter1.xhtml", MediaType: "application/xhtml+xml"},
+				{ID: "ch2", Href: "chapter2.xhtml", MediaType: "application/xhtml+xml"},
+			},
+		},
+	}
+
+	diags := (&ContentValidator{}).Validate("file:///book/OEBPS/chapter1.xhtml", content, ctx)
+
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", testutil.DiagCodes(diags))
+	}
+	// notes.xhtml exists but is not listed; the range skips the fragment
+	if d := diags[0]; d.Code != "RSC_008" || d.Severity != epub.SeverityWarning ||
+		d.Range.Start.Line != 5 || d.Range.Start.Character != 14 || d.Range.End.Character != 25 {
+		t.Errorf("unlisted target: %+v", d)
+	}
+	// gone.xhtml does not exist at all
+	if d := diags[1]; d.Code != "RSC_007" || d.Severity != epub.SeverityError ||
+		!strings.Contains(d.Message, "gone.xhtml") {
+		t.Errorf("missing target: %+v", d)
+	}
+}
@@ -0,0 +1,60 @@
+package resource
+
+import "strings"
+
+// parseSrcset returns the image candidate URLs of a srcset attribute,
+// dropping their width and density descriptors. It follows the HTML
+// parsing algorithm loosely: candidates are separated by commas, and a
+// comma inside parentheses in the descriptors does not end a candidate.
+func parseSrcset(value string) []string {
+	var urls []string
+	i := 0
+	for i < len(value) {
+		// Skip separators before the URL
+		for i < len(value) && (isSpace(value[i]) || value[i] == ',') {
+			i++
+		}
+		if i >= len(value) {
+			break
+		}
+
+		start := i
+		for i < len(value) && !isSpace(value[i]) {
+			i++
+		}
+		candidate := value[start:i]
+
+		// A URL ending in commas has no descriptors
+		if trimmed := strings.TrimRight(candidate, ","); trimmed != candidate {
+			urls = append(urls, trimmed)
+			continue
+		}
+		urls = append(urls, candidate)
+
+		i = skipDescriptors(value, i)
+	}
+	return urls
+}
+
+// skipDescriptors returns the index just past the comma that ends the
+// candidate whose descriptors start at i, ignoring commas in parentheses.
+func skipDescriptors(value string, i int) int {
+	depth := 0
+	for ; i < len(value); i++ {
+		switch value[i] {
+		case '(':
+			depth++
+		case ')':
+			depth = max(depth-1, 0)
+		case ',':
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
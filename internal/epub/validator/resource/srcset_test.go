@@ -0,0 +1,48 @@
+package resource
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"single URL", "small.jpg", []string{"small.jpg"}},
+		{
+			"density descriptors",
+			"small.jpg 1x, big.jpg 2x",
+			[]string{"small.jpg", "big.jpg"},
+		},
+		{
+			"width descriptors",
+			"a.jpg 480w,b.jpg 800w",
+			[]string{"a.jpg", "b.jpg"},
+		},
+		{
+			"extra whitespace",
+			"  \n a.jpg   1x ,\t b.jpg\n2x  ",
+			[]string{"a.jpg", "b.jpg"},
+		},
+		{"trailing comma on URL", "a.jpg, b.jpg", []string{"a.jpg", "b.jpg"}},
+		{"empty candidates", " , ,a.jpg,, ", []string{"a.jpg"}},
+		{
+			"comma inside parentheses",
+			"a.jpg (foo, bar), b.jpg 2x",
+			[]string{"a.jpg", "b.jpg"},
+		},
+		{"unbalanced parentheses", "a.jpg ((, b.jpg", []string{"a.jpg"}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSrcset(tt.value); !slices.Equal(got, tt.want) {
+				t.Errorf("parseSrcset(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package resource
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// URLSyntaxValidator reports references to publication resources that are
+// not well-formed URLs: backslashes used as path separators, characters
+// that must be percent-encoded, and values that do not parse at all. Some
+// reading systems tolerate these and others fail to find the resource. It
+// runs on package, content, navigation, and CSS files, and checks only
+// relative references, not remote or data URLs.
+type URLSyntaxValidator struct{}
+
+func (v *URLSyntaxValidator) FileTypes() []epub.FileType {
+	return []epub.FileType{
+		epub.FileTypeOPF,
+		epub.FileTypeXHTML,
+		epub.FileTypeNav,
+		epub.FileTypeNCX,
+		epub.FileTypeCSS,
+	}
+}
+
+// reference is a URL found in a document and the byte offset of its
+// first character.
+type reference struct {
+	offset int
+	value  string
+}
+
+func (v *URLSyntaxValidator) Validate(
+	uri string,
+	content []byte,
+	_ *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	var refs []reference
+	if epub.DetectFileType(uri, content) == epub.FileTypeCSS {
+		for _, u := range parser.CSSURLs(string(content)) {
+			refs = append(refs, reference{u.Offset, u.Value})
+		}
+	} else {
+		parser.ScanAttrs(content, func(name string, offset int, value string) {
+			if name == "xmlns" || strings.HasPrefix(name, "xmlns:") {
+				return
+			}
+			if _, local, found := strings.Cut(name, ":"); found {
+				name = local
+			}
+			if !urlAttrs[name] {
+				return
+			}
+			if name != "srcset" {
+				refs = append(refs, reference{offset, value})
+				return
+			}
+			at := 0
+			for _, candidate := range parseSrcset(value) {
+				i := strings.Index(value[at:], candidate)
+				refs = append(refs, reference{offset + at + i, candidate})
+				at += i + len(candidate)
+			}
+		})
+	}
+
+	var diags []epub.Diagnostic
+	lines := epub.NewLineIndex(content)
+	for _, ref := range refs {
+		diags = append(diags, checkURLSyntax(lines, ref)...)
+	}
+	return diags
+}
+
+// checkURLSyntax reports the syntax problems of one reference.
+func checkURLSyntax(lines *epub.LineIndex, ref reference) []epub.Diagnostic {
+	// Attribute values are trimmed before use as URLs
+	value := strings.TrimRight(ref.value, " \t\n\r\f")
+	trimmed := strings.TrimLeft(value, " \t\n\r\f")
+	offset := ref.offset + len(value) - len(trimmed)
+	value = trimmed
+
+	if value == "" || hasScheme(value) || strings.Contains(value, "{{") {
+		return nil
+	}
+
+	diag := func() *epub.DiagBuilder {
+		return epub.NewDiagAt(lines, offset, source).End(lines.Position(offset + len(value)))
+	}
+	encoded := epub.EncodeURLPath(value)
+
+	if _, err := url.Parse(value); err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		b := diag().Code("url-invalid").Error("invalid URL \"" + value + "\": " + err.Error())
+		if _, encodedErr := url.Parse(encoded); encodedErr == nil && encoded != value {
+			b = b.Fix("Percent-encode the path", epub.AnchorReplaceRange, encoded)
+		}
+		return []epub.Diagnostic{b.Build()}
+	}
+
+	var diags []epub.Diagnostic
+	pathEnd := len(value)
+	if i := strings.IndexAny(value, "?#"); i >= 0 {
+		pathEnd = i
+	}
+	if strings.Contains(value[:pathEnd], `\`) {
+		fixed := strings.ReplaceAll(value[:pathEnd], `\`, "/") + value[pathEnd:]
+		diags = append(diags, diag().Code("url-backslash").
+			Error("URL \""+value+"\" uses backslashes; paths in a publication are separated by /").
+			Fix("Use forward slashes", epub.AnchorReplaceRange, fixed).
+			Build())
+	}
+	if encoded != value {
+		diags = append(diags, diag().Code("url-unencoded").
+			Warning("URL \""+value+"\" has "+unencodedKind(value[:pathEnd])+
+				" that must be percent-encoded").
+			Fix("Percent-encode the path", epub.AnchorReplaceRange, encoded).
+			Build())
+	}
+	return diags
+}
+
+// unencodedKind describes the characters of path that need encoding.
+func unencodedKind(path string) string {
+	var space, nonASCII bool
+	for i := range len(path) {
+		switch c := path[i]; {
+		case c == ' ':
+			space = true
+		case c >= 0x80:
+			nonASCII = true
+		}
+	}
+	switch {
+	case space && nonASCII:
+		return "spaces and non-ASCII characters"
+	case space:
+		return "spaces"
+	case nonASCII:
+		return "non-ASCII characters"
+	}
+	return "characters"
+}
+
+// hasScheme reports whether ref begins with a URL scheme, such as https:
+// or data:, rather than being a relative reference.
+func hasScheme(ref string) bool {
+	for i := range len(ref) {
+		switch c := ref[i]; {
+		case c == ':':
+			return i > 0
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return false
+}
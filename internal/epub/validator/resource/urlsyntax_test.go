@@ -0,0 +1,160 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestURLSyntaxValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		content string
+		// want lists the reported codes and the text their fixes insert
+		want [][2]string
+	}{
+		{
+			"valid references",
+			"ch1.xhtml",
+			`<html><body><img src="images/a%20b.png" alt=""/>` +
+				`<a href="https://example.com/a b">x</a><a href="#sec 1">y</a>` +
+				`<img src="data:image/svg+xml;utf8,<svg a b/>" alt=""/></body></html>`,
+			nil,
+		},
+		{
+			"backslash",
+			"ch1.xhtml",
+			`<img src="images\cover.jpg" alt=""/>`,
+			[][2]string{{"url-backslash", "images/cover.jpg"}},
+		},
+		{
+			"space and existing escape",
+			"package.opf",
+			`<package><manifest><item id="c" href="text/my chapter%201.xhtml"/></manifest></package>`,
+			[][2]string{{"url-unencoded", "text/my%20chapter%201.xhtml"}},
+		},
+		{
+			"non-ASCII with fragment",
+			"nav.xhtml",
+			`<nav><ol><li><a href="café.xhtml#é">x</a></li></ol></nav>`,
+			[][2]string{{"url-unencoded", "caf%C3%A9.xhtml#é"}},
+		},
+		{
+			"backslash and space",
+			"ch1.xhtml",
+			`<img src="images\my cover.jpg" alt=""/>`,
+			[][2]string{
+				{"url-backslash", "images/my cover.jpg"},
+				{"url-unencoded", `images\my%20cover.jpg`},
+			},
+		},
+		{
+			"unparseable",
+			"ch1.xhtml",
+			`<a href="100%.xhtml">x</a>`,
+			[][2]string{{"url-invalid", "100%25.xhtml"}},
+		},
+		{
+			"srcset candidate",
+			"ch1.xhtml",
+			`<img srcset="a.png 1x, b\c.png 2x" alt=""/>`,
+			[][2]string{{"url-backslash", "b/c.png"}},
+		},
+		{
+			"css url",
+			"style.css",
+			`@font-face { src: url("fonts/Nöto Sans.woff2") }`,
+			[][2]string{{"url-unencoded", "fonts/N%C3%B6to%20Sans.woff2"}},
+		},
+		{
+			"templated",
+			"ch1.xhtml",
+			`<a href="{{ .Chapter.Href }}">x</a>`,
+			nil,
+		},
+	}
+
+	v := &URLSyntaxValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte(tt.content)
+			diags := v.Validate(tt.uri, content, nil)
+			if len(diags) != len(tt.want) {
+				t.Fatalf("expected %d diagnostics, got %d: %v", len(tt.want), len(diags), diags)
+			}
+			for i, d := range diags {
+				if d.Code != tt.want[i][0] {
+					t.Errorf("diagnostic %d code = %s, want %s", i, d.Code, tt.want[i][0])
+				}
+				if d.Fix == nil || d.Fix.Anchor != epub.AnchorReplaceRange ||
+					d.Fix.InsertText != tt.want[i][1] {
+					t.Errorf("diagnostic %d fix = %+v, want %q", i, d.Fix, tt.want[i][1])
+				}
+			}
+		})
+	}
+}
+
+func TestURLSyntaxValidator_FixRange(t *testing.T) {
+	content := []byte(`<p><img src=" images\cover.jpg " alt=""/></p>`)
+	diags := (&URLSyntaxValidator{}).Validate("ch1.xhtml", content, nil)
+	if len(diags) != 1 || diags[0].Severity != epub.SeverityError {
+		t.Fatalf("expected 1 error, got %v", diags)
+	}
+
+	d := diags[0]
+	lines := epub.NewLineIndex(content)
+	start, end := lines.Offset(d.Range.Start), lines.Offset(d.Range.End)
+	fixed := string(content[:start]) + d.Fix.InsertText + string(content[end:])
+	if want := `<p><img src=" images/cover.jpg " alt=""/></p>`; fixed != want {
+		t.Errorf("fixed content = %q, want %q", fixed, want)
+	}
+}
+
+func TestURLSyntax_EncodedFormResolves(t *testing.T) {
+	opfContent := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="c1" href="text/my%20chapter.xhtml" media-type="application/xhtml+xml"/>
+    <item id="img" href="images/caf%C3%A9.png" media-type="image/png"/>
+  </manifest>
+</package>`)
+	chapter := []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<img src="../images/caf%C3%A9.png" alt=""/>
+</body></html>`)
+
+	ctx := &validator.WorkspaceContext{
+		Files: map[string][]byte{
+			"file:///book/OEBPS/package.opf":           opfContent,
+			"file:///book/OEBPS/text/my chapter.xhtml": chapter,
+			"file:///book/OEBPS/images/café.png":       nil,
+		},
+		Manifest: &validator.ManifestInfo{
+			URI: "file:///book/OEBPS/package.opf",
+			Items: []validator.ManifestItem{
+				{ID: "c1", Href: "text/my%20chapter.xhtml", MediaType: "application/xhtml+xml"},
+				{ID: "img", Href: "images/caf%C3%A9.png", MediaType: "image/png"},
+			},
+		},
+	}
+
+	diags := (&ManifestValidator{}).Validate("file:///book/OEBPS/package.opf", opfContent, ctx)
+	if testutil.HasCode(diags, "RSC_007") {
+		t.Errorf("expected encoded manifest hrefs to resolve, got %v", diags)
+	}
+	diags = (&ContentValidator{}).Validate("file:///book/OEBPS/text/my chapter.xhtml", chapter, ctx)
+	if testutil.HasCode(diags, "RSC_008") {
+		t.Errorf("expected encoded content reference to match the manifest, got %v", diags)
+	}
+	if item := ctx.Manifest.ItemByPath("/book/OEBPS/text/my chapter.xhtml"); item == nil || item.ID != "c1" {
+		t.Errorf("ItemByPath = %+v, want c1", item)
+	}
+	for _, uri := range []string{"file:///book/OEBPS/package.opf", "file:///book/OEBPS/text/my chapter.xhtml"} {
+		if diags := (&URLSyntaxValidator{}).Validate(uri, ctx.Files[uri], ctx); len(diags) != 0 {
+			t.Errorf("%s: unexpected syntax diagnostics %v", uri, diags)
+		}
+	}
+}
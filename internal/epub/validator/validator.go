@@ -2,9 +2,12 @@
 package validator
 
 import (
+	"maps"
 	"slices"
+	"strings"
 
 	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
 )
 
 // Validator validates EPUB source files of specific types.
@@ -44,15 +47,143 @@ type MetadataInfo struct {
 	HasTitle bool
 	// HasLanguage is true if dc:language exists.
 	HasLanguage bool
+	// Languages lists the dc:language values in document order.
+	Languages []string
+}
+
+// NCXMediaType is the media type of the EPUB 2 NCX navigation document.
+const NCXMediaType = "application/x-dtbncx+xml"
+
+// CoreMediaTypes lists the EPUB core media types, which reading systems
+// must support without a fallback.
+var CoreMediaTypes = []string{
+	"application/xhtml+xml",
+	NCXMediaType,
+	"text/css",
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/svg+xml",
+	"image/webp",
+	"application/javascript",
+	"application/ecmascript",
+	"text/javascript",
+	"application/smil+xml",
+	"application/pls+xml",
+	"audio/mpeg",
+	"audio/mp4",
+	"audio/ogg",
+	"application/font-woff",
+	"application/font-sfnt",
+	"application/vnd.ms-opentype",
+	"font/otf",
+	"font/ttf",
+	"font/woff",
+	"font/woff2",
+}
+
+// IsCoreMediaType reports whether mediaType, ignoring any parameters, is an
+// EPUB core media type.
+func IsCoreMediaType(mediaType string) bool {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return slices.Contains(CoreMediaTypes, mediaType)
+}
+
+// extensionMediaTypes maps lowercase file extensions to the media types of
+// the publication resources they usually hold.
+var extensionMediaTypes = map[string]string{
+	".xhtml": "application/xhtml+xml",
+	".html":  "application/xhtml+xml",
+	".htm":   "application/xhtml+xml",
+	".ncx":   NCXMediaType,
+	".css":   "text/css",
+	".js":    "application/javascript",
+	".jpg":   "image/jpeg",
+	".jpeg":  "image/jpeg",
+	".png":   "image/png",
+	".gif":   "image/gif",
+	".svg":   "image/svg+xml",
+	".webp":  "image/webp",
+	".smil":  "application/smil+xml",
+	".pls":   "application/pls+xml",
+	".mp3":   "audio/mpeg",
+	".m4a":   "audio/mp4",
+	".ogg":   "audio/ogg",
+	".opus":  "audio/ogg",
+	".mp4":   "video/mp4",
+	".webm":  "video/webm",
+	".vtt":   "text/vtt",
+	".otf":   "font/otf",
+	".ttf":   "font/ttf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// MediaTypeForExtension returns the media type of files with extension
+// ext, such as ".png", or "" when it is not a known resource type.
+func MediaTypeForExtension(ext string) string {
+	return extensionMediaTypes[strings.ToLower(ext)]
 }
 
 // ManifestInfo holds parsed OPF manifest, spine, and metadata.
 type ManifestInfo struct {
+	// URI is the package document the manifest was read from, or empty
+	// when it was parsed from content alone.
+	URI string
+	// Version is the package version attribute, such as "3.0" or "2.0.1".
+	Version string
+	// Prefixes maps the prefixes declared in the package prefix attribute
+	// to their vocabulary URIs.
+	Prefixes map[string]string
 	Items    []ManifestItem
 	Spine    []SpineItem
 	Metadata MetadataInfo
 }
 
+// NCX returns the manifest item for the NCX document, or nil if there is
+// none.
+func (m *ManifestInfo) NCX() *ManifestItem {
+	for i := range m.Items {
+		if m.Items[i].MediaType == NCXMediaType {
+			return &m.Items[i]
+		}
+	}
+	return nil
+}
+
+// IsEPUB2 reports whether a package version names EPUB 2.
+func IsEPUB2(version string) bool {
+	return strings.HasPrefix(strings.TrimSpace(version), "2")
+}
+
+// ItemByPath returns the manifest item whose href, resolved against the
+// package document, is the resolved path, or nil if none is. A manifest
+// parsed without a URI has no directory to resolve against, so there the
+// path need only end with the percent-decoded href.
+func (m *ManifestInfo) ItemByPath(resolved string) *ManifestItem {
+	for i := range m.Items {
+		if m.itemPath(&m.Items[i]) == resolved ||
+			m.URI == "" && epub.PathEndsWith(resolved, epub.DecodeHref(m.Items[i].Href)) {
+			return &m.Items[i]
+		}
+	}
+	return nil
+}
+
+// itemPath returns the path of item, its href resolved against the package
+// document, or "" for a remote item or a manifest parsed without a URI.
+func (m *ManifestInfo) itemPath(item *ManifestItem) string {
+	if m.URI == "" || item.Href == "" {
+		return ""
+	}
+	resolved := uriutil.ResolveRelative(m.URI, item.Href)
+	if resolved == "" {
+		return ""
+	}
+	return uriutil.Path(resolved)
+}
+
 // WorkspaceContext provides cross-file information for validators.
 type WorkspaceContext struct {
 	RootPath  string
@@ -62,6 +193,37 @@ type WorkspaceContext struct {
 	// AccessibilitySeverity controls accessibility diagnostic severity.
 	// 0 = ignore (skip checks), 1 = error, 2 = warning (default).
 	AccessibilitySeverity int
+	// Version is the EPUB version to assume when no package document has
+	// been parsed. Empty means EPUB 3.
+	Version string
+	// AltRedundantPhrases lists the phrases, such as "image of", reported
+	// at the start of image alt text. Nil uses the accessibility defaults.
+	AltRedundantPhrases []string
+	// FakeHeadings enables the heuristic check for paragraphs styled to
+	// look like headings.
+	FakeHeadings bool
+	// MultipleH1 reports content documents with more than one h1.
+	MultipleH1 bool
+	// DisabledCodes holds the diagnostic codes not to report.
+	DisabledCodes map[string]bool
+	// ProseLineLength, when positive, is the longest line allowed in the
+	// paragraphs and other prose blocks of content documents.
+	ProseLineLength int
+	// DuplicateResources reports manifest items with identical content on
+	// the package document.
+	DuplicateResources bool
+}
+
+// EPUB2 reports whether the workspace is validated as EPUB 2: by the open
+// package document's version, or by Version when there is none.
+func (c *WorkspaceContext) EPUB2() bool {
+	if c == nil {
+		return false
+	}
+	if c.Manifest != nil && c.Manifest.Version != "" {
+		return IsEPUB2(c.Manifest.Version)
+	}
+	return IsEPUB2(c.Version)
 }
 
 // Registry holds all registered validators and dispatches validation.
@@ -79,7 +241,10 @@ func (r *Registry) Register(v Validator) {
 	r.validators = append(r.validators, v)
 }
 
-// ValidateFile runs all validators that match the given file type.
+// ValidateFile runs all validators that match the given file type. The
+// codes of validators implementing CodeLimiter are capped with Cap, and
+// codes the context disables are dropped. A validator that panics is
+// reported as an internal error without stopping the others.
 func (r *Registry) ValidateFile(
 	uri string,
 	content []byte,
@@ -87,12 +252,27 @@ func (r *Registry) ValidateFile(
 	ctx *WorkspaceContext,
 ) []epub.Diagnostic {
 	var diags []epub.Diagnostic
+	limits := make(map[string]int)
 
 	for _, v := range r.validators {
 		if slices.Contains(v.FileTypes(), fileType) {
-			diags = append(diags, v.Validate(uri, content, ctx)...)
+			diags = append(diags, validateSafely(v, uri, content, ctx)...)
+			if l, ok := v.(CodeLimiter); ok {
+				maps.Copy(limits, l.CodeLimits())
+			}
 		}
 	}
 
+	diags = Cap(Dedupe(diags), limits)
+	if ctx != nil && len(ctx.DisabledCodes) > 0 {
+		diags = slices.DeleteFunc(diags, func(d epub.Diagnostic) bool {
+			return ctx.DisabledCodes[d.Code]
+		})
+	}
+	for i := range diags {
+		if diags[i].Explanation == "" {
+			diags[i].Explanation = epub.CodeExplanation(diags[i].Code)
+		}
+	}
 	return diags
 }
@@ -0,0 +1,88 @@
+package whitespace
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// maxLongLines is the most prose-line-length diagnostics reported per
+// file.
+const maxLongLines = 50
+
+// proseBlocks lists the content document elements whose lines are
+// measured, the same blocks the formatter wraps by sentence.
+var proseBlocks = map[string]bool{
+	"p":          true,
+	"li":         true,
+	"blockquote": true,
+	"dd":         true,
+	"figcaption": true,
+}
+
+// longProseLines reports lines starting inside a prose block of a content
+// document that are longer than limit characters. Each diagnostic covers
+// the part of the line past the limit.
+func longProseLines(content []byte, lines *epub.LineIndex, limit int) []epub.Diagnostic {
+	root, xmlDiags := parser.Parse(content)
+	if len(xmlDiags) > 0 || root == nil {
+		return nil
+	}
+	html := root.FindFirst("html")
+	if html == nil || html.Space != epub.NSXHTML {
+		return nil
+	}
+
+	var blocks []line
+	var collect func(node *parser.XMLNode)
+	collect = func(node *parser.XMLNode) {
+		for _, child := range node.Children {
+			if child.Space == epub.NSXHTML && proseBlocks[child.Local] {
+				blocks = append(blocks, line{int(child.Offset), int(child.End)})
+				continue
+			}
+			collect(child)
+		}
+	}
+	collect(html)
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	var diags []epub.Diagnostic
+	for _, l := range splitLines(content) {
+		if !inBlock(blocks, l.start, l.end) {
+			continue
+		}
+		text := content[l.start:l.end]
+		length := utf8.RuneCount(text)
+		if length <= limit {
+			continue
+		}
+		over := l.start
+		for range limit {
+			_, size := utf8.DecodeRune(content[over:l.end])
+			over += size
+		}
+		diags = append(diags, epub.NewDiagAt(lines, over, source).
+			End(lines.Position(l.end)).
+			Code("prose-line-length").
+			Hint("line is "+strconv.Itoa(length)+" characters long, over the prose limit of "+
+				strconv.Itoa(limit)).
+			Build())
+	}
+	return diags
+}
+
+// inBlock reports whether the line from start to end overlaps one of
+// blocks.
+func inBlock(blocks []line, start, end int) bool {
+	for _, b := range blocks {
+		if start < b.end && end > b.start {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+// Package whitespace checks source hygiene in EPUB sources: consistent
+// indentation, trailing whitespace, and a final newline.
+package whitespace
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+const source = "epub-whitespace"
+
+// maxTrailing is the most trailing whitespace diagnostics reported per file.
+const maxTrailing = 50
+
+// Validator reports mixed indentation, trailing whitespace, a missing
+// final newline, and, when a limit is set, long lines of prose as hints.
+type Validator struct{}
+
+func (v *Validator) FileTypes() []epub.FileType {
+	return []epub.FileType{
+		epub.FileTypeOPF,
+		epub.FileTypeXHTML,
+		epub.FileTypeNav,
+		epub.FileTypeCSS,
+	}
+}
+
+// CodeLimits caps trailing whitespace, which editors can leave on every
+// line of a file, and long prose lines, which unwrapped paragraphs produce
+// throughout.
+func (v *Validator) CodeLimits() map[string]int {
+	return map[string]int{"WS_002": maxTrailing, "prose-line-length": maxLongLines}
+}
+
+func (v *Validator) Validate(
+	_ string,
+	content []byte,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	if len(content) == 0 {
+		return nil
+	}
+
+	lines := epub.NewLineIndex(content)
+	var diags []epub.Diagnostic
+	if d, ok := mixedIndent(content, lines); ok {
+		diags = append(diags, d)
+	}
+
+	for _, line := range splitLines(content) {
+		text := content[line.start:line.end]
+		trimmed := bytes.TrimRight(text, " \t")
+		if len(trimmed) == len(text) {
+			continue
+		}
+		start := line.start + len(trimmed)
+		diags = append(diags, epub.NewDiagAt(lines, start, source).
+			End(lines.Position(line.end)).
+			Code("WS_002").
+			Hint("trailing whitespace").
+			Fix("Remove trailing whitespace", epub.AnchorReplaceRange, "").
+			Build())
+	}
+
+	if ctx != nil && ctx.ProseLineLength > 0 {
+		diags = append(diags, longProseLines(content, lines, ctx.ProseLineLength)...)
+	}
+
+	if last := content[len(content)-1]; last != '\n' && last != '\r' {
+		diags = append(diags, epub.NewDiagAt(lines, len(content), source).
+			Code("WS_003").
+			Hint("file does not end with a newline").
+			Fix("Add final newline", epub.AnchorReplaceRange,
+				epub.DetectLineEnding(content)).
+			Build())
+	}
+
+	return diags
+}
+
+// line is the byte span of one line, without its terminator.
+type line struct {
+	start, end int
+}
+
+// splitLines returns the lines of content, ended by "\n", "\r\n", or "\r".
+func splitLines(content []byte) []line {
+	var result []line
+	start := 0
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '\n':
+			result = append(result, line{start, i})
+			start = i + 1
+		case '\r':
+			result = append(result, line{start, i})
+			if i+1 < len(content) && content[i+1] == '\n' {
+				i++
+			}
+			start = i + 1
+		}
+	}
+	if start < len(content) {
+		result = append(result, line{start, len(content)})
+	}
+	return result
+}
+
+// indentStyle counts the lines indented with tabs and with spaces, by
+// their first character, and records the first of each. Lines holding only
+// whitespace are trailing whitespace rather than indentation.
+type indentStyle struct {
+	tabs, spaces         int
+	firstTab, firstSpace line
+	narrowestSpace       int
+}
+
+func scanIndent(content []byte) indentStyle {
+	var style indentStyle
+	for _, l := range splitLines(content) {
+		text := content[l.start:l.end]
+		width := len(text) - len(bytes.TrimLeft(text, " \t"))
+		if width == 0 || width == len(text) {
+			continue
+		}
+		indent := line{l.start, l.start + width}
+		if text[0] == '\t' {
+			if style.tabs == 0 {
+				style.firstTab = indent
+			}
+			style.tabs++
+			continue
+		}
+		if style.spaces == 0 {
+			style.firstSpace = indent
+		}
+		style.spaces++
+		spaces := len(text) - len(bytes.TrimLeft(text, " "))
+		if style.narrowestSpace == 0 || spaces < style.narrowestSpace {
+			style.narrowestSpace = spaces
+		}
+	}
+	return style
+}
+
+// mixedIndent reports the first line indented in the less common style
+// when content uses both tabs and spaces, with the number of such lines.
+func mixedIndent(content []byte, lines *epub.LineIndex) (epub.Diagnostic, bool) {
+	style := scanIndent(content)
+	if style.tabs == 0 || style.spaces == 0 {
+		return epub.Diagnostic{}, false
+	}
+
+	first, count, minority, majority := style.firstTab, style.tabs, "tabs", "spaces"
+	if style.tabs > style.spaces {
+		first, count, minority, majority = style.firstSpace, style.spaces, "spaces", "tabs"
+	}
+	noun := "lines are"
+	if count == 1 {
+		noun = "line is"
+	}
+	return epub.NewDiagAt(lines, first.start, source).
+		End(lines.Position(first.end)).
+		Code("WS_001").
+		Hint(strconv.Itoa(count) + " " + noun + " indented with " + minority +
+			" while the rest of the file uses " + majority).
+		Build(), true
+}
+
+// Indent returns the indentation most of content uses: a tab, or the
+// narrowest run of spaces a line is indented by. Content without indented
+// lines gets two spaces.
+func Indent(content []byte) string {
+	style := scanIndent(content)
+	if style.tabs > style.spaces {
+		return "\t"
+	}
+	if style.narrowestSpace == 0 {
+		return "  "
+	}
+	return strings.Repeat(" ", min(style.narrowestSpace, 8))
+}
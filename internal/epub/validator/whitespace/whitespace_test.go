@@ -0,0 +1,165 @@
+package whitespace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestCleanFile(t *testing.T) {
+	content := []byte("<html>\n  <body>\n    <p>Text</p>\n  </body>\n</html>\n")
+
+	v := &Validator{}
+	if diags := v.Validate("ch.xhtml", content, nil); len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestMixedIndent(t *testing.T) {
+	content := []byte("body {\n  margin: 0;\n\tpadding: 0;\n  color: black;\n\tfont: serif;\n  border: 0;\n}\n")
+
+	v := &Validator{}
+	diags := v.Validate("style.css", content, nil)
+	if len(diags) != 1 || diags[0].Code != "WS_001" {
+		t.Fatalf("expected one WS_001, got %v", diags)
+	}
+	d := diags[0]
+	want := epub.Range{
+		Start: epub.Position{Line: 2, Character: 0},
+		End:   epub.Position{Line: 2, Character: 1},
+	}
+	if d.Range != want {
+		t.Errorf("range = %v, want %v", d.Range, want)
+	}
+	if d.Severity != epub.SeverityHint {
+		t.Errorf("severity = %s, want Hint", testutil.SeverityName(d.Severity))
+	}
+	if !strings.Contains(d.Message, "2 lines are indented with tabs") {
+		t.Errorf("expected count of tab-indented lines, got %q", d.Message)
+	}
+}
+
+func TestTrailingWhitespace(t *testing.T) {
+	content := []byte("<p>One</p>  \r\n<p>Two</p>\r\n\t\r\n")
+
+	v := &Validator{}
+	diags := v.Validate("ch.xhtml", content, nil)
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diags)
+	}
+	want := []epub.Range{
+		{Start: epub.Position{Line: 0, Character: 10}, End: epub.Position{Line: 0, Character: 12}},
+		{Start: epub.Position{Line: 2, Character: 0}, End: epub.Position{Line: 2, Character: 1}},
+	}
+	for i, d := range diags {
+		if d.Code != "WS_002" || d.Range != want[i] {
+			t.Errorf("diagnostic %d = %s %v, want WS_002 %v", i, d.Code, d.Range, want[i])
+		}
+		if d.Fix == nil || d.Fix.InsertText != "" {
+			t.Errorf("expected a fix removing the whitespace, got %+v", d.Fix)
+		}
+	}
+}
+
+func TestTrailingWhitespaceCapped(t *testing.T) {
+	content := []byte(strings.Repeat("<p>Text</p> \n", maxTrailing+10))
+
+	registry := validator.NewRegistry()
+	registry.Register(&Validator{})
+	diags := registry.ValidateFile("ch.xhtml", content, epub.FileTypeXHTML, nil)
+	if len(diags) != maxTrailing+1 {
+		t.Fatalf("expected %d diagnostics, got %d", maxTrailing+1, len(diags))
+	}
+	if last := diags[maxTrailing].Message; !strings.Contains(last, "10 more") {
+		t.Errorf("expected a summary of the rest, got %q", last)
+	}
+}
+
+func TestMissingFinalNewline(t *testing.T) {
+	tests := []struct {
+		name, content, ending string
+	}{
+		{"LF", "a {\n  color: red;\n}", "\n"},
+		{"CRLF", "a {\r\n  color: red;\r\n}", "\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validator{}
+			diags := v.Validate("style.css", []byte(tt.content), nil)
+			if len(diags) != 1 || diags[0].Code != "WS_003" {
+				t.Fatalf("expected one WS_003, got %v", diags)
+			}
+			end := epub.Position{Line: 2, Character: 1}
+			if diags[0].Range.Start != end {
+				t.Errorf("range starts at %v, want end of file %v", diags[0].Range.Start, end)
+			}
+			if diags[0].Fix == nil || diags[0].Fix.InsertText != tt.ending {
+				t.Errorf("expected a fix inserting %q, got %+v", tt.ending, diags[0].Fix)
+			}
+		})
+	}
+}
+
+func TestIndent(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"none", "<p/>\n", "  "},
+		{"tabs", "<a>\n\t<b/>\n\t<c/>\n  <d/>\n</a>\n", "\t"},
+		{"four spaces", "<a>\n    <b>\n        <c/>\n    </b>\n</a>\n", "    "},
+		{"tie prefers spaces", "<a>\n\t<b/>\n  <c/>\n</a>\n", "  "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Indent([]byte(tt.content)); got != tt.want {
+				t.Errorf("Indent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProseLineLength(t *testing.T) {
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml">
+  <body>
+    <div>A long line outside any prose block is not measured at all.</div>
+    <p>
+      Short line.
+      This line inside the paragraph runs past the limit.
+    </p>
+  </body>
+</html>
+`)
+
+	v := &Validator{}
+	if diags := v.Validate("ch.xhtml", content, nil); len(diags) != 0 {
+		t.Fatalf("expected no diagnostics without a limit, got %v", diags)
+	}
+
+	ctx := &validator.WorkspaceContext{ProseLineLength: 40}
+	diags := v.Validate("ch.xhtml", content, ctx)
+	if len(diags) != 1 || diags[0].Code != "prose-line-length" {
+		t.Fatalf("expected one prose-line-length, got %v", diags)
+	}
+	d := diags[0]
+	want := epub.Range{
+		Start: epub.Position{Line: 5, Character: 40},
+		End:   epub.Position{Line: 5, Character: 57},
+	}
+	if d.Range != want {
+		t.Errorf("range = %v, want %v", d.Range, want)
+	}
+	if d.Severity != epub.SeverityHint ||
+		d.Message != "line is 57 characters long, over the prose limit of 40" {
+		t.Errorf("unexpected diagnostic %s %q", testutil.SeverityName(d.Severity), d.Message)
+	}
+
+	css := []byte("body { font-family: serif, sans-serif, monospace, cursive; }\n")
+	if diags := v.Validate("style.css", css, ctx); len(diags) != 0 {
+		t.Errorf("expected stylesheets to be skipped, got %v", diags)
+	}
+}
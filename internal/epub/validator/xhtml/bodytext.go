@@ -0,0 +1,143 @@
+package xhtml
+
+import (
+	"bytes"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// phrasing names the inline elements that belong to a run of bare body
+// text, so that "Some <em>bare</em> text" is wrapped as one paragraph.
+var phrasing = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true,
+	"br": true, "cite": true, "code": true, "data": true, "dfn": true,
+	"em": true, "i": true, "kbd": true, "mark": true, "q": true,
+	"s": true, "samp": true, "small": true, "span": true, "strong": true,
+	"sub": true, "sup": true, "time": true, "u": true, "var": true,
+}
+
+// span is a byte range of the content.
+type span struct {
+	start, end int
+}
+
+// validateBodyText reports text that is a direct child of <body> rather
+// than of a block element such as <p>. Each run of such text, with any
+// inline elements within it, gets one error and a fix wrapping the run in
+// a paragraph. Whitespace around the run is left where it is.
+func validateBodyText(content []byte, root *parser.XMLNode) []epub.Diagnostic {
+	body := root.FindFirst("body")
+	if body == nil || body.End == 0 {
+		return nil
+	}
+	contentStart, _, _ := parser.ElementSpan(content, int(body.Offset))
+	contentEnd := bytes.LastIndex(content[:body.End], []byte("</"))
+	if contentEnd < contentStart {
+		return nil
+	}
+
+	var (
+		runs    []span
+		run     = span{-1, -1}
+		hasText bool
+	)
+	extend := func(s span) {
+		if run.start < 0 {
+			run.start = s.start
+		}
+		run.end = s.end
+	}
+	flush := func() {
+		if hasText {
+			runs = append(runs, run)
+		}
+		run, hasText = span{-1, -1}, false
+	}
+
+	cursor := contentStart
+	for _, child := range body.Children {
+		for _, text := range textSpans(content, cursor, int(child.Offset)) {
+			extend(text)
+			hasText = true
+		}
+		if phrasing[child.Local] {
+			extend(span{int(child.Offset), int(child.End)})
+		} else {
+			flush()
+		}
+		cursor = int(child.End)
+	}
+	for _, text := range textSpans(content, cursor, contentEnd) {
+		extend(text)
+		hasText = true
+	}
+	flush()
+
+	lines := epub.NewLineIndex(content)
+	diags := make([]epub.Diagnostic, 0, len(runs))
+	for _, r := range runs {
+		diags = append(diags, epub.NewDiagAt(lines, r.start, source).
+			End(lines.Position(r.end)).
+			Code("body-text").
+			Error("text directly inside <body> must be in a block element such as <p>").
+			Fix("Wrap text in <p>", epub.AnchorReplaceRange,
+				"<p>"+string(content[r.start:r.end])+"</p>").
+			Build())
+	}
+	return diags
+}
+
+// textSpans returns the runs of character data between from and to,
+// trimmed of whitespace. Comments, processing instructions, CDATA
+// sections, and Go template actions separate runs and are not text.
+func textSpans(content []byte, from, to int) []span {
+	var spans []span
+	start := -1
+	end := -1
+	emit := func() {
+		if start >= 0 {
+			spans = append(spans, span{start, end})
+		}
+		start, end = -1, -1
+	}
+
+	for i := from; i < to; {
+		rest := content[i:to]
+		var skip int
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			skip = skipTo(rest, "-->")
+		case bytes.HasPrefix(rest, []byte("<![CDATA[")):
+			skip = skipTo(rest, "]]>")
+		case bytes.HasPrefix(rest, []byte("<?")):
+			skip = skipTo(rest, "?>")
+		case bytes.HasPrefix(rest, []byte("{{")):
+			skip = skipTo(rest, "}}")
+		}
+		if skip > 0 {
+			emit()
+			i += skip
+			continue
+		}
+
+		if c := content[i]; c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			if start < 0 {
+				start = i
+			}
+			end = i + 1
+		}
+		i++
+	}
+	emit()
+	return spans
+}
+
+// skipTo returns the length of b up to and including the first terminator,
+// or all of b when there is none.
+func skipTo(b []byte, terminator string) int {
+	if i := bytes.Index(b, []byte(terminator)); i >= 0 {
+		return i + len(terminator)
+	}
+	return len(b)
+}
@@ -0,0 +1,85 @@
+package xhtml
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+// applyFix returns content with the replace-range fix of d applied.
+func applyFix(t *testing.T, content []byte, d epub.Diagnostic) string {
+	t.Helper()
+	if d.Fix == nil || d.Fix.Anchor != epub.AnchorReplaceRange {
+		t.Fatalf("expected a replace-range fix, got %+v", d.Fix)
+	}
+	lines := epub.NewLineIndex(content)
+	start, end := lines.Offset(d.Range.Start), lines.Offset(d.Range.End)
+	return string(content[:start]) + d.Fix.InsertText + string(content[end:])
+}
+
+func TestBodyText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		// want lists the text runs reported, in order
+		want []string
+	}{
+		{"paragraphs only", "\n  <p>Text</p>\n  <div><span>x</span></div>\n", nil},
+		{"bare text", "\n  Hello there.\n", []string{"Hello there."}},
+		{
+			"runs split by blocks",
+			"\n  First <em>bit</em> here\n  <p>ok</p>\n  Second\n",
+			[]string{"First <em>bit</em> here", "Second"},
+		},
+		{"inline element alone", "\n  <span>x</span>\n  <p>ok</p>\n", nil},
+		{"comments and templates", "\n  <!-- note -->\n  {{ .Content }}\n", nil},
+		{"text around a template", "\n  Dear {{ .Name }},\n", []string{"Dear {{ .Name }},"}},
+		{"entity reference", "\n  &#8212;\n", []string{"&#8212;"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+			var got []string
+			for _, d := range (&Validator{}).Validate("chapter.xhtml", content, nil) {
+				if d.Code != "body-text" {
+					continue
+				}
+				if d.Severity != epub.SeverityError {
+					t.Errorf("severity = %d, want error", d.Severity)
+				}
+				lines := epub.NewLineIndex(content)
+				got = append(got, string(content[lines.Offset(d.Range.Start):lines.Offset(d.Range.End)]))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("reported %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("run %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBodyText_Fix(t *testing.T) {
+	content := testutil.XHTMLDocument{Body: "\n  Some <b>bold</b> text\n  <p>After</p>\n"}.Bytes()
+	var fixed string
+	for _, d := range (&Validator{}).Validate("chapter.xhtml", content, nil) {
+		if d.Code == "body-text" {
+			fixed = applyFix(t, content, d)
+		}
+	}
+
+	want := string(testutil.XHTMLDocument{Body: "\n  <p>Some <b>bold</b> text</p>\n  <p>After</p>\n"}.Bytes())
+	if fixed != want {
+		t.Errorf("fixed content =\n%s\nwant\n%s", fixed, want)
+	}
+	for _, d := range (&Validator{}).Validate("chapter.xhtml", []byte(fixed), nil) {
+		if d.Code == "body-text" {
+			t.Errorf("unexpected diagnostic after fix: %+v", d)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package xhtml
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// DirValue is a value of the HTML dir attribute.
+type DirValue struct {
+	Name   string
+	Detail string
+}
+
+// DirValues lists the values of the dir attribute.
+var DirValues = []DirValue{
+	{"ltr", "Left-to-right text"},
+	{"rtl", "Right-to-left text"},
+	{"auto", "Direction from the first strong character of the content"},
+}
+
+// rtlLanguages are the primary language subtags of the languages most
+// often written right to left.
+var rtlLanguages = map[string]bool{
+	"ar": true,
+	"he": true,
+	"fa": true,
+	"ur": true,
+}
+
+// validateDirection checks the dir attribute, which EPUB uses in place of
+// the CSS direction and unicode-bidi properties: its value must be ltr,
+// rtl, or auto, and <bdo> must set it to ltr or rtl. When the package's
+// primary language is written right to left, a document without a
+// direction on its html element gets a suggestion to add one.
+func validateDirection(
+	content []byte,
+	root *parser.XMLNode,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	lines := epub.NewLineIndex(content)
+	var diags []epub.Diagnostic
+
+	walk(root, func(node *parser.XMLNode) {
+		value, hasDir := dirAttr(node)
+		if hasDir && !isDirValue(value) {
+			diags = append(diags, attrDiag(content, lines, node, "dir").Code("dir-value").
+				Error(`dir must be "ltr", "rtl", or "auto", not "`+value+`"`).Build())
+		}
+
+		if node.Local == "bdo" {
+			switch v := strings.ToLower(strings.TrimSpace(value)); {
+			case !hasDir:
+				diags = append(diags, tagNameDiag(content, lines, node).Code("bdo-dir").
+					Error(`<bdo> requires a dir attribute of "ltr" or "rtl"`).
+					Fix(`Add dir="rtl"`, epub.AnchorEndOfStartTag, ` dir="rtl"`).
+					Build())
+			case v == "auto":
+				diags = append(diags, tagNameDiag(content, lines, node).Code("bdo-dir").
+					Error(`<bdo> overrides the direction, so its dir must be "ltr" or "rtl", not "auto"`).
+					Build())
+			}
+		}
+	})
+
+	if html := root.FindFirst("html"); html != nil && ctx != nil && ctx.Manifest != nil {
+		if _, hasDir := dirAttr(html); !hasDir && rtlDocument(html, ctx.Manifest.Metadata.Languages) {
+			diags = append(diags, tagNameDiag(content, lines, html).Code("dir-rtl-language").
+				Info("the publication language is written right to left; "+
+					`set dir="rtl" on <html> so text and punctuation are ordered correctly`).
+				Fix(`Add dir="rtl"`, epub.AnchorEndOfStartTag, ` dir="rtl"`).
+				Build())
+		}
+	}
+
+	return diags
+}
+
+// dirAttr returns the value of the element's dir attribute and whether it
+// has one.
+func dirAttr(node *parser.XMLNode) (string, bool) {
+	for _, a := range node.Attrs {
+		if a.Local == "dir" && a.Space == "" {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// isDirValue reports whether value is a dir keyword, which HTML matches
+// without regard to ASCII case.
+func isDirValue(value string) bool {
+	return slices.ContainsFunc(DirValues, func(d DirValue) bool {
+		return strings.EqualFold(d.Name, value)
+	})
+}
+
+// rtlDocument reports whether a document is in a right-to-left language:
+// the package's first dc:language must be one, and so must the document's
+// own lang or xml:lang when it has one.
+func rtlDocument(html *parser.XMLNode, packageLanguages []string) bool {
+	if len(packageLanguages) == 0 || !isRTLLanguage(packageLanguages[0]) {
+		return false
+	}
+	lang := html.AttrNS(epub.NSXML, "lang")
+	if lang == "" {
+		lang = html.Attr("lang")
+	}
+	return lang == "" || isRTLLanguage(lang)
+}
+
+// isRTLLanguage reports whether a language tag's primary subtag names a
+// right-to-left language.
+func isRTLLanguage(tag string) bool {
+	primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	return rtlLanguages[strings.ToLower(primary)]
+}
+
+// tagNameDiag starts a diagnostic spanning the element's start tag name.
+func tagNameDiag(content []byte, lines *epub.LineIndex, node *parser.XMLNode) *epub.DiagBuilder {
+	name, _ := parser.TagNameSpans(content, int(node.Offset))
+	return epub.NewDiagAt(lines, name[0], source).End(lines.Position(name[1]))
+}
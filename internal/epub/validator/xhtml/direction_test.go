@@ -0,0 +1,102 @@
+package xhtml
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestDirValue(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"ltr", `<p dir="ltr">Text</p>`, false},
+		{"rtl", `<p dir="rtl">نص</p>`, false},
+		{"auto", `<p dir="auto">Text</p>`, false},
+		{"case-insensitive", `<p dir="RTL">نص</p>`, false},
+		{"invalid", `<p dir="right">Text</p>`, true},
+		{"empty", `<p dir="">Text</p>`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+			diags := (&Validator{}).Validate("chapter.xhtml", content, nil)
+			if got := testutil.HasCode(diags, "dir-value"); got != tt.want {
+				t.Errorf("dir-value = %v, want %v (%v)", got, tt.want, testutil.DiagCodes(diags))
+			}
+		})
+	}
+
+	content := testutil.XHTMLDocument{Body: `<p dir="right">Text</p>`}.Bytes()
+	diags := (&Validator{}).Validate("chapter.xhtml", content, nil)
+	for _, d := range diags {
+		if d.Code != "dir-value" {
+			continue
+		}
+		// The range covers the attribute value on line 5
+		if d.Severity != epub.SeverityError || d.Range.Start != (epub.Position{Line: 4, Character: 8}) ||
+			d.Range.End != (epub.Position{Line: 4, Character: 13}) {
+			t.Errorf("diagnostic = %+v", d)
+		}
+	}
+}
+
+func TestBdoDir(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"with dir", `<p><bdo dir="rtl">abc</bdo></p>`, false},
+		{"bdi without dir", `<p><bdi>abc</bdi></p>`, false},
+		{"without dir", `<p><bdo>abc</bdo></p>`, true},
+		{"auto", `<p><bdo dir="auto">abc</bdo></p>`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+			diags := (&Validator{}).Validate("chapter.xhtml", content, nil)
+			if got := testutil.HasCode(diags, "bdo-dir"); got != tt.want {
+				t.Errorf("bdo-dir = %v, want %v (%v)", got, tt.want, testutil.DiagCodes(diags))
+			}
+		})
+	}
+}
+
+func TestDirRTLLanguage(t *testing.T) {
+	tests := []struct {
+		name      string
+		languages []string
+		attrs     string
+		want      bool
+	}{
+		{"arabic package", []string{"ar"}, "", true},
+		{"hebrew region subtag", []string{"he-IL"}, ` lang="he"`, true},
+		{"english package", []string{"en"}, "", false},
+		{"english first", []string{"en", "ar"}, "", false},
+		{"no language", nil, "", false},
+		{"dir present", []string{"fa"}, ` dir="rtl"`, false},
+		{"document in another language", []string{"ur"}, ` lang="en" xml:lang="en"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &validator.WorkspaceContext{Manifest: &validator.ManifestInfo{
+				Metadata: validator.MetadataInfo{Languages: tt.languages},
+			}}
+			content := testutil.XHTMLDocument{HTMLAttrs: tt.attrs, Body: "<p>Text</p>"}.Bytes()
+			diags := (&Validator{}).Validate("chapter.xhtml", content, ctx)
+			if got := testutil.HasCode(diags, "dir-rtl-language"); got != tt.want {
+				t.Errorf("dir-rtl-language = %v, want %v (%v)", got, tt.want, testutil.DiagCodes(diags))
+			}
+			for _, d := range diags {
+				if d.Code == "dir-rtl-language" && (d.Severity != epub.SeverityInfo || d.Fix == nil) {
+					t.Errorf("expected an info diagnostic with a fix, got %+v", d)
+				}
+			}
+		})
+	}
+}
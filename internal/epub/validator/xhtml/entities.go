@@ -0,0 +1,204 @@
+package xhtml
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"unicode"
+
+	"github.com/toba/epub-lsp/internal/epub"
+)
+
+// xmlEntities are the entities predefined by XML itself.
+var xmlEntities = map[string]bool{
+	"amp": true, "lt": true, "gt": true, "quot": true, "apos": true,
+}
+
+// htmlEntities maps HTML named entities that XHTML authors commonly use to
+// their code points: the HTML4 Latin-1 set plus common punctuation and
+// symbols.
+var htmlEntities = map[string]rune{
+	"nbsp": 0xA0, "iexcl": 0xA1, "cent": 0xA2, "pound": 0xA3,
+	"curren": 0xA4, "yen": 0xA5, "brvbar": 0xA6, "sect": 0xA7,
+	"uml": 0xA8, "copy": 0xA9, "ordf": 0xAA, "laquo": 0xAB,
+	"not": 0xAC, "shy": 0xAD, "reg": 0xAE, "macr": 0xAF,
+	"deg": 0xB0, "plusmn": 0xB1, "sup2": 0xB2, "sup3": 0xB3,
+	"acute": 0xB4, "micro": 0xB5, "para": 0xB6, "middot": 0xB7,
+	"cedil": 0xB8, "sup1": 0xB9, "ordm": 0xBA, "raquo": 0xBB,
+	"frac14": 0xBC, "frac12": 0xBD, "frac34": 0xBE, "iquest": 0xBF,
+	"Agrave": 0xC0, "Aacute": 0xC1, "Acirc": 0xC2, "Atilde": 0xC3,
+	"Auml": 0xC4, "Aring": 0xC5, "AElig": 0xC6, "Ccedil": 0xC7,
+	"Egrave": 0xC8, "Eacute": 0xC9, "Ecirc": 0xCA, "Euml": 0xCB,
+	"Igrave": 0xCC, "Iacute": 0xCD, "Icirc": 0xCE, "Iuml": 0xCF,
+	"ETH": 0xD0, "Ntilde": 0xD1, "Ograve": 0xD2, "Oacute": 0xD3,
+	"Ocirc": 0xD4, "Otilde": 0xD5, "Ouml": 0xD6, "times": 0xD7,
+	"Oslash": 0xD8, "Ugrave": 0xD9, "Uacute": 0xDA, "Ucirc": 0xDB,
+	"Uuml": 0xDC, "Yacute": 0xDD, "THORN": 0xDE, "szlig": 0xDF,
+	"agrave": 0xE0, "aacute": 0xE1, "acirc": 0xE2, "atilde": 0xE3,
+	"auml": 0xE4, "aring": 0xE5, "aelig": 0xE6, "ccedil": 0xE7,
+	"egrave": 0xE8, "eacute": 0xE9, "ecirc": 0xEA, "euml": 0xEB,
+	"igrave": 0xEC, "iacute": 0xED, "icirc": 0xEE, "iuml": 0xEF,
+	"eth": 0xF0, "ntilde": 0xF1, "ograve": 0xF2, "oacute": 0xF3,
+	"ocirc": 0xF4, "otilde": 0xF5, "ouml": 0xF6, "divide": 0xF7,
+	"oslash": 0xF8, "ugrave": 0xF9, "uacute": 0xFA, "ucirc": 0xFB,
+	"uuml": 0xFC, "yacute": 0xFD, "thorn": 0xFE, "yuml": 0xFF,
+	"OElig": 0x152, "oelig": 0x153, "Scaron": 0x160, "scaron": 0x161,
+	"Yuml": 0x178, "fnof": 0x192, "circ": 0x2C6, "tilde": 0x2DC,
+	"ensp": 0x2002, "emsp": 0x2003, "thinsp": 0x2009, "zwnj": 0x200C,
+	"zwj": 0x200D, "lrm": 0x200E, "rlm": 0x200F, "ndash": 0x2013,
+	"mdash": 0x2014, "lsquo": 0x2018, "rsquo": 0x2019, "sbquo": 0x201A,
+	"ldquo": 0x201C, "rdquo": 0x201D, "bdquo": 0x201E, "dagger": 0x2020,
+	"Dagger": 0x2021, "bull": 0x2022, "hellip": 0x2026, "permil": 0x2030,
+	"prime": 0x2032, "Prime": 0x2033, "lsaquo": 0x2039, "rsaquo": 0x203A,
+	"oline": 0x203E, "frasl": 0x2044, "euro": 0x20AC, "trade": 0x2122,
+	"larr": 0x2190, "uarr": 0x2191, "rarr": 0x2192, "darr": 0x2193,
+	"harr": 0x2194, "minus": 0x2212,
+}
+
+// entityDeclPattern matches entity declarations in a DOCTYPE internal subset.
+var entityDeclPattern = regexp.MustCompile(`<!ENTITY\s+([^\s%]+)`)
+
+// LookupEntity returns the code point of an HTML named entity.
+func LookupEntity(name string) (rune, bool) {
+	r, ok := htmlEntities[name]
+	return r, ok
+}
+
+// EntityReplacement returns the XML-safe text for an HTML named entity:
+// the literal character when it is visible, otherwise a numeric character
+// reference (&nbsp; becomes &#160;, &mdash; becomes —).
+func EntityReplacement(name string) (string, bool) {
+	r, ok := htmlEntities[name]
+	if !ok {
+		return "", false
+	}
+	if unicode.IsPrint(r) && !unicode.IsSpace(r) {
+		return string(r), true
+	}
+	return "&#" + strconv.Itoa(int(r)) + ";", true
+}
+
+// validateEntities scans the raw content for references XML cannot parse:
+// named entities outside the XML predefined set (RSC_025) and bare
+// ampersands that do not start a reference (RSC_016). Comments, CDATA
+// sections, and processing instructions are skipped. It runs independently
+// of the parser, since either error aborts parsing.
+func validateEntities(content []byte) []epub.Diagnostic {
+	declared := make(map[string]bool)
+	for _, m := range entityDeclPattern.FindAllSubmatch(content, -1) {
+		declared[string(m[1])] = true
+	}
+
+	var lines *epub.LineIndex
+	var diags []epub.Diagnostic
+
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '<':
+			i = skipMarkup(content, i)
+		case '&':
+			end, name := scanReference(content, i)
+			if end > i && (name == "" || xmlEntities[name] || declared[name]) {
+				i = end - 1
+				continue
+			}
+
+			if lines == nil {
+				lines = epub.NewLineIndex(content)
+			}
+			if end < 0 {
+				diags = append(diags, epub.NewDiagAt(lines, i, source).
+					End(lines.Position(i+1)).
+					Code("RSC_016").
+					Error("unescaped '&' must be written as &amp;").
+					Fix("Escape as &amp;", epub.AnchorReplaceRange, "&amp;").
+					Build())
+				continue
+			}
+
+			b := epub.NewDiagAt(lines, i, source).
+				End(lines.Position(end)).
+				Code("RSC_025")
+			msg := "entity &" + name + "; is not defined in XHTML"
+			if replacement, ok := EntityReplacement(name); ok {
+				msg += "; use " + replacement
+				b.Fix("Replace with "+replacement, epub.AnchorReplaceRange, replacement)
+			}
+			diags = append(diags, b.Error(msg).Build())
+			i = end - 1
+		}
+	}
+
+	return diags
+}
+
+// skipMarkup returns the offset of the last byte of a comment, CDATA
+// section, or processing instruction starting at i, or i for other markup.
+func skipMarkup(content []byte, i int) int {
+	for _, delims := range [][2]string{
+		{"<!--", "-->"},
+		{"<![CDATA[", "]]>"},
+		{"<?", "?>"},
+	} {
+		if !bytes.HasPrefix(content[i:], []byte(delims[0])) {
+			continue
+		}
+		start := i + len(delims[0])
+		end := bytes.Index(content[start:], []byte(delims[1]))
+		if end < 0 {
+			return len(content) - 1
+		}
+		return start + end + len(delims[1]) - 1
+	}
+	return i
+}
+
+// scanReference parses the reference starting with the '&' at i. It returns
+// the offset after the closing ';' and the entity name, which is empty for
+// a valid character reference. The offset is -1 when no well-formed
+// reference starts at i.
+func scanReference(content []byte, i int) (int, string) {
+	j := i + 1
+	if j < len(content) && content[j] == '#' {
+		j++
+		isDigit := isDecimal
+		if j < len(content) && content[j] == 'x' {
+			j++
+			isDigit = isHex
+		}
+		start := j
+		for j < len(content) && isDigit(content[j]) {
+			j++
+		}
+		if j == start || j >= len(content) || content[j] != ';' {
+			return -1, ""
+		}
+		return j + 1, ""
+	}
+
+	start := j
+	for j < len(content) && isNameByte(content[j], j == start) {
+		j++
+	}
+	if j == start || j >= len(content) || content[j] != ';' {
+		return -1, ""
+	}
+	return j + 1, string(content[start:j])
+}
+
+func isDecimal(c byte) bool { return c >= '0' && c <= '9' }
+
+func isHex(c byte) bool {
+	return isDecimal(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// isNameByte reports whether c may appear in an ASCII entity name.
+func isNameByte(c byte, first bool) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+		return true
+	case isDecimal(c), c == '-', c == '.':
+		return !first
+	}
+	return false
+}
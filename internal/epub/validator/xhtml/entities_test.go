@@ -0,0 +1,129 @@
+package xhtml
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestEntityReplacement(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"nbsp", "&#160;"},
+		{"mdash", "—"},
+		{"eacute", "é"},
+		{"copy", "©"},
+		{"hellip", "…"},
+		{"zwj", "&#8205;"},
+		{"thinsp", "&#8201;"},
+	}
+
+	for _, tt := range tests {
+		got, ok := EntityReplacement(tt.name)
+		if !ok || got != tt.want {
+			t.Errorf("EntityReplacement(%q) = %q, %v; want %q", tt.name, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := EntityReplacement("bogus"); ok {
+		t.Error("expected no replacement for unknown entity")
+	}
+}
+
+func TestUndefinedEntity(t *testing.T) {
+	v := &Validator{}
+	content := testutil.XHTMLDocument{Body: "<p>one&nbsp;two</p>"}.Bytes()
+	diags := v.Validate("ch1.xhtml", content, nil)
+
+	var found *epub.Diagnostic
+	for i := range diags {
+		if diags[i].Code == "RSC_025" {
+			found = &diags[i]
+		}
+	}
+	if found == nil {
+		t.Fatal("expected RSC_025 for &nbsp;")
+	}
+	want := epub.Range{
+		Start: epub.Position{Line: 4, Character: 6},
+		End:   epub.Position{Line: 4, Character: 12},
+	}
+	if found.Range != want {
+		t.Errorf("expected range %v, got %v", want, found.Range)
+	}
+}
+
+func TestBareAmpersand(t *testing.T) {
+	v := &Validator{}
+	content := testutil.XHTMLDocument{Body: "<p>Smith & Sons</p>"}.Bytes()
+	diags := v.Validate("ch1.xhtml", content, nil)
+
+	if !testutil.HasCode(diags, "RSC_016") {
+		t.Error("expected RSC_016 for bare ampersand")
+	}
+	if testutil.HasCode(diags, "RSC_025") {
+		t.Error("unexpected RSC_025 for bare ampersand")
+	}
+}
+
+func TestValidReferences(t *testing.T) {
+	v := &Validator{}
+	content := testutil.XHTMLDocument{
+		Body: "<p>&amp; &lt; &gt; &quot; &apos; &#160; &#x2014; &#x41;</p>",
+	}.Bytes()
+	diags := v.Validate("ch1.xhtml", content, nil)
+
+	for _, d := range diags {
+		if d.Code == "RSC_025" || d.Code == "RSC_016" {
+			t.Errorf("unexpected [%s] %s", d.Code, d.Message)
+		}
+	}
+}
+
+func TestEntitiesIgnoredInCommentsAndCDATA(t *testing.T) {
+	content := testutil.XHTMLDocument{
+		Body: "<p><!-- &nbsp; & --><script><![CDATA[if (a && b) {}]]></script></p>",
+	}.Bytes()
+
+	v := &Validator{}
+	diags := v.Validate("ch1.xhtml", content, nil)
+
+	if testutil.HasCode(diags, "RSC_025") || testutil.HasCode(diags, "RSC_016") {
+		t.Errorf("unexpected entity diagnostics: %v", diags)
+	}
+}
+
+func TestDeclaredEntity(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html [<!ENTITY publisher "Acme">]>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Entities</title></head>
+<body><p>&publisher;</p></body>
+</html>`)
+
+	if diags := validateEntities(content); len(diags) > 0 {
+		t.Errorf("unexpected diagnostics for declared entity: %v", diags)
+	}
+}
+
+func TestRawAttributeChecks(t *testing.T) {
+	v := &Validator{}
+	content := testutil.XHTMLDocument{
+		Body: `<p><span class="a" class="b">x</span></p>`,
+	}.Bytes()
+	diags := v.Validate("ch1.xhtml", content, nil)
+	if !testutil.HasCode(diags, "XML_DUP_ATTR") {
+		t.Errorf("expected XML_DUP_ATTR, got %v", testutil.DiagCodes(diags))
+	}
+
+	content = testutil.XHTMLDocument{
+		Body: "<p><span title=\"a\nb\">x</span>&nbsp;</p>",
+	}.Bytes()
+	diags = v.Validate("ch1.xhtml", content, nil)
+	if !testutil.HasCode(diags, "ATTR_WS") || !testutil.HasCode(diags, "RSC_025") {
+		t.Errorf("expected ATTR_WS alongside RSC_025, got %v", testutil.DiagCodes(diags))
+	}
+}
@@ -0,0 +1,34 @@
+package xhtml
+
+import (
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/langtag"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// validateLangRegions reports lang and xml:lang values whose region
+// subtag is not a region code, such as "en-UK".
+func validateLangRegions(content []byte, root *parser.XMLNode) []epub.Diagnostic {
+	lines := epub.NewLineIndex(content)
+	var diags []epub.Diagnostic
+	var walk func(node *parser.XMLNode)
+	walk = func(node *parser.XMLNode) {
+		for _, name := range []string{"lang", "xml:lang"} {
+			start, end, ok := parser.AttrValueRange(content, node, name)
+			if !ok {
+				continue
+			}
+			tag := string(content[start:end])
+			if d, bad := langtag.RegionDiag(lines, tag, start, source); bad {
+				diags = append(diags, d)
+			}
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	for _, child := range root.Children {
+		walk(child)
+	}
+	return diags
+}
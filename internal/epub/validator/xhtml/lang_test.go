@@ -0,0 +1,47 @@
+package xhtml
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestLangRegion(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"language only", `<p lang="fr">Bonjour</p>`, false},
+		{"known region", `<p lang="en-GB">Colour</p>`, false},
+		{"script and region", `<p lang="zh-Hant-TW">你好</p>`, false},
+		{"numeric region", `<p lang="es-419">Hola</p>`, false},
+		{"unknown region", `<p lang="en-UK">Colour</p>`, true},
+		{"unknown xml:lang region", `<p xml:lang="pt-BX">Olá</p>`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testutil.XHTMLDocument{Body: tt.body}.Bytes()
+			diags := (&Validator{}).Validate("chapter.xhtml", content, nil)
+			if got := testutil.HasCode(diags, "lang-region-unlikely"); got != tt.want {
+				t.Errorf("lang-region-unlikely = %v, want %v (%v)",
+					got, tt.want, testutil.DiagCodes(diags))
+			}
+		})
+	}
+
+	content := testutil.XHTMLDocument{Body: `<p lang="en-UK">Colour</p>`}.Bytes()
+	diags := (&Validator{}).Validate("chapter.xhtml", content, nil)
+	for _, d := range diags {
+		if d.Code != "lang-region-unlikely" {
+			continue
+		}
+		// The range covers the region subtag on line 5, with a fix to GB
+		if d.Severity != epub.SeverityWarning ||
+			d.Range.Start != (epub.Position{Line: 4, Character: 12}) ||
+			d.Range.End != (epub.Position{Line: 4, Character: 14}) || d.Fix == nil {
+			t.Errorf("diagnostic = %+v", d)
+		}
+	}
+}
@@ -21,22 +21,37 @@ func validateNamespaces(content []byte, root *parser.XMLNode) []epub.Diagnostic
 			Build())
 	}
 
-	// Check lang attributes match
-	xmlLang := html.AttrNS(epub.NSXML, "lang")
-	lang := html.Attr("lang")
-
-	if xmlLang != "" && lang != "" && xmlLang != lang {
-		diags = append(diags, epub.NewDiag(content, int(html.Offset), source).
-			Code("HTM_017").
-			Warning(`xml:lang ("`+xmlLang+`") and lang ("`+lang+`") values don't match`).
-			Build())
-	}
+	diags = append(diags, checkLangMismatch(content, html)...)
 
 	// Check for missing lang attribute
-	if lang == "" && xmlLang == "" {
+	if html.InheritedLang() == "" {
 		diags = append(diags, epub.NewDiag(content, int(html.Offset), source).
 			Info(`missing lang attribute on <html> element`).Build())
 	}
 
 	return diags
 }
+
+// checkLangMismatch reports elements setting xml:lang or lang where the
+// effective values of the two differ, counting values inherited from
+// ancestors, since reading systems may read either attribute.
+func checkLangMismatch(content []byte, node *parser.XMLNode) []epub.Diagnostic {
+	var diags []epub.Diagnostic
+
+	if node.HasAttr("lang") || node.AttrNS(epub.NSXML, "lang") != "" {
+		xmlLang := node.EffectiveAttrNS(epub.NSXML, "lang")
+		lang := node.EffectiveAttr("lang")
+		if xmlLang != "" && lang != "" && xmlLang != lang {
+			diags = append(diags, epub.NewDiag(content, int(node.Offset), source).
+				Code("HTM_017").
+				Warning(`xml:lang ("`+xmlLang+`") and lang ("`+lang+
+					`") values don't match`).
+				Build())
+		}
+	}
+
+	for _, child := range node.Children {
+		diags = append(diags, checkLangMismatch(content, child)...)
+	}
+	return diags
+}
@@ -0,0 +1,40 @@
+package xhtml
+
+import (
+	"strconv"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+)
+
+// EpubNamespaceDeclaration is the attribute text declaring the epub prefix
+// on a start tag, for the EPUB structural semantics attributes.
+const EpubNamespaceDeclaration = ` xmlns:epub="` + epub.NSEpub + `"`
+
+// validateEpubPrefix reports the first attribute, such as epub:type, that
+// uses the epub prefix outside any xmlns:epub declaration (HTM_045), with a
+// fix declaring it on the root element. It scans the raw content, so it
+// also reports on documents the parser rejects.
+func validateEpubPrefix(content []byte) []epub.Diagnostic {
+	uses := parser.UndeclaredPrefixAttrs(content, "epub")
+	if len(uses) == 0 {
+		return nil
+	}
+
+	first := uses[0]
+	message := "epub prefix used but not declared; add " +
+		`xmlns:epub="` + epub.NSEpub + `" to <html>`
+	if len(uses) > 1 {
+		message += " (" + strconv.Itoa(len(uses)) + " attributes use it)"
+	}
+	lines := epub.NewLineIndex(content)
+	return []epub.Diagnostic{
+		epub.NewDiagAt(lines, first.NameOffset, source).
+			End(lines.Position(first.NameOffset+len(first.Name))).
+			Code("HTM_045").
+			Error(message).
+			Fix("Declare the epub namespace", epub.AnchorNamespaceDeclaration,
+				EpubNamespaceDeclaration).
+			Build(),
+	}
+}
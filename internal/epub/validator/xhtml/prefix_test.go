@@ -0,0 +1,54 @@
+package xhtml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestUndeclaredEpubPrefix(t *testing.T) {
+	content := []byte(`<html xmlns="http://www.w3.org/1999/xhtml" lang="en">
+<head><title>T</title></head>
+<body><section epub:type="chapter"><span epub:type="pagebreak"/></section></body>
+</html>`)
+
+	diags := (&Validator{}).Validate("ch1.xhtml", content, nil)
+	var found []epub.Diagnostic
+	for _, d := range diags {
+		if d.Code == "HTM_045" {
+			found = append(found, d)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("expected one HTM_045, got %v", testutil.DiagCodes(diags))
+	}
+	d := found[0]
+	if d.Severity != epub.SeverityError || !strings.Contains(d.Message, "2 attributes") {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+	if d.Range.Start != (epub.Position{Line: 2, Character: 15}) ||
+		d.Range.End != (epub.Position{Line: 2, Character: 24}) {
+		t.Errorf("range = %+v, want the first epub:type", d.Range)
+	}
+	if d.Fix == nil || d.Fix.Anchor != epub.AnchorNamespaceDeclaration ||
+		d.Fix.InsertText != EpubNamespaceDeclaration {
+		t.Errorf("fix = %+v", d.Fix)
+	}
+
+	declared := strings.Replace(string(content), `lang="en"`,
+		`xmlns:epub="http://www.idpf.org/2007/ops" lang="en"`, 1)
+	diags = (&Validator{}).Validate("ch1.xhtml", []byte(declared), nil)
+	if testutil.HasCode(diags, "HTM_045") {
+		t.Error("unexpected HTM_045 with the prefix declared")
+	}
+
+	// Reported before parsing, so malformed documents get it too
+	malformed := []byte(`<html><body><p epub:type="x"></body></html>`)
+	diags = (&Validator{}).Validate("ch1.xhtml", malformed, nil)
+	if !testutil.HasCode(diags, "HTM_045") {
+		t.Errorf("expected HTM_045 in a malformed document, got %v",
+			testutil.DiagCodes(diags))
+	}
+}
@@ -0,0 +1,141 @@
+package xhtml
+
+import (
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+// interactionHandlers are the event handler attributes that respond to
+// the user rather than to loading or media events.
+var interactionHandlers = map[string]bool{
+	"onclick":       true,
+	"ondblclick":    true,
+	"onmousedown":   true,
+	"onmouseup":     true,
+	"onkeydown":     true,
+	"onkeyup":       true,
+	"onkeypress":    true,
+	"onpointerdown": true,
+	"onpointerup":   true,
+	"ontouchstart":  true,
+	"ontouchend":    true,
+}
+
+// interactiveElements are the elements that take keyboard focus and have
+// an accessible role of their own.
+var interactiveElements = map[string]bool{
+	"a":        true,
+	"area":     true,
+	"audio":    true,
+	"button":   true,
+	"details":  true,
+	"iframe":   true,
+	"input":    true,
+	"label":    true,
+	"option":   true,
+	"select":   true,
+	"summary":  true,
+	"textarea": true,
+	"video":    true,
+}
+
+// validateScripts checks scripted content: documents with scripts should
+// keep working without them, external scripts must exist in the
+// workspace, and elements with inline interaction handlers must be
+// reachable from the keyboard.
+func validateScripts(
+	uri string,
+	content []byte,
+	root *parser.XMLNode,
+	ctx *validator.WorkspaceContext,
+) []epub.Diagnostic {
+	lines := epub.NewLineIndex(content)
+	var diags []epub.Diagnostic
+
+	scripts := root.FindAll("script")
+	if len(scripts) > 0 && !hasScriptFallback(root) {
+		name, _ := parser.TagNameSpans(content, int(scripts[0].Offset))
+		diags = append(diags, epub.NewDiagAt(lines, name[0], source).
+			End(lines.Position(name[1])).
+			Code("SCP_001").
+			Info("document has scripts but no <noscript> fallback or live region; "+
+				"its content may be inaccessible when scripting is off").
+			Build())
+	}
+
+	if ctx != nil && ctx.Files != nil {
+		for _, script := range scripts {
+			src := script.Attr("src")
+			if src == "" || epub.IsRemoteURL(src) || strings.HasPrefix(src, "data:") {
+				continue
+			}
+			if _, ok := uriutil.Lookup(ctx.Files, uriutil.ResolveRelative(uri, src)); ok {
+				continue
+			}
+			diags = append(diags, attrDiag(content, lines, script, "src").
+				Code("SCP_009").
+				Error("script references missing file: "+src).
+				Build())
+		}
+	}
+
+	walk(root, func(node *parser.XMLNode) {
+		if interactiveElements[node.Local] ||
+			node.HasAttr("tabindex") && node.HasAttr("role") {
+			return
+		}
+		for _, attr := range node.Attrs {
+			if attr.Space != "" || !interactionHandlers[strings.ToLower(attr.Local)] {
+				continue
+			}
+			diags = append(diags, attrDiag(content, lines, node, attr.Local).
+				Code("SCP_010").
+				Warning(attr.Local+" handler on <"+node.Local+"> without tabindex "+
+					"and role; keyboard and screen reader users cannot use it").
+				Build())
+		}
+	})
+
+	return diags
+}
+
+// hasScriptFallback reports whether the document has a <noscript>
+// element, or a live region or application role that scripts are expected
+// to keep accessible.
+func hasScriptFallback(root *parser.XMLNode) bool {
+	found := false
+	walk(root, func(node *parser.XMLNode) {
+		if node.Local == "noscript" || node.HasAttr("aria-live") ||
+			epub.ContainsToken(node.Attr("role"), "application") {
+			found = true
+		}
+	})
+	return found
+}
+
+// attrDiag starts a diagnostic spanning the value of node's attribute
+// name, or at node when the value cannot be found.
+func attrDiag(
+	content []byte,
+	lines *epub.LineIndex,
+	node *parser.XMLNode,
+	name string,
+) *epub.DiagBuilder {
+	start, end, ok := parser.AttrValueRange(content, node, name)
+	if !ok {
+		return epub.NewDiagAt(lines, int(node.Offset), source)
+	}
+	return epub.NewDiagAt(lines, start, source).End(lines.Position(end))
+}
+
+// walk calls fn for node and each of its descendants in document order.
+func walk(node *parser.XMLNode, fn func(*parser.XMLNode)) {
+	fn(node)
+	for _, child := range node.Children {
+		walk(child, fn)
+	}
+}
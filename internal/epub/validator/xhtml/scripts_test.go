@@ -0,0 +1,117 @@
+package xhtml
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+	"github.com/toba/epub-lsp/internal/epub/validator"
+)
+
+func TestMissingExternalScript(t *testing.T) {
+	content := testutil.XHTMLDocument{
+		Head: `<head><title>Quiz</title>` +
+			`<script src="js/quiz.js"></script><script src="js/app.js"></script></head>`,
+		Body: `<noscript><p>Answers are at the back of the book.</p></noscript>`,
+	}.Bytes()
+	ctx := &validator.WorkspaceContext{Files: map[string][]byte{
+		"file:///book/OEBPS/chapter.xhtml": content,
+		"file:///book/OEBPS/js/app.js":     nil,
+	}}
+
+	v := &Validator{}
+	diags := v.Validate("file:///book/OEBPS/chapter.xhtml", content, ctx)
+
+	var missing []epub.Diagnostic
+	for _, d := range diags {
+		if d.Code == "SCP_009" {
+			missing = append(missing, d)
+		}
+	}
+	if len(missing) != 1 {
+		t.Fatalf("expected 1 SCP_009, got %v", testutil.DiagCodes(diags))
+	}
+	if missing[0].Message != "script references missing file: js/quiz.js" {
+		t.Errorf("message = %q", missing[0].Message)
+	}
+	if missing[0].Severity != epub.SeverityError {
+		t.Errorf("severity = %s, want error", testutil.SeverityName(missing[0].Severity))
+	}
+	// The range covers the src value
+	if r := missing[0].Range; r.Start.Line != 2 || r.Start.Character != 38 || r.End.Character != 48 {
+		t.Errorf("range = %+v", r)
+	}
+	if testutil.HasCode(diags, "SCP_001") {
+		t.Error("unexpected SCP_001 with a noscript fallback")
+	}
+}
+
+func TestScriptWithoutFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"no fallback", `<p id="score"></p>`, true},
+		{"noscript", `<noscript><p>Scripting is off.</p></noscript>`, false},
+		{"live region", `<p id="score" aria-live="polite"></p>`, false},
+		{"application", `<div role="application"></div>`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testutil.XHTMLDocument{
+				Head: `<head><title>Quiz</title><script>var score = 0;</script></head>`,
+				Body: tt.body,
+			}.Bytes()
+			diags := (&Validator{}).Validate("chapter.xhtml", content, nil)
+			if got := testutil.HasCode(diags, "SCP_001"); got != tt.want {
+				t.Errorf("SCP_001 = %v, want %v", got, tt.want)
+			}
+			for _, d := range diags {
+				if d.Code == "SCP_001" && d.Severity != epub.SeverityInfo {
+					t.Errorf("severity = %s, want info", testutil.SeverityName(d.Severity))
+				}
+			}
+		})
+	}
+}
+
+func TestInlineEventHandlers(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"onclick on a div", `<div onclick="check()">Check</div>`, 1},
+		{"button", `<button type="button" onclick="check()">Check</button>`, 0},
+		{"div with tabindex and role", `<div role="button" tabindex="0" onclick="check()" onkeydown="key(event)">Check</div>`, 0},
+		{"div with role only", `<div role="button" onclick="check()">Check</div>`, 1},
+		{"load handler", `<img src="a.png" alt="" onload="ready()"/>`, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testutil.XHTMLDocument{
+				Head: `<head><title>Quiz</title><script>function check() {}</script></head>`,
+				Body: `<noscript><p>Scripting is off.</p></noscript>` + tt.body,
+			}.Bytes()
+			diags := (&Validator{}).Validate("chapter.xhtml", content, nil)
+
+			var handlers []epub.Diagnostic
+			for _, d := range diags {
+				if d.Code == "SCP_010" {
+					handlers = append(handlers, d)
+				}
+			}
+			if len(handlers) != tt.want {
+				t.Fatalf("expected %d SCP_010, got %d", tt.want, len(handlers))
+			}
+			if len(handlers) > 0 && handlers[0].Message !=
+				"onclick handler on <div> without tabindex and role; keyboard and "+
+					"screen reader users cannot use it" {
+				t.Errorf("message = %q", handlers[0].Message)
+			}
+		})
+	}
+}
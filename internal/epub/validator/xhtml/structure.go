@@ -12,8 +12,12 @@ func validateStructure(content []byte, root *parser.XMLNode) []epub.Diagnostic {
 	imgs := root.FindAll("img")
 	for _, img := range imgs {
 		if !img.HasAttr("alt") {
-			diags = append(diags, epub.NewDiag(content, int(img.Offset), source).
-				Code("HTM_008").Warning("<img> element missing alt attribute").Build())
+			name, _ := parser.TagNameSpans(content, int(img.Offset))
+			diags = append(diags, epub.NewDiag(content, name[0], source).
+				End(epub.ByteOffsetToPosition(content, name[1])).
+				Code("HTM_008").Warning("<img> element missing alt attribute").
+				Fix(`Mark as decorative with alt=""`, epub.AnchorEndOfStartTag, ` alt=""`).
+				Build())
 		}
 	}
 
@@ -0,0 +1,102 @@
+package xhtml
+
+import (
+	"bytes"
+	"html"
+	"path"
+	"strings"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/parser"
+	"github.com/toba/epub-lsp/internal/epub/uriutil"
+)
+
+// headings names the heading elements a missing title is taken from.
+var headings = []string{"h1", "h2", "h3", "h4", "h5", "h6"}
+
+// validateTitle reports a <head> without a <title>, at the head, and an
+// empty <title>, at the title. Reading systems show the title in history
+// and tables of contents, and assistive technology announces it. The fix
+// fills it from the document's first heading, or from its file name.
+func validateTitle(uri string, content []byte, root *parser.XMLNode) []epub.Diagnostic {
+	head := root.FindFirst("head")
+	if head == nil {
+		return nil
+	}
+	lines := epub.NewLineIndex(content)
+	text := titleText(uri, content, root)
+
+	title := head.FindFirst("title")
+	if title == nil {
+		b := tagNameDiag(content, lines, head).Code("title-missing").
+			Warning("<head> has no <title>")
+		// A self-closing head has no closing tag to insert before
+		if _, end := parser.TagNameSpans(content, int(head.Offset)); end[0] >= 0 {
+			b = b.Fix("Add <title> from first heading",
+				epub.AnchorBeforeClosingTag+"head", "<title>"+text+"</title>")
+		}
+		return []epub.Diagnostic{b.Build()}
+	}
+
+	if strings.TrimSpace(title.Text()) == "" {
+		return []epub.Diagnostic{tagNameDiag(content, lines, title).Code("title-missing").
+			Warning("<title> is empty").
+			Fix("Add <title> from first heading", epub.AnchorElementContent, text).
+			Build()}
+	}
+	return nil
+}
+
+// titleText returns the text of the first heading in the body, as written
+// in the source with its markup and template actions removed, or the
+// escaped file name of uri without its extension.
+func titleText(uri string, content []byte, root *parser.XMLNode) string {
+	if body := root.FindFirst("body"); body != nil {
+		var first *parser.XMLNode
+		for _, name := range headings {
+			for _, h := range body.FindAll(name) {
+				if first == nil || h.Offset < first.Offset {
+					first = h
+				}
+			}
+		}
+		if first != nil && first.End > 0 {
+			contentStart, _, _ := parser.ElementSpan(content, int(first.Offset))
+			contentEnd := bytes.LastIndex(content[:first.End], []byte("</"))
+			if contentEnd > contentStart {
+				if text := plainText(content[contentStart:contentEnd]); text != "" {
+					return text
+				}
+			}
+		}
+	}
+
+	name := path.Base(uriutil.Path(uri))
+	return html.EscapeString(strings.TrimSuffix(name, path.Ext(name)))
+}
+
+// plainText removes the tags, comments, and template actions from markup
+// and collapses its whitespace. Line breaks become spaces. Character references are kept, so the
+// result is still valid as XML text.
+func plainText(markup []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(markup); {
+		rest := markup[i:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			i += skipTo(rest, "-->")
+		case bytes.HasPrefix(rest, []byte("{{")):
+			i += skipTo(rest, "}}")
+		case rest[0] == '<':
+			// Inline tags do not separate words, but a line break does
+			if bytes.HasPrefix(rest, []byte("<br")) {
+				b.WriteByte(' ')
+			}
+			i += skipTo(rest, ">")
+		default:
+			b.WriteByte(rest[0])
+			i++
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,77 @@
+package xhtml
+
+import (
+	"testing"
+
+	"github.com/toba/epub-lsp/internal/epub"
+	"github.com/toba/epub-lsp/internal/epub/testutil"
+)
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		name       string
+		uri        string
+		head, body string
+		// anchor and insert describe the expected fix, or anchor is ""
+		// when no diagnostic is expected
+		anchor, insert string
+		// on is the element the diagnostic starts at
+		on string
+	}{
+		{
+			name: "present",
+			head: "<head><title>Chapter 1</title></head>", body: "<h1>Chapter 1</h1>",
+		},
+		{
+			name: "missing, from heading", uri: "file:///book/ch1.xhtml",
+			head:   "<head>\n  <meta charset=\"utf-8\"/>\n</head>",
+			body:   "<section><h2>Chapter <em>One</em><br/>The &amp; Start</h2></section><h1>Later</h1>",
+			anchor: "before-closing-tag:head", insert: "<title>Chapter One The &amp; Start</title>",
+			on: "head",
+		},
+		{
+			name: "empty, from file name", uri: "file:///book/text/Cover%20Page.xhtml",
+			head: "<head><title>  </title></head>", body: "<p>No heading</p>",
+			anchor: epub.AnchorElementContent, insert: "Cover Page",
+			on: "title",
+		},
+		{
+			name: "empty heading falls back", uri: "file:///book/a&b.xhtml",
+			head: "<head><title/></head>", body: "<h1>{{ .Title }}</h1>",
+			anchor: epub.AnchorElementContent, insert: "a&amp;b",
+			on: "title",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testutil.XHTMLDocument{Head: tt.head, Body: tt.body}.Bytes()
+			var found *epub.Diagnostic
+			for _, d := range (&Validator{}).Validate(tt.uri, content, nil) {
+				if d.Code == "title-missing" {
+					found = &d
+				}
+			}
+			if tt.anchor == "" {
+				if found != nil {
+					t.Fatalf("unexpected diagnostic %+v", found)
+				}
+				return
+			}
+			if found == nil {
+				t.Fatal("expected title-missing")
+			}
+			if found.Severity != epub.SeverityWarning {
+				t.Errorf("severity = %d, want warning", found.Severity)
+			}
+			lines := epub.NewLineIndex(content)
+			start := lines.Offset(found.Range.Start)
+			if got := string(content[start : start+len(tt.on)]); got != tt.on {
+				t.Errorf("diagnostic starts at %q, want %q", got, tt.on)
+			}
+			if found.Fix == nil || found.Fix.Anchor != tt.anchor || found.Fix.InsertText != tt.insert {
+				t.Errorf("fix = %+v, want %s %q", found.Fix, tt.anchor, tt.insert)
+			}
+		})
+	}
+}
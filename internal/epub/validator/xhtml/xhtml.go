@@ -17,17 +17,25 @@ func (v *Validator) FileTypes() []epub.FileType {
 }
 
 func (v *Validator) Validate(
-	_ string,
+	uri string,
 	content []byte,
-	_ *validator.WorkspaceContext,
+	ctx *validator.WorkspaceContext,
 ) []epub.Diagnostic {
+	rawDiags := append(validateEntities(content), parser.ValidateAttrs(content)...)
+	rawDiags = append(rawDiags, validateEpubPrefix(content)...)
+
 	root, diags := parser.Parse(content)
 	if len(diags) > 0 {
-		return diags
+		return append(rawDiags, diags...)
 	}
 
-	diags = append(diags, validateNamespaces(content, root)...)
+	diags = append(rawDiags, validateNamespaces(content, root)...)
 	diags = append(diags, validateStructure(content, root)...)
+	diags = append(diags, validateTitle(uri, content, root)...)
+	diags = append(diags, validateBodyText(content, root)...)
+	diags = append(diags, validateScripts(uri, content, root, ctx)...)
+	diags = append(diags, validateDirection(content, root, ctx)...)
+	diags = append(diags, validateLangRegions(content, root)...)
 
 	return diags
 }
@@ -1,6 +1,7 @@
 package xhtml
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/toba/epub-lsp/internal/epub"
@@ -63,6 +64,33 @@ func TestLangMismatch(t *testing.T) {
 	}
 }
 
+func TestInheritedLangMismatch(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Test</title></head>
+<body>
+<p lang="fr">Bonjour</p>
+<p lang="de" xml:lang="de">Hallo <span>Welt</span></p>
+</body>
+</html>`)
+
+	diags := (&Validator{}).Validate("chapter.xhtml", content, nil)
+
+	var mismatches []epub.Diagnostic
+	for _, d := range diags {
+		if d.Code == "HTM_017" {
+			mismatches = append(mismatches, d)
+		}
+	}
+	if len(mismatches) != 1 || mismatches[0].Range.Start.Line != 4 {
+		t.Fatalf("expected one HTM_017 on the French paragraph, got %v", mismatches)
+	}
+	if !strings.Contains(mismatches[0].Message, `xml:lang ("en")`) {
+		t.Errorf("expected the inherited xml:lang in the message, got %q",
+			mismatches[0].Message)
+	}
+}
+
 func TestMissingLang(t *testing.T) {
 	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
 <html xmlns="http://www.w3.org/1999/xhtml">
@@ -100,14 +128,18 @@ func TestMissingImgAlt(t *testing.T) {
 	v := &Validator{}
 	diags := v.Validate("chapter.xhtml", content, nil)
 
-	count := 0
+	var missing []epub.Diagnostic
 	for _, d := range diags {
 		if d.Code == "HTM_008" {
-			count++
+			missing = append(missing, d)
 		}
 	}
-	if count != 2 {
-		t.Errorf("expected 2 HTM_008 diagnostics, got %d", count)
+	if len(missing) != 2 {
+		t.Fatalf("expected 2 HTM_008 diagnostics, got %d", len(missing))
+	}
+	// The range covers the element name
+	if r := missing[0].Range; r.Start.Line != 4 || r.Start.Character != 3 || r.End.Character != 6 {
+		t.Errorf("range = %+v, want the img name on line 4", r)
 	}
 }
 
@@ -121,3 +153,30 @@ func TestMalformedXHTML(t *testing.T) {
 		t.Error("expected diagnostics for malformed XHTML")
 	}
 }
+
+func TestCRLFLineEndings(t *testing.T) {
+	fixtures := []string{
+		`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en">
+<head><title>Test</title></head>
+<body>
+  <img src="photo.jpg"/>
+  <img src="icon.png" alt="Icon"/>
+  <img src="banner.jpg"/>
+</body>
+</html>`,
+		`<?xml version="1.0" encoding="UTF-8"?>
+<html lang="en">
+<head><title>Test</title></head>
+<body><p>Hello</p></body>
+</html>`,
+		"<html>\n<body>\n<p>unclosed",
+	}
+
+	for _, fixture := range fixtures {
+		v := &Validator{}
+		lf := v.Validate("chapter.xhtml", []byte(fixture), nil)
+		crlf := v.Validate("chapter.xhtml", testutil.CRLF([]byte(fixture)), nil)
+		testutil.ExpectSameDiagnostics(t, lf, crlf)
+	}
+}
@@ -0,0 +1,282 @@
+// Package logging sends a language server's log to a file in the user's
+// cache directory, rotated by size, to stderr, or nowhere, and lets the
+// destination change while the server runs.
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaxFileSize is the size past which a log file is rotated.
+const MaxFileSize = 5_000_000
+
+// DefaultMaxFiles is the number of rotated log files kept when none is
+// configured.
+const DefaultMaxFiles = 3
+
+// FilePermissions are the permissions of log files.
+const FilePermissions = 0600
+
+// Sinks a log can be sent to.
+const (
+	// SinkFile appends to a log file, rotating it as it grows.
+	SinkFile = "file"
+	// SinkStderr writes to standard error, for runs where the process's
+	// output is captured, as in a container.
+	SinkStderr = "stderr"
+	// SinkOff discards the log.
+	SinkOff = "off"
+)
+
+// ValidSink reports whether name is SinkFile, SinkStderr, or SinkOff.
+func ValidSink(name string) bool {
+	return name == SinkFile || name == SinkStderr || name == SinkOff
+}
+
+// DefaultPath returns the log file for appName in the user's cache
+// directory.
+func DefaultPath(appName string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appName, appName+".log"), nil
+}
+
+// Output is the destination of a log, which Use can switch between sinks.
+// Until Use is first called it discards what is written. It is safe for
+// concurrent use.
+type Output struct {
+	// Stderr is where SinkStderr writes. When nil, os.Stderr is used.
+	Stderr io.Writer
+
+	mu       sync.Mutex
+	w        io.Writer
+	file     *File
+	sink     string
+	path     string
+	maxFiles int
+}
+
+// Use sends what is written to o to sink. For SinkFile, that is the file
+// at path, which is rotated past MaxFileSize keeping maxFiles rotated
+// files. Switching to the sink already in use changes nothing. When the
+// file cannot be opened, o keeps its previous sink.
+func (o *Output) Use(sink, path string, maxFiles int) error {
+	if !ValidSink(sink) {
+		return fmt.Errorf("unknown log sink %q", sink)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if sink == o.sink && (sink != SinkFile || path == o.path && maxFiles == o.maxFiles) {
+		return nil
+	}
+
+	var w io.Writer
+	var file *File
+	switch sink {
+	case SinkFile:
+		var err error
+		if file, err = OpenFile(path, MaxFileSize, maxFiles); err != nil {
+			return err
+		}
+		w = file
+	case SinkStderr:
+		w = o.Stderr
+		if w == nil {
+			w = os.Stderr
+		}
+	case SinkOff:
+		w = io.Discard
+	}
+
+	previous := o.file
+	o.w, o.file, o.sink, o.path, o.maxFiles = w, file, sink, path, maxFiles
+	if previous != nil {
+		return previous.Close()
+	}
+	return nil
+}
+
+// Write writes p to the sink in use.
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.w == nil {
+		return len(p), nil
+	}
+	return o.w.Write(p)
+}
+
+// Close closes the log file, if one is in use, and discards what is
+// written after.
+func (o *Output) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	file := o.file
+	o.w, o.file, o.sink = io.Discard, nil, SinkOff
+	if file != nil {
+		return file.Close()
+	}
+	return nil
+}
+
+// File appends to a log file, moving it to path + ".1" when a write would
+// take it past its maximum size, and the older rotated files up one
+// number, so that at most maxFiles rotated files are kept. It is safe for
+// concurrent use.
+type File struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	maxSize  int64
+	maxFiles int
+}
+
+// OpenFile opens the log file at path for appending, creating its
+// directory if needed. A file already past maxSize is rotated first, and
+// rotated files numbered above maxFiles are removed.
+func OpenFile(path string, maxSize int64, maxFiles int) (*File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	f := &File{path: path, maxSize: maxSize, maxFiles: max(maxFiles, 0)}
+	if err := f.prune(); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() >= maxSize {
+		if err := f.shift(); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile( //nolint:gosec // path chosen by the user or DefaultPath
+		f.path,
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		FilePermissions,
+	)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p to the log file, rotating it first when p would take it
+// past its maximum size.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate closes the full log file, shifts it aside, and starts a new one.
+// The caller must hold f.mu.
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	if err := f.shift(); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// shift moves each rotated file up one number, dropping the oldest, and
+// the log file to path + ".1", or removes the log file when no rotated
+// files are kept.
+func (f *File) shift() error {
+	if f.maxFiles == 0 {
+		return ignoreNotExist(os.Remove(f.path))
+	}
+	if err := ignoreNotExist(os.Remove(f.rotated(f.maxFiles))); err != nil {
+		return err
+	}
+	for n := f.maxFiles - 1; n >= 1; n-- {
+		if err := ignoreNotExist(os.Rename(f.rotated(n), f.rotated(n+1))); err != nil {
+			return err
+		}
+	}
+	return ignoreNotExist(os.Rename(f.path, f.rotated(1)))
+}
+
+// prune removes rotated files numbered above maxFiles, left by a run that
+// kept more.
+func (f *File) prune() error {
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return err
+	}
+	prefix := filepath.Base(f.path) + "."
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(suffix); err != nil || n <= f.maxFiles {
+			continue
+		}
+		path := filepath.Join(filepath.Dir(f.path), entry.Name())
+		if err := ignoreNotExist(os.Remove(path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotated returns the path of the rotated file numbered n.
+func (f *File) rotated(n int) string {
+	return f.path + "." + strconv.Itoa(n)
+}
+
+// Close closes the log file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// ignoreNotExist returns err unless it reports a missing file.
+func ignoreNotExist(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readFile returns the content of path, or "" when it does not exist.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path) //nolint:gosec // test paths
+	if errors.Is(err, fs.ErrNotExist) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFile_RotatesAtSizeThreshold(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "epub-lsp.log")
+	f, err := OpenFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+		path + ".3": "",
+	}
+	for p, content := range want {
+		if got := readFile(t, p); got != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(p), got, content)
+		}
+	}
+}
+
+func TestOpenFile_RotatesFullFileAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "epub-lsp.log")
+	files := map[string]string{
+		path:        "previous session\n",
+		path + ".1": "one\n",
+		path + ".2": "two\n",
+		path + ".5": "left by a run keeping more\n",
+		path + ".x": "not rotated\n",
+	}
+	for p, content := range files {
+		if err := os.WriteFile(p, []byte(content), FilePermissions); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := OpenFile(path, int64(len("previous session\n")), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	want := map[string]string{
+		path:        "",
+		path + ".1": "previous session\n",
+		path + ".2": "one\n",
+		path + ".3": "",
+		path + ".5": "",
+		path + ".x": "not rotated\n",
+	}
+	for p, content := range want {
+		if got := readFile(t, p); got != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(p), got, content)
+		}
+	}
+}
+
+func TestOpenFile_AppendsBelowThreshold(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "epub-lsp.log")
+	if err := os.WriteFile(path, []byte("kept\n"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFile(path, MaxFileSize, DefaultMaxFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("added\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "kept\nadded\n" {
+		t.Errorf("log = %q, want the earlier session kept", got)
+	}
+}
+
+func TestOutput_Stderr(t *testing.T) {
+	dir := t.TempDir()
+	var stderr bytes.Buffer
+	o := &Output{Stderr: &stderr}
+	if err := o.Use(SinkStderr, filepath.Join(dir, "epub-lsp.log"), DefaultMaxFiles); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Write([]byte("to stderr\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if stderr.String() != "to stderr\n" {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected nothing written to disk, found %d entries", len(entries))
+	}
+}
+
+func TestOutput_Switch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "epub-lsp.log")
+	var stderr bytes.Buffer
+	o := &Output{Stderr: &stderr}
+	defer o.Close()
+
+	if _, err := o.Write([]byte("before any sink\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Use(SinkFile, path, DefaultMaxFiles); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Write([]byte("to file\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Use(SinkOff, path, DefaultMaxFiles); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Write([]byte("dropped\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, path); got != "to file\n" {
+		t.Errorf("log = %q, want only the line written to the file sink", got)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("stderr = %q, want nothing", stderr.String())
+	}
+	if err := o.Use("syslog", path, 1); err == nil || !strings.Contains(err.Error(), "syslog") {
+		t.Errorf("expected an unknown sink error, got %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+// Package replay records the messages a language server receives and plays
+// them back, so a session from a bug report can be reproduced.
+//
+// A session file is a sequence of records, each the decimal length of a
+// message body, a newline, the body, and another newline. Each record is
+// written with a single call, so a crash leaves at most one truncated
+// record at the end, which Reader drops.
+package replay
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// MaxFileSize is the size past which a session file is rotated.
+const MaxFileSize = 50_000_000
+
+// FilePermissions are the permissions of session files.
+const FilePermissions = 0600
+
+// ErrMalformed reports a record whose length prefix is not a number or
+// whose body is not followed by a newline.
+var ErrMalformed = errors.New("malformed session record")
+
+// DefaultPath returns the session file for appName in the user's cache
+// directory, next to its log file.
+func DefaultPath(appName string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appName, appName+".session"), nil
+}
+
+// Recorder appends message bodies to a session file. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	size    int64
+	maxSize int64
+}
+
+// Create starts a session file at path, creating its directory if needed.
+// A file already there, from the previous session, is moved to path +
+// ".1", as is the new file once it grows past maxSize, so the latest
+// records are always at path.
+func Create(path string, maxSize int64) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(path, path+".1"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	r := &Recorder{path: path, maxSize: maxSize}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) open() error {
+	file, err := os.OpenFile( //nolint:gosec // path chosen by the user or DefaultPath
+		r.path,
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		FilePermissions,
+	)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+// Record appends body to the session file as one record.
+func (r *Recorder) Record(body []byte) error {
+	record := make([]byte, 0, len(body)+24)
+	record = strconv.AppendInt(record, int64(len(body)), 10)
+	record = append(record, '\n')
+	record = append(record, body...)
+	record = append(record, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(record)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.file.Write(record)
+	r.size += int64(n)
+	return err
+}
+
+// rotate moves the full session file aside and starts a new one. The
+// caller must hold r.mu.
+func (r *Recorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// Close closes the session file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Reader reads the message bodies of a session file in the order they were
+// recorded.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a Reader over the session file read from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next message body. It returns io.EOF at the end of the
+// file, including when the last record was cut short.
+func (r *Reader) Next() ([]byte, error) {
+	line, err := r.r.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	n, err := strconv.Atoi(line[:len(line)-1])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%w: length %q", ErrMalformed, line[:len(line)-1])
+	}
+
+	record := make([]byte, n+1)
+	if _, err := io.ReadFull(r.r, record); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	if record[n] != '\n' {
+		return nil, fmt.Errorf("%w: body is not %d bytes", ErrMalformed, n)
+	}
+	return record[:n], nil
+}
+
+// Frame returns a reader of the session file read from r with each
+// message re-framed by a Content-Length header, as a client sends it. A
+// malformed record ends the stream with its error. Closing the returned
+// reader stops reading r.
+func Frame(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		sessions := NewReader(r)
+		for {
+			body, err := sessions.Next()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+			header := "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n"
+			if _, err := io.WriteString(pw, header); err != nil {
+				return
+			}
+			if _, err := pw.Write(body); err != nil {
+				return
+			}
+		}
+	}()
+	return pr
+}
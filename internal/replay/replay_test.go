@@ -0,0 +1,153 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/toba/lsp/transport"
+)
+
+var messages = [][]byte{
+	[]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`),
+	[]byte(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"text":"a\nb\r\nc"}}`),
+	[]byte(""),
+	[]byte(`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`),
+}
+
+// record writes msgs to a new session file and returns its path.
+func record(t *testing.T, msgs [][]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cache", "epub-lsp.session")
+	r, err := Create(path, MaxFileSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range msgs {
+		if err := r.Record(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readAll returns the message bodies in the session file data.
+func readAll(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var got [][]byte
+	r := NewReader(bytes.NewReader(data))
+	for {
+		body, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, body)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data, err := os.ReadFile(record(t, messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, data); !slices.EqualFunc(got, messages, bytes.Equal) {
+		t.Errorf("read %q, want %q", got, messages)
+	}
+}
+
+func TestTruncatedRecordDropped(t *testing.T) {
+	data, err := os.ReadFile(record(t, messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cut the last record at every point short of its end
+	last := len(data) - len(messages[3]) - len("44\n\n")
+	for cut := last; cut < len(data); cut++ {
+		got := readAll(t, data[:cut])
+		if !slices.EqualFunc(got, messages[:3], bytes.Equal) {
+			t.Fatalf("cut at %d: read %q", cut, got)
+		}
+	}
+}
+
+func TestMalformedRecord(t *testing.T) {
+	for _, data := range []string{"x\n{}\n", "1\n{}\n"} {
+		_, err := NewReader(strings.NewReader(data)).Next()
+		if !errors.Is(err, ErrMalformed) {
+			t.Errorf("%q: expected ErrMalformed, got %v", data, err)
+		}
+	}
+}
+
+func TestFrame(t *testing.T) {
+	file, err := os.Open(record(t, messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	framed := Frame(file)
+	defer framed.Close()
+
+	var got [][]byte
+	scanner := transport.NewScanner(framed)
+	for scanner.Scan() {
+		got = append(got, slices.Clone(scanner.Bytes()))
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(got, messages, bytes.Equal) {
+		t.Errorf("framed %q, want %q", got, messages)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "epub-lsp.session")
+	if err := os.WriteFile(path, []byte("2\n{}\n"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Create(path, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// The previous session is kept aside
+	previous, err := os.ReadFile(path + ".1")
+	if err != nil || string(previous) != "2\n{}\n" {
+		t.Fatalf("previous session = %q, %v", previous, err)
+	}
+
+	for _, msg := range messages {
+		if err := r.Record(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first three records fill the file, so the last starts a new one
+	rotated, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := append(readAll(t, rotated), readAll(t, current)...); !slices.EqualFunc(got, messages, bytes.Equal) {
+		t.Errorf("read %q, want %q", got, messages)
+	}
+	if len(current) > 150 {
+		t.Errorf("current file is %d bytes, over the cap", len(current))
+	}
+}